@@ -15,13 +15,16 @@ package main
 
 import (
 	"context"
+	"crypto/x509"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/spf13/pflag"
 	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -35,7 +38,7 @@ import (
 	awsv1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
 	azurev1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
 	gcpv1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
-	ibmpowervsv1 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
+	ibmcloudv1 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
 	openstackv1 "sigs.k8s.io/cluster-api-provider-openstack/api/v1beta1"
 	vspherev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
@@ -58,6 +61,7 @@ import (
 	"github.com/openshift/cluster-capi-operator/pkg/controllers/kubeconfig"
 	"github.com/openshift/cluster-capi-operator/pkg/controllers/secretsync"
 	"github.com/openshift/cluster-capi-operator/pkg/controllers/unsupported"
+	"github.com/openshift/cluster-capi-operator/pkg/operatorconfig"
 	"github.com/openshift/cluster-capi-operator/pkg/operatorstatus"
 	"github.com/openshift/cluster-capi-operator/pkg/util"
 	"github.com/openshift/cluster-capi-operator/pkg/webhook"
@@ -77,7 +81,7 @@ func initScheme(scheme *runtime.Scheme) {
 	utilruntime.Must(gcpv1.AddToScheme(scheme))
 	utilruntime.Must(clusterv1.AddToScheme(scheme))
 	utilruntime.Must(clusterctlv1.AddToScheme(scheme))
-	utilruntime.Must(ibmpowervsv1.AddToScheme(scheme))
+	utilruntime.Must(ibmcloudv1.AddToScheme(scheme))
 	utilruntime.Must(openstackv1.AddToScheme(scheme))
 	utilruntime.Must(vspherev1.AddToScheme(scheme))
 	utilruntime.Must(mapiv1.AddToScheme(scheme))
@@ -104,6 +108,11 @@ func main() {
 		":9440",
 		"The address for health checking.",
 	)
+	diagnosticsCertDir := flag.String(
+		"diagnostics-cert-dir",
+		"",
+		"The directory of the serving certificate of the diagnostics endpoint, a self-signed certificate is generated when empty.",
+	)
 	managedNamespace := flag.String(
 		"namespace",
 		controllers.DefaultManagedNamespace,
@@ -114,6 +123,16 @@ func main() {
 		defaultImagesLocation,
 		"The location of images file to use by operator for managed CAPI binaries.",
 	)
+	kubeconfigTokenLifetime := flag.Duration(
+		"kubeconfig-token-lifetime",
+		kubeconfig.DefaultTokenLifetime,
+		"The lifetime of the token of the kubeconfig of the CAPI controllers, i.e. its rotation interval. Must be at least 10m.",
+	)
+	kubeconfigTokenRenewBefore := flag.Duration(
+		"kubeconfig-token-renew-before",
+		kubeconfig.DefaultTokenRenewBefore,
+		"How long before its expiry the token of the kubeconfig of the CAPI controllers is renewed.",
+	)
 	webhookPort := flag.Int(
 		"webhook-port",
 		9443,
@@ -157,13 +176,17 @@ func main() {
 		os.Exit(1)
 	}
 
+	diagnosticsOpts.CertDir = *diagnosticsCertDir
+
 	syncPeriod := 10 * time.Minute
+	gracefulShutdownTimeout := util.GracefulShutdownTimeout
 
 	cacheOpts := cache.Options{
 		DefaultNamespaces: map[string]cache.Config{
 			*managedNamespace:                {},
 			secretsync.SecretSourceNamespace: {},
 			"kube-system":                    {}, // For fetching cloud credentials.
+			"openshift-config":               {}, // For fetching the cloud provider config.
 		},
 		SyncPeriod: &syncPeriod,
 	}
@@ -180,7 +203,11 @@ func main() {
 		LeaderElectionID:        leaderElectionConfig.ResourceName,
 		RetryPeriod:             &leaderElectionConfig.RetryPeriod.Duration,
 		RenewDeadline:           &leaderElectionConfig.RenewDeadline.Duration,
-		Cache:                   cacheOpts,
+		// Releasing the lease on shutdown lets the next pod take over without waiting for it to expire,
+		// which is safe as the process exits as soon as the manager has stopped.
+		LeaderElectionReleaseOnCancel: true,
+		GracefulShutdownTimeout:       &gracefulShutdownTimeout,
+		Cache:                         cacheOpts,
 		WebhookServer: crwebhook.NewServer(crwebhook.Options{
 			Port:    *webhookPort,
 			CertDir: *webhookCertDir,
@@ -191,6 +218,9 @@ func main() {
 		os.Exit(1)
 	}
 
+	// This will catch signals from the OS and shutdown the manager gracefully.
+	stop := ctrl.SetupSignalHandler()
+
 	applyClient, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
 		klog.Error(err, "unable to set up apply client")
@@ -209,19 +239,41 @@ func main() {
 		os.Exit(1)
 	}
 
-	infra, err := util.GetInfra(context.Background(), mgr.GetAPIReader())
+	infra, err := util.GetInfra(stop, mgr.GetAPIReader())
 	if err != nil {
 		klog.Error(err, "unable to get infrastructure object")
 		os.Exit(1)
 	}
 
-	platform, err := util.GetPlatform(context.Background(), infra)
+	platform, err := util.GetPlatform(stop, infra)
 	if err != nil {
 		klog.Error(err, "unable to get platform from infrastructure object")
 		os.Exit(1)
 	}
 
-	setupPlatformReconcilers(mgr, infra, platform, containerImages, applyClient, apiextensionsClient, *managedNamespace)
+	featureGateAccessor, err := util.GetFeatureGates(cfg, "cluster-capi-operator")
+	if err != nil {
+		klog.Error(err, "unable to get feature gates")
+		os.Exit(1)
+	}
+
+	currentFeatureGates, err := featureGateAccessor.CurrentFeatureGates()
+	if err != nil {
+		klog.Error(err, "unable to get current feature gates")
+		os.Exit(1)
+	}
+
+	lifecycleProvidersEnabled := util.IsFeatureGateEnabled(currentFeatureGates, capiinstaller.FeatureGateClusterAPILifecycleProviders)
+
+	setupPlatformReconcilers(mgr, infra, platform, containerImages, applyClient, apiextensionsClient, *managedNamespace, lifecycleProvidersEnabled, *kubeconfigTokenLifetime, *kubeconfigTokenRenewBefore)
+	setupWebhookCertificateEvents(mgr, *webhookCertDir, *managedNamespace)
+
+	if err := (&operatorconfig.LogLevelReconciler{
+		Verbosity: textLoggerConfig.Verbosity(),
+	}).SetupWithManager(mgr); err != nil {
+		klog.Error(err, "unable to create controller", "controller", "LogLevel")
+		os.Exit(1)
+	}
 
 	// +kubebuilder:scaffold:builder
 
@@ -237,7 +289,7 @@ func main() {
 
 	klog.Info("Starting manager")
 
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(stop); err != nil {
 		klog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
@@ -252,15 +304,15 @@ func getClusterOperatorStatusClient(mgr manager.Manager, controller string, mana
 	}
 }
 
-func setupPlatformReconcilers(mgr manager.Manager, infra *configv1.Infrastructure, platform configv1.PlatformType, containerImages map[string]string, applyClient *kubernetes.Clientset, apiextensionsClient *apiextensionsclient.Clientset, managedNamespace string) {
+func setupPlatformReconcilers(mgr manager.Manager, infra *configv1.Infrastructure, platform configv1.PlatformType, containerImages map[string]string, applyClient *kubernetes.Clientset, apiextensionsClient *apiextensionsclient.Clientset, managedNamespace string, lifecycleProvidersEnabled bool, kubeconfigTokenLifetime, kubeconfigTokenRenewBefore time.Duration) {
 	// Only setup reconcile controllers and webhooks when the platform is supported.
 	// This avoids unnecessary CAPI providers discovery, installs and reconciles when the platform is not supported.
 	switch platform {
 	case configv1.AWSPlatformType:
-		setupReconcilers(mgr, infra, platform, &awsv1.AWSCluster{}, containerImages, applyClient, apiextensionsClient, managedNamespace)
+		setupReconcilers(mgr, infra, platform, &awsv1.AWSCluster{}, containerImages, applyClient, apiextensionsClient, managedNamespace, lifecycleProvidersEnabled, kubeconfigTokenLifetime, kubeconfigTokenRenewBefore)
 		setupWebhooks(mgr)
 	case configv1.GCPPlatformType:
-		setupReconcilers(mgr, infra, platform, &gcpv1.GCPCluster{}, containerImages, applyClient, apiextensionsClient, managedNamespace)
+		setupReconcilers(mgr, infra, platform, &gcpv1.GCPCluster{}, containerImages, applyClient, apiextensionsClient, managedNamespace, lifecycleProvidersEnabled, kubeconfigTokenLifetime, kubeconfigTokenRenewBefore)
 		setupWebhooks(mgr)
 	case configv1.AzurePlatformType:
 		azureCloudEnvironment := getAzureCloudEnvironment(infra.Status.PlatformStatus)
@@ -268,17 +320,26 @@ func setupPlatformReconcilers(mgr manager.Manager, infra *configv1.Infrastructur
 			klog.Infof("Detected Azure Cloud Environment %q on platform %q is not supported, skipping capi controllers setup", azureCloudEnvironment, platform)
 			setupUnsupportedController(mgr, managedNamespace)
 		} else {
-			setupReconcilers(mgr, infra, platform, &azurev1.AzureCluster{}, containerImages, applyClient, apiextensionsClient, managedNamespace)
+			setupReconcilers(mgr, infra, platform, &azurev1.AzureCluster{}, containerImages, applyClient, apiextensionsClient, managedNamespace, lifecycleProvidersEnabled, kubeconfigTokenLifetime, kubeconfigTokenRenewBefore)
 			setupWebhooks(mgr)
 		}
 	case configv1.PowerVSPlatformType:
-		setupReconcilers(mgr, infra, platform, &ibmpowervsv1.IBMPowerVSCluster{}, containerImages, applyClient, apiextensionsClient, managedNamespace)
+		setupReconcilers(mgr, infra, platform, &ibmcloudv1.IBMPowerVSCluster{}, containerImages, applyClient, apiextensionsClient, managedNamespace, lifecycleProvidersEnabled, kubeconfigTokenLifetime, kubeconfigTokenRenewBefore)
+		setupWebhooks(mgr)
+	case configv1.IBMCloudPlatformType:
+		setupReconcilers(mgr, infra, platform, &ibmcloudv1.IBMVPCCluster{}, containerImages, applyClient, apiextensionsClient, managedNamespace, lifecycleProvidersEnabled, kubeconfigTokenLifetime, kubeconfigTokenRenewBefore)
 		setupWebhooks(mgr)
 	case configv1.VSpherePlatformType:
-		setupReconcilers(mgr, infra, platform, &vspherev1.VSphereCluster{}, containerImages, applyClient, apiextensionsClient, managedNamespace)
+		setupReconcilers(mgr, infra, platform, &vspherev1.VSphereCluster{}, containerImages, applyClient, apiextensionsClient, managedNamespace, lifecycleProvidersEnabled, kubeconfigTokenLifetime, kubeconfigTokenRenewBefore)
 		setupWebhooks(mgr)
 	case configv1.OpenStackPlatformType:
-		setupReconcilers(mgr, infra, platform, &openstackv1.OpenStackCluster{}, containerImages, applyClient, apiextensionsClient, managedNamespace)
+		setupReconcilers(mgr, infra, platform, &openstackv1.OpenStackCluster{}, containerImages, applyClient, apiextensionsClient, managedNamespace, lifecycleProvidersEnabled, kubeconfigTokenLifetime, kubeconfigTokenRenewBefore)
+		setupWebhooks(mgr)
+	case configv1.NutanixPlatformType:
+		setupReconcilers(mgr, infra, platform, infracluster.NewNutanixCluster(), containerImages, applyClient, apiextensionsClient, managedNamespace, lifecycleProvidersEnabled, kubeconfigTokenLifetime, kubeconfigTokenRenewBefore)
+		setupWebhooks(mgr)
+	case configv1.BareMetalPlatformType:
+		setupReconcilers(mgr, infra, platform, infracluster.NewMetal3Cluster(), containerImages, applyClient, apiextensionsClient, managedNamespace, lifecycleProvidersEnabled, kubeconfigTokenLifetime, kubeconfigTokenRenewBefore)
 		setupWebhooks(mgr)
 	default:
 		klog.Infof("Detected platform %q is not supported, skipping capi controllers setup", platform)
@@ -286,7 +347,7 @@ func setupPlatformReconcilers(mgr manager.Manager, infra *configv1.Infrastructur
 	}
 }
 
-func setupReconcilers(mgr manager.Manager, infra *configv1.Infrastructure, platform configv1.PlatformType, infraClusterObject client.Object, containerImages map[string]string, applyClient *kubernetes.Clientset, apiextensionsClient *apiextensionsclient.Clientset, managedNamespace string) {
+func setupReconcilers(mgr manager.Manager, infra *configv1.Infrastructure, platform configv1.PlatformType, infraClusterObject client.Object, containerImages map[string]string, applyClient *kubernetes.Clientset, apiextensionsClient *apiextensionsclient.Clientset, managedNamespace string, lifecycleProvidersEnabled bool, kubeconfigTokenLifetime, kubeconfigTokenRenewBefore time.Duration) {
 	if err := (&cluster.CoreClusterReconciler{
 		ClusterOperatorStatusClient: getClusterOperatorStatusClient(mgr, "cluster-capi-operator-cluster-resource-controller", managedNamespace),
 		Cluster:                     &clusterv1.Cluster{},
@@ -307,6 +368,10 @@ func setupReconcilers(mgr manager.Manager, infra *configv1.Infrastructure, platf
 		ClusterOperatorStatusClient: getClusterOperatorStatusClient(mgr, "cluster-capi-operator-kubeconfig-controller", managedNamespace),
 		Scheme:                      mgr.GetScheme(),
 		RestCfg:                     mgr.GetConfig(),
+		TokenClient:                 applyClient.CoreV1().ServiceAccounts(managedNamespace),
+		SecretsClient:               applyClient.CoreV1(),
+		TokenLifetime:               kubeconfigTokenLifetime,
+		TokenRenewBefore:            kubeconfigTokenRenewBefore,
 	}).SetupWithManager(mgr); err != nil {
 		klog.Error(err, "unable to create controller", "controller", "Kubeconfig")
 		os.Exit(1)
@@ -320,11 +385,19 @@ func setupReconcilers(mgr manager.Manager, infra *configv1.Infrastructure, platf
 		Platform:                    platform,
 		ApplyClient:                 applyClient,
 		APIExtensionsClient:         apiextensionsClient,
+		LifecycleProvidersEnabled:   lifecycleProvidersEnabled,
 	}).SetupWithManager(mgr); err != nil {
 		klog.Error(err, "unable to create capi installer controller", "controller", "CAPIInstaller")
 		os.Exit(1)
 	}
 
+	if err := (&capiinstaller.OperandHealthController{
+		ClusterOperatorStatusClient: getClusterOperatorStatusClient(mgr, "cluster-capi-operator-operand-health-controller", managedNamespace),
+	}).SetupWithManager(mgr); err != nil {
+		klog.Error(err, "unable to create operand health controller", "controller", "OperandHealth")
+		os.Exit(1)
+	}
+
 	if err := (&infracluster.InfraClusterController{
 		ClusterOperatorStatusClient: getClusterOperatorStatusClient(mgr, "cluster-capi-operator-infracluster-controller", managedNamespace),
 		Scheme:                      mgr.GetScheme(),
@@ -343,6 +416,55 @@ func setupWebhooks(mgr ctrl.Manager) {
 		klog.Error(err, "unable to create webhook", "webhook", "Cluster")
 		os.Exit(1)
 	}
+
+	if err := (&webhook.MirrorWebhook{}).SetupWebhookWithManager(mgr); err != nil {
+		klog.Error(err, "unable to create webhook", "webhook", "Mirror")
+		os.Exit(1)
+	}
+
+	if err := (&webhook.MachineTemplateWebhook{}).SetupWebhookWithManager(mgr); err != nil {
+		klog.Error(err, "unable to create webhook", "webhook", "MachineTemplate")
+		os.Exit(1)
+	}
+
+	if err := (&webhook.InfraClusterWebhook{}).SetupWebhookWithManager(mgr); err != nil {
+		klog.Error(err, "unable to create webhook", "webhook", "InfraCluster")
+		os.Exit(1)
+	}
+
+	if err := (&webhook.MachineNamespaceWebhook{}).SetupWebhookWithManager(mgr); err != nil {
+		klog.Error(err, "unable to create webhook", "webhook", "MachineNamespace")
+		os.Exit(1)
+	}
+
+	if err := (&webhook.MachineSetScaleGuardWebhook{}).SetupWebhookWithManager(mgr); err != nil {
+		klog.Error(err, "unable to create webhook", "webhook", "MachineSetScaleGuard")
+		os.Exit(1)
+	}
+
+	if err := (&webhook.MachineDefaulterWebhook{}).SetupWebhookWithManager(mgr); err != nil {
+		klog.Error(err, "unable to create webhook", "webhook", "MachineDefaulter")
+		os.Exit(1)
+	}
+}
+
+// setupWebhookCertificateEvents records an Event on the ClusterOperator when the webhook serving certificate is renewed.
+// The certificate is not watched when it is missing, e.g. when running the operator outside of the cluster.
+func setupWebhookCertificateEvents(mgr manager.Manager, certDir string, managedNamespace string) {
+	if _, err := os.Stat(filepath.Join(certDir, "tls.crt")); err != nil {
+		klog.Infof("Webhook serving certificate not found in %q, not watching its renewal", certDir)
+		return
+	}
+
+	statusClient := getClusterOperatorStatusClient(mgr, "cluster-capi-operator-webhook", managedNamespace)
+
+	if err := webhook.WatchCertificateRenewal(mgr, certDir, func(cert *x509.Certificate) {
+		statusClient.RecordEvent(context.Background(), corev1.EventTypeNormal, "WebhookCertificateRenewed",
+			fmt.Sprintf("Renewed the webhook serving certificate, valid until %s", cert.NotAfter.UTC().Format(time.RFC3339)))
+	}); err != nil {
+		klog.Error(err, "unable to watch webhook serving certificate renewal")
+		os.Exit(1)
+	}
 }
 
 // setFeatureGatesEnvVars sets the explicit values for the listed feature gates in the environment.
@@ -14,25 +14,24 @@
 package main
 
 import (
-	"context"
 	"errors"
 	"flag"
-	"fmt"
 	"os"
 	"time"
 
 	configv1 "github.com/openshift/api/config/v1"
 	mapiv1beta1 "github.com/openshift/api/machine/v1beta1"
-	configv1client "github.com/openshift/client-go/config/clientset/versioned"
-	configinformers "github.com/openshift/client-go/config/informers/externalversions"
+	awscloud "github.com/openshift/cluster-capi-operator/pkg/cloud/aws"
 	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+	"github.com/openshift/cluster-capi-operator/pkg/controllers/bulkmigration"
 	"github.com/openshift/cluster-capi-operator/pkg/controllers/machinesetsync"
 	"github.com/openshift/cluster-capi-operator/pkg/controllers/machinesync"
+	"github.com/openshift/cluster-capi-operator/pkg/controllers/migrationstatus"
+	"github.com/openshift/cluster-capi-operator/pkg/operatorconfig"
+	"github.com/openshift/cluster-capi-operator/pkg/operatorstatus"
 	"github.com/openshift/cluster-capi-operator/pkg/util"
 
 	"github.com/openshift/api/features"
-	featuregates "github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
-	"github.com/openshift/library-go/pkg/operator/events"
 	"github.com/spf13/pflag"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
@@ -40,6 +39,13 @@ import (
 	"k8s.io/component-base/config/options"
 	klog "k8s.io/klog/v2"
 	"k8s.io/klog/v2/textlogger"
+	awsv1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	azurev1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	gcpv1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	ibmpowervsv1 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
+	openstackv1 "sigs.k8s.io/cluster-api-provider-openstack/api/v1beta1"
+	vspherev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	capiflags "sigs.k8s.io/cluster-api/util/flags"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/cache"
@@ -48,9 +54,6 @@ import (
 )
 
 var (
-	// errTimedOutWaitingForFeatureGates is returned when the feature gates are not initialized within the timeout.
-	errTimedOutWaitingForFeatureGates = errors.New("timed out waiting for feature gates to be initialized")
-
 	// errPlatformNotFound is returned when there is no platform set on the infrastructure object.
 	errPlatformNotFound = errors.New("no platform provider found on install config")
 )
@@ -59,6 +62,13 @@ func initScheme(scheme *runtime.Scheme) {
 	// TODO(joelspeed): Add additional schemes here once we work out exactly which will be needed.
 	utilruntime.Must(mapiv1beta1.AddToScheme(scheme))
 	utilruntime.Must(configv1.AddToScheme(scheme))
+	utilruntime.Must(clusterv1.AddToScheme(scheme))
+	utilruntime.Must(awsv1.AddToScheme(scheme))
+	utilruntime.Must(azurev1.AddToScheme(scheme))
+	utilruntime.Must(gcpv1.AddToScheme(scheme))
+	utilruntime.Must(openstackv1.AddToScheme(scheme))
+	utilruntime.Must(ibmpowervsv1.AddToScheme(scheme))
+	utilruntime.Must(vspherev1.AddToScheme(scheme))
 }
 
 //nolint:funlen
@@ -75,15 +85,18 @@ func main() {
 		ResourceNamespace: "openshift-cluster-api",
 	}
 
-	capiManagerOptions := capiflags.ManagerOptions{
-		MetricsBindAddr: ":8081",
-	}
+	capiManagerOptions := capiflags.ManagerOptions{}
 
 	healthAddr := flag.String(
 		"health-addr",
 		":9441",
 		"The address for health checking.",
 	)
+	diagnosticsCertDir := flag.String(
+		"diagnostics-cert-dir",
+		"",
+		"The directory of the serving certificate of the diagnostics endpoint, a self-signed certificate is generated when empty.",
+	)
 	capiManagedNamespace := flag.String(
 		"capi-namespace",
 		controllers.DefaultManagedNamespace,
@@ -95,6 +108,26 @@ func main() {
 		"The namespace to watch for MAPI resources.",
 	)
 
+	azureManagedBootDiagnostics := flag.Bool(
+		"azure-managed-boot-diagnostics",
+		false,
+		"Enable managed boot diagnostics on AzureMachineTemplates converted from MachineSets that do not configure boot diagnostics. "+
+			"The azureManagedBootDiagnostics feature of the ClusterCAPIOperatorConfig takes precedence.",
+	)
+
+	trustedCABundleFile := flag.String(
+		"trusted-ca-bundle-file",
+		"",
+		"The trusted CA bundle of the cluster, trusted along with the system CAs by the cloud API clients, e.g. to reach them through the cluster proxy.",
+	)
+
+	controlPlaneMigration := flag.Bool(
+		"control-plane-migration",
+		false,
+		"Mirror the control plane Machines managed by the ControlPlaneMachineSet into CAPI Machines. "+
+			"The controlPlaneMigration feature of the ClusterCAPIOperatorConfig takes precedence.",
+	)
+
 	logToStderr := flag.Bool(
 		"logtostderr",
 		true,
@@ -122,7 +155,10 @@ func main() {
 		os.Exit(1)
 	}
 
+	diagnosticsOpts.CertDir = *diagnosticsCertDir
+
 	syncPeriod := 10 * time.Minute
+	gracefulShutdownTimeout := util.GracefulShutdownTimeout
 
 	cacheOpts := cache.Options{
 		DefaultNamespaces: map[string]cache.Config{
@@ -144,7 +180,11 @@ func main() {
 		LeaderElectionID:        leaderElectionConfig.ResourceName,
 		RetryPeriod:             &leaderElectionConfig.RetryPeriod.Duration,
 		RenewDeadline:           &leaderElectionConfig.RenewDeadline.Duration,
-		Cache:                   cacheOpts,
+		// Releasing the lease on shutdown lets the next pod take over without waiting for it to expire,
+		// which is safe as the process exits as soon as the manager has stopped.
+		LeaderElectionReleaseOnCancel: true,
+		GracefulShutdownTimeout:       &gracefulShutdownTimeout,
+		Cache:                         cacheOpts,
 	})
 	if err != nil {
 		klog.Error(err, "unable to create manager")
@@ -155,7 +195,7 @@ func main() {
 	// Set it up here as we may need to branch early if the feature gate is not enabled.
 	stop := ctrl.SetupSignalHandler()
 
-	featureGateAccessor, err := getFeatureGates(mgr)
+	featureGateAccessor, err := util.GetFeatureGates(mgr.GetConfig(), "machineapimigration")
 	if err != nil {
 		klog.Error(err, "unable to get feature gates")
 		os.Exit(1)
@@ -187,10 +227,20 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Currently we only plan to support AWS, so all others are a noop until they're implemented.
+	// Only AWS, Azure, GCP, OpenStack, PowerVS and vSphere are supported so far, all others are a noop until they're implemented.
 	switch provider {
 	case configv1.AWSPlatformType:
 		klog.Info("MachineAPIMigration: starting AWS controllers")
+	case configv1.AzurePlatformType:
+		klog.Info("MachineAPIMigration: starting Azure controllers")
+	case configv1.GCPPlatformType:
+		klog.Info("MachineAPIMigration: starting GCP controllers")
+	case configv1.OpenStackPlatformType:
+		klog.Info("MachineAPIMigration: starting OpenStack controllers")
+	case configv1.PowerVSPlatformType:
+		klog.Info("MachineAPIMigration: starting PowerVS controllers")
+	case configv1.VSpherePlatformType:
+		klog.Info("MachineAPIMigration: starting vSphere controllers")
 
 	default:
 		klog.Infof("MachineAPIMigration not implemented for platform %s, nothing to do. Waiting for termination signal.", provider)
@@ -210,11 +260,32 @@ func main() {
 		os.Exit(1)
 	}
 
+	if err := (&operatorconfig.LogLevelReconciler{
+		Verbosity: textLoggerConfig.Verbosity(),
+	}).SetupWithManager(mgr); err != nil {
+		klog.Error(err, "failed to set up log level reconciler with manager")
+		os.Exit(1)
+	}
+
 	machineSyncReconciler := machinesync.MachineSyncReconciler{
+		Infra:    infra,
 		Platform: provider,
 
 		MAPINamespace: *mapiManagedNamespace,
 		CAPINamespace: *capiManagedNamespace,
+
+		ControlPlaneMigration: *controlPlaneMigration,
+
+		OperatorStatus: &operatorstatus.ClusterOperatorStatusClient{
+			Client:           mgr.GetClient(),
+			Recorder:         mgr.GetEventRecorderFor("machine-sync-controller"),
+			ReleaseVersion:   util.GetReleaseVersion(),
+			ManagedNamespace: *capiManagedNamespace,
+		},
+	}
+
+	if provider == configv1.AzurePlatformType {
+		machineSyncReconciler.AzureManagedBootDiagnostics = *azureManagedBootDiagnostics
 	}
 
 	if err := machineSyncReconciler.SetupWithManager(mgr); err != nil {
@@ -223,57 +294,60 @@ func main() {
 	}
 
 	machineSetSyncReconciler := machinesetsync.MachineSetSyncReconciler{
+		Infra:    infra,
 		Platform: provider,
 
 		MAPINamespace: *mapiManagedNamespace,
 		CAPINamespace: *capiManagedNamespace,
 	}
 
+	switch provider {
+	case configv1.AWSPlatformType:
+		iamClientOptions := awscloud.IAMClientOptions{TrustedCABundleFile: *trustedCABundleFile}
+		if infra.Status.PlatformStatus != nil && infra.Status.PlatformStatus.AWS != nil {
+			iamClientOptions.ServiceEndpoints = infra.Status.PlatformStatus.AWS.ServiceEndpoints
+		}
+
+		machineSetSyncReconciler.AWSIAMClient = awscloud.NewIAMInstanceProfileClient(mgr.GetClient(), iamClientOptions)
+	case configv1.AzurePlatformType:
+		machineSetSyncReconciler.AzureManagedBootDiagnostics = *azureManagedBootDiagnostics
+	}
+
 	if err := machineSetSyncReconciler.SetupWithManager(mgr); err != nil {
 		klog.Error(err, "failed to set up machineset sync reconciler with manager")
 		os.Exit(1)
 	}
 
-	klog.Info("Starting manager")
+	bulkMigrationReconciler := bulkmigration.BulkMigrationReconciler{
+		MAPINamespace: *mapiManagedNamespace,
+	}
 
-	if err := mgr.Start(stop); err != nil {
-		klog.Error(err, "problem running manager")
+	if err := bulkMigrationReconciler.SetupWithManager(mgr); err != nil {
+		klog.Error(err, "failed to set up bulk migration reconciler with manager")
 		os.Exit(1)
 	}
-}
 
-// getFeatureGates is used to fetch the current feature gates from the cluster.
-// We use this to check if the machine api migration is actually enabled or not.
-func getFeatureGates(mgr ctrl.Manager) (featuregates.FeatureGateAccess, error) {
-	desiredVersion := util.GetReleaseVersion()
-	missingVersion := "0.0.1-snapshot"
+	migrationStatusReconciler := migrationstatus.MigrationStatusReconciler{
+		ClusterOperatorStatusClient: operatorstatus.ClusterOperatorStatusClient{
+			Client:           mgr.GetClient(),
+			Recorder:         mgr.GetEventRecorderFor("migration-status-controller"),
+			ReleaseVersion:   util.GetReleaseVersion(),
+			ManagedNamespace: *capiManagedNamespace,
+		},
+		MAPINamespace: *mapiManagedNamespace,
+	}
 
-	configClient, err := configv1client.NewForConfig(mgr.GetConfig())
-	if err != nil {
-		return nil, fmt.Errorf("failed to create config client: %w", err)
+	if err := migrationStatusReconciler.SetupWithManager(mgr); err != nil {
+		klog.Error(err, "failed to set up migration status reconciler with manager")
+		os.Exit(1)
 	}
 
-	configInformers := configinformers.NewSharedInformerFactory(configClient, 10*time.Minute)
+	klog.Info("Starting manager")
 
-	// By default, this will exit(0) if the featuregates change.
-	featureGateAccessor := featuregates.NewFeatureGateAccess(
-		desiredVersion, missingVersion,
-		configInformers.Config().V1().ClusterVersions(),
-		configInformers.Config().V1().FeatureGates(),
-		events.NewLoggingEventRecorder("machineapimigration"),
-	)
-	go featureGateAccessor.Run(context.Background())
-	go configInformers.Start(context.Background().Done())
-
-	select {
-	case <-featureGateAccessor.InitialFeatureGatesObserved():
-		featureGates, _ := featureGateAccessor.CurrentFeatureGates()
-		klog.Infof("FeatureGates initialized: %v", featureGates.KnownFeatures())
-	case <-time.After(1 * time.Minute):
-		return nil, errTimedOutWaitingForFeatureGates
+	if err := mgr.Start(stop); err != nil {
+		klog.Error(err, "problem running manager")
+		os.Exit(1)
 	}
-
-	return featureGateAccessor, nil
 }
 
 // getProviderFromInfrastructure returns the PlatformType from the Infrastructure object.
@@ -15,8 +15,8 @@ import (
 	mapiv1 "github.com/openshift/api/machine/v1beta1"
 	"github.com/openshift/cluster-capi-operator/e2e/framework"
 	corev1 "k8s.io/api/core/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	awsv1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -25,6 +25,13 @@ import (
 
 const (
 	awsMachineTemplateName = "aws-machine-template"
+	// awsCapacityReservationMachineTemplateName is not shared with the other specs, so the template cannot be mistaken
+	// for the one of a previous spec, which would not set the capacity reservation.
+	awsCapacityReservationMachineTemplateName = "aws-machine-template-capacity-reservation"
+	// awsEFAMachineTemplateName is not shared with the other specs for the same reason, the template sets the EFA network interface.
+	awsEFAMachineTemplateName = "aws-machine-template-efa"
+	// awsEFAInstanceType is one of the smallest instance types supporting Elastic Fabric Adapters.
+	awsEFAInstanceType = "c5n.9xlarge"
 )
 
 var _ = Describe("Cluster API AWS MachineSet", Ordered, func() {
@@ -54,13 +61,12 @@ var _ = Describe("Cluster API AWS MachineSet", Ordered, func() {
 		framework.DeleteMachineSets(cl, machineSet)
 		framework.WaitForMachineSetsDeleted(cl, machineSet)
 		framework.DeleteObjects(cl, awsMachineTemplate)
+		framework.WaitForObjectsDeleted(cl, awsMachineTemplate)
 	})
 
 	It("should be able to run a machine with a default provider spec", func() {
-		awsMachineTemplate = newAWSMachineTemplate(mapiDefaultProviderSpec)
-		if err := cl.Create(ctx, awsMachineTemplate); err != nil && !apierrors.IsAlreadyExists(err) {
-			Expect(err).ToNot(HaveOccurred())
-		}
+		awsMachineTemplate = newAWSMachineTemplate(mapiDefaultProviderSpec, awsMachineTemplateName)
+		framework.CreateMachineTemplate(cl, awsMachineTemplate)
 
 		machineSet = framework.CreateMachineSet(cl, framework.NewMachineSetParams(
 			"aws-machineset",
@@ -78,6 +84,73 @@ var _ = Describe("Cluster API AWS MachineSet", Ordered, func() {
 
 		compareInstances(awsClient, mapiDefaultMS.Name, "aws-machineset")
 	})
+
+	It("should be able to run a machine in an existing capacity reservation", func() {
+		capacityReservationID := createAWSCapacityReservation(awsClient, mapiDefaultProviderSpec.InstanceType, mapiDefaultProviderSpec.Placement.AvailabilityZone)
+		DeferCleanup(cancelAWSCapacityReservation, awsClient, capacityReservationID)
+
+		awsMachineTemplate = newAWSMachineTemplate(mapiDefaultProviderSpec, awsCapacityReservationMachineTemplateName)
+
+		// The capacityReservationId field is newer than the CAPA API types vendored here,
+		// so set it on an unstructured copy of the template.
+		unstructuredTemplate := toUnstructuredAWSMachineTemplate(awsMachineTemplate)
+		Expect(unstructured.SetNestedField(unstructuredTemplate.Object, capacityReservationID,
+			"spec", "template", "spec", "capacityReservationId")).To(Succeed())
+
+		framework.CreateMachineTemplate(cl, unstructuredTemplate)
+
+		machineSet = framework.CreateMachineSet(cl, framework.NewMachineSetParams(
+			"aws-machineset-capacity-reservation",
+			clusterName,
+			mapiDefaultProviderSpec.Placement.AvailabilityZone,
+			1,
+			corev1.ObjectReference{
+				Kind:       "AWSMachineTemplate",
+				APIVersion: infraAPIVersion,
+				Name:       awsCapacityReservationMachineTemplateName,
+			},
+		))
+
+		framework.WaitForMachineSet(cl, machineSet.Name)
+
+		By("Verifying the instance was launched into the capacity reservation")
+		instance := getCAPICreatedInstance(awsClient, machineSet.Name)
+		Expect(instance.CapacityReservationId).To(HaveValue(Equal(capacityReservationID)),
+			"expected the instance to be launched into the capacity reservation")
+	})
+
+	It("should be able to run a machine with an EFA network interface", func() {
+		awsMachineTemplate = newAWSMachineTemplate(mapiDefaultProviderSpec, awsEFAMachineTemplateName)
+		awsMachineTemplate.Spec.Template.Spec.InstanceType = awsEFAInstanceType
+
+		// The networkInterfaceType field is newer than the CAPA API types vendored here,
+		// so set it on an unstructured copy of the template.
+		unstructuredTemplate := toUnstructuredAWSMachineTemplate(awsMachineTemplate)
+		Expect(unstructured.SetNestedField(unstructuredTemplate.Object, "efa",
+			"spec", "template", "spec", "networkInterfaceType")).To(Succeed())
+
+		framework.CreateMachineTemplate(cl, unstructuredTemplate)
+
+		machineSet = framework.CreateMachineSet(cl, framework.NewMachineSetParams(
+			"aws-machineset-efa",
+			clusterName,
+			mapiDefaultProviderSpec.Placement.AvailabilityZone,
+			1,
+			corev1.ObjectReference{
+				Kind:       "AWSMachineTemplate",
+				APIVersion: infraAPIVersion,
+				Name:       awsEFAMachineTemplateName,
+			},
+		))
+
+		framework.WaitForMachineSet(cl, machineSet.Name)
+
+		By("Verifying the instance was launched with an EFA network interface")
+		instance := getCAPICreatedInstance(awsClient, machineSet.Name)
+		Expect(instance.NetworkInterfaces).ToNot(BeEmpty())
+		Expect(instance.NetworkInterfaces[0].InterfaceType).To(HaveValue(Equal("efa")),
+			"expected the instance to be launched with an EFA network interface")
+	})
 })
 
 func getDefaultAWSMAPIProviderSpec(cl client.Client) (*mapiv1.MachineSet, *mapiv1.AWSMachineProviderConfig) {
@@ -94,69 +167,6 @@ func getDefaultAWSMAPIProviderSpec(cl client.Client) (*mapiv1.MachineSet, *mapiv
 	return machineSet, providerSpec
 }
 
-func newAWSMachineTemplate(mapiProviderSpec *mapiv1.AWSMachineProviderConfig) *awsv1.AWSMachineTemplate {
-	By("Creating AWS machine template")
-
-	Expect(mapiProviderSpec).ToNot(BeNil())
-	Expect(mapiProviderSpec.IAMInstanceProfile).ToNot(BeNil())
-	Expect(mapiProviderSpec.IAMInstanceProfile.ID).ToNot(BeNil())
-	Expect(mapiProviderSpec.InstanceType).ToNot(BeEmpty())
-	Expect(mapiProviderSpec.Placement.AvailabilityZone).ToNot(BeEmpty())
-	Expect(mapiProviderSpec.AMI.ID).ToNot(BeNil())
-	Expect(mapiProviderSpec.Subnet.Filters).ToNot(HaveLen(0))
-	Expect(mapiProviderSpec.Subnet.Filters[0].Values).ToNot(HaveLen(0))
-	Expect(mapiProviderSpec.SecurityGroups).ToNot(HaveLen(0))
-	Expect(mapiProviderSpec.SecurityGroups[0].Filters).ToNot(HaveLen(0))
-	Expect(mapiProviderSpec.SecurityGroups[0].Filters[0].Values).ToNot(HaveLen(0))
-
-	uncompressedUserData := true
-
-	awsMachineSpec := awsv1.AWSMachineSpec{
-		UncompressedUserData: &uncompressedUserData,
-		IAMInstanceProfile:   *mapiProviderSpec.IAMInstanceProfile.ID,
-		InstanceType:         mapiProviderSpec.InstanceType,
-		AMI: awsv1.AMIReference{
-			ID: mapiProviderSpec.AMI.ID,
-		},
-		Ignition: &awsv1.Ignition{
-			Version:     "3.4",
-			StorageType: awsv1.IgnitionStorageTypeOptionUnencryptedUserData,
-		},
-		Subnet: &awsv1.AWSResourceReference{
-			Filters: []awsv1.Filter{
-				{
-					Name:   "tag:Name",
-					Values: mapiProviderSpec.Subnet.Filters[0].Values,
-				},
-			},
-		},
-		AdditionalSecurityGroups: []awsv1.AWSResourceReference{
-			{
-				Filters: []awsv1.Filter{
-					{
-						Name:   "tag:Name",
-						Values: mapiProviderSpec.SecurityGroups[0].Filters[0].Values,
-					},
-				},
-			},
-		},
-	}
-
-	awsMachineTemplate := &awsv1.AWSMachineTemplate{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      awsMachineTemplateName,
-			Namespace: framework.CAPINamespace,
-		},
-		Spec: awsv1.AWSMachineTemplateSpec{
-			Template: awsv1.AWSMachineTemplateResource{
-				Spec: awsMachineSpec,
-			},
-		},
-	}
-
-	return awsMachineTemplate
-}
-
 func createAWSClient(region string) *ec2.EC2 {
 	var secret corev1.Secret
 	Expect(cl.Get(context.Background(), client.ObjectKey{
@@ -291,3 +301,48 @@ func compareInstances(awsClient *ec2.EC2, mapiMsName, capiMsName string) {
 		GinkgoWriter.Print("Instances created by MAPI and CAPI are not equal\n" + cmp.Diff(mapiEC2Instance, capiEC2Instance, cmpOpts...))
 	}
 }
+
+func createAWSCapacityReservation(awsClient *ec2.EC2, instanceType, availabilityZone string) string {
+	By("Creating an AWS On-Demand Capacity Reservation")
+
+	Expect(awsClient).ToNot(BeNil())
+	Expect(instanceType).ToNot(BeEmpty())
+	Expect(availabilityZone).ToNot(BeEmpty())
+
+	result, err := awsClient.CreateCapacityReservation(&ec2.CreateCapacityReservationInput{
+		AvailabilityZone:      aws.String(availabilityZone),
+		EndDateType:           aws.String(ec2.EndDateTypeUnlimited),
+		InstanceCount:         aws.Int64(1),
+		InstanceMatchCriteria: aws.String(ec2.InstanceMatchCriteriaTargeted),
+		InstancePlatform:      aws.String(ec2.CapacityReservationInstancePlatformLinuxUnix),
+		InstanceType:          aws.String(instanceType),
+	})
+	Expect(err).ToNot(HaveOccurred(), "should not fail creating a capacity reservation")
+	Expect(result.CapacityReservation).ToNot(BeNil())
+	Expect(result.CapacityReservation.CapacityReservationId).ToNot(BeNil())
+
+	return *result.CapacityReservation.CapacityReservationId
+}
+
+func newAWSMachineTemplate(mapiProviderSpec *mapiv1.AWSMachineProviderConfig, name string) *awsv1.AWSMachineTemplate {
+	return framework.NewMachineTemplateFromMAPI(cl, configv1.AWSPlatformType, mapiProviderSpec, clusterName, name).(*awsv1.AWSMachineTemplate)
+}
+
+func toUnstructuredAWSMachineTemplate(awsMachineTemplate *awsv1.AWSMachineTemplate) *unstructured.Unstructured {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(awsMachineTemplate)
+	Expect(err).ToNot(HaveOccurred(), "should not fail converting the AWSMachineTemplate to unstructured")
+
+	unstructuredTemplate := &unstructured.Unstructured{Object: content}
+	unstructuredTemplate.SetGroupVersionKind(awsv1.GroupVersion.WithKind("AWSMachineTemplate"))
+
+	return unstructuredTemplate
+}
+
+func cancelAWSCapacityReservation(awsClient *ec2.EC2, capacityReservationID string) {
+	By("Cancelling the AWS On-Demand Capacity Reservation")
+
+	_, err := awsClient.CancelCapacityReservation(&ec2.CancelCapacityReservationInput{
+		CapacityReservationId: aws.String(capacityReservationID),
+	})
+	Expect(err).ToNot(HaveOccurred(), "should not fail cancelling the capacity reservation")
+}
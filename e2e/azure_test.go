@@ -1,19 +1,12 @@
 package e2e
 
 import (
-	"context"
-	"fmt"
-
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	configv1 "github.com/openshift/api/config/v1"
 	mapiv1 "github.com/openshift/api/machine/v1beta1"
 	"github.com/openshift/cluster-capi-operator/e2e/framework"
 	corev1 "k8s.io/api/core/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
-	ptr "k8s.io/utils/ptr"
 	azurev1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -24,6 +17,10 @@ const (
 	azureMachineTemplateName        = "azure-machine-template"
 	clusterSecretName               = "capz-manager-cluster-credential"
 	capzManagerBootstrapCredentials = "capz-manager-bootstrap-credentials"
+
+	// azureEphemeralOSDiskVMSize has a cache disk large enough to hold the default OS disk of the MAPI MachineSets,
+	// which is where Azure places ephemeral OS disks by default.
+	azureEphemeralOSDiskVMSize = "Standard_D8s_v3"
 )
 
 var _ = Describe("Cluster API Azure MachineSet", Ordered, func() {
@@ -35,6 +32,11 @@ var _ = Describe("Cluster API Azure MachineSet", Ordered, func() {
 		if platform != configv1.AzurePlatformType {
 			Skip("Skipping Azure E2E tests")
 		}
+		if azurePlatformStatus := mapiInfrastructure.Status.PlatformStatus.Azure; azurePlatformStatus != nil &&
+			azurePlatformStatus.CloudName == configv1.AzureStackCloud {
+			// CAPZ only supports the named Azure clouds, so no AzureCluster is created on Azure Stack Hub.
+			Skip("Skipping Azure E2E tests, Azure Stack Hub is not supported by CAPZ")
+		}
 		framework.CreateCoreCluster(cl, clusterName, "AzureCluster")
 		mapiMachineSpec = getAzureMAPIProviderSpec(cl)
 	})
@@ -45,13 +47,31 @@ var _ = Describe("Cluster API Azure MachineSet", Ordered, func() {
 			// explicitly skip it here for other platforms.
 			Skip("Skipping Azure E2E tests")
 		}
+		if machineSet == nil {
+			return
+		}
 		framework.DeleteMachineSets(cl, machineSet)
 		framework.WaitForMachineSetsDeleted(cl, machineSet)
 		framework.DeleteObjects(cl, azureMachineTemplate)
+		machineSet, azureMachineTemplate = nil, nil
+	})
+
+	It("should use the Azure environment of the cluster", func() {
+		expectedEnvironment := azurev1.DefaultAzureCloud
+		if azurePlatformStatus := mapiInfrastructure.Status.PlatformStatus.Azure; azurePlatformStatus != nil && azurePlatformStatus.CloudName != "" {
+			expectedEnvironment = string(azurePlatformStatus.CloudName)
+		}
+
+		azureCluster := &azurev1.AzureCluster{}
+		Eventually(func() error {
+			return cl.Get(ctx, client.ObjectKey{Namespace: framework.CAPINamespace, Name: clusterName}, azureCluster)
+		}, framework.WaitShort).Should(Succeed())
+		Expect(azureCluster.Spec.AzureEnvironment).To(Equal(expectedEnvironment))
 	})
 
 	It("should be able to run a machine", func() {
-		azureMachineTemplate = createAzureMachineTemplate(cl, mapiMachineSpec)
+		azureMachineTemplate = newAzureMachineTemplate(mapiMachineSpec)
+		framework.CreateMachineTemplate(cl, azureMachineTemplate)
 
 		machineSet = framework.CreateMachineSet(cl, framework.NewMachineSetParams(
 			"azure-machineset",
@@ -68,6 +88,43 @@ var _ = Describe("Cluster API Azure MachineSet", Ordered, func() {
 		framework.WaitForMachineSet(cl, machineSet.Name)
 	})
 
+	It("should be able to run a machine with an ephemeral OS disk", func() {
+		azureMachineTemplate = newAzureMachineTemplate(mapiMachineSpec)
+		azureMachineTemplate.Spec.Template.Spec.VMSize = azureEphemeralOSDiskVMSize
+		// Ephemeral OS disks only support read only caching.
+		azureMachineTemplate.Spec.Template.Spec.OSDisk.CachingType = "ReadOnly"
+		azureMachineTemplate.Spec.Template.Spec.OSDisk.DiffDiskSettings = &azurev1.DiffDiskSettings{
+			Option: "Local",
+		}
+
+		framework.CreateMachineTemplate(cl, azureMachineTemplate)
+
+		machineSet = framework.CreateMachineSet(cl, framework.NewMachineSetParams(
+			"azure-machineset-ephemeral",
+			clusterName,
+			"",
+			1,
+			corev1.ObjectReference{
+				Kind:       "AzureMachineTemplate",
+				APIVersion: infraAPIVersion,
+				Name:       azureMachineTemplateName,
+			},
+		))
+
+		framework.WaitForMachineSet(cl, machineSet.Name)
+
+		By("Verifying the machine was created with an ephemeral OS disk")
+		machines, err := framework.GetMachinesFromMachineSet(cl, machineSet)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(machines).To(HaveLen(1))
+
+		azureMachine := &azurev1.AzureMachine{}
+		Expect(cl.Get(ctx, client.ObjectKey{
+			Namespace: framework.CAPINamespace,
+			Name:      machines[0].Spec.InfrastructureRef.Name,
+		}, azureMachine)).To(Succeed())
+		Expect(azureMachine.Spec.OSDisk.DiffDiskSettings).To(HaveValue(HaveField("Option", Equal("Local"))))
+	})
 })
 
 func getAzureMAPIProviderSpec(cl client.Client) *mapiv1.AzureMachineProviderSpec {
@@ -84,69 +141,6 @@ func getAzureMAPIProviderSpec(cl client.Client) *mapiv1.AzureMachineProviderSpec
 	return providerSpec
 }
 
-func createAzureMachineTemplate(cl client.Client, mapiProviderSpec *mapiv1.AzureMachineProviderSpec) *azurev1.AzureMachineTemplate {
-	By("Creating Azure machine template")
-
-	Expect(mapiProviderSpec).ToNot(BeNil())
-	Expect(mapiProviderSpec.Subnet).ToNot(BeEmpty())
-	Expect(mapiProviderSpec.AcceleratedNetworking).ToNot(BeNil())
-	Expect(mapiProviderSpec.Image.ResourceID).ToNot(BeEmpty())
-	Expect(mapiProviderSpec.OSDisk.ManagedDisk.StorageAccountType).ToNot(BeEmpty())
-	Expect(mapiProviderSpec.OSDisk.DiskSizeGB).To(BeNumerically(">", 0))
-	Expect(mapiProviderSpec.OSDisk.OSType).ToNot(BeEmpty())
-	Expect(mapiProviderSpec.VMSize).ToNot(BeEmpty())
-
-	azure_credentials_secret := corev1.Secret{}
-	azure_credentials_secret_key := types.NamespacedName{Name: "capz-manager-bootstrap-credentials", Namespace: "openshift-cluster-api"}
-	err := cl.Get(context.Background(), azure_credentials_secret_key, &azure_credentials_secret)
-	Expect(err).To(BeNil(), "capz-manager-bootstrap-credentials secret should exist")
-	subscriptionID := azure_credentials_secret.Data["azure_subscription_id"]
-	azureImageID := fmt.Sprintf("/subscriptions/%s%s", subscriptionID, mapiProviderSpec.Image.ResourceID)
-	azureMachineSpec := azurev1.AzureMachineSpec{
-		Identity: azurev1.VMIdentityUserAssigned,
-		UserAssignedIdentities: []azurev1.UserAssignedIdentity{
-			{
-				ProviderID: fmt.Sprintf("azure:///subscriptions/%s/resourcegroups/%s/providers/Microsoft.ManagedIdentity/userAssignedIdentities/%s", subscriptionID, mapiProviderSpec.ResourceGroup, mapiProviderSpec.ManagedIdentity),
-			},
-		},
-		NetworkInterfaces: []azurev1.NetworkInterface{
-			{
-				PrivateIPConfigs:      1,
-				SubnetName:            mapiProviderSpec.Subnet,
-				AcceleratedNetworking: &mapiProviderSpec.AcceleratedNetworking,
-			},
-		},
-		Image: &azurev1.Image{
-			ID: &azureImageID,
-		},
-		OSDisk: azurev1.OSDisk{
-			DiskSizeGB: &mapiProviderSpec.OSDisk.DiskSizeGB,
-			ManagedDisk: &azurev1.ManagedDiskParameters{
-				StorageAccountType: mapiProviderSpec.OSDisk.ManagedDisk.StorageAccountType,
-			},
-			CachingType: mapiProviderSpec.OSDisk.CachingType,
-			OSType:      mapiProviderSpec.OSDisk.OSType,
-		},
-		DisableExtensionOperations: ptr.To(true),
-		SSHPublicKey:               mapiProviderSpec.SSHPublicKey,
-		VMSize:                     mapiProviderSpec.VMSize,
-	}
-
-	azureMachineTemplate := &azurev1.AzureMachineTemplate{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      azureMachineTemplateName,
-			Namespace: framework.CAPINamespace,
-		},
-		Spec: azurev1.AzureMachineTemplateSpec{
-			Template: azurev1.AzureMachineTemplateResource{
-				Spec: azureMachineSpec,
-			},
-		},
-	}
-
-	if err := cl.Create(ctx, azureMachineTemplate); err != nil && !apierrors.IsAlreadyExists(err) {
-		Expect(err).ToNot(HaveOccurred())
-	}
-
-	return azureMachineTemplate
+func newAzureMachineTemplate(mapiProviderSpec *mapiv1.AzureMachineProviderSpec) *azurev1.AzureMachineTemplate {
+	return framework.NewMachineTemplateFromMAPI(cl, configv1.AzurePlatformType, mapiProviderSpec, clusterName, azureMachineTemplateName).(*azurev1.AzureMachineTemplate)
 }
@@ -7,6 +7,7 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	awsv1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
 	azurev1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
@@ -19,6 +20,7 @@ import (
 
 	configv1 "github.com/openshift/api/config/v1"
 	mapiv1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/cluster-capi-operator/e2e/framework"
 )
 
 const (
@@ -29,6 +31,7 @@ const (
 
 var (
 	cl                 runtimeclient.Client
+	clientset          kubernetes.Interface
 	ctx                = context.Background()
 	platform           configv1.PlatformType
 	clusterName        string
@@ -58,6 +61,9 @@ var _ = BeforeSuite(func() {
 	cl, err = runtimeclient.New(cfg, runtimeclient.Options{})
 	Expect(err).ToNot(HaveOccurred())
 
+	clientset, err = kubernetes.NewForConfig(cfg)
+	Expect(err).ToNot(HaveOccurred())
+
 	infra := &configv1.Infrastructure{}
 	infraName := runtimeclient.ObjectKey{
 		Name: infrastructureName,
@@ -68,3 +74,11 @@ var _ = BeforeSuite(func() {
 	clusterName = infra.Status.InfrastructureName
 	platform = infra.Status.PlatformStatus.Type
 })
+
+// The artifacts are collected in a JustAfterEach, as it runs before the AfterEach of the specs,
+// which delete the resources the specs created.
+var _ = JustAfterEach(func() {
+	if CurrentSpecReport().Failed() {
+		framework.CollectArtifacts(cl, clientset, CurrentSpecReport().FullText())
+	}
+})
@@ -0,0 +1,186 @@
+package framework
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	mapiv1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	awsv1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	azurev1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	gcpv1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	ibmpowervsv1 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
+	vspherev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+)
+
+// ArtifactDirEnvVar is the environment variable CI sets to the directory collecting the artifacts of the job.
+const ArtifactDirEnvVar = "ARTIFACT_DIR"
+
+// unsafePathCharacters matches the characters of a spec name which are replaced in its artifacts directory name.
+var unsafePathCharacters = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// artifactLists are the CAPI and MAPI resources dumped by CollectArtifacts.
+// The resources of the providers not installed on the cluster are skipped.
+func artifactLists() []client.ObjectList {
+	return []client.ObjectList{
+		&clusterv1.ClusterList{},
+		&clusterv1.MachineDeploymentList{},
+		&clusterv1.MachineSetList{},
+		&clusterv1.MachineList{},
+		&awsv1.AWSClusterList{},
+		&awsv1.AWSMachineTemplateList{},
+		&awsv1.AWSMachineList{},
+		&azurev1.AzureClusterList{},
+		&azurev1.AzureMachineTemplateList{},
+		&azurev1.AzureMachineList{},
+		&gcpv1.GCPClusterList{},
+		&gcpv1.GCPMachineTemplateList{},
+		&gcpv1.GCPMachineList{},
+		&ibmpowervsv1.IBMPowerVSClusterList{},
+		&ibmpowervsv1.IBMPowerVSMachineTemplateList{},
+		&ibmpowervsv1.IBMPowerVSMachineList{},
+		&vspherev1.VSphereClusterList{},
+		&vspherev1.VSphereMachineTemplateList{},
+		&vspherev1.VSphereMachineList{},
+		&mapiv1beta1.MachineSetList{},
+		&mapiv1beta1.MachineList{},
+	}
+}
+
+// CollectArtifacts dumps the CAPI and MAPI resources, and the events and pod logs of the CAPI and MAPI namespaces,
+// which run the operator, the provider controllers and the machine API controllers, into a directory named after
+// the spec in ARTIFACT_DIR, so failures can be debugged after the cluster is gone.
+// Nothing is collected when ARTIFACT_DIR is not set. Collection errors are only logged, not to hide the spec failure.
+func CollectArtifacts(cl client.Client, clientset kubernetes.Interface, specName string) {
+	artifactDir := os.Getenv(ArtifactDirEnvVar)
+	if artifactDir == "" {
+		return
+	}
+
+	dir := filepath.Join(artifactDir, "e2e", strings.Trim(unsafePathCharacters.ReplaceAllString(specName, "-"), "-"))
+	By(fmt.Sprintf("Collecting artifacts into %s", dir))
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		GinkgoWriter.Printf("Failed to create artifacts directory %s: %v\n", dir, err)
+		return
+	}
+
+	for _, list := range artifactLists() {
+		collectResources(cl, dir, list)
+	}
+
+	for _, namespace := range []string{CAPINamespace, MAPINamespace} {
+		collectEvents(cl, dir, namespace)
+		collectPodLogs(clientset, dir, namespace)
+	}
+}
+
+// collectResources dumps the resources of the list, from all namespaces, into <resource>.<group>.yaml.
+func collectResources(cl client.Client, dir string, list client.ObjectList) {
+	gvk, err := apiutil.GVKForObject(list, cl.Scheme())
+	if err != nil {
+		GinkgoWriter.Printf("Failed to get the kind of %T: %v\n", list, err)
+		return
+	}
+
+	if err := cl.List(ctx, list); meta.IsNoMatchError(err) {
+		return
+	} else if err != nil {
+		GinkgoWriter.Printf("Failed to list %s: %v\n", gvk.Kind, err)
+		return
+	}
+
+	fileName := fmt.Sprintf("%s.%s.yaml", strings.ToLower(strings.TrimSuffix(gvk.Kind, "List")), gvk.Group)
+	writeYAMLArtifact(filepath.Join(dir, fileName), list)
+}
+
+// collectEvents dumps the events of the namespace into events-<namespace>.yaml, the most recent last.
+func collectEvents(cl client.Client, dir, namespace string) {
+	events := &corev1.EventList{}
+	if err := cl.List(ctx, events, client.InNamespace(namespace)); err != nil {
+		GinkgoWriter.Printf("Failed to list events in namespace %s: %v\n", namespace, err)
+		return
+	}
+
+	sort.SliceStable(events.Items, func(i, j int) bool {
+		return eventTime(events.Items[i]).Before(eventTime(events.Items[j]))
+	})
+
+	writeYAMLArtifact(filepath.Join(dir, fmt.Sprintf("events-%s.yaml", namespace)), events)
+}
+
+// eventTime returns the last time the event was observed.
+// Events created with the events.k8s.io API only have an event time.
+func eventTime(event corev1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+
+	return event.EventTime.Time
+}
+
+// collectPodLogs dumps the logs of the containers of the pods of the namespace into
+// logs/<namespace>/<pod>/<container>.log, with the logs of the previous instance of restarted containers.
+func collectPodLogs(clientset kubernetes.Interface, dir, namespace string) {
+	pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		GinkgoWriter.Printf("Failed to list pods in namespace %s: %v\n", namespace, err)
+		return
+	}
+
+	for _, pod := range pods.Items {
+		podDir := filepath.Join(dir, "logs", namespace, pod.Name)
+		if err := os.MkdirAll(podDir, 0o755); err != nil {
+			GinkgoWriter.Printf("Failed to create logs directory %s: %v\n", podDir, err)
+			continue
+		}
+
+		for _, status := range pod.Status.ContainerStatuses {
+			collectContainerLogs(clientset, pod, status.Name, false, filepath.Join(podDir, status.Name+".log"))
+
+			if status.RestartCount > 0 {
+				collectContainerLogs(clientset, pod, status.Name, true, filepath.Join(podDir, status.Name+".previous.log"))
+			}
+		}
+	}
+}
+
+func collectContainerLogs(clientset kubernetes.Interface, pod corev1.Pod, container string, previous bool, path string) {
+	logs, err := clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container: container,
+		Previous:  previous,
+	}).DoRaw(ctx)
+	if err != nil {
+		GinkgoWriter.Printf("Failed to get logs of container %s of pod %s/%s: %v\n", container, pod.Namespace, pod.Name, err)
+		return
+	}
+
+	if err := os.WriteFile(path, logs, 0o600); err != nil {
+		GinkgoWriter.Printf("Failed to write artifact %s: %v\n", path, err)
+	}
+}
+
+func writeYAMLArtifact(path string, obj interface{}) {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		GinkgoWriter.Printf("Failed to marshal artifact %s: %v\n", path, err)
+		return
+	}
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		GinkgoWriter.Printf("Failed to write artifact %s: %v\n", path, err)
+	}
+}
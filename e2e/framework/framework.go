@@ -7,7 +7,9 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 )
 
 const (
@@ -30,7 +32,27 @@ var (
 // DeleteObjects deletes the objects in the given list.
 func DeleteObjects(cl client.Client, objs ...client.Object) {
 	for _, o := range objs {
-		By(fmt.Sprintf("Deleting %s/%s", o.GetObjectKind().GroupVersionKind().Kind, o.GetName()))
+		By(fmt.Sprintf("Deleting %s/%s", kindOf(cl, o), o.GetName()))
 		Expect(cl.Delete(ctx, o)).To(Succeed())
 	}
 }
+
+// WaitForObjectsDeleted polls until the objects in the given list are not found.
+func WaitForObjectsDeleted(cl client.Client, objs ...client.Object) {
+	for _, o := range objs {
+		By(fmt.Sprintf("Waiting for %s/%s to be deleted", kindOf(cl, o), o.GetName()))
+		Eventually(func() bool {
+			err := cl.Get(ctx, client.ObjectKeyFromObject(o), o)
+			return apierrors.IsNotFound(err)
+		}, WaitMedium, RetryMedium).Should(BeTrue())
+	}
+}
+
+// kindOf returns the kind of the object from the scheme of the client,
+// as the TypeMeta of typed objects is empty once they have been read from the API.
+func kindOf(cl client.Client, o client.Object) string {
+	gvk, err := apiutil.GVKForObject(o, cl.Scheme())
+	Expect(err).ToNot(HaveOccurred())
+
+	return gvk.Kind
+}
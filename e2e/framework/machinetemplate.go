@@ -0,0 +1,332 @@
+package framework
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	awsv1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	azurev1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	gcpv1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	ibmpowervsv1 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
+	vspherev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	configv1 "github.com/openshift/api/config/v1"
+	mapiv1 "github.com/openshift/api/machine/v1"
+	mapiv1beta1 "github.com/openshift/api/machine/v1beta1"
+)
+
+// NewMachineTemplateFromMAPI returns the CAPI machine template of the platform, in the CAPI namespace,
+// built from the MAPI provider spec of the platform, e.g. a *mapiv1beta1.AWSMachineProviderConfig on AWS.
+// The template is not created, so tests can customize it first, see CreateMachineTemplate.
+func NewMachineTemplateFromMAPI(cl client.Client, platform configv1.PlatformType, mapiProviderSpec interface{}, clusterName, templateName string) client.Object {
+	By(fmt.Sprintf("Creating %s machine template", platform))
+
+	Expect(mapiProviderSpec).ToNot(BeNil())
+	Expect(templateName).ToNot(BeEmpty())
+
+	objectMeta := metav1.ObjectMeta{
+		Name:      templateName,
+		Namespace: CAPINamespace,
+	}
+
+	switch platform {
+	case configv1.AWSPlatformType:
+		Expect(mapiProviderSpec).To(BeAssignableToTypeOf(&mapiv1beta1.AWSMachineProviderConfig{}))
+
+		return &awsv1.AWSMachineTemplate{
+			ObjectMeta: objectMeta,
+			Spec: awsv1.AWSMachineTemplateSpec{
+				Template: awsv1.AWSMachineTemplateResource{
+					Spec: newAWSMachineSpec(mapiProviderSpec.(*mapiv1beta1.AWSMachineProviderConfig)),
+				},
+			},
+		}
+	case configv1.AzurePlatformType:
+		Expect(mapiProviderSpec).To(BeAssignableToTypeOf(&mapiv1beta1.AzureMachineProviderSpec{}))
+
+		return &azurev1.AzureMachineTemplate{
+			ObjectMeta: objectMeta,
+			Spec: azurev1.AzureMachineTemplateSpec{
+				Template: azurev1.AzureMachineTemplateResource{
+					Spec: newAzureMachineSpec(cl, mapiProviderSpec.(*mapiv1beta1.AzureMachineProviderSpec)),
+				},
+			},
+		}
+	case configv1.GCPPlatformType:
+		Expect(mapiProviderSpec).To(BeAssignableToTypeOf(&mapiv1beta1.GCPMachineProviderSpec{}))
+
+		return &gcpv1.GCPMachineTemplate{
+			ObjectMeta: objectMeta,
+			Spec: gcpv1.GCPMachineTemplateSpec{
+				Template: gcpv1.GCPMachineTemplateResource{
+					Spec: newGCPMachineSpec(mapiProviderSpec.(*mapiv1beta1.GCPMachineProviderSpec), clusterName),
+				},
+			},
+		}
+	case configv1.PowerVSPlatformType:
+		Expect(mapiProviderSpec).To(BeAssignableToTypeOf(&mapiv1.PowerVSMachineProviderConfig{}))
+
+		return &ibmpowervsv1.IBMPowerVSMachineTemplate{
+			ObjectMeta: objectMeta,
+			Spec: ibmpowervsv1.IBMPowerVSMachineTemplateSpec{
+				Template: ibmpowervsv1.IBMPowerVSMachineTemplateResource{
+					Spec: newPowerVSMachineSpec(mapiProviderSpec.(*mapiv1.PowerVSMachineProviderConfig)),
+				},
+			},
+		}
+	case configv1.VSpherePlatformType:
+		Expect(mapiProviderSpec).To(BeAssignableToTypeOf(&mapiv1beta1.VSphereMachineProviderSpec{}))
+
+		return &vspherev1.VSphereMachineTemplate{
+			ObjectMeta: objectMeta,
+			Spec: vspherev1.VSphereMachineTemplateSpec{
+				Template: vspherev1.VSphereMachineTemplateResource{
+					Spec: newVSphereMachineSpec(mapiProviderSpec.(*mapiv1beta1.VSphereMachineProviderSpec)),
+				},
+			},
+		}
+	default:
+		Fail(fmt.Sprintf("machine templates are not supported on platform %q", platform))
+		return nil
+	}
+}
+
+// CreateMachineTemplate creates the machine template, if it does not exist yet.
+func CreateMachineTemplate(cl client.Client, template client.Object) {
+	if err := cl.Create(ctx, template); err != nil && !apierrors.IsAlreadyExists(err) {
+		Expect(err).ToNot(HaveOccurred(), "should not fail creating the machine template")
+	}
+}
+
+func newAWSMachineSpec(mapiProviderSpec *mapiv1beta1.AWSMachineProviderConfig) awsv1.AWSMachineSpec {
+	Expect(mapiProviderSpec.IAMInstanceProfile).ToNot(BeNil())
+	Expect(mapiProviderSpec.IAMInstanceProfile.ID).ToNot(BeNil())
+	Expect(mapiProviderSpec.InstanceType).ToNot(BeEmpty())
+	Expect(mapiProviderSpec.Placement.AvailabilityZone).ToNot(BeEmpty())
+	Expect(mapiProviderSpec.AMI.ID).ToNot(BeNil())
+	Expect(mapiProviderSpec.Subnet.Filters).ToNot(HaveLen(0))
+	Expect(mapiProviderSpec.Subnet.Filters[0].Values).ToNot(HaveLen(0))
+	Expect(mapiProviderSpec.SecurityGroups).ToNot(HaveLen(0))
+	Expect(mapiProviderSpec.SecurityGroups[0].Filters).ToNot(HaveLen(0))
+	Expect(mapiProviderSpec.SecurityGroups[0].Filters[0].Values).ToNot(HaveLen(0))
+
+	return awsv1.AWSMachineSpec{
+		UncompressedUserData: ptr.To(true),
+		IAMInstanceProfile:   *mapiProviderSpec.IAMInstanceProfile.ID,
+		InstanceType:         mapiProviderSpec.InstanceType,
+		AMI: awsv1.AMIReference{
+			ID: mapiProviderSpec.AMI.ID,
+		},
+		Ignition: &awsv1.Ignition{
+			Version:     "3.4",
+			StorageType: awsv1.IgnitionStorageTypeOptionUnencryptedUserData,
+		},
+		Subnet: &awsv1.AWSResourceReference{
+			Filters: []awsv1.Filter{
+				{
+					Name:   "tag:Name",
+					Values: mapiProviderSpec.Subnet.Filters[0].Values,
+				},
+			},
+		},
+		AdditionalSecurityGroups: []awsv1.AWSResourceReference{
+			{
+				Filters: []awsv1.Filter{
+					{
+						Name:   "tag:Name",
+						Values: mapiProviderSpec.SecurityGroups[0].Filters[0].Values,
+					},
+				},
+			},
+		},
+	}
+}
+
+func newAzureMachineSpec(cl client.Client, mapiProviderSpec *mapiv1beta1.AzureMachineProviderSpec) azurev1.AzureMachineSpec {
+	Expect(mapiProviderSpec.Subnet).ToNot(BeEmpty())
+	Expect(mapiProviderSpec.AcceleratedNetworking).ToNot(BeNil())
+	Expect(mapiProviderSpec.Image.ResourceID != "" || mapiProviderSpec.Image.Publisher != "").To(BeTrue(), "image should reference a resource ID or a marketplace image")
+	Expect(mapiProviderSpec.OSDisk.ManagedDisk.StorageAccountType).ToNot(BeEmpty())
+	Expect(mapiProviderSpec.OSDisk.DiskSizeGB).To(BeNumerically(">", 0))
+	Expect(mapiProviderSpec.OSDisk.OSType).ToNot(BeEmpty())
+	Expect(mapiProviderSpec.VMSize).ToNot(BeEmpty())
+
+	azureCredentialsSecret := corev1.Secret{}
+	azureCredentialsSecretKey := types.NamespacedName{Name: "capz-manager-bootstrap-credentials", Namespace: CAPINamespace}
+	Expect(cl.Get(ctx, azureCredentialsSecretKey, &azureCredentialsSecret)).To(Succeed(), "capz-manager-bootstrap-credentials secret should exist")
+	subscriptionID := string(azureCredentialsSecret.Data["azure_subscription_id"])
+
+	return azurev1.AzureMachineSpec{
+		Identity: azurev1.VMIdentityUserAssigned,
+		UserAssignedIdentities: []azurev1.UserAssignedIdentity{
+			{
+				ProviderID: fmt.Sprintf("azure:///subscriptions/%s/resourcegroups/%s/providers/Microsoft.ManagedIdentity/userAssignedIdentities/%s", subscriptionID, mapiProviderSpec.ResourceGroup, mapiProviderSpec.ManagedIdentity),
+			},
+		},
+		NetworkInterfaces: []azurev1.NetworkInterface{
+			{
+				PrivateIPConfigs:      1,
+				SubnetName:            mapiProviderSpec.Subnet,
+				AcceleratedNetworking: &mapiProviderSpec.AcceleratedNetworking,
+			},
+		},
+		Image: newAzureImage(subscriptionID, mapiProviderSpec.Image),
+		OSDisk: azurev1.OSDisk{
+			DiskSizeGB: &mapiProviderSpec.OSDisk.DiskSizeGB,
+			ManagedDisk: &azurev1.ManagedDiskParameters{
+				StorageAccountType: mapiProviderSpec.OSDisk.ManagedDisk.StorageAccountType,
+			},
+			CachingType: mapiProviderSpec.OSDisk.CachingType,
+			OSType:      mapiProviderSpec.OSDisk.OSType,
+		},
+		DisableExtensionOperations: ptr.To(true),
+		SSHPublicKey:               mapiProviderSpec.SSHPublicKey,
+		VMSize:                     mapiProviderSpec.VMSize,
+	}
+}
+
+// newAzureImage builds the CAPZ image from the MAPI image of the cluster,
+// which is either a marketplace image or an image ID relative to the subscription.
+func newAzureImage(subscriptionID string, mapiImage mapiv1beta1.Image) *azurev1.Image {
+	if mapiImage.ResourceID == "" {
+		return &azurev1.Image{
+			Marketplace: &azurev1.AzureMarketplaceImage{
+				ImagePlan: azurev1.ImagePlan{
+					Publisher: mapiImage.Publisher,
+					Offer:     mapiImage.Offer,
+					SKU:       mapiImage.SKU,
+				},
+				Version:         mapiImage.Version,
+				ThirdPartyImage: mapiImage.Type == mapiv1beta1.AzureImageTypeMarketplaceWithPlan,
+			},
+		}
+	}
+
+	azureImageID := fmt.Sprintf("/subscriptions/%s%s", subscriptionID, mapiImage.ResourceID)
+
+	return &azurev1.Image{
+		ID: &azureImageID,
+	}
+}
+
+func newGCPMachineSpec(mapiProviderSpec *mapiv1beta1.GCPMachineProviderSpec, clusterName string) gcpv1.GCPMachineSpec {
+	Expect(mapiProviderSpec.Disks).ToNot(BeNil())
+	Expect(len(mapiProviderSpec.Disks)).To(BeNumerically(">", 0))
+	Expect(mapiProviderSpec.Disks[0].Type).ToNot(BeEmpty())
+	Expect(mapiProviderSpec.MachineType).ToNot(BeEmpty())
+	Expect(mapiProviderSpec.NetworkInterfaces).ToNot(BeNil())
+	Expect(len(mapiProviderSpec.NetworkInterfaces)).To(BeNumerically(">", 0))
+	Expect(mapiProviderSpec.NetworkInterfaces[0].Subnetwork).ToNot(BeEmpty())
+	Expect(mapiProviderSpec.ServiceAccounts).ToNot(BeNil())
+	Expect(len(mapiProviderSpec.ServiceAccounts)).To(BeNumerically(">", 0))
+	Expect(mapiProviderSpec.ServiceAccounts[0].Email).ToNot(BeEmpty())
+	Expect(mapiProviderSpec.ServiceAccounts[0].Scopes).ToNot(BeNil())
+	Expect(mapiProviderSpec.Tags).ToNot(BeNil())
+	Expect(len(mapiProviderSpec.Tags)).To(BeNumerically(">", 0))
+	Expect(clusterName).ToNot(BeEmpty())
+
+	var rootDeviceType gcpv1.DiskType
+	switch mapiProviderSpec.Disks[0].Type {
+	case "pd-standard":
+		rootDeviceType = gcpv1.PdStandardDiskType
+	case "pd-ssd":
+		rootDeviceType = gcpv1.PdSsdDiskType
+	case "local-ssd":
+		rootDeviceType = gcpv1.LocalSsdDiskType
+	}
+
+	return gcpv1.GCPMachineSpec{
+		RootDeviceType: &rootDeviceType,
+		RootDeviceSize: mapiProviderSpec.Disks[0].SizeGB,
+		InstanceType:   mapiProviderSpec.MachineType,
+		Image:          &mapiProviderSpec.Disks[0].Image,
+		Subnet:         &mapiProviderSpec.NetworkInterfaces[0].Subnetwork,
+		ServiceAccount: &gcpv1.ServiceAccount{
+			Email:  mapiProviderSpec.ServiceAccounts[0].Email,
+			Scopes: mapiProviderSpec.ServiceAccounts[0].Scopes,
+		},
+		AdditionalNetworkTags: mapiProviderSpec.Tags,
+		AdditionalLabels:      gcpv1.Labels{fmt.Sprintf("kubernetes-io-cluster-%s", clusterName): "owned"},
+		IPForwarding:          ptr.To(gcpv1.IPForwardingDisabled),
+	}
+}
+
+func newPowerVSMachineSpec(mapiProviderSpec *mapiv1.PowerVSMachineProviderConfig) ibmpowervsv1.IBMPowerVSMachineSpec {
+	Expect(mapiProviderSpec.KeyPairName).ToNot(BeEmpty())
+	Expect(mapiProviderSpec.SystemType).ToNot(BeEmpty())
+	Expect(mapiProviderSpec.ProcessorType).ToNot(BeEmpty())
+	Expect(mapiProviderSpec.MemoryGiB).To(BeNumerically(">", 0))
+
+	return ibmpowervsv1.IBMPowerVSMachineSpec{
+		ServiceInstance: ptr.To(PowerVSResourceReference(mapiProviderSpec.ServiceInstance)),
+		SSHKey:          mapiProviderSpec.KeyPairName,
+		Image:           ptr.To(PowerVSResourceReference(mapiProviderSpec.Image)),
+		SystemType:      mapiProviderSpec.SystemType,
+		ProcessorType:   ibmpowervsv1.PowerVSProcessorType(mapiProviderSpec.ProcessorType),
+		Processors:      mapiProviderSpec.Processors,
+		MemoryGiB:       mapiProviderSpec.MemoryGiB,
+		Network:         PowerVSResourceReference(mapiProviderSpec.Network),
+	}
+}
+
+// PowerVSResourceReference converts a MAPI PowerVS resource to a CAPIBM resource reference.
+// The MAPI resource type tells which of the fields references the resource.
+func PowerVSResourceReference(resource mapiv1.PowerVSResource) ibmpowervsv1.IBMPowerVSResourceReference {
+	switch resource.Type {
+	case mapiv1.PowerVSResourceTypeID:
+		Expect(resource.ID).ToNot(BeNil(), "resource reference is specified as ID but it is nil")
+		return ibmpowervsv1.IBMPowerVSResourceReference{
+			ID: resource.ID,
+		}
+	case mapiv1.PowerVSResourceTypeName:
+		Expect(resource.Name).ToNot(BeNil(), "resource reference is specified as Name but it is nil")
+		return ibmpowervsv1.IBMPowerVSResourceReference{
+			Name: resource.Name,
+		}
+	case mapiv1.PowerVSResourceTypeRegEx:
+		Expect(resource.RegEx).ToNot(BeNil(), "resource reference is specified as RegEx but it is nil")
+		return ibmpowervsv1.IBMPowerVSResourceReference{
+			RegEx: resource.RegEx,
+		}
+	default:
+		Fail(fmt.Sprintf("resource reference type %q is not supported", resource.Type))
+		return ibmpowervsv1.IBMPowerVSResourceReference{}
+	}
+}
+
+func newVSphereMachineSpec(mapiProviderSpec *mapiv1beta1.VSphereMachineProviderSpec) vspherev1.VSphereMachineSpec {
+	Expect(mapiProviderSpec.Network).ToNot(BeNil(), "expected MAPI ProviderSpec's network to not be nil")
+	Expect(len(mapiProviderSpec.Network.Devices)).To(BeNumerically(">", 0), "expected MAPI ProviderSpec's Network to have Devices")
+	Expect(mapiProviderSpec.Network.Devices[0].NetworkName).ToNot(BeEmpty(), "expected MAPI ProviderSpec's Network Device to have a network name")
+	Expect(mapiProviderSpec.Template).ToNot(BeEmpty(), "expected MAPI ProviderSpec's Template to not be empty")
+
+	return vspherev1.VSphereMachineSpec{
+		VirtualMachineCloneSpec: vspherev1.VirtualMachineCloneSpec{
+			Template:     mapiProviderSpec.Template,
+			Server:       mapiProviderSpec.Workspace.Server,
+			DiskGiB:      mapiProviderSpec.DiskGiB,
+			CloneMode:    vspherev1.CloneMode("linkedClone"),
+			Datacenter:   mapiProviderSpec.Workspace.Datacenter,
+			Datastore:    mapiProviderSpec.Workspace.Datastore,
+			Folder:       mapiProviderSpec.Workspace.Folder,
+			ResourcePool: mapiProviderSpec.Workspace.ResourcePool,
+			NumCPUs:      mapiProviderSpec.NumCPUs,
+			MemoryMiB:    mapiProviderSpec.MemoryMiB,
+			Network: vspherev1.NetworkSpec{
+				Devices: []vspherev1.NetworkDeviceSpec{
+					{
+						DHCP4:       true,
+						NetworkName: mapiProviderSpec.Network.Devices[0].NetworkName,
+					},
+				},
+			},
+		},
+	}
+}
@@ -2,12 +2,13 @@ package e2e
 
 import (
 	"fmt"
+	"os"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	ptr "k8s.io/utils/ptr"
 	gcpv1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -20,6 +21,14 @@ import (
 
 const (
 	gcpMachineTemplateName = "gcp-machine-template"
+	// gcpGPUMachineTemplateName is not shared with the other specs, so the template cannot be mistaken
+	// for the one of a previous spec, which would not set the GPU machine type.
+	gcpGPUMachineTemplateName = "gcp-machine-template-gpu"
+
+	// gcpGPUMachineTypeEnvVar names an accelerator optimized machine type, such as g2-standard-4, to run the GPU test with.
+	// The GPU test is skipped when it is not set, as it needs GPU quota and the NVIDIA GPU operator installed on the cluster.
+	gcpGPUMachineTypeEnvVar = "GCP_GPU_MACHINE_TYPE"
+	gcpGPUResourceName      = corev1.ResourceName("nvidia.com/gpu")
 )
 
 var _ = Describe("Cluster API GCP MachineSet", Ordered, func() {
@@ -41,13 +50,19 @@ var _ = Describe("Cluster API GCP MachineSet", Ordered, func() {
 			// explicitly skip it here for other platforms.
 			Skip("Skipping GCP E2E tests")
 		}
+		if machineSet == nil {
+			return
+		}
 		framework.DeleteMachineSets(cl, machineSet)
 		framework.WaitForMachineSetsDeleted(cl, machineSet)
 		framework.DeleteObjects(cl, gcpMachineTemplate)
+		framework.WaitForObjectsDeleted(cl, gcpMachineTemplate)
+		machineSet, gcpMachineTemplate = nil, nil
 	})
 
 	It("should be able to run a machine", func() {
-		gcpMachineTemplate = createGCPMachineTemplate(cl, mapiMachineSpec)
+		gcpMachineTemplate = newGCPMachineTemplate(mapiMachineSpec, gcpMachineTemplateName)
+		framework.CreateMachineTemplate(cl, gcpMachineTemplate)
 
 		machineSet = framework.CreateMachineSet(cl, framework.NewMachineSetParams(
 			"gcp-machineset",
@@ -63,6 +78,48 @@ var _ = Describe("Cluster API GCP MachineSet", Ordered, func() {
 
 		framework.WaitForMachineSet(cl, machineSet.Name)
 	})
+
+	It("should be able to run a machine with a GPU", func() {
+		gpuMachineType := os.Getenv(gcpGPUMachineTypeEnvVar)
+		if gpuMachineType == "" {
+			Skip(fmt.Sprintf("Skipping GCP GPU E2E test, %s is not set", gcpGPUMachineTypeEnvVar))
+		}
+
+		gcpMachineTemplate = newGCPMachineTemplate(mapiMachineSpec, gcpGPUMachineTemplateName)
+		gcpMachineTemplate.Spec.Template.Spec.InstanceType = gpuMachineType
+		// Instances with GPUs attached cannot be live migrated.
+		gcpMachineTemplate.Spec.Template.Spec.OnHostMaintenance = ptr.To(gcpv1.HostMaintenancePolicyTerminate)
+
+		framework.CreateMachineTemplate(cl, gcpMachineTemplate)
+
+		machineSet = framework.CreateMachineSet(cl, framework.NewMachineSetParams(
+			"gcp-machineset-gpu",
+			clusterName,
+			mapiMachineSpec.Zone,
+			1,
+			corev1.ObjectReference{
+				Kind:       "GCPMachineTemplate",
+				APIVersion: infraAPIVersion,
+				Name:       gcpGPUMachineTemplateName,
+			},
+		))
+
+		framework.WaitForMachineSet(cl, machineSet.Name)
+
+		By("Verifying the node advertises the GPU")
+		machines, err := framework.GetMachinesFromMachineSet(cl, machineSet)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(machines).To(HaveLen(1))
+
+		Eventually(func() (resource.Quantity, error) {
+			node, err := framework.GetNodeForMachine(cl, machines[0])
+			if err != nil {
+				return resource.Quantity{}, err
+			}
+
+			return node.Status.Allocatable[gcpGPUResourceName], nil
+		}, framework.WaitLong, framework.RetryMedium).Should(WithTransform(func(q resource.Quantity) int64 { return q.Value() }, BeNumerically(">", 0)))
+	})
 })
 
 func getGCPMAPIProviderSpec(cl client.Client) *mapiv1.GCPMachineProviderSpec {
@@ -79,65 +136,6 @@ func getGCPMAPIProviderSpec(cl client.Client) *mapiv1.GCPMachineProviderSpec {
 	return providerSpec
 }
 
-func createGCPMachineTemplate(cl client.Client, mapiProviderSpec *mapiv1.GCPMachineProviderSpec) *gcpv1.GCPMachineTemplate {
-	By("Creating GCP machine template")
-
-	Expect(mapiProviderSpec).ToNot(BeNil())
-	Expect(mapiProviderSpec.Disks).ToNot(BeNil())
-	Expect(len(mapiProviderSpec.Disks)).To(BeNumerically(">", 0))
-	Expect(mapiProviderSpec.Disks[0].Type).ToNot(BeEmpty())
-	Expect(mapiProviderSpec.MachineType).ToNot(BeEmpty())
-	Expect(mapiProviderSpec.NetworkInterfaces).ToNot(BeNil())
-	Expect(len(mapiProviderSpec.NetworkInterfaces)).To(BeNumerically(">", 0))
-	Expect(mapiProviderSpec.NetworkInterfaces[0].Subnetwork).ToNot(BeEmpty())
-	Expect(mapiProviderSpec.ServiceAccounts).ToNot(BeNil())
-	Expect(mapiProviderSpec.ServiceAccounts[0].Email).ToNot(BeEmpty())
-	Expect(mapiProviderSpec.ServiceAccounts[0].Scopes).ToNot(BeNil())
-	Expect(len(mapiProviderSpec.ServiceAccounts)).To(BeNumerically(">", 0))
-	Expect(mapiProviderSpec.Tags).ToNot(BeNil())
-	Expect(len(mapiProviderSpec.Tags)).To(BeNumerically(">", 0))
-
-	var rootDeviceType gcpv1.DiskType
-	switch mapiProviderSpec.Disks[0].Type {
-	case "pd-standard":
-		rootDeviceType = gcpv1.PdStandardDiskType
-	case "pd-ssd":
-		rootDeviceType = gcpv1.PdSsdDiskType
-	case "local-ssd":
-		rootDeviceType = gcpv1.LocalSsdDiskType
-	}
-
-	ipForwardingDisabled := gcpv1.IPForwardingDisabled
-	gcpMachineSpec := gcpv1.GCPMachineSpec{
-		RootDeviceType: &rootDeviceType,
-		RootDeviceSize: mapiProviderSpec.Disks[0].SizeGB,
-		InstanceType:   mapiProviderSpec.MachineType,
-		Image:          &mapiProviderSpec.Disks[0].Image,
-		Subnet:         &mapiProviderSpec.NetworkInterfaces[0].Subnetwork,
-		ServiceAccount: &gcpv1.ServiceAccount{
-			Email:  mapiProviderSpec.ServiceAccounts[0].Email,
-			Scopes: mapiProviderSpec.ServiceAccounts[0].Scopes,
-		},
-		AdditionalNetworkTags: mapiProviderSpec.Tags,
-		AdditionalLabels:      gcpv1.Labels{fmt.Sprintf("kubernetes-io-cluster-%s", clusterName): "owned"},
-		IPForwarding:          &ipForwardingDisabled,
-	}
-
-	gcpMachineTemplate := &gcpv1.GCPMachineTemplate{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      gcpMachineTemplateName,
-			Namespace: framework.CAPINamespace,
-		},
-		Spec: gcpv1.GCPMachineTemplateSpec{
-			Template: gcpv1.GCPMachineTemplateResource{
-				Spec: gcpMachineSpec,
-			},
-		},
-	}
-
-	if err := cl.Create(ctx, gcpMachineTemplate); err != nil && !apierrors.IsAlreadyExists(err) {
-		Expect(err).ToNot(HaveOccurred())
-	}
-
-	return gcpMachineTemplate
+func newGCPMachineTemplate(mapiProviderSpec *mapiv1.GCPMachineProviderSpec, name string) *gcpv1.GCPMachineTemplate {
+	return framework.NewMachineTemplateFromMAPI(cl, configv1.GCPPlatformType, mapiProviderSpec, clusterName, name).(*gcpv1.GCPMachineTemplate)
 }
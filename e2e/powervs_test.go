@@ -1,11 +1,10 @@
 package e2e
 
 import (
-	"fmt"
-
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
 	ibmpowervsv1 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -45,13 +44,27 @@ var _ = Describe("Cluster API IBMPowerVS MachineSet", Ordered, func() {
 			// explicitly skip it here for other platforms.
 			Skip("Skipping PowerVS E2E tests")
 		}
+		if machineSet == nil {
+			return
+		}
 		framework.DeleteMachineSets(cl, machineSet)
 		framework.WaitForMachineSetsDeleted(cl, machineSet)
 		framework.DeleteObjects(cl, powerVSMachineTemplate)
+		machineSet, powerVSMachineTemplate = nil, nil
+	})
+
+	It("should use the service instance and network of the cluster", func() {
+		powerVSCluster := &ibmpowervsv1.IBMPowerVSCluster{}
+		Eventually(func() error {
+			return cl.Get(ctx, client.ObjectKey{Namespace: framework.CAPINamespace, Name: clusterName}, powerVSCluster)
+		}, framework.WaitShort).Should(Succeed())
+		Expect(powerVSCluster.Spec.ServiceInstance).To(HaveValue(Equal(framework.PowerVSResourceReference(mapiMachineSpec.ServiceInstance))))
+		Expect(powerVSCluster.Spec.Network).To(Equal(framework.PowerVSResourceReference(mapiMachineSpec.Network)))
 	})
 
 	It("should be able to run a machine", func() {
-		powerVSMachineTemplate = createIBMPowerVSMachineTemplate(cl, mapiMachineSpec)
+		powerVSMachineTemplate = framework.NewMachineTemplateFromMAPI(cl, configv1.PowerVSPlatformType, mapiMachineSpec, clusterName, powerVSMachineTemplateName).(*ibmpowervsv1.IBMPowerVSMachineTemplate)
+		framework.CreateMachineTemplate(cl, powerVSMachineTemplate)
 
 		machineSet = framework.CreateMachineSet(cl, framework.NewMachineSetParams(
 			"ibmpowervs-machineset",
@@ -65,8 +78,22 @@ var _ = Describe("Cluster API IBMPowerVS MachineSet", Ordered, func() {
 			},
 		))
 		framework.WaitForMachineSet(cl, machineSet.Name)
-	})
 
+		By("Verifying the machine was created from the MAPI provider spec")
+		machines, err := framework.GetMachinesFromMachineSet(cl, machineSet)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(machines).To(HaveLen(1))
+		Expect(machines[0].Spec.ProviderID).To(HaveValue(HavePrefix("ibmpowervs://")))
+
+		powerVSMachine := &ibmpowervsv1.IBMPowerVSMachine{}
+		Expect(cl.Get(ctx, client.ObjectKey{
+			Namespace: framework.CAPINamespace,
+			Name:      machines[0].Spec.InfrastructureRef.Name,
+		}, powerVSMachine)).To(Succeed())
+		Expect(powerVSMachine.Spec.SystemType).To(Equal(mapiMachineSpec.SystemType))
+		Expect(string(powerVSMachine.Spec.ProcessorType)).To(Equal(string(mapiMachineSpec.ProcessorType)))
+		Expect(powerVSMachine.Spec.MemoryGiB).To(Equal(mapiMachineSpec.MemoryGiB))
+	})
 })
 
 func getPowerVSMAPIProviderSpec(cl client.Client) *mapiv1.PowerVSMachineProviderConfig {
@@ -97,8 +124,8 @@ func createIBMPowerVSCluster(cl client.Client, mapiProviderSpec *mapiv1.PowerVSM
 			},
 		},
 		Spec: ibmpowervsv1.IBMPowerVSClusterSpec{
-			ServiceInstanceID: *mapiProviderSpec.ServiceInstance.ID,
-			Network:           getNetworkResourceReference(mapiProviderSpec.Network),
+			ServiceInstance: ptr.To(framework.PowerVSResourceReference(mapiProviderSpec.ServiceInstance)),
+			Network:         framework.PowerVSResourceReference(mapiProviderSpec.Network),
 		},
 	}
 
@@ -126,74 +153,3 @@ func createIBMPowerVSCluster(cl client.Client, mapiProviderSpec *mapiv1.PowerVSM
 
 	return powerVSCluster
 }
-
-func createIBMPowerVSMachineTemplate(cl client.Client, mapiProviderSpec *mapiv1.PowerVSMachineProviderConfig) *ibmpowervsv1.IBMPowerVSMachineTemplate {
-	By("Creating IBMPowerVS machine template")
-
-	Expect(mapiProviderSpec).ToNot(BeNil())
-	Expect(mapiProviderSpec.ServiceInstance.ID).ToNot(BeNil())
-	Expect(mapiProviderSpec.KeyPairName).ToNot(BeEmpty())
-	Expect(mapiProviderSpec.Image).ToNot(BeNil())
-	Expect(mapiProviderSpec.SystemType).ToNot(BeEmpty())
-	Expect(mapiProviderSpec.ProcessorType).ToNot(BeEmpty())
-
-	ibmPowerVSMachineSpec := ibmpowervsv1.IBMPowerVSMachineSpec{
-		ServiceInstanceID: *mapiProviderSpec.ServiceInstance.ID,
-		SSHKey:            mapiProviderSpec.KeyPairName,
-		Image: &ibmpowervsv1.IBMPowerVSResourceReference{
-			Name: mapiProviderSpec.Image.Name,
-		},
-		SystemType:    mapiProviderSpec.SystemType,
-		ProcessorType: ibmpowervsv1.PowerVSProcessorType(mapiProviderSpec.ProcessorType),
-		Processors:    mapiProviderSpec.Processors,
-		MemoryGiB:     mapiProviderSpec.MemoryGiB,
-		Network:       getNetworkResourceReference(mapiProviderSpec.Network),
-	}
-
-	ibmPowerVSMachineTemplate := &ibmpowervsv1.IBMPowerVSMachineTemplate{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      powerVSMachineTemplateName,
-			Namespace: framework.CAPINamespace,
-		},
-		Spec: ibmpowervsv1.IBMPowerVSMachineTemplateSpec{
-			Template: ibmpowervsv1.IBMPowerVSMachineTemplateResource{
-				Spec: ibmPowerVSMachineSpec,
-			},
-		},
-	}
-
-	if err := cl.Create(ctx, ibmPowerVSMachineTemplate); err != nil && !apierrors.IsAlreadyExists(err) {
-		fmt.Println(err)
-		Expect(err).ToNot(HaveOccurred())
-	}
-
-	return ibmPowerVSMachineTemplate
-}
-
-func getNetworkResourceReference(networkResource mapiv1.PowerVSResource) ibmpowervsv1.IBMPowerVSResourceReference {
-	switch networkResource.Type {
-	case mapiv1.PowerVSResourceTypeID:
-		if networkResource.ID == nil {
-			panic("networkResource reference is specified as ID but it is nil")
-		}
-		return ibmpowervsv1.IBMPowerVSResourceReference{
-			ID: networkResource.ID,
-		}
-	case mapiv1.PowerVSResourceTypeName:
-		if networkResource.Name == nil {
-			panic("networkResource reference is specified as Name but it is nil")
-		}
-		return ibmpowervsv1.IBMPowerVSResourceReference{
-			Name: networkResource.Name,
-		}
-	case mapiv1.PowerVSResourceTypeRegEx:
-		if networkResource.RegEx == nil {
-			panic("networkResource reference is specified as RegEx but it is nil")
-		}
-		return ibmpowervsv1.IBMPowerVSResourceReference{
-			RegEx: networkResource.RegEx,
-		}
-	default:
-		panic("networkResource reference is not specified")
-	}
-}
@@ -51,7 +51,8 @@ var _ = Describe("Cluster API vSphere MachineSet", Ordered, func() {
 	})
 
 	It("should be able to run a machine", func() {
-		vSphereMachineTemplate = createVSphereMachineTemplate(cl, mapiMachineSpec)
+		vSphereMachineTemplate = framework.NewMachineTemplateFromMAPI(cl, configv1.VSpherePlatformType, mapiMachineSpec, clusterName, vSphereMachineTemplateName).(*vspherev1.VSphereMachineTemplate)
+		framework.CreateMachineTemplate(cl, vSphereMachineTemplate)
 
 		machineSet = framework.CreateMachineSet(cl, framework.NewMachineSetParams(
 			"vsphere-machineset",
@@ -179,54 +180,3 @@ func createVSphereCluster(cl client.Client, mapiProviderSpec *mapiv1.VSphereMach
 
 	return vSphereCluster
 }
-
-func createVSphereMachineTemplate(cl client.Client, mapiProviderSpec *mapiv1.VSphereMachineProviderSpec) *vspherev1.VSphereMachineTemplate {
-	By("Creating vSphere machine template")
-
-	Expect(mapiProviderSpec).ToNot(BeNil(), "expected MAPI ProviderSpec to not be nil")
-	Expect(mapiProviderSpec.Network).ToNot(BeNil(), "expected MAPI ProviderSpec's network to not be nil")
-	Expect(len(mapiProviderSpec.Network.Devices)).To(BeNumerically(">", 0), "expected MAPI ProviderSpec's Network to have Devices")
-	Expect(mapiProviderSpec.Network.Devices[0].NetworkName).ToNot(BeEmpty(), "expected MAPI ProviderSpec's Network Device to have a network name")
-	Expect(mapiProviderSpec.Template).ToNot(BeEmpty(), "expected MAPI ProviderSpec's Template to not be empty")
-
-	vSphereMachineSpec := vspherev1.VSphereMachineSpec{
-		VirtualMachineCloneSpec: vspherev1.VirtualMachineCloneSpec{
-			Template:     mapiProviderSpec.Template,
-			Server:       mapiProviderSpec.Workspace.Server,
-			DiskGiB:      mapiProviderSpec.DiskGiB,
-			CloneMode:    vspherev1.CloneMode("linkedClone"),
-			Datacenter:   mapiProviderSpec.Workspace.Datacenter,
-			Datastore:    mapiProviderSpec.Workspace.Datastore,
-			Folder:       mapiProviderSpec.Workspace.Folder,
-			ResourcePool: mapiProviderSpec.Workspace.ResourcePool,
-			NumCPUs:      mapiProviderSpec.NumCPUs,
-			MemoryMiB:    mapiProviderSpec.MemoryMiB,
-			Network: vspherev1.NetworkSpec{
-				Devices: []vspherev1.NetworkDeviceSpec{
-					{
-						DHCP4:       true,
-						NetworkName: mapiProviderSpec.Network.Devices[0].NetworkName,
-					},
-				},
-			},
-		},
-	}
-
-	vSphereMachineTemplate := &vspherev1.VSphereMachineTemplate{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      vSphereMachineTemplateName,
-			Namespace: framework.CAPINamespace,
-		},
-		Spec: vspherev1.VSphereMachineTemplateSpec{
-			Template: vspherev1.VSphereMachineTemplateResource{
-				Spec: vSphereMachineSpec,
-			},
-		},
-	}
-
-	if err := cl.Create(ctx, vSphereMachineTemplate); err != nil && !apierrors.IsAlreadyExists(err) {
-		Expect(err).ToNot(HaveOccurred(), "should not error creating the VSphere Cluster object")
-	}
-
-	return vSphereMachineTemplate
-}
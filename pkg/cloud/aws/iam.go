@@ -0,0 +1,254 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package aws
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/iam"
+
+	configv1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AccessKeyIDKey is the key of the AWS access key ID in a MAPI credentials secret.
+	AccessKeyIDKey = "aws_access_key_id"
+	// SecretAccessKeyKey is the key of the AWS secret access key in a MAPI credentials secret.
+	SecretAccessKeyKey = "aws_secret_access_key" //nolint:gosec
+	// CredentialsKey is the key of the AWS shared credentials file in a credentials secret minted by the cloud credential operator.
+	// On STS clusters, it is the only key, and configures the role to assume with the web identity token of the service account.
+	CredentialsKey = "credentials"
+
+	// instanceProfileCacheTTL is how long an instance profile found to exist is not looked up again.
+	// Missing instance profiles are not cached, so they are picked up as soon as they are created.
+	instanceProfileCacheTTL = 30 * time.Minute
+	// iamRequestTimeout bounds a single IAM lookup, so an unreachable endpoint does not hold the reconcile.
+	iamRequestTimeout = 30 * time.Second
+)
+
+var (
+	// errMissingCredentials is returned when the credentials secret contains neither a shared credentials file nor static AWS credentials.
+	errMissingCredentials = errors.New("credentials secret is missing the credentials key, or the aws_access_key_id and aws_secret_access_key keys")
+	// errIAMRequestFailed is returned when the IAM API returns an error other than the entity not existing.
+	errIAMRequestFailed = errors.New("IAM request failed")
+)
+
+// IAMInstanceProfileClient looks up IAM instance profiles.
+type IAMInstanceProfileClient interface {
+	// InstanceProfileExists returns whether the named IAM instance profile exists,
+	// using the AWS credentials from the given secret.
+	// An error is returned when the existence could not be confirmed either way.
+	InstanceProfileExists(ctx context.Context, credentialsSecret client.ObjectKey, region, name string) (bool, error)
+}
+
+// IAMClientOptions configures how the IAM API is reached.
+type IAMClientOptions struct {
+	// ServiceEndpoints are the AWS service endpoint overrides of the cluster, from the Infrastructure platform status.
+	// The IAM and STS overrides, when present, are used instead of the default endpoints of the partition of the region.
+	ServiceEndpoints []configv1.AWSServiceEndpoint
+
+	// TrustedCABundleFile is the trusted CA bundle of the cluster, which includes the additional trust bundle
+	// of the cluster proxy. It is trusted along with the system CAs, and is read on every request so it can be rotated.
+	TrustedCABundleFile string
+}
+
+// instanceProfileKey identifies an instance profile lookup.
+type instanceProfileKey struct {
+	credentialsSecret client.ObjectKey
+	region            string
+	name              string
+}
+
+// iamInstanceProfileClient is an IAMInstanceProfileClient backed by the AWS SDK IAM client.
+// Requests go through the proxy configured in the environment, i.e. the cluster proxy.
+type iamInstanceProfileClient struct {
+	client  client.Reader
+	options IAMClientOptions
+
+	now func() time.Time
+
+	mu sync.Mutex
+	// found holds when the instance profiles found to exist were looked up.
+	found map[instanceProfileKey]time.Time
+}
+
+// NewIAMInstanceProfileClient returns an IAMInstanceProfileClient that reads AWS credentials
+// from secrets using the given client. Credentials are read on every lookup so that rotated
+// credentials are picked up without a restart.
+func NewIAMInstanceProfileClient(cl client.Reader, options IAMClientOptions) IAMInstanceProfileClient {
+	return &iamInstanceProfileClient{
+		client:  cl,
+		options: options,
+		now:     time.Now,
+		found:   map[instanceProfileKey]time.Time{},
+	}
+}
+
+// InstanceProfileExists returns whether the named IAM instance profile exists.
+// Instance profiles found to exist are cached, so IAM is not called on every reconcile.
+func (c *iamInstanceProfileClient) InstanceProfileExists(ctx context.Context, credentialsSecret client.ObjectKey, region, name string) (bool, error) {
+	key := instanceProfileKey{credentialsSecret: credentialsSecret, region: region, name: name}
+
+	c.mu.Lock()
+	foundAt, ok := c.found[key]
+	c.mu.Unlock()
+
+	if ok && c.now().Sub(foundAt) < instanceProfileCacheTTL {
+		return true, nil
+	}
+
+	exists, err := c.getInstanceProfile(ctx, credentialsSecret, region, name)
+	if err != nil {
+		return false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if exists {
+		c.found[key] = c.now()
+	} else {
+		delete(c.found, key)
+	}
+
+	return exists, nil
+}
+
+// getInstanceProfile calls the IAM GetInstanceProfile API, and returns false only when IAM reports that
+// the instance profile does not exist.
+func (c *iamInstanceProfileClient) getInstanceProfile(ctx context.Context, credentialsSecret client.ObjectKey, region, name string) (bool, error) {
+	secret := &corev1.Secret{}
+	if err := c.client.Get(ctx, credentialsSecret, secret); err != nil {
+		return false, fmt.Errorf("failed to get credentials secret %s: %w", credentialsSecret, err)
+	}
+
+	sess, err := c.newSession(secret, region)
+	if err != nil {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, iamRequestTimeout)
+	defer cancel()
+
+	_, err = iam.New(sess).GetInstanceProfileWithContext(ctx, &iam.GetInstanceProfileInput{InstanceProfileName: aws.String(name)})
+
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == iam.ErrCodeNoSuchEntityException {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("%w: getting IAM instance profile %q: %w", errIAMRequestFailed, name, err)
+	}
+
+	return true, nil
+}
+
+// newSession returns an AWS session with the credentials of the secret.
+// The shared credentials file of the secret is preferred, as it supports both static credentials and,
+// on STS clusters, assuming a role with the web identity token of the service account.
+// Otherwise the static credentials of the secret are used.
+func (c *iamInstanceProfileClient) newSession(secret *corev1.Secret, region string) (*session.Session, error) {
+	options := session.Options{
+		Config: aws.Config{
+			Region:           aws.String(region),
+			EndpointResolver: endpoints.ResolverFunc(c.resolveEndpoint),
+		},
+	}
+
+	if c.options.TrustedCABundleFile != "" {
+		caBundle, err := os.ReadFile(c.options.TrustedCABundleFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read trusted CA bundle %s: %w", c.options.TrustedCABundleFile, err)
+		}
+
+		// The bundle is only mounted once it has been injected, until then the system CAs are used.
+		if len(caBundle) > 0 {
+			options.CustomCABundle = bytes.NewReader(caBundle)
+		}
+	}
+
+	switch {
+	case len(secret.Data[CredentialsKey]) > 0:
+		sharedCredentialsFile, err := writeSharedCredentialsFile(secret.Data[CredentialsKey])
+		if err != nil {
+			return nil, err
+		}
+		// The shared credentials file is only read when the session is created.
+		defer os.Remove(sharedCredentialsFile)
+
+		options.Profile = "default"
+		options.SharedConfigState = session.SharedConfigEnable
+		options.SharedConfigFiles = []string{sharedCredentialsFile}
+	case len(secret.Data[AccessKeyIDKey]) > 0 && len(secret.Data[SecretAccessKeyKey]) > 0:
+		options.Config.Credentials = credentials.NewStaticCredentials(string(secret.Data[AccessKeyIDKey]), string(secret.Data[SecretAccessKeyKey]), "")
+	default:
+		return nil, fmt.Errorf("%w: %s/%s", errMissingCredentials, secret.Namespace, secret.Name)
+	}
+
+	sess, err := session.NewSessionWithOptions(options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+	}
+
+	return sess, nil
+}
+
+// writeSharedCredentialsFile writes the shared credentials file of a credentials secret to a temporary file,
+// as the AWS SDK only loads shared credentials from files, and returns its path.
+func writeSharedCredentialsFile(data []byte) (string, error) {
+	f, err := os.CreateTemp("", "aws-shared-credentials")
+	if err != nil {
+		return "", fmt.Errorf("failed to create shared credentials file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(data); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("failed to write shared credentials file: %w", err)
+	}
+
+	return f.Name(), nil
+}
+
+// resolveEndpoint returns the endpoint of the AWS service for the region: the service endpoint override of the cluster if any,
+// or else the endpoint of the partition of the region. The signing region of the partition is kept either way,
+// as IAM is a global service signed for the partition, not the region.
+func (c *iamInstanceProfileClient) resolveEndpoint(service, region string, opts ...func(*endpoints.Options)) (endpoints.ResolvedEndpoint, error) {
+	endpoint, err := endpoints.DefaultResolver().EndpointFor(service, region, opts...)
+	if err != nil {
+		return endpoints.ResolvedEndpoint{}, fmt.Errorf("failed to resolve %s endpoint for region %q: %w", service, region, err)
+	}
+
+	for _, serviceEndpoint := range c.options.ServiceEndpoints {
+		if serviceEndpoint.Name == service && serviceEndpoint.URL != "" {
+			endpoint.URL = serviceEndpoint.URL
+		}
+	}
+
+	return endpoint, nil
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	configv1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+const noSuchEntityResponse = `<ErrorResponse xmlns="https://iam.amazonaws.com/doc/2010-05-08/">
+  <Error><Type>Sender</Type><Code>NoSuchEntity</Code><Message>Instance Profile missing cannot be found.</Message></Error>
+</ErrorResponse>`
+
+const accessDeniedResponse = `<ErrorResponse xmlns="https://iam.amazonaws.com/doc/2010-05-08/">
+  <Error><Type>Sender</Type><Code>AccessDenied</Code><Message>not authorized to perform iam:GetInstanceProfile</Message></Error>
+</ErrorResponse>`
+
+const assumeRoleWithWebIdentityResponse = `<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>web-identity-access-key-id</AccessKeyId>
+      <SecretAccessKey>web-identity-secret-access-key</SecretAccessKey>
+      <SessionToken>web-identity-session-token</SessionToken>
+      <Expiration>2099-01-01T00:00:00Z</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`
+
+var _ = Describe("IAMInstanceProfileClient", func() {
+	var (
+		ctx               context.Context
+		server            *httptest.Server
+		requests          int
+		webIdentityTokens []string
+		accessKeyIDs      []string
+		status            int
+		response          string
+		now               time.Time
+		cl                client.Client
+		iamClient         *iamInstanceProfileClient
+		credentialsSecret = client.ObjectKey{Namespace: "openshift-machine-api", Name: "aws-cloud-credentials"}
+	)
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		requests, status, response = 0, http.StatusOK, "<GetInstanceProfileResponse/>"
+		webIdentityTokens, accessKeyIDs = nil, nil
+		now = time.Now()
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+
+			Expect(r.ParseForm()).To(Succeed())
+
+			if r.Form.Get("Action") == "AssumeRoleWithWebIdentity" {
+				webIdentityTokens = append(webIdentityTokens, r.Form.Get("WebIdentityToken"))
+				fmt.Fprint(w, assumeRoleWithWebIdentityResponse)
+
+				return
+			}
+
+			requests++
+
+			Expect(r.Form.Get("Action")).To(Equal("GetInstanceProfile"))
+			Expect(r.Header.Get("Authorization")).To(ContainSubstring("/us-east-1/iam/aws4_request"))
+
+			var accessKeyID string
+			_, _ = fmt.Sscanf(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=%s", &accessKeyID)
+			accessKeyIDs = append(accessKeyIDs, accessKeyID)
+
+			w.WriteHeader(status)
+			fmt.Fprint(w, response)
+		}))
+		DeferCleanup(server.Close)
+
+		cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: credentialsSecret.Namespace, Name: credentialsSecret.Name},
+			Data: map[string][]byte{
+				AccessKeyIDKey:     []byte("access-key-id"),
+				SecretAccessKeyKey: []byte("secret-access-key"),
+			},
+		}).Build()
+
+		iamClient = NewIAMInstanceProfileClient(cl, IAMClientOptions{
+			ServiceEndpoints: []configv1.AWSServiceEndpoint{{Name: "iam", URL: server.URL}, {Name: "sts", URL: server.URL}},
+		}).(*iamInstanceProfileClient)
+		iamClient.now = func() time.Time { return now }
+	})
+
+	It("should report an existing instance profile through the service endpoint override", func() {
+		Expect(iamClient.InstanceProfileExists(ctx, credentialsSecret, "us-west-2", "worker-profile")).To(BeTrue())
+		Expect(requests).To(Equal(1))
+		Expect(accessKeyIDs).To(ConsistOf(HavePrefix("access-key-id/")))
+	})
+
+	It("should cache existing instance profiles until the cache expires", func() {
+		Expect(iamClient.InstanceProfileExists(ctx, credentialsSecret, "us-west-2", "worker-profile")).To(BeTrue())
+		Expect(iamClient.InstanceProfileExists(ctx, credentialsSecret, "us-west-2", "worker-profile")).To(BeTrue())
+		Expect(requests).To(Equal(1))
+
+		now = now.Add(instanceProfileCacheTTL)
+		Expect(iamClient.InstanceProfileExists(ctx, credentialsSecret, "us-west-2", "worker-profile")).To(BeTrue())
+		Expect(requests).To(Equal(2))
+	})
+
+	It("should report a missing instance profile only when IAM returns NoSuchEntity", func() {
+		status, response = http.StatusNotFound, noSuchEntityResponse
+
+		Expect(iamClient.InstanceProfileExists(ctx, credentialsSecret, "us-west-2", "missing")).To(BeFalse())
+	})
+
+	It("should not cache missing instance profiles", func() {
+		status, response = http.StatusNotFound, noSuchEntityResponse
+		Expect(iamClient.InstanceProfileExists(ctx, credentialsSecret, "us-west-2", "missing")).To(BeFalse())
+
+		status, response = http.StatusOK, "<GetInstanceProfileResponse/>"
+		Expect(iamClient.InstanceProfileExists(ctx, credentialsSecret, "us-west-2", "missing")).To(BeTrue())
+		Expect(requests).To(Equal(2))
+	})
+
+	It("should return an error on other IAM errors", func() {
+		status, response = http.StatusForbidden, accessDeniedResponse
+
+		_, err := iamClient.InstanceProfileExists(ctx, credentialsSecret, "us-west-2", "worker-profile")
+		Expect(err).To(MatchError(errIAMRequestFailed))
+		Expect(err).To(MatchError(ContainSubstring("AccessDenied")))
+	})
+
+	It("should return an error on responses that are not IAM errors", func() {
+		status, response = http.StatusBadRequest, "bad request"
+
+		_, err := iamClient.InstanceProfileExists(ctx, credentialsSecret, "us-west-2", "worker-profile")
+		Expect(err).To(MatchError(errIAMRequestFailed))
+	})
+
+	It("should return an error when the credentials secret is missing", func() {
+		_, err := iamClient.InstanceProfileExists(ctx, client.ObjectKey{Namespace: "openshift-machine-api", Name: "missing"}, "us-west-2", "worker-profile")
+		Expect(err).To(MatchError(ContainSubstring("failed to get credentials secret")))
+		Expect(requests).To(BeZero())
+	})
+
+	It("should use the static credentials of the shared credentials file", func() {
+		Expect(cl.Update(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: credentialsSecret.Namespace, Name: credentialsSecret.Name},
+			Data: map[string][]byte{
+				CredentialsKey: []byte("[default]\naws_access_key_id = shared-access-key-id\naws_secret_access_key = shared-secret-access-key\n"),
+			},
+		})).To(Succeed())
+
+		Expect(iamClient.InstanceProfileExists(ctx, credentialsSecret, "us-west-2", "worker-profile")).To(BeTrue())
+		Expect(accessKeyIDs).To(ConsistOf(HavePrefix("shared-access-key-id/")))
+	})
+
+	It("should assume the role of the shared credentials file with the web identity token", func() {
+		tokenFile := filepath.Join(GinkgoT().TempDir(), "token")
+		Expect(os.WriteFile(tokenFile, []byte("service-account-token"), 0o600)).To(Succeed())
+
+		Expect(cl.Update(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: credentialsSecret.Namespace, Name: credentialsSecret.Name},
+			Data: map[string][]byte{
+				CredentialsKey: []byte("[default]\nrole_arn = arn:aws:iam::123456789012:role/machine-api\nweb_identity_token_file = " + tokenFile + "\n"),
+			},
+		})).To(Succeed())
+
+		Expect(iamClient.InstanceProfileExists(ctx, credentialsSecret, "us-west-2", "worker-profile")).To(BeTrue())
+		Expect(webIdentityTokens).To(ConsistOf("service-account-token"))
+		Expect(accessKeyIDs).To(ConsistOf(HavePrefix("web-identity-access-key-id/")))
+	})
+
+	It("should return an error when the credentials secret has no credentials", func() {
+		Expect(cl.Update(ctx, &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: credentialsSecret.Namespace, Name: credentialsSecret.Name},
+		})).To(Succeed())
+
+		_, err := iamClient.InstanceProfileExists(ctx, credentialsSecret, "us-west-2", "worker-profile")
+		Expect(err).To(MatchError(errMissingCredentials))
+		Expect(requests).To(BeZero())
+	})
+
+	It("should resolve the endpoint of the partition of the region without override", func() {
+		iamClient.options.ServiceEndpoints = nil
+
+		endpoint, err := iamClient.resolveEndpoint("iam", "cn-north-1")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(endpoint.URL).To(HaveSuffix(".amazonaws.com.cn"))
+		Expect(endpoint.SigningRegion).To(HavePrefix("cn-"))
+	})
+})
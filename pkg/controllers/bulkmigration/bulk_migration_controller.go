@@ -0,0 +1,225 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package bulkmigration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	configv1 "github.com/openshift/api/config/v1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+	"github.com/openshift/cluster-capi-operator/pkg/util"
+)
+
+const (
+	controllerName = "BulkMigrationController"
+
+	// BulkMigrationAnnotation is set to "true" on the cluster Infrastructure resource to migrate
+	// every worker MachineSet from MAPI to CAPI authoritative.
+	BulkMigrationAnnotation = "machine.openshift.io/migrate-worker-machinesets-to-cluster-api"
+
+	// BulkMigrationMaxInFlightAnnotation is set on the cluster Infrastructure resource to limit how many
+	// MachineSets are migrated at the same time. Defaults to defaultMaxInFlight.
+	BulkMigrationMaxInFlightAnnotation = "machine.openshift.io/migrate-worker-machinesets-max-in-flight"
+
+	// defaultMaxInFlight migrates MachineSets one at a time.
+	defaultMaxInFlight = 1
+
+	// machineRoleLabel is the label holding the role of the Machines created by a MAPI MachineSet.
+	machineRoleLabel = "machine.openshift.io/cluster-api-machine-role"
+
+	// masterMachineRole is the role of control plane Machines, which are not migrated.
+	masterMachineRole = "master"
+
+	reasonMigrationStarted = "BulkMigrationStarted"
+)
+
+// errInvalidMaxInFlight is returned when the max in flight annotation is not a positive integer.
+var errInvalidMaxInFlight = errors.New("max in flight must be a positive integer")
+
+// BulkMigrationReconciler migrates worker MachineSets from MAPI to CAPI authoritative, a few at a time,
+// when requested on the cluster Infrastructure resource.
+type BulkMigrationReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	MAPINamespace string
+}
+
+// Reconcile moves the next worker MachineSets to CAPI once the previous ones have finished migrating.
+func (r *BulkMigrationReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx).WithName(controllerName)
+	log.V(1).Info("reconciling bulk migration")
+
+	infra := &configv1.Infrastructure{}
+	if err := r.Get(ctx, client.ObjectKey{Name: controllers.InfrastructureResourceName}, infra); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to get infrastructure: %w", err)
+	}
+
+	if infra.GetAnnotations()[BulkMigrationAnnotation] != "true" {
+		log.V(1).Info("bulk migration not requested, nothing to do")
+		return ctrl.Result{}, nil
+	}
+
+	maxInFlight, err := getMaxInFlight(infra)
+	if err != nil {
+		// The annotation can only be fixed by updating the infrastructure, which triggers a new reconcile.
+		log.Error(err, "invalid bulk migration max in flight")
+		return ctrl.Result{}, nil
+	}
+
+	machineSets := &machinev1beta1.MachineSetList{}
+	if err := r.List(ctx, machineSets, client.InNamespace(r.MAPINamespace)); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list MAPI MachineSets: %w", err)
+	}
+
+	for _, machineSet := range nextMachineSetsToMigrate(machineSets.Items, maxInFlight) {
+		patchBase := client.MergeFrom(machineSet.DeepCopy())
+		machineSet.Spec.AuthoritativeAPI = machinev1beta1.MachineAuthorityClusterAPI
+
+		if err := r.Patch(ctx, machineSet, patchBase); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to set authoritative API of MAPI MachineSet %s: %w", machineSet.GetName(), err)
+		}
+
+		log.Info("started migrating MachineSet to CAPI", "machineSet", machineSet.GetName())
+		r.Recorder.Event(machineSet, corev1.EventTypeNormal, reasonMigrationStarted, "Bulk migration set the authoritative API to ClusterAPI")
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// getMaxInFlight returns how many MachineSets may be migrating at the same time.
+func getMaxInFlight(infra *configv1.Infrastructure) (int, error) {
+	value, ok := infra.GetAnnotations()[BulkMigrationMaxInFlightAnnotation]
+	if !ok {
+		return defaultMaxInFlight, nil
+	}
+
+	maxInFlight, err := strconv.Atoi(value)
+	if err != nil || maxInFlight < 1 {
+		return 0, fmt.Errorf("%w: %q in annotation %s", errInvalidMaxInFlight, value, BulkMigrationMaxInFlightAnnotation)
+	}
+
+	return maxInFlight, nil
+}
+
+// nextMachineSetsToMigrate returns the worker MachineSets to move to CAPI, in name order, so that no more than
+// maxInFlight MachineSets are migrating at the same time. A MachineSet is migrating from when its authoritative API
+// is set to ClusterAPI until it is CAPI authoritative and synchronized.
+// MachineSets that are paused or fail to synchronize are left for the admin to resolve.
+func nextMachineSetsToMigrate(machineSets []machinev1beta1.MachineSet, maxInFlight int) []*machinev1beta1.MachineSet {
+	inFlight := 0
+	candidates := []*machinev1beta1.MachineSet{}
+
+	for i := range machineSets {
+		machineSet := &machineSets[i]
+
+		if machineSet.Spec.Template.Labels[machineRoleLabel] == masterMachineRole {
+			continue
+		}
+
+		if machineSet.Spec.AuthoritativeAPI == machinev1beta1.MachineAuthorityClusterAPI {
+			if !isMigrated(machineSet) {
+				inFlight++
+			}
+
+			continue
+		}
+
+		if machineSet.Status.AuthoritativeAPI != machinev1beta1.MachineAuthorityMachineAPI || annotations.HasPaused(machineSet) || !isSynchronized(machineSet) {
+			continue
+		}
+
+		candidates = append(candidates, machineSet)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].GetName() < candidates[j].GetName()
+	})
+
+	available := maxInFlight - inFlight
+	if available <= 0 {
+		return []*machinev1beta1.MachineSet{}
+	}
+
+	if available < len(candidates) {
+		candidates = candidates[:available]
+	}
+
+	return candidates
+}
+
+// isMigrated returns whether the MachineSet is CAPI authoritative and synchronized.
+func isMigrated(machineSet *machinev1beta1.MachineSet) bool {
+	return machineSet.Status.AuthoritativeAPI == machinev1beta1.MachineAuthorityClusterAPI && isSynchronized(machineSet)
+}
+
+// isSynchronized returns whether the Synchronized condition of the MachineSet is True.
+func isSynchronized(machineSet *machinev1beta1.MachineSet) bool {
+	condition := util.GetMAPICondition(machineSet.Status.Conditions, controllers.SynchronizedCondition)
+	return condition != nil && condition.Status == corev1.ConditionTrue
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *BulkMigrationReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.MAPINamespace == "" {
+		r.MAPINamespace = controllers.DefaultMAPIManagedNamespace
+	}
+
+	// The migration progress depends on the infrastructure and all MachineSets, so all events map to a single request.
+	toInfrastructure := handler.EnqueueRequestsFromMapFunc(func(context.Context, client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: client.ObjectKey{Name: controllers.InfrastructureResourceName}}}
+	})
+
+	if err := ctrl.NewControllerManagedBy(mgr).
+		Named(controllerName).
+		For(
+			&configv1.Infrastructure{},
+			builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+				return obj.GetName() == controllers.InfrastructureResourceName
+			})),
+		).
+		Watches(
+			&machinev1beta1.MachineSet{},
+			toInfrastructure,
+			builder.WithPredicates(util.FilterNamespace(r.MAPINamespace)),
+		).
+		Complete(r); err != nil {
+		return fmt.Errorf("failed to create controller: %w", err)
+	}
+
+	r.Client = mgr.GetClient()
+	r.Scheme = mgr.GetScheme()
+	r.Recorder = mgr.GetEventRecorderFor("bulk-migration-controller")
+
+	return nil
+}
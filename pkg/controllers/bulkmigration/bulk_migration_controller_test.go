@@ -0,0 +1,103 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package bulkmigration
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	configv1 "github.com/openshift/api/config/v1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	machinev1resourcebuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	capiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+)
+
+// machineSet returns a worker MachineSet with the given authoritative APIs and Synchronized condition status.
+func machineSet(name string, specAuthority, statusAuthority machinev1beta1.MachineAuthority, synchronized corev1.ConditionStatus) machinev1beta1.MachineSet {
+	return *machinev1resourcebuilder.MachineSet().
+		WithName(name).
+		WithMachineTemplateLabels(map[string]string{machineRoleLabel: "worker"}).
+		WithAuthoritativeAPI(specAuthority).
+		WithAuthoritativeAPIStatus(statusAuthority).
+		WithConditions([]machinev1beta1.Condition{{Type: controllers.SynchronizedCondition, Status: synchronized}}).
+		Build()
+}
+
+var _ = DescribeTable("nextMachineSetsToMigrate",
+	func(machineSets []machinev1beta1.MachineSet, maxInFlight int, expectedNames []string) {
+		names := []string{}
+		for _, machineSet := range nextMachineSetsToMigrate(machineSets, maxInFlight) {
+			names = append(names, machineSet.GetName())
+		}
+
+		Expect(names).To(Equal(expectedNames))
+	},
+	Entry("with no MachineSets", []machinev1beta1.MachineSet{}, 1, []string{}),
+	Entry("migrates one MachineSet at a time in name order", []machinev1beta1.MachineSet{
+		machineSet("worker-b", machinev1beta1.MachineAuthorityMachineAPI, machinev1beta1.MachineAuthorityMachineAPI, corev1.ConditionTrue),
+		machineSet("worker-a", machinev1beta1.MachineAuthorityMachineAPI, machinev1beta1.MachineAuthorityMachineAPI, corev1.ConditionTrue),
+	}, 1, []string{"worker-a"}),
+	Entry("migrates up to max in flight MachineSets", []machinev1beta1.MachineSet{
+		machineSet("worker-a", machinev1beta1.MachineAuthorityMachineAPI, machinev1beta1.MachineAuthorityMachineAPI, corev1.ConditionTrue),
+		machineSet("worker-b", machinev1beta1.MachineAuthorityMachineAPI, machinev1beta1.MachineAuthorityMachineAPI, corev1.ConditionTrue),
+		machineSet("worker-c", machinev1beta1.MachineAuthorityMachineAPI, machinev1beta1.MachineAuthorityMachineAPI, corev1.ConditionTrue),
+	}, 2, []string{"worker-a", "worker-b"}),
+	Entry("waits for the MachineSet being migrated", []machinev1beta1.MachineSet{
+		machineSet("worker-a", machinev1beta1.MachineAuthorityClusterAPI, machinev1beta1.MachineAuthorityMigrating, corev1.ConditionTrue),
+		machineSet("worker-b", machinev1beta1.MachineAuthorityMachineAPI, machinev1beta1.MachineAuthorityMachineAPI, corev1.ConditionTrue),
+	}, 1, []string{}),
+	Entry("waits for a migrated MachineSet failing to synchronize", []machinev1beta1.MachineSet{
+		machineSet("worker-a", machinev1beta1.MachineAuthorityClusterAPI, machinev1beta1.MachineAuthorityClusterAPI, corev1.ConditionFalse),
+		machineSet("worker-b", machinev1beta1.MachineAuthorityMachineAPI, machinev1beta1.MachineAuthorityMachineAPI, corev1.ConditionTrue),
+	}, 1, []string{}),
+	Entry("continues once the previous MachineSet is migrated", []machinev1beta1.MachineSet{
+		machineSet("worker-a", machinev1beta1.MachineAuthorityClusterAPI, machinev1beta1.MachineAuthorityClusterAPI, corev1.ConditionTrue),
+		machineSet("worker-b", machinev1beta1.MachineAuthorityMachineAPI, machinev1beta1.MachineAuthorityMachineAPI, corev1.ConditionTrue),
+	}, 1, []string{"worker-b"}),
+	Entry("skips control plane, paused and unsynchronized MachineSets", []machinev1beta1.MachineSet{
+		*machinev1resourcebuilder.MachineSet().WithName("master").
+			WithMachineTemplateLabels(map[string]string{machineRoleLabel: masterMachineRole}).
+			WithAuthoritativeAPIStatus(machinev1beta1.MachineAuthorityMachineAPI).
+			WithConditions([]machinev1beta1.Condition{{Type: controllers.SynchronizedCondition, Status: corev1.ConditionTrue}}).Build(),
+		*machinev1resourcebuilder.MachineSet().WithName("paused").
+			WithAnnotations(map[string]string{capiv1beta1.PausedAnnotation: ""}).
+			WithAuthoritativeAPIStatus(machinev1beta1.MachineAuthorityMachineAPI).
+			WithConditions([]machinev1beta1.Condition{{Type: controllers.SynchronizedCondition, Status: corev1.ConditionTrue}}).Build(),
+		machineSet("unsynchronized", machinev1beta1.MachineAuthorityMachineAPI, machinev1beta1.MachineAuthorityMachineAPI, corev1.ConditionFalse),
+		machineSet("worker", machinev1beta1.MachineAuthorityMachineAPI, machinev1beta1.MachineAuthorityMachineAPI, corev1.ConditionTrue),
+	}, 1, []string{"worker"}),
+)
+
+var _ = DescribeTable("getMaxInFlight",
+	func(annotations map[string]string, expectedMaxInFlight int, expectedErr error) {
+		maxInFlight, err := getMaxInFlight(&configv1.Infrastructure{ObjectMeta: metav1.ObjectMeta{Annotations: annotations}})
+		if expectedErr != nil {
+			Expect(err).To(MatchError(expectedErr))
+			return
+		}
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(maxInFlight).To(Equal(expectedMaxInFlight))
+	},
+	Entry("without the annotation", nil, defaultMaxInFlight, nil),
+	Entry("with a positive value", map[string]string{BulkMigrationMaxInFlightAnnotation: "3"}, 3, nil),
+	Entry("with zero", map[string]string{BulkMigrationMaxInFlightAnnotation: "0"}, 0, errInvalidMaxInFlight),
+	Entry("with a non numeric value", map[string]string{BulkMigrationMaxInFlightAnnotation: "all"}, 0, errInvalidMaxInFlight),
+)
@@ -0,0 +1,72 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// apiServerCAConfigMapName is the ConfigMap the serving CA bundle of the API server is published in, in every namespace.
+	// The kubeconfig controller embeds it in the kubeconfig secrets of the CAPI namespace.
+	apiServerCAConfigMapName = "kube-root-ca.crt"
+	apiServerCAKey           = "ca.crt"
+
+	// apiServerCAHashAnnotation is set on the pod template of the deployments of the CAPI namespace to the hash
+	// of the serving CA bundle of the API server, so the controllers consuming the kubeconfig secrets are rolled out
+	// when it rotates, instead of failing TLS verification with connections cached before the rotation.
+	apiServerCAHashAnnotation = "cluster-capi-operator.openshift.io/api-server-ca-hash"
+)
+
+// getAPIServerCAHash returns the hash of the serving CA bundle of the API server, or an empty string while it is not published.
+func (r *CapiInstallerController) getAPIServerCAHash(ctx context.Context) (string, error) {
+	cm := &corev1.ConfigMap{}
+	if err := r.Get(ctx, client.ObjectKey{Name: apiServerCAConfigMapName, Namespace: defaultCAPINamespace}, cm); kerrors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("unable to get API server CA ConfigMap %s/%s: %w", defaultCAPINamespace, apiServerCAConfigMapName, err)
+	}
+
+	return caBundleHash(cm.Data[apiServerCAKey]), nil
+}
+
+// setAPIServerCAHash sets the hash of the serving CA bundle of the API server on the pod template of the deployment,
+// if it runs in the CAPI namespace, where the kubeconfig secrets are consumed.
+func setAPIServerCAHash(deployment *appsv1.Deployment, hash string) {
+	if hash == "" || deployment.Namespace != defaultCAPINamespace {
+		return
+	}
+
+	if deployment.Spec.Template.Annotations == nil {
+		deployment.Spec.Template.Annotations = map[string]string{}
+	}
+
+	deployment.Spec.Template.Annotations[apiServerCAHashAnnotation] = hash
+}
+
+// apiServerCAPredicate defines a predicate function for the API server CA ConfigMap of the CAPI namespace.
+func apiServerCAPredicate() predicate.Funcs {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetNamespace() == defaultCAPINamespace && obj.GetName() == apiServerCAConfigMapName
+	})
+}
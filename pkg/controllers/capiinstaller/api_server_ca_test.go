@@ -0,0 +1,44 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+var _ = Describe("setAPIServerCAHash", func() {
+	It("should roll the deployments of the CAPI namespace out when the CA rotates", func() {
+		deployment := &appsv1.Deployment{}
+		deployment.Namespace = defaultCAPINamespace
+
+		setAPIServerCAHash(deployment, caBundleHash("ca"))
+		Expect(deployment.Spec.Template.Annotations).To(HaveKeyWithValue(apiServerCAHashAnnotation, caBundleHash("ca")))
+
+		setAPIServerCAHash(deployment, caBundleHash("rotated"))
+		Expect(deployment.Spec.Template.Annotations).To(HaveKeyWithValue(apiServerCAHashAnnotation, caBundleHash("rotated")))
+	})
+
+	It("should not roll out deployments of other namespaces", func() {
+		deployment := &appsv1.Deployment{}
+		deployment.Namespace = "openshift-cluster-api-operator"
+
+		setAPIServerCAHash(deployment, caBundleHash("ca"))
+		Expect(deployment.Spec.Template.Annotations).To(BeEmpty())
+	})
+})
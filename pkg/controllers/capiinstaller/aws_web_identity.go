@@ -0,0 +1,120 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// awsCredentialsSecretName is the credentials secret of the AWS provider, provisioned from its CredentialsRequest.
+	awsCredentialsSecretName = "capa-manager-bootstrap-credentials"
+	// awsCredentialsKey is the key of the secret holding the AWS shared credentials file.
+	awsCredentialsKey = "credentials"
+
+	// awsRoleARNEnv and awsWebIdentityTokenFileEnv configure the AWS SDK to assume the role with the web identity token,
+	// taking precedence over the shared credentials file.
+	awsRoleARNEnv              = "AWS_ROLE_ARN"
+	awsWebIdentityTokenFileEnv = "AWS_WEB_IDENTITY_TOKEN_FILE"
+)
+
+// awsWebIdentity is the role the AWS provider assumes with a web identity token on clusters using short-lived STS credentials.
+type awsWebIdentity struct {
+	roleARN   string
+	tokenFile string
+}
+
+// getAWSWebIdentity returns the web identity of the AWS credentials secret in the namespace,
+// or nil when the secret holds static credentials or does not exist yet.
+// The API is read directly, as provider namespaces besides the CAPI namespace are not cached.
+func (r *CapiInstallerController) getAWSWebIdentity(ctx context.Context, namespace string) (*awsWebIdentity, error) {
+	secret, err := r.ApplyClient.CoreV1().Secrets(namespace).Get(ctx, awsCredentialsSecretName, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to get AWS credentials secret %s/%s: %w", namespace, awsCredentialsSecretName, err)
+	}
+
+	return parseAWSWebIdentity(secret.Data[awsCredentialsKey]), nil
+}
+
+// parseAWSWebIdentity returns the web identity of the default profile of the AWS shared credentials file,
+// or nil when the profile has no role_arn and web_identity_token_file, i.e. with static access keys.
+// In manual credentials mode with STS the cloud-credential-operator tooling writes such a profile instead of access keys.
+func parseAWSWebIdentity(credentials []byte) *awsWebIdentity {
+	identity := awsWebIdentity{}
+	profile := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(credentials))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			profile = strings.TrimSpace(strings.Trim(line, "[]"))
+		case profile != "default":
+			continue
+		default:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok {
+				continue
+			}
+
+			switch strings.TrimSpace(key) {
+			case "role_arn":
+				identity.roleARN = strings.TrimSpace(value)
+			case "web_identity_token_file":
+				identity.tokenFile = strings.TrimSpace(value)
+			}
+		}
+	}
+
+	if identity.roleARN == "" || identity.tokenFile == "" {
+		return nil
+	}
+
+	return &identity
+}
+
+// setAWSWebIdentity configures the manager container of the AWS provider deployment to assume the role of the web identity,
+// with a service account token projected at the token file of the credentials secret.
+// Nothing is done without web identity, the provider then reads the static credentials of the shared credentials file.
+func setAWSWebIdentity(deployment *appsv1.Deployment, identity *awsWebIdentity) {
+	if identity == nil {
+		return
+	}
+
+	projectServiceAccountToken(deployment, identity.tokenFile)
+
+	for i := range deployment.Spec.Template.Spec.Containers {
+		container := &deployment.Spec.Template.Spec.Containers[i]
+		if container.Name != providerManagerContainerName {
+			continue
+		}
+
+		container.Env = setEnv(container.Env, corev1.EnvVar{Name: awsRoleARNEnv, Value: identity.roleARN})
+		container.Env = setEnv(container.Env, corev1.EnvVar{Name: awsWebIdentityTokenFileEnv, Value: identity.tokenFile})
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("parseAWSWebIdentity", func() {
+	It("should return the web identity of the default profile", func() {
+		identity := parseAWSWebIdentity([]byte(`[other]
+role_arn = arn:aws:iam::123456789012:role/other
+
+[default]
+sts_regional_endpoints = regional
+role_arn = arn:aws:iam::123456789012:role/capa
+web_identity_token_file = /var/run/secrets/openshift/serviceaccount/token
+`))
+
+		Expect(identity).To(Equal(&awsWebIdentity{
+			roleARN:   "arn:aws:iam::123456789012:role/capa",
+			tokenFile: "/var/run/secrets/openshift/serviceaccount/token",
+		}))
+	})
+
+	It("should not return a web identity for static credentials", func() {
+		Expect(parseAWSWebIdentity([]byte(`[default]
+aws_access_key_id = AKIA
+aws_secret_access_key = secret
+`))).To(BeNil())
+	})
+})
+
+var _ = Describe("setAWSWebIdentity", func() {
+	It("should project the service account token into the manager container", func() {
+		deployment := &appsv1.Deployment{}
+		deployment.Spec.Template.Spec.Containers = []corev1.Container{{Name: providerManagerContainerName}, {Name: "kube-rbac-proxy"}}
+
+		identity := &awsWebIdentity{roleARN: "arn:aws:iam::123456789012:role/capa", tokenFile: "/var/run/secrets/openshift/serviceaccount/token"}
+		setAWSWebIdentity(deployment, identity)
+		setAWSWebIdentity(deployment, identity)
+
+		podSpec := deployment.Spec.Template.Spec
+		Expect(podSpec.Volumes).To(HaveLen(1))
+		Expect(podSpec.Volumes[0].Projected.Sources[0].ServiceAccountToken.Audience).To(Equal("openshift"))
+		Expect(podSpec.Volumes[0].Projected.Sources[0].ServiceAccountToken.Path).To(Equal("token"))
+
+		Expect(podSpec.Containers[0].VolumeMounts).To(ConsistOf(HaveField("MountPath", "/var/run/secrets/openshift/serviceaccount")))
+		Expect(podSpec.Containers[0].Env).To(ConsistOf(
+			corev1.EnvVar{Name: awsRoleARNEnv, Value: identity.roleARN},
+			corev1.EnvVar{Name: awsWebIdentityTokenFileEnv, Value: identity.tokenFile},
+		))
+		Expect(podSpec.Containers[1].VolumeMounts).To(BeEmpty())
+		Expect(podSpec.Containers[1].Env).To(BeEmpty())
+	})
+})
@@ -0,0 +1,71 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// azureCredentialsSecretName is the credentials secret of the Azure provider, provisioned from its CredentialsRequest.
+	azureCredentialsSecretName = "capz-manager-bootstrap-credentials"
+	// azureFederatedTokenFileKey is set in the credentials secret instead of the client secret
+	// on clusters using Azure AD Workload Identity, i.e. manual credentials mode with short-lived credentials.
+	azureFederatedTokenFileKey = "azure_federated_token_file"
+
+	// azureFederatedTokenFileEnv is the federated token file CAPZ reads for AzureClusterIdentities of type WorkloadIdentity.
+	azureFederatedTokenFileEnv = "AZURE_FEDERATED_TOKEN_FILE"
+)
+
+// getAzureFederatedTokenFile returns the federated token file of the Azure credentials secret in the namespace,
+// or an empty string when the secret holds a client secret or does not exist yet.
+// The API is read directly, as provider namespaces besides the CAPI namespace are not cached.
+func (r *CapiInstallerController) getAzureFederatedTokenFile(ctx context.Context, namespace string) (string, error) {
+	secret, err := r.ApplyClient.CoreV1().Secrets(namespace).Get(ctx, azureCredentialsSecretName, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("unable to get Azure credentials secret %s/%s: %w", namespace, azureCredentialsSecretName, err)
+	}
+
+	return string(secret.Data[azureFederatedTokenFileKey]), nil
+}
+
+// setAzureWorkloadIdentity configures the manager container of the Azure provider deployment to authenticate with
+// the federated token, projected at the token file of the credentials secret, for AzureClusterIdentities of type WorkloadIdentity.
+// Nothing is done without token file, the provider then authenticates with the client secret of the AzureClusterIdentity.
+func setAzureWorkloadIdentity(deployment *appsv1.Deployment, tokenFile string) {
+	if tokenFile == "" {
+		return
+	}
+
+	projectServiceAccountToken(deployment, tokenFile)
+
+	for i := range deployment.Spec.Template.Spec.Containers {
+		container := &deployment.Spec.Template.Spec.Containers[i]
+		if container.Name != providerManagerContainerName {
+			continue
+		}
+
+		container.Env = setEnv(container.Env, corev1.EnvVar{Name: azureFederatedTokenFileEnv, Value: tokenFile})
+	}
+}
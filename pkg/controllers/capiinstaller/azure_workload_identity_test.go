@@ -0,0 +1,53 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("setAzureWorkloadIdentity", func() {
+	newDeployment := func() *appsv1.Deployment {
+		deployment := &appsv1.Deployment{}
+		deployment.Spec.Template.Spec.Containers = []corev1.Container{{Name: providerManagerContainerName}}
+
+		return deployment
+	}
+
+	It("should project the federated token into the manager container", func() {
+		deployment := newDeployment()
+		setAzureWorkloadIdentity(deployment, "/var/run/secrets/azure/tokens/azure-identity-token")
+
+		podSpec := deployment.Spec.Template.Spec
+		Expect(podSpec.Volumes).To(ConsistOf(HaveField("Name", boundServiceAccountTokenVolumeName)))
+		Expect(podSpec.Volumes[0].Projected.Sources[0].ServiceAccountToken.Path).To(Equal("azure-identity-token"))
+		Expect(podSpec.Containers[0].VolumeMounts).To(ConsistOf(HaveField("MountPath", "/var/run/secrets/azure/tokens")))
+		Expect(podSpec.Containers[0].Env).To(ConsistOf(
+			corev1.EnvVar{Name: azureFederatedTokenFileEnv, Value: "/var/run/secrets/azure/tokens/azure-identity-token"},
+		))
+	})
+
+	It("should leave the deployment alone with a client secret", func() {
+		deployment := newDeployment()
+		setAzureWorkloadIdentity(deployment, "")
+
+		Expect(deployment).To(Equal(newDeployment()))
+	})
+})
@@ -45,6 +45,7 @@ import (
 
 	configv1 "github.com/openshift/api/config/v1"
 	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+	"github.com/openshift/cluster-capi-operator/pkg/operatorconfig"
 	"github.com/openshift/cluster-capi-operator/pkg/operatorstatus"
 	"github.com/openshift/library-go/pkg/operator/events"
 	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
@@ -57,6 +58,11 @@ const (
 	// Controller conditions for the Cluster Operator resource.
 	capiInstallerControllerAvailableCondition = "CapiInstallerControllerAvailable"
 	capiInstallerControllerDegradedCondition  = "CapiInstallerControllerDegraded"
+	// capiInstallerControllerProgressingCondition is True while the provider deployments are rolling out.
+	capiInstallerControllerProgressingCondition = "CapiInstallerControllerProgressing"
+
+	// ReasonProviderRollingOut is the reason of the Progressing condition while a provider is rolling out.
+	ReasonProviderRollingOut = "ProviderRollingOut"
 
 	controllerName                    = "CapiInstallerController"
 	defaultCAPINamespace              = "openshift-cluster-api"
@@ -70,6 +76,7 @@ const (
 	clusterOperatorName               = "cluster-api"
 	defaultCoreProviderComponentName  = "cluster-api"
 	powerVSIBMCloudProvider           = "ibmcloud"
+	baremetalMetal3Provider           = "metal3"
 )
 
 var (
@@ -87,6 +94,9 @@ type CapiInstallerController struct {
 	Platform            configv1.PlatformType
 	ApplyClient         *kubernetes.Clientset
 	APIExtensionsClient *apiextensionsclient.Clientset
+	// LifecycleProvidersEnabled enables installing the bootstrap and control plane providers of the operator config,
+	// see FeatureGateClusterAPILifecycleProviders.
+	LifecycleProvidersEnabled bool
 }
 
 // Reconcile reconciles the cluster-api ClusterOperator object.
@@ -98,10 +108,6 @@ func (r *CapiInstallerController) Reconcile(ctx context.Context, req ctrl.Reques
 		return ctrl.Result{}, fmt.Errorf("error during reconcile: %w", err)
 	}
 
-	if err := r.setAvailableCondition(ctx, log); err != nil {
-		return ctrl.Result{}, fmt.Errorf("failed to set conditions for CAPI Installer Controller: %w", err)
-	}
-
 	return res, nil
 }
 
@@ -109,34 +115,58 @@ func (r *CapiInstallerController) Reconcile(ctx context.Context, req ctrl.Reques
 // Notably it fetches CAPI providers "transport" ConfigMap(s) matching the required labels,
 // it extracts from those ConfigMaps the embedded CAPI providers manifests for the components
 // and it applies them to the cluster.
-//
-//nolint:unparam
+// A requeue is requested while the providers are rolling out. Once they all are, the controller is reported Available
+// along with the versions of the providers.
 func (r *CapiInstallerController) reconcile(ctx context.Context, log logr.Logger) (ctrl.Result, error) {
-	// Define the desired providers to be installed for this cluster.
-	// We always want to install the core provider, which in our case is the default cluster-api core provider.
-	// We also want to install the infrastructure provider that matches the currently detected platform the cluster is running on.
-	providerConfigMapLabels := map[string]string{
-		"core":           defaultCoreProviderComponentName,
-		"infrastructure": platformToProviderConfigMapLabelNameValue(r.Platform),
+	config, err := getOperatorConfig(ctx, r.Client)
+	if err != nil {
+		if err := r.setDegradedCondition(ctx, log); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to set conditions for CAPI Installer controller: %w", err)
+		}
+
+		return ctrl.Result{}, fmt.Errorf("error getting operator config: %w", err)
+	}
+
+	images := config.images(r.Images)
+
+	machines := 0
+
+	if config.hasVerticalScaling() {
+		if machines, err = r.countMachines(ctx); err != nil {
+			if err := r.setDegradedCondition(ctx, log); err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to set conditions for CAPI Installer controller: %w", err)
+			}
+
+			return ctrl.Result{}, fmt.Errorf("error counting machines for vertical scaling: %w", err)
+		}
 	}
 
+	// The versions of the providers are reported once they are all rolled out.
+	providerVersions := []configv1.OperandVersion{}
+
 	// Process each one of the desired providers.
-	for providerConfigMapLabelTypeVal, providerConfigMapLabelNameVal := range providerConfigMapLabels {
-		log.Info("reconciling CAPI provider", "name", providerConfigMapLabelNameVal)
+	for _, provider := range desiredProviders(r.Platform, config, r.LifecycleProvidersEnabled) {
+		log.Info("reconciling CAPI provider", "name", provider.name)
 
 		// Get a List all the ConfigMaps matching the desired provider labels.
 		configMapList := &corev1.ConfigMapList{}
 		if err := r.List(ctx, configMapList, client.InNamespace(defaultCAPINamespace),
 			client.MatchingLabels{
-				providerConfigMapLabelNameKey: providerConfigMapLabelNameVal,
-				providerConfigMapLabelTypeKey: providerConfigMapLabelTypeVal,
+				providerConfigMapLabelNameKey: provider.name,
+				providerConfigMapLabelTypeKey: provider.providerType,
 			},
 		); err != nil {
 			if err := r.setDegradedCondition(ctx, log); err != nil {
 				return ctrl.Result{}, fmt.Errorf("failed to set conditions for CAPI Installer controller: %w", err)
 			}
 
-			return ctrl.Result{}, fmt.Errorf("unable to list CAPI provider %q ConfigMaps: %w", providerConfigMapLabelNameVal, err)
+			return ctrl.Result{}, fmt.Errorf("unable to list CAPI provider %q ConfigMaps: %w", provider.name, err)
+		}
+
+		providerConfig := config.provider(provider.name)
+		if providerConfig.VerticalScaling != nil {
+			providerConfig = withVerticalScaling(providerConfig, machines)
+			log.V(2).Info("vertically scaling CAPI provider", "name", provider.name, "machines", machines, "resources", providerConfig.Resources)
 		}
 
 		// Extract the provider manifests stored each of the matching ConfigMaps.
@@ -146,7 +176,11 @@ func (r *CapiInstallerController) reconcile(ctx context.Context, log logr.Logger
 			log.Info("processing CAPI provider ConfigMap", "configmapName", cm.Name, "providerType", cm.Labels[providerConfigMapLabelTypeKey],
 				"providerName", cm.Labels[providerConfigMapLabelNameKey], "providerVersion", cm.Labels[providerConfigMapLabelVersionKey])
 
-			partialComponents, err := r.extractProviderComponents(cm)
+			partialComponents, err := extractProviderComponents(cm, images)
+			if err == nil {
+				partialComponents, err = setTargetNamespace(partialComponents, provider.namespace)
+			}
+
 			if err != nil {
 				if err := r.setDegradedCondition(ctx, log); err != nil {
 					return ctrl.Result{}, fmt.Errorf("failed to set conditions for CAPI Installer controller: %w", err)
@@ -158,40 +192,120 @@ func (r *CapiInstallerController) reconcile(ctx context.Context, log logr.Logger
 			providerComponents = append(providerComponents, partialComponents...)
 		}
 
-		// Apply all the collected provider components manifests.
-		if err := r.applyProviderComponents(ctx, providerComponents); err != nil {
-			if err := r.setDegradedCondition(ctx, log); err != nil {
+		// Apply all the collected provider components manifests,
+		// once the infrastructure providers have requested their credentials.
+		err = r.ensureProviderNamespace(ctx, provider.namespace)
+		if err == nil && provider.providerType == "infrastructure" {
+			err = r.ensureCredentialsRequest(ctx, log, provider, providerComponents)
+		}
+
+		if err == nil {
+			err = r.applyProviderComponents(ctx, log, providerComponents, provider.componentName, providerConfig)
+		}
+
+		if errors.Is(err, errProviderRolloutInProgress) {
+			// The following providers may depend on this one, so they are only rolled out once it is.
+			log.Info("CAPI provider rollout in progress", "name", provider.name, "reason", err.Error())
+
+			if err := r.setProgressingCondition(ctx, log, err.Error()); err != nil {
 				return ctrl.Result{}, fmt.Errorf("failed to set conditions for CAPI Installer controller: %w", err)
 			}
 
-			return ctrl.Result{}, fmt.Errorf("error applying CAPI provider %q components: %w", providerConfigMapLabelNameVal, err)
+			return ctrl.Result{RequeueAfter: providerRolloutRequeueAfter}, nil
+		} else if err != nil {
+			reason, message := operatorstatus.ReasonSyncFailed, "CAPI Installer Controller failed install"
+
+			var invalidComponent *invalidComponentError
+			if errors.As(err, &invalidComponent) {
+				reason, message = ReasonInvalidProviderComponent, fmt.Sprintf("CAPI provider %q was not installed: %v", provider.name, err)
+			}
+
+			if err := r.setDegradedConditionWithReason(ctx, log, reason, message); err != nil {
+				return ctrl.Result{}, fmt.Errorf("failed to set conditions for CAPI Installer controller: %w", err)
+			}
+
+			return ctrl.Result{}, fmt.Errorf("error applying CAPI provider %q components: %w", provider.name, err)
+		}
+
+		if version := providerVersion(configMapList.Items); version != "" {
+			providerVersions = append(providerVersions, configv1.OperandVersion{Name: provider.componentName, Version: version})
 		}
 
-		log.Info("finished reconciling CAPI provider", "name", providerConfigMapLabelNameVal)
+		log.Info("finished reconciling CAPI provider", "name", provider.name)
+	}
+
+	if err := r.setAvailableCondition(ctx, log, providerVersions); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to set conditions for CAPI Installer Controller: %w", err)
 	}
 
 	return ctrl.Result{}, nil
 }
 
+// providerVersion returns the version of the provider, from the version label of its transport ConfigMaps.
+func providerVersion(configMaps []corev1.ConfigMap) string {
+	for _, cm := range configMaps {
+		if version := cm.Labels[providerConfigMapLabelVersionKey]; version != "" {
+			return version
+		}
+	}
+
+	return ""
+}
+
 // applyProviderComponents applies the provider components to the cluster.
-// It does so by differentiating between static components and dynamic components (i.e. Deployments).
-func (r *CapiInstallerController) applyProviderComponents(ctx context.Context, components []string) error {
+// The components are first validated by a server-side dry-run, so that no component is applied unless all are valid.
+// It then applies them in sequence, so that no API request is sent to a webhook server which is not ready:
+// the CustomResourceDefinitions are applied first and waited on to be established, then the other static components,
+// then the Deployments, customized with the provider configuration, are rolled out.
+// The webhook configurations are applied last, once the conversion webhooks of the CustomResourceDefinitions are served.
+// While a step is still in progress errProviderRolloutInProgress is returned, so the rollout is resumed later.
+// Once rolled out, the provider API is smoke checked, its CustomResourceDefinitions are migrated
+// to their storage version and stale ones are pruned.
+func (r *CapiInstallerController) applyProviderComponents(ctx context.Context, log logr.Logger, components []string, providerComponentName string, config providerConfig) error {
 	componentsFilenames, componentsAssets, deploymentsFilenames, deploymentsAssets, err := getProviderComponents(r.Scheme, components)
 	if err != nil {
 		return fmt.Errorf("error getting provider components: %w", err)
 	}
 
-	// Perform a Direct apply of the static components.
-	res := resourceapply.ApplyDirectly(
-		ctx,
-		resourceapply.NewKubeClientHolder(r.ApplyClient).WithAPIExtensionsClient(r.APIExtensionsClient),
-		events.NewInMemoryRecorder("cluster-capi-operator-capi-installer-apply-client"),
-		resourceapply.NewResourceCache(),
-		assetFn(componentsAssets),
-		componentsFilenames...,
-	)
+	crdNames, err := r.retainStoredVersions(ctx, componentsAssets)
+	if err != nil {
+		return fmt.Errorf("error retaining stored versions of provider CustomResourceDefinitions: %w", err)
+	}
+
+	sequence, err := sequenceComponents(r.Scheme, componentsFilenames, componentsAssets)
+	if err != nil {
+		return fmt.Errorf("error sequencing provider components: %w", err)
+	}
+
+	// The platform infrastructure provider is rolled out when its credentials are rotated.
+	credentialsHashValue := ""
+
+	if providerComponentName == platformToInfraProviderComponentName(r.Platform) {
+		secrets, err := r.getCredentialsSecrets(ctx)
+		if err != nil {
+			return err
+		}
+
+		credentialsHashValue = credentialsHash(secrets)
+	}
+
+	// The providers go through the cluster-wide proxy, if any.
+	proxyEnv, err := r.getProxyEnv(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The controllers consuming the kubeconfig secrets are rolled out when the serving CA of the API server rotates.
+	apiServerCAHash, err := r.getAPIServerCAHash(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The providers trust the CA bundle of the cluster, e.g. to reach private cloud endpoints or go through a TLS-intercepting proxy.
+	trustedCABundleHashes := map[string]string{}
+
+	deployments := make(map[string]*appsv1.Deployment, len(deploymentsFilenames))
 
-	// For each of the Deployment components perform a Deployment-specific apply.
 	for _, d := range deploymentsFilenames {
 		deploymentManifest, ok := deploymentsAssets[d]
 		if !ok {
@@ -203,32 +317,125 @@ func (r *CapiInstallerController) applyProviderComponents(ctx context.Context, c
 			return fmt.Errorf("error parsing CAPI provider deployment manifets %q: %w", d, err)
 		}
 
-		// TODO: Deployments State/Conditions should influence the overall ClusterOperator Status.
 		deployment, ok := obj.(*appsv1.Deployment)
 		if !ok {
 			return fmt.Errorf("error casting object to Deployment: %w", err)
 		}
 
-		if _, _, err := resourceapply.ApplyDeployment(
+		bundleHash, ok := trustedCABundleHashes[deployment.Namespace]
+		if !ok {
+			if bundleHash, err = r.ensureTrustedCABundle(ctx, deployment.Namespace); err != nil {
+				return err
+			}
+
+			trustedCABundleHashes[deployment.Namespace] = bundleHash
+		}
+
+		// The AWS provider assumes its role with a web identity token on clusters using short-lived STS credentials.
+		if providerComponentName == platformToInfraProviderComponentName(configv1.AWSPlatformType) {
+			webIdentity, err := r.getAWSWebIdentity(ctx, deployment.Namespace)
+			if err != nil {
+				return err
+			}
+
+			setAWSWebIdentity(deployment, webIdentity)
+		}
+
+		// The Azure provider authenticates with a federated token on clusters using Azure AD Workload Identity.
+		if providerComponentName == platformToInfraProviderComponentName(configv1.AzurePlatformType) {
+			tokenFile, err := r.getAzureFederatedTokenFile(ctx, deployment.Namespace)
+			if err != nil {
+				return err
+			}
+
+			setAzureWorkloadIdentity(deployment, tokenFile)
+		}
+
+		// The GCP provider exchanges a service account token for credentials on clusters using Workload Identity Federation.
+		if providerComponentName == platformToInfraProviderComponentName(configv1.GCPPlatformType) {
+			tokenFile, err := r.getGCPExternalAccountTokenFile(ctx, deployment.Namespace)
+			if err != nil {
+				return err
+			}
+
+			setGCPWorkloadIdentity(deployment, tokenFile)
+		}
+
+		customizeDeployment(deployment, config)
+		setCredentialsHash(deployment, credentialsHashValue)
+		setTrustedCABundle(deployment, bundleHash)
+		setProxyEnv(deployment, proxyEnv)
+		setAPIServerCAHash(deployment, apiServerCAHash)
+
+		deployments[d] = deployment
+	}
+
+	if err := r.dryRunComponents(ctx, componentsFilenames, componentsAssets, deployments); err != nil {
+		return fmt.Errorf("error validating provider components: %w", err)
+	}
+
+	if err := r.applyStaticComponents(ctx, componentsAssets, sequence.crds); err != nil {
+		return err
+	}
+
+	if err := r.waitForCRDsEstablished(ctx, crdNames); err != nil {
+		return fmt.Errorf("error waiting for provider CustomResourceDefinitions: %w", err)
+	}
+
+	if err := r.applyStaticComponents(ctx, componentsAssets, sequence.static); err != nil {
+		return err
+	}
+
+	// For each of the Deployment components perform a Deployment-specific apply.
+	rolloutProgress := []string{}
+
+	for _, d := range deploymentsFilenames {
+		deployment := deployments[d]
+
+		applied, _, err := resourceapply.ApplyDeployment(
 			ctx,
 			r.ApplyClient.AppsV1(),
 			events.NewInMemoryRecorder("cluster-capi-operator-capi-installer-apply-client"),
 			deployment,
 			resourcemerge.ExpectedDeploymentGeneration(deployment, nil),
-		); err != nil {
+		)
+		if err != nil {
 			return fmt.Errorf("error applying CAPI provider deployment %q: %w", deployment.Name, err)
 		}
-	}
 
-	var errs error
+		// Deployments which never roll out, e.g. crash looping, are reported by the OperandHealthController.
+		if !isDeploymentRolledOut(applied) {
+			log.Info("waiting for CAPI provider deployment to roll out", "name", applied.Name)
 
-	for i, r := range res {
-		if r.Error != nil {
-			errs = errors.Join(errs, fmt.Errorf("error applying CAPI provider component %q at position %d: %w", r.File, i, r.Error))
+			rolloutProgress = append(rolloutProgress, deploymentRolloutProgress(applied))
 		}
 	}
 
-	return errs
+	if len(rolloutProgress) > 0 {
+		return fmt.Errorf("%w: waiting for deployments of provider %q: %s", errProviderRolloutInProgress, providerComponentName, strings.Join(rolloutProgress, "; "))
+	}
+
+	if err := r.checkConversionWebhooksReady(ctx, crdNames); err != nil {
+		return err
+	}
+
+	if err := r.applyStaticComponents(ctx, componentsAssets, sequence.webhooks); err != nil {
+		return err
+	}
+
+	if err := r.smokeCheckCRDs(ctx, crdNames); err != nil {
+		return fmt.Errorf("error smoke checking provider API: %w", err)
+	}
+
+	if err := r.migrateStoredVersions(ctx, log, crdNames); err != nil {
+		return fmt.Errorf("error migrating stored versions of provider CustomResourceDefinitions: %w", err)
+	}
+
+	if err := r.pruneStaleCRDs(ctx, log, providerComponentName, crdNames); err != nil {
+		return fmt.Errorf("error pruning stale provider CustomResourceDefinitions: %w", err)
+	}
+
+	return nil
 }
 
 // getProviderComponents parses the provided list of components into a map of filenames and assets.
@@ -267,8 +474,9 @@ func getProviderComponents(scheme *runtime.Scheme, components []string) ([]strin
 	return componentsFilenames, componentsAssets, deploymentsFilenames, deploymentsAssets, nil
 }
 
-// setAvailableCondition sets the ClusterOperator status condition to Available.
-func (r *CapiInstallerController) setAvailableCondition(ctx context.Context, log logr.Logger) error {
+// setAvailableCondition sets the ClusterOperator status condition to Available,
+// and reports the versions of the operator and of the rolled out providers.
+func (r *CapiInstallerController) setAvailableCondition(ctx context.Context, log logr.Logger, providerVersions []configv1.OperandVersion) error {
 	co, err := r.GetOrCreateClusterOperator(ctx)
 	if err != nil {
 		return fmt.Errorf("unable to get cluster operator: %w", err)
@@ -279,9 +487,14 @@ func (r *CapiInstallerController) setAvailableCondition(ctx context.Context, log
 			"CAPI Installer Controller works as expected"),
 		operatorstatus.NewClusterOperatorStatusCondition(capiInstallerControllerDegradedCondition, configv1.ConditionFalse, operatorstatus.ReasonAsExpected,
 			"CAPI Installer Controller works as expected"),
+		operatorstatus.NewClusterOperatorStatusCondition(capiInstallerControllerProgressingCondition, configv1.ConditionFalse, operatorstatus.ReasonAsExpected,
+			"All CAPI providers are rolled out"),
 	}
 
-	co.Status.Versions = []configv1.OperandVersion{{Name: controllers.OperatorVersionKey, Version: r.ReleaseVersion}}
+	events := providerVersionEvents(co.Status.Versions, providerVersions)
+
+	// The versions of providers which are no longer installed are dropped.
+	co.Status.Versions = append([]configv1.OperandVersion{{Name: controllers.OperatorVersionKey, Version: r.ReleaseVersion}}, providerVersions...)
 
 	log.V(2).Info("CAPI Installer Controller is Available")
 
@@ -289,24 +502,52 @@ func (r *CapiInstallerController) setAvailableCondition(ctx context.Context, log
 		return fmt.Errorf("failed to sync status: %w", err)
 	}
 
+	for _, event := range events {
+		r.Recorder.Event(co, corev1.EventTypeNormal, event.reason, event.message)
+	}
+
+	return nil
+}
+
+// setProgressingCondition sets the ClusterOperator status condition to Progressing, with the progress of the rollout.
+// The Available and Degraded conditions are left as they are until the rollout completes.
+func (r *CapiInstallerController) setProgressingCondition(ctx context.Context, log logr.Logger, message string) error {
+	co, err := r.GetOrCreateClusterOperator(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get cluster operator: %w", err)
+	}
+
+	conds := []configv1.ClusterOperatorStatusCondition{
+		operatorstatus.NewClusterOperatorStatusCondition(capiInstallerControllerProgressingCondition, configv1.ConditionTrue, ReasonProviderRollingOut, message),
+	}
+
+	log.V(2).Info("CAPI Installer Controller is Progressing")
+
+	if err := r.SyncStatus(ctx, co, conds); err != nil {
+		return fmt.Errorf("failed to sync status: %w", err)
+	}
+
 	return nil
 }
 
-// setAvailableCondition sets the ClusterOperator status condition to Degraded.
+// setDegradedCondition sets the ClusterOperator status condition to Degraded.
 func (r *CapiInstallerController) setDegradedCondition(ctx context.Context, log logr.Logger) error {
+	return r.setDegradedConditionWithReason(ctx, log, operatorstatus.ReasonSyncFailed, "CAPI Installer Controller failed install")
+}
+
+// setDegradedConditionWithReason sets the ClusterOperator status condition to Degraded, with the given reason and message.
+func (r *CapiInstallerController) setDegradedConditionWithReason(ctx context.Context, log logr.Logger, reason, message string) error {
 	co, err := r.GetOrCreateClusterOperator(ctx)
 	if err != nil {
 		return fmt.Errorf("unable to get cluster operator: %w", err)
 	}
 
 	conds := []configv1.ClusterOperatorStatusCondition{
-		operatorstatus.NewClusterOperatorStatusCondition(capiInstallerControllerAvailableCondition, configv1.ConditionFalse, operatorstatus.ReasonSyncFailed,
-			"CAPI Installer Controller failed install"),
-		operatorstatus.NewClusterOperatorStatusCondition(capiInstallerControllerDegradedCondition, configv1.ConditionTrue, operatorstatus.ReasonSyncFailed,
-			"CAPI Installer Controller failed install"),
+		operatorstatus.NewClusterOperatorStatusCondition(capiInstallerControllerAvailableCondition, configv1.ConditionFalse, reason, message),
+		operatorstatus.NewClusterOperatorStatusCondition(capiInstallerControllerDegradedCondition, configv1.ConditionTrue, reason, message),
 	}
 
-	co.Status.Versions = []configv1.OperandVersion{{Name: controllers.OperatorVersionKey, Version: r.ReleaseVersion}}
+	r.SetOperatorVersion(co)
 
 	log.Info("CAPI Installer Controller is Degraded")
 
@@ -326,6 +567,36 @@ func (r *CapiInstallerController) SetupWithManager(mgr ctrl.Manager) error {
 			&corev1.ConfigMap{},
 			handler.EnqueueRequestsFromMapFunc(toClusterOperator),
 			builder.WithPredicates(configMapPredicate(r.ManagedNamespace, r.Platform)),
+		).
+		Watches(
+			&corev1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(toClusterOperator),
+			builder.WithPredicates(operatorConfigPredicate()),
+		).
+		Watches(
+			operatorconfig.New(),
+			handler.EnqueueRequestsFromMapFunc(toClusterOperator),
+			builder.WithPredicates(operatorconfig.Predicate()),
+		).
+		Watches(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(toClusterOperator),
+			builder.WithPredicates(credentialsSecretPredicate(r.Platform)),
+		).
+		Watches(
+			&corev1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(toClusterOperator),
+			builder.WithPredicates(trustedCABundlePredicate()),
+		).
+		Watches(
+			&corev1.ConfigMap{},
+			handler.EnqueueRequestsFromMapFunc(toClusterOperator),
+			builder.WithPredicates(apiServerCAPredicate()),
+		).
+		Watches(
+			&configv1.Proxy{},
+			handler.EnqueueRequestsFromMapFunc(toClusterOperator),
+			builder.WithPredicates(proxyPredicate()),
 		)
 
 	// All of the following watches share the ownedPlatformLabelPredicate.
@@ -366,7 +637,7 @@ func (r *CapiInstallerController) SetupWithManager(mgr ctrl.Manager) error {
 // The format of the ConfigMap is well known and follows the upstream CAPI's
 // clusterctl Provider Contract - Components YAML file contract defined at:
 // https://github.com/kubernetes-sigs/cluster-api/blob/a36712e28bf5d54e398ea84cb3e20102c0499426/docs/book/src/clusterctl/provider-contract.md?plain=1#L157-L162
-func (r *CapiInstallerController) extractProviderComponents(cm corev1.ConfigMap) ([]string, error) {
+func extractProviderComponents(cm corev1.ConfigMap, images map[string]string) ([]string, error) {
 	yamlManifests, err := extractManifests(cm)
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract manifests from configMap: %w", err)
@@ -374,12 +645,13 @@ func (r *CapiInstallerController) extractProviderComponents(cm corev1.ConfigMap)
 
 	replacedYamlManifests := []string{}
 	providerName := cm.Labels[providerConfigMapLabelNameKey]
+	providerType := cm.Labels[providerConfigMapLabelTypeKey]
 
 	for _, m := range yamlManifests {
-		newM := strings.Replace(m, imagePlaceholder, r.Images[providerNameToImageKey(providerName)], 1)
-		newM = strings.Replace(newM, "registry.ci.openshift.org/openshift:kube-rbac-proxy", r.Images["kube-rbac-proxy"], 1)
+		newM := strings.Replace(m, imagePlaceholder, images[providerNameToImageKey(providerName)], 1)
+		newM = strings.Replace(newM, "registry.ci.openshift.org/openshift:kube-rbac-proxy", images["kube-rbac-proxy"], 1)
 		// TODO: change this to manager in the forked providers openshift/Dockerfile.rhel.
-		newM = strings.Replace(newM, "/manager", providerNameToCommand(providerName), 1)
+		newM = strings.Replace(newM, "/manager", providerToCommand(providerType, providerName), 1)
 
 		replacedYamlManifests = append(replacedYamlManifests, newM)
 	}
@@ -428,21 +700,26 @@ func extractManifests(cm corev1.ConfigMap) ([]string, error) {
 // platformToProviderConfigMapLabelNameValue maps an OpenShift configv1.PlatformType
 // to a matching CAPI provider ConfigMap `name` Label value.
 func platformToProviderConfigMapLabelNameValue(platform configv1.PlatformType) string {
-	if platform == configv1.PowerVSPlatformType {
-		platform = powerVSIBMCloudProvider
-	}
-
-	return strings.ToLower(string(platform))
+	return strings.ToLower(platformToInfraProviderName(platform))
 }
 
 // platformToInfraProviderComponentName maps an OpenShift configv1.PlatformType
 // to a matching CAPI ownedProviderComponentName (see consts) Label value.
 func platformToInfraProviderComponentName(platform configv1.PlatformType) string {
-	if platform == configv1.PowerVSPlatformType {
-		platform = powerVSIBMCloudProvider
-	}
+	return strings.ToLower(fmt.Sprintf("infrastructure-%s", platformToInfraProviderName(platform)))
+}
 
-	return strings.ToLower(fmt.Sprintf("infrastructure-%s", platform))
+// platformToInfraProviderName maps an OpenShift configv1.PlatformType
+// to the name of the CAPI infrastructure provider for that platform.
+func platformToInfraProviderName(platform configv1.PlatformType) string {
+	switch platform {
+	case configv1.PowerVSPlatformType:
+		return powerVSIBMCloudProvider
+	case configv1.BareMetalPlatformType:
+		return baremetalMetal3Provider
+	default:
+		return string(platform)
+	}
 }
 
 // getResourceName returns a "namespace/name" string or a "name" string if namespace is empty.
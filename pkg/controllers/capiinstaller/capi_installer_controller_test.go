@@ -19,6 +19,7 @@ import (
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	configv1 "github.com/openshift/api/config/v1"
 	corev1 "k8s.io/api/core/v1"
 )
 
@@ -105,3 +106,36 @@ var _ = Describe("extractManifests", func() {
 		})
 	}
 })
+
+var _ = DescribeTable("platformToProviderConfigMapLabelNameValue and platformToInfraProviderComponentName",
+	func(platform configv1.PlatformType, expectedLabelValue, expectedComponentName string) {
+		Expect(platformToProviderConfigMapLabelNameValue(platform)).To(Equal(expectedLabelValue))
+		Expect(platformToInfraProviderComponentName(platform)).To(Equal(expectedComponentName))
+	},
+	Entry("with AWS", configv1.AWSPlatformType, "aws", "infrastructure-aws"),
+	Entry("with PowerVS", configv1.PowerVSPlatformType, "ibmcloud", "infrastructure-ibmcloud"),
+	Entry("with IBM Cloud", configv1.IBMCloudPlatformType, "ibmcloud", "infrastructure-ibmcloud"),
+	Entry("with BareMetal", configv1.BareMetalPlatformType, "metal3", "infrastructure-metal3"),
+	Entry("with Nutanix", configv1.NutanixPlatformType, "nutanix", "infrastructure-nutanix"),
+)
+
+var _ = Describe("providerVersion", func() {
+	configMap := func(version string) corev1.ConfigMap {
+		cm := corev1.ConfigMap{}
+		cm.Labels = map[string]string{providerConfigMapLabelNameKey: "aws"}
+
+		if version != "" {
+			cm.Labels[providerConfigMapLabelVersionKey] = version
+		}
+
+		return cm
+	}
+
+	It("should be empty without transport ConfigMaps", func() {
+		Expect(providerVersion(nil)).To(BeEmpty())
+	})
+
+	It("should be the version label of the transport ConfigMaps", func() {
+		Expect(providerVersion([]corev1.ConfigMap{configMap(""), configMap("v2.5.2")})).To(Equal("v2.5.2"))
+	})
+})
@@ -16,6 +16,20 @@ limitations under the License.
 
 package capiinstaller
 
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// providerManagerContainerName is the name of the container running the provider controllers.
+const providerManagerContainerName = "manager"
+
 func providerNameToImageKey(name string) string {
 	switch name {
 	case "aws":
@@ -28,13 +42,28 @@ func providerNameToImageKey(name string) string {
 		return "vsphere-cluster-api-controllers"
 	case "ibmcloud":
 		return "ibmcloud-cluster-api-controllers"
-	case "cluster-api":
+	case "cluster-api", "kubeadm":
 		return "cluster-capi-controllers"
+	case "in-cluster":
+		return "cluster-api-ipam-in-cluster-controllers"
 	default:
 		return "none"
 	}
 }
 
+// providerToCommand returns the command of the provider manager.
+// The kubeadm bootstrap and control plane providers are built along with the core provider, so share its image.
+func providerToCommand(providerType, name string) string {
+	switch {
+	case providerType == bootstrapProviderType && name == "kubeadm":
+		return "./bin/kubeadm-bootstrap-controller-manager"
+	case providerType == controlPlaneProviderType && name == "kubeadm":
+		return "./bin/kubeadm-control-plane-controller-manager"
+	default:
+		return providerNameToCommand(name)
+	}
+}
+
 func providerNameToCommand(name string) string {
 	switch name {
 	case "aws", "gcp", "ibmcloud":
@@ -45,3 +74,92 @@ func providerNameToCommand(name string) string {
 		return "/manager"
 	}
 }
+
+// customizeDeployment applies the provider configuration of the operator config to a provider deployment.
+func customizeDeployment(deployment *appsv1.Deployment, config providerConfig) {
+	if placement := config.NodePlacement; placement != nil {
+		podSpec := &deployment.Spec.Template.Spec
+
+		if placement.NodeSelector != nil {
+			podSpec.NodeSelector = placement.NodeSelector
+		}
+
+		if placement.Tolerations != nil {
+			podSpec.Tolerations = placement.Tolerations
+		}
+
+		if placement.Affinity != nil {
+			podSpec.Affinity = placement.Affinity
+		}
+	}
+
+	for i := range deployment.Spec.Template.Spec.Containers {
+		container := &deployment.Spec.Template.Spec.Containers[i]
+		if container.Name != providerManagerContainerName {
+			continue
+		}
+
+		if config.Resources != nil {
+			container.Resources.Requests = mergeResourceList(container.Resources.Requests, config.Resources.Requests)
+			container.Resources.Limits = mergeResourceList(container.Resources.Limits, config.Resources.Limits)
+		}
+
+		if leaderElection := config.LeaderElection; leaderElection != nil {
+			setDurationArg(container, "--leader-elect-lease-duration", leaderElection.LeaseDuration)
+			setDurationArg(container, "--leader-elect-renew-deadline", leaderElection.RenewDeadline)
+			setDurationArg(container, "--leader-elect-retry-period", leaderElection.RetryPeriod)
+		}
+
+		setDurationArg(container, "--sync-period", config.SyncPeriod)
+	}
+}
+
+// setDurationArg sets the argument of the container to the duration, if set,
+// replacing any value the argument had, in either the "--flag=value" or the "--flag value" form.
+func setDurationArg(container *corev1.Container, flag string, d *metav1.Duration) {
+	if d == nil {
+		return
+	}
+
+	args := make([]string, 0, len(container.Args)+1)
+
+	for i := 0; i < len(container.Args); i++ {
+		switch {
+		case strings.HasPrefix(container.Args[i], flag+"="):
+			continue
+		case container.Args[i] == flag:
+			// Skip the value too.
+			i++
+
+			continue
+		}
+
+		args = append(args, container.Args[i])
+	}
+
+	container.Args = append(args, fmt.Sprintf("%s=%s", flag, d.Duration))
+}
+
+// setEnv returns the environment variables with the given one set, replacing its value if it was already set.
+func setEnv(env []corev1.EnvVar, envVar corev1.EnvVar) []corev1.EnvVar {
+	if i := slices.IndexFunc(env, func(e corev1.EnvVar) bool { return e.Name == envVar.Name }); i >= 0 {
+		env[i] = envVar
+
+		return env
+	}
+
+	return append(env, envVar)
+}
+
+// mergeResourceList returns the resources with the overrides applied.
+func mergeResourceList(resources, overrides corev1.ResourceList) corev1.ResourceList {
+	if len(overrides) == 0 {
+		return resources
+	}
+
+	merged := make(corev1.ResourceList, len(resources)+len(overrides))
+	maps.Copy(merged, resources)
+	maps.Copy(merged, overrides)
+
+	return merged
+}
@@ -0,0 +1,260 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/go-logr/logr"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	errCRDHasNoStorageVersion = errors.New("CustomResourceDefinition has no storage version")
+	errCRDHasNoServedVersion  = errors.New("CustomResourceDefinition has no served version")
+)
+
+// retainStoredVersions updates the CustomResourceDefinition manifests among the component assets so that the versions
+// objects may still be stored in are not dropped before those objects are migrated, which the API server would refuse.
+// It returns the names of the CustomResourceDefinitions among the components.
+func (r *CapiInstallerController) retainStoredVersions(ctx context.Context, componentsAssets map[string]string) ([]string, error) {
+	crdNames := []string{}
+
+	for name, manifest := range componentsAssets {
+		u, err := yamlToUnstructured(r.Scheme, manifest)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing provider component %q to unstructured: %w", name, err)
+		}
+
+		if u.GroupVersionKind().Kind != "CustomResourceDefinition" {
+			continue
+		}
+
+		crdNames = append(crdNames, u.GetName())
+
+		existing, err := r.APIExtensionsClient.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, u.GetName(), metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("error getting CustomResourceDefinition %q: %w", u.GetName(), err)
+		}
+
+		crd := &apiextensionsv1.CustomResourceDefinition{}
+		if err := yaml.Unmarshal([]byte(manifest), crd); err != nil {
+			return nil, fmt.Errorf("error parsing CustomResourceDefinition %q: %w", u.GetName(), err)
+		}
+
+		if !withStoredVersions(crd, existing) {
+			continue
+		}
+
+		retained, err := yaml.Marshal(crd)
+		if err != nil {
+			return nil, fmt.Errorf("error serializing CustomResourceDefinition %q: %w", u.GetName(), err)
+		}
+
+		componentsAssets[name] = string(retained)
+	}
+
+	return crdNames, nil
+}
+
+// withStoredVersions adds the versions of the existing CustomResourceDefinition which are still recorded as stored
+// but are missing from the required CustomResourceDefinition, as neither served nor stored.
+// It returns whether the required CustomResourceDefinition was changed.
+func withStoredVersions(required, existing *apiextensionsv1.CustomResourceDefinition) bool {
+	changed := false
+
+	for _, storedVersion := range existing.Status.StoredVersions {
+		if slices.ContainsFunc(required.Spec.Versions, func(v apiextensionsv1.CustomResourceDefinitionVersion) bool { return v.Name == storedVersion }) {
+			continue
+		}
+
+		i := slices.IndexFunc(existing.Spec.Versions, func(v apiextensionsv1.CustomResourceDefinitionVersion) bool { return v.Name == storedVersion })
+		if i < 0 {
+			continue
+		}
+
+		version := *existing.Spec.Versions[i].DeepCopy()
+		version.Served = false
+		version.Storage = false
+
+		required.Spec.Versions = append(required.Spec.Versions, version)
+		changed = true
+	}
+
+	return changed
+}
+
+// migrateStoredVersions rewrites the objects of the CustomResourceDefinitions which may still be stored in versions
+// other than the storage version, then records the storage version as the only stored version.
+// Once no longer stored, the versions dropped by the provider are pruned the next time its components are applied.
+func (r *CapiInstallerController) migrateStoredVersions(ctx context.Context, log logr.Logger, crdNames []string) error {
+	var errs error
+
+	for _, name := range crdNames {
+		crd, err := r.APIExtensionsClient.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error getting CustomResourceDefinition %q: %w", name, err))
+			continue
+		}
+
+		storageVersion, err := getStorageVersion(crd)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error migrating CustomResourceDefinition %q: %w", name, err))
+			continue
+		}
+
+		if slices.Equal(crd.Status.StoredVersions, []string{storageVersion}) {
+			continue
+		}
+
+		log.Info("migrating CustomResourceDefinition objects to the storage version", "name", name,
+			"storedVersions", crd.Status.StoredVersions, "storageVersion", storageVersion)
+
+		if err := r.migrateObjects(ctx, crd); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error migrating CustomResourceDefinition %q objects: %w", name, err))
+			continue
+		}
+
+		crd.Status.StoredVersions = []string{storageVersion}
+		if _, err := r.APIExtensionsClient.ApiextensionsV1().CustomResourceDefinitions().UpdateStatus(ctx, crd, metav1.UpdateOptions{}); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error updating CustomResourceDefinition %q stored versions: %w", name, err))
+		}
+	}
+
+	return errs
+}
+
+// migrateObjects rewrites all the objects of the CustomResourceDefinition, so they are stored in the storage version.
+func (r *CapiInstallerController) migrateObjects(ctx context.Context, crd *apiextensionsv1.CustomResourceDefinition) error {
+	list, err := newObjectList(crd)
+	if err != nil {
+		return err
+	}
+
+	if err := r.List(ctx, list); err != nil {
+		return fmt.Errorf("error listing objects: %w", err)
+	}
+
+	for i := range list.Items {
+		// Objects are written in the storage version, even when unchanged.
+		if err := r.Update(ctx, &list.Items[i]); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("error updating %s: %w", client.ObjectKeyFromObject(&list.Items[i]), err)
+		}
+	}
+
+	return nil
+}
+
+// pruneStaleCRDs deletes the CustomResourceDefinitions previously installed for the provider which are no longer
+// part of its components. CustomResourceDefinitions which still have objects are kept, so no data is lost.
+func (r *CapiInstallerController) pruneStaleCRDs(ctx context.Context, log logr.Logger, providerComponentName string, crdNames []string) error {
+	if len(crdNames) == 0 {
+		// Without any CustomResourceDefinition among the components, they are most likely incomplete.
+		return nil
+	}
+
+	crds, err := r.APIExtensionsClient.ApiextensionsV1().CustomResourceDefinitions().List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", ownedProviderComponentName, providerComponentName),
+	})
+	if err != nil {
+		return fmt.Errorf("error listing CustomResourceDefinitions of provider %q: %w", providerComponentName, err)
+	}
+
+	var errs error
+
+	for i := range crds.Items {
+		crd := &crds.Items[i]
+		if slices.Contains(crdNames, crd.Name) {
+			continue
+		}
+
+		hasObjects, err := r.hasObjects(ctx, crd)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error checking stale CustomResourceDefinition %q for objects: %w", crd.Name, err))
+			continue
+		}
+
+		if hasObjects {
+			log.Info("keeping stale CustomResourceDefinition as it still has objects", "name", crd.Name, "provider", providerComponentName)
+			continue
+		}
+
+		log.Info("pruning stale CustomResourceDefinition", "name", crd.Name, "provider", providerComponentName)
+
+		if err := r.APIExtensionsClient.ApiextensionsV1().CustomResourceDefinitions().Delete(ctx, crd.Name, metav1.DeleteOptions{}); err != nil && !kerrors.IsNotFound(err) {
+			errs = errors.Join(errs, fmt.Errorf("error deleting stale CustomResourceDefinition %q: %w", crd.Name, err))
+		}
+	}
+
+	return errs
+}
+
+// hasObjects returns whether any object of the CustomResourceDefinition exists.
+func (r *CapiInstallerController) hasObjects(ctx context.Context, crd *apiextensionsv1.CustomResourceDefinition) (bool, error) {
+	list, err := newObjectList(crd)
+	if err != nil {
+		return false, err
+	}
+
+	if err := r.List(ctx, list, client.Limit(1)); err != nil {
+		return false, fmt.Errorf("error listing objects: %w", err)
+	}
+
+	return len(list.Items) > 0, nil
+}
+
+// newObjectList returns an empty list of the objects of the CustomResourceDefinition, in a served version.
+// The storage version is preferred, so objects are listed without conversion.
+func newObjectList(crd *apiextensionsv1.CustomResourceDefinition) (*unstructured.UnstructuredList, error) {
+	version := ""
+
+	for _, v := range crd.Spec.Versions {
+		if v.Served && (version == "" || v.Storage) {
+			version = v.Name
+		}
+	}
+
+	if version == "" {
+		return nil, fmt.Errorf("%w: %s", errCRDHasNoServedVersion, crd.Name)
+	}
+
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(schema.GroupVersionKind{Group: crd.Spec.Group, Version: version, Kind: crd.Spec.Names.ListKind})
+
+	return list, nil
+}
+
+// getStorageVersion returns the storage version of the CustomResourceDefinition.
+func getStorageVersion(crd *apiextensionsv1.CustomResourceDefinition) (string, error) {
+	for _, version := range crd.Spec.Versions {
+		if version.Storage {
+			return version.Name, nil
+		}
+	}
+
+	return "", errCRDHasNoStorageVersion
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func newTestCRD(storedVersions []string, versions ...apiextensionsv1.CustomResourceDefinitionVersion) *apiextensionsv1.CustomResourceDefinition {
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	crd.Name = "awsclusters.infrastructure.cluster.x-k8s.io"
+	crd.Spec.Group = "infrastructure.cluster.x-k8s.io"
+	crd.Spec.Names.ListKind = "AWSClusterList"
+	crd.Spec.Versions = versions
+	crd.Status.StoredVersions = storedVersions
+
+	return crd
+}
+
+var _ = Describe("withStoredVersions", func() {
+	v1beta1 := apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1beta1", Served: true, Storage: true}
+	v1beta2 := apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1beta2", Served: true, Storage: true}
+
+	It("should keep a dropped version objects may still be stored in, as neither served nor stored", func() {
+		required := newTestCRD(nil, v1beta2)
+
+		Expect(withStoredVersions(required, newTestCRD([]string{"v1beta1"}, v1beta1))).To(BeTrue())
+		Expect(required.Spec.Versions).To(Equal([]apiextensionsv1.CustomResourceDefinitionVersion{
+			v1beta2,
+			{Name: "v1beta1", Served: false, Storage: false},
+		}))
+	})
+
+	It("should not change the required versions once the dropped version is no longer stored", func() {
+		required := newTestCRD(nil, v1beta2)
+
+		Expect(withStoredVersions(required, newTestCRD([]string{"v1beta2"}, v1beta1, v1beta2))).To(BeFalse())
+		Expect(required.Spec.Versions).To(Equal([]apiextensionsv1.CustomResourceDefinitionVersion{v1beta2}))
+	})
+})
+
+var _ = Describe("newObjectList", func() {
+	It("should list the objects in the storage version when it is served", func() {
+		list, err := newObjectList(newTestCRD(nil,
+			apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1beta1", Served: true},
+			apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1beta2", Served: true, Storage: true},
+		))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(list.GroupVersionKind()).To(Equal(schema.GroupVersionKind{Group: "infrastructure.cluster.x-k8s.io", Version: "v1beta2", Kind: "AWSClusterList"}))
+	})
+
+	It("should list the objects in a served version when the storage version is not served", func() {
+		list, err := newObjectList(newTestCRD(nil,
+			apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1beta1", Storage: true},
+			apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1beta2", Served: true},
+		))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(list.GroupVersionKind().Version).To(Equal("v1beta2"))
+	})
+
+	It("should fail without a served version", func() {
+		_, err := newObjectList(newTestCRD(nil, apiextensionsv1.CustomResourceDefinitionVersion{Name: "v1beta1", Storage: true}))
+		Expect(err).To(MatchError(errCRDHasNoServedVersion))
+	})
+})
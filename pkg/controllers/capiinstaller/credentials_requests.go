@@ -0,0 +1,191 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"slices"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+// credentialsRequestTemplates are the CredentialsRequests of the infrastructure providers, keyed by file name.
+// Each one requests the cloud permissions its provider needs, in a secret of the namespace the provider is installed in.
+// They request the same permissions as the CredentialsRequests of the release payload.
+//
+//go:embed credentialsrequests/*.yaml
+var credentialsRequestTemplates embed.FS
+
+// credentialsRequestTemplate returns the file name of the CredentialsRequest template of the infrastructure provider,
+// or an empty string when the provider does not request credentials from the cloud-credential-operator.
+// The ibmcloud provider manages either IBM Cloud VPC or Power VS machines, depending on the platform.
+func credentialsRequestTemplate(platform configv1.PlatformType, providerName string) string {
+	name := providerName
+	if platform == configv1.PowerVSPlatformType && providerName == platformToProviderConfigMapLabelNameValue(platform) {
+		name = "powervs"
+	}
+
+	fileName := fmt.Sprintf("credentialsrequests/%s.yaml", name)
+	if _, err := credentialsRequestTemplates.Open(fileName); err != nil {
+		return ""
+	}
+
+	return fileName
+}
+
+// renderCredentialsRequest renders the CredentialsRequest of the infrastructure provider from its template,
+// or returns nil when the provider has none.
+// The credentials are only granted to the service accounts of the provider deployments,
+// so that tokens of other service accounts cannot assume them on STS clusters.
+// Providers installed outside of the CAPI namespace get a CredentialsRequest of their own, suffixed with their namespace.
+func renderCredentialsRequest(platform configv1.PlatformType, provider desiredProvider, serviceAccountNames []string) (*unstructured.Unstructured, error) {
+	fileName := credentialsRequestTemplate(platform, provider.name)
+	if fileName == "" {
+		return nil, nil
+	}
+
+	data, err := credentialsRequestTemplates.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read CredentialsRequest template %q: %w", fileName, err)
+	}
+
+	u := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal(data, &u.Object); err != nil {
+		return nil, fmt.Errorf("unable to parse CredentialsRequest template %q: %w", fileName, err)
+	}
+
+	if provider.namespace != defaultCAPINamespace {
+		u.SetName(fmt.Sprintf("%s-%s", u.GetName(), provider.namespace))
+	}
+
+	u.SetLabels(map[string]string{ownedProviderComponentName: provider.componentName})
+
+	if err := unstructured.SetNestedField(u.Object, provider.namespace, "spec", "secretRef", "namespace"); err != nil {
+		return nil, fmt.Errorf("unable to set the secret namespace of CredentialsRequest %q: %w", u.GetName(), err)
+	}
+
+	if len(serviceAccountNames) > 0 {
+		if err := unstructured.SetNestedStringSlice(u.Object, serviceAccountNames, "spec", "serviceAccountNames"); err != nil {
+			return nil, fmt.Errorf("unable to set the service accounts of CredentialsRequest %q: %w", u.GetName(), err)
+		}
+	}
+
+	return u, nil
+}
+
+// providerServiceAccountNames returns the sorted names of the service accounts the provider deployments run as.
+func providerServiceAccountNames(components []string) ([]string, error) {
+	names := []string{}
+
+	for _, component := range components {
+		u := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(component), &u.Object); err != nil {
+			return nil, fmt.Errorf("unable to parse provider component: %w", err)
+		}
+
+		if u.GetKind() != "Deployment" {
+			continue
+		}
+
+		name, _, err := unstructured.NestedString(u.Object, "spec", "template", "spec", "serviceAccountName")
+		if err != nil {
+			return nil, fmt.Errorf("incorrect service account of deployment %q: %w", getResourceName(u.GetNamespace(), u.GetName()), err)
+		}
+
+		if name != "" && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+
+	return names, nil
+}
+
+// ensureCredentialsRequest creates or updates the CredentialsRequest of an infrastructure provider installed outside of the CAPI namespace,
+// so the cloud-credential-operator provisions the provider credentials secret in its namespace before the provider is rolled out.
+// The CredentialsRequests of the providers of the CAPI namespace ship in the release payload instead, so that they can be extracted
+// with `oc adm release extract --credentials-requests` and provisioned ahead of time on manual mode and STS clusters.
+// Nothing is done when the cluster has no CredentialsRequest API, i.e. when the CloudCredential capability is disabled;
+// CredentialsRequests of providers which are no longer installed are left in place, like the provider components.
+func (r *CapiInstallerController) ensureCredentialsRequest(ctx context.Context, log logr.Logger, provider desiredProvider, components []string) error {
+	if provider.namespace == defaultCAPINamespace {
+		return nil
+	}
+
+	serviceAccountNames, err := providerServiceAccountNames(components)
+	if err != nil {
+		return err
+	}
+
+	desired, err := renderCredentialsRequest(r.Platform, provider, serviceAccountNames)
+	if err != nil || desired == nil {
+		return err
+	}
+
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(desired.GroupVersionKind())
+
+	if err := r.Get(ctx, client.ObjectKeyFromObject(desired), existing); meta.IsNoMatchError(err) {
+		log.V(2).Info("CredentialsRequest API not available, skipping provider CredentialsRequest", "name", provider.name)
+
+		return nil
+	} else if kerrors.IsNotFound(err) {
+		if err := r.Create(ctx, desired); err != nil {
+			return fmt.Errorf("unable to create CredentialsRequest %q: %w", getResourceName(desired.GetNamespace(), desired.GetName()), err)
+		}
+
+		log.Info("Created CredentialsRequest of CAPI provider", "name", provider.name, "credentialsRequest", desired.GetName())
+		r.RecordEvent(ctx, corev1.EventTypeNormal, "CredentialsRequestCreated",
+			fmt.Sprintf("Created CredentialsRequest %s for CAPI provider %q", desired.GetName(), provider.name))
+
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to get CredentialsRequest %q: %w", getResourceName(desired.GetNamespace(), desired.GetName()), err)
+	}
+
+	labels := existing.GetLabels()
+	if equality.Semantic.DeepEqual(existing.Object["spec"], desired.Object["spec"]) && labels[ownedProviderComponentName] == provider.componentName {
+		return nil
+	}
+
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	labels[ownedProviderComponentName] = provider.componentName
+	existing.SetLabels(labels)
+	existing.Object["spec"] = desired.Object["spec"]
+
+	if err := r.Update(ctx, existing); err != nil {
+		return fmt.Errorf("unable to update CredentialsRequest %q: %w", getResourceName(desired.GetNamespace(), desired.GetName()), err)
+	}
+
+	log.Info("Updated CredentialsRequest of CAPI provider", "name", provider.name, "credentialsRequest", desired.GetName())
+
+	return nil
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	"os"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+var _ = Describe("renderCredentialsRequest", func() {
+	It("should request the credentials of the platform provider for its service accounts", func() {
+		provider := desiredProviders(configv1.AWSPlatformType, operatorConfig{}, false)[1]
+
+		cr, err := renderCredentialsRequest(configv1.AWSPlatformType, provider, []string{"capa-controller-manager"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cr.GetName()).To(Equal("openshift-cluster-api-aws"))
+		Expect(cr.GetNamespace()).To(Equal("openshift-cloud-credential-operator"))
+		Expect(cr.GetLabels()).To(HaveKeyWithValue(ownedProviderComponentName, "infrastructure-aws"))
+
+		serviceAccountNames, _, err := unstructured.NestedStringSlice(cr.Object, "spec", "serviceAccountNames")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(serviceAccountNames).To(ConsistOf("capa-controller-manager"))
+
+		secretNamespace, _, err := unstructured.NestedString(cr.Object, "spec", "secretRef", "namespace")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(secretNamespace).To(Equal(defaultCAPINamespace))
+	})
+
+	It("should request the credentials of providers installed in another namespace in that namespace", func() {
+		config := operatorConfig{InfrastructureProviders: []string{"gcp"}, Providers: map[string]providerConfig{"gcp": {Namespace: "capg-system"}}}
+		provider := desiredProviders(configv1.AWSPlatformType, config, false)[2]
+
+		cr, err := renderCredentialsRequest(configv1.AWSPlatformType, provider, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cr.GetName()).To(Equal("openshift-cluster-api-gcp-capg-system"))
+
+		secretNamespace, _, err := unstructured.NestedString(cr.Object, "spec", "secretRef", "namespace")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(secretNamespace).To(Equal("capg-system"))
+	})
+
+	It("should request Power VS credentials for the ibmcloud provider on Power VS", func() {
+		provider := desiredProviders(configv1.PowerVSPlatformType, operatorConfig{}, false)[1]
+
+		cr, err := renderCredentialsRequest(configv1.PowerVSPlatformType, provider, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cr.GetName()).To(Equal("openshift-cluster-api-powervs"))
+	})
+
+	It("should not request credentials for providers without a CredentialsRequest", func() {
+		provider := desiredProviders(configv1.BareMetalPlatformType, operatorConfig{}, false)[1]
+
+		cr, err := renderCredentialsRequest(configv1.BareMetalPlatformType, provider, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(cr).To(BeNil())
+	})
+})
+
+var _ = Describe("providerServiceAccountNames", func() {
+	It("should return the service accounts of the provider deployments", func() {
+		components := []string{
+			"apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: unused\n",
+			"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: b\nspec:\n  template:\n    spec:\n      serviceAccountName: manager-b\n",
+			"apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: a\nspec:\n  template:\n    spec:\n      serviceAccountName: manager-a\n",
+		}
+
+		Expect(providerServiceAccountNames(components)).To(Equal([]string{"manager-a", "manager-b"}))
+	})
+})
+
+var _ = Describe("credentialsRequestTemplates", func() {
+	It("should request the same credentials as the CredentialsRequests of the release payload", func() {
+		data, err := os.ReadFile("../../../manifests/0000_30_cluster-api_01_credentials-request.yaml")
+		Expect(err).ToNot(HaveOccurred())
+
+		releaseCredentialsRequests := map[string]*unstructured.Unstructured{}
+
+		for _, doc := range strings.Split(string(data), "\n---\n") {
+			u := &unstructured.Unstructured{}
+			Expect(yaml.Unmarshal([]byte(doc), &u.Object)).To(Succeed())
+
+			Expect(u.GetAnnotations()).To(HaveKeyWithValue("release.openshift.io/feature-set", "TechPreviewNoUpgrade"), u.GetName())
+			releaseCredentialsRequests[u.GetName()] = u
+		}
+
+		templates, err := credentialsRequestTemplates.ReadDir("credentialsrequests")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(templates).ToNot(BeEmpty())
+
+		for _, template := range templates {
+			data, err := credentialsRequestTemplates.ReadFile("credentialsrequests/" + template.Name())
+			Expect(err).ToNot(HaveOccurred())
+
+			u := &unstructured.Unstructured{}
+			Expect(yaml.Unmarshal(data, &u.Object)).To(Succeed())
+
+			Expect(releaseCredentialsRequests).To(HaveKey(u.GetName()), template.Name())
+			release := releaseCredentialsRequests[u.GetName()]
+
+			Expect(u.Object["spec"]).To(HaveKeyWithValue("providerSpec", release.Object["spec"].(map[string]interface{})["providerSpec"]), template.Name())
+			Expect(u.Object["spec"]).To(HaveKeyWithValue("secretRef", release.Object["spec"].(map[string]interface{})["secretRef"]), template.Name())
+		}
+	})
+})
@@ -0,0 +1,125 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+// credentialsHashAnnotation is set on the pod template of the platform infrastructure provider deployments
+// to the hash of the platform credentials secrets, so the provider is rolled out when its credentials are rotated
+// instead of using stale credentials until its next restart.
+const credentialsHashAnnotation = "cluster-capi-operator.openshift.io/credentials-hash"
+
+// platformCredentialsSecrets are the credentials secrets of the platform infrastructure providers, in the CAPI namespace:
+// the secrets requested from the cloud-credential-operator, which rotates them,
+// and the secrets the InfraClusterController derives from them.
+//
+//nolint:gochecknoglobals
+var platformCredentialsSecrets = map[configv1.PlatformType][]string{
+	configv1.AWSPlatformType:       {"capa-manager-bootstrap-credentials"},
+	configv1.AzurePlatformType:     {"capz-manager-bootstrap-credentials", "capz-manager-cluster-credential"},
+	configv1.GCPPlatformType:       {"capg-manager-bootstrap-credentials"},
+	configv1.PowerVSPlatformType:   {"capi-ibmcloud-manager-bootstrap-credentials"},
+	configv1.IBMCloudPlatformType:  {"capi-ibmcloud-manager-bootstrap-credentials"},
+	configv1.VSpherePlatformType:   {"capv-manager-bootstrap-credentials"},
+	configv1.OpenStackPlatformType: {"openstack-cloud-credentials"},
+}
+
+// getCredentialsSecrets returns the credentials secrets of the platform infrastructure provider.
+// Secrets which do not exist are skipped, e.g. while the cloud-credential-operator has not provisioned them yet.
+func (r *CapiInstallerController) getCredentialsSecrets(ctx context.Context) ([]corev1.Secret, error) {
+	secrets := []corev1.Secret{}
+
+	for _, name := range platformCredentialsSecrets[r.Platform] {
+		secret := corev1.Secret{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: defaultCAPINamespace, Name: name}, &secret); kerrors.IsNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to get credentials secret %s/%s: %w", defaultCAPINamespace, name, err)
+		}
+
+		secrets = append(secrets, secret)
+	}
+
+	return secrets, nil
+}
+
+// credentialsHash returns the hash of the data of the credentials secrets, or an empty string without secrets.
+// Only the data is hashed, so that changes of the metadata of the secrets do not roll the provider out.
+func credentialsHash(secrets []corev1.Secret) string {
+	if len(secrets) == 0 {
+		return ""
+	}
+
+	secrets = slices.Clone(secrets)
+	slices.SortFunc(secrets, func(a, b corev1.Secret) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	h := sha256.New()
+
+	for _, secret := range secrets {
+		keys := make([]string, 0, len(secret.Data))
+		for key := range secret.Data {
+			keys = append(keys, key)
+		}
+
+		slices.Sort(keys)
+
+		fmt.Fprintf(h, "%s\x00", secret.Name)
+
+		for _, key := range keys {
+			fmt.Fprintf(h, "%s\x00%d\x00", key, len(secret.Data[key]))
+			h.Write(secret.Data[key])
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// setCredentialsHash sets the credentials hash on the pod template of the deployment.
+func setCredentialsHash(deployment *appsv1.Deployment, hash string) {
+	if hash == "" {
+		return
+	}
+
+	if deployment.Spec.Template.Annotations == nil {
+		deployment.Spec.Template.Annotations = map[string]string{}
+	}
+
+	deployment.Spec.Template.Annotations[credentialsHashAnnotation] = hash
+}
+
+// credentialsSecretPredicate defines a predicate function for the credentials secrets of the platform infrastructure provider.
+func credentialsSecretPredicate(platform configv1.PlatformType) predicate.Funcs {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetNamespace() == defaultCAPINamespace && slices.Contains(platformCredentialsSecrets[platform], obj.GetName())
+	})
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("credentialsHash", func() {
+	newSecret := func(name, value string) corev1.Secret {
+		return corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: defaultCAPINamespace},
+			Data:       map[string][]byte{"azure_client_secret": []byte(value), "azure_client_id": []byte("id")},
+		}
+	}
+
+	It("should not depend on the order of the secrets", func() {
+		bootstrap, cluster := newSecret("capz-manager-bootstrap-credentials", "s"), newSecret("capz-manager-cluster-credential", "s")
+		Expect(credentialsHash([]corev1.Secret{bootstrap, cluster})).To(Equal(credentialsHash([]corev1.Secret{cluster, bootstrap})))
+	})
+
+	It("should change when the credentials are rotated", func() {
+		Expect(credentialsHash([]corev1.Secret{newSecret("capz-manager-bootstrap-credentials", "old")})).
+			ToNot(Equal(credentialsHash([]corev1.Secret{newSecret("capz-manager-bootstrap-credentials", "new")})))
+	})
+
+	It("should not change with the metadata of the secrets", func() {
+		secret := newSecret("capz-manager-bootstrap-credentials", "s")
+		updated := *secret.DeepCopy()
+		updated.Labels = map[string]string{"rotated": "true"}
+
+		Expect(credentialsHash([]corev1.Secret{secret})).To(Equal(credentialsHash([]corev1.Secret{updated})))
+	})
+
+	It("should not annotate the deployment without credentials", func() {
+		deployment := &appsv1.Deployment{}
+		setCredentialsHash(deployment, credentialsHash(nil))
+		Expect(deployment.Spec.Template.Annotations).ToNot(HaveKey(credentialsHashAnnotation))
+
+		setCredentialsHash(deployment, credentialsHash([]corev1.Secret{newSecret("capa-manager-bootstrap-credentials", "s")}))
+		Expect(deployment.Spec.Template.Annotations).To(HaveKey(credentialsHashAnnotation))
+	})
+})
@@ -0,0 +1,100 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	appsv1 "k8s.io/api/apps/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ReasonInvalidProviderComponent is the reason for the condition when the API server refuses a component of a CAPI provider.
+	ReasonInvalidProviderComponent = "InvalidProviderComponent"
+
+	// dryRunFieldOwner is the field manager of the dry-run requests.
+	dryRunFieldOwner = "cluster-capi-operator"
+)
+
+// invalidComponentError is returned when the API server refuses a component of a CAPI provider,
+// i.e. the component fails the schema validation or is denied by an admission plugin.
+type invalidComponentError struct {
+	// component is the name of the component, as returned by getProviderComponents.
+	component string
+	err       error
+}
+
+func (e *invalidComponentError) Error() string {
+	return fmt.Sprintf("provider component %q is invalid: %v", e.component, e.err)
+}
+
+func (e *invalidComponentError) Unwrap() error {
+	return e.err
+}
+
+// dryRunComponents applies the provider components in server-side dry-run mode, so that components the API server
+// refuses are reported before any of the provider components is applied, rather than leaving the provider half applied.
+func (r *CapiInstallerController) dryRunComponents(ctx context.Context, componentsFilenames []string, componentsAssets map[string]string, deployments map[string]*appsv1.Deployment) error {
+	var errs error
+
+	for _, name := range componentsFilenames {
+		u, err := yamlToUnstructured(r.Scheme, componentsAssets[name])
+		if err != nil {
+			return fmt.Errorf("error parsing provider component %q to unstructured: %w", name, err)
+		}
+
+		errs = errors.Join(errs, r.dryRunComponent(ctx, name, u))
+	}
+
+	deploymentsFilenames := make([]string, 0, len(deployments))
+	for name := range deployments {
+		deploymentsFilenames = append(deploymentsFilenames, name)
+	}
+
+	slices.Sort(deploymentsFilenames)
+
+	for _, name := range deploymentsFilenames {
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(deployments[name])
+		if err != nil {
+			return fmt.Errorf("error converting provider component %q to unstructured: %w", name, err)
+		}
+
+		errs = errors.Join(errs, r.dryRunComponent(ctx, name, &unstructured.Unstructured{Object: obj}))
+	}
+
+	return errs
+}
+
+// dryRunComponent applies the provider component in server-side dry-run mode.
+func (r *CapiInstallerController) dryRunComponent(ctx context.Context, name string, u *unstructured.Unstructured) error {
+	err := r.Patch(ctx, u, client.Apply, client.DryRunAll, client.ForceOwnership, client.FieldOwner(dryRunFieldOwner))
+	if err == nil {
+		return nil
+	}
+
+	if kerrors.IsInvalid(err) || kerrors.IsBadRequest(err) || kerrors.IsForbidden(err) {
+		return &invalidComponentError{component: name, err: err}
+	}
+
+	return fmt.Errorf("error validating provider component %q: %w", name, err)
+}
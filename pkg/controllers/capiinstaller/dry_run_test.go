@@ -0,0 +1,109 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	"github.com/openshift/cluster-capi-operator/pkg/operatorstatus"
+)
+
+var _ = Describe("dryRunComponents", func() {
+	const serviceManifest = `
+apiVersion: v1
+kind: Service
+metadata:
+  name: webhook-service
+  namespace: openshift-cluster-api
+`
+
+	var scheme *runtime.Scheme
+
+	BeforeEach(func() {
+		scheme = runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+	})
+
+	newController := func(patchErr error) *CapiInstallerController {
+		cl := fake.NewClientBuilder().WithScheme(scheme).WithInterceptorFuncs(interceptor.Funcs{
+			Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+				Expect(patch).To(Equal(client.Apply))
+				Expect(opts).To(ContainElement(client.DryRunAll))
+
+				return patchErr
+			},
+		}).Build()
+
+		return &CapiInstallerController{
+			ClusterOperatorStatusClient: operatorstatus.ClusterOperatorStatusClient{Client: cl},
+			Scheme:                      scheme,
+		}
+	}
+
+	dryRun := func(r *CapiInstallerController) error {
+		componentsFilenames, componentsAssets, _, _, err := getProviderComponents(scheme, []string{serviceManifest})
+		Expect(err).ToNot(HaveOccurred())
+
+		deployment, err := yamlToRuntimeObject(scheme, testManifest)
+		Expect(err).ToNot(HaveOccurred())
+
+		return r.dryRunComponents(context.Background(), componentsFilenames, componentsAssets, map[string]*appsv1.Deployment{
+			"apps/v1/Deployment - nginx-deployment": deployment.(*appsv1.Deployment),
+		})
+	}
+
+	It("should accept valid components", func() {
+		Expect(dryRun(newController(nil))).To(Succeed())
+	})
+
+	It("should name the components the API server refuses", func() {
+		err := dryRun(newController(kerrors.NewInvalid(schema.GroupKind{Kind: "Service"}, "webhook-service", nil)))
+
+		var invalidComponent *invalidComponentError
+		Expect(errors.As(err, &invalidComponent)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring(`provider component "/v1/Service - openshift-cluster-api/webhook-service" is invalid`)))
+		Expect(err).To(MatchError(ContainSubstring(`provider component "apps/v1/Deployment - nginx-deployment" is invalid`)))
+	})
+
+	It("should name the components denied by an admission plugin", func() {
+		err := dryRun(newController(kerrors.NewForbidden(schema.GroupResource{Resource: "services"}, "webhook-service", errors.New("denied by policy"))))
+
+		var invalidComponent *invalidComponentError
+		Expect(errors.As(err, &invalidComponent)).To(BeTrue())
+		Expect(err).To(MatchError(ContainSubstring("denied by policy")))
+	})
+
+	It("should not report the components invalid when the API server is unavailable", func() {
+		err := dryRun(newController(kerrors.NewServiceUnavailable("etcd is unavailable")))
+		Expect(err).To(HaveOccurred())
+
+		var invalidComponent *invalidComponentError
+		Expect(errors.As(err, &invalidComponent)).To(BeFalse())
+	})
+})
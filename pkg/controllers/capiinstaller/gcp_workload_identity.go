@@ -0,0 +1,119 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// gcpCredentialsSecretName is the credentials secret of the GCP provider, provisioned from its CredentialsRequest.
+	gcpCredentialsSecretName = "capg-manager-bootstrap-credentials"
+	// gcpCredentialsKey is the key of the secret holding the GCP credentials configuration.
+	gcpCredentialsKey = "service_account.json"
+	// gcpExternalAccountType is the type of the credentials configurations of Workload Identity Federation,
+	// written instead of service account keys in manual credentials mode with short-lived credentials.
+	gcpExternalAccountType = "external_account"
+
+	// gcpApplicationCredentialsEnv is the credentials configuration file the Google client libraries read.
+	gcpApplicationCredentialsEnv = "GOOGLE_APPLICATION_CREDENTIALS"
+	// gcpCredentialsVolumeName and gcpCredentialsMountPath locate the credentials secret in the manager container,
+	// when the provider deployment does not set the credentials configuration file itself.
+	gcpCredentialsVolumeName = "gcp-credentials"
+	gcpCredentialsMountPath  = "/var/run/secrets/gcp-credentials"
+)
+
+// getGCPExternalAccountTokenFile returns the subject token file of the GCP credentials secret in the namespace,
+// or an empty string when the secret holds a service account key or does not exist yet.
+// The API is read directly, as provider namespaces besides the CAPI namespace are not cached.
+func (r *CapiInstallerController) getGCPExternalAccountTokenFile(ctx context.Context, namespace string) (string, error) {
+	secret, err := r.ApplyClient.CoreV1().Secrets(namespace).Get(ctx, gcpCredentialsSecretName, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("unable to get GCP credentials secret %s/%s: %w", namespace, gcpCredentialsSecretName, err)
+	}
+
+	return parseGCPExternalAccountTokenFile(secret.Data[gcpCredentialsKey]), nil
+}
+
+// parseGCPExternalAccountTokenFile returns the file of the subject token the external account credentials
+// configuration exchanges for GCP credentials, or an empty string for other credentials, e.g. service account keys.
+func parseGCPExternalAccountTokenFile(credentials []byte) string {
+	config := struct {
+		Type             string `json:"type"`
+		CredentialSource struct {
+			File string `json:"file"`
+		} `json:"credential_source"`
+	}{}
+
+	if err := json.Unmarshal(credentials, &config); err != nil || config.Type != gcpExternalAccountType {
+		return ""
+	}
+
+	return config.CredentialSource.File
+}
+
+// setGCPWorkloadIdentity configures the manager container of the GCP provider deployment to use the external account
+// credentials configuration, with a service account token projected at its subject token file.
+// Nothing is done without token file, the provider then authenticates with the service account key.
+func setGCPWorkloadIdentity(deployment *appsv1.Deployment, tokenFile string) {
+	if tokenFile == "" {
+		return
+	}
+
+	projectServiceAccountToken(deployment, tokenFile)
+
+	podSpec := &deployment.Spec.Template.Spec
+
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		if container.Name != providerManagerContainerName {
+			continue
+		}
+
+		// The credentials configuration is read by the Google client libraries whatever its type,
+		// it only needs to be mounted when the provider deployment does not mount it already.
+		if slices.ContainsFunc(container.Env, func(e corev1.EnvVar) bool { return e.Name == gcpApplicationCredentialsEnv }) {
+			continue
+		}
+
+		if !slices.ContainsFunc(podSpec.Volumes, func(v corev1.Volume) bool { return v.Name == gcpCredentialsVolumeName }) {
+			podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+				Name: gcpCredentialsVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					Secret: &corev1.SecretVolumeSource{SecretName: gcpCredentialsSecretName},
+				},
+			})
+		}
+
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      gcpCredentialsVolumeName,
+			MountPath: gcpCredentialsMountPath,
+			ReadOnly:  true,
+		})
+		container.Env = append(container.Env, corev1.EnvVar{Name: gcpApplicationCredentialsEnv, Value: path.Join(gcpCredentialsMountPath, gcpCredentialsKey)})
+	}
+}
@@ -0,0 +1,73 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("parseGCPExternalAccountTokenFile", func() {
+	It("should return the subject token file of an external account", func() {
+		Expect(parseGCPExternalAccountTokenFile([]byte(`{
+  "type": "external_account",
+  "audience": "//iam.googleapis.com/projects/1/locations/global/workloadIdentityPools/pool/providers/provider",
+  "subject_token_type": "urn:ietf:params:oauth:token-type:jwt",
+  "credential_source": {"file": "/var/run/secrets/openshift/serviceaccount/token", "format": {"type": "text"}}
+}`))).To(Equal("/var/run/secrets/openshift/serviceaccount/token"))
+	})
+
+	It("should not return a token file for a service account key", func() {
+		Expect(parseGCPExternalAccountTokenFile([]byte(`{"type": "service_account", "private_key": "key"}`))).To(BeEmpty())
+		Expect(parseGCPExternalAccountTokenFile([]byte(`not json`))).To(BeEmpty())
+	})
+})
+
+var _ = Describe("setGCPWorkloadIdentity", func() {
+	const tokenFile = "/var/run/secrets/openshift/serviceaccount/token"
+
+	It("should mount the credentials configuration when the deployment does not", func() {
+		deployment := &appsv1.Deployment{}
+		deployment.Spec.Template.Spec.Containers = []corev1.Container{{Name: providerManagerContainerName}}
+
+		setGCPWorkloadIdentity(deployment, tokenFile)
+
+		podSpec := deployment.Spec.Template.Spec
+		Expect(podSpec.Volumes).To(ConsistOf(HaveField("Name", boundServiceAccountTokenVolumeName), HaveField("Name", gcpCredentialsVolumeName)))
+		Expect(podSpec.Containers[0].VolumeMounts).To(ConsistOf(
+			HaveField("MountPath", "/var/run/secrets/openshift/serviceaccount"),
+			HaveField("MountPath", gcpCredentialsMountPath),
+		))
+		Expect(podSpec.Containers[0].Env).To(ConsistOf(
+			corev1.EnvVar{Name: gcpApplicationCredentialsEnv, Value: "/var/run/secrets/gcp-credentials/service_account.json"},
+		))
+	})
+
+	It("should keep the credentials configuration of the deployment", func() {
+		env := corev1.EnvVar{Name: gcpApplicationCredentialsEnv, Value: "/home/.gcp/credentials.json"}
+		deployment := &appsv1.Deployment{}
+		deployment.Spec.Template.Spec.Containers = []corev1.Container{{Name: providerManagerContainerName, Env: []corev1.EnvVar{env}}}
+
+		setGCPWorkloadIdentity(deployment, tokenFile)
+
+		podSpec := deployment.Spec.Template.Spec
+		Expect(podSpec.Volumes).To(ConsistOf(HaveField("Name", boundServiceAccountTokenVolumeName)))
+		Expect(podSpec.Containers[0].Env).To(ConsistOf(env))
+	})
+})
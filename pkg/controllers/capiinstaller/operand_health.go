@@ -0,0 +1,312 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/cluster-capi-operator/pkg/operatorstatus"
+)
+
+const (
+	// Controller conditions for the Cluster Operator resource.
+	operandHealthControllerAvailableCondition = "OperandHealthControllerAvailable"
+	operandHealthControllerDegradedCondition  = "OperandHealthControllerDegraded"
+
+	operandHealthControllerName = "OperandHealthController"
+
+	// ReasonOperandCrashLooping is the reason for the condition when a container of a provider deployment is in CrashLoopBackOff.
+	ReasonOperandCrashLooping = "OperandCrashLooping"
+
+	// ReasonOperandLostLeaderElection is the reason for the condition when a container of a provider deployment
+	// last exited because it lost the leader election.
+	ReasonOperandLostLeaderElection = "OperandLostLeaderElection"
+
+	// ReasonOperandRestartStorm is the reason for the condition when a container of a provider deployment keeps restarting.
+	ReasonOperandRestartStorm = "OperandRestartStorm"
+
+	// reasonOperandRestarted is the reason of the event recorded when a wedged operand pod is deleted.
+	reasonOperandRestarted = "OperandRestarted"
+
+	// restartStormThreshold is the number of restarts from which a container which restarted within
+	// restartStormWindow is considered to be in a restart storm.
+	restartStormThreshold = 5
+	restartStormWindow    = 10 * time.Minute
+
+	// wedgedOperandTimeout is how long a pod must have existed, while unhealthy, before it is restarted.
+	wedgedOperandTimeout = 15 * time.Minute
+
+	// maxTerminationMessageLength is the length the termination messages are truncated to in the condition message.
+	// Termination messages often fall back to the container logs, the end of which is the most relevant.
+	maxTerminationMessageLength = 256
+
+	// leaderElectionLostMessage is logged by controller-runtime when a manager exits after losing the leader election.
+	leaderElectionLostMessage = "leader election lost"
+)
+
+// OperandHealthController reconciles a ClusterOperator object.
+// It is responsible for reporting the health of the Cluster API provider deployments installed in the cluster.
+type OperandHealthController struct {
+	operatorstatus.ClusterOperatorStatusClient
+}
+
+// operandProblem describes why a container of a provider deployment is unhealthy.
+type operandProblem struct {
+	reason  string
+	message string
+	// pod is the pod of the unhealthy container.
+	pod *corev1.Pod
+}
+
+// Reconcile reconciles the health of the CAPI provider deployments.
+func (r *OperandHealthController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx).WithName(operandHealthControllerName)
+
+	problems, err := r.getOperandProblems(ctx)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("error checking CAPI provider deployments health: %w", err)
+	}
+
+	if len(problems) == 0 {
+		if err := r.setAvailableCondition(ctx, log); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to set conditions for operand health controller: %w", err)
+		}
+
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.setDegradedCondition(ctx, log, problems); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to set conditions for operand health controller: %w", err)
+	}
+
+	config, err := getOperatorConfig(ctx, r.Client)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("error getting operator config: %w", err)
+	}
+
+	if config.OperandHealth.AutoRestart {
+		if err := r.restartWedgedOperands(ctx, log, problems, time.Now()); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Restart storms and wedged operands are assessed over time, so check again once they may have changed.
+	return ctrl.Result{RequeueAfter: restartStormWindow}, nil
+}
+
+// getOperandProblems returns the problems of the containers of the provider deployments.
+func (r *OperandHealthController) getOperandProblems(ctx context.Context) ([]operandProblem, error) {
+	deployments := &appsv1.DeploymentList{}
+	if err := r.List(ctx, deployments, client.InNamespace(r.ManagedNamespace), client.HasLabels{ownedProviderComponentName}); err != nil {
+		return nil, fmt.Errorf("unable to list CAPI provider deployments: %w", err)
+	}
+
+	var problems []operandProblem
+
+	now := time.Now()
+
+	for _, deployment := range deployments.Items {
+		selector, err := metav1.LabelSelectorAsSelector(deployment.Spec.Selector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid selector of CAPI provider deployment %q: %w", deployment.Name, err)
+		}
+
+		pods := &corev1.PodList{}
+		if err := r.List(ctx, pods, client.InNamespace(deployment.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return nil, fmt.Errorf("unable to list pods of CAPI provider deployment %q: %w", deployment.Name, err)
+		}
+
+		for i := range pods.Items {
+			problems = append(problems, assessPod(&pods.Items[i], now)...)
+		}
+	}
+
+	return problems, nil
+}
+
+// assessPod returns the problems of the containers of the pod.
+func assessPod(pod *corev1.Pod, now time.Time) []operandProblem {
+	var problems []operandProblem
+
+	for _, status := range pod.Status.ContainerStatuses {
+		prefix := fmt.Sprintf("pod %s container %s", pod.Name, status.Name)
+		lastTerminated := status.LastTerminationState.Terminated
+
+		switch {
+		case lastTerminated != nil && strings.Contains(lastTerminated.Message, leaderElectionLostMessage):
+			problems = append(problems, operandProblem{
+				reason:  ReasonOperandLostLeaderElection,
+				message: fmt.Sprintf("%s lost the leader election: %s", prefix, terminationMessage(lastTerminated)),
+				pod:     pod,
+			})
+		case status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff":
+			problems = append(problems, operandProblem{
+				reason:  ReasonOperandCrashLooping,
+				message: fmt.Sprintf("%s is in CrashLoopBackOff: %s", prefix, terminationMessage(lastTerminated)),
+				pod:     pod,
+			})
+		case status.RestartCount >= restartStormThreshold && lastTerminated != nil && now.Sub(lastTerminated.FinishedAt.Time) < restartStormWindow:
+			problems = append(problems, operandProblem{
+				reason:  ReasonOperandRestartStorm,
+				message: fmt.Sprintf("%s restarted %d times: %s", prefix, status.RestartCount, terminationMessage(lastTerminated)),
+				pod:     pod,
+			})
+		}
+	}
+
+	return problems
+}
+
+// terminationMessage describes the last termination of a container.
+func terminationMessage(terminated *corev1.ContainerStateTerminated) string {
+	if terminated == nil {
+		return "no termination recorded"
+	}
+
+	message := strings.TrimSpace(terminated.Message)
+	if message == "" {
+		return fmt.Sprintf("exited with code %d (%s)", terminated.ExitCode, terminated.Reason)
+	}
+
+	if len(message) > maxTerminationMessageLength {
+		message = "..." + message[len(message)-maxTerminationMessageLength:]
+	}
+
+	return message
+}
+
+// restartWedgedOperands deletes the pods which have been unhealthy for longer than wedgedOperandTimeout,
+// so they are recreated by their deployment.
+func (r *OperandHealthController) restartWedgedOperands(ctx context.Context, log logr.Logger, problems []operandProblem, now time.Time) error {
+	restarted := map[string]bool{}
+
+	for _, problem := range problems {
+		pod := problem.pod
+		if restarted[pod.Name] || now.Sub(pod.CreationTimestamp.Time) < wedgedOperandTimeout {
+			continue
+		}
+
+		log.Info("restarting wedged CAPI provider pod", "pod", pod.Name, "reason", problem.reason)
+
+		if err := r.Delete(ctx, pod, client.Preconditions{UID: &pod.UID}); err != nil && !kerrors.IsNotFound(err) {
+			return fmt.Errorf("unable to restart wedged CAPI provider pod %q: %w", pod.Name, err)
+		}
+
+		r.Recorder.Event(pod, corev1.EventTypeWarning, reasonOperandRestarted, fmt.Sprintf("Restarted wedged operand: %s", problem.message))
+
+		restarted[pod.Name] = true
+	}
+
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *OperandHealthController) SetupWithManager(mgr ctrl.Manager) error {
+	inManagedNamespace := predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetNamespace() == r.ManagedNamespace
+	})
+
+	if err := ctrl.NewControllerManagedBy(mgr).
+		Named(operandHealthControllerName).
+		For(&configv1.ClusterOperator{}, builder.WithPredicates(clusterOperatorPredicates())).
+		Watches(
+			&appsv1.Deployment{},
+			handler.EnqueueRequestsFromMapFunc(toClusterOperator),
+			builder.WithPredicates(inManagedNamespace),
+		).
+		Watches(
+			&corev1.Pod{},
+			handler.EnqueueRequestsFromMapFunc(toClusterOperator),
+			builder.WithPredicates(inManagedNamespace),
+		).
+		Complete(r); err != nil {
+		return fmt.Errorf("failed to create controller: %w", err)
+	}
+
+	return nil
+}
+
+// setAvailableCondition sets the ClusterOperator status condition to Available.
+func (r *OperandHealthController) setAvailableCondition(ctx context.Context, log logr.Logger) error {
+	co, err := r.GetOrCreateClusterOperator(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get cluster operator: %w", err)
+	}
+
+	conds := []configv1.ClusterOperatorStatusCondition{
+		operatorstatus.NewClusterOperatorStatusCondition(operandHealthControllerAvailableCondition, configv1.ConditionTrue, operatorstatus.ReasonAsExpected,
+			"CAPI provider deployments are healthy"),
+		operatorstatus.NewClusterOperatorStatusCondition(operandHealthControllerDegradedCondition, configv1.ConditionFalse, operatorstatus.ReasonAsExpected,
+			"CAPI provider deployments are healthy"),
+	}
+
+	r.SetOperatorVersion(co)
+
+	log.V(2).Info("CAPI provider deployments are healthy")
+
+	if err := r.SyncStatus(ctx, co, conds); err != nil {
+		return fmt.Errorf("failed to sync status: %w", err)
+	}
+
+	return nil
+}
+
+// setDegradedCondition sets the ClusterOperator status condition to Degraded, describing the problems.
+// The reason is the one of the first problem.
+func (r *OperandHealthController) setDegradedCondition(ctx context.Context, log logr.Logger, problems []operandProblem) error {
+	co, err := r.GetOrCreateClusterOperator(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get cluster operator: %w", err)
+	}
+
+	messages := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		messages = append(messages, problem.message)
+	}
+
+	message := fmt.Sprintf("CAPI provider deployments are unhealthy: %s", strings.Join(messages, "; "))
+
+	conds := []configv1.ClusterOperatorStatusCondition{
+		operatorstatus.NewClusterOperatorStatusCondition(operandHealthControllerAvailableCondition, configv1.ConditionFalse, problems[0].reason, message),
+		operatorstatus.NewClusterOperatorStatusCondition(operandHealthControllerDegradedCondition, configv1.ConditionTrue, problems[0].reason, message),
+	}
+
+	r.SetOperatorVersion(co)
+
+	log.Info("CAPI provider deployments are unhealthy", "problems", messages)
+
+	if err := r.SyncStatus(ctx, co, conds); err != nil {
+		return fmt.Errorf("failed to sync status: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("assessPod", func() {
+	now := time.Now()
+
+	podWithStatus := func(status corev1.ContainerStatus) *corev1.Pod {
+		status.Name = "manager"
+
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "capa-controller-manager-abcde"},
+			Status:     corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{status}},
+		}
+	}
+
+	terminated := func(message string, finishedAt time.Time) corev1.ContainerState {
+		return corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+			ExitCode:   1,
+			Reason:     "Error",
+			Message:    message,
+			FinishedAt: metav1.NewTime(finishedAt),
+		}}
+	}
+
+	type tableInput struct {
+		status          corev1.ContainerStatus
+		expectedReason  string
+		expectedMessage string
+	}
+
+	DescribeTable("should report the problems of the containers",
+		func(in tableInput) {
+			problems := assessPod(podWithStatus(in.status), now)
+
+			if in.expectedReason == "" {
+				Expect(problems).To(BeEmpty())
+				return
+			}
+
+			Expect(problems).To(HaveLen(1))
+			Expect(problems[0].reason).To(Equal(in.expectedReason))
+			Expect(problems[0].message).To(Equal(in.expectedMessage))
+		},
+		Entry("with a running container", tableInput{
+			status: corev1.ContainerStatus{
+				State:        corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				RestartCount: 1,
+			},
+		}),
+		Entry("with a crash looping container", tableInput{
+			status: corev1.ContainerStatus{
+				State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				LastTerminationState: terminated("failed to get credentials\n", now.Add(-time.Minute)),
+				RestartCount:         2,
+			},
+			expectedReason:  ReasonOperandCrashLooping,
+			expectedMessage: "pod capa-controller-manager-abcde container manager is in CrashLoopBackOff: failed to get credentials",
+		}),
+		Entry("with a crash looping container without termination message", tableInput{
+			status: corev1.ContainerStatus{
+				State:                corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+				LastTerminationState: terminated("", now.Add(-time.Minute)),
+				RestartCount:         2,
+			},
+			expectedReason:  ReasonOperandCrashLooping,
+			expectedMessage: "pod capa-controller-manager-abcde container manager is in CrashLoopBackOff: exited with code 1 (Error)",
+		}),
+		Entry("with a container which lost the leader election", tableInput{
+			status: corev1.ContainerStatus{
+				State:                corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				LastTerminationState: terminated("problem running manager: leader election lost", now.Add(-time.Minute)),
+				RestartCount:         1,
+			},
+			expectedReason:  ReasonOperandLostLeaderElection,
+			expectedMessage: "pod capa-controller-manager-abcde container manager lost the leader election: problem running manager: leader election lost",
+		}),
+		Entry("with a container in a restart storm", tableInput{
+			status: corev1.ContainerStatus{
+				State:                corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				LastTerminationState: terminated("OOMKilled", now.Add(-time.Minute)),
+				RestartCount:         restartStormThreshold,
+			},
+			expectedReason:  ReasonOperandRestartStorm,
+			expectedMessage: "pod capa-controller-manager-abcde container manager restarted 5 times: OOMKilled",
+		}),
+		Entry("with a container which stopped restarting", tableInput{
+			status: corev1.ContainerStatus{
+				State:                corev1.ContainerState{Running: &corev1.ContainerStateRunning{}},
+				LastTerminationState: terminated("OOMKilled", now.Add(-restartStormWindow-time.Minute)),
+				RestartCount:         restartStormThreshold,
+			},
+		}),
+	)
+
+	It("should truncate long termination messages to their end", func() {
+		message := terminationMessage(&corev1.ContainerStateTerminated{
+			Message: strings.Repeat("a", maxTerminationMessageLength) + "panic: runtime error",
+		})
+		Expect(message).To(HaveLen(maxTerminationMessageLength + 3))
+		Expect(message).To(HavePrefix("..."))
+		Expect(message).To(HaveSuffix("panic: runtime error"))
+	})
+})
+
+var _ = Describe("operand health config", func() {
+	It("should not restart operands by default", func() {
+		config, err := parseOperatorConfig("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(config.OperandHealth.AutoRestart).To(BeFalse())
+	})
+
+	It("should enable restarting wedged operands", func() {
+		config, err := parseOperatorConfig(`
+operandHealth:
+  autoRestart: true
+`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(config.OperandHealth.AutoRestart).To(BeTrue())
+	})
+})
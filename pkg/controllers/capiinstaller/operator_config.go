@@ -0,0 +1,301 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/openshift/cluster-capi-operator/pkg/operatorconfig"
+)
+
+var errInvalidOperatorConfig = errors.New("invalid value")
+
+const (
+	// operatorConfigName is the ConfigMap admins can create to configure the CAPI components installed by the operator.
+	// It is not part of the release payload, so it is left alone on upgrades.
+	// It is superseded by the ClusterCAPIOperatorConfig, and only read when the ClusterCAPIOperatorConfig does not exist.
+	operatorConfigName = "cluster-capi-operator-config"
+
+	// operatorConfigKey is the key of the operator configuration in the ConfigMap.
+	operatorConfigKey = "config.yaml"
+)
+
+// operatorConfig is the configuration of the CAPI components installed by the operator.
+type operatorConfig struct {
+	// ImageOverrides replaces the images of the images file, keyed by image name (e.g. cluster-capi-controllers),
+	// e.g. to use a disconnected mirror or to test a hotfix.
+	ImageOverrides map[string]string `json:"imageOverrides,omitempty"`
+
+	// NodePlacement places the deployments of all the CAPI providers, e.g. on infra nodes.
+	NodePlacement *nodePlacement `json:"nodePlacement,omitempty"`
+
+	// LeaderElection configures the leader election of all the CAPI providers,
+	// e.g. to avoid spurious leader changes on clusters with a slow etcd.
+	LeaderElection *leaderElection `json:"leaderElection,omitempty"`
+
+	// SyncPeriod is the resync period of the controllers of all the CAPI providers.
+	SyncPeriod *metav1.Duration `json:"syncPeriod,omitempty"`
+
+	// VerticalScaling scales the resources of all the CAPI providers with the number of machines of the cluster.
+	VerticalScaling *verticalScaling `json:"verticalScaling,omitempty"`
+
+	// Providers configures the deployments of the CAPI providers, keyed by provider name (e.g. cluster-api, aws).
+	Providers map[string]providerConfig `json:"providers,omitempty"`
+
+	// InfrastructureProviders lists the CAPI infrastructure providers to install in addition to the one of the platform,
+	// by provider name (e.g. metal3), e.g. on hybrid clusters.
+	InfrastructureProviders []string `json:"infrastructureProviders,omitempty"`
+
+	// IPAMProviders lists the CAPI IPAM providers to install, by provider name (e.g. in-cluster),
+	// e.g. for vSphere and bare metal machines to be provisioned with static IP addresses.
+	IPAMProviders []string `json:"ipamProviders,omitempty"`
+
+	// BootstrapProviders and ControlPlaneProviders list the CAPI bootstrap and control plane providers to install,
+	// by provider name (e.g. kubeadm). They are only installed when the ClusterAPILifecycleProviders feature gate is enabled.
+	BootstrapProviders    []string `json:"bootstrapProviders,omitempty"`
+	ControlPlaneProviders []string `json:"controlPlaneProviders,omitempty"`
+
+	// OperandHealth configures how the health of the CAPI provider deployments is looked after.
+	OperandHealth operandHealthConfig `json:"operandHealth,omitempty"`
+}
+
+// operandHealthConfig configures how the health of the CAPI provider deployments is looked after.
+type operandHealthConfig struct {
+	// AutoRestart deletes the pods of the provider deployments which are wedged, i.e. unhealthy for longer than
+	// wedgedOperandTimeout, so they are recreated, e.g. on fresh nodes.
+	AutoRestart bool `json:"autoRestart,omitempty"`
+}
+
+// providerConfig is the configuration of the deployments of a CAPI provider.
+type providerConfig struct {
+	// Resources are merged into the compute resources of the provider manager container,
+	// e.g. to give the controllers of large clusters more memory than the shipped defaults.
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// NodePlacement places the deployments of the provider, taking precedence over the NodePlacement of all providers.
+	NodePlacement *nodePlacement `json:"nodePlacement,omitempty"`
+
+	// LeaderElection configures the leader election of the provider, taking precedence over the LeaderElection of all providers.
+	LeaderElection *leaderElection `json:"leaderElection,omitempty"`
+
+	// SyncPeriod is the resync period of the controllers of the provider, taking precedence over the SyncPeriod of all providers.
+	SyncPeriod *metav1.Duration `json:"syncPeriod,omitempty"`
+
+	// VerticalScaling scales the resources of the provider, taking precedence over the VerticalScaling of all providers.
+	// The Resources of the provider take precedence over the ones of the scaling tiers.
+	VerticalScaling *verticalScaling `json:"verticalScaling,omitempty"`
+
+	// Namespace is the namespace the provider components are installed in, instead of the CAPI namespace,
+	// e.g. to keep a secondary provider apart from the primary one.
+	// It only applies to the optional providers, i.e. all but the core and platform providers, as the other controllers
+	// of the operator expect the core and platform providers in the CAPI namespace.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// nodePlacement describes the nodes the deployments of a CAPI provider run on.
+// Each field that is set replaces the one of the deployments.
+type nodePlacement struct {
+	NodeSelector map[string]string   `json:"nodeSelector,omitempty"`
+	Tolerations  []corev1.Toleration `json:"tolerations,omitempty"`
+	Affinity     *corev1.Affinity    `json:"affinity,omitempty"`
+}
+
+// leaderElection configures the leader election of the managers of a CAPI provider.
+// Each field that is set is passed to the manager container as the matching argument.
+type leaderElection struct {
+	LeaseDuration *metav1.Duration `json:"leaseDuration,omitempty"`
+	RenewDeadline *metav1.Duration `json:"renewDeadline,omitempty"`
+	RetryPeriod   *metav1.Duration `json:"retryPeriod,omitempty"`
+}
+
+// verticalScaling scales the resources of the provider manager container with the number of machines of the cluster,
+// e.g. to avoid the controllers of large clusters being OOM killed.
+// The number of machines is observed on each reconcile of the providers, so at the latest on each resync.
+type verticalScaling struct {
+	// Tiers are the resources of the provider manager container from a number of machines.
+	// The tier with the highest MinMachines the cluster reaches applies.
+	Tiers []scalingTier `json:"tiers"`
+}
+
+// scalingTier is the resources of the provider manager container from a number of machines.
+type scalingTier struct {
+	MinMachines int                         `json:"minMachines"`
+	Resources   corev1.ResourceRequirements `json:"resources"`
+}
+
+// getOperatorConfig returns the operator configuration, read from the ClusterCAPIOperatorConfig
+// or, when it does not exist, from the ConfigMap.
+// An empty configuration is returned when neither exists.
+func getOperatorConfig(ctx context.Context, cl client.Reader) (operatorConfig, error) {
+	u, err := operatorconfig.Get(ctx, cl)
+	if err != nil {
+		return operatorConfig{}, err
+	} else if u != nil {
+		return decodeOperatorConfig(u)
+	}
+
+	cm := &corev1.ConfigMap{}
+	if err := cl.Get(ctx, client.ObjectKey{Namespace: defaultCAPINamespace, Name: operatorConfigName}, cm); kerrors.IsNotFound(err) {
+		return operatorConfig{}, nil
+	} else if err != nil {
+		return operatorConfig{}, fmt.Errorf("unable to get operator config ConfigMap %s/%s: %w", defaultCAPINamespace, operatorConfigName, err)
+	}
+
+	return parseOperatorConfig(cm.Data[operatorConfigKey])
+}
+
+// parseOperatorConfig parses the operator configuration, rejecting unknown fields so typos do not go unnoticed.
+func parseOperatorConfig(data string) (operatorConfig, error) {
+	config := operatorConfig{}
+	if err := yaml.UnmarshalStrict([]byte(data), &config); err != nil {
+		return operatorConfig{}, fmt.Errorf("unable to parse operator config %s/%s: %w", defaultCAPINamespace, operatorConfigName, err)
+	}
+
+	if err := config.validate(); err != nil {
+		return operatorConfig{}, fmt.Errorf("invalid operator config %s/%s: %w", defaultCAPINamespace, operatorConfigName, err)
+	}
+
+	return config, nil
+}
+
+// decodeOperatorConfig decodes the operator configuration from the spec of the ClusterCAPIOperatorConfig.
+// Unlike the ConfigMap, unknown fields are not rejected, as the spec also holds the settings of the other controllers
+// and typos are caught by the schema of the CRD.
+func decodeOperatorConfig(u *unstructured.Unstructured) (operatorConfig, error) {
+	config := operatorConfig{}
+	if err := operatorconfig.DecodeSpec(u, &config); err != nil {
+		return operatorConfig{}, err
+	}
+
+	if err := config.validate(); err != nil {
+		return operatorConfig{}, fmt.Errorf("invalid %s %s: %w", operatorconfig.GroupVersionKind.Kind, u.GetName(), err)
+	}
+
+	return config, nil
+}
+
+// validate checks the settings which would prevent the CAPI providers from running.
+func (c operatorConfig) validate() error {
+	errs := errors.Join(
+		c.LeaderElection.validate("leaderElection"),
+		validatePositiveDuration("syncPeriod", c.SyncPeriod),
+		c.VerticalScaling.validate("verticalScaling"),
+	)
+
+	for name, provider := range c.Providers {
+		errs = errors.Join(errs,
+			provider.LeaderElection.validate(fmt.Sprintf("providers.%s.leaderElection", name)),
+			validatePositiveDuration(fmt.Sprintf("providers.%s.syncPeriod", name), provider.SyncPeriod),
+			provider.VerticalScaling.validate(fmt.Sprintf("providers.%s.verticalScaling", name)),
+		)
+	}
+
+	return errs
+}
+
+// validate checks that the leader election timings are consistent, as the managers refuse to start otherwise.
+func (l *leaderElection) validate(path string) error {
+	if l == nil {
+		return nil
+	}
+
+	errs := errors.Join(
+		validatePositiveDuration(path+".leaseDuration", l.LeaseDuration),
+		validatePositiveDuration(path+".renewDeadline", l.RenewDeadline),
+		validatePositiveDuration(path+".retryPeriod", l.RetryPeriod),
+	)
+
+	if l.LeaseDuration != nil && l.RenewDeadline != nil && l.RenewDeadline.Duration >= l.LeaseDuration.Duration {
+		errs = errors.Join(errs, fmt.Errorf("%w: %s.renewDeadline must be less than %s.leaseDuration", errInvalidOperatorConfig, path, path))
+	}
+
+	if l.RenewDeadline != nil && l.RetryPeriod != nil && l.RetryPeriod.Duration >= l.RenewDeadline.Duration {
+		errs = errors.Join(errs, fmt.Errorf("%w: %s.retryPeriod must be less than %s.renewDeadline", errInvalidOperatorConfig, path, path))
+	}
+
+	return errs
+}
+
+// validate checks that the scaling tiers start from a valid number of machines.
+func (v *verticalScaling) validate(path string) error {
+	if v == nil {
+		return nil
+	}
+
+	var errs error
+
+	for i, tier := range v.Tiers {
+		if tier.MinMachines < 0 {
+			errs = errors.Join(errs, fmt.Errorf("%w: %s.tiers[%d].minMachines must not be negative", errInvalidOperatorConfig, path, i))
+		}
+	}
+
+	return errs
+}
+
+// validatePositiveDuration checks that the duration, if set, is positive.
+func validatePositiveDuration(path string, d *metav1.Duration) error {
+	if d != nil && d.Duration <= 0 {
+		return fmt.Errorf("%w: %s must be positive", errInvalidOperatorConfig, path)
+	}
+
+	return nil
+}
+
+// provider returns the configuration of the deployments of the named CAPI provider.
+func (c operatorConfig) provider(name string) providerConfig {
+	provider := c.Providers[name]
+	if provider.NodePlacement == nil {
+		provider.NodePlacement = c.NodePlacement
+	}
+
+	if provider.LeaderElection == nil {
+		provider.LeaderElection = c.LeaderElection
+	}
+
+	if provider.SyncPeriod == nil {
+		provider.SyncPeriod = c.SyncPeriod
+	}
+
+	if provider.VerticalScaling == nil {
+		provider.VerticalScaling = c.VerticalScaling
+	}
+
+	return provider
+}
+
+// images returns the given images with the image overrides applied.
+func (c operatorConfig) images(images map[string]string) map[string]string {
+	if len(c.ImageOverrides) == 0 {
+		return images
+	}
+
+	overridden := make(map[string]string, len(images)+len(c.ImageOverrides))
+	maps.Copy(overridden, images)
+	maps.Copy(overridden, c.ImageOverrides)
+
+	return overridden
+}
@@ -0,0 +1,297 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/openshift/cluster-capi-operator/pkg/operatorconfig"
+)
+
+var _ = Describe("operator config", func() {
+	images := map[string]string{
+		"cluster-capi-controllers":    "registry.ci.openshift.org/openshift:cluster-capi-controllers",
+		"aws-cluster-api-controllers": "registry.ci.openshift.org/openshift:aws-cluster-api-controllers",
+	}
+
+	It("should keep the images without a config", func() {
+		config, err := parseOperatorConfig("")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(config.images(images)).To(Equal(images))
+	})
+
+	It("should override the images", func() {
+		config, err := parseOperatorConfig(`
+imageOverrides:
+  aws-cluster-api-controllers: mirror.example.com/openshift/aws-cluster-api-controllers@sha256:1234
+`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(config.images(images)).To(Equal(map[string]string{
+			"cluster-capi-controllers":    "registry.ci.openshift.org/openshift:cluster-capi-controllers",
+			"aws-cluster-api-controllers": "mirror.example.com/openshift/aws-cluster-api-controllers@sha256:1234",
+		}))
+		Expect(images).To(HaveKeyWithValue("aws-cluster-api-controllers", "registry.ci.openshift.org/openshift:aws-cluster-api-controllers"),
+			"the images of the images file should not be modified")
+	})
+
+	It("should reject unknown fields", func() {
+		_, err := parseOperatorConfig(`
+imageOverride:
+  aws-cluster-api-controllers: mirror.example.com/openshift/aws-cluster-api-controllers@sha256:1234
+`)
+		Expect(err).To(MatchError(ContainSubstring("unknown field")))
+	})
+})
+
+var _ = Describe("customizeDeployment", func() {
+	var deployment *appsv1.Deployment
+
+	BeforeEach(func() {
+		deployment = &appsv1.Deployment{}
+		deployment.Spec.Template.Spec.Containers = []corev1.Container{
+			{
+				Name: "manager",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse("10m"),
+						corev1.ResourceMemory: resource.MustParse("50Mi"),
+					},
+				},
+			},
+			{
+				Name: "kube-rbac-proxy",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("50Mi")},
+				},
+			},
+		}
+	})
+
+	It("should merge the configured resources into the manager container", func() {
+		config, err := parseOperatorConfig(`
+providers:
+  aws:
+    resources:
+      requests:
+        memory: 500Mi
+      limits:
+        memory: 1Gi
+`)
+		Expect(err).ToNot(HaveOccurred())
+
+		customizeDeployment(deployment, config.Providers["aws"])
+
+		Expect(deployment.Spec.Template.Spec.Containers[0].Resources).To(Equal(corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("10m"),
+				corev1.ResourceMemory: resource.MustParse("500Mi"),
+			},
+			Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+		}))
+		Expect(deployment.Spec.Template.Spec.Containers[1].Resources).To(Equal(corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("50Mi")},
+		}))
+	})
+
+	It("should place the deployment on the configured nodes, favouring the provider node placement", func() {
+		config, err := parseOperatorConfig(`
+nodePlacement:
+  nodeSelector:
+    node-role.kubernetes.io/infra: ""
+  tolerations:
+  - key: node-role.kubernetes.io/infra
+    effect: NoSchedule
+providers:
+  aws:
+    nodePlacement:
+      nodeSelector:
+        node-role.kubernetes.io/control-plane: ""
+`)
+		Expect(err).ToNot(HaveOccurred())
+
+		coreDeployment := deployment.DeepCopy()
+		customizeDeployment(coreDeployment, config.provider("cluster-api"))
+		Expect(coreDeployment.Spec.Template.Spec.NodeSelector).To(Equal(map[string]string{"node-role.kubernetes.io/infra": ""}))
+		Expect(coreDeployment.Spec.Template.Spec.Tolerations).To(Equal([]corev1.Toleration{
+			{Key: "node-role.kubernetes.io/infra", Effect: corev1.TaintEffectNoSchedule},
+		}))
+
+		customizeDeployment(deployment, config.provider("aws"))
+		Expect(deployment.Spec.Template.Spec.NodeSelector).To(Equal(map[string]string{"node-role.kubernetes.io/control-plane": ""}))
+		Expect(deployment.Spec.Template.Spec.Tolerations).To(BeEmpty())
+	})
+
+	It("should pass the leader election and sync period settings to the manager container", func() {
+		deployment.Spec.Template.Spec.Containers[0].Args = []string{
+			"--leader-elect",
+			"--leader-elect-lease-duration=137s",
+			"--sync-period", "10m",
+		}
+
+		config, err := parseOperatorConfig(`
+leaderElection:
+  leaseDuration: 270s
+  renewDeadline: 240s
+syncPeriod: 20m
+providers:
+  aws:
+    leaderElection:
+      retryPeriod: 30s
+`)
+		Expect(err).ToNot(HaveOccurred())
+
+		coreDeployment := deployment.DeepCopy()
+		customizeDeployment(coreDeployment, config.provider("cluster-api"))
+		Expect(coreDeployment.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--leader-elect",
+			"--leader-elect-lease-duration=4m30s",
+			"--leader-elect-renew-deadline=4m0s",
+			"--sync-period=20m0s",
+		}))
+		Expect(coreDeployment.Spec.Template.Spec.Containers[1].Args).To(BeEmpty())
+
+		customizeDeployment(deployment, config.provider("aws"))
+		Expect(deployment.Spec.Template.Spec.Containers[0].Args).To(Equal([]string{
+			"--leader-elect",
+			"--leader-elect-lease-duration=137s",
+			"--leader-elect-retry-period=30s",
+			"--sync-period=20m0s",
+		}))
+	})
+
+	It("should reject inconsistent leader election settings", func() {
+		_, err := parseOperatorConfig(`
+leaderElection:
+  leaseDuration: 30s
+  renewDeadline: 40s
+providers:
+  aws:
+    syncPeriod: 0s
+`)
+		Expect(err).To(MatchError(ContainSubstring("leaderElection.renewDeadline must be less than leaderElection.leaseDuration")))
+		Expect(err).To(MatchError(ContainSubstring("providers.aws.syncPeriod must be positive")))
+	})
+
+	It("should leave the deployment alone without a provider config", func() {
+		expected := deployment.DeepCopy()
+
+		customizeDeployment(deployment, providerConfig{})
+
+		Expect(deployment).To(Equal(expected))
+	})
+})
+
+var _ = Describe("withVerticalScaling", func() {
+	var config operatorConfig
+
+	BeforeEach(func() {
+		var err error
+
+		config, err = parseOperatorConfig(`
+verticalScaling:
+  tiers:
+  - minMachines: 1000
+    resources:
+      requests:
+        memory: 2Gi
+  - minMachines: 500
+    resources:
+      requests:
+        cpu: 100m
+        memory: 1Gi
+providers:
+  aws:
+    resources:
+      limits:
+        memory: 4Gi
+    verticalScaling:
+      tiers:
+      - minMachines: 500
+        resources:
+          requests:
+            memory: 3Gi
+          limits:
+            memory: 3Gi
+`)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should keep the resources below the first tier", func() {
+		Expect(withVerticalScaling(config.provider("cluster-api"), 499).Resources).To(BeNil())
+	})
+
+	It("should apply the highest tier the cluster reaches", func() {
+		Expect(withVerticalScaling(config.provider("cluster-api"), 500).Resources).To(Equal(&corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    resource.MustParse("100m"),
+				corev1.ResourceMemory: resource.MustParse("1Gi"),
+			},
+		}))
+		Expect(withVerticalScaling(config.provider("cluster-api"), 1200).Resources).To(Equal(&corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")},
+		}))
+	})
+
+	It("should favour the provider tiers and resources", func() {
+		Expect(withVerticalScaling(config.provider("aws"), 1200).Resources).To(Equal(&corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("3Gi")},
+			Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+		}))
+	})
+
+	It("should reject tiers from a negative number of machines", func() {
+		_, err := parseOperatorConfig(`
+verticalScaling:
+  tiers:
+  - minMachines: -1
+`)
+		Expect(err).To(MatchError(ContainSubstring("verticalScaling.tiers[0].minMachines must not be negative")))
+	})
+})
+
+var _ = Describe("operator config from the ClusterCAPIOperatorConfig", func() {
+	newClusterCAPIOperatorConfig := func(spec map[string]interface{}) *unstructured.Unstructured {
+		u := operatorconfig.New()
+		u.SetName(operatorconfig.Name)
+		u.Object["spec"] = spec
+
+		return u
+	}
+
+	It("should ignore the settings of the other controllers", func() {
+		config, err := decodeOperatorConfig(newClusterCAPIOperatorConfig(map[string]interface{}{
+			"logLevel":                int64(4),
+			"infrastructureProviders": []interface{}{"metal3"},
+			"operandHealth":           map[string]interface{}{"autoRestart": true},
+		}))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(config.InfrastructureProviders).To(ConsistOf("metal3"))
+		Expect(config.OperandHealth.AutoRestart).To(BeTrue())
+	})
+
+	It("should reject invalid settings", func() {
+		_, err := decodeOperatorConfig(newClusterCAPIOperatorConfig(map[string]interface{}{
+			"syncPeriod": "-1m",
+		}))
+		Expect(err).To(MatchError(ContainSubstring("invalid ClusterCAPIOperatorConfig cluster")))
+	})
+})
@@ -0,0 +1,260 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+// FeatureGateClusterAPILifecycleProviders enables installing the bootstrap and control plane providers listed
+// in the operator config, laying the groundwork for managing the full cluster lifecycle through CAPI.
+// It is part of the TechPreviewNoUpgrade feature set; until the cluster knows the feature gate, it is disabled.
+const FeatureGateClusterAPILifecycleProviders configv1.FeatureGateName = "ClusterAPILifecycleProviders"
+
+const (
+	// bootstrapProviderType and controlPlaneProviderType are the types of the transport ConfigMaps
+	// of the bootstrap and control plane providers.
+	bootstrapProviderType    = "bootstrap"
+	controlPlaneProviderType = "control-plane"
+)
+
+// desiredProvider is a CAPI provider to be installed in the cluster.
+type desiredProvider struct {
+	// providerType is the type of the provider, matching the type label of its transport ConfigMaps.
+	providerType string
+	// name is the name of the provider, matching the name label of its transport ConfigMaps.
+	name string
+	// componentName is the value of the ownedProviderComponentName label of the provider components.
+	componentName string
+	// namespace is the namespace the provider components are installed in.
+	namespace string
+}
+
+// desiredProviders returns the CAPI providers to be installed for this cluster, in installation order.
+// We always want to install the core provider, which in our case is the default cluster-api core provider.
+// We also want to install the infrastructure provider that matches the currently detected platform the cluster is running on,
+// followed by the additional infrastructure providers and the IPAM providers enabled in the operator config.
+// The bootstrap and control plane providers enabled in the operator config come last,
+// as long as lifecycle providers are enabled by the FeatureGateClusterAPILifecycleProviders feature gate.
+func desiredProviders(platform configv1.PlatformType, config operatorConfig, lifecycleProvidersEnabled bool) []desiredProvider {
+	providers := []desiredProvider{
+		{
+			providerType:  "core",
+			name:          defaultCoreProviderComponentName,
+			componentName: defaultCoreProviderComponentName,
+			namespace:     defaultCAPINamespace,
+		},
+		{
+			providerType:  "infrastructure",
+			name:          platformToProviderConfigMapLabelNameValue(platform),
+			componentName: platformToInfraProviderComponentName(platform),
+			namespace:     defaultCAPINamespace,
+		},
+	}
+
+	providers = appendOptionalProviders(providers, config, "infrastructure", "infrastructure-", config.InfrastructureProviders)
+	providers = appendOptionalProviders(providers, config, "ipam", "ipam-", config.IPAMProviders)
+
+	if lifecycleProvidersEnabled {
+		providers = appendOptionalProviders(providers, config, bootstrapProviderType, "bootstrap-", config.BootstrapProviders)
+		providers = appendOptionalProviders(providers, config, controlPlaneProviderType, "control-plane-", config.ControlPlaneProviders)
+	}
+
+	return providers
+}
+
+// appendOptionalProviders appends the named providers of the given type enabled in the operator config,
+// unless they are already installed.
+func appendOptionalProviders(providers []desiredProvider, config operatorConfig, providerType, componentNamePrefix string, names []string) []desiredProvider {
+	for _, name := range names {
+		name = strings.ToLower(name)
+		if slices.ContainsFunc(providers, func(p desiredProvider) bool { return p.providerType == providerType && p.name == name }) {
+			continue
+		}
+
+		namespace := config.Providers[name].Namespace
+		if namespace == "" {
+			namespace = defaultCAPINamespace
+		}
+
+		providers = append(providers, desiredProvider{
+			providerType:  providerType,
+			name:          name,
+			componentName: componentNamePrefix + name,
+			namespace:     namespace,
+		})
+	}
+
+	return providers
+}
+
+// providerVersionEvent is an Event recording the install or upgrade of a provider.
+type providerVersionEvent struct {
+	reason  string
+	message string
+}
+
+// providerVersionEvents returns the Events recording the providers installed or upgraded since the previous versions
+// were reported on the ClusterOperator.
+func providerVersionEvents(previous, current []configv1.OperandVersion) []providerVersionEvent {
+	events := []providerVersionEvent{}
+
+	for _, version := range current {
+		i := slices.IndexFunc(previous, func(v configv1.OperandVersion) bool { return v.Name == version.Name })
+
+		switch {
+		case i < 0:
+			events = append(events, providerVersionEvent{
+				reason:  "ProviderInstalled",
+				message: fmt.Sprintf("Installed CAPI provider %s at version %s", version.Name, version.Version),
+			})
+		case previous[i].Version != version.Version:
+			events = append(events, providerVersionEvent{
+				reason:  "ProviderUpgraded",
+				message: fmt.Sprintf("Upgraded CAPI provider %s from version %s to %s", version.Name, previous[i].Version, version.Version),
+			})
+		}
+	}
+
+	return events
+}
+
+// ensureProviderNamespace creates the namespace a provider is installed in, unless it is the CAPI namespace,
+// which is part of the release payload.
+func (r *CapiInstallerController) ensureProviderNamespace(ctx context.Context, namespace string) error {
+	if namespace == defaultCAPINamespace {
+		return nil
+	}
+
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	if err := r.Create(ctx, ns); err != nil && !kerrors.IsAlreadyExists(err) {
+		return fmt.Errorf("unable to create provider namespace %q: %w", namespace, err)
+	}
+
+	return nil
+}
+
+// setTargetNamespace moves the provider components from the CAPI namespace to the given namespace.
+// Besides the namespace of the namespaced components, the references to the CAPI namespace of the RBAC subjects
+// and of the webhook and conversion webhook services are updated.
+func setTargetNamespace(components []string, namespace string) ([]string, error) {
+	if namespace == defaultCAPINamespace {
+		return components, nil
+	}
+
+	retargeted := make([]string, 0, len(components))
+
+	for i, m := range components {
+		u := &unstructured.Unstructured{}
+		if err := yaml.Unmarshal([]byte(m), &u.Object); err != nil {
+			return nil, fmt.Errorf("error parsing provider component at position %d: %w", i, err)
+		}
+
+		if len(u.Object) == 0 {
+			retargeted = append(retargeted, m)
+			continue
+		}
+
+		if err := retargetObject(u, namespace); err != nil {
+			return nil, fmt.Errorf("error setting the namespace of provider component %q: %w", getResourceName(u.GetNamespace(), u.GetName()), err)
+		}
+
+		out, err := yaml.Marshal(u.Object)
+		if err != nil {
+			return nil, fmt.Errorf("error serializing provider component at position %d: %w", i, err)
+		}
+
+		retargeted = append(retargeted, string(out))
+	}
+
+	return retargeted, nil
+}
+
+// retargetObject replaces the references to the CAPI namespace of the object with the given namespace.
+func retargetObject(u *unstructured.Unstructured, namespace string) error {
+	if u.GetNamespace() == defaultCAPINamespace {
+		u.SetNamespace(namespace)
+	}
+
+	switch u.GetKind() {
+	case "RoleBinding", "ClusterRoleBinding":
+		return retargetSlice(u.Object, namespace, []string{"subjects"}, "namespace")
+	case "ValidatingWebhookConfiguration", "MutatingWebhookConfiguration":
+		return retargetSlice(u.Object, namespace, []string{"webhooks"}, "clientConfig", "service", "namespace")
+	case "CustomResourceDefinition":
+		return retargetField(u.Object, namespace, "spec", "conversion", "webhook", "clientConfig", "service", "namespace")
+	}
+
+	return nil
+}
+
+// retargetSlice replaces the CAPI namespace at the given field path of each item of the slice.
+func retargetSlice(obj map[string]interface{}, namespace string, slicePath []string, fieldPath ...string) error {
+	items, found, err := unstructured.NestedSlice(obj, slicePath...)
+	if err != nil {
+		return fmt.Errorf("incorrect value for %s: %w", strings.Join(slicePath, "."), err)
+	}
+
+	if !found {
+		return nil
+	}
+
+	for i := range items {
+		item, ok := items[i].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if err := retargetField(item, namespace, fieldPath...); err != nil {
+			return err
+		}
+	}
+
+	if err := unstructured.SetNestedSlice(obj, items, slicePath...); err != nil {
+		return fmt.Errorf("unable to set %s: %w", strings.Join(slicePath, "."), err)
+	}
+
+	return nil
+}
+
+// retargetField replaces the CAPI namespace at the given field path.
+func retargetField(obj map[string]interface{}, namespace string, fieldPath ...string) error {
+	current, found, err := unstructured.NestedString(obj, fieldPath...)
+	if err != nil {
+		return fmt.Errorf("incorrect value for %s: %w", strings.Join(fieldPath, "."), err)
+	}
+
+	if !found || current != defaultCAPINamespace {
+		return nil
+	}
+
+	if err := unstructured.SetNestedField(obj, namespace, fieldPath...); err != nil {
+		return fmt.Errorf("unable to set %s: %w", strings.Join(fieldPath, "."), err)
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+var _ = Describe("desiredProviders", func() {
+	It("should install the core and platform providers without a config", func() {
+		Expect(desiredProviders(configv1.AWSPlatformType, operatorConfig{}, false)).To(Equal([]desiredProvider{
+			{providerType: "core", name: "cluster-api", componentName: "cluster-api", namespace: defaultCAPINamespace},
+			{providerType: "infrastructure", name: "aws", componentName: "infrastructure-aws", namespace: defaultCAPINamespace},
+		}))
+	})
+
+	It("should install the additional infrastructure providers in their namespace", func() {
+		config, err := parseOperatorConfig(`
+infrastructureProviders:
+- aws
+- metal3
+- vsphere
+providers:
+  aws:
+    namespace: ignored
+  vsphere:
+    namespace: openshift-cluster-api-vsphere
+`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(desiredProviders(configv1.AWSPlatformType, config, false)).To(Equal([]desiredProvider{
+			{providerType: "core", name: "cluster-api", componentName: "cluster-api", namespace: defaultCAPINamespace},
+			{providerType: "infrastructure", name: "aws", componentName: "infrastructure-aws", namespace: defaultCAPINamespace},
+			{providerType: "infrastructure", name: "metal3", componentName: "infrastructure-metal3", namespace: defaultCAPINamespace},
+			{providerType: "infrastructure", name: "vsphere", componentName: "infrastructure-vsphere", namespace: "openshift-cluster-api-vsphere"},
+		}))
+	})
+
+	It("should install the IPAM providers after the infrastructure providers", func() {
+		config, err := parseOperatorConfig(`
+infrastructureProviders:
+- metal3
+ipamProviders:
+- in-cluster
+`)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(desiredProviders(configv1.VSpherePlatformType, config, false)).To(Equal([]desiredProvider{
+			{providerType: "core", name: "cluster-api", componentName: "cluster-api", namespace: defaultCAPINamespace},
+			{providerType: "infrastructure", name: "vsphere", componentName: "infrastructure-vsphere", namespace: defaultCAPINamespace},
+			{providerType: "infrastructure", name: "metal3", componentName: "infrastructure-metal3", namespace: defaultCAPINamespace},
+			{providerType: "ipam", name: "in-cluster", componentName: "ipam-in-cluster", namespace: defaultCAPINamespace},
+		}))
+		Expect(providerNameToImageKey("in-cluster")).To(Equal("cluster-api-ipam-in-cluster-controllers"))
+	})
+
+	It("should only install the bootstrap and control plane providers when lifecycle providers are enabled", func() {
+		config, err := parseOperatorConfig(`
+bootstrapProviders:
+- kubeadm
+controlPlaneProviders:
+- kubeadm
+`)
+		Expect(err).ToNot(HaveOccurred())
+
+		coreAndPlatform := []desiredProvider{
+			{providerType: "core", name: "cluster-api", componentName: "cluster-api", namespace: defaultCAPINamespace},
+			{providerType: "infrastructure", name: "aws", componentName: "infrastructure-aws", namespace: defaultCAPINamespace},
+		}
+		Expect(desiredProviders(configv1.AWSPlatformType, config, false)).To(Equal(coreAndPlatform))
+		Expect(desiredProviders(configv1.AWSPlatformType, config, true)).To(Equal(append(coreAndPlatform,
+			desiredProvider{providerType: "bootstrap", name: "kubeadm", componentName: "bootstrap-kubeadm", namespace: defaultCAPINamespace},
+			desiredProvider{providerType: "control-plane", name: "kubeadm", componentName: "control-plane-kubeadm", namespace: defaultCAPINamespace},
+		)))
+		Expect(providerToCommand("bootstrap", "kubeadm")).To(Equal("./bin/kubeadm-bootstrap-controller-manager"))
+		Expect(providerToCommand("control-plane", "kubeadm")).To(Equal("./bin/kubeadm-control-plane-controller-manager"))
+		Expect(providerToCommand("core", "cluster-api")).To(Equal("./bin/cluster-api-controller-manager"))
+	})
+})
+
+var _ = Describe("providerVersionEvents", func() {
+	It("should report installed and upgraded providers", func() {
+		previous := []configv1.OperandVersion{
+			{Name: "operator", Version: "4.17.0"},
+			{Name: "cluster-api", Version: "4.16.0"},
+			{Name: "infrastructure-aws", Version: "4.17.0"},
+		}
+		current := []configv1.OperandVersion{
+			{Name: "operator", Version: "4.17.0"},
+			{Name: "cluster-api", Version: "4.17.0"},
+			{Name: "infrastructure-aws", Version: "4.17.0"},
+			{Name: "ipam-in-cluster", Version: "4.17.0"},
+		}
+
+		Expect(providerVersionEvents(previous, current)).To(Equal([]providerVersionEvent{
+			{reason: "ProviderUpgraded", message: "Upgraded CAPI provider cluster-api from version 4.16.0 to 4.17.0"},
+			{reason: "ProviderInstalled", message: "Installed CAPI provider ipam-in-cluster at version 4.17.0"},
+		}))
+	})
+
+	It("should report nothing when the versions are unchanged", func() {
+		versions := []configv1.OperandVersion{{Name: "cluster-api", Version: "4.17.0"}}
+		Expect(providerVersionEvents(versions, versions)).To(BeEmpty())
+	})
+})
+
+var _ = Describe("setTargetNamespace", func() {
+	const namespace = "openshift-cluster-api-vsphere"
+
+	parse := func(m string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{}
+		Expect(yaml.Unmarshal([]byte(m), &u.Object)).To(Succeed())
+
+		return u
+	}
+
+	nestedString := func(obj map[string]interface{}, fields ...string) string {
+		value, found, err := unstructured.NestedString(obj, fields...)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(found).To(BeTrue())
+
+		return value
+	}
+
+	It("should keep the components in the CAPI namespace", func() {
+		components := []string{"kind: ServiceAccount\nmetadata:\n  name: manager\n  namespace: openshift-cluster-api\n"}
+		Expect(setTargetNamespace(components, defaultCAPINamespace)).To(Equal(components))
+	})
+
+	It("should move the components and their references to the namespace", func() {
+		components, err := setTargetNamespace([]string{`
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: manager
+  namespace: openshift-cluster-api
+`, `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: manager
+subjects:
+- kind: ServiceAccount
+  name: manager
+  namespace: openshift-cluster-api
+- kind: ServiceAccount
+  name: other
+  namespace: kube-system
+`, `
+apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingWebhookConfiguration
+metadata:
+  name: validating-webhook-configuration
+webhooks:
+- name: validation.vspherecluster.infrastructure.cluster.x-k8s.io
+  clientConfig:
+    service:
+      name: webhook-service
+      namespace: openshift-cluster-api
+`, `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: vsphereclusters.infrastructure.cluster.x-k8s.io
+spec:
+  conversion:
+    strategy: Webhook
+    webhook:
+      clientConfig:
+        service:
+          name: webhook-service
+          namespace: openshift-cluster-api
+`}, namespace)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(components).To(HaveLen(4))
+
+		Expect(parse(components[0]).GetNamespace()).To(Equal(namespace))
+
+		subjects, _, err := unstructured.NestedSlice(parse(components[1]).Object, "subjects")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(subjects).To(ConsistOf(
+			HaveKeyWithValue("namespace", namespace),
+			HaveKeyWithValue("namespace", "kube-system"),
+		))
+
+		webhooks, _, err := unstructured.NestedSlice(parse(components[2]).Object, "webhooks")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(webhooks).To(HaveLen(1))
+		Expect(nestedString(webhooks[0].(map[string]interface{}), "clientConfig", "service", "namespace")).To(Equal(namespace))
+
+		Expect(nestedString(parse(components[3]).Object,
+			"spec", "conversion", "webhook", "clientConfig", "service", "namespace")).To(Equal(namespace))
+	})
+})
@@ -0,0 +1,125 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+const (
+	// clusterConfigName is the name of the cluster-wide configuration objects, e.g. the Proxy and the Network.
+	clusterConfigName = "cluster"
+
+	httpProxyEnv  = "HTTP_PROXY"
+	httpsProxyEnv = "HTTPS_PROXY"
+	noProxyEnv    = "NO_PROXY"
+)
+
+// requiredNoProxy are the destinations the providers always reach directly: the in-cluster services
+// and the cloud metadata endpoints, which are link-local and cannot be reached through a proxy.
+//
+//nolint:gochecknoglobals
+var requiredNoProxy = []string{
+	"localhost",
+	"127.0.0.1",
+	".svc",
+	".cluster.local",
+	"169.254.169.254",
+	"fd00:ec2::254",
+}
+
+// getProxyEnv returns the proxy environment variables of the provider containers, from the cluster-wide Proxy,
+// or nil when the cluster has no proxy.
+// The status of the Proxy is used, as it is the validated configuration, with the complete NO_PROXY list.
+func (r *CapiInstallerController) getProxyEnv(ctx context.Context) ([]corev1.EnvVar, error) {
+	proxy := &configv1.Proxy{}
+	if err := r.Get(ctx, client.ObjectKey{Name: clusterConfigName}, proxy); kerrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to get cluster proxy: %w", err)
+	}
+
+	if proxy.Status.HTTPProxy == "" && proxy.Status.HTTPSProxy == "" {
+		return nil, nil
+	}
+
+	network := &configv1.Network{}
+	if err := r.Get(ctx, client.ObjectKey{Name: clusterConfigName}, network); err != nil && !kerrors.IsNotFound(err) {
+		return nil, fmt.Errorf("unable to get cluster network: %w", err)
+	}
+
+	return proxyEnv(proxy.Status, network.Status.ServiceNetwork), nil
+}
+
+// proxyEnv returns the proxy environment variables for the proxy status.
+// The service network and the requiredNoProxy destinations are added to the NO_PROXY list, if missing.
+func proxyEnv(status configv1.ProxyStatus, serviceNetwork []string) []corev1.EnvVar {
+	noProxy := []string{}
+
+	for _, entry := range strings.Split(status.NoProxy, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" && !slices.Contains(noProxy, entry) {
+			noProxy = append(noProxy, entry)
+		}
+	}
+
+	for _, entry := range append(slices.Clone(serviceNetwork), requiredNoProxy...) {
+		if !slices.Contains(noProxy, entry) {
+			noProxy = append(noProxy, entry)
+		}
+	}
+
+	env := []corev1.EnvVar{}
+
+	if status.HTTPProxy != "" {
+		env = append(env, corev1.EnvVar{Name: httpProxyEnv, Value: status.HTTPProxy})
+	}
+
+	if status.HTTPSProxy != "" {
+		env = append(env, corev1.EnvVar{Name: httpsProxyEnv, Value: status.HTTPSProxy})
+	}
+
+	return append(env, corev1.EnvVar{Name: noProxyEnv, Value: strings.Join(noProxy, ",")})
+}
+
+// setProxyEnv sets the proxy environment variables on all the containers of the deployment,
+// replacing the values they had, so the providers are rolled out when the proxy changes.
+func setProxyEnv(deployment *appsv1.Deployment, env []corev1.EnvVar) {
+	for i := range deployment.Spec.Template.Spec.Containers {
+		container := &deployment.Spec.Template.Spec.Containers[i]
+
+		for _, e := range env {
+			container.Env = setEnv(container.Env, e)
+		}
+	}
+}
+
+// proxyPredicate defines a predicate function for the cluster-wide Proxy.
+func proxyPredicate() predicate.Funcs {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetName() == clusterConfigName
+	})
+}
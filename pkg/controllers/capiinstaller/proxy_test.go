@@ -0,0 +1,63 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+var _ = Describe("proxyEnv", func() {
+	It("should complete the NO_PROXY list with the service network and the metadata endpoints", func() {
+		env := proxyEnv(configv1.ProxyStatus{
+			HTTPProxy: "http://proxy:3128",
+			NoProxy:   ".cluster.local, .example.com,172.30.0.0/16",
+		}, []string{"172.30.0.0/16"})
+
+		Expect(env).To(HaveLen(2))
+		Expect(env[0]).To(Equal(corev1.EnvVar{Name: httpProxyEnv, Value: "http://proxy:3128"}))
+		Expect(env[1].Name).To(Equal(noProxyEnv))
+
+		noProxy := strings.Split(env[1].Value, ",")
+		Expect(noProxy[:3]).To(Equal([]string{".cluster.local", ".example.com", "172.30.0.0/16"}))
+		Expect(noProxy).To(ContainElements("169.254.169.254", ".svc", "localhost"))
+		Expect(noProxy).To(HaveLen(3 + len(requiredNoProxy) - 1))
+	})
+})
+
+var _ = Describe("setProxyEnv", func() {
+	It("should refresh the proxy env of all the containers", func() {
+		deployment := &appsv1.Deployment{}
+		deployment.Spec.Template.Spec.Containers = []corev1.Container{
+			{Name: providerManagerContainerName, Env: []corev1.EnvVar{{Name: httpsProxyEnv, Value: "http://old:3128"}, {Name: "OTHER", Value: "x"}}},
+			{Name: "kube-rbac-proxy"},
+		}
+
+		setProxyEnv(deployment, []corev1.EnvVar{{Name: httpsProxyEnv, Value: "http://new:3128"}, {Name: noProxyEnv, Value: ".svc"}})
+
+		Expect(deployment.Spec.Template.Spec.Containers[0].Env).To(Equal([]corev1.EnvVar{
+			{Name: httpsProxyEnv, Value: "http://new:3128"}, {Name: "OTHER", Value: "x"}, {Name: noProxyEnv, Value: ".svc"},
+		}))
+		Expect(deployment.Spec.Template.Spec.Containers[1].Env).To(HaveLen(2))
+	})
+})
@@ -0,0 +1,73 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	"path"
+	"slices"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+)
+
+const (
+	// boundServiceAccountTokenVolumeName is the volume of the service account token exchanged for cloud credentials.
+	boundServiceAccountTokenVolumeName = "bound-sa-token"
+	// boundServiceAccountTokenAudience is the audience the cloud identity providers of OpenShift clusters
+	// using short-lived credentials trust, e.g. the AWS IAM OIDC provider or the Azure federated credentials.
+	boundServiceAccountTokenAudience = "openshift"
+	// boundServiceAccountTokenExpirationSeconds is the lifetime of the token, which the kubelet refreshes.
+	boundServiceAccountTokenExpirationSeconds = 3600
+)
+
+// projectServiceAccountToken projects a bound service account token at the token file into the manager container
+// of the provider deployment, for the provider to exchange it for short-lived cloud credentials.
+func projectServiceAccountToken(deployment *appsv1.Deployment, tokenFile string) {
+	podSpec := &deployment.Spec.Template.Spec
+
+	if !slices.ContainsFunc(podSpec.Volumes, func(v corev1.Volume) bool { return v.Name == boundServiceAccountTokenVolumeName }) {
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: boundServiceAccountTokenVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{
+					Sources: []corev1.VolumeProjection{{
+						ServiceAccountToken: &corev1.ServiceAccountTokenProjection{
+							Audience:          boundServiceAccountTokenAudience,
+							ExpirationSeconds: ptr.To[int64](boundServiceAccountTokenExpirationSeconds),
+							Path:              path.Base(tokenFile),
+						},
+					}},
+				},
+			},
+		})
+	}
+
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		if container.Name != providerManagerContainerName {
+			continue
+		}
+
+		if !slices.ContainsFunc(container.VolumeMounts, func(m corev1.VolumeMount) bool { return m.Name == boundServiceAccountTokenVolumeName }) {
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+				Name:      boundServiceAccountTokenVolumeName,
+				MountPath: path.Dir(tokenFile),
+				ReadOnly:  true,
+			})
+		}
+	}
+}
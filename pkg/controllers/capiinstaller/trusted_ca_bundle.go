@@ -0,0 +1,166 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"slices"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+const (
+	// trustedCABundleConfigMapName is the ConfigMap the cluster network operator injects the trusted CA bundle of
+	// the cluster into, i.e. the system CAs along with the additional trust bundle of the cluster proxy.
+	trustedCABundleConfigMapName = "capi-trusted-ca-bundle"
+	// trustedCABundleInjectLabel requests the injection of the trusted CA bundle into a ConfigMap.
+	trustedCABundleInjectLabel = "config.openshift.io/inject-trusted-cabundle"
+	// trustedCABundleKey is the key the trusted CA bundle is injected at.
+	trustedCABundleKey = "ca-bundle.crt"
+
+	// trustedCABundleVolumeName, trustedCABundleMountPath and trustedCABundleFile locate the trusted CA bundle
+	// in the provider containers. It is mounted aside from the system CAs of the image, which are still read
+	// while the bundle has not been injected yet.
+	trustedCABundleVolumeName = "trusted-ca-bundle"
+	trustedCABundleMountPath  = "/var/run/trusted-ca-bundle"
+	trustedCABundleFile       = "tls-ca-bundle.pem"
+
+	// sslCertFileEnv points the Go TLS clients of the providers to the trusted CA bundle.
+	sslCertFileEnv = "SSL_CERT_FILE"
+
+	// trustedCABundleHashAnnotation is set on the pod template of the provider deployments to the hash
+	// of the trusted CA bundle, so the providers are rolled out when it changes, as they only read it on startup.
+	trustedCABundleHashAnnotation = "cluster-capi-operator.openshift.io/trusted-ca-bundle-hash"
+)
+
+// ensureTrustedCABundle creates the trusted CA bundle ConfigMap in the namespace, if needed,
+// and returns the hash of the injected bundle, or an empty string while it has not been injected.
+// The ConfigMap data is owned by the cluster network operator, so an existing ConfigMap is only labelled.
+// The API is read directly, as provider namespaces besides the CAPI namespace are not cached.
+func (r *CapiInstallerController) ensureTrustedCABundle(ctx context.Context, namespace string) (string, error) {
+	configMaps := r.ApplyClient.CoreV1().ConfigMaps(namespace)
+
+	cm, err := configMaps.Get(ctx, trustedCABundleConfigMapName, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      trustedCABundleConfigMapName,
+				Namespace: namespace,
+				Labels:    map[string]string{trustedCABundleInjectLabel: "true"},
+			},
+		}
+
+		if _, err := configMaps.Create(ctx, cm, metav1.CreateOptions{}); err != nil && !kerrors.IsAlreadyExists(err) {
+			return "", fmt.Errorf("unable to create trusted CA bundle ConfigMap %s/%s: %w", namespace, trustedCABundleConfigMapName, err)
+		}
+
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("unable to get trusted CA bundle ConfigMap %s/%s: %w", namespace, trustedCABundleConfigMapName, err)
+	}
+
+	if cm.Labels[trustedCABundleInjectLabel] != "true" {
+		if cm.Labels == nil {
+			cm.Labels = map[string]string{}
+		}
+
+		cm.Labels[trustedCABundleInjectLabel] = "true"
+
+		if _, err := configMaps.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+			return "", fmt.Errorf("unable to label trusted CA bundle ConfigMap %s/%s: %w", namespace, trustedCABundleConfigMapName, err)
+		}
+	}
+
+	return caBundleHash(cm.Data[trustedCABundleKey]), nil
+}
+
+// trustedCABundleHash returns the hash of the trusted CA bundle, or an empty string without bundle.
+func caBundleHash(bundle string) string {
+	if bundle == "" {
+		return ""
+	}
+
+	h := sha256.Sum256([]byte(bundle))
+
+	return hex.EncodeToString(h[:])
+}
+
+// setTrustedCABundle mounts the trusted CA bundle into all the containers of the deployment and points
+// their SSL_CERT_FILE to it, unless a container sets its own. The bundle hash is set on the pod template, if any.
+func setTrustedCABundle(deployment *appsv1.Deployment, hash string) {
+	podSpec := &deployment.Spec.Template.Spec
+
+	if !slices.ContainsFunc(podSpec.Volumes, func(v corev1.Volume) bool { return v.Name == trustedCABundleVolumeName }) {
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: trustedCABundleVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: trustedCABundleConfigMapName},
+					Items:                []corev1.KeyToPath{{Key: trustedCABundleKey, Path: trustedCABundleFile}},
+					// The bundle is not injected yet when the deployment is first rolled out.
+					Optional: ptr.To(true),
+				},
+			},
+		})
+	}
+
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+
+		if !slices.ContainsFunc(container.VolumeMounts, func(m corev1.VolumeMount) bool { return m.Name == trustedCABundleVolumeName }) {
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+				Name:      trustedCABundleVolumeName,
+				MountPath: trustedCABundleMountPath,
+				ReadOnly:  true,
+			})
+		}
+
+		if !slices.ContainsFunc(container.Env, func(e corev1.EnvVar) bool { return e.Name == sslCertFileEnv }) {
+			container.Env = append(container.Env, corev1.EnvVar{
+				Name:  sslCertFileEnv,
+				Value: path.Join(trustedCABundleMountPath, trustedCABundleFile),
+			})
+		}
+	}
+
+	if hash == "" {
+		return
+	}
+
+	if deployment.Spec.Template.Annotations == nil {
+		deployment.Spec.Template.Annotations = map[string]string{}
+	}
+
+	deployment.Spec.Template.Annotations[trustedCABundleHashAnnotation] = hash
+}
+
+// trustedCABundlePredicate defines a predicate function for the trusted CA bundle ConfigMap of the CAPI namespace.
+// Changes of the bundle in other provider namespaces are picked up by the next reconcile.
+func trustedCABundlePredicate() predicate.Funcs {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetNamespace() == defaultCAPINamespace && obj.GetName() == trustedCABundleConfigMapName
+	})
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("setTrustedCABundle", func() {
+	newDeployment := func() *appsv1.Deployment {
+		deployment := &appsv1.Deployment{}
+		deployment.Spec.Template.Spec.Containers = []corev1.Container{
+			{Name: providerManagerContainerName},
+			{Name: "kube-rbac-proxy", Env: []corev1.EnvVar{{Name: sslCertFileEnv, Value: "/custom.pem"}}},
+		}
+
+		return deployment
+	}
+
+	It("should mount the trusted CA bundle into all the containers", func() {
+		deployment := newDeployment()
+		setTrustedCABundle(deployment, "")
+
+		podSpec := deployment.Spec.Template.Spec
+		Expect(podSpec.Volumes).To(HaveLen(1))
+		Expect(*podSpec.Volumes[0].ConfigMap.Optional).To(BeTrue())
+
+		for _, container := range podSpec.Containers {
+			Expect(container.VolumeMounts).To(ConsistOf(HaveField("MountPath", trustedCABundleMountPath)))
+		}
+
+		Expect(podSpec.Containers[0].Env).To(ConsistOf(corev1.EnvVar{Name: sslCertFileEnv, Value: "/var/run/trusted-ca-bundle/tls-ca-bundle.pem"}))
+		Expect(podSpec.Containers[1].Env).To(ConsistOf(corev1.EnvVar{Name: sslCertFileEnv, Value: "/custom.pem"}))
+		Expect(deployment.Spec.Template.Annotations).ToNot(HaveKey(trustedCABundleHashAnnotation))
+	})
+
+	It("should be idempotent", func() {
+		deployment := newDeployment()
+		setTrustedCABundle(deployment, caBundleHash("bundle"))
+
+		once := deployment.DeepCopy()
+		setTrustedCABundle(deployment, caBundleHash("bundle"))
+		Expect(deployment).To(Equal(once))
+	})
+
+	It("should roll the deployment out when the bundle changes", func() {
+		before, after := newDeployment(), newDeployment()
+		setTrustedCABundle(before, caBundleHash("old"))
+		setTrustedCABundle(after, caBundleHash("new"))
+
+		Expect(before.Spec.Template.Annotations[trustedCABundleHashAnnotation]).
+			ToNot(Equal(after.Spec.Template.Annotations[trustedCABundleHashAnnotation]))
+	})
+})
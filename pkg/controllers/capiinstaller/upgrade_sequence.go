@@ -0,0 +1,256 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	"github.com/openshift/library-go/pkg/operator/resource/resourceapply"
+)
+
+const (
+	// crdEstablishedTimeout is how long to wait for the provider CustomResourceDefinitions to be established
+	// before rolling out the provider deployments.
+	crdEstablishedTimeout  = 30 * time.Second
+	crdEstablishedInterval = time.Second
+
+	// providerRolloutRequeueAfter is how long to wait before checking again on a provider rollout in progress.
+	providerRolloutRequeueAfter = 15 * time.Second
+)
+
+var (
+	// errProviderRolloutInProgress is returned while the provider components are waiting on a step of the rollout
+	// to complete. It is not a failure, the rollout is resumed once the step completes.
+	errProviderRolloutInProgress = errors.New("CAPI provider rollout in progress")
+
+	errCRDNotEstablished = errors.New("CustomResourceDefinition is not established")
+)
+
+// componentSequence is the provider static components, grouped by upgrade step.
+type componentSequence struct {
+	// crds are applied first, so the provider deployments find the API they serve.
+	crds []string
+	// static are applied once the CustomResourceDefinitions are established.
+	static []string
+	// webhooks are applied once the provider deployments serving them are rolled out,
+	// so API requests are not sent to webhook servers which are not ready.
+	webhooks []string
+}
+
+// sequenceComponents groups the provider static components by upgrade step, keeping their order within each step.
+func sequenceComponents(scheme *runtime.Scheme, componentsFilenames []string, componentsAssets map[string]string) (componentSequence, error) {
+	sequence := componentSequence{}
+
+	for _, name := range componentsFilenames {
+		u, err := yamlToUnstructured(scheme, componentsAssets[name])
+		if err != nil {
+			return componentSequence{}, fmt.Errorf("error parsing provider component %q to unstructured: %w", name, err)
+		}
+
+		switch u.GroupVersionKind().Kind {
+		case "CustomResourceDefinition":
+			sequence.crds = append(sequence.crds, name)
+		case "ValidatingWebhookConfiguration", "MutatingWebhookConfiguration":
+			sequence.webhooks = append(sequence.webhooks, name)
+		default:
+			sequence.static = append(sequence.static, name)
+		}
+	}
+
+	return sequence, nil
+}
+
+// applyStaticComponents performs a Direct apply of the static components.
+func (r *CapiInstallerController) applyStaticComponents(ctx context.Context, componentsAssets map[string]string, componentsFilenames []string) error {
+	res := resourceapply.ApplyDirectly(
+		ctx,
+		resourceapply.NewKubeClientHolder(r.ApplyClient).WithAPIExtensionsClient(r.APIExtensionsClient),
+		events.NewInMemoryRecorder("cluster-capi-operator-capi-installer-apply-client"),
+		resourceapply.NewResourceCache(),
+		assetFn(componentsAssets),
+		componentsFilenames...,
+	)
+
+	var errs error
+
+	for i, r := range res {
+		if r.Error != nil {
+			errs = errors.Join(errs, fmt.Errorf("error applying CAPI provider component %q at position %d: %w", r.File, i, r.Error))
+		}
+	}
+
+	return errs
+}
+
+// waitForCRDsEstablished waits for the CustomResourceDefinitions to be established, i.e. served by the API server.
+func (r *CapiInstallerController) waitForCRDsEstablished(ctx context.Context, crdNames []string) error {
+	for _, name := range crdNames {
+		if err := wait.PollUntilContextTimeout(ctx, crdEstablishedInterval, crdEstablishedTimeout, true, func(ctx context.Context) (bool, error) {
+			crd, err := r.APIExtensionsClient.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				// The CustomResourceDefinition may not be visible yet, keep polling until the timeout.
+				return false, nil //nolint:nilerr
+			}
+
+			return isCRDEstablished(crd), nil
+		}); err != nil {
+			return fmt.Errorf("%w: %s", errCRDNotEstablished, name)
+		}
+	}
+
+	return nil
+}
+
+// isCRDEstablished returns whether the CustomResourceDefinition is established.
+func isCRDEstablished(crd *apiextensionsv1.CustomResourceDefinition) bool {
+	for _, cond := range crd.Status.Conditions {
+		if cond.Type == apiextensionsv1.Established {
+			return cond.Status == apiextensionsv1.ConditionTrue
+		}
+	}
+
+	return false
+}
+
+// desiredReplicas returns the number of replicas of the deployment, defaulted as per the Deployment API.
+func desiredReplicas(deployment *appsv1.Deployment) int32 {
+	if deployment.Spec.Replicas != nil {
+		return *deployment.Spec.Replicas
+	}
+
+	return 1
+}
+
+// isDeploymentRolledOut returns whether all the replicas of the deployment are updated and available.
+func isDeploymentRolledOut(deployment *appsv1.Deployment) bool {
+	replicas := desiredReplicas(deployment)
+	status := deployment.Status
+
+	return status.ObservedGeneration >= deployment.Generation &&
+		status.UpdatedReplicas == replicas &&
+		status.Replicas == replicas &&
+		status.AvailableReplicas == replicas
+}
+
+// deploymentRolloutProgress describes the progress of the rollout of the deployment, for the Progressing condition.
+func deploymentRolloutProgress(deployment *appsv1.Deployment) string {
+	name := getResourceName(deployment.Namespace, deployment.Name)
+	status := deployment.Status
+
+	if status.ObservedGeneration < deployment.Generation {
+		return fmt.Sprintf("deployment %s: generation %d not observed yet, observed generation is %d", name, deployment.Generation, status.ObservedGeneration)
+	}
+
+	return fmt.Sprintf("deployment %s: %d of %d replicas updated, %d available, %d in total",
+		name, status.UpdatedReplicas, desiredReplicas(deployment), status.AvailableReplicas, status.Replicas)
+}
+
+// checkConversionWebhooksReady checks that the services of the conversion webhooks of the CustomResourceDefinitions
+// have ready endpoints, so objects can be converted between versions.
+func (r *CapiInstallerController) checkConversionWebhooksReady(ctx context.Context, crdNames []string) error {
+	for _, name := range crdNames {
+		crd, err := r.APIExtensionsClient.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("error getting CustomResourceDefinition %q: %w", name, err)
+		}
+
+		service := conversionWebhookService(crd)
+		if service == nil {
+			continue
+		}
+
+		endpointSlices, err := r.ApplyClient.DiscoveryV1().EndpointSlices(service.Namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: fmt.Sprintf("%s=%s", discoveryv1.LabelServiceName, service.Name),
+		})
+		if err != nil {
+			return fmt.Errorf("error listing endpoints of conversion webhook service %s: %w", getResourceName(service.Namespace, service.Name), err)
+		}
+
+		if !hasReadyEndpoint(endpointSlices.Items) {
+			return fmt.Errorf("%w: conversion webhook service %s of CustomResourceDefinition %q has no ready endpoints",
+				errProviderRolloutInProgress, getResourceName(service.Namespace, service.Name), name)
+		}
+	}
+
+	return nil
+}
+
+// conversionWebhookService returns the service of the conversion webhook of the CustomResourceDefinition, if any.
+func conversionWebhookService(crd *apiextensionsv1.CustomResourceDefinition) *apiextensionsv1.ServiceReference {
+	conversion := crd.Spec.Conversion
+	if conversion == nil || conversion.Strategy != apiextensionsv1.WebhookConverter || conversion.Webhook == nil || conversion.Webhook.ClientConfig == nil {
+		return nil
+	}
+
+	return conversion.Webhook.ClientConfig.Service
+}
+
+// hasReadyEndpoint returns whether any of the endpoints is ready.
+// As per the EndpointSlice API, an endpoint with an unknown readiness is ready.
+func hasReadyEndpoint(endpointSlices []discoveryv1.EndpointSlice) bool {
+	for _, endpointSlice := range endpointSlices {
+		for _, endpoint := range endpointSlice.Endpoints {
+			if endpoint.Conditions.Ready == nil || *endpoint.Conditions.Ready {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// smokeCheckCRDs lists the objects of the CustomResourceDefinitions in each of their served versions, which requires
+// their conversion webhooks to work, to verify the provider serves its API once rolled out.
+func (r *CapiInstallerController) smokeCheckCRDs(ctx context.Context, crdNames []string) error {
+	var errs error
+
+	for _, name := range crdNames {
+		crd, err := r.APIExtensionsClient.ApiextensionsV1().CustomResourceDefinitions().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("error getting CustomResourceDefinition %q: %w", name, err))
+			continue
+		}
+
+		for _, version := range crd.Spec.Versions {
+			if !version.Served {
+				continue
+			}
+
+			list := &unstructured.UnstructuredList{}
+			list.SetGroupVersionKind(schema.GroupVersionKind{Group: crd.Spec.Group, Version: version.Name, Kind: crd.Spec.Names.ListKind})
+
+			if err := r.List(ctx, list, client.Limit(1)); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("error listing %s objects in version %q: %w", crd.Spec.Names.Plural, version.Name, err))
+			}
+		}
+	}
+
+	return errs
+}
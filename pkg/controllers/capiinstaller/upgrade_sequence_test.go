@@ -0,0 +1,147 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	appsv1 "k8s.io/api/apps/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+)
+
+var _ = Describe("sequenceComponents", func() {
+	It("should group the components by upgrade step", func() {
+		scheme := runtime.NewScheme()
+		Expect(clientgoscheme.AddToScheme(scheme)).To(Succeed())
+		Expect(apiextensionsv1.AddToScheme(scheme)).To(Succeed())
+
+		componentsFilenames, componentsAssets, deploymentsFilenames, _, err := getProviderComponents(scheme, []string{`
+apiVersion: admissionregistration.k8s.io/v1
+kind: ValidatingWebhookConfiguration
+metadata:
+  name: validating-webhook-configuration
+`, `
+apiVersion: v1
+kind: Service
+metadata:
+  name: webhook-service
+  namespace: openshift-cluster-api
+`, `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: awsclusters.infrastructure.cluster.x-k8s.io
+`, testManifest, `
+apiVersion: admissionregistration.k8s.io/v1
+kind: MutatingWebhookConfiguration
+metadata:
+  name: mutating-webhook-configuration
+`})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(deploymentsFilenames).To(HaveLen(1))
+
+		sequence, err := sequenceComponents(scheme, componentsFilenames, componentsAssets)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(sequence.crds).To(Equal([]string{"apiextensions.k8s.io/v1/CustomResourceDefinition - awsclusters.infrastructure.cluster.x-k8s.io"}))
+		Expect(sequence.static).To(Equal([]string{"/v1/Service - openshift-cluster-api/webhook-service"}))
+		Expect(sequence.webhooks).To(Equal([]string{
+			"admissionregistration.k8s.io/v1/ValidatingWebhookConfiguration - validating-webhook-configuration",
+			"admissionregistration.k8s.io/v1/MutatingWebhookConfiguration - mutating-webhook-configuration",
+		}))
+	})
+})
+
+var _ = Describe("isDeploymentRolledOut", func() {
+	deploymentWithStatus := func(status appsv1.DeploymentStatus) *appsv1.Deployment {
+		deployment := &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Replicas: ptr.To[int32](2)}, Status: status}
+		deployment.Generation = 2
+
+		return deployment
+	}
+
+	It("should be rolled out when all the replicas are updated and available", func() {
+		Expect(isDeploymentRolledOut(deploymentWithStatus(appsv1.DeploymentStatus{
+			ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2,
+		}))).To(BeTrue())
+	})
+
+	It("should not be rolled out before the new generation is observed", func() {
+		Expect(isDeploymentRolledOut(deploymentWithStatus(appsv1.DeploymentStatus{
+			ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2,
+		}))).To(BeFalse())
+	})
+
+	It("should not be rolled out while old replicas are running", func() {
+		Expect(isDeploymentRolledOut(deploymentWithStatus(appsv1.DeploymentStatus{
+			ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 2, AvailableReplicas: 2,
+		}))).To(BeFalse())
+	})
+
+	It("should not be rolled out while updated replicas are not available", func() {
+		Expect(isDeploymentRolledOut(deploymentWithStatus(appsv1.DeploymentStatus{
+			ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 1,
+		}))).To(BeFalse())
+	})
+})
+
+var _ = Describe("deploymentRolloutProgress", func() {
+	deploymentWithStatus := func(status appsv1.DeploymentStatus) *appsv1.Deployment {
+		deployment := &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Replicas: ptr.To[int32](2)}, Status: status}
+		deployment.Name = "capa-controller-manager"
+		deployment.Namespace = "openshift-cluster-api"
+		deployment.Generation = 2
+
+		return deployment
+	}
+
+	It("should describe a generation not observed yet", func() {
+		Expect(deploymentRolloutProgress(deploymentWithStatus(appsv1.DeploymentStatus{ObservedGeneration: 1}))).To(Equal(
+			"deployment openshift-cluster-api/capa-controller-manager: generation 2 not observed yet, observed generation is 1"))
+	})
+
+	It("should describe the replicas rolling out", func() {
+		Expect(deploymentRolloutProgress(deploymentWithStatus(appsv1.DeploymentStatus{
+			ObservedGeneration: 2, Replicas: 3, UpdatedReplicas: 1, AvailableReplicas: 2,
+		}))).To(Equal("deployment openshift-cluster-api/capa-controller-manager: 1 of 2 replicas updated, 2 available, 3 in total"))
+	})
+})
+
+var _ = Describe("hasReadyEndpoint", func() {
+	endpointSlice := func(ready *bool) discoveryv1.EndpointSlice {
+		return discoveryv1.EndpointSlice{Endpoints: []discoveryv1.Endpoint{{Conditions: discoveryv1.EndpointConditions{Ready: ready}}}}
+	}
+
+	It("should not be ready without endpoints", func() {
+		Expect(hasReadyEndpoint(nil)).To(BeFalse())
+	})
+
+	It("should not be ready without ready endpoints", func() {
+		Expect(hasReadyEndpoint([]discoveryv1.EndpointSlice{endpointSlice(ptr.To(false))})).To(BeFalse())
+	})
+
+	It("should be ready with a ready endpoint", func() {
+		Expect(hasReadyEndpoint([]discoveryv1.EndpointSlice{endpointSlice(ptr.To(false)), endpointSlice(ptr.To(true))})).To(BeTrue())
+	})
+
+	It("should be ready with an endpoint of unknown readiness", func() {
+		Expect(hasReadyEndpoint([]discoveryv1.EndpointSlice{endpointSlice(nil)})).To(BeTrue())
+	})
+})
@@ -0,0 +1,88 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capiinstaller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// hasVerticalScaling returns whether any of the CAPI providers is vertically scaled.
+func (c operatorConfig) hasVerticalScaling() bool {
+	if c.VerticalScaling != nil {
+		return true
+	}
+
+	for _, provider := range c.Providers {
+		if provider.VerticalScaling != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// countMachines returns the number of CAPI machines of the cluster.
+// Only the machines metadata is listed, so large clusters do not take up the memory of the operator.
+func (r *CapiInstallerController) countMachines(ctx context.Context) (int, error) {
+	machines := &metav1.PartialObjectMetadataList{}
+	machines.SetGroupVersionKind(clusterv1.GroupVersion.WithKind("MachineList"))
+
+	if err := r.List(ctx, machines, client.InNamespace(r.ManagedNamespace)); meta.IsNoMatchError(err) {
+		// The core provider is not installed yet, so there are no machines.
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("unable to list CAPI machines: %w", err)
+	}
+
+	return len(machines.Items), nil
+}
+
+// withVerticalScaling returns the provider configuration with the resources of the scaling tier of the number
+// of machines applied. The resources of the provider configuration take precedence over the ones of the tier.
+func withVerticalScaling(config providerConfig, machines int) providerConfig {
+	if config.VerticalScaling == nil {
+		return config
+	}
+
+	var tier *scalingTier
+
+	for i := range config.VerticalScaling.Tiers {
+		t := &config.VerticalScaling.Tiers[i]
+		if machines >= t.MinMachines && (tier == nil || t.MinMachines > tier.MinMachines) {
+			tier = t
+		}
+	}
+
+	if tier == nil {
+		return config
+	}
+
+	resources := tier.Resources.DeepCopy()
+	if config.Resources != nil {
+		resources.Requests = mergeResourceList(resources.Requests, config.Resources.Requests)
+		resources.Limits = mergeResourceList(resources.Limits, config.Resources.Limits)
+	}
+
+	config.Resources = resources
+
+	return config
+}
@@ -57,6 +57,13 @@ func configMapPredicate(namespace string, platform configv1.PlatformType) predic
 	}
 }
 
+// operatorConfigPredicate defines a predicate function for the operator config ConfigMap.
+func operatorConfigPredicate() predicate.Funcs {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetNamespace() == defaultCAPINamespace && obj.GetName() == operatorConfigName
+	})
+}
+
 // ownedPlatformLabelPredicate defines a predicate function for owned objects.
 func ownedPlatformLabelPredicate(namespace string, platform configv1.PlatformType) predicate.Funcs {
 	return predicate.Funcs{
@@ -23,7 +23,11 @@ import (
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/cluster-api/util/conditions"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/openshift/cluster-capi-operator/pkg/operatorstatus"
@@ -43,6 +47,11 @@ func (r *CoreClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	if err := ctrl.NewControllerManagedBy(mgr).
 		Named(controllerName).
 		For(r.Cluster).
+		Watches(
+			&clusterv1.Machine{},
+			handler.EnqueueRequestsFromMapFunc(machineToCluster),
+			builder.WithPredicates(machineDeletedPredicate()),
+		).
 		Complete(r); err != nil {
 		return fmt.Errorf("failed to create controller: %w", err)
 	}
@@ -60,6 +69,18 @@ func (r *CoreClusterReconciler) Reconcile(ctx context.Context, req reconcile.Req
 		return ctrl.Result{}, fmt.Errorf("failed to get core cluster: %w", err)
 	}
 
+	if cluster.Namespace == r.ManagedNamespace {
+		machines, err := util.ReconcileDeletionProtection(ctx, r.Client, cluster)
+		if err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to reconcile core cluster deletion protection: %w", err)
+		}
+
+		if len(machines) > 0 {
+			// The Machines are watched, the finalizer is removed once the last one is deleted.
+			log.Info("Core cluster is being deleted, waiting for the Machines referencing it to be deleted", "machines", machines)
+		}
+	}
+
 	if !cluster.DeletionTimestamp.IsZero() {
 		if err := r.SetStatusAvailable(ctx, ""); err != nil {
 			return ctrl.Result{}, fmt.Errorf("failed to set status available: %w", err)
@@ -93,3 +114,23 @@ func (r *CoreClusterReconciler) Reconcile(ctx context.Context, req reconcile.Req
 
 	return ctrl.Result{}, nil
 }
+
+// machineToCluster maps a Machine to the Cluster it references.
+func machineToCluster(_ context.Context, obj client.Object) []reconcile.Request {
+	machine, ok := obj.(*clusterv1.Machine)
+	if !ok || machine.Spec.ClusterName == "" {
+		return nil
+	}
+
+	return []reconcile.Request{{NamespacedName: client.ObjectKey{Namespace: machine.Namespace, Name: machine.Spec.ClusterName}}}
+}
+
+// machineDeletedPredicate filters the Machine events down to deletions, which can release the deletion protection of the Cluster.
+func machineDeletedPredicate() predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		UpdateFunc:  func(event.UpdateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return true },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+	}
+}
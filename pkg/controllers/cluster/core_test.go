@@ -19,6 +19,7 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
@@ -28,6 +29,7 @@ import (
 	"github.com/openshift/cluster-capi-operator/pkg/controllers"
 	"github.com/openshift/cluster-capi-operator/pkg/operatorstatus"
 	"github.com/openshift/cluster-capi-operator/pkg/test"
+	"github.com/openshift/cluster-capi-operator/pkg/util"
 )
 
 var _ = Describe("Reconcile Core cluster", func() {
@@ -74,4 +76,59 @@ var _ = Describe("Reconcile Core cluster", func() {
 		Expect(coreCluster.Status.Conditions[0].Type).To(Equal(clusterv1.ControlPlaneInitializedCondition))
 		Expect(coreCluster.Status.Conditions[0].Status).To(Equal(corev1.ConditionTrue))
 	})
+
+	Context("in the managed namespace", func() {
+		var machine *clusterv1.Machine
+
+		reconcileCoreCluster := func() {
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(coreCluster)})
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		BeforeEach(func() {
+			r.ManagedNamespace = controllers.DefaultManagedNamespace
+
+			machine = &clusterv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "test-machine",
+					Namespace: controllers.DefaultManagedNamespace,
+				},
+				Spec: clusterv1.MachineSpec{
+					ClusterName: coreCluster.Name,
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: "infrastructure.cluster.x-k8s.io/v1beta2",
+						Kind:       "AWSMachine",
+						Name:       "test-machine",
+					},
+				},
+			}
+		})
+
+		AfterEach(func() {
+			Expect(test.CleanupAndWait(ctx, cl, machine)).To(Succeed())
+		})
+
+		It("should add the deletion protection finalizer", func() {
+			reconcileCoreCluster()
+
+			Expect(cl.Get(ctx, client.ObjectKeyFromObject(coreCluster), coreCluster)).To(Succeed())
+			Expect(coreCluster.Finalizers).To(ContainElement(util.DeletionProtectionFinalizer))
+		})
+
+		It("should keep the deletion protection finalizer while a Machine references the cluster", func() {
+			Expect(cl.Create(ctx, machine)).To(Succeed())
+
+			reconcileCoreCluster()
+			Expect(cl.Delete(ctx, coreCluster)).To(Succeed())
+			reconcileCoreCluster()
+
+			Expect(cl.Get(ctx, client.ObjectKeyFromObject(coreCluster), coreCluster)).To(Succeed())
+			Expect(coreCluster.Finalizers).To(ContainElement(util.DeletionProtectionFinalizer))
+
+			Expect(test.CleanupAndWait(ctx, cl, machine)).To(Succeed())
+			reconcileCoreCluster()
+
+			Expect(apierrors.IsNotFound(cl.Get(ctx, client.ObjectKeyFromObject(coreCluster), coreCluster))).To(BeTrue())
+		})
+	})
 })
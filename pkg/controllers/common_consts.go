@@ -15,6 +15,8 @@ limitations under the License.
 */
 package controllers
 
+import machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+
 const (
 	// DefaultManagedNamespace is the default namespace where the operator
 	// manages CAPI resources.
@@ -24,6 +26,10 @@ const (
 	// manages MAPI resources.
 	DefaultMAPIManagedNamespace = "openshift-machine-api"
 
+	// OperatorServiceAccountName is the name of the service account of the operator deployment,
+	// in the DefaultManagedNamespace.
+	OperatorServiceAccountName = "cluster-capi-operator"
+
 	// OperatorVersionKey is the key used to store the operator version in the ClusterOperator status.
 	OperatorVersionKey = "operator"
 
@@ -32,4 +38,8 @@ const (
 
 	// InfrastructureResourceName is the name of the cluster global infrastructure resource.
 	InfrastructureResourceName = "cluster"
+
+	// SynchronizedCondition is set on Machine API resources by the sync controllers to report whether
+	// the authoritative resource has been successfully mirrored to its non-authoritative counterpart.
+	SynchronizedCondition machinev1beta1.ConditionType = "Synchronized"
 )
@@ -18,6 +18,7 @@ package infracluster
 import (
 	"context"
 	"fmt"
+	"maps"
 	"net/url"
 	"strconv"
 
@@ -27,6 +28,8 @@ import (
 	awsv1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/cluster-capi-operator/pkg/util"
 )
 
 // ensureAWSCluster ensures the AWSCluster cluster object exists.
@@ -41,6 +44,10 @@ func (r *InfraClusterController) ensureAWSCluster(ctx context.Context, log logr.
 	if err := r.Get(ctx, client.ObjectKeyFromObject(target), target); err != nil && !cerrors.IsNotFound(err) {
 		return nil, fmt.Errorf("failed to get InfraCluster: %w", err)
 	} else if err == nil {
+		if err := r.ensureAWSClusterTags(ctx, log, target); err != nil {
+			return nil, fmt.Errorf("failed to ensure AWSCluster tags: %w", err)
+		}
+
 		return target, nil
 	}
 
@@ -60,6 +67,8 @@ func (r *InfraClusterController) ensureAWSCluster(ctx context.Context, log logr.
 		return nil, fmt.Errorf("infrastructure PlatformStatus should not be nil: %w", err)
 	}
 
+	resourceTags := r.getAWSResourceTags()
+
 	target = &awsv1.AWSCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      r.Infra.Status.InfrastructureName,
@@ -71,7 +80,8 @@ func (r *InfraClusterController) ensureAWSCluster(ctx context.Context, log logr.
 			},
 		},
 		Spec: awsv1.AWSClusterSpec{
-			Region: r.Infra.Status.PlatformStatus.AWS.Region,
+			Region:         r.Infra.Status.PlatformStatus.AWS.Region,
+			AdditionalTags: awsv1.Tags(resourceTags),
 			ControlPlaneEndpoint: clusterv1.APIEndpoint{
 				Host: apiURL.Hostname(),
 				Port: int32(port),
@@ -79,11 +89,60 @@ func (r *InfraClusterController) ensureAWSCluster(ctx context.Context, log logr.
 		},
 	}
 
+	util.SetManagedTagKeys(target, resourceTags)
+
 	if err := r.Create(ctx, target); err != nil {
 		return nil, fmt.Errorf("failed to create InfraCluster: %w", err)
 	}
 
 	log.Info(fmt.Sprintf("InfraCluster '%s/%s' successfully created", defaultCAPINamespace, r.Infra.Status.InfrastructureName))
+	r.recordInfraClusterCreated(ctx)
 
 	return target, nil
 }
+
+// ensureAWSClusterTags keeps the AdditionalTags of an AWSCluster managed by this controller in sync with
+// the Infrastructure resource tags. The Infrastructure is authoritative, so its values win on conflicting keys
+// and the tags removed from it are removed from the AWSCluster, while tags added to the AWSCluster directly are preserved.
+func (r *InfraClusterController) ensureAWSClusterTags(ctx context.Context, log logr.Logger, awsCluster *awsv1.AWSCluster) error {
+	if awsCluster.GetAnnotations()[clusterv1.ManagedByAnnotation] != managedByAnnotationValueClusterCAPIOperatorInfraClusterController {
+		// Only the AWSCluster created by this controller is kept in sync.
+		return nil
+	}
+
+	resourceTags := r.getAWSResourceTags()
+	desiredTags := awsv1.Tags(util.SyncTags(resourceTags, awsCluster.Spec.AdditionalTags, util.GetManagedTagKeys(awsCluster)))
+
+	updatedAWSCluster := awsCluster.DeepCopy()
+	updatedAWSCluster.Spec.AdditionalTags = desiredTags
+	util.SetManagedTagKeys(updatedAWSCluster, resourceTags)
+
+	if maps.Equal(desiredTags, awsCluster.Spec.AdditionalTags) && maps.Equal(updatedAWSCluster.GetAnnotations(), awsCluster.GetAnnotations()) {
+		return nil
+	}
+
+	if err := r.Patch(ctx, updatedAWSCluster, client.MergeFrom(awsCluster)); err != nil {
+		return fmt.Errorf("failed to patch AWSCluster additionalTags: %w", err)
+	}
+
+	*awsCluster = *updatedAWSCluster
+
+	log.Info(fmt.Sprintf("AWSCluster '%s/%s' additionalTags successfully updated", awsCluster.Namespace, awsCluster.Name))
+
+	return nil
+}
+
+// getAWSResourceTags returns the user defined resource tags from the Infrastructure AWS platform status.
+func (r *InfraClusterController) getAWSResourceTags() map[string]string {
+	tags := map[string]string{}
+
+	if r.Infra.Status.PlatformStatus == nil || r.Infra.Status.PlatformStatus.AWS == nil {
+		return tags
+	}
+
+	for _, tag := range r.Infra.Status.PlatformStatus.AWS.ResourceTags {
+		tags[tag.Key] = tag.Value
+	}
+
+	return tags
+}
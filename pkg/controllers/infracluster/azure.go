@@ -23,6 +23,7 @@ import (
 	"strconv"
 
 	"github.com/go-logr/logr"
+	configv1 "github.com/openshift/api/config/v1"
 	mapiv1beta1 "github.com/openshift/api/machine/v1beta1"
 	corev1 "k8s.io/api/core/v1"
 	cerrors "k8s.io/apimachinery/pkg/api/errors"
@@ -39,15 +40,28 @@ var (
 	errUnableToGetAzureClientID         = errors.New("unable to get Azure Client ID")
 	errUnableToGetAzureTenantID         = errors.New("unable to get Azure Tenant ID")
 	errPlatformStatusNil                = errors.New("platform status should not be nil")
+
+	// errAzureCloudEnvironmentNotSupported is returned when CAPZ cannot manage machines in the cloud environment of the cluster.
+	// It wraps errPlatformNotSupported, so such clusters are reported as not supported yet rather than degraded.
+	errAzureCloudEnvironmentNotSupported = fmt.Errorf("%w: azure cloud environment", errPlatformNotSupported)
 )
 
 const (
 	clusterSecretName               = "capz-manager-cluster-credential"    // #nosec G101
 	capzManagerBootstrapCredentials = "capz-manager-bootstrap-credentials" // #nosec G101
+	// azureFederatedTokenFileKey is set in the bootstrap credentials instead of the client secret
+	// on clusters using Azure AD Workload Identity, i.e. manual credentials mode with short-lived credentials.
+	azureFederatedTokenFileKey = "azure_federated_token_file" // #nosec G101
 )
 
 // ensureAzureCluster ensures the AzureCluster cluster object exists.
 func (r *InfraClusterController) ensureAzureCluster(ctx context.Context, log logr.Logger) (client.Object, error) {
+	// Check the cloud environment first, so that nothing is created for clusters CAPZ cannot manage.
+	azureEnvironment, err := getAzureEnvironment(r.Infra.Status.PlatformStatus)
+	if err != nil {
+		return nil, fmt.Errorf("error determining Azure environment: %w", err)
+	}
+
 	// Get the Azure Bootstrap Credentials Secret.
 	// This is created by the Cluster Credential Operator and should always exist. We expect to always find it, if not, we error.
 	capzManagerBootstrapSecret := &corev1.Secret{}
@@ -55,8 +69,11 @@ func (r *InfraClusterController) ensureAzureCluster(ctx context.Context, log log
 		return nil, fmt.Errorf("failed to get Azure Boostrap Credentials Secret: %w", err)
 	}
 
-	if err := r.ensureClusterSecret(ctx, *capzManagerBootstrapSecret); err != nil {
-		return nil, fmt.Errorf("error obtaining Azure Cluster Secret: %w", err)
+	// With Workload Identity CAPZ exchanges a federated token for Azure credentials, there is no client secret to copy.
+	if azureClusterIdentityType(*capzManagerBootstrapSecret) != azurev1.WorkloadIdentity {
+		if err := r.ensureClusterSecret(ctx, *capzManagerBootstrapSecret); err != nil {
+			return nil, fmt.Errorf("error obtaining Azure Cluster Secret: %w", err)
+		}
 	}
 
 	if err := r.ensureClusterIdentity(ctx, *capzManagerBootstrapSecret); err != nil {
@@ -68,13 +85,32 @@ func (r *InfraClusterController) ensureAzureCluster(ctx context.Context, log log
 		Namespace: defaultCAPINamespace,
 	}}
 
-	if err := r.ensureAzureInfraCluster(ctx, target, log); err != nil {
+	if err := r.ensureAzureInfraCluster(ctx, target, azureEnvironment, log); err != nil {
 		return nil, fmt.Errorf("error ensuring Azure Infra Cluster: %w", err)
 	}
 
 	return target, nil
 }
 
+// getAzureEnvironment returns the CAPZ AzureEnvironment matching the cloud name of the cluster.
+// CAPZ only supports the named Azure clouds, it cannot be pointed at the custom ARM endpoint of an Azure Stack Hub.
+// Azure Stack Hub support, with its ARM endpoint override, is deferred until CAPZ supports custom cloud environments.
+func getAzureEnvironment(platformStatus *configv1.PlatformStatus) (string, error) {
+	if platformStatus == nil || platformStatus.Azure == nil {
+		return azurev1.DefaultAzureCloud, nil
+	}
+
+	switch cloudName := platformStatus.Azure.CloudName; cloudName {
+	case "":
+		return azurev1.DefaultAzureCloud, nil
+	case configv1.AzurePublicCloud, configv1.AzureUSGovernmentCloud, configv1.AzureChinaCloud:
+		// The CAPZ environment names match the names used by the Infrastructure.
+		return string(cloudName), nil
+	default:
+		return "", fmt.Errorf("%w: %s", errAzureCloudEnvironmentNotSupported, cloudName)
+	}
+}
+
 // getAzureMAPIProviderSpec returns a Azure Machine ProviderSpec from the the cluster.
 func getAzureMAPIProviderSpec(ctx context.Context, cl client.Client) (*mapiv1beta1.AzureMachineProviderSpec, error) {
 	rawProviderSpec, err := getRawMAPIProviderSpec(ctx, cl)
@@ -105,29 +141,31 @@ func (r *InfraClusterController) getAzureLocation(ctx context.Context) (string,
 	return machineSpec.Location, nil
 }
 
-// ensureClusterSecret ensures the AzureClusterSecret exists, and if it doesn't, creates it.
+// ensureClusterSecret ensures the AzureClusterSecret exists and holds the current client secret, creating it if it doesn't.
+// CAPZ controllers expect a Secret Ref with a different data structure to what the secret we get from the Cluster Credential Operator provides.
+// That is why the values are copied to a new secret, which is updated when the Cluster Credential Operator rotates the client secret.
 func (r *InfraClusterController) ensureClusterSecret(ctx context.Context, capzManagerBootstrapSecret corev1.Secret) error {
-	// Get the Azure Cluster Secret.
-	// CAPZ controllers expect a Secret Ref with a different data structure to what the secret we get from the Cluster Credential Operator above provides.
-	// That is why we are creating a new secret below to copy the values to.
-	clusterSecret := &corev1.Secret{}
-	if err := r.Get(ctx, client.ObjectKey{Namespace: defaultCAPINamespace, Name: clusterSecretName}, clusterSecret); err != nil && !cerrors.IsNotFound(err) {
-		return fmt.Errorf("failed to get Azure Cluster Secret: %w", err)
-	} else if err == nil {
-		// When the object already exists, there's nothing to do.
-		return nil
+	azureClientSecret, ok := capzManagerBootstrapSecret.Data["azure_client_secret"]
+	if !ok {
+		// Without the bootstrap credentials, the existing secret is kept rather than emptied.
+		clusterSecret := &corev1.Secret{}
+		if err := r.Get(ctx, client.ObjectKey{Namespace: defaultCAPINamespace, Name: clusterSecretName}, clusterSecret); err == nil {
+			return nil
+		} else if !cerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get Azure Cluster Secret: %w", err)
+		}
 	}
 
-	if err := createNewAzureSecret(ctx, r.Client, capzManagerBootstrapSecret.Data["azure_client_secret"]); err != nil {
-		return fmt.Errorf("failed to create Azure Cluster secret: %w", err)
+	if err := r.syncCredentialsSecret(ctx, newAzureSecret(azureClientSecret)); err != nil {
+		return fmt.Errorf("failed to sync Azure Cluster secret: %w", err)
 	}
 
 	return nil
 }
 
-// createNewAzureSecret creates a new Azure Cluster Secret.
-func createNewAzureSecret(ctx context.Context, cl client.Client, azureClientSecret []byte) error {
-	azureSecret := &corev1.Secret{
+// newAzureSecret returns the Azure Cluster Secret holding the given client secret.
+func newAzureSecret(azureClientSecret []byte) *corev1.Secret {
+	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      clusterSecretName,
 			Namespace: defaultCAPINamespace,
@@ -137,23 +175,17 @@ func createNewAzureSecret(ctx context.Context, cl client.Client, azureClientSecr
 			"clientSecret": azureClientSecret,
 		},
 	}
-
-	if err := cl.Create(ctx, azureSecret); err != nil && !cerrors.IsAlreadyExists(err) {
-		return fmt.Errorf("failed to create new Azure Cluster Secret: %w", err)
-	}
-
-	return nil
 }
 
-// ensureClusterIdentity ensures the AzureClusterSecret exists, and if it doesn't, creates it.
+// ensureClusterIdentity ensures the AzureClusterIdentity exists, and if it doesn't, creates it.
+// An existing AzureClusterIdentity is updated when the Cluster Credential Operator rotates the client or tenant IDs.
 func (r *InfraClusterController) ensureClusterIdentity(ctx context.Context, capzManagerBootstrapSecret corev1.Secret) error {
 	azureClusterIdentity := &azurev1.AzureClusterIdentity{}
 	// Get the Azure Cluster Identity.
 	if err := r.Get(ctx, client.ObjectKey{Namespace: defaultCAPINamespace, Name: r.Infra.Status.InfrastructureName}, azureClusterIdentity); err != nil && !cerrors.IsNotFound(err) {
 		return fmt.Errorf("failed to get Azure Cluster Identity: %w", err)
 	} else if err == nil {
-		// When the object already exists, there's nothing to do.
-		return nil
+		return r.syncClusterIdentity(ctx, azureClusterIdentity, capzManagerBootstrapSecret)
 	}
 
 	if err := r.createAzureClusterIdentity(ctx, capzManagerBootstrapSecret); err != nil {
@@ -163,16 +195,57 @@ func (r *InfraClusterController) ensureClusterIdentity(ctx context.Context, capz
 	return nil
 }
 
-// createNewAzureClusterIdenity creates a new AzureClusterIdentity.
-func (r *InfraClusterController) createAzureClusterIdentity(ctx context.Context, capzManagerBootstrapSecret corev1.Secret) error {
-	azureClientID, ok := capzManagerBootstrapSecret.Data["azure_client_id"]
-	if !ok {
-		return errUnableToGetAzureClientID
+// syncClusterIdentity updates the client and tenant IDs of the AzureClusterIdentity from the bootstrap credentials.
+// IDs missing from the bootstrap credentials are left as they are.
+// The AzureClusterIdentity is recreated when the type of the bootstrap credentials changed, as its type is immutable.
+func (r *InfraClusterController) syncClusterIdentity(ctx context.Context, azureClusterIdentity *azurev1.AzureClusterIdentity, capzManagerBootstrapSecret corev1.Secret) error {
+	if identityType := azureClusterIdentityType(capzManagerBootstrapSecret); len(capzManagerBootstrapSecret.Data) > 0 && azureClusterIdentity.Spec.Type != identityType {
+		if err := r.Delete(ctx, azureClusterIdentity, client.Preconditions{ResourceVersion: &azureClusterIdentity.ResourceVersion}); err != nil && !cerrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete Azure Cluster Identity of type %s: %w", azureClusterIdentity.Spec.Type, err)
+		}
+
+		if err := r.createAzureClusterIdentity(ctx, capzManagerBootstrapSecret); err != nil {
+			return fmt.Errorf("failed to recreate Azure Cluster Identity: %w", err)
+		}
+
+		r.RecordEvent(ctx, corev1.EventTypeNormal, "CredentialsRotated",
+			fmt.Sprintf("Recreated AzureClusterIdentity %s/%s of type %s after the change of its credentials", azureClusterIdentity.Namespace, azureClusterIdentity.Name, identityType))
+
+		return nil
 	}
 
-	azureTenantID, ok := capzManagerBootstrapSecret.Data["azure_tenant_id"]
-	if !ok {
-		return errUnableToGetAzureTenantID
+	patchBase := client.MergeFrom(azureClusterIdentity.DeepCopy())
+
+	if azureClientID, ok := capzManagerBootstrapSecret.Data["azure_client_id"]; ok {
+		azureClusterIdentity.Spec.ClientID = string(azureClientID)
+	}
+
+	if azureTenantID, ok := capzManagerBootstrapSecret.Data["azure_tenant_id"]; ok {
+		azureClusterIdentity.Spec.TenantID = string(azureTenantID)
+	}
+
+	patch, err := patchBase.Data(azureClusterIdentity)
+	if err != nil {
+		return fmt.Errorf("failed to compute Azure Cluster Identity patch: %w", err)
+	} else if string(patch) == "{}" {
+		return nil
+	}
+
+	if err := r.Patch(ctx, azureClusterIdentity, patchBase); err != nil {
+		return fmt.Errorf("failed to update Azure Cluster Identity: %w", err)
+	}
+
+	r.RecordEvent(ctx, corev1.EventTypeNormal, "CredentialsRotated",
+		fmt.Sprintf("Updated AzureClusterIdentity %s/%s after the rotation of its credentials", azureClusterIdentity.Namespace, azureClusterIdentity.Name))
+
+	return nil
+}
+
+// createNewAzureClusterIdenity creates a new AzureClusterIdentity.
+func (r *InfraClusterController) createAzureClusterIdentity(ctx context.Context, capzManagerBootstrapSecret corev1.Secret) error {
+	spec, err := newAzureClusterIdentitySpec(capzManagerBootstrapSecret)
+	if err != nil {
+		return err
 	}
 
 	azureClusterIdentity := &azurev1.AzureClusterIdentity{
@@ -185,13 +258,7 @@ func (r *InfraClusterController) createAzureClusterIdentity(ctx context.Context,
 				clusterv1.ManagedByAnnotation: managedByAnnotationValueClusterCAPIOperatorInfraClusterController,
 			},
 		},
-		Spec: azurev1.AzureClusterIdentitySpec{
-			Type:              azurev1.ServicePrincipal,
-			AllowedNamespaces: &azurev1.AllowedNamespaces{NamespaceList: []string{defaultCAPINamespace}},
-			ClientID:          string(azureClientID),
-			TenantID:          string(azureTenantID),
-			ClientSecret:      corev1.SecretReference{Name: clusterSecretName, Namespace: defaultCAPINamespace},
-		},
+		Spec: spec,
 	}
 
 	// The Azure Cluster Identtiy does not exist, so it needs to be created.
@@ -202,8 +269,45 @@ func (r *InfraClusterController) createAzureClusterIdentity(ctx context.Context,
 	return nil
 }
 
+// azureClusterIdentityType returns the type of AzureClusterIdentity matching the bootstrap credentials:
+// WorkloadIdentity when they hold a federated token file rather than a client secret, ServicePrincipal otherwise.
+func azureClusterIdentityType(capzManagerBootstrapSecret corev1.Secret) azurev1.IdentityType {
+	if _, ok := capzManagerBootstrapSecret.Data[azureFederatedTokenFileKey]; ok {
+		return azurev1.WorkloadIdentity
+	}
+
+	return azurev1.ServicePrincipal
+}
+
+// newAzureClusterIdentitySpec returns the spec of the AzureClusterIdentity for the bootstrap credentials.
+// A WorkloadIdentity has no client secret, CAPZ reads the federated token projected into its pod instead.
+func newAzureClusterIdentitySpec(capzManagerBootstrapSecret corev1.Secret) (azurev1.AzureClusterIdentitySpec, error) {
+	azureClientID, ok := capzManagerBootstrapSecret.Data["azure_client_id"]
+	if !ok {
+		return azurev1.AzureClusterIdentitySpec{}, errUnableToGetAzureClientID
+	}
+
+	azureTenantID, ok := capzManagerBootstrapSecret.Data["azure_tenant_id"]
+	if !ok {
+		return azurev1.AzureClusterIdentitySpec{}, errUnableToGetAzureTenantID
+	}
+
+	spec := azurev1.AzureClusterIdentitySpec{
+		Type:              azureClusterIdentityType(capzManagerBootstrapSecret),
+		AllowedNamespaces: &azurev1.AllowedNamespaces{NamespaceList: []string{defaultCAPINamespace}},
+		ClientID:          string(azureClientID),
+		TenantID:          string(azureTenantID),
+	}
+
+	if spec.Type == azurev1.ServicePrincipal {
+		spec.ClientSecret = corev1.SecretReference{Name: clusterSecretName, Namespace: defaultCAPINamespace}
+	}
+
+	return spec, nil
+}
+
 // ensureAzureInfraCluster ensures the InfraCluster exists, and if it doesn't, creates it.
-func (r *InfraClusterController) ensureAzureInfraCluster(ctx context.Context, target *azurev1.AzureCluster, log logr.Logger) error {
+func (r *InfraClusterController) ensureAzureInfraCluster(ctx context.Context, target *azurev1.AzureCluster, azureEnvironment string, log logr.Logger) error {
 	if r.Infra.Status.PlatformStatus == nil {
 		return errPlatformStatusNil
 	}
@@ -238,18 +342,33 @@ func (r *InfraClusterController) ensureAzureInfraCluster(ctx context.Context, ta
 		return fmt.Errorf("error obtaining Azure Cluster location: %w", err)
 	}
 
-	azureCluster := r.newAzureCluster(providerSpec, apiURL, port, location)
+	azureCluster := r.newAzureCluster(providerSpec, apiURL, port, location, azureEnvironment)
 	if err := r.Create(ctx, azureCluster); err != nil {
 		return fmt.Errorf("error creating New Azure Cluster: %w", err)
 	}
 
 	log.Info(fmt.Sprintf("InfraCluster '%s/%s' successfully created", defaultCAPINamespace, r.Infra.Status.InfrastructureName))
+	r.recordInfraClusterCreated(ctx)
+	r.recordAzureUnsupportedOnCAPI(ctx, providerSpec)
 
 	return nil
 }
 
+// recordAzureUnsupportedOnCAPI records a Warning event when the MAPI providerSpec the AzureCluster was created from
+// places the Machines in an availability set. Neither the AzureCluster nor the AzureMachine can reference an existing
+// availability set, CAPZ only uses the availability sets it creates itself, so these Machines cannot be migrated.
+func (r *InfraClusterController) recordAzureUnsupportedOnCAPI(ctx context.Context, providerSpec *mapiv1beta1.AzureMachineProviderSpec) {
+	if providerSpec.AvailabilitySet == "" {
+		return
+	}
+
+	r.RecordEvent(ctx, corev1.EventTypeWarning, ReasonUnsupportedOnCAPI,
+		fmt.Sprintf("Machines in availability set %s cannot be migrated to Cluster API, CAPZ only places machines in availability sets it creates itself",
+			providerSpec.AvailabilitySet))
+}
+
 // createNewAzureCluster creates a new Azure Infra Cluster.
-func (r *InfraClusterController) newAzureCluster(providerSpec *mapiv1beta1.AzureMachineProviderSpec, apiURL *url.URL, port int64, location string) *azurev1.AzureCluster {
+func (r *InfraClusterController) newAzureCluster(providerSpec *mapiv1beta1.AzureMachineProviderSpec, apiURL *url.URL, port int64, location string, azureEnvironment string) *azurev1.AzureCluster {
 	return &azurev1.AzureCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      r.Infra.Status.InfrastructureName,
@@ -264,7 +383,7 @@ func (r *InfraClusterController) newAzureCluster(providerSpec *mapiv1beta1.Azure
 		Spec: azurev1.AzureClusterSpec{
 			AzureClusterClassSpec: azurev1.AzureClusterClassSpec{
 				Location:         location,
-				AzureEnvironment: "AzurePublicCloud",
+				AzureEnvironment: azureEnvironment,
 				IdentityRef: &corev1.ObjectReference{
 					Name:      r.Infra.Status.InfrastructureName,
 					Namespace: defaultCAPINamespace,
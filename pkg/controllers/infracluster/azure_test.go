@@ -0,0 +1,126 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package infracluster
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/record"
+	azurev1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	configv1 "github.com/openshift/api/config/v1"
+	mapiv1beta1 "github.com/openshift/api/machine/v1beta1"
+
+	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+	"github.com/openshift/cluster-capi-operator/pkg/operatorstatus"
+)
+
+var _ = DescribeTable("getAzureEnvironment",
+	func(platformStatus *configv1.PlatformStatus, expectedEnvironment string, expectedErr error) {
+		azureEnvironment, err := getAzureEnvironment(platformStatus)
+		if expectedErr != nil {
+			Expect(err).To(MatchError(expectedErr))
+			Expect(err).To(MatchError(errPlatformNotSupported), "should be reported as a platform not supported yet")
+			return
+		}
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(azureEnvironment).To(Equal(expectedEnvironment))
+	},
+	Entry("without an Azure platform status", &configv1.PlatformStatus{Type: configv1.AzurePlatformType}, "AzurePublicCloud", nil),
+	Entry("without a cloud name", &configv1.PlatformStatus{Azure: &configv1.AzurePlatformStatus{}}, "AzurePublicCloud", nil),
+	Entry("with the public cloud", &configv1.PlatformStatus{Azure: &configv1.AzurePlatformStatus{CloudName: configv1.AzurePublicCloud}}, "AzurePublicCloud", nil),
+	Entry("with the US government cloud", &configv1.PlatformStatus{Azure: &configv1.AzurePlatformStatus{CloudName: configv1.AzureUSGovernmentCloud}}, "AzureUSGovernmentCloud", nil),
+	Entry("with the China cloud", &configv1.PlatformStatus{Azure: &configv1.AzurePlatformStatus{CloudName: configv1.AzureChinaCloud}}, "AzureChinaCloud", nil),
+	Entry("with Azure Stack Hub", &configv1.PlatformStatus{Azure: &configv1.AzurePlatformStatus{
+		CloudName:   configv1.AzureStackCloud,
+		ARMEndpoint: "https://management.local.azurestack.external",
+	}}, "", errAzureCloudEnvironmentNotSupported),
+)
+
+var _ = Describe("newAzureClusterIdentitySpec", func() {
+	newBootstrapSecret := func(data map[string]string) corev1.Secret {
+		secret := corev1.Secret{Data: map[string][]byte{"azure_client_id": []byte("client"), "azure_tenant_id": []byte("tenant")}}
+		for k, v := range data {
+			secret.Data[k] = []byte(v)
+		}
+
+		return secret
+	}
+
+	It("should reference the cluster secret of a service principal", func() {
+		spec, err := newAzureClusterIdentitySpec(newBootstrapSecret(map[string]string{"azure_client_secret": "secret"}))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(spec.Type).To(Equal(azurev1.ServicePrincipal))
+		Expect(spec.ClientID).To(Equal("client"))
+		Expect(spec.TenantID).To(Equal("tenant"))
+		Expect(spec.ClientSecret.Name).To(Equal(clusterSecretName))
+	})
+
+	It("should use Workload Identity with a federated token file", func() {
+		spec, err := newAzureClusterIdentitySpec(newBootstrapSecret(map[string]string{azureFederatedTokenFileKey: "/var/run/secrets/openshift/serviceaccount/token"}))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(spec.Type).To(Equal(azurev1.WorkloadIdentity))
+		Expect(spec.ClientID).To(Equal("client"))
+		Expect(spec.ClientSecret).To(BeZero())
+	})
+
+	It("should require the client ID", func() {
+		_, err := newAzureClusterIdentitySpec(corev1.Secret{Data: map[string][]byte{"azure_tenant_id": []byte("tenant")}})
+		Expect(err).To(MatchError(errUnableToGetAzureClientID))
+	})
+})
+
+var _ = DescribeTable("recordAzureUnsupportedOnCAPI",
+	func(availabilitySet string, expectedEvents []string) {
+		scheme := runtime.NewScheme()
+		utilruntime.Must(configv1.AddToScheme(scheme))
+
+		recorder := record.NewFakeRecorder(10)
+		r := &InfraClusterController{
+			ClusterOperatorStatusClient: operatorstatus.ClusterOperatorStatusClient{
+				Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(&configv1.ClusterOperator{
+					ObjectMeta: metav1.ObjectMeta{Name: controllers.ClusterOperatorName},
+				}).Build(),
+				Recorder: recorder,
+			},
+		}
+
+		r.recordAzureUnsupportedOnCAPI(context.Background(), &mapiv1beta1.AzureMachineProviderSpec{AvailabilitySet: availabilitySet})
+
+		close(recorder.Events)
+
+		events := []string{}
+		for event := range recorder.Events {
+			events = append(events, event)
+		}
+
+		Expect(events).To(Equal(expectedEvents))
+	},
+	Entry("without an availability set", "", []string{}),
+	Entry("with an availability set", "cluster-worker-as", []string{
+		"Warning UnsupportedOnCAPI Machines in availability set cluster-worker-as cannot be migrated to Cluster API, " +
+			"CAPZ only places machines in availability sets it creates itself",
+	}),
+)
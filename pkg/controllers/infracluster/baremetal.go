@@ -0,0 +1,109 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package infracluster
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	configv1 "github.com/openshift/api/config/v1"
+	cerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Metal3ClusterGroupVersionKind is the GroupVersionKind of the CAPM3 Metal3Cluster.
+// The CAPM3 API is not vendored, so Metal3Clusters are handled as unstructured objects.
+// Only CAPM3 and the Metal3Cluster are managed: the MAPI Machines cannot be converted to Metal3Machines
+// until the APIs are vendored, see the TODO in the sync controllers.
+var Metal3ClusterGroupVersionKind = schema.GroupVersionKind{
+	Group:   "infrastructure.cluster.x-k8s.io",
+	Version: "v1beta1",
+	Kind:    "Metal3Cluster",
+}
+
+// NewMetal3Cluster returns an empty unstructured Metal3Cluster.
+func NewMetal3Cluster() *unstructured.Unstructured {
+	metal3Cluster := &unstructured.Unstructured{}
+	metal3Cluster.SetGroupVersionKind(Metal3ClusterGroupVersionKind)
+
+	return metal3Cluster
+}
+
+// ensureMetal3Cluster ensures the Metal3Cluster cluster object exists.
+func (r *InfraClusterController) ensureMetal3Cluster(ctx context.Context, log logr.Logger) (client.Object, error) {
+	target := NewMetal3Cluster()
+	target.SetName(r.Infra.Status.InfrastructureName)
+	target.SetNamespace(defaultCAPINamespace)
+
+	// Checking whether InfraCluster object exists. If it doesn't, create it.
+	if err := r.Get(ctx, client.ObjectKeyFromObject(target), target); err != nil && !cerrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get InfraCluster: %w", err)
+	} else if err == nil {
+		return target, nil
+	}
+
+	log.Info(fmt.Sprintf("Metal3Cluster %s/%s does not exist, creating it", target.GetNamespace(), target.GetName()))
+
+	spec, err := metal3ClusterSpecFromInfra(r.Infra)
+	if err != nil {
+		return nil, err
+	}
+
+	target.SetAnnotations(map[string]string{
+		// The ManagedBy Annotation is set so CAPI infra providers ignore the InfraCluster object,
+		// as that's managed externally, in this case by this controller.
+		clusterv1.ManagedByAnnotation: managedByAnnotationValueClusterCAPIOperatorInfraClusterController,
+	})
+
+	target.Object["spec"] = spec
+
+	if err := r.Create(ctx, target); err != nil {
+		return nil, fmt.Errorf("failed to create InfraCluster: %w", err)
+	}
+
+	log.Info(fmt.Sprintf("InfraCluster '%s/%s' successfully created", defaultCAPINamespace, r.Infra.Status.InfrastructureName))
+	r.recordInfraClusterCreated(ctx)
+
+	return target, nil
+}
+
+// metal3ClusterSpecFromInfra builds the Metal3Cluster spec describing the existing cluster.
+// OpenShift bare metal clusters run no cloud provider, so CAPM3 is left to set the providerID on the Nodes.
+func metal3ClusterSpecFromInfra(infra *configv1.Infrastructure) (map[string]interface{}, error) {
+	apiURL, err := url.Parse(infra.Status.APIServerInternalURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse apiUrl: %w", err)
+	}
+
+	port, err := strconv.ParseInt(apiURL.Port(), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse apiUrl port: %w", err)
+	}
+
+	return map[string]interface{}{
+		"controlPlaneEndpoint": map[string]interface{}{
+			"host": apiURL.Hostname(),
+			"port": port,
+		},
+		"noCloudProvider": true,
+	}, nil
+}
@@ -0,0 +1,46 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package infracluster
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+var _ = Describe("metal3ClusterSpecFromInfra", func() {
+	It("should point at the internal API without a cloud provider", func() {
+		spec, err := metal3ClusterSpecFromInfra(&configv1.Infrastructure{Status: configv1.InfrastructureStatus{
+			APIServerInternalURL: "https://api-int.test.example.com:6443",
+		}})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(spec).To(Equal(map[string]interface{}{
+			"controlPlaneEndpoint": map[string]interface{}{
+				"host": "api-int.test.example.com",
+				"port": int64(6443),
+			},
+			"noCloudProvider": true,
+		}))
+	})
+
+	It("should fail without an internal API port", func() {
+		_, err := metal3ClusterSpecFromInfra(&configv1.Infrastructure{Status: configv1.InfrastructureStatus{
+			APIServerInternalURL: "https://api-int.test.example.com",
+		}})
+		Expect(err).To(MatchError(ContainSubstring("failed to parse apiUrl port")))
+	})
+})
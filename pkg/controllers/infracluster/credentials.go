@@ -0,0 +1,93 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package infracluster
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	cerrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// credentialsSourceSecrets are the credentials secrets the CAPI credentials secrets are derived from.
+// They are rotated by the cloud-credential-operator or by admins, so they are watched to update the derived secrets.
+//
+//nolint:gochecknoglobals
+var credentialsSourceSecrets = []client.ObjectKey{
+	{Namespace: defaultCAPINamespace, Name: capzManagerBootstrapCredentials},
+	{Namespace: kubeSystemNamespace, Name: vSphereCredentialsName},
+	{Namespace: defaultMAPINamespace, Name: nutanixCredentialsName},
+}
+
+// syncCredentialsSecret creates the derived credentials secret, or updates its data when the source credentials were rotated.
+// Immutable secrets are replaced, as their data cannot be updated.
+// The desired secret sets its Data rather than its StringData, so it can be compared with the existing secret.
+func (r *InfraClusterController) syncCredentialsSecret(ctx context.Context, desired *corev1.Secret) error {
+	existing := &corev1.Secret{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(desired), existing); cerrors.IsNotFound(err) {
+		if err := r.Create(ctx, desired); err != nil && !cerrors.IsAlreadyExists(err) {
+			return fmt.Errorf("unable to create credentials secret %s/%s: %w", desired.Namespace, desired.Name, err)
+		}
+
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to get credentials secret %s/%s: %w", desired.Namespace, desired.Name, err)
+	}
+
+	if secretDataEqual(existing.Data, desired.Data) {
+		return nil
+	}
+
+	if existing.Immutable != nil && *existing.Immutable {
+		if err := r.Delete(ctx, existing, client.Preconditions{ResourceVersion: &existing.ResourceVersion}); err != nil && !cerrors.IsNotFound(err) {
+			return fmt.Errorf("unable to delete rotated credentials secret %s/%s: %w", desired.Namespace, desired.Name, err)
+		}
+
+		if err := r.Create(ctx, desired); err != nil {
+			return fmt.Errorf("unable to recreate rotated credentials secret %s/%s: %w", desired.Namespace, desired.Name, err)
+		}
+	} else {
+		existing.Data = desired.Data
+		if err := r.Update(ctx, existing); err != nil {
+			return fmt.Errorf("unable to update rotated credentials secret %s/%s: %w", desired.Namespace, desired.Name, err)
+		}
+	}
+
+	ctrl.LoggerFrom(ctx).Info("Updated credentials secret after the rotation of its source credentials", "secret", client.ObjectKeyFromObject(desired))
+	r.RecordEvent(ctx, corev1.EventTypeNormal, "CredentialsRotated",
+		fmt.Sprintf("Updated credentials secret %s/%s after the rotation of its source credentials", desired.Namespace, desired.Name))
+
+	return nil
+}
+
+// secretDataEqual checks whether the data of two secrets is the same.
+func secretDataEqual(a, b map[string][]byte) bool {
+	return maps.EqualFunc(a, b, bytes.Equal)
+}
+
+// credentialsSourceSecretPredicate defines a predicate function for the credentials secrets the CAPI credentials secrets are derived from.
+func credentialsSourceSecretPredicate() predicate.Funcs {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return slices.Contains(credentialsSourceSecrets, client.ObjectKeyFromObject(obj))
+	})
+}
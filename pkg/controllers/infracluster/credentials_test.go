@@ -0,0 +1,90 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package infracluster
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/cluster-capi-operator/pkg/operatorstatus"
+)
+
+var _ = Describe("syncCredentialsSecret", func() {
+	var (
+		ctx      context.Context
+		cl       client.Client
+		recorder *record.FakeRecorder
+		r        *InfraClusterController
+	)
+
+	newSecret := func(clientSecret string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: clusterSecretName, Namespace: defaultCAPINamespace},
+			Immutable:  ptr.To(true),
+			Data:       map[string][]byte{"clientSecret": []byte(clientSecret)},
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+		cl = fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(newSecret("old")).Build()
+		recorder = record.NewFakeRecorder(10)
+		r = &InfraClusterController{
+			ClusterOperatorStatusClient: operatorstatus.ClusterOperatorStatusClient{Client: cl, Recorder: recorder},
+		}
+	})
+
+	It("should leave the secret alone when the credentials did not change", func() {
+		before := &corev1.Secret{}
+		Expect(cl.Get(ctx, client.ObjectKeyFromObject(newSecret("")), before)).To(Succeed())
+
+		Expect(r.syncCredentialsSecret(ctx, newSecret("old"))).To(Succeed())
+
+		after := &corev1.Secret{}
+		Expect(cl.Get(ctx, client.ObjectKeyFromObject(newSecret("")), after)).To(Succeed())
+		Expect(after.ResourceVersion).To(Equal(before.ResourceVersion))
+	})
+
+	It("should replace the immutable secret when the credentials were rotated", func() {
+		Expect(r.syncCredentialsSecret(ctx, newSecret("new"))).To(Succeed())
+
+		secret := &corev1.Secret{}
+		Expect(cl.Get(ctx, client.ObjectKeyFromObject(newSecret("")), secret)).To(Succeed())
+		Expect(secret.Data).To(HaveKeyWithValue("clientSecret", []byte("new")))
+		Expect(secret.Immutable).To(HaveValue(BeTrue()))
+	})
+
+	It("should create the secret when it does not exist", func() {
+		desired := newSecret("new")
+		desired.Name = "other"
+
+		Expect(r.syncCredentialsSecret(ctx, desired)).To(Succeed())
+
+		secret := &corev1.Secret{}
+		Expect(cl.Get(ctx, client.ObjectKeyFromObject(desired), secret)).To(Succeed())
+		Expect(secret.Data).To(HaveKeyWithValue("clientSecret", []byte("new")))
+	})
+})
@@ -101,6 +101,7 @@ func (r *InfraClusterController) ensureGCPCluster(ctx context.Context, log logr.
 	}
 
 	log.Info(fmt.Sprintf("InfraCluster '%s/%s' successfully created", defaultCAPINamespace, r.Infra.Status.InfrastructureName))
+	r.recordInfraClusterCreated(ctx)
 
 	return target, nil
 }
@@ -0,0 +1,144 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package infracluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	configv1 "github.com/openshift/api/config/v1"
+	cerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ibmcloudv1 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	errUnableToFindIBMCloudPlatformStatus = errors.New("unable to find the IBM Cloud platform status in the infrastructure status")
+	errUnsupportedIBMCloudProviderType    = errors.New("unsupported IBM Cloud provider type, only VPC is supported")
+)
+
+// ibmCloudMAPIProviderSpec holds the fields of the MAPI IBMCloudMachineProviderSpec needed by the IBMVPCCluster.
+// The MAPI IBM Cloud API is not vendored, so only the required fields are decoded.
+// Only the IBMVPCCluster and its credentials are managed: the MAPI Machines cannot be converted to IBMVPCMachines
+// until the APIs are vendored, see the TODO in the sync controllers.
+type ibmCloudMAPIProviderSpec struct {
+	VPC  string `json:"vpc"`
+	Zone string `json:"zone"`
+}
+
+// ensureIBMVPCCluster ensures the IBMVPCCluster cluster object exists.
+func (r *InfraClusterController) ensureIBMVPCCluster(ctx context.Context, log logr.Logger) (client.Object, error) {
+	platformStatus, err := getIBMCloudVPCPlatformStatus(r.Infra)
+	if err != nil {
+		return nil, fmt.Errorf("error obtaining IBM Cloud platform status: %w", err)
+	}
+
+	target := &ibmcloudv1.IBMVPCCluster{ObjectMeta: metav1.ObjectMeta{
+		Name:      r.Infra.Status.InfrastructureName,
+		Namespace: defaultCAPINamespace,
+	}}
+
+	// Checking whether InfraCluster object exists. If it doesn't, create it.
+	if err := r.Get(ctx, client.ObjectKeyFromObject(target), target); err != nil && !cerrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get InfraCluster: %w", err)
+	} else if err == nil {
+		return target, nil
+	}
+
+	log.Info(fmt.Sprintf("IBMVPCCluster %s/%s does not exist, creating it", target.Namespace, target.Name))
+
+	apiURL, err := url.Parse(r.Infra.Status.APIServerInternalURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse apiUrl: %w", err)
+	}
+
+	port, err := strconv.ParseInt(apiURL.Port(), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse apiUrl port: %w", err)
+	}
+
+	providerSpec, err := getIBMCloudMAPIProviderSpec(ctx, r.Client)
+	if err != nil {
+		return nil, fmt.Errorf("error obtaining IBM Cloud Provider Spec: %w", err)
+	}
+
+	target = &ibmcloudv1.IBMVPCCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      r.Infra.Status.InfrastructureName,
+			Namespace: defaultCAPINamespace,
+			// The ManagedBy Annotation is set so CAPI infra providers ignore the InfraCluster object,
+			// as that's managed externally, in this case by this controller.
+			Annotations: map[string]string{
+				clusterv1.ManagedByAnnotation: managedByAnnotationValueClusterCAPIOperatorInfraClusterController,
+			},
+		},
+		Spec: ibmcloudv1.IBMVPCClusterSpec{
+			Region:        platformStatus.Location,
+			ResourceGroup: platformStatus.ResourceGroupName,
+			VPC:           providerSpec.VPC,
+			Zone:          providerSpec.Zone,
+			ControlPlaneEndpoint: clusterv1.APIEndpoint{
+				Host: apiURL.Hostname(),
+				Port: int32(port),
+			},
+		},
+	}
+
+	if err := r.Create(ctx, target); err != nil {
+		return nil, fmt.Errorf("failed to create InfraCluster: %w", err)
+	}
+
+	log.Info(fmt.Sprintf("InfraCluster '%s/%s' successfully created", defaultCAPINamespace, r.Infra.Status.InfrastructureName))
+	r.recordInfraClusterCreated(ctx)
+
+	return target, nil
+}
+
+// getIBMCloudVPCPlatformStatus returns the IBM Cloud platform status, provided the cluster runs on IBM Cloud VPC.
+func getIBMCloudVPCPlatformStatus(infra *configv1.Infrastructure) (*configv1.IBMCloudPlatformStatus, error) {
+	if infra.Status.PlatformStatus == nil || infra.Status.PlatformStatus.IBMCloud == nil {
+		return nil, errUnableToFindIBMCloudPlatformStatus
+	}
+
+	platformStatus := infra.Status.PlatformStatus.IBMCloud
+	if platformStatus.ProviderType != configv1.IBMCloudProviderTypeVPC {
+		return nil, fmt.Errorf("%w: %q", errUnsupportedIBMCloudProviderType, platformStatus.ProviderType)
+	}
+
+	return platformStatus, nil
+}
+
+// getIBMCloudMAPIProviderSpec returns the IBM Cloud Machine ProviderSpec fields needed by the IBMVPCCluster from the cluster.
+func getIBMCloudMAPIProviderSpec(ctx context.Context, cl client.Client) (*ibmCloudMAPIProviderSpec, error) {
+	rawProviderSpec, err := getRawMAPIProviderSpec(ctx, cl)
+	if err != nil {
+		return nil, fmt.Errorf("unable to obtain MAPI ProviderSpec: %w", err)
+	}
+
+	providerSpec := &ibmCloudMAPIProviderSpec{}
+	if err := yaml.Unmarshal(rawProviderSpec, providerSpec); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal MAPI ProviderSpec: %w", err)
+	}
+
+	return providerSpec, nil
+}
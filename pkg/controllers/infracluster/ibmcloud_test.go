@@ -0,0 +1,50 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package infracluster
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+var _ = DescribeTable("getIBMCloudVPCPlatformStatus",
+	func(platformStatus *configv1.PlatformStatus, expectedPlatformStatus *configv1.IBMCloudPlatformStatus, expectedErr error) {
+		ibmCloudPlatformStatus, err := getIBMCloudVPCPlatformStatus(&configv1.Infrastructure{Status: configv1.InfrastructureStatus{PlatformStatus: platformStatus}})
+		if expectedErr != nil {
+			Expect(err).To(MatchError(expectedErr))
+			return
+		}
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ibmCloudPlatformStatus).To(Equal(expectedPlatformStatus))
+	},
+	Entry("without a platform status", nil, nil, errUnableToFindIBMCloudPlatformStatus),
+	Entry("without an IBM Cloud platform status", &configv1.PlatformStatus{Type: configv1.IBMCloudPlatformType}, nil, errUnableToFindIBMCloudPlatformStatus),
+	Entry("with the Classic provider type", &configv1.PlatformStatus{IBMCloud: &configv1.IBMCloudPlatformStatus{
+		ProviderType: configv1.IBMCloudProviderTypeClassic,
+	}}, nil, errUnsupportedIBMCloudProviderType),
+	Entry("with the VPC provider type", &configv1.PlatformStatus{IBMCloud: &configv1.IBMCloudPlatformStatus{
+		Location:          "us-east",
+		ResourceGroupName: "sample-resource-group",
+		ProviderType:      configv1.IBMCloudProviderTypeVPC,
+	}}, &configv1.IBMCloudPlatformStatus{
+		Location:          "us-east",
+		ResourceGroupName: "sample-resource-group",
+		ProviderType:      configv1.IBMCloudProviderTypeVPC,
+	}, nil),
+)
@@ -19,9 +19,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/url"
+	"strconv"
 
 	"github.com/go-logr/logr"
 
+	corev1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -33,14 +36,16 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 
-	ibmpowervsv1 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
-	openstackv1 "sigs.k8s.io/cluster-api-provider-openstack/api/v1beta1"
+	ibmcloudv1 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
 
 	configv1 "github.com/openshift/api/config/v1"
 	mapiv1 "github.com/openshift/api/machine/v1"
 	mapiv1beta1 "github.com/openshift/api/machine/v1beta1"
 	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+	"github.com/openshift/cluster-capi-operator/pkg/metrics"
 	"github.com/openshift/cluster-capi-operator/pkg/operatorstatus"
+	"github.com/openshift/cluster-capi-operator/pkg/util"
+	"github.com/openshift/library-go/pkg/config/clusteroperator/v1helpers"
 )
 
 const (
@@ -52,6 +57,26 @@ const (
 	// InfraClusterControllerDegradedCondition is the condition type that indicates the InfraCluster controller is degraded.
 	InfraClusterControllerDegradedCondition = "InfraClusterControllerDegraded"
 
+	// Reasons of the InfraClusterControllerAvailable condition, reporting which entity manages the InfraCluster.
+
+	// ReasonManagedByInfraClusterController indicates the InfraCluster is managed by this controller.
+	ReasonManagedByInfraClusterController = "ManagedByInfraClusterController"
+
+	// ReasonManagedByInfrastructureProvider indicates the InfraCluster is managed by the CAPI infrastructure provider.
+	ReasonManagedByInfrastructureProvider = "ManagedByInfrastructureProvider"
+
+	// ReasonInfraClusterNotReady is the reason of the InfraClusterControllerDegraded condition when the InfraCluster
+	// is not ready and its manager did not report a reason.
+	ReasonInfraClusterNotReady = "InfraClusterNotReady"
+
+	// ReasonManagedExternally indicates the InfraCluster is managed by an entity other than this controller
+	// or the CAPI infrastructure provider.
+	ReasonManagedExternally = "ManagedExternally"
+
+	// ReasonUnsupportedOnCAPI is the reason of the Warning event recorded when the MAPI providerSpec the InfraCluster is
+	// created from uses settings that have no Cluster API equivalent, so its Machines cannot be migrated to Cluster API.
+	ReasonUnsupportedOnCAPI = "UnsupportedOnCAPI"
+
 	defaultCAPINamespace = "openshift-cluster-api"
 	defaultMAPINamespace = "openshift-machine-api"
 	controllerName       = "InfraClusterController"
@@ -62,6 +87,12 @@ const (
 
 	kubeSystemNamespace    = "kube-system"
 	vSphereCredentialsName = "vsphere-creds" //nolint:gosec
+
+	// openshiftConfigNamespace holds the cloud provider config referenced by the infrastructure.
+	openshiftConfigNamespace = "openshift-config"
+
+	// defaultCloudConfigKey is the key of the cloud provider config when the infrastructure does not specify one.
+	defaultCloudConfigKey = "config"
 )
 
 var (
@@ -81,45 +112,164 @@ type InfraClusterController struct {
 	Infra    *configv1.Infrastructure
 }
 
+// infraClusterOwnership describes which entity manages the InfraCluster.
+type infraClusterOwnership struct {
+	reason  string
+	message string
+}
+
+// infraClusterFailure describes why the InfraCluster is not ready, as reported by the entity managing it.
+type infraClusterFailure struct {
+	reason  string
+	message string
+}
+
+// infraClusterState is the state of the InfraCluster reported on the ClusterOperator.
+type infraClusterState struct {
+	ownership infraClusterOwnership
+	// failure is set when the InfraCluster is not ready because of a failure.
+	failure *infraClusterFailure
+}
+
 // Reconcile reconciles the cluster-api ClusterOperator object.
 func (r *InfraClusterController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := ctrl.LoggerFrom(ctx).WithName(controllerName)
 
 	log.Info("Reconciling InfraCluster")
 
-	res, err := r.reconcile(ctx, log)
+	// The infrastructure is read on every reconcile so changes to it, such as a new API VIP,
+	// are carried over to the InfraCluster.
+	infra := &configv1.Infrastructure{}
+	if err := r.Get(ctx, client.ObjectKey{Name: controllers.InfrastructureResourceName}, infra); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to get infrastructure: %w", err)
+	}
+
+	r.Infra = infra
+
+	res, state, err := r.reconcile(ctx, log)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("error during reconcile: %w", err)
 	}
 
-	if err := r.setAvailableCondition(ctx, log); err != nil {
+	metrics.SetInfraClusterReady(state.failure == nil)
+
+	if state.failure != nil {
+		// The InfraCluster is watched, so the conditions are updated once it recovers.
+		if err := r.setDegradedCondition(ctx, log, *state.failure); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to set conditions for InfraCluster controller: %w", err)
+		}
+
+		return res, nil
+	}
+
+	if err := r.setAvailableCondition(ctx, log, state.ownership); err != nil {
 		return ctrl.Result{}, fmt.Errorf("failed to set conditions for InfraCluster controller: %w", err)
 	}
 
 	return res, nil
 }
 
-func (r *InfraClusterController) reconcile(ctx context.Context, log logr.Logger) (ctrl.Result, error) {
+func (r *InfraClusterController) reconcile(ctx context.Context, log logr.Logger) (ctrl.Result, infraClusterState, error) {
 	infraCluster, err := r.ensureInfraCluster(ctx, log)
 	if err != nil && errors.Is(err, errPlatformNotSupported) {
 		log.Info("Could not find or create an InfraCluster on this platform as it is not yet supported.")
-		return ctrl.Result{}, nil
+		return ctrl.Result{}, infraClusterState{
+			ownership: infraClusterOwnership{reason: operatorstatus.ReasonAsExpected, message: "InfraCluster Controller works as expected"},
+		}, nil
 	} else if err != nil {
-		return ctrl.Result{}, fmt.Errorf("unable to ensure InfraCluster: %w", err)
+		return ctrl.Result{}, infraClusterState{}, fmt.Errorf("unable to ensure InfraCluster: %w", err)
+	}
+
+	if err := r.reconcileDeletionProtection(ctx, log, infraCluster); err != nil {
+		return ctrl.Result{}, infraClusterState{}, err
 	}
 
 	// At this point, the InfraCluster exists.
 	// Check if it has the managedByAnnotation.
-	return r.reconcileInfraCluster(ctx, log, infraCluster)
+	ownership := getInfraClusterOwnership(infraCluster)
+
+	if !infraCluster.GetDeletionTimestamp().IsZero() {
+		// The InfraCluster is watched, it is recreated once its deletion completes.
+		return ctrl.Result{}, infraClusterState{ownership: ownership}, nil
+	}
+
+	res, err := r.reconcileInfraCluster(ctx, log, infraCluster, ownership)
+	if err != nil {
+		return ctrl.Result{}, infraClusterState{}, err
+	}
+
+	failure, err := getInfraClusterFailure(infraCluster)
+	if err != nil {
+		return ctrl.Result{}, infraClusterState{}, fmt.Errorf("unable to get failure for InfraCluster: %w", err)
+	}
+
+	return res, infraClusterState{ownership: ownership, failure: failure}, nil
 }
 
-// reconcileInfraCluster reconciles the InfraCluster object.
-// It first determines if the infra cluster should be managed before setting the infra cluster ready.
-func (r *InfraClusterController) reconcileInfraCluster(ctx context.Context, log logr.Logger, infraCluster client.Object) (ctrl.Result, error) {
+// reconcileDeletionProtection keeps the InfraCluster from being removed while Machines still reference its Cluster,
+// regardless of which entity manages it, as its deletion would deprovision the infrastructure the Machines run on.
+func (r *InfraClusterController) reconcileDeletionProtection(ctx context.Context, log logr.Logger, infraCluster client.Object) error {
+	if infraCluster.GetName() == "" {
+		// The InfraCluster is not created by this controller on this platform and does not exist yet.
+		return nil
+	}
+
+	machines, err := util.ReconcileDeletionProtection(ctx, r.Client, infraCluster)
+	if err != nil {
+		return fmt.Errorf("unable to reconcile InfraCluster deletion protection: %w", err)
+	}
+
+	if len(machines) > 0 {
+		// The Machines are watched, the finalizer is removed once the last one is deleted.
+		log.Info(fmt.Sprintf("InfraCluster '%s/%s' is being deleted, waiting for the Machines referencing it to be deleted",
+			infraCluster.GetNamespace(), infraCluster.GetName()), "machines", machines)
+	}
+
+	return nil
+}
+
+// getInfraClusterOwnership determines which entity manages the InfraCluster from its managed-by annotation.
+// Admins can take over the management of the InfraCluster by changing or removing the annotation.
+func getInfraClusterOwnership(infraCluster client.Object) infraClusterOwnership {
 	managedByAnnotationVal, foundAnnotation := infraCluster.GetAnnotations()[clusterv1.ManagedByAnnotation]
 
 	switch {
 	case !foundAnnotation:
+		return infraClusterOwnership{
+			reason: ReasonManagedByInfrastructureProvider,
+			message: fmt.Sprintf("InfraCluster '%s/%s' is managed by the CAPI infrastructure provider",
+				infraCluster.GetNamespace(), infraCluster.GetName()),
+		}
+	case managedByAnnotationVal != managedByAnnotationValueClusterCAPIOperatorInfraClusterController:
+		return infraClusterOwnership{
+			reason: ReasonManagedExternally,
+			message: fmt.Sprintf("InfraCluster '%s/%s' is managed externally by %q",
+				infraCluster.GetNamespace(), infraCluster.GetName(), managedByAnnotationVal),
+		}
+	default:
+		return infraClusterOwnership{
+			reason: ReasonManagedByInfraClusterController,
+			message: fmt.Sprintf("InfraCluster '%s/%s' is managed by the InfraCluster Controller",
+				infraCluster.GetNamespace(), infraCluster.GetName()),
+		}
+	}
+}
+
+// isOwnershipReason returns whether the condition reason reports which entity manages the InfraCluster.
+func isOwnershipReason(reason string) bool {
+	switch reason {
+	case ReasonManagedByInfraClusterController, ReasonManagedByInfrastructureProvider, ReasonManagedExternally:
+		return true
+	default:
+		return false
+	}
+}
+
+// reconcileInfraCluster reconciles the InfraCluster object.
+// It first determines if the infra cluster should be managed before setting the infra cluster ready.
+func (r *InfraClusterController) reconcileInfraCluster(ctx context.Context, log logr.Logger, infraCluster client.Object, ownership infraClusterOwnership) (ctrl.Result, error) {
+	switch ownership.reason {
+	case ReasonManagedByInfrastructureProvider:
 		// Could not find the managedByAnnotation on the InfraCluster object.
 		// This means, by definition, that the object is directly managed by CAPI infrastructure providers.
 		// No action should be taken by this controller.
@@ -128,18 +278,26 @@ func (r *InfraClusterController) reconcileInfraCluster(ctx context.Context, log
 			infraCluster.GetNamespace(), infraCluster.GetName()))
 
 		return ctrl.Result{}, nil
-	case managedByAnnotationVal != managedByAnnotationValueClusterCAPIOperatorInfraClusterController:
+	case ReasonManagedExternally:
 		// At this point it is not this controller's responsibility to manage this InfraCluster object, nor it is
 		// the CAPI infra providers responsbility to do so. This means this object was created outside of these two entities - thus
 		// the creating entity must manage its readiness.
 		log.Info(fmt.Sprintf("InfraCluster '%s/%s' is annotated with an unrecognized externally managed annotation value %q"+
 			" - skipping as it is not managed by this controller",
-			infraCluster.GetNamespace(), infraCluster.GetName(), managedByAnnotationVal))
+			infraCluster.GetNamespace(), infraCluster.GetName(), infraCluster.GetAnnotations()[clusterv1.ManagedByAnnotation]))
 
 		return ctrl.Result{}, nil
 	}
 
 	// At this point it is this controller's responsibility to manage this InfraCluster object.
+	if err := r.ensureControlPlaneEndpoint(ctx, log, infraCluster); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to ensure InfraCluster control plane endpoint: %w", err)
+	}
+
+	if err := r.ensureInfraClusterTopology(ctx, log, infraCluster); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to ensure InfraCluster topology: %w", err)
+	}
+
 	isReady, err := getReadiness(infraCluster)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf("unable to get readiness for InfraCluster: %w", err)
@@ -189,11 +347,6 @@ func (r *InfraClusterController) ensureInfraCluster(ctx context.Context, log log
 			return nil, fmt.Errorf("error ensuring GCPCluster: %w", err)
 		}
 	case configv1.AzurePlatformType:
-		if r.Infra.Status.PlatformStatus.Azure.CloudName == configv1.AzureStackCloud {
-			log.Info("%s cloud environment for platform %s is not supported", "environment", configv1.AzureStackCloud, "platform", configv1.AzurePlatformType)
-			return nil, errPlatformNotSupported
-		}
-
 		var err error
 
 		infraCluster, err = r.ensureAzureCluster(ctx, log)
@@ -201,12 +354,19 @@ func (r *InfraClusterController) ensureInfraCluster(ctx context.Context, log log
 			return nil, fmt.Errorf("error getting InfraCluster object: %w", err)
 		}
 	case configv1.PowerVSPlatformType:
-		powervsCluster := &ibmpowervsv1.IBMPowerVSCluster{}
+		powervsCluster := &ibmcloudv1.IBMPowerVSCluster{}
 		if err := r.Get(ctx, client.ObjectKey{Namespace: defaultCAPINamespace, Name: r.Infra.Status.InfrastructureName}, powervsCluster); err != nil && !kerrors.IsNotFound(err) {
 			return nil, fmt.Errorf("error getting InfraCluster object: %w", err)
 		}
 
 		infraCluster = powervsCluster
+	case configv1.IBMCloudPlatformType:
+		var err error
+
+		infraCluster, err = r.ensureIBMVPCCluster(ctx, log)
+		if err != nil {
+			return nil, fmt.Errorf("error getting InfraCluster object: %w", err)
+		}
 	case configv1.VSpherePlatformType:
 		var err error
 
@@ -215,12 +375,26 @@ func (r *InfraClusterController) ensureInfraCluster(ctx context.Context, log log
 			return nil, fmt.Errorf("error getting InfraCluster object: %w", err)
 		}
 	case configv1.OpenStackPlatformType:
-		openstackCluster := &openstackv1.OpenStackCluster{}
-		if err := r.Get(ctx, client.ObjectKey{Namespace: defaultCAPINamespace, Name: r.Infra.Status.InfrastructureName}, openstackCluster); err != nil && !kerrors.IsNotFound(err) {
-			return nil, fmt.Errorf("error getting InfraCluster object: %w", err)
+		var err error
+
+		infraCluster, err = r.ensureOpenStackCluster(ctx, log)
+		if err != nil {
+			return nil, fmt.Errorf("error ensuring OpenStackCluster: %w", err)
 		}
+	case configv1.NutanixPlatformType:
+		var err error
 
-		infraCluster = openstackCluster
+		infraCluster, err = r.ensureNutanixCluster(ctx, log)
+		if err != nil {
+			return nil, fmt.Errorf("error ensuring NutanixCluster: %w", err)
+		}
+	case configv1.BareMetalPlatformType:
+		var err error
+
+		infraCluster, err = r.ensureMetal3Cluster(ctx, log)
+		if err != nil {
+			return nil, fmt.Errorf("error ensuring Metal3Cluster: %w", err)
+		}
 	default:
 		return nil, errPlatformNotSupported
 	}
@@ -229,20 +403,26 @@ func (r *InfraClusterController) ensureInfraCluster(ctx context.Context, log log
 }
 
 // setAvailableCondition sets the ClusterOperator status condition to Available.
-func (r *InfraClusterController) setAvailableCondition(ctx context.Context, log logr.Logger) error {
+// The Available condition reports which entity manages the InfraCluster.
+func (r *InfraClusterController) setAvailableCondition(ctx context.Context, log logr.Logger, ownership infraClusterOwnership) error {
 	co, err := r.GetOrCreateClusterOperator(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get cluster operator: %w", err)
 	}
 
+	if previous := v1helpers.FindStatusCondition(co.Status.Conditions, InfraClusterControllerAvailableCondition); previous != nil &&
+		isOwnershipReason(previous.Reason) && previous.Reason != ownership.reason {
+		log.Info("InfraCluster management has been handed off", "previousReason", previous.Reason, "reason", ownership.reason, "message", ownership.message)
+	}
+
 	conds := []configv1.ClusterOperatorStatusCondition{
-		operatorstatus.NewClusterOperatorStatusCondition(InfraClusterControllerAvailableCondition, configv1.ConditionTrue, operatorstatus.ReasonAsExpected,
-			"InfraCluster Controller works as expected"),
+		operatorstatus.NewClusterOperatorStatusCondition(InfraClusterControllerAvailableCondition, configv1.ConditionTrue, ownership.reason,
+			ownership.message),
 		operatorstatus.NewClusterOperatorStatusCondition(InfraClusterControllerDegradedCondition, configv1.ConditionFalse, operatorstatus.ReasonAsExpected,
 			"InfraCluster Controller works as expected"),
 	}
 
-	co.Status.Versions = []configv1.OperandVersion{{Name: controllers.OperatorVersionKey, Version: r.ReleaseVersion}}
+	r.SetOperatorVersion(co)
 
 	log.V(2).Info("InfraCluster Controller is Available")
 
@@ -253,6 +433,39 @@ func (r *InfraClusterController) setAvailableCondition(ctx context.Context, log
 	return nil
 }
 
+// recordInfraClusterCreated records an Event on the ClusterOperator for the creation of the InfraCluster.
+func (r *InfraClusterController) recordInfraClusterCreated(ctx context.Context) {
+	r.RecordEvent(ctx, corev1.EventTypeNormal, "InfraClusterCreated",
+		fmt.Sprintf("Created InfraCluster %s/%s for platform %s", defaultCAPINamespace, r.Infra.Status.InfrastructureName, r.Platform))
+}
+
+// setDegradedCondition sets the ClusterOperator status condition to Degraded with the failure of the InfraCluster.
+func (r *InfraClusterController) setDegradedCondition(ctx context.Context, log logr.Logger, failure infraClusterFailure) error {
+	metrics.RecordReconcileError(controllerName, failure.reason)
+
+	co, err := r.GetOrCreateClusterOperator(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get cluster operator: %w", err)
+	}
+
+	conds := []configv1.ClusterOperatorStatusCondition{
+		operatorstatus.NewClusterOperatorStatusCondition(InfraClusterControllerAvailableCondition, configv1.ConditionFalse, failure.reason,
+			failure.message),
+		operatorstatus.NewClusterOperatorStatusCondition(InfraClusterControllerDegradedCondition, configv1.ConditionTrue, failure.reason,
+			failure.message),
+	}
+
+	r.SetOperatorVersion(co)
+
+	log.Info("InfraCluster Controller is Degraded", "reason", failure.reason, "message", failure.message)
+
+	if err := r.SyncStatus(ctx, co, conds); err != nil {
+		return fmt.Errorf("failed to sync status: %w", err)
+	}
+
+	return nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *InfraClusterController) SetupWithManager(mgr ctrl.Manager, watchedObject client.Object) error {
 	if err := ctrl.NewControllerManagedBy(mgr).
@@ -263,6 +476,21 @@ func (r *InfraClusterController) SetupWithManager(mgr ctrl.Manager, watchedObjec
 			handler.EnqueueRequestsFromMapFunc(toClusterOperator),
 			builder.WithPredicates(infraClusterPredicate(r.ManagedNamespace)),
 		).
+		Watches(
+			&configv1.Infrastructure{},
+			handler.EnqueueRequestsFromMapFunc(toClusterOperator),
+			builder.WithPredicates(infrastructurePredicate()),
+		).
+		Watches(
+			&corev1.Secret{},
+			handler.EnqueueRequestsFromMapFunc(toClusterOperator),
+			builder.WithPredicates(credentialsSourceSecretPredicate()),
+		).
+		Watches(
+			&clusterv1.Machine{},
+			handler.EnqueueRequestsFromMapFunc(toClusterOperator),
+			builder.WithPredicates(machineDeletedPredicate(r.ManagedNamespace)),
+		).
 		Complete(r); err != nil {
 		return fmt.Errorf("failed to create controller: %w", err)
 	}
@@ -270,6 +498,96 @@ func (r *InfraClusterController) SetupWithManager(mgr ctrl.Manager, watchedObjec
 	return nil
 }
 
+// ensureControlPlaneEndpoint keeps the control plane endpoint of the InfraCluster in sync with the internal API
+// of the infrastructure, which can change after the InfraCluster is created, e.g. when the API VIP is moved.
+func (r *InfraClusterController) ensureControlPlaneEndpoint(ctx context.Context, log logr.Logger, infraCluster client.Object) error {
+	desiredEndpoint, err := controlPlaneEndpointFromInfra(r.Infra)
+	if err != nil {
+		return err
+	}
+
+	currentEndpoint, err := getControlPlaneEndpoint(infraCluster)
+	if err != nil {
+		return err
+	}
+
+	if currentEndpoint == desiredEndpoint {
+		return nil
+	}
+
+	infraClusterPatchCopy, ok := infraCluster.DeepCopyObject().(client.Object)
+	if !ok {
+		return errCouldNotDeepCopyInfraObject
+	}
+
+	if err := setControlPlaneEndpoint(infraCluster, desiredEndpoint); err != nil {
+		return fmt.Errorf("unable to set control plane endpoint for InfraCluster: %w", err)
+	}
+
+	if err := r.Patch(ctx, infraCluster, client.MergeFrom(infraClusterPatchCopy)); err != nil {
+		return fmt.Errorf("unable to patch InfraCluster: %w", err)
+	}
+
+	log.Info(fmt.Sprintf("InfraCluster '%s/%s' control plane endpoint successfully updated to %s",
+		infraCluster.GetNamespace(), infraCluster.GetName(), desiredEndpoint.String()))
+
+	return nil
+}
+
+// controlPlaneEndpointFromInfra returns the control plane endpoint of the internal API of the infrastructure.
+func controlPlaneEndpointFromInfra(infra *configv1.Infrastructure) (clusterv1.APIEndpoint, error) {
+	apiURL, err := url.Parse(infra.Status.APIServerInternalURL)
+	if err != nil {
+		return clusterv1.APIEndpoint{}, fmt.Errorf("failed to parse apiURL: %w", err)
+	}
+
+	port, err := strconv.ParseInt(apiURL.Port(), 10, 32)
+	if err != nil {
+		return clusterv1.APIEndpoint{}, fmt.Errorf("failed to parse apiURL port: %w", err)
+	}
+
+	return clusterv1.APIEndpoint{Host: apiURL.Hostname(), Port: int32(port)}, nil
+}
+
+func getControlPlaneEndpoint(infraCluster client.Object) (clusterv1.APIEndpoint, error) {
+	unstructuredInfraCluster, err := runtime.DefaultUnstructuredConverter.ToUnstructured(infraCluster)
+	if err != nil {
+		return clusterv1.APIEndpoint{}, fmt.Errorf("unable to convert to unstructured: %w", err)
+	}
+
+	host, _, err := unstructured.NestedString(unstructuredInfraCluster, "spec", "controlPlaneEndpoint", "host")
+	if err != nil {
+		return clusterv1.APIEndpoint{}, fmt.Errorf("incorrect value for Spec.ControlPlaneEndpoint.Host: %w", err)
+	}
+
+	port, _, err := unstructured.NestedInt64(unstructuredInfraCluster, "spec", "controlPlaneEndpoint", "port")
+	if err != nil {
+		return clusterv1.APIEndpoint{}, fmt.Errorf("incorrect value for Spec.ControlPlaneEndpoint.Port: %w", err)
+	}
+
+	return clusterv1.APIEndpoint{Host: host, Port: int32(port)}, nil
+}
+
+func setControlPlaneEndpoint(infraCluster client.Object, endpoint clusterv1.APIEndpoint) error {
+	unstructuredInfraCluster, err := runtime.DefaultUnstructuredConverter.ToUnstructured(infraCluster)
+	if err != nil {
+		return fmt.Errorf("unable to convert to unstructured: %w", err)
+	}
+
+	if err := unstructured.SetNestedMap(unstructuredInfraCluster, map[string]interface{}{
+		"host": endpoint.Host,
+		"port": int64(endpoint.Port),
+	}, "spec", "controlPlaneEndpoint"); err != nil {
+		return fmt.Errorf("unable to set spec: %w", err)
+	}
+
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(unstructuredInfraCluster, infraCluster); err != nil {
+		return fmt.Errorf("unable to convert from unstructured: %w", err)
+	}
+
+	return nil
+}
+
 func setReadiness(infraCluster client.Object, readiness bool) error {
 	unstructuredInfraCluster, err := runtime.DefaultUnstructuredConverter.ToUnstructured(infraCluster)
 	if err != nil {
@@ -305,6 +623,67 @@ func getReadiness(infraCluster client.Object) (bool, error) {
 	return val, nil
 }
 
+// getInfraClusterFailure returns why the InfraCluster is not ready, or nil when it is ready or still being provisioned.
+// The failure is taken from the terminal failureReason and failureMessage of the InfraCluster status, or from its
+// Ready condition when that is false with an error severity.
+func getInfraClusterFailure(infraCluster client.Object) (*infraClusterFailure, error) {
+	isReady, err := getReadiness(infraCluster)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get readiness for InfraCluster: %w", err)
+	}
+
+	if isReady {
+		return nil, nil //nolint:nilnil
+	}
+
+	unstructuredInfraCluster, err := runtime.DefaultUnstructuredConverter.ToUnstructured(infraCluster)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert to unstructured: %w", err)
+	}
+
+	failureReason, _, _ := unstructured.NestedString(unstructuredInfraCluster, "status", "failureReason")
+	failureMessage, _, _ := unstructured.NestedString(unstructuredInfraCluster, "status", "failureMessage")
+
+	if failureReason == "" && failureMessage == "" {
+		failureReason, failureMessage = getReadyConditionFailure(unstructuredInfraCluster)
+	}
+
+	if failureReason == "" && failureMessage == "" {
+		// The InfraCluster is still being provisioned.
+		return nil, nil //nolint:nilnil
+	}
+
+	if failureReason == "" {
+		failureReason = ReasonInfraClusterNotReady
+	}
+
+	return &infraClusterFailure{
+		reason: failureReason,
+		message: fmt.Sprintf("InfraCluster '%s/%s' is not ready: %s",
+			infraCluster.GetNamespace(), infraCluster.GetName(), failureMessage),
+	}, nil
+}
+
+// getReadyConditionFailure returns the reason and message of the Ready condition of an unstructured InfraCluster
+// when it is false with an error severity.
+func getReadyConditionFailure(unstructuredInfraCluster map[string]interface{}) (string, string) {
+	conditions, _, _ := unstructured.NestedSlice(unstructuredInfraCluster, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != string(clusterv1.ReadyCondition) || condition["status"] != string(corev1.ConditionFalse) ||
+			condition["severity"] != string(clusterv1.ConditionSeverityError) {
+			continue
+		}
+
+		reason, _ := condition["reason"].(string)
+		message, _ := condition["message"].(string)
+
+		return reason, message
+	}
+
+	return "", ""
+}
+
 // getRawMAPIProviderSpec returns a raw Machine ProviderSpec from the the cluster.
 func getRawMAPIProviderSpec(ctx context.Context, cl client.Client) ([]byte, error) {
 	cpms, err := getActiveCPMS(ctx, cl)
@@ -331,6 +710,27 @@ func getRawMAPIProviderSpec(ctx context.Context, cl client.Client) ([]byte, erro
 	return cpms.Spec.Template.OpenShiftMachineV1Beta1Machine.Spec.ProviderSpec.Value.Raw, nil
 }
 
+// getCloudConfig returns the cloud provider config referenced by the infrastructure.
+// An empty config is returned when the infrastructure does not reference one.
+func (r *InfraClusterController) getCloudConfig(ctx context.Context) (string, error) {
+	cloudConfigRef := r.Infra.Spec.CloudConfig
+	if cloudConfigRef.Name == "" {
+		return "", nil
+	}
+
+	cloudConfig := &corev1.ConfigMap{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: openshiftConfigNamespace, Name: cloudConfigRef.Name}, cloudConfig); err != nil {
+		return "", fmt.Errorf("unable to get the cloud provider config %s/%s: %w", openshiftConfigNamespace, cloudConfigRef.Name, err)
+	}
+
+	key := cloudConfigRef.Key
+	if key == "" {
+		key = defaultCloudConfigKey
+	}
+
+	return cloudConfig.Data[key], nil
+}
+
 // getActiveCPMS returns the CPMS if it exists and it is in Active state, otherwise returns nil.
 func getActiveCPMS(ctx context.Context, cl client.Client) (*mapiv1.ControlPlaneMachineSet, error) {
 	cpms := &mapiv1.ControlPlaneMachineSet{}
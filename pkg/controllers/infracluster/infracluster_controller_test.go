@@ -22,7 +22,9 @@ import (
 	. "github.com/onsi/gomega"
 
 	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/cluster-capi-operator/pkg/controllers"
 	"github.com/openshift/cluster-capi-operator/pkg/operatorstatus"
+	"github.com/openshift/cluster-capi-operator/pkg/util"
 
 	"github.com/openshift/cluster-api-actuator-pkg/testutils"
 	configv1resourcebuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/config/v1"
@@ -48,7 +50,12 @@ var _ = Describe("InfraCluster", func() {
 	var bareInfraCluster *awsv1.AWSCluster
 
 	ocpInfraClusterName := "test-infra-cluster-name"
-	ocpInfraAWS := configv1resourcebuilder.Infrastructure().AsAWS(ocpInfraClusterName, awsTestRegion).Build()
+	ocpInfraAWS := configv1resourcebuilder.Infrastructure().AsAWS(ocpInfraClusterName, awsTestRegion).
+		WithName(controllers.InfrastructureResourceName).Build()
+	ocpInfraAWS.Status.PlatformStatus.AWS.ResourceTags = []configv1.AWSResourceTag{
+		{Key: "environment", Value: "test"},
+		{Key: "owner", Value: "infra-team"},
+	}
 
 	infraClusterWithExternallyManagedByAnnotation := &awsv1.AWSCluster{
 		ObjectMeta: metav1.ObjectMeta{
@@ -90,6 +97,11 @@ var _ = Describe("InfraCluster", func() {
 		}
 		// Create ClusterOperator.
 		Expect(cl.Create(ctx, configv1resourcebuilder.ClusterOperator().WithName(clusterOperatorName).Build())).To(Succeed())
+		// Create the Infrastructure, which is read by the controller on every reconcile.
+		infra := ocpInfraAWS.DeepCopy()
+		Expect(cl.Create(ctx, infra)).To(Succeed())
+		infra.Status = ocpInfraAWS.Status
+		Expect(cl.Status().Update(ctx, infra)).To(Succeed())
 		// Create CAPI Namespace.
 		Expect(cl.Create(ctx, corev1resourcebuilder.Namespace().WithName(defaultCAPINamespace).Build())).To(Succeed())
 		// Setup and Start Manager.
@@ -103,6 +115,7 @@ var _ = Describe("InfraCluster", func() {
 		stopManager(mgrCancel, mgrDone)
 		// Cleanup Resources.
 		testutils.CleanupResources(Default, ctx, cfg, cl, "", &configv1.ClusterOperator{})
+		testutils.CleanupResources(Default, ctx, cfg, cl, "", &configv1.Infrastructure{})
 		testutils.CleanupResources(Default, ctx, cfg, cl, defaultCAPINamespace, &awsv1.AWSCluster{})
 	})
 
@@ -113,6 +126,33 @@ var _ = Describe("InfraCluster", func() {
 				HaveField("Annotations", HaveKeyWithValue(clusterv1.ManagedByAnnotation, managedByAnnotationValueClusterCAPIOperatorInfraClusterController)),
 			))
 		})
+
+		It("should create an InfraCluster with the Infrastructure resource tags", func() {
+			Eventually(komega.Object(bareInfraCluster)).Should(
+				HaveField("Spec.AdditionalTags", Equal(awsv1.Tags{"environment": "test", "owner": "infra-team"})),
+			)
+		})
+
+		It("should update the InfraCluster control plane endpoint when the Infrastructure internal API changes", func() {
+			Eventually(komega.Object(bareInfraCluster)).Should(
+				HaveField("Spec.ControlPlaneEndpoint", Equal(clusterv1.APIEndpoint{Host: "api-int.test-cluster.test-domain", Port: 6443})),
+			)
+
+			infra := &configv1.Infrastructure{ObjectMeta: metav1.ObjectMeta{Name: controllers.InfrastructureResourceName}}
+			Eventually(komega.UpdateStatus(infra, func() {
+				infra.Status.APIServerInternalURL = "https://api-int.new-test-cluster.test-domain:6443"
+			})).Should(Succeed())
+
+			Eventually(komega.Object(bareInfraCluster)).Should(
+				HaveField("Spec.ControlPlaneEndpoint", Equal(clusterv1.APIEndpoint{Host: "api-int.new-test-cluster.test-domain", Port: 6443})),
+			)
+		})
+
+		It("should protect the InfraCluster from deletion", func() {
+			Eventually(komega.Object(bareInfraCluster)).Should(
+				HaveField("Finalizers", ContainElement(util.DeletionProtectionFinalizer)),
+			)
+		})
 	})
 
 	Context("When there is an InfraCluster with no externally ManagedBy Annotation", func() {
@@ -156,6 +196,23 @@ var _ = Describe("InfraCluster", func() {
 					HaveField("Annotations", HaveKeyWithValue(clusterv1.ManagedByAnnotation, thirdPartyAnnotation)),
 				))
 			})
+
+			It("should not change the Spec.AdditionalTags field", func() {
+				Consistently(komega.Object(bareInfraCluster)).Should(
+					HaveField("Spec.AdditionalTags", BeEmpty()),
+				)
+			})
+
+			It("should report the InfraCluster as managed externally on the ClusterOperator", func() {
+				co := configv1resourcebuilder.ClusterOperator().WithName(clusterOperatorName).Build()
+				Eventually(komega.Object(co)).Should(
+					HaveField("Status.Conditions", ContainElement(SatisfyAll(
+						HaveField("Type", Equal(configv1.ClusterStatusConditionType(InfraClusterControllerAvailableCondition))),
+						HaveField("Status", Equal(configv1.ConditionTrue)),
+						HaveField("Reason", Equal(ReasonManagedExternally)),
+					))),
+				)
+			})
 		})
 		Context("When the InfraCluster is not Ready", func() {
 			BeforeEach(func() {
@@ -199,9 +256,55 @@ var _ = Describe("InfraCluster", func() {
 				))
 			})
 		})
+		Context("When the InfraCluster has user added tags", func() {
+			BeforeEach(func() {
+				infraCluster := infraClusterWithExternallyManagedByAnnotation.DeepCopy()
+				infraCluster.Spec.AdditionalTags = awsv1.Tags{"user-tag": "user-value", "owner": "someone-else"}
+				Expect(cl.Create(ctx, infraCluster)).To(Succeed())
+			})
+
+			It("should keep the user added tags and favour the Infrastructure resource tags on conflicts", func() {
+				Eventually(komega.Object(bareInfraCluster)).Should(
+					HaveField("Spec.AdditionalTags", Equal(awsv1.Tags{"environment": "test", "owner": "infra-team", "user-tag": "user-value"})),
+				)
+			})
+		})
+		Context("When a tag was removed from the Infrastructure resource tags", func() {
+			BeforeEach(func() {
+				infraCluster := infraClusterWithExternallyManagedByAnnotation.DeepCopy()
+				infraCluster.Annotations[util.ManagedTagsAnnotation] = "environment,owner,removed-tag"
+				infraCluster.Spec.AdditionalTags = awsv1.Tags{"environment": "test", "owner": "infra-team", "removed-tag": "value", "user-tag": "user-value"}
+				Expect(cl.Create(ctx, infraCluster)).To(Succeed())
+			})
+
+			It("should remove the tag and keep the user added tags", func() {
+				Eventually(komega.Object(bareInfraCluster)).Should(SatisfyAll(
+					HaveField("Spec.AdditionalTags", Equal(awsv1.Tags{"environment": "test", "owner": "infra-team", "user-tag": "user-value"})),
+					HaveField("Annotations", HaveKeyWithValue(util.ManagedTagsAnnotation, "environment,owner")),
+				))
+			})
+		})
 	})
 })
 
+var _ = DescribeTable("getInfraClusterOwnership",
+	func(annotations map[string]string, expectedReason string) {
+		infraCluster := &awsv1.AWSCluster{ObjectMeta: metav1.ObjectMeta{
+			Name:        "test-infra-cluster-name",
+			Namespace:   defaultCAPINamespace,
+			Annotations: annotations,
+		}}
+
+		Expect(getInfraClusterOwnership(infraCluster).reason).To(Equal(expectedReason))
+	},
+	Entry("without the managed-by annotation", nil, ReasonManagedByInfrastructureProvider),
+	Entry("with the managed-by annotation of this controller",
+		map[string]string{clusterv1.ManagedByAnnotation: managedByAnnotationValueClusterCAPIOperatorInfraClusterController}, ReasonManagedByInfraClusterController),
+	Entry("with the managed-by annotation of another entity",
+		map[string]string{clusterv1.ManagedByAnnotation: "thirdparty"}, ReasonManagedExternally),
+	Entry("with an empty managed-by annotation", map[string]string{clusterv1.ManagedByAnnotation: ""}, ReasonManagedExternally),
+)
+
 func mustPatchAWSInfraClusterReadiness(awsInfraCluster *awsv1.AWSCluster, readiness bool) {
 	Eventually(komega.UpdateStatus(awsInfraCluster, func() {
 		awsInfraCluster.Status.Ready = readiness
@@ -229,7 +332,8 @@ func startManager(mgrCtx context.Context, mgrDone chan struct{}, ocpInfra *confi
 
 	r := &InfraClusterController{
 		ClusterOperatorStatusClient: operatorstatus.ClusterOperatorStatusClient{
-			Client: cl,
+			Client:   cl,
+			Recorder: mgr.GetEventRecorderFor("cluster-capi-operator-infracluster-controller"),
 		},
 		Infra:    ocpInfra,
 		Platform: ocpInfra.Status.PlatformStatus.Type,
@@ -254,3 +358,49 @@ func stopManager(mgrCancel context.CancelFunc, mgrDone chan struct{}) {
 	// Wait for the mgrDone to be closed, which will happen once the mgr has stopped
 	<-mgrDone
 }
+
+var _ = DescribeTable("getInfraClusterFailure",
+	func(status map[string]interface{}, expectedFailure *infraClusterFailure) {
+		infraCluster := NewMetal3Cluster()
+		infraCluster.SetName("test-infra-cluster-name")
+		infraCluster.SetNamespace(defaultCAPINamespace)
+		infraCluster.Object["status"] = status
+
+		failure, err := getInfraClusterFailure(infraCluster)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(failure).To(Equal(expectedFailure))
+	},
+	Entry("when the InfraCluster is ready", map[string]interface{}{
+		"ready":         true,
+		"failureReason": "CreateError",
+	}, nil),
+	Entry("when the InfraCluster is being provisioned", map[string]interface{}{
+		"ready": false,
+		"conditions": []interface{}{
+			map[string]interface{}{"type": "Ready", "status": "False", "severity": "Info", "reason": "Provisioning"},
+		},
+	}, nil),
+	Entry("when the InfraCluster has a terminal failure", map[string]interface{}{
+		"ready":          false,
+		"failureReason":  "CreateError",
+		"failureMessage": "invalid credentials",
+	}, &infraClusterFailure{
+		reason:  "CreateError",
+		message: "InfraCluster 'openshift-cluster-api/test-infra-cluster-name' is not ready: invalid credentials",
+	}),
+	Entry("when the InfraCluster has a terminal failure without a reason", map[string]interface{}{
+		"failureMessage": "invalid credentials",
+	}, &infraClusterFailure{
+		reason:  ReasonInfraClusterNotReady,
+		message: "InfraCluster 'openshift-cluster-api/test-infra-cluster-name' is not ready: invalid credentials",
+	}),
+	Entry("when the InfraCluster Ready condition has an error severity", map[string]interface{}{
+		"ready": false,
+		"conditions": []interface{}{
+			map[string]interface{}{"type": "Ready", "status": "False", "severity": "Error", "reason": "NetworkNotFound", "message": "network not found"},
+		},
+	}, &infraClusterFailure{
+		reason:  "NetworkNotFound",
+		message: "InfraCluster 'openshift-cluster-api/test-infra-cluster-name' is not ready: network not found",
+	}),
+)
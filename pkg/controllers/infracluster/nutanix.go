@@ -0,0 +1,213 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package infracluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	configv1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	cerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	nutanixCredentialsName = "nutanix-credentials" //nolint:gosec
+	nutanixCredentialsKey  = "credentials"
+)
+
+var (
+	// NutanixClusterGroupVersionKind is the GroupVersionKind of the CAPX NutanixCluster.
+	// The CAPX API is not vendored, so NutanixClusters are handled as unstructured objects.
+	// Only the NutanixCluster and the Prism Central credentials are managed: the MAPI Machines cannot be converted to
+	// NutanixMachines until the APIs are vendored, see the TODO in the sync controllers.
+	NutanixClusterGroupVersionKind = schema.GroupVersionKind{
+		Group:   "infrastructure.cluster.x-k8s.io",
+		Version: "v1beta1",
+		Kind:    "NutanixCluster",
+	}
+
+	errUnableToFindNutanixPrismCentral      = errors.New("unable to find the Nutanix Prism Central endpoint in the infrastructure platform spec")
+	errUnableToFindCredentialsNutanixSecret = errors.New("unable to find credentials in the Nutanix credentials secret")
+)
+
+// NewNutanixCluster returns an empty unstructured NutanixCluster.
+func NewNutanixCluster() *unstructured.Unstructured {
+	nutanixCluster := &unstructured.Unstructured{}
+	nutanixCluster.SetGroupVersionKind(NutanixClusterGroupVersionKind)
+
+	return nutanixCluster
+}
+
+// ensureNutanixCluster ensures the NutanixCluster cluster object exists.
+func (r *InfraClusterController) ensureNutanixCluster(ctx context.Context, log logr.Logger) (client.Object, error) {
+	prismCentral, err := getNutanixPrismCentral(r.Infra)
+	if err != nil {
+		return nil, fmt.Errorf("error obtaining Nutanix Prism Central endpoint: %w", err)
+	}
+
+	// First make sure the CAPI Nutanix credentials secret exists.
+	if err := r.ensureNutanixSecret(ctx); err != nil {
+		return nil, fmt.Errorf("unable to ensure CAPI Nutanix credentials secret: %w", err)
+	}
+
+	target := NewNutanixCluster()
+	target.SetName(r.Infra.Status.InfrastructureName)
+	target.SetNamespace(defaultCAPINamespace)
+
+	// Checking whether InfraCluster object exists. If it doesn't, create it.
+	if err := r.Get(ctx, client.ObjectKeyFromObject(target), target); err != nil && !cerrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get InfraCluster: %w", err)
+	} else if err == nil {
+		if err := r.ensureNutanixClusterPrismCentral(ctx, log, target, prismCentral); err != nil {
+			return nil, fmt.Errorf("failed to ensure NutanixCluster Prism Central: %w", err)
+		}
+
+		return target, nil
+	}
+
+	log.Info(fmt.Sprintf("NutanixCluster %s/%s does not exist, creating it", target.GetNamespace(), target.GetName()))
+
+	apiURL, err := url.Parse(r.Infra.Status.APIServerInternalURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse apiUrl: %w", err)
+	}
+
+	port, err := strconv.ParseInt(apiURL.Port(), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse apiUrl port: %w", err)
+	}
+
+	target.SetAnnotations(map[string]string{
+		// The ManagedBy Annotation is set so CAPI infra providers ignore the InfraCluster object,
+		// as that's managed externally, in this case by this controller.
+		clusterv1.ManagedByAnnotation: managedByAnnotationValueClusterCAPIOperatorInfraClusterController,
+	})
+
+	target.Object["spec"] = map[string]interface{}{
+		"controlPlaneEndpoint": map[string]interface{}{
+			"host": apiURL.Hostname(),
+			"port": port,
+		},
+		"prismCentral": nutanixPrismCentralSpec(prismCentral, r.Infra.Status.InfrastructureName),
+	}
+
+	if err := r.Create(ctx, target); err != nil {
+		return nil, fmt.Errorf("failed to create InfraCluster: %w", err)
+	}
+
+	log.Info(fmt.Sprintf("InfraCluster '%s/%s' successfully created", defaultCAPINamespace, r.Infra.Status.InfrastructureName))
+	r.recordInfraClusterCreated(ctx)
+
+	return target, nil
+}
+
+// ensureNutanixClusterPrismCentral keeps the Prism Central endpoint of a NutanixCluster managed by this controller
+// in sync with the Infrastructure platform spec, which is authoritative.
+func (r *InfraClusterController) ensureNutanixClusterPrismCentral(ctx context.Context, log logr.Logger, nutanixCluster *unstructured.Unstructured, prismCentral configv1.NutanixPrismEndpoint) error {
+	if nutanixCluster.GetAnnotations()[clusterv1.ManagedByAnnotation] != managedByAnnotationValueClusterCAPIOperatorInfraClusterController {
+		// Only the NutanixCluster created by this controller is kept in sync.
+		return nil
+	}
+
+	desiredPrismCentral := nutanixPrismCentralSpec(prismCentral, r.Infra.Status.InfrastructureName)
+
+	currentPrismCentral, _, err := unstructured.NestedMap(nutanixCluster.Object, "spec", "prismCentral")
+	if err != nil {
+		return fmt.Errorf("unable to read NutanixCluster prismCentral: %w", err)
+	}
+
+	if equality.Semantic.DeepEqual(desiredPrismCentral, currentPrismCentral) {
+		return nil
+	}
+
+	patchBase := client.MergeFrom(nutanixCluster.DeepCopy())
+
+	if err := unstructured.SetNestedMap(nutanixCluster.Object, desiredPrismCentral, "spec", "prismCentral"); err != nil {
+		return fmt.Errorf("unable to set NutanixCluster prismCentral: %w", err)
+	}
+
+	if err := r.Patch(ctx, nutanixCluster, patchBase); err != nil {
+		return fmt.Errorf("failed to patch NutanixCluster prismCentral: %w", err)
+	}
+
+	log.Info(fmt.Sprintf("NutanixCluster '%s/%s' prismCentral successfully updated", nutanixCluster.GetNamespace(), nutanixCluster.GetName()))
+
+	return nil
+}
+
+// nutanixPrismCentralSpec returns the NutanixCluster prismCentral for the given endpoint,
+// referencing the CAPI Nutanix credentials secret.
+func nutanixPrismCentralSpec(prismCentral configv1.NutanixPrismEndpoint, credentialsSecretName string) map[string]interface{} {
+	return map[string]interface{}{
+		"address": prismCentral.Address,
+		"port":    int64(prismCentral.Port),
+		"credentialRef": map[string]interface{}{
+			"kind":      "Secret",
+			"name":      credentialsSecretName,
+			"namespace": defaultCAPINamespace,
+		},
+	}
+}
+
+// getNutanixPrismCentral returns the Prism Central endpoint from the infrastructure platform spec.
+func getNutanixPrismCentral(infra *configv1.Infrastructure) (configv1.NutanixPrismEndpoint, error) {
+	if infra.Spec.PlatformSpec.Nutanix == nil || infra.Spec.PlatformSpec.Nutanix.PrismCentral.Address == "" {
+		return configv1.NutanixPrismEndpoint{}, errUnableToFindNutanixPrismCentral
+	}
+
+	return infra.Spec.PlatformSpec.Nutanix.PrismCentral, nil
+}
+
+// ensureNutanixSecret ensures the CAPI Nutanix credentials secret exists and holds the current credentials.
+// The MAPI credentials secret already uses the credentials format CAPX expects, so it is copied as is.
+func (r *InfraClusterController) ensureNutanixSecret(ctx context.Context) error {
+	nutanixCredentialsSecret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Namespace: defaultMAPINamespace, Name: nutanixCredentialsName}, nutanixCredentialsSecret); err != nil {
+		return fmt.Errorf("unable to get the Nutanix credentials secret %s/%s: %w", defaultMAPINamespace, nutanixCredentialsName, err)
+	}
+
+	credentials, ok := nutanixCredentialsSecret.Data[nutanixCredentialsKey]
+	if !ok {
+		return fmt.Errorf("%w %s/%s", errUnableToFindCredentialsNutanixSecret, defaultMAPINamespace, nutanixCredentialsName)
+	}
+
+	nutanixSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      r.Infra.Status.InfrastructureName,
+			Namespace: defaultCAPINamespace,
+		},
+		Data: map[string][]byte{
+			nutanixCredentialsKey: credentials,
+		},
+	}
+
+	if err := r.syncCredentialsSecret(ctx, nutanixSecret); err != nil {
+		return fmt.Errorf("unable to sync CAPI Nutanix credentials secret: %w", err)
+	}
+
+	return nil
+}
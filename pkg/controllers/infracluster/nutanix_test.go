@@ -0,0 +1,55 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package infracluster
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+var _ = DescribeTable("getNutanixPrismCentral",
+	func(platformSpec configv1.PlatformSpec, expectedPrismCentral configv1.NutanixPrismEndpoint, expectedErr error) {
+		prismCentral, err := getNutanixPrismCentral(&configv1.Infrastructure{Spec: configv1.InfrastructureSpec{PlatformSpec: platformSpec}})
+		if expectedErr != nil {
+			Expect(err).To(MatchError(expectedErr))
+			return
+		}
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(prismCentral).To(Equal(expectedPrismCentral))
+	},
+	Entry("without a Nutanix platform spec", configv1.PlatformSpec{Type: configv1.NutanixPlatformType}, configv1.NutanixPrismEndpoint{}, errUnableToFindNutanixPrismCentral),
+	Entry("without a Prism Central address", configv1.PlatformSpec{Nutanix: &configv1.NutanixPlatformSpec{}}, configv1.NutanixPrismEndpoint{}, errUnableToFindNutanixPrismCentral),
+	Entry("with a Prism Central endpoint", configv1.PlatformSpec{Nutanix: &configv1.NutanixPlatformSpec{
+		PrismCentral: configv1.NutanixPrismEndpoint{Address: "prism-central.example.com", Port: 9440},
+	}}, configv1.NutanixPrismEndpoint{Address: "prism-central.example.com", Port: 9440}, nil),
+)
+
+var _ = Describe("nutanixPrismCentralSpec", func() {
+	It("should reference the CAPI Nutanix credentials secret", func() {
+		Expect(nutanixPrismCentralSpec(configv1.NutanixPrismEndpoint{Address: "prism-central.example.com", Port: 9440}, "test-infra")).To(Equal(map[string]interface{}{
+			"address": "prism-central.example.com",
+			"port":    int64(9440),
+			"credentialRef": map[string]interface{}{
+				"kind":      "Secret",
+				"name":      "test-infra",
+				"namespace": "openshift-cluster-api",
+			},
+		}))
+	})
+})
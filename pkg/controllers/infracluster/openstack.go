@@ -0,0 +1,195 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package infracluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/go-logr/logr"
+	configv1 "github.com/openshift/api/config/v1"
+	"gopkg.in/ini.v1"
+	cerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	openstackv1 "sigs.k8s.io/cluster-api-provider-openstack/api/v1beta1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// openStackCredentialsSecretName is the clouds.yaml secret created by the cloud credential operator for CAPO.
+	openStackCredentialsSecretName = "openstack-cloud-credentials" //nolint:gosec
+
+	// defaultOpenStackCloudName is the cloud of the clouds.yaml used when the infrastructure does not specify one.
+	defaultOpenStackCloudName = "openstack"
+)
+
+var errOpenStackPlatformStatusMissing = errors.New("infrastructure PlatformStatus.OpenStack should not be nil")
+
+// ensureOpenStackCluster ensures the OpenStackCluster cluster object exists.
+func (r *InfraClusterController) ensureOpenStackCluster(ctx context.Context, log logr.Logger) (client.Object, error) {
+	target := &openstackv1.OpenStackCluster{ObjectMeta: metav1.ObjectMeta{
+		Name:      r.Infra.Status.InfrastructureName,
+		Namespace: defaultCAPINamespace,
+	}}
+
+	// Checking whether InfraCluster object exists. If it doesn't, create it.
+	if err := r.Get(ctx, client.ObjectKeyFromObject(target), target); err != nil && !cerrors.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to get InfraCluster: %w", err)
+	} else if err == nil {
+		return target, nil
+	}
+
+	log.Info(fmt.Sprintf("OpenStackCluster %s/%s does not exist, creating it", target.Namespace, target.Name))
+
+	cloudConfig, err := r.getCloudConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error obtaining OpenStack cloud provider config: %w", err)
+	}
+
+	externalNetworkID, err := openStackExternalNetworkFromCloudConfig(cloudConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error obtaining OpenStack external network: %w", err)
+	}
+
+	spec, err := openStackClusterSpecFromInfra(r.Infra, externalNetworkID, r.discoverOpenStackAPIServerAddrs(ctx, log))
+	if err != nil {
+		return nil, err
+	}
+
+	target = &openstackv1.OpenStackCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      r.Infra.Status.InfrastructureName,
+			Namespace: defaultCAPINamespace,
+			// The ManagedBy Annotation is set so CAPI infra providers ignore the InfraCluster object,
+			// as that's managed externally, in this case by this controller.
+			Annotations: map[string]string{
+				clusterv1.ManagedByAnnotation: managedByAnnotationValueClusterCAPIOperatorInfraClusterController,
+			},
+		},
+		Spec: spec,
+	}
+
+	if err := r.Create(ctx, target); err != nil {
+		return nil, fmt.Errorf("failed to create InfraCluster: %w", err)
+	}
+
+	log.Info(fmt.Sprintf("InfraCluster '%s/%s' successfully created", defaultCAPINamespace, r.Infra.Status.InfrastructureName))
+	r.recordInfraClusterCreated(ctx)
+
+	return target, nil
+}
+
+// openStackClusterSpecFromInfra builds the OpenStackCluster spec describing the existing cluster.
+// The API VIP is managed by the cluster itself, so CAPO is given its fixed IP, and the floating IP the installer
+// attached to it when the external API resolves to one. Without an external network, CAPO must not look for one.
+func openStackClusterSpecFromInfra(infra *configv1.Infrastructure, externalNetworkID string, apiServerAddrs []string) (openstackv1.OpenStackClusterSpec, error) {
+	if infra.Status.PlatformStatus == nil || infra.Status.PlatformStatus.OpenStack == nil {
+		return openstackv1.OpenStackClusterSpec{}, errOpenStackPlatformStatusMissing
+	}
+
+	apiURL, err := url.Parse(infra.Status.APIServerInternalURL)
+	if err != nil {
+		return openstackv1.OpenStackClusterSpec{}, fmt.Errorf("failed to parse apiURL: %w", err)
+	}
+
+	port, err := strconv.ParseInt(apiURL.Port(), 10, 32)
+	if err != nil {
+		return openstackv1.OpenStackClusterSpec{}, fmt.Errorf("failed to parse apiURL port: %w", err)
+	}
+
+	platformStatus := infra.Status.PlatformStatus.OpenStack
+
+	cloudName := platformStatus.CloudName
+	if cloudName == "" {
+		cloudName = defaultOpenStackCloudName
+	}
+
+	spec := openstackv1.OpenStackClusterSpec{
+		IdentityRef: openstackv1.OpenStackIdentityReference{
+			Name:      openStackCredentialsSecretName,
+			CloudName: cloudName,
+		},
+		ControlPlaneEndpoint: &clusterv1.APIEndpoint{
+			Host: apiURL.Hostname(),
+			Port: int32(port),
+		},
+	}
+
+	if len(platformStatus.APIServerInternalIPs) > 0 {
+		spec.APIServerFixedIP = ptr.To(platformStatus.APIServerInternalIPs[0])
+	}
+
+	if externalNetworkID == "" {
+		spec.DisableExternalNetwork = ptr.To(true)
+	} else {
+		spec.ExternalNetwork = &openstackv1.NetworkParam{ID: ptr.To(externalNetworkID)}
+	}
+
+	if floatingIP := openStackAPIServerFloatingIP(apiServerAddrs, platformStatus.APIServerInternalIPs); floatingIP != "" && externalNetworkID != "" {
+		spec.APIServerFloatingIP = ptr.To(floatingIP)
+	} else {
+		spec.DisableAPIServerFloatingIP = ptr.To(true)
+	}
+
+	return spec, nil
+}
+
+// discoverOpenStackAPIServerAddrs resolves the external API of the cluster.
+// Failing to resolve it is not an error, the cluster is then assumed to have no API floating IP.
+func (r *InfraClusterController) discoverOpenStackAPIServerAddrs(ctx context.Context, log logr.Logger) []string {
+	apiURL, err := url.Parse(r.Infra.Status.APIServerURL)
+	if err != nil || apiURL.Hostname() == "" {
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, apiURL.Hostname())
+	if err != nil {
+		log.Info("Unable to resolve the external API, assuming it has no floating IP", "host", apiURL.Hostname(), "error", err.Error())
+		return nil
+	}
+
+	return addrs
+}
+
+// openStackAPIServerFloatingIP returns the address the external API resolves to when it is not one of the internal
+// API addresses, in which case it is the floating IP attached to the API VIP.
+func openStackAPIServerFloatingIP(apiServerAddrs, apiServerInternalIPs []string) string {
+	for _, addr := range apiServerAddrs {
+		if net.ParseIP(addr) != nil && !slices.Contains(apiServerInternalIPs, addr) {
+			return addr
+		}
+	}
+
+	return ""
+}
+
+// openStackExternalNetworkFromCloudConfig returns the ID of the external network from the OpenStack cloud provider config,
+// which is the network the floating IPs of the cluster are allocated from.
+func openStackExternalNetworkFromCloudConfig(cloudConfig string) (string, error) {
+	iniConfig, err := ini.Load([]byte(cloudConfig))
+	if err != nil {
+		return "", fmt.Errorf("unable to parse the OpenStack cloud provider config: %w", err)
+	}
+
+	return iniConfig.Section("LoadBalancer").Key("floating-network-id").String(), nil
+}
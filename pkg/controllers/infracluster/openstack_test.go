@@ -0,0 +1,92 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package infracluster
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"k8s.io/utils/ptr"
+	openstackv1 "sigs.k8s.io/cluster-api-provider-openstack/api/v1beta1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+var _ = DescribeTable("openStackClusterSpecFromInfra",
+	func(platformStatus *configv1.PlatformStatus, externalNetworkID string, apiServerAddrs []string, expectedSpec openstackv1.OpenStackClusterSpec, expectedErr error) {
+		spec, err := openStackClusterSpecFromInfra(&configv1.Infrastructure{Status: configv1.InfrastructureStatus{
+			APIServerInternalURL: "https://api-int.test.example.com:6443",
+			PlatformStatus:       platformStatus,
+		}}, externalNetworkID, apiServerAddrs)
+		if expectedErr != nil {
+			Expect(err).To(MatchError(expectedErr))
+			return
+		}
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(spec).To(Equal(expectedSpec))
+	},
+	Entry("without an OpenStack platform status", &configv1.PlatformStatus{Type: configv1.OpenStackPlatformType}, "", nil,
+		openstackv1.OpenStackClusterSpec{}, errOpenStackPlatformStatusMissing),
+	Entry("without an external network", &configv1.PlatformStatus{OpenStack: &configv1.OpenStackPlatformStatus{
+		APIServerInternalIPs: []string{"10.0.0.5"},
+	}}, "", []string{"10.0.0.5"}, openstackv1.OpenStackClusterSpec{
+		IdentityRef:                openstackv1.OpenStackIdentityReference{Name: "openstack-cloud-credentials", CloudName: "openstack"},
+		ControlPlaneEndpoint:       &clusterv1.APIEndpoint{Host: "api-int.test.example.com", Port: 6443},
+		APIServerFixedIP:           ptr.To("10.0.0.5"),
+		DisableExternalNetwork:     ptr.To(true),
+		DisableAPIServerFloatingIP: ptr.To(true),
+	}, nil),
+	Entry("with an external network and an API floating IP", &configv1.PlatformStatus{OpenStack: &configv1.OpenStackPlatformStatus{
+		CloudName:            "test-cloud",
+		APIServerInternalIPs: []string{"10.0.0.5"},
+	}}, "test-external-network", []string{"203.0.113.10"}, openstackv1.OpenStackClusterSpec{
+		IdentityRef:          openstackv1.OpenStackIdentityReference{Name: "openstack-cloud-credentials", CloudName: "test-cloud"},
+		ControlPlaneEndpoint: &clusterv1.APIEndpoint{Host: "api-int.test.example.com", Port: 6443},
+		APIServerFixedIP:     ptr.To("10.0.0.5"),
+		ExternalNetwork:      &openstackv1.NetworkParam{ID: ptr.To("test-external-network")},
+		APIServerFloatingIP:  ptr.To("203.0.113.10"),
+	}, nil),
+	Entry("with an external network and an API resolving to the VIP", &configv1.PlatformStatus{OpenStack: &configv1.OpenStackPlatformStatus{
+		APIServerInternalIPs: []string{"10.0.0.5"},
+	}}, "test-external-network", []string{"10.0.0.5"}, openstackv1.OpenStackClusterSpec{
+		IdentityRef:                openstackv1.OpenStackIdentityReference{Name: "openstack-cloud-credentials", CloudName: "openstack"},
+		ControlPlaneEndpoint:       &clusterv1.APIEndpoint{Host: "api-int.test.example.com", Port: 6443},
+		APIServerFixedIP:           ptr.To("10.0.0.5"),
+		ExternalNetwork:            &openstackv1.NetworkParam{ID: ptr.To("test-external-network")},
+		DisableAPIServerFloatingIP: ptr.To(true),
+	}, nil),
+)
+
+var _ = DescribeTable("openStackExternalNetworkFromCloudConfig",
+	func(cloudConfig, expectedExternalNetworkID string) {
+		externalNetworkID, err := openStackExternalNetworkFromCloudConfig(cloudConfig)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(externalNetworkID).To(Equal(expectedExternalNetworkID))
+	},
+	Entry("with an empty cloud config", "", ""),
+	Entry("without a load balancer section", `[Global]
+secret-name = openstack-credentials
+secret-namespace = kube-system
+`, ""),
+	Entry("with a floating network", `[Global]
+secret-name = openstack-credentials
+secret-namespace = kube-system
+
+[LoadBalancer]
+floating-network-id = 5bd5b3fa-1e65-4a2b-8b5d-3bb0b0b1a3c2
+`, "5bd5b3fa-1e65-4a2b-8b5d-3bb0b0b1a3c2"),
+)
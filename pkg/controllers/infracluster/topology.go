@@ -0,0 +1,146 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package infracluster
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	configv1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// reasonInfraClusterTopologyUpdated is the reason of the event recorded when the InfraCluster spec is updated
+	// to follow the infrastructure platform status.
+	reasonInfraClusterTopologyUpdated = "InfraClusterTopologyUpdated"
+)
+
+// topologyField is a field of the InfraCluster spec describing the infrastructure topology.
+type topologyField struct {
+	// path is the path of the field in the InfraCluster.
+	path []string
+	// value is the value of the field according to the infrastructure platform status.
+	value string
+}
+
+// infraClusterTopology returns the InfraCluster fields which follow the infrastructure platform status.
+// Fields the platform status does not report are left alone, as they were devised from other sources at creation.
+func infraClusterTopology(infra *configv1.Infrastructure) []topologyField {
+	platformStatus := infra.Status.PlatformStatus
+	if platformStatus == nil {
+		return nil
+	}
+
+	var fields []topologyField
+
+	switch {
+	case platformStatus.AWS != nil:
+		fields = []topologyField{
+			{path: []string{"spec", "region"}, value: platformStatus.AWS.Region},
+		}
+	case platformStatus.GCP != nil:
+		fields = []topologyField{
+			{path: []string{"spec", "region"}, value: platformStatus.GCP.Region},
+			{path: []string{"spec", "project"}, value: platformStatus.GCP.ProjectID},
+		}
+	case platformStatus.Azure != nil:
+		networkResourceGroupName := platformStatus.Azure.NetworkResourceGroupName
+		if networkResourceGroupName == "" {
+			networkResourceGroupName = platformStatus.Azure.ResourceGroupName
+		}
+
+		fields = []topologyField{
+			{path: []string{"spec", "resourceGroup"}, value: platformStatus.Azure.ResourceGroupName},
+			{path: []string{"spec", "networkSpec", "vnet", "resourceGroup"}, value: networkResourceGroupName},
+		}
+	}
+
+	return fields
+}
+
+// ensureInfraClusterTopology keeps the topology of the InfraCluster in sync with the infrastructure platform status,
+// so that day-2 changes to the infrastructure are reflected on the InfraCluster.
+// An event describing the changes is recorded on the InfraCluster.
+func (r *InfraClusterController) ensureInfraClusterTopology(ctx context.Context, log logr.Logger, infraCluster client.Object) error {
+	unstructuredInfraCluster, err := runtime.DefaultUnstructuredConverter.ToUnstructured(infraCluster)
+	if err != nil {
+		return fmt.Errorf("unable to convert to unstructured: %w", err)
+	}
+
+	changes, err := applyInfraClusterTopology(unstructuredInfraCluster, infraClusterTopology(r.Infra))
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	infraClusterPatchCopy, ok := infraCluster.DeepCopyObject().(client.Object)
+	if !ok {
+		return errCouldNotDeepCopyInfraObject
+	}
+
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(unstructuredInfraCluster, infraCluster); err != nil {
+		return fmt.Errorf("unable to convert from unstructured: %w", err)
+	}
+
+	if err := r.Patch(ctx, infraCluster, client.MergeFrom(infraClusterPatchCopy)); err != nil {
+		return fmt.Errorf("unable to patch InfraCluster: %w", err)
+	}
+
+	message := fmt.Sprintf("Updated to follow the infrastructure platform status: %s", strings.Join(changes, ", "))
+	r.Recorder.Event(infraCluster, corev1.EventTypeNormal, reasonInfraClusterTopologyUpdated, message)
+
+	log.Info(fmt.Sprintf("InfraCluster '%s/%s' topology successfully updated", infraCluster.GetNamespace(), infraCluster.GetName()), "changes", changes)
+
+	return nil
+}
+
+// applyInfraClusterTopology sets the topology fields on the unstructured InfraCluster
+// and returns a description of each field that changed.
+func applyInfraClusterTopology(unstructuredInfraCluster map[string]interface{}, fields []topologyField) ([]string, error) {
+	var changes []string
+
+	for _, field := range fields {
+		if field.value == "" {
+			continue
+		}
+
+		current, _, err := unstructured.NestedString(unstructuredInfraCluster, field.path...)
+		if err != nil {
+			return nil, fmt.Errorf("incorrect value for %s: %w", strings.Join(field.path, "."), err)
+		}
+
+		if current == field.value {
+			continue
+		}
+
+		if err := unstructured.SetNestedField(unstructuredInfraCluster, field.value, field.path...); err != nil {
+			return nil, fmt.Errorf("unable to set %s: %w", strings.Join(field.path, "."), err)
+		}
+
+		changes = append(changes, fmt.Sprintf("%s changed from %q to %q", strings.Join(field.path, "."), current, field.value))
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package infracluster
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+var _ = Describe("InfraCluster topology", func() {
+	It("should follow the Azure resource groups, defaulting the network resource group", func() {
+		infra := &configv1.Infrastructure{Status: configv1.InfrastructureStatus{PlatformStatus: &configv1.PlatformStatus{
+			Azure: &configv1.AzurePlatformStatus{ResourceGroupName: "new-rg"},
+		}}}
+
+		infraCluster := map[string]interface{}{
+			"spec": map[string]interface{}{
+				"resourceGroup": "old-rg",
+				"networkSpec": map[string]interface{}{
+					"vnet": map[string]interface{}{"name": "test-vnet", "resourceGroup": "new-rg"},
+				},
+			},
+		}
+
+		changes, err := applyInfraClusterTopology(infraCluster, infraClusterTopology(infra))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changes).To(ConsistOf(`spec.resourceGroup changed from "old-rg" to "new-rg"`))
+		Expect(infraCluster).To(HaveKeyWithValue("spec", HaveKeyWithValue("resourceGroup", "new-rg")))
+	})
+
+	It("should follow the GCP region and project", func() {
+		infra := &configv1.Infrastructure{Status: configv1.InfrastructureStatus{PlatformStatus: &configv1.PlatformStatus{
+			GCP: &configv1.GCPPlatformStatus{Region: "us-east1", ProjectID: "test-project"},
+		}}}
+
+		infraCluster := map[string]interface{}{
+			"spec": map[string]interface{}{"region": "us-central1", "project": "test-project"},
+		}
+
+		changes, err := applyInfraClusterTopology(infraCluster, infraClusterTopology(infra))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changes).To(ConsistOf(`spec.region changed from "us-central1" to "us-east1"`))
+		Expect(infraCluster).To(HaveKeyWithValue("spec", HaveKeyWithValue("region", "us-east1")))
+	})
+
+	It("should leave fields the platform status does not report alone", func() {
+		infra := &configv1.Infrastructure{Status: configv1.InfrastructureStatus{PlatformStatus: &configv1.PlatformStatus{
+			AWS: &configv1.AWSPlatformStatus{},
+		}}}
+
+		infraCluster := map[string]interface{}{
+			"spec": map[string]interface{}{"region": "us-east-1"},
+		}
+
+		changes, err := applyInfraClusterTopology(infraCluster, infraClusterTopology(infra))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(changes).To(BeEmpty())
+		Expect(infraCluster).To(HaveKeyWithValue("spec", HaveKeyWithValue("region", "us-east-1")))
+	})
+})
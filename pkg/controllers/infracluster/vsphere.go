@@ -23,17 +23,27 @@ import (
 	"strconv"
 
 	"github.com/go-logr/logr"
+	configv1 "github.com/openshift/api/config/v1"
 	mapiv1beta1 "github.com/openshift/api/machine/v1beta1"
+	"gopkg.in/ini.v1"
 	corev1 "k8s.io/api/core/v1"
 	cerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
 	vspherev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/yaml"
 )
 
+const (
+	// vSphereRegionTagCategory and vSphereZoneTagCategory are the vCenter tag categories
+	// the installer tags the datacenters and compute clusters of the failure domains with.
+	vSphereRegionTagCategory = "openshift-region"
+	vSphereZoneTagCategory   = "openshift-zone"
+)
+
 var (
 	errUnableToFindPasswordVSphereCredsSecret = errors.New("unable to find password in the VSphere credentials secret")
 	errUnableToFindUsernameVSphereCredsSecret = errors.New("unable to find username in the VSphere credentials secret")
@@ -53,6 +63,11 @@ func (r *InfraClusterController) ensureVSphereCluster(ctx context.Context, log l
 		return nil, fmt.Errorf("unable to ensure CAPI VSphere credentials secret: %w", err)
 	}
 
+	// Then make sure the zonal placement of the cluster is available to CAPV.
+	if err := r.ensureVSphereFailureDomains(ctx, log); err != nil {
+		return nil, fmt.Errorf("unable to ensure CAPI VSphere failure domains: %w", err)
+	}
+
 	target := &vspherev1.VSphereCluster{ObjectMeta: metav1.ObjectMeta{
 		Name:      r.Infra.Status.InfrastructureName,
 		Namespace: defaultCAPINamespace,
@@ -81,6 +96,11 @@ func (r *InfraClusterController) ensureVSphereCluster(ctx context.Context, log l
 		return nil, fmt.Errorf("infrastructure PlatformStatus should not be nil: %w", err)
 	}
 
+	thumbprint, err := r.getVSphereThumbprint(ctx, vsphereServerAddr)
+	if err != nil {
+		return nil, fmt.Errorf("error obtaining VSphere server thumbprint: %w", err)
+	}
+
 	target = &vspherev1.VSphereCluster{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      r.Infra.Status.InfrastructureName,
@@ -92,7 +112,8 @@ func (r *InfraClusterController) ensureVSphereCluster(ctx context.Context, log l
 			},
 		},
 		Spec: vspherev1.VSphereClusterSpec{
-			Server: vsphereServerAddr,
+			Server:     vsphereServerAddr,
+			Thumbprint: thumbprint,
 			IdentityRef: &vspherev1.VSphereIdentityReference{
 				Kind: "Secret",
 				Name: r.Infra.Status.InfrastructureName,
@@ -109,6 +130,7 @@ func (r *InfraClusterController) ensureVSphereCluster(ctx context.Context, log l
 	}
 
 	log.Info(fmt.Sprintf("InfraCluster '%s/%s' successfully created", defaultCAPINamespace, r.Infra.Status.InfrastructureName))
+	r.recordInfraClusterCreated(ctx)
 
 	return target, nil
 }
@@ -128,34 +150,26 @@ func getVSphereMAPIProviderSpec(ctx context.Context, cl client.Client) (*mapiv1b
 	return providerSpec, nil
 }
 
-// ensureVSphereSecret ensures the CAPI VSphere credentials secret exists.
+// ensureVSphereSecret ensures the CAPI VSphere credentials secret exists and holds the current credentials.
 func (r *InfraClusterController) ensureVSphereSecret(ctx context.Context, vsphereServerAddr string) error {
-	vSphereSecret := &corev1.Secret{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      r.Infra.Status.InfrastructureName,
-			Namespace: defaultCAPINamespace,
-		},
-	}
-
-	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(vSphereSecret), vSphereSecret); err != nil && !cerrors.IsNotFound(err) {
-		return fmt.Errorf("failed to get CAPI VSphere credentials secret: %w", err)
-	} else if err == nil {
-		// The secret already exists.
-		return nil
-	}
-
 	username, password, err := r.getVSphereCredentials(ctx, vsphereServerAddr)
 	if err != nil {
 		return fmt.Errorf("unable to get VSphere credentials: %w", err)
 	}
 
-	vSphereSecret.StringData = map[string]string{
-		"username": username,
-		"password": password,
+	vSphereSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      r.Infra.Status.InfrastructureName,
+			Namespace: defaultCAPINamespace,
+		},
+		Data: map[string][]byte{
+			"username": []byte(username),
+			"password": []byte(password),
+		},
 	}
 
-	if err := r.Client.Create(ctx, vSphereSecret); err != nil && !cerrors.IsAlreadyExists(err) {
-		return fmt.Errorf("unable to create CAPI VSphere credentials secret: %w", err)
+	if err := r.syncCredentialsSecret(ctx, vSphereSecret); err != nil {
+		return fmt.Errorf("unable to sync CAPI VSphere credentials secret: %w", err)
 	}
 
 	return nil
@@ -202,3 +216,148 @@ func (r *InfraClusterController) getVSphereServerAddr(ctx context.Context) (stri
 
 	return vCenter.Server, nil
 }
+
+// vSphereCloudConfig is the part of the YAML format of the vSphere cloud provider config read by this controller.
+type vSphereCloudConfig struct {
+	VCenter map[string]vSphereCloudConfigVCenter `json:"vcenter"`
+}
+
+// vSphereCloudConfigVCenter is a vCenter of the YAML format of the vSphere cloud provider config.
+type vSphereCloudConfigVCenter struct {
+	Server     string `json:"server"`
+	Thumbprint string `json:"thumbprint"`
+}
+
+// getVSphereThumbprint obtains the thumbprint of the VSphere Server certificate from the cloud provider config.
+// An empty thumbprint is returned when the cloud provider config does not pin the certificate.
+func (r *InfraClusterController) getVSphereThumbprint(ctx context.Context, vsphereServerAddr string) (string, error) {
+	cloudConfig, err := r.getCloudConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return vsphereThumbprintFromCloudConfig(cloudConfig, vsphereServerAddr)
+}
+
+// vsphereThumbprintFromCloudConfig returns the thumbprint of the vCenter server from the vSphere cloud provider config,
+// which is either in the legacy INI format or in the YAML format.
+func vsphereThumbprintFromCloudConfig(cloudConfig, vsphereServerAddr string) (string, error) {
+	yamlConfig := &vSphereCloudConfig{}
+	if err := yaml.Unmarshal([]byte(cloudConfig), yamlConfig); err == nil && len(yamlConfig.VCenter) > 0 {
+		for name, vCenter := range yamlConfig.VCenter {
+			if vCenter.Server == vsphereServerAddr || (vCenter.Server == "" && name == vsphereServerAddr) {
+				return vCenter.Thumbprint, nil
+			}
+		}
+
+		return "", nil
+	}
+
+	iniConfig, err := ini.Load([]byte(cloudConfig))
+	if err != nil {
+		return "", fmt.Errorf("unable to parse the vSphere cloud provider config: %w", err)
+	}
+
+	// The thumbprint of a vCenter takes precedence over the global one.
+	if vCenter, err := iniConfig.GetSection(fmt.Sprintf("VirtualCenter %q", vsphereServerAddr)); err == nil && vCenter.HasKey("thumbprint") {
+		return vCenter.Key("thumbprint").String(), nil
+	}
+
+	return iniConfig.Section("Global").Key("thumbprint").String(), nil
+}
+
+// ensureVSphereFailureDomains ensures a VSphereFailureDomain and a VSphereDeploymentZone exist
+// for every failure domain of the infrastructure, so that CAPV spreads machines the same way MAPV does.
+func (r *InfraClusterController) ensureVSphereFailureDomains(ctx context.Context, log logr.Logger) error {
+	failureDomains, deploymentZones := vsphereFailureDomainsFromInfra(r.Infra)
+
+	for i := range failureDomains {
+		if err := r.ensureVSphereTopologyObject(ctx, log, &failureDomains[i]); err != nil {
+			return fmt.Errorf("unable to ensure VSphereFailureDomain %s: %w", failureDomains[i].Name, err)
+		}
+	}
+
+	for i := range deploymentZones {
+		if err := r.ensureVSphereTopologyObject(ctx, log, &deploymentZones[i]); err != nil {
+			return fmt.Errorf("unable to ensure VSphereDeploymentZone %s: %w", deploymentZones[i].Name, err)
+		}
+	}
+
+	return nil
+}
+
+// ensureVSphereTopologyObject creates the object when it does not exist yet. Existing objects are left untouched.
+func (r *InfraClusterController) ensureVSphereTopologyObject(ctx context.Context, log logr.Logger, obj client.Object) error {
+	if err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil && !cerrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get %T: %w", obj, err)
+	} else if err == nil {
+		return nil
+	}
+
+	if err := r.Create(ctx, obj); err != nil && !cerrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create %T: %w", obj, err)
+	}
+
+	log.Info(fmt.Sprintf("%T %s successfully created", obj, obj.GetName()))
+
+	return nil
+}
+
+// vsphereFailureDomainsFromInfra builds the CAPV failure domains and deployment zones matching the infrastructure failure domains.
+// Regions are tagged on datacenters and zones on compute clusters, the tags already exist so CAPV must not configure them.
+func vsphereFailureDomainsFromInfra(infra *configv1.Infrastructure) ([]vspherev1.VSphereFailureDomain, []vspherev1.VSphereDeploymentZone) {
+	if infra.Spec.PlatformSpec.VSphere == nil {
+		return nil, nil
+	}
+
+	var (
+		failureDomains  []vspherev1.VSphereFailureDomain
+		deploymentZones []vspherev1.VSphereDeploymentZone
+	)
+
+	for _, fd := range infra.Spec.PlatformSpec.VSphere.FailureDomains {
+		failureDomains = append(failureDomains, vspherev1.VSphereFailureDomain{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: fd.Name,
+			},
+			Spec: vspherev1.VSphereFailureDomainSpec{
+				Region: vspherev1.FailureDomain{
+					Name:          fd.Region,
+					Type:          vspherev1.DatacenterFailureDomain,
+					TagCategory:   vSphereRegionTagCategory,
+					AutoConfigure: ptr.To(false),
+				},
+				Zone: vspherev1.FailureDomain{
+					Name:          fd.Zone,
+					Type:          vspherev1.ComputeClusterFailureDomain,
+					TagCategory:   vSphereZoneTagCategory,
+					AutoConfigure: ptr.To(false),
+				},
+				Topology: vspherev1.Topology{
+					Datacenter:     fd.Topology.Datacenter,
+					ComputeCluster: ptr.To(fd.Topology.ComputeCluster),
+					Networks:       fd.Topology.Networks,
+					Datastore:      fd.Topology.Datastore,
+				},
+			},
+		})
+
+		deploymentZones = append(deploymentZones, vspherev1.VSphereDeploymentZone{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: fd.Name,
+			},
+			Spec: vspherev1.VSphereDeploymentZoneSpec{
+				Server:        fd.Server,
+				FailureDomain: fd.Name,
+				// The control plane is managed by the control plane machine set, never by CAPI.
+				ControlPlane: ptr.To(false),
+				PlacementConstraint: vspherev1.PlacementConstraint{
+					ResourcePool: fd.Topology.ResourcePool,
+					Folder:       fd.Topology.Folder,
+				},
+			},
+		})
+	}
+
+	return failureDomains, deploymentZones
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package infracluster
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	configv1 "github.com/openshift/api/config/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	vspherev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+var _ = DescribeTable("vsphereFailureDomainsFromInfra",
+	func(platformSpec configv1.PlatformSpec, expectedFailureDomains []vspherev1.VSphereFailureDomain, expectedDeploymentZones []vspherev1.VSphereDeploymentZone) {
+		failureDomains, deploymentZones := vsphereFailureDomainsFromInfra(&configv1.Infrastructure{Spec: configv1.InfrastructureSpec{PlatformSpec: platformSpec}})
+		Expect(failureDomains).To(Equal(expectedFailureDomains))
+		Expect(deploymentZones).To(Equal(expectedDeploymentZones))
+	},
+	Entry("without a vSphere platform spec", configv1.PlatformSpec{Type: configv1.VSpherePlatformType}, nil, nil),
+	Entry("without failure domains", configv1.PlatformSpec{VSphere: &configv1.VSpherePlatformSpec{}}, nil, nil),
+	Entry("with a failure domain", configv1.PlatformSpec{VSphere: &configv1.VSpherePlatformSpec{
+		FailureDomains: []configv1.VSpherePlatformFailureDomainSpec{{
+			Name:   "us-east-1a",
+			Region: "us-east",
+			Zone:   "us-east-1a",
+			Server: "test-vcenter",
+			Topology: configv1.VSpherePlatformTopology{
+				Datacenter:     "test-datacenter",
+				ComputeCluster: "/test-datacenter/host/test-cluster",
+				Networks:       []string{"test-network"},
+				Datastore:      "/test-datacenter/datastore/test-datastore",
+				ResourcePool:   "/test-datacenter/host/test-cluster/Resources",
+				Folder:         "/test-datacenter/vm/test-folder",
+			},
+		}},
+	}}, []vspherev1.VSphereFailureDomain{{
+		ObjectMeta: metav1.ObjectMeta{Name: "us-east-1a"},
+		Spec: vspherev1.VSphereFailureDomainSpec{
+			Region: vspherev1.FailureDomain{Name: "us-east", Type: vspherev1.DatacenterFailureDomain, TagCategory: "openshift-region", AutoConfigure: ptr.To(false)},
+			Zone:   vspherev1.FailureDomain{Name: "us-east-1a", Type: vspherev1.ComputeClusterFailureDomain, TagCategory: "openshift-zone", AutoConfigure: ptr.To(false)},
+			Topology: vspherev1.Topology{
+				Datacenter:     "test-datacenter",
+				ComputeCluster: ptr.To("/test-datacenter/host/test-cluster"),
+				Networks:       []string{"test-network"},
+				Datastore:      "/test-datacenter/datastore/test-datastore",
+			},
+		},
+	}}, []vspherev1.VSphereDeploymentZone{{
+		ObjectMeta: metav1.ObjectMeta{Name: "us-east-1a"},
+		Spec: vspherev1.VSphereDeploymentZoneSpec{
+			Server:        "test-vcenter",
+			FailureDomain: "us-east-1a",
+			ControlPlane:  ptr.To(false),
+			PlacementConstraint: vspherev1.PlacementConstraint{
+				ResourcePool: "/test-datacenter/host/test-cluster/Resources",
+				Folder:       "/test-datacenter/vm/test-folder",
+			},
+		},
+	}}),
+)
+
+var _ = DescribeTable("vsphereThumbprintFromCloudConfig",
+	func(cloudConfig, expectedThumbprint string) {
+		thumbprint, err := vsphereThumbprintFromCloudConfig(cloudConfig, "test-vcenter")
+		Expect(err).ToNot(HaveOccurred())
+		Expect(thumbprint).To(Equal(expectedThumbprint))
+	},
+	Entry("with an empty cloud config", "", ""),
+	Entry("with an INI cloud config without a thumbprint", `[Global]
+secret-name = "vsphere-creds"
+secret-namespace = "kube-system"
+
+[VirtualCenter "test-vcenter"]
+datacenters = "test-datacenter"
+`, ""),
+	Entry("with an INI cloud config with a vCenter thumbprint", `[Global]
+thumbprint = "global-thumbprint"
+
+[VirtualCenter "test-vcenter"]
+datacenters = "test-datacenter"
+thumbprint = "vcenter-thumbprint"
+`, "vcenter-thumbprint"),
+	Entry("with an INI cloud config with a global thumbprint", `[Global]
+thumbprint = "global-thumbprint"
+
+[VirtualCenter "test-vcenter"]
+datacenters = "test-datacenter"
+`, "global-thumbprint"),
+	Entry("with a YAML cloud config with a thumbprint", `global:
+  secretName: vsphere-creds
+  secretNamespace: kube-system
+vcenter:
+  test-vcenter:
+    server: test-vcenter
+    thumbprint: vcenter-thumbprint
+    datacenters:
+    - test-datacenter
+`, "vcenter-thumbprint"),
+	Entry("with a YAML cloud config for another vCenter", `vcenter:
+  other-vcenter:
+    server: other-vcenter
+    thumbprint: other-thumbprint
+`, ""),
+)
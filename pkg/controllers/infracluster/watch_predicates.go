@@ -25,6 +25,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/cluster-capi-operator/pkg/controllers"
 )
 
 // clusterOperatorPredicates defines a predicate function for the cluster-api ClusterOperator.
@@ -42,6 +43,13 @@ func clusterOperatorPredicates() predicate.Funcs {
 	}
 }
 
+// infrastructurePredicate defines a predicate function for the cluster Infrastructure.
+func infrastructurePredicate() predicate.Funcs {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetName() == controllers.InfrastructureResourceName
+	})
+}
+
 // toClusterOperator maps a reconcile request to the cluster-api ClusterOperator.
 func toClusterOperator(ctx context.Context, cO client.Object) []reconcile.Request {
 	return []reconcile.Request{{
@@ -68,3 +76,14 @@ func isOwnedCInfraCluster(obj runtime.Object, namespace string) bool {
 
 	return cO.GetNamespace() == namespace
 }
+
+// machineDeletedPredicate defines a predicate function for the deletions of the Machines of the namespace,
+// which can release the deletion protection of the InfraCluster.
+func machineDeletedPredicate(namespace string) predicate.Funcs {
+	return predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return false },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return false },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return e.Object.GetNamespace() == namespace },
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kubeconfig
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+
+	"github.com/go-logr/logr"
+
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+)
+
+// kubeconfigCopyLabel marks the copies of a kubeconfig secret distributed to other namespaces,
+// with the name of the source secret, so copies of namespaces which are not configured anymore are garbage-collected.
+const kubeconfigCopyLabel = "cluster-capi-operator.openshift.io/kubeconfig-copy-of"
+
+// distributeKubeconfig copies the kubeconfig secret of the core CAPI controllers to the namespaces, e.g. the namespace
+// of an IPAM or addon provider, and deletes the copies of other namespaces.
+// The API is read directly, as the namespaces besides the CAPI namespace are not cached.
+// It returns whether a namespace does not exist yet.
+func (r *KubeconfigReconciler) distributeKubeconfig(ctx context.Context, log logr.Logger, namespaces []string) (bool, error) {
+	sourceName := r.clusterName + coreKubeconfig.secretSuffix
+
+	source, err := r.SecretsClient.Secrets(controllers.DefaultManagedNamespace).Get(ctx, sourceName, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("unable to get kubeconfig secret %s/%s: %w", controllers.DefaultManagedNamespace, sourceName, err)
+	}
+
+	missingNamespace := false
+	errs := []error{}
+
+	for _, namespace := range namespaces {
+		if namespace == controllers.DefaultManagedNamespace {
+			continue
+		}
+
+		if err := r.syncKubeconfigCopy(ctx, source, namespace); kerrors.IsNotFound(err) {
+			log.Info("Waiting for namespace to distribute the kubeconfig to", "namespace", namespace)
+
+			missingNamespace = true
+		} else if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := r.deleteStaleKubeconfigCopies(ctx, sourceName, namespaces); err != nil {
+		errs = append(errs, err)
+	}
+
+	return missingNamespace, errors.Join(errs...)
+}
+
+// syncKubeconfigCopy creates or updates the copy of the kubeconfig secret in the namespace.
+// A NotFound error is returned when the namespace does not exist.
+func (r *KubeconfigReconciler) syncKubeconfigCopy(ctx context.Context, source *corev1.Secret, namespace string) error {
+	secrets := r.SecretsClient.Secrets(namespace)
+	desired := newKubeconfigCopy(source, namespace)
+
+	existing, err := secrets.Get(ctx, desired.Name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		if _, err := secrets.Create(ctx, desired, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("unable to create kubeconfig secret %s/%s: %w", namespace, desired.Name, err)
+		}
+
+		r.RecordEvent(ctx, corev1.EventTypeNormal, "KubeconfigDistributed",
+			fmt.Sprintf("Copied kubeconfig secret %s/%s to namespace %s", source.Namespace, source.Name, namespace))
+
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to get kubeconfig secret %s/%s: %w", namespace, desired.Name, err)
+	}
+
+	if reflect.DeepEqual(existing.Data, desired.Data) && reflect.DeepEqual(existing.Labels, desired.Labels) &&
+		reflect.DeepEqual(existing.Annotations, desired.Annotations) && existing.Type == desired.Type {
+		return nil
+	}
+
+	existing.Data = desired.Data
+	existing.Labels = desired.Labels
+	existing.Annotations = desired.Annotations
+	existing.Type = desired.Type
+
+	if _, err := secrets.Update(ctx, existing, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("unable to update kubeconfig secret %s/%s: %w", namespace, desired.Name, err)
+	}
+
+	return nil
+}
+
+// deleteStaleKubeconfigCopies deletes the copies of the kubeconfig secret in namespaces which are not configured anymore.
+func (r *KubeconfigReconciler) deleteStaleKubeconfigCopies(ctx context.Context, sourceName string, namespaces []string) error {
+	copies, err := r.SecretsClient.Secrets(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", kubeconfigCopyLabel, sourceName),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to list copies of kubeconfig secret %s: %w", sourceName, err)
+	}
+
+	errs := []error{}
+
+	for _, secret := range copies.Items {
+		if slices.Contains(namespaces, secret.Namespace) {
+			continue
+		}
+
+		if err := r.SecretsClient.Secrets(secret.Namespace).Delete(ctx, secret.Name, metav1.DeleteOptions{}); err != nil && !kerrors.IsNotFound(err) {
+			errs = append(errs, fmt.Errorf("unable to delete kubeconfig secret %s/%s: %w", secret.Namespace, secret.Name, err))
+
+			continue
+		}
+
+		r.RecordEvent(ctx, corev1.EventTypeNormal, "KubeconfigCopyDeleted",
+			fmt.Sprintf("Deleted copy %s/%s of kubeconfig secret %s, the namespace is not configured anymore", secret.Namespace, secret.Name, sourceName))
+	}
+
+	return errors.Join(errs...)
+}
+
+// newKubeconfigCopy returns the copy of the kubeconfig secret for the namespace, labelled as a copy of the secret.
+func newKubeconfigCopy(source *corev1.Secret, namespace string) *corev1.Secret {
+	labels := maps.Clone(source.Labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	labels[kubeconfigCopyLabel] = source.Name
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        source.Name,
+			Namespace:   namespace,
+			Labels:      labels,
+			Annotations: maps.Clone(source.Annotations),
+		},
+		Data: maps.Clone(source.Data),
+		Type: source.Type,
+	}
+}
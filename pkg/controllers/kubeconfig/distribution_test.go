@@ -0,0 +1,46 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kubeconfig
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+)
+
+var _ = Describe("newKubeconfigCopy", func() {
+	It("should copy the kubeconfig secret to the namespace, labelled as a copy", func() {
+		source := newKubeConfigSecret("test-cluster-kubeconfig", "test-cluster", []byte("kubeconfig"))
+		source.Annotations = map[string]string{tokenExpiresAtAnnotation: "2024-01-01T11:00:00Z"}
+
+		kubeconfigCopy := newKubeconfigCopy(source, "ipam")
+
+		Expect(kubeconfigCopy.Name).To(Equal(source.Name))
+		Expect(kubeconfigCopy.Namespace).To(Equal("ipam"))
+		Expect(kubeconfigCopy.Labels).To(Equal(map[string]string{
+			clusterv1.ClusterNameLabel: "test-cluster",
+			kubeconfigCopyLabel:        source.Name,
+		}))
+		Expect(kubeconfigCopy.Annotations).To(Equal(source.Annotations))
+		Expect(kubeconfigCopy.Data).To(Equal(source.Data))
+		Expect(kubeconfigCopy.Type).To(Equal(clusterv1.ClusterSecretType))
+
+		Expect(source.Namespace).To(Equal(controllers.DefaultManagedNamespace))
+		Expect(source.Labels).NotTo(HaveKey(kubeconfigCopyLabel))
+	})
+})
@@ -17,6 +17,7 @@ package kubeconfig
 
 import (
 	"errors"
+	"fmt"
 
 	"k8s.io/client-go/tools/clientcmd/api"
 
@@ -30,15 +31,26 @@ var (
 	errClusterNameEmpty       = errors.New("cluster name can't be empty")
 )
 
+const (
+	// serviceAccountTokenFile is the service account token the kubelet projects into pods, and rotates.
+	serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+	// execCredentialTemplate is the ExecCredential the exec credential plugin prints, with the token read from the file.
+	execCredentialTemplate = `printf '{"apiVersion":"client.authentication.k8s.io/v1","kind":"ExecCredential","status":{"token":"%%s"}}' "$(cat %s)"`
+)
+
 type kubeconfigOptions struct {
-	token            []byte
+	token []byte
+	// execTokenFile configures an exec credential plugin reading the token from the file when called,
+	// instead of embedding the token.
+	execTokenFile    string
 	caCert           []byte
 	apiServerEnpoint string
 	clusterName      string
 }
 
 func generateKubeconfig(options kubeconfigOptions) (*api.Config, error) {
-	if len(options.token) == 0 {
+	if len(options.token) == 0 && options.execTokenFile == "" {
 		return nil, errTokenEmpty
 	}
 
@@ -55,6 +67,21 @@ func generateKubeconfig(options kubeconfigOptions) (*api.Config, error) {
 	}
 
 	userName := "cluster-capi-operator"
+
+	authInfo := &api.AuthInfo{
+		Token: string(options.token),
+	}
+	if options.execTokenFile != "" {
+		authInfo = &api.AuthInfo{
+			Exec: &api.ExecConfig{
+				APIVersion:      "client.authentication.k8s.io/v1",
+				Command:         "/bin/sh",
+				Args:            []string{"-c", fmt.Sprintf(execCredentialTemplate, options.execTokenFile)},
+				InteractiveMode: api.NeverExecInteractiveMode,
+			},
+		}
+	}
+
 	kubeconfig := &api.Config{
 		Clusters: map[string]*api.Cluster{
 			options.clusterName: {
@@ -70,9 +97,7 @@ func generateKubeconfig(options kubeconfigOptions) (*api.Config, error) {
 			},
 		},
 		AuthInfos: map[string]*api.AuthInfo{
-			userName: {
-				Token: string(options.token),
-			},
+			userName: authInfo,
 		},
 		CurrentContext: options.clusterName,
 	}
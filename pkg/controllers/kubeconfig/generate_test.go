@@ -53,6 +53,19 @@ var _ = Describe("Generate kubeconfig", func() {
 		Expect(kubeconfig.AuthInfos["cluster-capi-operator"].Token).To(Equal(testBase64Text))
 	})
 
+	It("should generate kubeconfig with an exec credential plugin", func() {
+		options.token = nil
+		options.execTokenFile = serviceAccountTokenFile
+		kubeconfig, err := generateKubeconfig(*options)
+		Expect(err).NotTo(HaveOccurred())
+
+		authInfo := kubeconfig.AuthInfos["cluster-capi-operator"]
+		Expect(authInfo.Token).To(BeEmpty())
+		Expect(authInfo.Exec).NotTo(BeNil())
+		Expect(authInfo.Exec.APIVersion).To(Equal("client.authentication.k8s.io/v1"))
+		Expect(authInfo.Exec.Args).To(ConsistOf("-c", ContainSubstring(serviceAccountTokenFile)))
+	})
+
 	It("should fail with empty token", func() {
 		options.token = nil
 		kubeconfig, err := generateKubeconfig(*options)
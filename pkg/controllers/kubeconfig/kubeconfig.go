@@ -26,6 +26,7 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
@@ -37,34 +38,60 @@ import (
 
 	configv1 "github.com/openshift/api/config/v1"
 	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+	"github.com/openshift/cluster-capi-operator/pkg/metrics"
+	"github.com/openshift/cluster-capi-operator/pkg/operatorconfig"
 	"github.com/openshift/cluster-capi-operator/pkg/operatorstatus"
 )
 
 const (
-	controllerName  = "KubeconfigController"
-	tokenSecretName = "cluster-capi-operator-secret" //nolint
+	controllerName = "KubeconfigController"
+
+	// legacyTokenSecretName is the long-lived service account token secret of previous releases.
+	legacyTokenSecretName = "cluster-capi-operator-secret" //nolint
+
+	// Controller conditions for the Cluster Operator resource.
+	kubeconfigControllerAvailableCondition = "KubeconfigControllerAvailable"
+	kubeconfigControllerDegradedCondition  = "KubeconfigControllerDegraded"
 )
 
 // KubeconfigReconciler reconciles a ClusterOperator object.
 type KubeconfigReconciler struct {
 	operatorstatus.ClusterOperatorStatusClient
-	Scheme      *runtime.Scheme
-	RestCfg     *rest.Config
-	clusterName string
+	Scheme  *runtime.Scheme
+	RestCfg *rest.Config
+	// TokenClient requests the bound tokens of the operator service account for the kubeconfig.
+	TokenClient TokenRequester
+	// SecretsClient copies the kubeconfig secret to the additional namespaces, which are not cached.
+	SecretsClient corev1client.SecretsGetter
+	// TokenLifetime is the lifetime of the kubeconfig token, i.e. its rotation interval.
+	TokenLifetime time.Duration
+	// TokenRenewBefore is how long before its expiry the kubeconfig token is renewed.
+	TokenRenewBefore time.Duration
+	clusterName      string
+	platform         configv1.PlatformType
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *KubeconfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := validateTokenRotation(r.TokenLifetime, r.TokenRenewBefore); err != nil {
+		return fmt.Errorf("invalid kubeconfig token rotation: %w", err)
+	}
+
 	if err := ctrl.NewControllerManagedBy(mgr).
 		Named(controllerName).
 		For(
 			&corev1.Secret{},
-			builder.WithPredicates(tokenSecretPredicate()),
+			builder.WithPredicates(kubeconfigSecretPredicate()),
 		).
 		Watches(
-			&corev1.Secret{},
-			handler.EnqueueRequestsFromMapFunc(toTokenSecret),
-			builder.WithPredicates(kubeconfigSecretPredicate()),
+			&corev1.ConfigMap{},
+			&handler.EnqueueRequestForObject{},
+			builder.WithPredicates(rootCAConfigMapPredicate()),
+		).
+		Watches(
+			operatorconfig.New(),
+			&handler.EnqueueRequestForObject{},
+			builder.WithPredicates(operatorconfig.Predicate()),
 		).
 		Complete(r); err != nil {
 		return fmt.Errorf("failed to create controller: %w", err)
@@ -81,7 +108,7 @@ func (r *KubeconfigReconciler) Reconcile(ctx context.Context, _ ctrl.Request) (c
 	if err := r.Get(ctx, client.ObjectKey{Name: controllers.InfrastructureResourceName}, infra); err != nil {
 		log.Error(err, "Unable to retrieve Infrastructure object")
 
-		if err := r.SetStatusDegraded(ctx, err); err != nil {
+		if err := r.setDegradedCondition(ctx, log, fmt.Errorf("unable to retrieve Infrastructure object: %w", err)); err != nil {
 			return ctrl.Result{}, fmt.Errorf("error syncing ClusterOperatorStatus: %w", err)
 		}
 
@@ -91,7 +118,7 @@ func (r *KubeconfigReconciler) Reconcile(ctx context.Context, _ ctrl.Request) (c
 	if infra.Status.PlatformStatus == nil {
 		log.Info("No platform status exists in infrastructure object. Skipping kubeconfig reconciliation...")
 
-		if err := r.SetStatusAvailable(ctx, ""); err != nil {
+		if err := r.setAvailableCondition(ctx, log); err != nil {
 			return ctrl.Result{}, fmt.Errorf("error syncing ClusterOperatorStatus: %w", err)
 		}
 
@@ -99,21 +126,22 @@ func (r *KubeconfigReconciler) Reconcile(ctx context.Context, _ ctrl.Request) (c
 	}
 
 	r.clusterName = infra.Status.InfrastructureName
+	r.platform = infra.Status.PlatformStatus.Type
 
-	log.Info("Reconciling kubeconfig secret")
+	log.Info("Reconciling kubeconfig secrets")
 
 	res, err := r.reconcileKubeconfig(ctx, log)
 	if err != nil {
 		log.Error(err, "Error reconciling kubeconfig")
 
-		if err := r.SetStatusDegraded(ctx, err); err != nil {
+		if err := r.setDegradedCondition(ctx, log, err); err != nil {
 			return ctrl.Result{}, fmt.Errorf("error syncing ClusterOperatorStatus: %w", err)
 		}
 
 		return ctrl.Result{}, fmt.Errorf("error reconciling kubeconfig: %w", err)
 	}
 
-	if err := r.SetStatusAvailable(ctx, ""); err != nil {
+	if err := r.setAvailableCondition(ctx, log); err != nil {
 		return ctrl.Result{}, fmt.Errorf("error syncing ClusterOperatorStatus: %w", err)
 	}
 
@@ -121,72 +149,251 @@ func (r *KubeconfigReconciler) Reconcile(ctx context.Context, _ ctrl.Request) (c
 }
 
 func (r *KubeconfigReconciler) reconcileKubeconfig(ctx context.Context, log logr.Logger) (ctrl.Result, error) {
-	// Get the token secret
-	tokenSecret := &corev1.Secret{}
-	tokenSecretKey := client.ObjectKey{
-		Name:      tokenSecretName,
+	if err := r.deleteLegacyTokenSecret(ctx, log); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Get the CA of the API server
+	rootCA := &corev1.ConfigMap{}
+	rootCAKey := client.ObjectKey{
+		Name:      rootCAConfigMapName,
 		Namespace: controllers.DefaultManagedNamespace,
 	}
 
-	if err := r.Get(ctx, tokenSecretKey, tokenSecret); err != nil {
+	if err := r.Get(ctx, rootCAKey, rootCA); err != nil {
 		if errors.IsNotFound(err) {
-			log.Info("Waiting for token secret to be created")
+			log.Info("Waiting for root CA ConfigMap to be published")
 
 			return ctrl.Result{RequeueAfter: 1 * time.Minute}, nil
 		}
 
-		return ctrl.Result{}, fmt.Errorf("unable to retrieve Secret object: %w", err)
+		return ctrl.Result{}, fmt.Errorf("unable to retrieve ConfigMap object: %w", err)
+	}
+
+	caCert := []byte(rootCA.Data[rootCACertKey])
+
+	spec, err := operatorconfig.GetSpec(ctx, r.Client)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to get operator config: %w", err)
+	}
+
+	result := ctrl.Result{}
+
+	for _, target := range kubeconfigTargets(r.platform) {
+		if spec.Kubeconfig.Credentials == operatorconfig.KubeconfigCredentialsExec {
+			if err := r.reconcileExecKubeconfigSecret(ctx, target, caCert); err != nil {
+				return ctrl.Result{}, err
+			}
+
+			continue
+		}
+
+		renewAt, err := r.reconcileKubeconfigSecret(ctx, log, target, caCert)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		// Requeue for the earliest renewal, at least a minute from now in case the API server shortened the lifetime of a token.
+		requeueAfter := max(time.Until(renewAt), time.Minute)
+		if result.RequeueAfter == 0 || requeueAfter < result.RequeueAfter {
+			result.RequeueAfter = requeueAfter
+		}
+	}
+
+	missingNamespace, err := r.distributeKubeconfig(ctx, log, spec.Kubeconfig.Namespaces)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Namespaces besides the CAPI namespace are not watched, retry until the missing ones are created.
+	if missingNamespace && (result.RequeueAfter == 0 || result.RequeueAfter > time.Minute) {
+		result.RequeueAfter = time.Minute
+	}
+
+	return result, nil
+}
+
+// reconcileKubeconfigSecret generates the kubeconfig secret of the target, renewing its token when due.
+// It returns when the token is due for renewal.
+func (r *KubeconfigReconciler) reconcileKubeconfigSecret(ctx context.Context, log logr.Logger, target kubeconfigTarget, caCert []byte) (time.Time, error) {
+	kubeconfigSecret := newKubeConfigSecret(r.clusterName+target.secretSuffix, r.clusterName, nil)
+
+	existingSecret := &corev1.Secret{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(kubeconfigSecret), existingSecret); err != nil && !errors.IsNotFound(err) {
+		return time.Time{}, fmt.Errorf("unable to retrieve Secret object: %w", err)
+	}
+
+	// Keep the token of the kubeconfig until its renewal.
+	now := time.Now()
+	renewAt, ok := tokenRenewalTime(existingSecret, r.TokenRenewBefore)
+
+	token := boundToken{
+		token:     kubeconfigToken(existingSecret),
+		issuedAt:  tokenAnnotationTime(existingSecret, tokenIssuedAtAnnotation),
+		expiresAt: tokenAnnotationTime(existingSecret, tokenExpiresAtAnnotation),
+	}
+	renew := !ok || !now.Before(renewAt) || token.token == ""
+	// The kubeconfig is regenerated with its current token when the API server endpoint or its CA changed,
+	// e.g. when the serving CA of the API server was rotated, so the CAPI controllers keep trusting the API server.
+	regenerate := renew || !isKubeconfigCurrent(existingSecret, r.clusterName, r.RestCfg.Host, caCert)
+
+	kubeconfigSecret.Data = existingSecret.Data
+
+	if renew {
+		log.Info("Requesting a new token for the kubeconfig", "secret", kubeconfigSecret.Name, "serviceAccount", target.serviceAccountName)
+
+		var err error
+		if token, err = r.requestToken(ctx, target.serviceAccountName, now); err != nil {
+			metrics.RecordKubeconfigTokenRotationFailure(kubeconfigSecret.Name)
+
+			return time.Time{}, err
+		}
+
+		renewAt = token.expiresAt.Add(-r.TokenRenewBefore)
+	}
+
+	if regenerate {
+		// Generate kubeconfig
+		kubeconfig, err := generateKubeconfig(kubeconfigOptions{
+			token:            []byte(token.token),
+			caCert:           caCert,
+			apiServerEnpoint: r.RestCfg.Host,
+			clusterName:      r.clusterName,
+		})
+		if err != nil {
+			return time.Time{}, fmt.Errorf("error generating kubeconfig: %w", err)
+		}
+
+		out, err := clientcmd.Write(*kubeconfig)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("error writing kubeconfig: %w", err)
+		}
+
+		kubeconfigSecret.Data = map[string][]byte{
+			"value": out,
+		}
 	}
 
-	if time.Since(tokenSecret.CreationTimestamp.Time) >= 30*time.Minute {
-		log.Info("Token secret is older than 30 minutes. Recreating it...")
+	setTokenAnnotations(kubeconfigSecret, token)
 
-		// The token secret is managed by the CVO, it should be recreated shortly after deletion.
-		if err := r.Delete(ctx, tokenSecret); err != nil {
-			return ctrl.Result{}, fmt.Errorf("unable to delete Secret object: %w", err)
+	result, err := r.applyKubeconfigSecret(ctx, kubeconfigSecret)
+	if err != nil {
+		if renew {
+			metrics.RecordKubeconfigTokenRotationFailure(kubeconfigSecret.Name)
 		}
 
-		return ctrl.Result{RequeueAfter: 1 * time.Minute}, nil
+		return time.Time{}, err
+	}
+
+	switch {
+	case result == controllerutil.OperationResultCreated:
+		r.RecordEvent(ctx, corev1.EventTypeNormal, "KubeconfigCreated",
+			fmt.Sprintf("Created kubeconfig secret %s/%s for service account %s", kubeconfigSecret.Namespace, kubeconfigSecret.Name, target.serviceAccountName))
+	case result == controllerutil.OperationResultUpdated && renew:
+		r.RecordEvent(ctx, corev1.EventTypeNormal, "KubeconfigTokenRotated",
+			fmt.Sprintf("Updated kubeconfig secret %s/%s with a new token, expiring at %s",
+				kubeconfigSecret.Namespace, kubeconfigSecret.Name, token.expiresAt.UTC().Format(time.RFC3339)))
+	case result == controllerutil.OperationResultUpdated && regenerate:
+		r.RecordEvent(ctx, corev1.EventTypeNormal, "KubeconfigRegenerated",
+			fmt.Sprintf("Regenerated kubeconfig secret %s/%s for the current API server endpoint and CA", kubeconfigSecret.Namespace, kubeconfigSecret.Name))
+	case result == controllerutil.OperationResultUpdated:
+		r.RecordEvent(ctx, corev1.EventTypeNormal, "KubeconfigUpdated",
+			fmt.Sprintf("Updated kubeconfig secret %s/%s for service account %s", kubeconfigSecret.Namespace, kubeconfigSecret.Name, target.serviceAccountName))
 	}
 
-	// Generate kubeconfig
+	metrics.SetKubeconfigToken(kubeconfigSecret.Name, token.issuedAt, token.expiresAt)
+
+	return renewAt, nil
+}
+
+// reconcileExecKubeconfigSecret generates the kubeconfig secret of the target with an exec credential plugin,
+// reading the service account token the kubelet projects into the pod of the consumer, and rotates.
+// The kubeconfig then authenticates as the service account of the consumer, the operator has no token to rotate.
+func (r *KubeconfigReconciler) reconcileExecKubeconfigSecret(ctx context.Context, target kubeconfigTarget, caCert []byte) error {
 	kubeconfig, err := generateKubeconfig(kubeconfigOptions{
-		token:            tokenSecret.Data["token"],
-		caCert:           tokenSecret.Data["ca.crt"],
+		execTokenFile:    serviceAccountTokenFile,
+		caCert:           caCert,
 		apiServerEnpoint: r.RestCfg.Host,
 		clusterName:      r.clusterName,
 	})
-
 	if err != nil {
-		return ctrl.Result{}, fmt.Errorf("error generating kubeconfig: %w", err)
+		return fmt.Errorf("error generating kubeconfig: %w", err)
 	}
 
-	// Create a secret with generated kubeconfig
 	out, err := clientcmd.Write(*kubeconfig)
 	if err != nil {
-		return ctrl.Result{}, fmt.Errorf("error writing kubeconfig: %w", err)
+		return fmt.Errorf("error writing kubeconfig: %w", err)
+	}
+
+	kubeconfigSecret := newKubeConfigSecret(r.clusterName+target.secretSuffix, r.clusterName, out)
+
+	result, err := r.applyKubeconfigSecret(ctx, kubeconfigSecret)
+	if err != nil {
+		return err
+	}
+
+	switch result {
+	case controllerutil.OperationResultCreated:
+		r.RecordEvent(ctx, corev1.EventTypeNormal, "KubeconfigCreated",
+			fmt.Sprintf("Created kubeconfig secret %s/%s with an exec credential plugin", kubeconfigSecret.Namespace, kubeconfigSecret.Name))
+	case controllerutil.OperationResultUpdated:
+		r.RecordEvent(ctx, corev1.EventTypeNormal, "KubeconfigUpdated",
+			fmt.Sprintf("Updated kubeconfig secret %s/%s with an exec credential plugin", kubeconfigSecret.Namespace, kubeconfigSecret.Name))
 	}
 
-	kubeconfigSecret := newKubeConfigSecret(r.clusterName, out)
+	metrics.DeleteKubeconfigToken(kubeconfigSecret.Name)
+
+	return nil
+}
+
+// applyKubeconfigSecret creates or patches the kubeconfig secret.
+func (r *KubeconfigReconciler) applyKubeconfigSecret(ctx context.Context, kubeconfigSecret *corev1.Secret) (controllerutil.OperationResult, error) {
 	kubeconfigSecretCopy := kubeconfigSecret.DeepCopy()
 
-	if _, err := controllerutil.CreateOrPatch(ctx, r.Client, kubeconfigSecret, func() error {
+	result, err := controllerutil.CreateOrPatch(ctx, r.Client, kubeconfigSecret, func() error {
 		kubeconfigSecret.ObjectMeta = kubeconfigSecretCopy.ObjectMeta
 		kubeconfigSecret.Data = kubeconfigSecretCopy.Data
 		kubeconfigSecret.Type = kubeconfigSecretCopy.Type
 
 		return nil
-	}); err != nil {
-		return ctrl.Result{}, fmt.Errorf("error reconciling kubeconfig secret: %w", err)
+	})
+	if err != nil {
+		return result, fmt.Errorf("error reconciling kubeconfig secret: %w", err)
 	}
 
-	return ctrl.Result{}, nil
+	return result, nil
 }
 
-func newKubeConfigSecret(clusterName string, data []byte) *corev1.Secret {
+// deleteLegacyTokenSecret deletes the long-lived token secret the kubeconfig was generated from in previous releases.
+// It is not part of the release manifests anymore, so it is left behind on upgraded clusters.
+func (r *KubeconfigReconciler) deleteLegacyTokenSecret(ctx context.Context, log logr.Logger) error {
+	tokenSecret := &corev1.Secret{}
+	tokenSecretKey := client.ObjectKey{
+		Name:      legacyTokenSecretName,
+		Namespace: controllers.DefaultManagedNamespace,
+	}
+
+	if err := r.Get(ctx, tokenSecretKey, tokenSecret); errors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to retrieve Secret object: %w", err)
+	}
+
+	if err := r.Delete(ctx, tokenSecret); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("unable to delete Secret object: %w", err)
+	}
+
+	log.Info("Deleted legacy token secret", "secret", tokenSecretKey)
+	r.RecordEvent(ctx, corev1.EventTypeNormal, "LegacyTokenSecretDeleted",
+		fmt.Sprintf("Deleted long-lived token secret %s, the kubeconfig uses bound tokens", tokenSecretKey))
+
+	return nil
+}
+
+func newKubeConfigSecret(name, clusterName string, data []byte) *corev1.Secret {
 	return &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-kubeconfig", clusterName),
+			Name:      name,
 			Namespace: controllers.DefaultManagedNamespace,
 			Labels: map[string]string{
 				clusterv1.ClusterNameLabel: clusterName,
@@ -198,3 +405,53 @@ func newKubeConfigSecret(clusterName string, data []byte) *corev1.Secret {
 		Type: clusterv1.ClusterSecretType,
 	}
 }
+
+func (r *KubeconfigReconciler) setAvailableCondition(ctx context.Context, log logr.Logger) error {
+	co, err := r.GetOrCreateClusterOperator(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get cluster operator: %w", err)
+	}
+
+	conds := []configv1.ClusterOperatorStatusCondition{
+		operatorstatus.NewClusterOperatorStatusCondition(kubeconfigControllerAvailableCondition, configv1.ConditionTrue, operatorstatus.ReasonAsExpected,
+			"Kubeconfig Controller works as expected"),
+		operatorstatus.NewClusterOperatorStatusCondition(kubeconfigControllerDegradedCondition, configv1.ConditionFalse, operatorstatus.ReasonAsExpected,
+			"Kubeconfig Controller works as expected"),
+	}
+
+	r.SetOperatorVersion(co)
+
+	log.V(2).Info("Kubeconfig Controller is available")
+
+	if err := r.SyncStatus(ctx, co, conds); err != nil {
+		return fmt.Errorf("failed to sync status: %w", err)
+	}
+
+	return nil
+}
+
+func (r *KubeconfigReconciler) setDegradedCondition(ctx context.Context, log logr.Logger, reconcileErr error) error {
+	metrics.RecordReconcileError(controllerName, metrics.ErrorReason(reconcileErr))
+
+	co, err := r.GetOrCreateClusterOperator(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get cluster operator: %w", err)
+	}
+
+	conds := []configv1.ClusterOperatorStatusCondition{
+		operatorstatus.NewClusterOperatorStatusCondition(kubeconfigControllerAvailableCondition, configv1.ConditionFalse, operatorstatus.ReasonSyncFailed,
+			"Kubeconfig Controller failed to reconcile the kubeconfig secret"),
+		operatorstatus.NewClusterOperatorStatusCondition(kubeconfigControllerDegradedCondition, configv1.ConditionTrue, operatorstatus.ReasonSyncFailed,
+			fmt.Sprintf("Kubeconfig Controller failed to reconcile the kubeconfig secret: %v", reconcileErr)),
+	}
+
+	r.SetOperatorVersion(co)
+
+	log.Info("Kubeconfig Controller is degraded")
+
+	if err := r.SyncStatus(ctx, co, conds); err != nil {
+		return fmt.Errorf("failed to sync status: %w", err)
+	}
+
+	return nil
+}
@@ -16,103 +16,256 @@ limitations under the License.
 package kubeconfig
 
 import (
+	"context"
 	"fmt"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	authenticationv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
+	configv1 "github.com/openshift/api/config/v1"
 	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+	"github.com/openshift/cluster-capi-operator/pkg/operatorconfig"
 	"github.com/openshift/cluster-capi-operator/pkg/operatorstatus"
 	"github.com/openshift/cluster-capi-operator/pkg/test"
 )
 
+// fakeTokenRequester issues a new token on every request, valid for the requested lifetime.
+type fakeTokenRequester struct {
+	requests        int
+	serviceAccounts []string
+}
+
+func (f *fakeTokenRequester) CreateToken(_ context.Context, serviceAccountName string, tokenRequest *authenticationv1.TokenRequest, _ metav1.CreateOptions) (*authenticationv1.TokenRequest, error) {
+	f.requests++
+	f.serviceAccounts = append(f.serviceAccounts, serviceAccountName)
+
+	tokenRequest = tokenRequest.DeepCopy()
+	tokenRequest.Status = authenticationv1.TokenRequestStatus{
+		Token:               fmt.Sprintf("token-%d", f.requests),
+		ExpirationTimestamp: metav1.NewTime(time.Now().Add(time.Duration(*tokenRequest.Spec.ExpirationSeconds) * time.Second)),
+	}
+
+	return tokenRequest, nil
+}
+
 var _ = Describe("Reconcile kubeconfig secret", func() {
 	Context("create or update kubeconfig secret", func() {
 		var r *KubeconfigReconciler
-		var tokenSecret *corev1.Secret
+		var tokenClient *fakeTokenRequester
+		var rootCA *corev1.ConfigMap
 		kubeconfigSecret := &corev1.Secret{}
 		log := ctrl.LoggerFrom(ctx).WithName("KubeconfigController")
 
+		getKubeconfigSecret := func() {
+			Expect(cl.Get(ctx, client.ObjectKey{
+				Name:      fmt.Sprintf("%s-kubeconfig", r.clusterName),
+				Namespace: controllers.DefaultManagedNamespace,
+			}, kubeconfigSecret)).To(Succeed())
+		}
+
 		BeforeEach(func() {
+			tokenClient = &fakeTokenRequester{}
+			clientset, err := kubernetes.NewForConfig(cfg)
+			Expect(err).NotTo(HaveOccurred())
+
 			r = &KubeconfigReconciler{
 				ClusterOperatorStatusClient: operatorstatus.ClusterOperatorStatusClient{
-					Client: cl,
+					Client:   cl,
+					Recorder: record.NewFakeRecorder(10),
 				},
-				clusterName: "test-cluster",
-				RestCfg:     cfg,
+				clusterName:      "test-cluster",
+				platform:         configv1.GCPPlatformType,
+				RestCfg:          cfg,
+				TokenClient:      tokenClient,
+				SecretsClient:    clientset.CoreV1(),
+				TokenLifetime:    DefaultTokenLifetime,
+				TokenRenewBefore: DefaultTokenRenewBefore,
 			}
 
-			tokenSecret = &corev1.Secret{
+			rootCA = &corev1.ConfigMap{
 				ObjectMeta: metav1.ObjectMeta{
-					Name:      tokenSecretName,
+					Name:      rootCAConfigMapName,
 					Namespace: controllers.DefaultManagedNamespace,
 				},
-				Data: map[string][]byte{
-					"token":  []byte("dGVzdA=="),
-					"ca.crt": []byte("dGVzdA=="),
+				Data: map[string]string{
+					rootCACertKey: "test",
 				},
 			}
 
-			Expect(cl.Create(ctx, tokenSecret)).To(Succeed())
+			Expect(cl.Create(ctx, rootCA)).To(Succeed())
 		})
 
 		AfterEach(func() {
-			Expect(test.CleanupAndWait(ctx, cl, tokenSecret, kubeconfigSecret)).To(Succeed())
+			Expect(test.CleanupAndWait(ctx, cl, rootCA, kubeconfigSecret)).To(Succeed())
+		})
+
+		It("should create a kubeconfig secret with a bound token when it doesn't exist", func() {
+			res, err := r.reconcileKubeconfig(ctx, log)
+			Expect(err).To(Succeed())
+			Expect(res.RequeueAfter).To(BeNumerically("~", DefaultTokenLifetime-DefaultTokenRenewBefore, time.Minute))
+
+			getKubeconfigSecret()
+			Expect(kubeconfigSecret.Data).To(HaveKey("value")) // kubeconfig content is tested separately
+			Expect(kubeconfigSecret.Annotations).To(HaveKey(tokenIssuedAtAnnotation))
+			Expect(kubeconfigSecret.Annotations).To(HaveKey(tokenExpiresAtAnnotation))
+			Expect(tokenClient.serviceAccounts).To(Equal([]string{coreKubeconfig.serviceAccountName}))
 		})
 
-		It("should create a kubeconfig secret when it doesn't exist", func() {
+		It("should create a kubeconfig secret for the provider with its own service account", func() {
+			r.platform = configv1.AWSPlatformType
+
 			_, err := r.reconcileKubeconfig(ctx, log)
 			Expect(err).To(Succeed())
 
+			providerKubeconfigSecret := &corev1.Secret{}
 			Expect(cl.Get(ctx, client.ObjectKey{
-				Name:      fmt.Sprintf("%s-kubeconfig", r.clusterName),
+				Name:      r.clusterName + "-capa-kubeconfig",
 				Namespace: controllers.DefaultManagedNamespace,
-			}, kubeconfigSecret)).To(Succeed())
-			Expect(kubeconfigSecret.Data).To(HaveKey("value")) // kubeconfig content is tested separately
+			}, providerKubeconfigSecret)).To(Succeed())
+			Expect(providerKubeconfigSecret.Data).To(HaveKey("value"))
+			Expect(tokenClient.serviceAccounts).To(Equal([]string{"capi-kubeconfig", "capa-kubeconfig"}))
+
+			getKubeconfigSecret()
+			Expect(test.CleanupAndWait(ctx, cl, providerKubeconfigSecret)).To(Succeed())
 		})
 
-		It("should reconcile existing kubeconfig secret when it doesn't exist", func() {
+		It("should keep the token of an existing kubeconfig secret until its renewal", func() {
 			_, err := r.reconcileKubeconfig(ctx, log)
 			Expect(err).To(Succeed())
 			_, err = r.reconcileKubeconfig(ctx, log)
 			Expect(err).To(Succeed())
 
-			Expect(cl.Get(ctx, client.ObjectKey{
-				Name:      fmt.Sprintf("%s-kubeconfig", r.clusterName),
-				Namespace: controllers.DefaultManagedNamespace,
-			}, kubeconfigSecret)).To(Succeed())
-			Expect(kubeconfigSecret.Data).To(HaveKey("value")) // kubeconfig content is tested separately
+			getKubeconfigSecret()
+			Expect(kubeconfigSecret.Data).To(HaveKey("value"))
+			Expect(tokenClient.requests).To(Equal(1))
+		})
+
+		It("should renew the token when it is about to expire", func() {
+			_, err := r.reconcileKubeconfig(ctx, log)
+			Expect(err).To(Succeed())
+
+			getKubeconfigSecret()
+			kubeconfigSecret.Annotations[tokenExpiresAtAnnotation] = time.Now().Add(5 * time.Minute).UTC().Format(time.RFC3339)
+			Expect(cl.Update(ctx, kubeconfigSecret)).To(Succeed())
+
+			Eventually(func() (int, error) {
+				_, err := r.reconcileKubeconfig(ctx, log)
+
+				return tokenClient.requests, err
+			}, timeout).Should(Equal(2))
+		})
+
+		It("should regenerate the kubeconfig with its token when the CA rotates", func() {
+			_, err := r.reconcileKubeconfig(ctx, log)
+			Expect(err).To(Succeed())
+
+			rootCA.Data[rootCACertKey] = "rotated"
+			Expect(cl.Update(ctx, rootCA)).To(Succeed())
+
+			Eventually(func() (bool, error) {
+				_, err := r.reconcileKubeconfig(ctx, log)
+				getKubeconfigSecret()
+
+				return isKubeconfigCurrent(kubeconfigSecret, r.clusterName, cfg.Host, []byte("rotated")), err
+			}, timeout).Should(BeTrue())
+			Expect(kubeconfigToken(kubeconfigSecret)).To(Equal("token-1"))
+			Expect(tokenClient.requests).To(Equal(1))
 		})
 
-		It("requeue when token secret doesn't exist", func() {
-			Expect(cl.Delete(ctx, tokenSecret)).To(Succeed())
+		It("requeue when root CA ConfigMap doesn't exist", func() {
+			Expect(cl.Delete(ctx, rootCA)).To(Succeed())
 			Eventually(func() error {
-				return cl.Get(ctx, client.ObjectKeyFromObject(tokenSecret), tokenSecret)
+				return cl.Get(ctx, client.ObjectKeyFromObject(rootCA), rootCA)
 			}, timeout).Should(Not(Succeed()))
 
 			res, err := r.reconcileKubeconfig(ctx, log)
 			Expect(err).To(Succeed())
 			Expect(res.RequeueAfter).To(Equal(1 * time.Minute))
+			Expect(tokenClient.requests).To(BeZero())
 		})
 
-		It("should delete token secret if its old and requeue", func() {
-			// Use fake client because it's not possible to update creation timestamp in envtest
-			fakeClient := fake.NewClientBuilder().WithScheme(testEnv.Scheme).WithRuntimeObjects(tokenSecret).Build()
-			r.Client = fakeClient
-			tokenSecret.SetCreationTimestamp(metav1.Time{Time: time.Now().Add(-1 * time.Hour)})
-			Expect(fakeClient.Update(ctx, tokenSecret)).To(Succeed())
+		It("should generate a kubeconfig with an exec credential plugin in Exec mode", func() {
+			operatorConfig := operatorconfig.New()
+			operatorConfig.SetName(operatorconfig.Name)
+			operatorConfig.Object["spec"] = map[string]interface{}{
+				"kubeconfig": map[string]interface{}{"credentials": string(operatorconfig.KubeconfigCredentialsExec)},
+			}
+			Expect(cl.Create(ctx, operatorConfig)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(test.CleanupAndWait(ctx, cl, operatorConfig)).To(Succeed())
+			})
+
 			res, err := r.reconcileKubeconfig(ctx, log)
 			Expect(err).To(Succeed())
+			Expect(res.RequeueAfter).To(BeZero())
+
+			getKubeconfigSecret()
+			kubeconfig, err := clientcmd.Load(kubeconfigSecret.Data["value"])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(kubeconfig.AuthInfos).To(HaveEach(HaveField("Exec", Not(BeNil()))))
+			Expect(kubeconfigSecret.Annotations).NotTo(HaveKey(tokenExpiresAtAnnotation))
+			Expect(tokenClient.requests).To(BeZero())
+		})
+
+		It("should distribute the kubeconfig to the configured namespaces and delete stale copies", func() {
+			addonNamespace := &corev1.Namespace{}
+			addonNamespace.SetName("kubeconfig-addon")
+			Expect(cl.Create(ctx, addonNamespace)).To(Succeed())
+
+			operatorConfig := operatorconfig.New()
+			operatorConfig.SetName(operatorconfig.Name)
+			operatorConfig.Object["spec"] = map[string]interface{}{
+				"kubeconfig": map[string]interface{}{"namespaces": []interface{}{addonNamespace.Name, "kubeconfig-missing"}},
+			}
+			Expect(cl.Create(ctx, operatorConfig)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(test.CleanupAndWait(ctx, cl, operatorConfig)).To(Succeed())
+			})
+
+			res, err := r.reconcileKubeconfig(ctx, log)
+			Expect(err).To(Succeed())
+			Expect(res.RequeueAfter).To(Equal(time.Minute))
+
+			getKubeconfigSecret()
+			kubeconfigCopy := &corev1.Secret{}
+			Expect(cl.Get(ctx, client.ObjectKey{Name: kubeconfigSecret.Name, Namespace: addonNamespace.Name}, kubeconfigCopy)).To(Succeed())
+			Expect(kubeconfigCopy.Data).To(Equal(kubeconfigSecret.Data))
+			Expect(kubeconfigCopy.Labels).To(HaveKeyWithValue(kubeconfigCopyLabel, kubeconfigSecret.Name))
+
+			operatorConfig.Object["spec"] = map[string]interface{}{}
+			Expect(cl.Update(ctx, operatorConfig)).To(Succeed())
+
+			_, err = r.reconcileKubeconfig(ctx, log)
+			Expect(err).To(Succeed())
+
+			Eventually(func() error {
+				return cl.Get(ctx, client.ObjectKeyFromObject(kubeconfigCopy), kubeconfigCopy)
+			}, timeout).Should(Not(Succeed()))
+		})
+
+		It("should delete the legacy token secret", func() {
+			legacyTokenSecret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      legacyTokenSecretName,
+					Namespace: controllers.DefaultManagedNamespace,
+				},
+			}
+			Expect(cl.Create(ctx, legacyTokenSecret)).To(Succeed())
+
+			_, err := r.reconcileKubeconfig(ctx, log)
+			Expect(err).To(Succeed())
 
-			Expect(res.RequeueAfter).To(Equal(1 * time.Minute))
 			Eventually(func() error {
-				return fakeClient.Get(ctx, client.ObjectKeyFromObject(tokenSecret), tokenSecret)
+				return cl.Get(ctx, client.ObjectKeyFromObject(legacyTokenSecret), legacyTokenSecret)
 			}, timeout).Should(Not(Succeed()))
 		})
 	})
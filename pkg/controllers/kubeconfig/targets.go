@@ -0,0 +1,65 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kubeconfig
+
+import (
+	configv1 "github.com/openshift/api/config/v1"
+)
+
+// kubeconfigTarget is a kubeconfig secret, with the service account its tokens are issued for.
+// Each consumer gets its own service account, with its own RBAC, so that a leaked kubeconfig
+// only grants the permissions of its consumer.
+type kubeconfigTarget struct {
+	// serviceAccountName is the service account the tokens of the kubeconfig are issued for.
+	serviceAccountName string
+	// secretSuffix is appended to the cluster name to name the kubeconfig secret.
+	secretSuffix string
+}
+
+// coreKubeconfig is the kubeconfig of the core CAPI controllers.
+// Its secret is named after the cluster, as per the CAPI contract.
+//
+//nolint:gochecknoglobals
+var coreKubeconfig = kubeconfigTarget{
+	serviceAccountName: "capi-kubeconfig",
+	secretSuffix:       "-kubeconfig",
+}
+
+// providerKubeconfigs are the kubeconfigs of the infrastructure providers, by platform.
+//
+//nolint:gochecknoglobals
+var providerKubeconfigs = map[configv1.PlatformType]kubeconfigTarget{
+	configv1.AWSPlatformType: {
+		serviceAccountName: "capa-kubeconfig",
+		secretSuffix:       "-capa-kubeconfig",
+	},
+	configv1.AzurePlatformType: {
+		serviceAccountName: "capz-kubeconfig",
+		secretSuffix:       "-capz-kubeconfig",
+	},
+}
+
+// kubeconfigTargets returns the kubeconfig secrets to generate on the platform:
+// the core CAPI one, and the one of the infrastructure provider, if it has its own.
+func kubeconfigTargets(platform configv1.PlatformType) []kubeconfigTarget {
+	targets := []kubeconfigTarget{coreKubeconfig}
+
+	if target, ok := providerKubeconfigs[platform]; ok {
+		targets = append(targets, target)
+	}
+
+	return targets
+}
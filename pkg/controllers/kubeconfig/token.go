@@ -0,0 +1,164 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kubeconfig
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/utils/ptr"
+
+	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+)
+
+const (
+	// DefaultTokenLifetime is the default lifetime of the kubeconfig token, i.e. its rotation interval.
+	DefaultTokenLifetime = time.Hour
+	// DefaultTokenRenewBefore is the default early-renewal window of the kubeconfig token:
+	// the token is renewed this long before it expires, so the CAPI controllers never use an expired token.
+	DefaultTokenRenewBefore = 15 * time.Minute
+
+	// minTokenLifetime is the shortest lifetime the API server issues tokens for.
+	minTokenLifetime = 10 * time.Minute
+
+	// rootCAConfigMapName is the ConfigMap the root CA of the API server is published in, in every namespace.
+	rootCAConfigMapName = "kube-root-ca.crt"
+	rootCACertKey       = "ca.crt"
+
+	// tokenIssuedAtAnnotation and tokenExpiresAtAnnotation record, in RFC 3339 format,
+	// when the token of the kubeconfig secret was issued and when it expires.
+	tokenIssuedAtAnnotation  = "cluster-capi-operator.openshift.io/token-issued-at"
+	tokenExpiresAtAnnotation = "cluster-capi-operator.openshift.io/token-expires-at"
+)
+
+var (
+	errInvalidTokenLifetime    = errors.New("token lifetime must be at least 10 minutes")
+	errInvalidTokenRenewBefore = errors.New("token renewal window must be positive and shorter than the token lifetime")
+)
+
+// TokenRequester requests bound service account tokens, i.e. the ServiceAccounts client of the managed namespace.
+type TokenRequester interface {
+	CreateToken(ctx context.Context, serviceAccountName string, tokenRequest *authenticationv1.TokenRequest, opts metav1.CreateOptions) (*authenticationv1.TokenRequest, error)
+}
+
+// boundToken is a bound service account token, with its validity.
+type boundToken struct {
+	token     string
+	issuedAt  time.Time
+	expiresAt time.Time
+}
+
+// validateTokenRotation checks that the token lifetime and its renewal window are consistent.
+func validateTokenRotation(lifetime, renewBefore time.Duration) error {
+	if lifetime < minTokenLifetime {
+		return fmt.Errorf("%w: %s", errInvalidTokenLifetime, lifetime)
+	}
+
+	if renewBefore <= 0 || renewBefore >= lifetime {
+		return fmt.Errorf("%w: %s for a lifetime of %s", errInvalidTokenRenewBefore, renewBefore, lifetime)
+	}
+
+	return nil
+}
+
+// requestToken requests a bound token of the service account, valid for the token lifetime.
+// The API server may shorten or extend the lifetime, so the expiration it returns is used.
+func (r *KubeconfigReconciler) requestToken(ctx context.Context, serviceAccountName string, now time.Time) (boundToken, error) {
+	tokenRequest, err := r.TokenClient.CreateToken(ctx, serviceAccountName, &authenticationv1.TokenRequest{
+		Spec: authenticationv1.TokenRequestSpec{
+			ExpirationSeconds: ptr.To(int64(r.TokenLifetime.Seconds())),
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return boundToken{}, fmt.Errorf("unable to request token for service account %s/%s: %w", controllers.DefaultManagedNamespace, serviceAccountName, err)
+	}
+
+	return boundToken{
+		token:     tokenRequest.Status.Token,
+		issuedAt:  now,
+		expiresAt: tokenRequest.Status.ExpirationTimestamp.Time,
+	}, nil
+}
+
+// tokenRenewalTime returns when the token of the kubeconfig secret is due for renewal,
+// or false when the secret does not record the expiry of its token, e.g. when it was written with a legacy token.
+func tokenRenewalTime(secret *corev1.Secret, renewBefore time.Duration) (time.Time, bool) {
+	expiresAt, err := time.Parse(time.RFC3339, secret.Annotations[tokenExpiresAtAnnotation])
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return expiresAt.Add(-renewBefore), true
+}
+
+// setTokenAnnotations records the validity of the token on the kubeconfig secret.
+func setTokenAnnotations(secret *corev1.Secret, token boundToken) {
+	if secret.Annotations == nil {
+		secret.Annotations = map[string]string{}
+	}
+
+	secret.Annotations[tokenIssuedAtAnnotation] = token.issuedAt.UTC().Format(time.RFC3339)
+	secret.Annotations[tokenExpiresAtAnnotation] = token.expiresAt.UTC().Format(time.RFC3339)
+}
+
+// tokenAnnotationTime returns the time recorded in the annotation of the kubeconfig secret, or the zero time.
+func tokenAnnotationTime(secret *corev1.Secret, annotation string) time.Time {
+	t, err := time.Parse(time.RFC3339, secret.Annotations[annotation])
+	if err != nil {
+		return time.Time{}
+	}
+
+	return t
+}
+
+// kubeconfigToken returns the token of the current context of the kubeconfig of the secret, or an empty string.
+func kubeconfigToken(secret *corev1.Secret) string {
+	kubeconfig, err := clientcmd.Load(secret.Data["value"])
+	if err != nil {
+		return ""
+	}
+
+	kubeContext, ok := kubeconfig.Contexts[kubeconfig.CurrentContext]
+	if !ok {
+		return ""
+	}
+
+	authInfo, ok := kubeconfig.AuthInfos[kubeContext.AuthInfo]
+	if !ok {
+		return ""
+	}
+
+	return authInfo.Token
+}
+
+// isKubeconfigCurrent checks whether the kubeconfig of the secret targets the API server with the given CA.
+func isKubeconfigCurrent(secret *corev1.Secret, clusterName, server string, caCert []byte) bool {
+	kubeconfig, err := clientcmd.Load(secret.Data["value"])
+	if err != nil {
+		return false
+	}
+
+	cluster, ok := kubeconfig.Clusters[clusterName]
+
+	return ok && cluster.Server == server && bytes.Equal(cluster.CertificateAuthorityData, caCert)
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package kubeconfig
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var _ = Describe("validateTokenRotation", func() {
+	It("should accept the defaults", func() {
+		Expect(validateTokenRotation(DefaultTokenLifetime, DefaultTokenRenewBefore)).To(Succeed())
+	})
+
+	It("should reject a lifetime shorter than the API minimum", func() {
+		Expect(validateTokenRotation(5*time.Minute, time.Minute)).To(MatchError(errInvalidTokenLifetime))
+	})
+
+	It("should reject a renewal window not shorter than the lifetime", func() {
+		Expect(validateTokenRotation(time.Hour, time.Hour)).To(MatchError(errInvalidTokenRenewBefore))
+		Expect(validateTokenRotation(time.Hour, 0)).To(MatchError(errInvalidTokenRenewBefore))
+	})
+})
+
+var _ = Describe("tokenRenewalTime", func() {
+	It("should renew the token the renewal window before its expiry", func() {
+		secret := &corev1.Secret{}
+		setTokenAnnotations(secret, boundToken{
+			issuedAt:  time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+			expiresAt: time.Date(2024, 1, 1, 11, 0, 0, 0, time.UTC),
+		})
+
+		renewAt, ok := tokenRenewalTime(secret, 15*time.Minute)
+		Expect(ok).To(BeTrue())
+		Expect(renewAt).To(Equal(time.Date(2024, 1, 1, 10, 45, 0, 0, time.UTC)))
+		Expect(secret.Annotations).To(HaveKeyWithValue(tokenIssuedAtAnnotation, "2024-01-01T10:00:00Z"))
+	})
+
+	It("should not return a renewal time without token annotations", func() {
+		_, ok := tokenRenewalTime(&corev1.Secret{}, 15*time.Minute)
+		Expect(ok).To(BeFalse())
+	})
+})
+
+var _ = Describe("isKubeconfigCurrent", func() {
+	It("should match the server and CA of the kubeconfig, and return its token", func() {
+		kubeconfig, err := generateKubeconfig(kubeconfigOptions{
+			token:            []byte("token"),
+			caCert:           []byte("ca"),
+			apiServerEnpoint: "https://api:6443",
+			clusterName:      "test-cluster",
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		out, err := clientcmd.Write(*kubeconfig)
+		Expect(err).NotTo(HaveOccurred())
+
+		secret := &corev1.Secret{Data: map[string][]byte{"value": out}}
+		Expect(kubeconfigToken(secret)).To(Equal("token"))
+		Expect(kubeconfigToken(&corev1.Secret{})).To(BeEmpty())
+		Expect(isKubeconfigCurrent(secret, "test-cluster", "https://api:6443", []byte("ca"))).To(BeTrue())
+		Expect(isKubeconfigCurrent(secret, "test-cluster", "https://api:6443", []byte("rotated"))).To(BeFalse())
+		Expect(isKubeconfigCurrent(secret, "test-cluster", "https://other:6443", []byte("ca"))).To(BeFalse())
+		Expect(isKubeconfigCurrent(&corev1.Secret{}, "test-cluster", "https://api:6443", []byte("ca"))).To(BeFalse())
+	})
+})
@@ -16,7 +16,6 @@ limitations under the License.
 package kubeconfig
 
 import (
-	"context"
 	"strings"
 
 	corev1 "k8s.io/api/core/v1"
@@ -24,33 +23,14 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
-	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	"github.com/openshift/cluster-capi-operator/pkg/controllers"
 )
 
-func toTokenSecret(ctx context.Context, o client.Object) []reconcile.Request {
-	return []reconcile.Request{{
-		NamespacedName: client.ObjectKey{Name: tokenSecretName, Namespace: controllers.DefaultManagedNamespace},
-	}}
-}
-
-func tokenSecretPredicate() predicate.Funcs {
-	isOwnedTokenSecret := func(obj runtime.Object) bool {
-		secret, ok := obj.(*corev1.Secret)
-		if !ok {
-			panic("expected to get an of object of type corev1.Secret")
-		}
-
-		return secret.GetNamespace() == controllers.DefaultManagedNamespace && secret.GetName() == tokenSecretName
-	}
-
-	return predicate.Funcs{
-		CreateFunc:  func(e event.CreateEvent) bool { return isOwnedTokenSecret(e.Object) },
-		UpdateFunc:  func(e event.UpdateEvent) bool { return isOwnedTokenSecret(e.ObjectNew) },
-		DeleteFunc:  func(e event.DeleteEvent) bool { return isOwnedTokenSecret(e.Object) },
-		GenericFunc: func(e event.GenericEvent) bool { return isOwnedTokenSecret(e.Object) },
-	}
+func rootCAConfigMapPredicate() predicate.Funcs {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetNamespace() == controllers.DefaultManagedNamespace && obj.GetName() == rootCAConfigMapName
+	})
 }
 
 func kubeconfigSecretPredicate() predicate.Funcs {
@@ -0,0 +1,92 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package machinesetsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	configv1 "github.com/openshift/api/config/v1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	awscapiv1beta2 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	// errIAMInstanceProfileNotFound is returned when the IAM instance profile referenced by a MachineSet does not exist.
+	errIAMInstanceProfileNotFound = errors.New("IAM instance profile not found")
+)
+
+// preflightMAPIMachineSet runs platform specific checks against the converted InfraMachineTemplate
+// before it is created, so that problems that would only surface once CAPI tries to launch instances
+// are reported on the MAPI MachineSet instead.
+func (r *MachineSetSyncReconciler) preflightMAPIMachineSet(ctx context.Context, mapiMachineSet *machinev1beta1.MachineSet, infraMachineTemplate client.Object) error {
+	switch r.Platform {
+	case configv1.AWSPlatformType:
+		return r.preflightAWSIAMInstanceProfile(ctx, mapiMachineSet, infraMachineTemplate)
+	default:
+		return nil
+	}
+}
+
+// preflightAWSIAMInstanceProfile checks that the IAM instance profile of the converted AWSMachineTemplate exists.
+// The check is skipped when no IAM client is configured, or when the MachineSet has no credentials secret to check with.
+func (r *MachineSetSyncReconciler) preflightAWSIAMInstanceProfile(ctx context.Context, mapiMachineSet *machinev1beta1.MachineSet, infraMachineTemplate client.Object) error {
+	if r.AWSIAMClient == nil {
+		return nil
+	}
+
+	awsMachineTemplate, ok := infraMachineTemplate.(*awscapiv1beta2.AWSMachineTemplate)
+	if !ok {
+		return fmt.Errorf("%w: %T", errPlatformNotSupported, infraMachineTemplate)
+	}
+
+	instanceProfile := awsMachineTemplate.Spec.Template.Spec.IAMInstanceProfile
+	if instanceProfile == "" {
+		return nil
+	}
+
+	providerSpec := &machinev1beta1.AWSMachineProviderConfig{}
+	if mapiMachineSet.Spec.Template.Spec.ProviderSpec.Value != nil {
+		if err := yaml.Unmarshal(mapiMachineSet.Spec.Template.Spec.ProviderSpec.Value.Raw, providerSpec); err != nil {
+			return fmt.Errorf("failed to unmarshal AWS providerSpec: %w", err)
+		}
+	}
+
+	if providerSpec.CredentialsSecret == nil || providerSpec.CredentialsSecret.Name == "" {
+		return nil
+	}
+
+	region := providerSpec.Placement.Region
+	if region == "" && r.Infra != nil && r.Infra.Status.PlatformStatus != nil && r.Infra.Status.PlatformStatus.AWS != nil {
+		region = r.Infra.Status.PlatformStatus.AWS.Region
+	}
+
+	credentialsSecret := client.ObjectKey{Namespace: mapiMachineSet.Namespace, Name: providerSpec.CredentialsSecret.Name}
+
+	exists, err := r.AWSIAMClient.InstanceProfileExists(ctx, credentialsSecret, region, instanceProfile)
+	if err != nil {
+		return fmt.Errorf("failed to check IAM instance profile %q: %w", instanceProfile, err)
+	}
+
+	if !exists {
+		return fmt.Errorf("%w: %q", errIAMInstanceProfileNotFound, instanceProfile)
+	}
+
+	return nil
+}
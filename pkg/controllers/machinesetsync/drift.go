@@ -0,0 +1,89 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package machinesetsync
+
+import (
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/equality"
+	capiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// machineSetDrifted reports, per MAPI MachineSet, whether the last synchronization found the spec of
+// the non-authoritative copy changed since the previous synchronization.
+var machineSetDrifted = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "mapi_machineset_sync_drifted",
+	Help: "Whether the non-authoritative copy of the MachineSet was found to differ from what conversion produces (1) or not (0).",
+}, []string{"namespace", "name"})
+
+func init() {
+	metrics.Registry.MustRegister(machineSetDrifted)
+}
+
+// setMachineSetDriftedMetric records whether drift was found for the MAPI MachineSet.
+func setMachineSetDriftedMetric(mapiMachineSet *machinev1beta1.MachineSet, drift []string) {
+	value := 0.0
+	if len(drift) > 0 {
+		value = 1.0
+	}
+
+	machineSetDrifted.WithLabelValues(mapiMachineSet.GetNamespace(), mapiMachineSet.GetName()).Set(value)
+}
+
+// deleteMachineSetDriftedMetric removes the drift metric of a MAPI MachineSet that no longer exists.
+func deleteMachineSetDriftedMetric(namespace, name string) {
+	machineSetDrifted.DeleteLabelValues(namespace, name)
+}
+
+// capiMachineSetSpecDrift returns the paths of the CAPI MachineSet spec fields that differ from the converted MachineSet.
+// Metadata is not compared, as label and annotation changes on the authoritative MachineSet cannot be told apart
+// from changes to the non-authoritative copy.
+func capiMachineSetSpecDrift(existing, converted *capiv1beta1.MachineSet) []string {
+	existingSpec, convertedSpec := existing.Spec, converted.Spec
+	drift := []string{}
+
+	appendIfDifferent := func(path string, a, b interface{}) {
+		if !equality.Semantic.DeepEqual(a, b) {
+			drift = append(drift, path)
+		}
+	}
+
+	appendIfDifferent("spec.clusterName", existingSpec.ClusterName, convertedSpec.ClusterName)
+	appendIfDifferent("spec.replicas", existingSpec.Replicas, convertedSpec.Replicas)
+	appendIfDifferent("spec.minReadySeconds", existingSpec.MinReadySeconds, convertedSpec.MinReadySeconds)
+	appendIfDifferent("spec.deletePolicy", existingSpec.DeletePolicy, convertedSpec.DeletePolicy)
+	appendIfDifferent("spec.selector", existingSpec.Selector, convertedSpec.Selector)
+	appendIfDifferent("spec.template.metadata", existingSpec.Template.ObjectMeta, convertedSpec.Template.ObjectMeta)
+
+	existingMachineSpec, convertedMachineSpec := existingSpec.Template.Spec, convertedSpec.Template.Spec
+	appendIfDifferent("spec.template.spec.clusterName", existingMachineSpec.ClusterName, convertedMachineSpec.ClusterName)
+	appendIfDifferent("spec.template.spec.bootstrap", existingMachineSpec.Bootstrap, convertedMachineSpec.Bootstrap)
+	appendIfDifferent("spec.template.spec.infrastructureRef", existingMachineSpec.InfrastructureRef, convertedMachineSpec.InfrastructureRef)
+	appendIfDifferent("spec.template.spec.version", existingMachineSpec.Version, convertedMachineSpec.Version)
+	appendIfDifferent("spec.template.spec.providerID", existingMachineSpec.ProviderID, convertedMachineSpec.ProviderID)
+	appendIfDifferent("spec.template.spec.failureDomain", existingMachineSpec.FailureDomain, convertedMachineSpec.FailureDomain)
+	appendIfDifferent("spec.template.spec.nodeDrainTimeout", existingMachineSpec.NodeDrainTimeout, convertedMachineSpec.NodeDrainTimeout)
+	appendIfDifferent("spec.template.spec.nodeVolumeDetachTimeout", existingMachineSpec.NodeVolumeDetachTimeout, convertedMachineSpec.NodeVolumeDetachTimeout)
+	appendIfDifferent("spec.template.spec.nodeDeletionTimeout", existingMachineSpec.NodeDeletionTimeout, convertedMachineSpec.NodeDeletionTimeout)
+
+	// Catch fields added to the spec in future API versions.
+	if len(drift) == 0 && !equality.Semantic.DeepEqual(existingSpec, convertedSpec) {
+		drift = append(drift, "spec")
+	}
+
+	return drift
+}
@@ -0,0 +1,157 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package machinesetsync
+
+import (
+	"context"
+	"fmt"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	capiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// MigrationDryRunAnnotation is set to "true" on a MAPI MachineSet to render the converted CAPI resources
+	// to a ConfigMap for review, instead of creating or updating the CAPI resources.
+	MigrationDryRunAnnotation = "machine.openshift.io/migration-dry-run"
+
+	// migrationDryRunConfigMapSuffix is appended to the MAPI MachineSet name to name the dry run ConfigMap.
+	migrationDryRunConfigMapSuffix = "-migration-dry-run"
+
+	// migrationDryRunMachineSetKey is the ConfigMap key holding the rendered CAPI MachineSet.
+	migrationDryRunMachineSetKey = "machineset.yaml"
+
+	// migrationDryRunInfraMachineTemplateKey is the ConfigMap key holding the rendered InfraMachineTemplate.
+	migrationDryRunInfraMachineTemplateKey = "inframachinetemplate.yaml"
+)
+
+// isMigrationDryRun returns whether the MAPI MachineSet requests a migration dry run.
+func isMigrationDryRun(mapiMachineSet *machinev1beta1.MachineSet) bool {
+	return mapiMachineSet.GetAnnotations()[MigrationDryRunAnnotation] == "true"
+}
+
+// migrationDryRunConfigMapKey returns the key of the dry run ConfigMap of the MAPI MachineSet.
+func migrationDryRunConfigMapKey(mapiMachineSet *machinev1beta1.MachineSet) client.ObjectKey {
+	return client.ObjectKey{Namespace: mapiMachineSet.GetNamespace(), Name: mapiMachineSet.GetName() + migrationDryRunConfigMapSuffix}
+}
+
+// ensureMigrationDryRunConfigMap renders the converted CAPI resources to the dry run ConfigMap of the MAPI MachineSet.
+func (r *MachineSetSyncReconciler) ensureMigrationDryRunConfigMap(ctx context.Context, mapiMachineSet *machinev1beta1.MachineSet,
+	capiMachineSet *capiv1beta1.MachineSet, infraMachineTemplate client.Object) (*corev1.ConfigMap, error) {
+	newConfigMap, err := r.renderMigrationDryRunConfigMap(mapiMachineSet, capiMachineSet, infraMachineTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render dry run ConfigMap: %w", err)
+	}
+
+	existingConfigMap := &corev1.ConfigMap{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(newConfigMap), existingConfigMap); apierrors.IsNotFound(err) {
+		if err := r.Create(ctx, newConfigMap); err != nil {
+			return nil, fmt.Errorf("failed to create dry run ConfigMap: %w", err)
+		}
+
+		return newConfigMap, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get dry run ConfigMap: %w", err)
+	}
+
+	existingConfigMap.Data = newConfigMap.Data
+	if err := r.Update(ctx, existingConfigMap); err != nil {
+		return nil, fmt.Errorf("failed to update dry run ConfigMap: %w", err)
+	}
+
+	return existingConfigMap, nil
+}
+
+// deleteMigrationDryRunConfigMap removes the dry run ConfigMap of the MAPI MachineSet once the dry run is over.
+func (r *MachineSetSyncReconciler) deleteMigrationDryRunConfigMap(ctx context.Context, mapiMachineSet *machinev1beta1.MachineSet) error {
+	configMap := &corev1.ConfigMap{}
+	if err := r.Get(ctx, migrationDryRunConfigMapKey(mapiMachineSet), configMap); apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get dry run ConfigMap: %w", err)
+	}
+
+	if err := r.Delete(ctx, configMap); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to delete dry run ConfigMap: %w", err)
+	}
+
+	return nil
+}
+
+// renderMigrationDryRunConfigMap returns the dry run ConfigMap holding the converted CAPI resources as YAML.
+// The ConfigMap is owned by the MAPI MachineSet so that it is removed along with it.
+func (r *MachineSetSyncReconciler) renderMigrationDryRunConfigMap(mapiMachineSet *machinev1beta1.MachineSet,
+	capiMachineSet *capiv1beta1.MachineSet, infraMachineTemplate client.Object) (*corev1.ConfigMap, error) {
+	renderedMachineSet, err := r.renderObject(capiMachineSet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render CAPI MachineSet: %w", err)
+	}
+
+	renderedInfraMachineTemplate, err := r.renderObject(infraMachineTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render CAPI InfraMachineTemplate: %w", err)
+	}
+
+	key := migrationDryRunConfigMapKey(mapiMachineSet)
+
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      key.Name,
+			Namespace: key.Namespace,
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion:         machinev1beta1.GroupVersion.String(),
+				Kind:               "MachineSet",
+				Name:               mapiMachineSet.GetName(),
+				UID:                mapiMachineSet.GetUID(),
+				Controller:         ptr.To(true),
+				BlockOwnerDeletion: ptr.To(true),
+			}},
+		},
+		Data: map[string]string{
+			migrationDryRunMachineSetKey:           renderedMachineSet,
+			migrationDryRunInfraMachineTemplateKey: renderedInfraMachineTemplate,
+		},
+	}, nil
+}
+
+// renderObject renders the object as YAML, including its apiVersion and kind so that it can be applied as is.
+func (r *MachineSetSyncReconciler) renderObject(obj client.Object) (string, error) {
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+	if err != nil {
+		return "", fmt.Errorf("failed to get GroupVersionKind: %w", err)
+	}
+
+	rendered, ok := obj.DeepCopyObject().(client.Object)
+	if !ok {
+		return "", fmt.Errorf("%w: %T", errPlatformNotSupported, obj)
+	}
+
+	rendered.GetObjectKind().SetGroupVersionKind(gvk)
+
+	out, err := yaml.Marshal(rendered)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal: %w", err)
+	}
+
+	return string(out), nil
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package machinesetsync
+
+import (
+	"context"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+	"github.com/openshift/cluster-capi-operator/pkg/util"
+)
+
+const (
+	reasonAuthoritativeAPIChanged string = "AuthoritativeAPIChanged"
+)
+
+// authoritativeAPIChangedEventHandler records an event on the MAPI MachineSet whenever its authoritative API
+// changes, so that the migration history can be read from the MachineSet events.
+// It does not enqueue anything, the MachineSet is already reconciled for the update.
+func (r *MachineSetSyncReconciler) authoritativeAPIChangedEventHandler() handler.EventHandler {
+	return handler.Funcs{
+		UpdateFunc: func(_ context.Context, e event.UpdateEvent, _ workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			oldMachineSet, ok := e.ObjectOld.(*machinev1beta1.MachineSet)
+			if !ok {
+				return
+			}
+
+			newMachineSet, ok := e.ObjectNew.(*machinev1beta1.MachineSet)
+			if !ok {
+				return
+			}
+
+			if message, changed := authoritativeAPIChangedMessage(oldMachineSet, newMachineSet); changed {
+				r.Recorder.Event(newMachineSet, corev1.EventTypeNormal, reasonAuthoritativeAPIChanged, message)
+			}
+		},
+	}
+}
+
+// authoritativeAPIChangedMessage returns the event message for a change of the authoritative API in the status of the
+// MachineSet. The authoritative API being set for the first time is not a change.
+func authoritativeAPIChangedMessage(oldMachineSet, newMachineSet *machinev1beta1.MachineSet) (string, bool) {
+	oldAuthority, newAuthority := oldMachineSet.Status.AuthoritativeAPI, newMachineSet.Status.AuthoritativeAPI
+	if oldAuthority == "" || oldAuthority == newAuthority {
+		return "", false
+	}
+
+	return "Authoritative API changed from " + string(oldAuthority) + " to " + string(newAuthority), true
+}
+
+// isSynchronizedTransition returns whether setting the Synchronized condition to the status moves it to True,
+// so that completed synchronization is recorded once rather than on every reconcile.
+// Failures record their own events, with the reason of the failure.
+func isSynchronizedTransition(oldConditions []machinev1beta1.Condition, status corev1.ConditionStatus) bool {
+	if status != corev1.ConditionTrue {
+		return false
+	}
+
+	condition := util.GetMAPICondition(oldConditions, controllers.SynchronizedCondition)
+
+	return condition == nil || condition.Status != corev1.ConditionTrue
+}
@@ -20,15 +20,31 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
 	configv1 "github.com/openshift/api/config/v1"
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	awscloud "github.com/openshift/cluster-capi-operator/pkg/cloud/aws"
+	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/mapi2capi"
+	"github.com/openshift/cluster-capi-operator/pkg/operatorconfig"
 	"github.com/openshift/cluster-capi-operator/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/client-go/tools/record"
-	awscapiv1beta1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta1"
+	"k8s.io/utils/ptr"
+	awscapiv1beta2 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	azurecapiv1beta1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	gcpcapiv1beta1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	powervscapiv1beta2 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
+	openstackcapiv1beta1 "sigs.k8s.io/cluster-api-provider-openstack/api/v1beta1"
+	vspherecapiv1beta1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	capiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/annotations"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -40,11 +56,38 @@ import (
 const (
 	capiNamespace string = "openshift-cluster-api"
 	mapiNamespace string = "openshift-machine-api"
+
+	reasonFailedToConvertMAPIMachineSetToCAPI    string = "FailedToConvertMAPIMachineSetToCAPI"
+	reasonFailedToUpdateCAPIInfraMachineTemplate string = "FailedToUpdateCAPIInfraMachineTemplate"
+	reasonFailedToDeleteCAPIInfraMachineTemplate string = "FailedToDeleteCAPIInfraMachineTemplate"
+	reasonFailedToUpdateCAPIMachineSet           string = "FailedToUpdateCAPIMachineSet"
+	reasonResourceSynchronized                   string = "ResourceSynchronized"
+	reasonConversionWarning                      string = "ConversionWarning"
+	reasonIAMInstanceProfileNotFound             string = "IAMInstanceProfileNotFound"
+	reasonFailedPreflightCheck                   string = "FailedPreflightCheck"
+	reasonUnsupportedOnCAPI                      string = "UnsupportedOnCAPI"
+	reasonInvalidSyncConflictPolicy              string = "InvalidSyncConflictPolicy"
+	reasonDrifted                                string = "Drifted"
+	reasonMigrationDryRun                        string = "MigrationDryRun"
+	reasonFailedToRenderMigrationDryRun          string = "FailedToRenderMigrationDryRun"
+
+	messageSuccessfullySynchronizedMAPItoCAPI string = "Successfully synchronized MAPI MachineSet to CAPI"
+
+	// preflightRequeueAfter is how long to wait before re-running a failed preflight check that depends on cloud resources.
+	preflightRequeueAfter = 1 * time.Minute
 )
 
 var (
 	// errPlatformNotSupported is returned when the platform is not supported.
 	errPlatformNotSupported = errors.New("error determining InfraMachineTemplate type, platform not supported")
+
+	// errInfraMachineTemplateChanged is returned when the existing InfraMachineTemplate of the converted name differs from the converted one.
+	// InfraMachineTemplates are immutable, so they cannot be updated in place.
+	errInfraMachineTemplateChanged = errors.New("existing InfraMachineTemplate differs from the converted MAPI MachineSet, InfraMachineTemplates are immutable")
+
+	// errCAPIMachineSetConflict is returned when the CAPI MachineSet spec drifted since the last synchronization
+	// and the sync conflict policy does not allow overwriting it.
+	errCAPIMachineSetConflict = errors.New("CAPI MachineSet spec was changed since the last synchronization and differs from the converted MAPI MachineSet")
 )
 
 // MachineSetSyncReconciler reconciles CAPI and MAPI MachineSets.
@@ -53,9 +96,19 @@ type MachineSetSyncReconciler struct {
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
 
+	Infra         *configv1.Infrastructure
 	Platform      configv1.PlatformType
 	CAPINamespace string
 	MAPINamespace string
+
+	// AWSIAMClient is used to check that the IAM instance profiles referenced by AWS MachineSets exist.
+	// When nil, the check is skipped.
+	AWSIAMClient awscloud.IAMInstanceProfileClient
+
+	// AzureManagedBootDiagnostics enables managed boot diagnostics on the AzureMachineTemplates
+	// of MachineSets that do not configure boot diagnostics.
+	// The azureManagedBootDiagnostics feature of the ClusterCAPIOperatorConfig takes precedence.
+	AzureManagedBootDiagnostics bool
 }
 
 // SetupWithManager sets the CoreClusterReconciler controller up with the given manager.
@@ -86,6 +139,16 @@ func (r *MachineSetSyncReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			handler.EnqueueRequestsFromMapFunc(util.ResolveCAPIMachineSetFromObject(r.MAPINamespace)),
 			builder.WithPredicates(util.FilterNamespace(r.CAPINamespace)),
 		).
+		Watches(
+			operatorconfig.New(),
+			handler.EnqueueRequestsFromMapFunc(operatorconfig.EnqueueAll(mgr.GetClient(), &machinev1beta1.MachineSetList{}, r.MAPINamespace)),
+			builder.WithPredicates(operatorconfig.Predicate()),
+		).
+		Watches(
+			&machinev1beta1.MachineSet{},
+			r.authoritativeAPIChangedEventHandler(),
+			builder.WithPredicates(util.FilterNamespace(r.MAPINamespace)),
+		).
 		Complete(r); err != nil {
 		return fmt.Errorf("failed to create controller: %w", err)
 	}
@@ -107,6 +170,16 @@ func (r *MachineSetSyncReconciler) Reconcile(ctx context.Context, req reconcile.
 	logger.V(1).Info("Reconciling machineset")
 	defer logger.V(1).Info("Finished reconciling machineset")
 
+	config, err := operatorconfig.GetSpec(ctx, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if config.Controllers.MachineSetSync.Disabled {
+		logger.V(1).Info("MachineSet sync is disabled by the operator config, skipping synchronization")
+		return ctrl.Result{}, nil
+	}
+
 	var mapiMachineSetNotFound, capiMachineSetNotFound bool
 
 	// Get the MAPI MachineSet.
@@ -120,6 +193,8 @@ func (r *MachineSetSyncReconciler) Reconcile(ctx context.Context, req reconcile.
 		logger.Info("MAPI MachineSet not found")
 
 		mapiMachineSetNotFound = true
+
+		deleteMachineSetDriftedMetric(r.MAPINamespace, req.Name)
 	} else if err != nil {
 		logger.Error(err, "Failed to get MAPI MachineSet")
 		return ctrl.Result{}, fmt.Errorf("failed to get MAPI MachineSet: %w", err)
@@ -152,10 +227,20 @@ func (r *MachineSetSyncReconciler) Reconcile(ctx context.Context, req reconcile.
 		return ctrl.Result{}, nil
 	}
 
+	// Pausing either copy stops the controller from overwriting manual edits, e.g. while debugging a divergence.
+	if annotations.HasPaused(mapiMachineSet) || annotations.HasPaused(capiMachineSet) {
+		logger.Info("MachineSet is paused, skipping synchronization", "annotation", capiv1beta1.PausedAnnotation)
+		return ctrl.Result{}, nil
+	}
+
 	switch mapiMachineSet.Status.AuthoritativeAPI {
 	case machinev1beta1.MachineAuthorityMachineAPI:
-		return r.reconcileMAPIMachineSettoCAPIMachineSet(ctx, mapiMachineSet, capiMachineSet)
+		return r.reconcileMAPIMachineSettoCAPIMachineSet(ctx, mapiMachineSet, capiMachineSet, config.Features)
 	case machinev1beta1.MachineAuthorityClusterAPI:
+		if isRollbackToMAPIRequested(mapiMachineSet) {
+			return r.reconcileRollbackToMAPI(ctx, capiMachineSet, mapiMachineSet)
+		}
+
 		return r.reconcileCAPIMachineSettoMAPIMachineSet(ctx, capiMachineSet, mapiMachineSet)
 	case machinev1beta1.MachineAuthorityMigrating:
 		logger.Info("machine currently migrating", "machine", mapiMachineSet.GetName())
@@ -172,8 +257,409 @@ func (r *MachineSetSyncReconciler) reconcileCAPIMachineSettoMAPIMachineSet(ctx c
 }
 
 // reconcileMAPIMachineSettoCAPIMachineSet MAPI MachineSet to a CAPI MachineSet.
-func (r *MachineSetSyncReconciler) reconcileMAPIMachineSettoCAPIMachineSet(ctx context.Context, mapiMachineSet *machinev1beta1.MachineSet, capiMachineSet *capiv1beta1.MachineSet) (ctrl.Result, error) {
-	return ctrl.Result{}, nil
+// The features of the ClusterCAPIOperatorConfig take precedence over the ones of the reconciler.
+func (r *MachineSetSyncReconciler) reconcileMAPIMachineSettoCAPIMachineSet(ctx context.Context, mapiMachineSet *machinev1beta1.MachineSet, capiMachineSet *capiv1beta1.MachineSet, features operatorconfig.Features) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	conflictPolicy, err := controllers.GetSyncConflictPolicy(mapiMachineSet)
+	if err != nil {
+		logger.Error(err, "Invalid sync conflict policy")
+		r.Recorder.Event(mapiMachineSet, corev1.EventTypeWarning, reasonInvalidSyncConflictPolicy, err.Error())
+
+		// The policy can only be fixed by updating the MAPI MachineSet annotations, which triggers a new reconcile.
+		return ctrl.Result{}, r.applySynchronizedConditionWithPatch(ctx, mapiMachineSet, corev1.ConditionFalse,
+			reasonInvalidSyncConflictPolicy, err.Error(), nil)
+	}
+
+	newCAPIMachineSet, newCAPIInfraMachineTemplate, warns, err := r.convertMAPIToCAPIMachineSet(mapiMachineSet, features)
+	if err != nil {
+		conversionErr := fmt.Errorf("failed to convert MAPI MachineSet to CAPI MachineSet: %w", err)
+		logger.Error(conversionErr, "Unable to convert MAPI MachineSet to CAPI")
+
+		// Fields that have no CAPI equivalent get their own reason, so that users can tell them apart
+		// from conversion failures that may be resolved by a future version of the conversion.
+		reason := reasonFailedToConvertMAPIMachineSetToCAPI
+		if mapi2capi.IsUnsupportedOnCAPI(err) {
+			reason = reasonUnsupportedOnCAPI
+		}
+
+		r.Recorder.Event(mapiMachineSet, corev1.EventTypeWarning, reason, conversionErr.Error())
+
+		// Conversion errors can only be resolved by updating the MAPI MachineSet, which triggers a new reconcile,
+		// so surface the error on the Synchronized condition instead of requeueing.
+		return ctrl.Result{}, r.applySynchronizedConditionWithPatch(ctx, mapiMachineSet, corev1.ConditionFalse,
+			reason, conversionErr.Error(), nil)
+	}
+
+	for _, warning := range warns {
+		logger.Info("Warning during conversion", "warning", warning)
+		r.Recorder.Event(mapiMachineSet, corev1.EventTypeWarning, reasonConversionWarning, warning)
+	}
+
+	newCAPIMachineSet.SetNamespace(r.CAPINamespace)
+	newCAPIMachineSet.Spec.Template.Spec.InfrastructureRef.Namespace = r.CAPINamespace
+	newCAPIInfraMachineTemplate.SetNamespace(r.CAPINamespace)
+
+	var preflightErr error
+	if err := r.preflightMAPIMachineSet(ctx, mapiMachineSet, newCAPIInfraMachineTemplate); errors.Is(err, errIAMInstanceProfileNotFound) {
+		preflightErr = fmt.Errorf("failed preflight check: %w", err)
+		logger.Error(preflightErr, "Referenced IAM instance profile does not exist")
+		r.Recorder.Event(mapiMachineSet, corev1.EventTypeWarning, reasonIAMInstanceProfileNotFound, preflightErr.Error())
+
+		// A missing instance profile is resolved outside of the cluster, so there is no event to watch for.
+		// Surface it on the Synchronized condition and check again later.
+		return ctrl.Result{RequeueAfter: preflightRequeueAfter}, r.applySynchronizedConditionWithPatch(ctx, mapiMachineSet, corev1.ConditionFalse,
+			reasonIAMInstanceProfileNotFound, preflightErr.Error(), nil)
+	} else if err != nil {
+		// Only a confirmed missing instance profile blocks the synchronization. When the check itself fails,
+		// e.g. as the cloud API is unreachable or the credentials lack permissions, the sync goes on,
+		// and the failure is reported on the Synchronized condition until the check succeeds.
+		preflightErr = fmt.Errorf("unable to run preflight check: %w", err)
+		logger.Error(preflightErr, "Preflight check failed, continuing with the synchronization")
+		r.Recorder.Event(mapiMachineSet, corev1.EventTypeWarning, reasonFailedPreflightCheck, preflightErr.Error())
+	}
+
+	if err := r.setInfraMachineTemplateName(ctx, capiMachineSet, newCAPIMachineSet, newCAPIInfraMachineTemplate); err != nil {
+		nameErr := fmt.Errorf("failed to name CAPI InfraMachineTemplate: %w", err)
+
+		if condErr := r.applySynchronizedConditionWithPatch(ctx, mapiMachineSet, corev1.ConditionFalse,
+			reasonFailedToUpdateCAPIInfraMachineTemplate, nameErr.Error(), nil); condErr != nil {
+			return ctrl.Result{}, utilerrors.NewAggregate([]error{nameErr, condErr})
+		}
+
+		return ctrl.Result{}, nameErr
+	}
+
+	if isMigrationDryRun(mapiMachineSet) {
+		return r.reconcileMigrationDryRun(ctx, mapiMachineSet, newCAPIMachineSet, newCAPIInfraMachineTemplate)
+	}
+
+	if err := r.deleteMigrationDryRunConfigMap(ctx, mapiMachineSet); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to clean up migration dry run: %w", err)
+	}
+
+	if err := r.ensureCAPIInfraMachineTemplate(ctx, newCAPIInfraMachineTemplate); err != nil {
+		updateErr := fmt.Errorf("failed to ensure CAPI InfraMachineTemplate: %w", err)
+
+		if condErr := r.applySynchronizedConditionWithPatch(ctx, mapiMachineSet, corev1.ConditionFalse,
+			reasonFailedToUpdateCAPIInfraMachineTemplate, updateErr.Error(), nil); condErr != nil {
+			return ctrl.Result{}, utilerrors.NewAggregate([]error{updateErr, condErr})
+		}
+
+		return ctrl.Result{}, updateErr
+	}
+
+	// When the MAPI MachineSet spec has already been synchronized, any difference in the CAPI MachineSet spec
+	// was introduced on the CAPI side.
+	drift := []string{}
+	if capiMachineSet.GetName() != "" && mapiMachineSet.Status.SynchronizedGeneration == mapiMachineSet.Generation {
+		drift = capiMachineSetSpecDrift(capiMachineSet, newCAPIMachineSet)
+	}
+
+	setMachineSetDriftedMetric(mapiMachineSet, drift)
+
+	if len(drift) > 0 && conflictPolicy != controllers.SyncConflictPolicyFailAndReport {
+		r.Recorder.Eventf(mapiMachineSet, corev1.EventTypeWarning, reasonDrifted,
+			"CAPI MachineSet drifted from the converted MAPI MachineSet in %s, reverting with sync conflict policy %q", strings.Join(drift, ", "), conflictPolicy)
+	}
+
+	if err := r.ensureCAPIMachineSet(ctx, conflictPolicy, drift, capiMachineSet, newCAPIMachineSet); errors.Is(err, errCAPIMachineSetConflict) {
+		conflictErr := fmt.Errorf("failed to ensure CAPI MachineSet with sync conflict policy %q: %w", conflictPolicy, err)
+		logger.Error(conflictErr, "CAPI MachineSet drifted from the MAPI MachineSet")
+		r.Recorder.Event(mapiMachineSet, corev1.EventTypeWarning, reasonDrifted, conflictErr.Error())
+
+		// The drift is resolved by updating either MachineSet, or by changing the policy, all of which trigger a new reconcile.
+		return ctrl.Result{}, r.applySynchronizedConditionWithPatch(ctx, mapiMachineSet, corev1.ConditionFalse,
+			reasonDrifted, conflictErr.Error(), nil)
+	} else if err != nil {
+		updateErr := fmt.Errorf("failed to ensure CAPI MachineSet: %w", err)
+
+		if condErr := r.applySynchronizedConditionWithPatch(ctx, mapiMachineSet, corev1.ConditionFalse,
+			reasonFailedToUpdateCAPIMachineSet, updateErr.Error(), nil); condErr != nil {
+			return ctrl.Result{}, utilerrors.NewAggregate([]error{updateErr, condErr})
+		}
+
+		return ctrl.Result{}, updateErr
+	}
+
+	if err := r.deleteUnreferencedInfraMachineTemplates(ctx, newCAPIMachineSet); err != nil {
+		// The leftover templates don't affect the synchronization, they are deleted on a later reconcile.
+		logger.Error(err, "Failed to delete unreferenced CAPI InfraMachineTemplates")
+		r.Recorder.Event(mapiMachineSet, corev1.EventTypeWarning, reasonFailedToDeleteCAPIInfraMachineTemplate, err.Error())
+	}
+
+	if preflightErr != nil {
+		// The failed check is resolved outside of the cluster, so there is no event to watch for, check again later.
+		return ctrl.Result{RequeueAfter: preflightRequeueAfter}, r.applySynchronizedConditionWithPatch(ctx, mapiMachineSet, corev1.ConditionTrue,
+			reasonResourceSynchronized, fmt.Sprintf("%s, but %s", messageSuccessfullySynchronizedMAPItoCAPI, preflightErr), &mapiMachineSet.Generation)
+	}
+
+	return ctrl.Result{}, r.applySynchronizedConditionWithPatch(ctx, mapiMachineSet, corev1.ConditionTrue,
+		reasonResourceSynchronized, messageSuccessfullySynchronizedMAPItoCAPI, &mapiMachineSet.Generation)
+}
+
+// reconcileMigrationDryRun renders the converted CAPI resources to the dry run ConfigMap instead of creating or updating them,
+// so that they can be reviewed before the MAPI MachineSet is synchronized to CAPI.
+func (r *MachineSetSyncReconciler) reconcileMigrationDryRun(ctx context.Context, mapiMachineSet *machinev1beta1.MachineSet,
+	newCAPIMachineSet *capiv1beta1.MachineSet, newCAPIInfraMachineTemplate client.Object) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	configMap, err := r.ensureMigrationDryRunConfigMap(ctx, mapiMachineSet, newCAPIMachineSet, newCAPIInfraMachineTemplate)
+	if err != nil {
+		dryRunErr := fmt.Errorf("failed to render migration dry run: %w", err)
+
+		if condErr := r.applySynchronizedConditionWithPatch(ctx, mapiMachineSet, corev1.ConditionFalse,
+			reasonFailedToRenderMigrationDryRun, dryRunErr.Error(), nil); condErr != nil {
+			return ctrl.Result{}, utilerrors.NewAggregate([]error{dryRunErr, condErr})
+		}
+
+		return ctrl.Result{}, dryRunErr
+	}
+
+	message := fmt.Sprintf("Rendered the converted CAPI resources to ConfigMap %s/%s, CAPI resources are not updated while %s is set",
+		configMap.GetNamespace(), configMap.GetName(), MigrationDryRunAnnotation)
+	logger.Info("Rendered migration dry run", "configMap", client.ObjectKeyFromObject(configMap))
+	r.Recorder.Event(mapiMachineSet, corev1.EventTypeNormal, reasonMigrationDryRun, message)
+
+	return ctrl.Result{}, r.applySynchronizedConditionWithPatch(ctx, mapiMachineSet, corev1.ConditionFalse,
+		reasonMigrationDryRun, message, nil)
+}
+
+// convertMAPIToCAPIMachineSet converts a MAPI MachineSet to a CAPI MachineSet and InfraMachineTemplate
+// for the platform the reconciler is running on. The operator level defaults, which only ever fill in settings
+// the MAPI MachineSet does not configure, are applied by the conversion.
+// The features of the ClusterCAPIOperatorConfig take precedence over the ones of the reconciler.
+func (r *MachineSetSyncReconciler) convertMAPIToCAPIMachineSet(mapiMachineSet *machinev1beta1.MachineSet,
+	features operatorconfig.Features) (*capiv1beta1.MachineSet, client.Object, []string, error) {
+	switch r.Platform {
+	case configv1.AWSPlatformType:
+		return mapi2capi.FromAWSMachineSetAndInfra(mapiMachineSet, r.Infra).ToMachineSetAndMachineTemplate() //nolint:wrapcheck
+	case configv1.AzurePlatformType:
+		return mapi2capi.FromAzureMachineSetAndInfraWithOptions(mapiMachineSet, r.Infra, mapi2capi.AzureConversionOptions{
+			ManagedBootDiagnostics: ptr.Deref(features.AzureManagedBootDiagnostics, r.AzureManagedBootDiagnostics),
+		}).ToMachineSetAndMachineTemplate() //nolint:wrapcheck
+	case configv1.GCPPlatformType:
+		return mapi2capi.FromGCPMachineSetAndInfra(mapiMachineSet, r.Infra).ToMachineSetAndMachineTemplate() //nolint:wrapcheck
+	case configv1.OpenStackPlatformType:
+		return mapi2capi.FromOpenStackMachineSetAndInfra(mapiMachineSet, r.Infra).ToMachineSetAndMachineTemplate() //nolint:wrapcheck
+	case configv1.PowerVSPlatformType:
+		return mapi2capi.FromPowerVSMachineSetAndInfra(mapiMachineSet, r.Infra).ToMachineSetAndMachineTemplate() //nolint:wrapcheck
+	case configv1.VSpherePlatformType:
+		return mapi2capi.FromVSphereMachineSetAndInfra(mapiMachineSet, r.Infra).ToMachineSetAndMachineTemplate() //nolint:wrapcheck
+	// TODO(OCPCLOUD-xxxx): Nutanix Machines are not converted, neither the MAPI NutanixMachineProviderConfig nor the CAPX API is vendored.
+	// TODO(OCPCLOUD-xxxx): BareMetal Machines are not converted, neither the MAPI BareMetalMachineProviderSpec nor the CAPM3 API is vendored.
+	// TODO(OCPCLOUD-xxxx): IBM Cloud VPC Machines are not converted, neither the MAPI IBMCloudMachineProviderSpec nor the CAPIBM VPC API is vendored.
+	default:
+		return nil, nil, nil, fmt.Errorf("%w: %s", errPlatformNotSupported, r.Platform)
+	}
+}
+
+// setInfraMachineTemplateName names the converted InfraMachineTemplate, and points the converted CAPI MachineSet to it.
+// As InfraMachineTemplates are immutable, the template referenced by the existing CAPI MachineSet is kept only while its spec
+// matches the converted one. Otherwise, e.g. after a change of the MAPI providerSpec, the template is rotated:
+// it is named after the MachineSet suffixed with the hash of its spec, so it is created next to the previous template.
+func (r *MachineSetSyncReconciler) setInfraMachineTemplateName(ctx context.Context, existingCAPIMachineSet, newCAPIMachineSet *capiv1beta1.MachineSet,
+	newTemplate client.Object) error {
+	newRef := &newCAPIMachineSet.Spec.Template.Spec.InfrastructureRef
+
+	if existingRef := existingCAPIMachineSet.Spec.Template.Spec.InfrastructureRef; existingCAPIMachineSet.GetName() != "" &&
+		existingRef.Name != "" && existingRef.Kind == newRef.Kind {
+		existingTemplate, err := getInfraMachineTemplateFromProvider(r.Platform)
+		if err != nil {
+			return err
+		}
+
+		if err := r.Get(ctx, client.ObjectKey{Namespace: r.CAPINamespace, Name: existingRef.Name}, existingTemplate); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to get CAPI InfraMachineTemplate %s: %w", existingRef.Name, err)
+		} else if err == nil && infraMachineTemplateSpecEqual(existingTemplate, newTemplate) {
+			newTemplate.SetName(existingRef.Name)
+			newRef.Name = existingRef.Name
+
+			return nil
+		}
+	}
+
+	spec, err := util.MachineTemplateSpec(newTemplate)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	name, err := util.RotatedMachineTemplateName(newCAPIMachineSet.GetName(), spec)
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+
+	annotations := newTemplate.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	annotations[util.MachineTemplateBaseNameAnnotation] = newCAPIMachineSet.GetName()
+	newTemplate.SetAnnotations(annotations)
+	newTemplate.SetName(name)
+	newRef.Name = name
+
+	return nil
+}
+
+// ensureCAPIInfraMachineTemplate creates the converted InfraMachineTemplate when it does not yet exist.
+// The template is named after its spec by setInfraMachineTemplateName, so an existing template with a different spec
+// was changed by someone else, and as InfraMachineTemplates are immutable, an error is returned.
+func (r *MachineSetSyncReconciler) ensureCAPIInfraMachineTemplate(ctx context.Context, newTemplate client.Object) error {
+	existingTemplate, ok := newTemplate.DeepCopyObject().(client.Object)
+	if !ok {
+		return fmt.Errorf("%w: %T", errPlatformNotSupported, newTemplate)
+	}
+
+	if err := r.Get(ctx, client.ObjectKeyFromObject(newTemplate), existingTemplate); apierrors.IsNotFound(err) {
+		if err := r.Create(ctx, newTemplate); err != nil {
+			return fmt.Errorf("failed to create CAPI InfraMachineTemplate: %w", err)
+		}
+
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get CAPI InfraMachineTemplate: %w", err)
+	}
+
+	if !infraMachineTemplateSpecEqual(existingTemplate, newTemplate) {
+		return errInfraMachineTemplateChanged
+	}
+
+	return nil
+}
+
+// ensureCAPIMachineSet creates the CAPI MachineSet, or updates the existing one to match the converted MachineSet
+// according to the sync conflict policy.
+func (r *MachineSetSyncReconciler) ensureCAPIMachineSet(ctx context.Context, conflictPolicy controllers.SyncConflictPolicy, drift []string,
+	existingCAPIMachineSet, newCAPIMachineSet *capiv1beta1.MachineSet) error {
+	if existingCAPIMachineSet.GetName() == "" {
+		if err := r.Create(ctx, newCAPIMachineSet); err != nil {
+			return fmt.Errorf("failed to create CAPI MachineSet: %w", err)
+		}
+
+		return nil
+	}
+
+	updatedCAPIMachineSet, err := resolveCAPIMachineSetConflicts(conflictPolicy, drift, existingCAPIMachineSet, newCAPIMachineSet)
+	if err != nil {
+		return err
+	}
+
+	if equality.Semantic.DeepEqual(existingCAPIMachineSet, updatedCAPIMachineSet) {
+		return nil
+	}
+
+	if err := r.Update(ctx, updatedCAPIMachineSet); err != nil {
+		return fmt.Errorf("failed to update CAPI MachineSet: %w", err)
+	}
+
+	return nil
+}
+
+// resolveCAPIMachineSetConflicts returns the existing CAPI MachineSet updated to match the converted MachineSet
+// according to the sync conflict policy. The drift lists the spec fields changed on the CAPI MachineSet since the last synchronization.
+func resolveCAPIMachineSetConflicts(conflictPolicy controllers.SyncConflictPolicy, drift []string,
+	existingCAPIMachineSet, newCAPIMachineSet *capiv1beta1.MachineSet) (*capiv1beta1.MachineSet, error) {
+	updatedCAPIMachineSet := existingCAPIMachineSet.DeepCopy()
+	updatedCAPIMachineSet.Spec = newCAPIMachineSet.Spec
+
+	switch conflictPolicy {
+	case controllers.SyncConflictPolicyFailAndReport:
+		if len(drift) > 0 {
+			return nil, fmt.Errorf("%w: %s", errCAPIMachineSetConflict, strings.Join(drift, ", "))
+		}
+
+		updatedCAPIMachineSet.SetLabels(newCAPIMachineSet.GetLabels())
+		updatedCAPIMachineSet.SetAnnotations(newCAPIMachineSet.GetAnnotations())
+	case controllers.SyncConflictPolicyMergeNonConflicting:
+		updatedCAPIMachineSet.SetLabels(mergeNonConflicting(existingCAPIMachineSet.GetLabels(), newCAPIMachineSet.GetLabels()))
+		updatedCAPIMachineSet.SetAnnotations(mergeNonConflicting(existingCAPIMachineSet.GetAnnotations(), newCAPIMachineSet.GetAnnotations()))
+	default:
+		updatedCAPIMachineSet.SetLabels(newCAPIMachineSet.GetLabels())
+		updatedCAPIMachineSet.SetAnnotations(newCAPIMachineSet.GetAnnotations())
+	}
+
+	return updatedCAPIMachineSet, nil
+}
+
+// mergeNonConflicting returns the authoritative map extended with the keys only present in the existing map.
+func mergeNonConflicting(existing, authoritative map[string]string) map[string]string {
+	if len(existing) == 0 {
+		return authoritative
+	}
+
+	merged := make(map[string]string, len(existing)+len(authoritative))
+
+	for key, value := range existing {
+		merged[key] = value
+	}
+
+	for key, value := range authoritative {
+		merged[key] = value
+	}
+
+	return merged
+}
+
+// applySynchronizedConditionWithPatch sets the Synchronized condition on the MAPI MachineSet and patches its status.
+// When the generation is provided, the synchronized generation is updated to match.
+func (r *MachineSetSyncReconciler) applySynchronizedConditionWithPatch(ctx context.Context, mapiMachineSet *machinev1beta1.MachineSet,
+	status corev1.ConditionStatus, reason, message string, generation *int64) error {
+	patchBase := client.MergeFrom(mapiMachineSet.DeepCopy())
+	synchronized := isSynchronizedTransition(mapiMachineSet.Status.Conditions, status)
+
+	severity := machinev1beta1.ConditionSeverityNone
+	if status != corev1.ConditionTrue {
+		severity = machinev1beta1.ConditionSeverityError
+	}
+
+	mapiMachineSet.Status.Conditions = util.SetMAPICondition(mapiMachineSet.Status.Conditions, machinev1beta1.Condition{
+		Type:     controllers.SynchronizedCondition,
+		Status:   status,
+		Severity: severity,
+		Reason:   reason,
+		Message:  message,
+	})
+
+	if generation != nil {
+		mapiMachineSet.Status.SynchronizedGeneration = *generation
+	}
+
+	if err := r.Status().Patch(ctx, mapiMachineSet, patchBase); err != nil {
+		return fmt.Errorf("failed to patch MAPI MachineSet status with synchronized condition: %w", err)
+	}
+
+	if synchronized {
+		r.Recorder.Event(mapiMachineSet, corev1.EventTypeNormal, reason, message)
+	}
+
+	return nil
+}
+
+// infraMachineTemplateSpecEqual compares the specs of two InfraMachineTemplates of the same type.
+func infraMachineTemplateSpecEqual(a, b client.Object) bool {
+	switch aTemplate := a.(type) {
+	case *awscapiv1beta2.AWSMachineTemplate:
+		bTemplate, ok := b.(*awscapiv1beta2.AWSMachineTemplate)
+		return ok && equality.Semantic.DeepEqual(aTemplate.Spec, bTemplate.Spec)
+	case *azurecapiv1beta1.AzureMachineTemplate:
+		bTemplate, ok := b.(*azurecapiv1beta1.AzureMachineTemplate)
+		return ok && equality.Semantic.DeepEqual(aTemplate.Spec, bTemplate.Spec)
+	case *gcpcapiv1beta1.GCPMachineTemplate:
+		bTemplate, ok := b.(*gcpcapiv1beta1.GCPMachineTemplate)
+		return ok && equality.Semantic.DeepEqual(aTemplate.Spec, bTemplate.Spec)
+	case *openstackcapiv1beta1.OpenStackMachineTemplate:
+		bTemplate, ok := b.(*openstackcapiv1beta1.OpenStackMachineTemplate)
+		return ok && equality.Semantic.DeepEqual(aTemplate.Spec, bTemplate.Spec)
+	case *powervscapiv1beta2.IBMPowerVSMachineTemplate:
+		bTemplate, ok := b.(*powervscapiv1beta2.IBMPowerVSMachineTemplate)
+		return ok && equality.Semantic.DeepEqual(aTemplate.Spec, bTemplate.Spec)
+	case *vspherecapiv1beta1.VSphereMachineTemplate:
+		bTemplate, ok := b.(*vspherecapiv1beta1.VSphereMachineTemplate)
+		return ok && equality.Semantic.DeepEqual(aTemplate.Spec, bTemplate.Spec)
+	default:
+		return false
+	}
 }
 
 // getInfraMachineTemplateFromProvider returns the correct InfraMachineTemplate implementation
@@ -183,7 +669,17 @@ func (r *MachineSetSyncReconciler) reconcileMAPIMachineSettoCAPIMachineSet(ctx c
 func getInfraMachineTemplateFromProvider(platform configv1.PlatformType) (client.Object, error) {
 	switch platform {
 	case configv1.AWSPlatformType:
-		return &awscapiv1beta1.AWSMachineTemplate{}, nil
+		return &awscapiv1beta2.AWSMachineTemplate{}, nil
+	case configv1.AzurePlatformType:
+		return &azurecapiv1beta1.AzureMachineTemplate{}, nil
+	case configv1.GCPPlatformType:
+		return &gcpcapiv1beta1.GCPMachineTemplate{}, nil
+	case configv1.OpenStackPlatformType:
+		return &openstackcapiv1beta1.OpenStackMachineTemplate{}, nil
+	case configv1.PowerVSPlatformType:
+		return &powervscapiv1beta2.IBMPowerVSMachineTemplate{}, nil
+	case configv1.VSpherePlatformType:
+		return &vspherecapiv1beta1.VSphereMachineTemplate{}, nil
 	default:
 		return nil, fmt.Errorf("%w: %s", errPlatformNotSupported, platform)
 	}
@@ -18,19 +18,33 @@ package machinesetsync
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	configv1 "github.com/openshift/api/config/v1"
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	capibuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/cluster-api/core/v1beta1"
+	configv1resourcebuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/config/v1"
 	corev1resourcebuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/core/v1"
 	machinev1resourcebuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/machine/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	awsv1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	azurecapiv1beta1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	capiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest/komega"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+	"github.com/openshift/cluster-capi-operator/pkg/operatorconfig"
 	"github.com/openshift/cluster-capi-operator/pkg/test"
 )
 
@@ -43,6 +57,9 @@ var _ = Describe("MachineSetSync Reconciler", func() {
 	var namespace *corev1.Namespace
 	var namespaceName string
 
+	var capiNamespace *corev1.Namespace
+	var capiNamespaceName string
+
 	var machineSetBuilder machinev1resourcebuilder.MachineSetBuilder
 	var machineset *machinev1beta1.MachineSet
 
@@ -72,6 +89,10 @@ var _ = Describe("MachineSetSync Reconciler", func() {
 		Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
 		namespaceName = namespace.GetName()
 
+		capiNamespace = corev1resourcebuilder.Namespace().WithGenerateName("machineset-sync-controller-capi-").Build()
+		Expect(k8sClient.Create(ctx, capiNamespace)).To(Succeed())
+		capiNamespaceName = capiNamespace.GetName()
+
 		By("Setting up the machineset builder")
 		machineSetBuilder = machinev1resourcebuilder.MachineSet().
 			WithNamespace(namespaceName).
@@ -87,13 +108,19 @@ var _ = Describe("MachineSetSync Reconciler", func() {
 
 		reconciler = &MachineSetSyncReconciler{
 			Client:   mgr.GetClient(),
+			Infra:    configv1resourcebuilder.Infrastructure().AsAWS("cluster", "us-east-1").Build(),
 			Platform: configv1.AWSPlatformType,
+
+			MAPINamespace: namespaceName,
+			CAPINamespace: capiNamespaceName,
 		}
 		Expect(reconciler.SetupWithManager(mgr)).To(Succeed(), "Reconciler should be able to setup with manager")
 	})
 
 	AfterEach(func() {
 		Expect(test.CleanupAndWait(ctx, k8sClient, machineset)).To(Succeed())
+		Expect(k8sClient.DeleteAllOf(ctx, &capiv1beta1.MachineSet{}, client.InNamespace(capiNamespaceName))).To(Succeed())
+		Expect(k8sClient.DeleteAllOf(ctx, &awsv1.AWSMachineTemplate{}, client.InNamespace(capiNamespaceName))).To(Succeed())
 	})
 
 	JustBeforeEach(func() {
@@ -117,4 +144,540 @@ var _ = Describe("MachineSetSync Reconciler", func() {
 		})
 		Expect(err).ToNot(HaveOccurred())
 	})
+
+	Context("when the MAPI MachineSet is authoritative", func() {
+		var providerSpec *machinev1beta1.AWSMachineProviderConfig
+
+		BeforeEach(func() {
+			providerSpec = machinev1resourcebuilder.AWSProviderSpec().
+				WithLoadBalancers(nil).
+				WithRegion("us-east-1").
+				Build()
+		})
+
+		JustBeforeEach(func() {
+			rawProviderSpec, err := json.Marshal(providerSpec)
+			Expect(err).ToNot(HaveOccurred())
+
+			machineset = machineSetBuilder.WithProviderSpec(machinev1beta1.ProviderSpec{
+				Value: &runtime.RawExtension{Raw: rawProviderSpec},
+			}).Build()
+			Expect(k8sClient.Create(ctx, machineset)).To(Succeed())
+
+			Eventually(komega.UpdateStatus(machineset, func() {
+				machineset.Status.AuthoritativeAPI = machinev1beta1.MachineAuthorityMachineAPI
+			})).Should(Succeed())
+		})
+
+		Context("when the MAPI MachineSet can be converted", func() {
+			It("should create the CAPI MachineSet and AWSMachineTemplate", func() {
+				capiMachineSet := &capiv1beta1.MachineSet{ObjectMeta: metav1.ObjectMeta{Namespace: capiNamespaceName, Name: machineset.GetName()}}
+				Eventually(komega.Get(capiMachineSet)).Should(Succeed())
+
+				Eventually(komega.Object(capiMachineSet)).Should(
+					HaveField("Spec.Template.Spec.InfrastructureRef.Name", HavePrefix(machineset.GetName()+"-")))
+
+				awsMachineTemplate := &awsv1.AWSMachineTemplate{ObjectMeta: metav1.ObjectMeta{
+					Namespace: capiNamespaceName,
+					Name:      capiMachineSet.Spec.Template.Spec.InfrastructureRef.Name,
+				}}
+				Eventually(komega.Get(awsMachineTemplate)).Should(Succeed())
+			})
+
+			It("should rotate the AWSMachineTemplate when the providerSpec changes", func() {
+				capiMachineSet := &capiv1beta1.MachineSet{ObjectMeta: metav1.ObjectMeta{Namespace: capiNamespaceName, Name: machineset.GetName()}}
+				Eventually(komega.Object(capiMachineSet)).Should(
+					HaveField("Spec.Template.Spec.InfrastructureRef.Name", HavePrefix(machineset.GetName()+"-")))
+
+				previousTemplateName := capiMachineSet.Spec.Template.Spec.InfrastructureRef.Name
+
+				providerSpec.InstanceType = "m6i.xlarge"
+				rawProviderSpec, err := json.Marshal(providerSpec)
+				Expect(err).ToNot(HaveOccurred())
+
+				Eventually(komega.Update(machineset, func() {
+					machineset.Spec.Template.Spec.ProviderSpec.Value = &runtime.RawExtension{Raw: rawProviderSpec}
+				})).Should(Succeed())
+
+				Eventually(komega.Object(capiMachineSet)).Should(
+					HaveField("Spec.Template.Spec.InfrastructureRef.Name", SatisfyAll(
+						HavePrefix(machineset.GetName()+"-"),
+						Not(Equal(previousTemplateName)),
+					)))
+
+				awsMachineTemplate := &awsv1.AWSMachineTemplate{ObjectMeta: metav1.ObjectMeta{
+					Namespace: capiNamespaceName,
+					Name:      capiMachineSet.Spec.Template.Spec.InfrastructureRef.Name,
+				}}
+				Eventually(komega.Object(awsMachineTemplate)).Should(
+					HaveField("Spec.Template.Spec.InstanceType", Equal("m6i.xlarge")))
+
+				Eventually(komega.Object(machineset)).Should(
+					HaveField("Status.Conditions", ContainElement(SatisfyAll(
+						HaveField("Type", Equal(controllers.SynchronizedCondition)),
+						HaveField("Status", Equal(corev1.ConditionTrue)),
+					))))
+			})
+
+			It("should sync the tags added to and removed from the MAPI MachineSet to the AWSMachineTemplate", func() {
+				capiMachineSet := &capiv1beta1.MachineSet{ObjectMeta: metav1.ObjectMeta{Namespace: capiNamespaceName, Name: machineset.GetName()}}
+				awsMachineTemplate := &awsv1.AWSMachineTemplate{}
+
+				updateTagsAndExpectTemplateTags := func(tags []machinev1beta1.TagSpecification, expectedTags awsv1.Tags) {
+					providerSpec.Tags = tags
+					rawProviderSpec, err := json.Marshal(providerSpec)
+					Expect(err).ToNot(HaveOccurred())
+
+					Eventually(komega.Update(machineset, func() {
+						machineset.Spec.Template.Spec.ProviderSpec.Value = &runtime.RawExtension{Raw: rawProviderSpec}
+					})).Should(Succeed())
+
+					Eventually(func(g Gomega) {
+						g.Expect(komega.Get(capiMachineSet)()).To(Succeed())
+						g.Expect(k8sClient.Get(ctx, client.ObjectKey{
+							Namespace: capiNamespaceName,
+							Name:      capiMachineSet.Spec.Template.Spec.InfrastructureRef.Name,
+						}, awsMachineTemplate)).To(Succeed())
+						g.Expect(awsMachineTemplate.Spec.Template.Spec.AdditionalTags).To(Equal(expectedTags))
+					}).Should(Succeed())
+				}
+
+				updateTagsAndExpectTemplateTags([]machinev1beta1.TagSpecification{{Name: "team", Value: "infra"}, {Name: "day2", Value: "added"}},
+					awsv1.Tags{"team": "infra", "day2": "added"})
+
+				updateTagsAndExpectTemplateTags([]machinev1beta1.TagSpecification{{Name: "team", Value: "infra"}},
+					awsv1.Tags{"team": "infra"})
+			})
+
+			It("should set the Synchronized condition to True", func() {
+				Eventually(komega.Object(machineset)).Should(SatisfyAll(
+					HaveField("Status.Conditions", ContainElement(SatisfyAll(
+						HaveField("Type", Equal(controllers.SynchronizedCondition)),
+						HaveField("Status", Equal(corev1.ConditionTrue)),
+						HaveField("Reason", Equal(reasonResourceSynchronized)),
+					))),
+					HaveField("Status.SynchronizedGeneration", Equal(machineset.GetGeneration())),
+				))
+			})
+		})
+
+		Context("when the MAPI MachineSet has a placement group partition without a placement group name", func() {
+			BeforeEach(func() {
+				providerSpec.PlacementGroupPartition = ptr.To(int32(2))
+			})
+
+			It("should set the Synchronized condition to False with the conversion error", func() {
+				Eventually(komega.Object(machineset)).Should(
+					HaveField("Status.Conditions", ContainElement(SatisfyAll(
+						HaveField("Type", Equal(controllers.SynchronizedCondition)),
+						HaveField("Status", Equal(corev1.ConditionFalse)),
+						HaveField("Reason", Equal(reasonFailedToConvertMAPIMachineSetToCAPI)),
+						HaveField("Message", ContainSubstring("placementGroupPartition is only valid when placementGroupName is set")),
+					))),
+				)
+			})
+
+			It("should not create the CAPI MachineSet", func() {
+				capiMachineSet := &capiv1beta1.MachineSet{ObjectMeta: metav1.ObjectMeta{Namespace: capiNamespaceName, Name: machineset.GetName()}}
+				Consistently(komega.Get(capiMachineSet)).ShouldNot(Succeed())
+			})
+		})
+
+		Context("when the IAM instance profile exists", func() {
+			BeforeEach(func() {
+				reconciler.AWSIAMClient = &fakeIAMInstanceProfileClient{exists: true}
+			})
+
+			It("should set the Synchronized condition to True", func() {
+				Eventually(komega.Object(machineset)).Should(
+					HaveField("Status.Conditions", ContainElement(SatisfyAll(
+						HaveField("Type", Equal(controllers.SynchronizedCondition)),
+						HaveField("Status", Equal(corev1.ConditionTrue)),
+						HaveField("Reason", Equal(reasonResourceSynchronized)),
+					))),
+				)
+			})
+		})
+
+		Context("when the IAM instance profile does not exist", func() {
+			BeforeEach(func() {
+				reconciler.AWSIAMClient = &fakeIAMInstanceProfileClient{exists: false}
+				providerSpec.IAMInstanceProfile = &machinev1beta1.AWSResourceReference{ID: ptr.To("missing-profile")}
+			})
+
+			It("should set the Synchronized condition to False with the missing profile", func() {
+				Eventually(komega.Object(machineset)).Should(
+					HaveField("Status.Conditions", ContainElement(SatisfyAll(
+						HaveField("Type", Equal(controllers.SynchronizedCondition)),
+						HaveField("Status", Equal(corev1.ConditionFalse)),
+						HaveField("Reason", Equal(reasonIAMInstanceProfileNotFound)),
+						HaveField("Message", ContainSubstring("\"missing-profile\"")),
+					))),
+				)
+			})
+
+			It("should not create the CAPI MachineSet", func() {
+				capiMachineSet := &capiv1beta1.MachineSet{ObjectMeta: metav1.ObjectMeta{Namespace: capiNamespaceName, Name: machineset.GetName()}}
+				Consistently(komega.Get(capiMachineSet)).ShouldNot(Succeed())
+			})
+		})
+
+		Context("when the IAM instance profile cannot be looked up", func() {
+			BeforeEach(func() {
+				reconciler.AWSIAMClient = &fakeIAMInstanceProfileClient{err: errors.New("AccessDenied")}
+				providerSpec.IAMInstanceProfile = &machinev1beta1.AWSResourceReference{ID: ptr.To("unknown-profile")}
+			})
+
+			It("should still create the CAPI MachineSet", func() {
+				capiMachineSet := &capiv1beta1.MachineSet{ObjectMeta: metav1.ObjectMeta{Namespace: capiNamespaceName, Name: machineset.GetName()}}
+				Eventually(komega.Get(capiMachineSet)).Should(Succeed())
+			})
+
+			It("should set the Synchronized condition to True with the failed preflight check", func() {
+				Eventually(komega.Object(machineset)).Should(
+					HaveField("Status.Conditions", ContainElement(SatisfyAll(
+						HaveField("Type", Equal(controllers.SynchronizedCondition)),
+						HaveField("Status", Equal(corev1.ConditionTrue)),
+						HaveField("Reason", Equal(reasonResourceSynchronized)),
+						HaveField("Message", SatisfyAll(
+							HavePrefix(messageSuccessfullySynchronizedMAPItoCAPI),
+							ContainSubstring("unable to run preflight check"),
+							ContainSubstring("AccessDenied"),
+						)),
+					))),
+				)
+			})
+		})
+
+		Context("when the MAPI MachineSet has an unknown sync conflict policy", func() {
+			BeforeEach(func() {
+				machineSetBuilder = machineSetBuilder.WithAnnotations(map[string]string{
+					controllers.SyncConflictPolicyAnnotation: "last-writer-wins",
+				})
+			})
+
+			It("should set the Synchronized condition to False with the invalid policy", func() {
+				Eventually(komega.Object(machineset)).Should(
+					HaveField("Status.Conditions", ContainElement(SatisfyAll(
+						HaveField("Type", Equal(controllers.SynchronizedCondition)),
+						HaveField("Status", Equal(corev1.ConditionFalse)),
+						HaveField("Reason", Equal(reasonInvalidSyncConflictPolicy)),
+						HaveField("Message", ContainSubstring("\"last-writer-wins\"")),
+					))),
+				)
+			})
+
+			It("should not create the CAPI MachineSet", func() {
+				capiMachineSet := &capiv1beta1.MachineSet{ObjectMeta: metav1.ObjectMeta{Namespace: capiNamespaceName, Name: machineset.GetName()}}
+				Consistently(komega.Get(capiMachineSet)).ShouldNot(Succeed())
+			})
+		})
+
+		Context("when the MAPI MachineSet is paused", func() {
+			BeforeEach(func() {
+				machineSetBuilder = machineSetBuilder.WithAnnotations(map[string]string{
+					capiv1beta1.PausedAnnotation: "",
+				})
+			})
+
+			It("should not create the CAPI MachineSet", func() {
+				capiMachineSet := &capiv1beta1.MachineSet{ObjectMeta: metav1.ObjectMeta{Namespace: capiNamespaceName, Name: machineset.GetName()}}
+				Consistently(komega.Get(capiMachineSet)).ShouldNot(Succeed())
+			})
+
+			It("should not set the Synchronized condition", func() {
+				Consistently(komega.Object(machineset)).Should(
+					HaveField("Status.Conditions", Not(ContainElement(HaveField("Type", Equal(controllers.SynchronizedCondition))))),
+				)
+			})
+		})
+
+		Context("when the CAPI MachineSet is paused after synchronization", func() {
+			JustBeforeEach(func() {
+				Eventually(komega.Object(machineset)).Should(
+					HaveField("Status.SynchronizedGeneration", Equal(machineset.GetGeneration())),
+				)
+
+				capiMachineSet := &capiv1beta1.MachineSet{ObjectMeta: metav1.ObjectMeta{Namespace: capiNamespaceName, Name: machineset.GetName()}}
+				Eventually(komega.Update(capiMachineSet, func() {
+					capiMachineSet.SetAnnotations(map[string]string{capiv1beta1.PausedAnnotation: ""})
+					capiMachineSet.Spec.MinReadySeconds = 30
+				})).Should(Succeed())
+			})
+
+			It("should not revert manual edits to the CAPI MachineSet", func() {
+				capiMachineSet := &capiv1beta1.MachineSet{ObjectMeta: metav1.ObjectMeta{Namespace: capiNamespaceName, Name: machineset.GetName()}}
+				Consistently(komega.Object(capiMachineSet)).Should(HaveField("Spec.MinReadySeconds", BeEquivalentTo(30)))
+			})
+		})
+
+		Context("when the MAPI MachineSet requests a migration dry run", func() {
+			BeforeEach(func() {
+				machineSetBuilder = machineSetBuilder.WithAnnotations(map[string]string{
+					MigrationDryRunAnnotation: "true",
+				})
+			})
+
+			It("should render the converted resources to the dry run ConfigMap", func() {
+				configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: machineset.GetName() + migrationDryRunConfigMapSuffix}}
+				Eventually(komega.Object(configMap)).Should(SatisfyAll(
+					HaveField("Data", HaveKeyWithValue(migrationDryRunMachineSetKey, ContainSubstring("kind: MachineSet"))),
+					HaveField("Data", HaveKeyWithValue(migrationDryRunInfraMachineTemplateKey, ContainSubstring("kind: AWSMachineTemplate"))),
+					HaveField("OwnerReferences", ContainElement(HaveField("UID", Equal(machineset.GetUID())))),
+				))
+			})
+
+			It("should set the Synchronized condition to False with the dry run", func() {
+				Eventually(komega.Object(machineset)).Should(
+					HaveField("Status.Conditions", ContainElement(SatisfyAll(
+						HaveField("Type", Equal(controllers.SynchronizedCondition)),
+						HaveField("Status", Equal(corev1.ConditionFalse)),
+						HaveField("Reason", Equal(reasonMigrationDryRun)),
+					))),
+				)
+			})
+
+			It("should not create the CAPI MachineSet", func() {
+				capiMachineSet := &capiv1beta1.MachineSet{ObjectMeta: metav1.ObjectMeta{Namespace: capiNamespaceName, Name: machineset.GetName()}}
+				Consistently(komega.Get(capiMachineSet)).ShouldNot(Succeed())
+			})
+
+			Context("when the dry run annotation is removed", func() {
+				JustBeforeEach(func() {
+					Eventually(komega.Object(machineset)).Should(
+						HaveField("Status.Conditions", ContainElement(HaveField("Reason", Equal(reasonMigrationDryRun)))),
+					)
+
+					Eventually(komega.Update(machineset, func() {
+						delete(machineset.Annotations, MigrationDryRunAnnotation)
+					})).Should(Succeed())
+				})
+
+				It("should create the CAPI MachineSet and remove the dry run ConfigMap", func() {
+					capiMachineSet := &capiv1beta1.MachineSet{ObjectMeta: metav1.ObjectMeta{Namespace: capiNamespaceName, Name: machineset.GetName()}}
+					Eventually(komega.Get(capiMachineSet)).Should(Succeed())
+
+					configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: namespaceName, Name: machineset.GetName() + migrationDryRunConfigMapSuffix}}
+					Eventually(komega.Get(configMap)).ShouldNot(Succeed())
+				})
+			})
+		})
+
+		Context("when the MAPI MachineSet uses the fail-and-report sync conflict policy", func() {
+			BeforeEach(func() {
+				machineSetBuilder = machineSetBuilder.WithAnnotations(map[string]string{
+					controllers.SyncConflictPolicyAnnotation: string(controllers.SyncConflictPolicyFailAndReport),
+				})
+			})
+
+			Context("when the CAPI MachineSet spec is changed after synchronization", func() {
+				JustBeforeEach(func() {
+					Eventually(komega.Object(machineset)).Should(
+						HaveField("Status.SynchronizedGeneration", Equal(machineset.GetGeneration())),
+					)
+
+					capiMachineSet := &capiv1beta1.MachineSet{ObjectMeta: metav1.ObjectMeta{Namespace: capiNamespaceName, Name: machineset.GetName()}}
+					Eventually(komega.Update(capiMachineSet, func() {
+						capiMachineSet.Spec.MinReadySeconds = 30
+					})).Should(Succeed())
+				})
+
+				It("should set the Synchronized condition to False with the conflict", func() {
+					Eventually(komega.Object(machineset)).Should(
+						HaveField("Status.Conditions", ContainElement(SatisfyAll(
+							HaveField("Type", Equal(controllers.SynchronizedCondition)),
+							HaveField("Status", Equal(corev1.ConditionFalse)),
+							HaveField("Reason", Equal(reasonDrifted)),
+							HaveField("Message", ContainSubstring("spec.minReadySeconds")),
+						))),
+					)
+				})
+
+				It("should not revert the CAPI MachineSet", func() {
+					capiMachineSet := &capiv1beta1.MachineSet{ObjectMeta: metav1.ObjectMeta{Namespace: capiNamespaceName, Name: machineset.GetName()}}
+					Consistently(komega.Object(capiMachineSet)).Should(HaveField("Spec.MinReadySeconds", BeEquivalentTo(30)))
+				})
+			})
+		})
+	})
+
+	Context("when rolling back a CAPI authoritative MachineSet to MAPI", func() {
+		JustBeforeEach(func() {
+			machineset = machineSetBuilder.WithAuthoritativeAPI(machinev1beta1.MachineAuthorityMachineAPI).Build()
+			Expect(k8sClient.Create(ctx, machineset)).To(Succeed())
+
+			Eventually(komega.UpdateStatus(machineset, func() {
+				machineset.Status.AuthoritativeAPI = machinev1beta1.MachineAuthorityClusterAPI
+			})).Should(Succeed())
+		})
+
+		Context("when the CAPI MachineSet does not exist", func() {
+			It("should make MAPI authoritative", func() {
+				Eventually(komega.Object(machineset)).Should(SatisfyAll(
+					HaveField("Status.AuthoritativeAPI", Equal(machinev1beta1.MachineAuthorityMachineAPI)),
+					HaveField("Status.Conditions", ContainElement(SatisfyAll(
+						HaveField("Type", Equal(controllers.SynchronizedCondition)),
+						HaveField("Status", Equal(corev1.ConditionTrue)),
+					))),
+				))
+			})
+		})
+	})
 })
+
+var _ = DescribeTable("midProvisioningMachineNames",
+	func(machines []capiv1beta1.Machine, expectedNames []string) {
+		Expect(midProvisioningMachineNames(machines)).To(Equal(expectedNames))
+	},
+	Entry("with no machines", []capiv1beta1.Machine{}, []string{}),
+	Entry("with running and failed machines", []capiv1beta1.Machine{
+		*capibuilder.Machine().WithName("running").WithPhase(capiv1beta1.MachinePhaseRunning).Build(),
+		*capibuilder.Machine().WithName("failed").WithPhase(capiv1beta1.MachinePhaseFailed).Build(),
+	}, []string{}),
+	Entry("with machines mid-provisioning", []capiv1beta1.Machine{
+		*capibuilder.Machine().WithName("running").WithPhase(capiv1beta1.MachinePhaseRunning).Build(),
+		*capibuilder.Machine().WithName("provisioning").WithPhase(capiv1beta1.MachinePhaseProvisioning).Build(),
+		*capibuilder.Machine().WithName("provisioned").WithPhase(capiv1beta1.MachinePhaseProvisioned).Build(),
+		*capibuilder.Machine().WithName("deleting").WithPhase(capiv1beta1.MachinePhaseDeleting).Build(),
+		*capibuilder.Machine().WithName("new").Build(),
+	}, []string{"deleting", "new", "provisioned", "provisioning"}),
+)
+
+var _ = DescribeTable("resolveCAPIMachineSetConflicts",
+	func(conflictPolicy controllers.SyncConflictPolicy, drift []string, existing, converted, expected *capiv1beta1.MachineSet, expectedErr error) {
+		resolved, err := resolveCAPIMachineSetConflicts(conflictPolicy, drift, existing, converted)
+		if expectedErr != nil {
+			Expect(err).To(MatchError(expectedErr))
+			return
+		}
+
+		Expect(err).ToNot(HaveOccurred())
+		Expect(resolved).To(Equal(expected))
+	},
+	Entry("authoritative-wins overwrites labels and spec", controllers.SyncConflictPolicyAuthoritativeWins, []string{"spec.replicas"},
+		capiMachineSetWith(map[string]string{"capi": "added", "shared": "capi"}, 1),
+		capiMachineSetWith(map[string]string{"shared": "mapi"}, 2),
+		capiMachineSetWith(map[string]string{"shared": "mapi"}, 2), nil),
+	Entry("fail-and-report reports a drifted CAPI spec", controllers.SyncConflictPolicyFailAndReport, []string{"spec.replicas"},
+		capiMachineSetWith(nil, 1),
+		capiMachineSetWith(nil, 2),
+		nil, errCAPIMachineSetConflict),
+	Entry("fail-and-report applies a changed MAPI spec", controllers.SyncConflictPolicyFailAndReport, nil,
+		capiMachineSetWith(nil, 1),
+		capiMachineSetWith(nil, 2),
+		capiMachineSetWith(nil, 2), nil),
+	Entry("fail-and-report applies labels when the specs match", controllers.SyncConflictPolicyFailAndReport, nil,
+		capiMachineSetWith(map[string]string{"capi": "added"}, 1),
+		capiMachineSetWith(map[string]string{"shared": "mapi"}, 1),
+		capiMachineSetWith(map[string]string{"shared": "mapi"}, 1), nil),
+	Entry("merge-nonconflicting keeps CAPI only labels", controllers.SyncConflictPolicyMergeNonConflicting, []string{"spec.replicas"},
+		capiMachineSetWith(map[string]string{"capi": "added", "shared": "capi"}, 1),
+		capiMachineSetWith(map[string]string{"shared": "mapi"}, 2),
+		capiMachineSetWith(map[string]string{"capi": "added", "shared": "mapi"}, 2), nil),
+)
+
+var _ = DescribeTable("capiMachineSetSpecDrift",
+	func(mutate func(*capiv1beta1.MachineSet), expectedDrift []string) {
+		existing := capiMachineSetWith(nil, 1)
+		mutate(existing)
+
+		Expect(capiMachineSetSpecDrift(existing, capiMachineSetWith(nil, 1))).To(Equal(expectedDrift))
+	},
+	Entry("without changes", func(*capiv1beta1.MachineSet) {}, []string{}),
+	Entry("with changed labels only", func(ms *capiv1beta1.MachineSet) {
+		ms.SetLabels(map[string]string{"manual": "edit"})
+	}, []string{}),
+	Entry("with changed replicas and min ready seconds", func(ms *capiv1beta1.MachineSet) {
+		ms.Spec.Replicas = ptr.To(int32(3))
+		ms.Spec.MinReadySeconds = 30
+	}, []string{"spec.replicas", "spec.minReadySeconds"}),
+	Entry("with a changed failure domain", func(ms *capiv1beta1.MachineSet) {
+		ms.Spec.Template.Spec.FailureDomain = ptr.To("us-east-1b")
+	}, []string{"spec.template.spec.failureDomain"}),
+)
+
+var _ = DescribeTable("authoritativeAPIChangedMessage",
+	func(oldAuthority, newAuthority machinev1beta1.MachineAuthority, expectedMessage string, expectedChanged bool) {
+		oldMachineSet := &machinev1beta1.MachineSet{Status: machinev1beta1.MachineSetStatus{AuthoritativeAPI: oldAuthority}}
+		newMachineSet := &machinev1beta1.MachineSet{Status: machinev1beta1.MachineSetStatus{AuthoritativeAPI: newAuthority}}
+
+		message, changed := authoritativeAPIChangedMessage(oldMachineSet, newMachineSet)
+		Expect(changed).To(Equal(expectedChanged))
+		Expect(message).To(Equal(expectedMessage))
+	},
+	Entry("when the authoritative API is first set", machinev1beta1.MachineAuthority(""), machinev1beta1.MachineAuthorityMachineAPI, "", false),
+	Entry("when the authoritative API is unchanged", machinev1beta1.MachineAuthorityMachineAPI, machinev1beta1.MachineAuthorityMachineAPI, "", false),
+	Entry("when migration starts", machinev1beta1.MachineAuthorityMachineAPI, machinev1beta1.MachineAuthorityMigrating,
+		"Authoritative API changed from MachineAPI to Migrating", true),
+	Entry("when rolled back", machinev1beta1.MachineAuthorityClusterAPI, machinev1beta1.MachineAuthorityMachineAPI,
+		"Authoritative API changed from ClusterAPI to MachineAPI", true),
+)
+
+var _ = DescribeTable("isSynchronizedTransition",
+	func(oldConditions []machinev1beta1.Condition, status corev1.ConditionStatus, expected bool) {
+		Expect(isSynchronizedTransition(oldConditions, status)).To(Equal(expected))
+	},
+	Entry("when first synchronized", nil, corev1.ConditionTrue, true),
+	Entry("when synchronized after a failure", []machinev1beta1.Condition{
+		{Type: controllers.SynchronizedCondition, Status: corev1.ConditionFalse},
+	}, corev1.ConditionTrue, true),
+	Entry("when still synchronized", []machinev1beta1.Condition{
+		{Type: controllers.SynchronizedCondition, Status: corev1.ConditionTrue},
+	}, corev1.ConditionTrue, false),
+	Entry("when synchronization fails", []machinev1beta1.Condition{
+		{Type: controllers.SynchronizedCondition, Status: corev1.ConditionTrue},
+	}, corev1.ConditionFalse, false),
+)
+
+var _ = DescribeTable("convertMAPIToCAPIMachineSet Azure managed boot diagnostics default",
+	func(reconcilerDefault bool, configFeature *bool, bootDiagnostics *machinev1beta1.AzureBootDiagnostics,
+		expected azurecapiv1beta1.BootDiagnosticsStorageAccountType) {
+		r := &MachineSetSyncReconciler{
+			Platform:                    configv1.AzurePlatformType,
+			Infra:                       &configv1.Infrastructure{Status: configv1.InfrastructureStatus{InfrastructureName: "sample-cluster-name"}},
+			AzureManagedBootDiagnostics: reconcilerDefault,
+		}
+
+		providerSpec := machinev1resourcebuilder.AzureProviderSpec().WithInternalLoadBalancer("").Build()
+		providerSpec.Diagnostics.Boot = bootDiagnostics
+		rawProviderSpec, err := json.Marshal(providerSpec)
+		Expect(err).ToNot(HaveOccurred())
+
+		mapiMachineSet := machinev1resourcebuilder.MachineSet().WithProviderSpec(machinev1beta1.ProviderSpec{
+			Value: &runtime.RawExtension{Raw: rawProviderSpec},
+		}).Build()
+
+		_, template, _, err := r.convertMAPIToCAPIMachineSet(mapiMachineSet, operatorconfig.Features{AzureManagedBootDiagnostics: configFeature})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(template).To(HaveField("Spec.Template.Spec.Diagnostics.Boot.StorageAccountType", Equal(expected)))
+	},
+	Entry("when the default is off", false, nil, nil, azurecapiv1beta1.DisabledDiagnosticsStorage),
+	Entry("when the default is on", true, nil, nil, azurecapiv1beta1.ManagedDiagnosticsStorage),
+	Entry("when the operator config enables the default over the flag", false, ptr.To(true), nil, azurecapiv1beta1.ManagedDiagnosticsStorage),
+	Entry("when the operator config disables the default over the flag", true, ptr.To(false), nil, azurecapiv1beta1.DisabledDiagnosticsStorage),
+	Entry("when the default is on and the MachineSet configures boot diagnostics", true, nil,
+		&machinev1beta1.AzureBootDiagnostics{
+			StorageAccountType: machinev1beta1.CustomerManagedAzureDiagnosticsStorage,
+			CustomerManaged:    &machinev1beta1.AzureCustomerManagedBootDiagnostics{StorageAccountURI: "https://sample.blob.core.windows.net/"},
+		},
+		azurecapiv1beta1.UserManagedDiagnosticsStorage),
+)
+
+// capiMachineSetWith returns a CAPI MachineSet with the given labels and replicas.
+func capiMachineSetWith(labels map[string]string, replicas int32) *capiv1beta1.MachineSet {
+	return &capiv1beta1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "foo", Labels: labels},
+		Spec:       capiv1beta1.MachineSetSpec{Replicas: ptr.To(replicas)},
+	}
+}
+
+// fakeIAMInstanceProfileClient is an IAMInstanceProfileClient that reports every instance profile as existing or not,
+// or fails every lookup with the error.
+type fakeIAMInstanceProfileClient struct {
+	exists bool
+	err    error
+}
+
+func (f *fakeIAMInstanceProfileClient) InstanceProfileExists(_ context.Context, _ client.ObjectKey, _, _ string) (bool, error) {
+	return f.exists, f.err
+}
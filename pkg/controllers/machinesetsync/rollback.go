@@ -0,0 +1,245 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package machinesetsync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/capi2mapi"
+	"github.com/openshift/cluster-capi-operator/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	awscapiv1beta2 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	azurecapiv1beta1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	gcpcapiv1beta1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	powervscapiv1beta2 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
+	openstackcapiv1beta1 "sigs.k8s.io/cluster-api-provider-openstack/api/v1beta1"
+	vspherecapiv1beta1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	capiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/labels/format"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	reasonRollbackBlocked                      string = "RollbackBlocked"
+	reasonFailedToConvertCAPIMachineSetToMAPI  string = "FailedToConvertCAPIMachineSetToMAPI"
+	reasonFailedToRollBackMAPIMachineSet       string = "FailedToRollBackMAPIMachineSet"
+	reasonRolledBackToMachineAPI               string = "RolledBackToMachineAPI"
+	messageSuccessfullyRolledBackCAPItoMAPI    string = "Successfully rolled back the MachineSet from CAPI to MAPI"
+	messageRollbackBlockedByMidProvisioningFmt string = "Rollback to MAPI is blocked until the following CAPI Machines finish provisioning or deleting: %s"
+
+	// rollbackRequeueAfter is how long to wait before checking again whether a blocked rollback can proceed.
+	// CAPI Machines are not watched, so their progress does not trigger a new reconcile.
+	rollbackRequeueAfter = 30 * time.Second
+)
+
+var (
+	// errInfraClusterNotSupported is returned when the platform has no InfraCluster type to convert with.
+	errInfraClusterNotSupported = errors.New("error determining InfraCluster type, platform not supported")
+)
+
+// isRollbackToMAPIRequested returns whether the MAPI MachineSet asks to move authority back from CAPI to MAPI.
+func isRollbackToMAPIRequested(mapiMachineSet *machinev1beta1.MachineSet) bool {
+	return mapiMachineSet.Status.AuthoritativeAPI == machinev1beta1.MachineAuthorityClusterAPI &&
+		mapiMachineSet.Spec.AuthoritativeAPI == machinev1beta1.MachineAuthorityMachineAPI
+}
+
+// reconcileRollbackToMAPI reverses the mirroring of a MachineSet whose authority is moved back from CAPI to MAPI.
+// The MAPI MachineSet is re-seeded from the CAPI copy before MAPI becomes authoritative again, so that changes made
+// while CAPI was authoritative are not lost. The rollback waits while any CAPI Machine of the MachineSet is mid-provisioning,
+// as MAPI would not be able to adopt it.
+func (r *MachineSetSyncReconciler) reconcileRollbackToMAPI(ctx context.Context, capiMachineSet *capiv1beta1.MachineSet, mapiMachineSet *machinev1beta1.MachineSet) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	// Without a CAPI copy there is nothing to re-seed the MAPI MachineSet from.
+	if capiMachineSet.GetName() == "" {
+		logger.Info("CAPI MachineSet not found, rolling back to MAPI without re-seeding")
+		return ctrl.Result{}, r.completeRollbackToMAPI(ctx, mapiMachineSet)
+	}
+
+	capiMachines := &capiv1beta1.MachineList{}
+	if err := r.List(ctx, capiMachines, client.InNamespace(r.CAPINamespace),
+		client.MatchingLabels{capiv1beta1.MachineSetNameLabel: format.MustFormatValue(capiMachineSet.GetName())}); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list CAPI Machines: %w", err)
+	}
+
+	if names := midProvisioningMachineNames(capiMachines.Items); len(names) > 0 {
+		message := fmt.Sprintf(messageRollbackBlockedByMidProvisioningFmt, strings.Join(names, ", "))
+		logger.Info("Rollback to MAPI blocked", "machines", names)
+
+		return ctrl.Result{RequeueAfter: rollbackRequeueAfter}, r.applySynchronizedConditionWithPatch(ctx, mapiMachineSet, corev1.ConditionFalse,
+			reasonRollbackBlocked, message, nil)
+	}
+
+	newMAPIMachineSet, warns, err := r.convertCAPIToMAPIMachineSet(ctx, capiMachineSet)
+	if err != nil {
+		conversionErr := fmt.Errorf("failed to convert CAPI MachineSet to MAPI MachineSet: %w", err)
+		logger.Error(conversionErr, "Unable to convert CAPI MachineSet to MAPI")
+		r.Recorder.Event(mapiMachineSet, corev1.EventTypeWarning, reasonFailedToConvertCAPIMachineSetToMAPI, conversionErr.Error())
+
+		return ctrl.Result{}, r.applySynchronizedConditionWithPatch(ctx, mapiMachineSet, corev1.ConditionFalse,
+			reasonFailedToConvertCAPIMachineSetToMAPI, conversionErr.Error(), nil)
+	}
+
+	for _, warning := range warns {
+		logger.Info("Warning during conversion", "warning", warning)
+		r.Recorder.Event(mapiMachineSet, corev1.EventTypeWarning, reasonConversionWarning, warning)
+	}
+
+	if err := r.reseedMAPIMachineSet(ctx, mapiMachineSet, newMAPIMachineSet); err != nil {
+		reseedErr := fmt.Errorf("failed to re-seed MAPI MachineSet from CAPI: %w", err)
+
+		if condErr := r.applySynchronizedConditionWithPatch(ctx, mapiMachineSet, corev1.ConditionFalse,
+			reasonFailedToRollBackMAPIMachineSet, reseedErr.Error(), nil); condErr != nil {
+			return ctrl.Result{}, utilerrors.NewAggregate([]error{reseedErr, condErr})
+		}
+
+		return ctrl.Result{}, reseedErr
+	}
+
+	r.Recorder.Event(mapiMachineSet, corev1.EventTypeNormal, reasonRolledBackToMachineAPI, messageSuccessfullyRolledBackCAPItoMAPI)
+
+	return ctrl.Result{}, r.completeRollbackToMAPI(ctx, mapiMachineSet)
+}
+
+// reseedMAPIMachineSet updates the MAPI MachineSet spec to match the MachineSet converted from CAPI.
+// The requested authoritative API is kept, as it is what triggered the rollback.
+func (r *MachineSetSyncReconciler) reseedMAPIMachineSet(ctx context.Context, mapiMachineSet, newMAPIMachineSet *machinev1beta1.MachineSet) error {
+	updatedMAPIMachineSet := mapiMachineSet.DeepCopy()
+	updatedMAPIMachineSet.Spec = newMAPIMachineSet.Spec
+	updatedMAPIMachineSet.Spec.AuthoritativeAPI = mapiMachineSet.Spec.AuthoritativeAPI
+
+	if equality.Semantic.DeepEqual(mapiMachineSet.Spec, updatedMAPIMachineSet.Spec) {
+		return nil
+	}
+
+	if err := r.Update(ctx, updatedMAPIMachineSet); err != nil {
+		return fmt.Errorf("failed to update MAPI MachineSet: %w", err)
+	}
+
+	// Carry the updated generation and resource version over, so that the status patch applies to the re-seeded MachineSet.
+	updatedMAPIMachineSet.Status = mapiMachineSet.Status
+	updatedMAPIMachineSet.DeepCopyInto(mapiMachineSet)
+
+	return nil
+}
+
+// completeRollbackToMAPI makes MAPI authoritative for the MachineSet again and marks it as synchronized.
+func (r *MachineSetSyncReconciler) completeRollbackToMAPI(ctx context.Context, mapiMachineSet *machinev1beta1.MachineSet) error {
+	patchBase := client.MergeFrom(mapiMachineSet.DeepCopy())
+
+	mapiMachineSet.Status.AuthoritativeAPI = machinev1beta1.MachineAuthorityMachineAPI
+	mapiMachineSet.Status.SynchronizedGeneration = mapiMachineSet.Generation
+	mapiMachineSet.Status.Conditions = util.SetMAPICondition(mapiMachineSet.Status.Conditions, machinev1beta1.Condition{
+		Type:     controllers.SynchronizedCondition,
+		Status:   corev1.ConditionTrue,
+		Severity: machinev1beta1.ConditionSeverityNone,
+		Reason:   reasonRolledBackToMachineAPI,
+		Message:  messageSuccessfullyRolledBackCAPItoMAPI,
+	})
+
+	if err := r.Status().Patch(ctx, mapiMachineSet, patchBase); err != nil {
+		return fmt.Errorf("failed to patch MAPI MachineSet status with rolled back authoritative API: %w", err)
+	}
+
+	return nil
+}
+
+// convertCAPIToMAPIMachineSet converts the CAPI MachineSet, along with its InfraMachineTemplate and the InfraCluster,
+// to a MAPI MachineSet for the platform the reconciler is running on.
+func (r *MachineSetSyncReconciler) convertCAPIToMAPIMachineSet(ctx context.Context, capiMachineSet *capiv1beta1.MachineSet) (*machinev1beta1.MachineSet, []string, error) {
+	infraMachineTemplate, err := getInfraMachineTemplateFromProvider(r.Platform)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	templateKey := client.ObjectKey{Namespace: r.CAPINamespace, Name: capiMachineSet.Spec.Template.Spec.InfrastructureRef.Name}
+	if err := r.Get(ctx, templateKey, infraMachineTemplate); err != nil {
+		return nil, nil, fmt.Errorf("failed to get CAPI InfraMachineTemplate: %w", err)
+	}
+
+	infraCluster, err := getInfraClusterFromProvider(r.Platform)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	clusterKey := client.ObjectKey{Namespace: r.CAPINamespace, Name: capiMachineSet.Spec.ClusterName}
+	if err := r.Get(ctx, clusterKey, infraCluster); err != nil {
+		return nil, nil, fmt.Errorf("failed to get CAPI InfraCluster: %w", err)
+	}
+
+	conversion, err := capi2mapi.FromMachineSetAndInfraMachineTemplateAndInfraCluster(capiMachineSet, infraMachineTemplate, infraCluster)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	newMAPIMachineSet, warns, err := conversion.ToMachineSet()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to convert: %w", err)
+	}
+
+	return newMAPIMachineSet, warns, nil
+}
+
+// getInfraClusterFromProvider returns the correct InfraCluster implementation
+// for a given provider.
+func getInfraClusterFromProvider(platform configv1.PlatformType) (client.Object, error) {
+	switch platform {
+	case configv1.AWSPlatformType:
+		return &awscapiv1beta2.AWSCluster{}, nil
+	case configv1.AzurePlatformType:
+		return &azurecapiv1beta1.AzureCluster{}, nil
+	case configv1.GCPPlatformType:
+		return &gcpcapiv1beta1.GCPCluster{}, nil
+	case configv1.OpenStackPlatformType:
+		return &openstackcapiv1beta1.OpenStackCluster{}, nil
+	case configv1.PowerVSPlatformType:
+		return &powervscapiv1beta2.IBMPowerVSCluster{}, nil
+	case configv1.VSpherePlatformType:
+		return &vspherecapiv1beta1.VSphereCluster{}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", errInfraClusterNotSupported, platform)
+	}
+}
+
+// midProvisioningMachineNames returns the sorted names of the CAPI Machines that are still being provisioned or deleted.
+// Machines that have not reported a phase yet are treated as being provisioned.
+func midProvisioningMachineNames(machines []capiv1beta1.Machine) []string {
+	names := []string{}
+
+	for _, machine := range machines {
+		switch capiv1beta1.MachinePhase(machine.Status.Phase) {
+		case "", capiv1beta1.MachinePhasePending, capiv1beta1.MachinePhaseProvisioning,
+			capiv1beta1.MachinePhaseProvisioned, capiv1beta1.MachinePhaseDeleting:
+			names = append(names, machine.GetName())
+		}
+	}
+
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package machinesetsync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	capiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/openshift/cluster-capi-operator/pkg/util"
+)
+
+// deleteUnreferencedInfraMachineTemplates deletes the InfraMachineTemplates previously rotated for the CAPI MachineSet
+// by setInfraMachineTemplateName, once neither a CAPI MachineSet nor a Machine references them anymore.
+// A Machine references the template its InfraMachine was cloned from, so a rotated template is kept until the Machines
+// created from it are gone; the CAPI MachineSet status changes as they go, which triggers a new reconcile.
+// The templates not rotated for the MachineSet are left to whoever created them.
+// Only the metadata is listed, so the InfraMachines of large clusters do not take up the memory of the controller.
+func (r *MachineSetSyncReconciler) deleteUnreferencedInfraMachineTemplates(ctx context.Context, capiMachineSet *capiv1beta1.MachineSet) error {
+	logger := log.FromContext(ctx)
+
+	template, err := getInfraMachineTemplateFromProvider(r.Platform)
+	if err != nil {
+		return err
+	}
+
+	templateGVK, err := apiutil.GVKForObject(template, r.Scheme)
+	if err != nil {
+		return fmt.Errorf("failed to get the kind of InfraMachineTemplate: %w", err)
+	}
+
+	templates := &metav1.PartialObjectMetadataList{}
+	templates.SetGroupVersionKind(templateGVK.GroupVersion().WithKind(templateGVK.Kind + "List"))
+
+	if err := r.List(ctx, templates, client.InNamespace(r.CAPINamespace)); err != nil {
+		return fmt.Errorf("failed to list CAPI InfraMachineTemplates: %w", err)
+	}
+
+	rotated := []metav1.PartialObjectMetadata{}
+
+	for _, t := range templates.Items {
+		if t.GetAnnotations()[util.MachineTemplateBaseNameAnnotation] == capiMachineSet.Name &&
+			t.Name != capiMachineSet.Spec.Template.Spec.InfrastructureRef.Name {
+			rotated = append(rotated, t)
+		}
+	}
+
+	if len(rotated) == 0 {
+		return nil
+	}
+
+	referenced, err := r.referencedInfraMachineTemplates(ctx, templateGVK)
+	if err != nil {
+		return err
+	}
+
+	for i := range rotated {
+		if referenced.Has(rotated[i].Name) {
+			continue
+		}
+
+		rotated[i].SetGroupVersionKind(templateGVK)
+
+		if err := r.Delete(ctx, &rotated[i]); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete CAPI InfraMachineTemplate %s: %w", rotated[i].Name, err)
+		}
+
+		logger.Info("Deleted unreferenced CAPI InfraMachineTemplate", "name", rotated[i].Name)
+	}
+
+	return nil
+}
+
+// referencedInfraMachineTemplates returns the names of the InfraMachineTemplates of the kind referenced by the CAPI MachineSets,
+// or which the InfraMachines of the Machines were cloned from.
+func (r *MachineSetSyncReconciler) referencedInfraMachineTemplates(ctx context.Context, templateGVK schema.GroupVersionKind) (sets.Set[string], error) {
+	referenced := sets.New[string]()
+
+	machineSets := &capiv1beta1.MachineSetList{}
+	if err := r.List(ctx, machineSets, client.InNamespace(r.CAPINamespace)); err != nil {
+		return nil, fmt.Errorf("failed to list CAPI MachineSets: %w", err)
+	}
+
+	for _, machineSet := range machineSets.Items {
+		if ref := machineSet.Spec.Template.Spec.InfrastructureRef; ref.GroupVersionKind().GroupKind() == templateGVK.GroupKind() {
+			referenced.Insert(ref.Name)
+		}
+	}
+
+	// The InfraMachines are the kind of the InfraMachineTemplates without the Template suffix, e.g. AWSMachine for AWSMachineTemplate.
+	infraMachines := &metav1.PartialObjectMetadataList{}
+	infraMachines.SetGroupVersionKind(templateGVK.GroupVersion().WithKind(strings.TrimSuffix(templateGVK.Kind, "Template") + "List"))
+
+	if err := r.List(ctx, infraMachines, client.InNamespace(r.CAPINamespace)); err != nil {
+		return nil, fmt.Errorf("failed to list CAPI InfraMachines: %w", err)
+	}
+
+	for _, infraMachine := range infraMachines.Items {
+		if infraMachine.GetAnnotations()[capiv1beta1.TemplateClonedFromGroupKindAnnotation] == templateGVK.GroupKind().String() {
+			referenced.Insert(infraMachine.GetAnnotations()[capiv1beta1.TemplateClonedFromNameAnnotation])
+		}
+	}
+
+	return referenced, nil
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package machinesetsync
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	configv1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	awscapiv1beta2 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	capiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/cluster-capi-operator/pkg/util"
+)
+
+var _ = DescribeTable("deleteUnreferencedInfraMachineTemplates",
+	func(objs []client.Object, expectTemplates []string) {
+		scheme := runtime.NewScheme()
+		utilruntime.Must(awscapiv1beta2.AddToScheme(scheme))
+		utilruntime.Must(capiv1beta1.AddToScheme(scheme))
+
+		reconciler := &MachineSetSyncReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+			Scheme:        scheme,
+			Platform:      configv1.AWSPlatformType,
+			CAPINamespace: capiNamespace,
+		}
+
+		Expect(reconciler.deleteUnreferencedInfraMachineTemplates(ctx, newCleanupCAPIMachineSet("worker", "worker-22222222"))).To(Succeed())
+
+		templates := &awscapiv1beta2.AWSMachineTemplateList{}
+		Expect(reconciler.List(ctx, templates)).To(Succeed())
+
+		names := []string{}
+		for _, template := range templates.Items {
+			names = append(names, template.Name)
+		}
+
+		Expect(names).To(ConsistOf(expectTemplates))
+	},
+	Entry("deletes the unreferenced templates rotated for the MachineSet",
+		[]client.Object{newCleanupTemplate("worker-11111111", "worker"), newCleanupTemplate("worker-22222222", "worker")},
+		[]string{"worker-22222222"}),
+	Entry("keeps the rotated templates the InfraMachines of Machines were cloned from",
+		[]client.Object{newCleanupTemplate("worker-11111111", "worker"), newCleanupTemplate("worker-22222222", "worker"),
+			newCleanupAWSMachine("worker-abcde", "worker-11111111")},
+		[]string{"worker-11111111", "worker-22222222"}),
+	Entry("keeps the rotated templates referenced by other MachineSets",
+		[]client.Object{newCleanupTemplate("worker-11111111", "worker"), newCleanupTemplate("worker-22222222", "worker"),
+			newCleanupCAPIMachineSet("worker-copy", "worker-11111111")},
+		[]string{"worker-11111111", "worker-22222222"}),
+	Entry("keeps the templates not rotated for the MachineSet",
+		[]client.Object{newCleanupTemplate("worker", ""), newCleanupTemplate("infra-11111111", "infra"),
+			newCleanupTemplate("worker-22222222", "worker")},
+		[]string{"worker", "infra-11111111", "worker-22222222"}),
+)
+
+func newCleanupTemplate(name, baseName string) *awscapiv1beta2.AWSMachineTemplate {
+	template := &awscapiv1beta2.AWSMachineTemplate{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: capiNamespace}}
+	if baseName != "" {
+		template.Annotations = map[string]string{util.MachineTemplateBaseNameAnnotation: baseName}
+	}
+
+	return template
+}
+
+func newCleanupCAPIMachineSet(name, templateName string) *capiv1beta1.MachineSet {
+	return &capiv1beta1.MachineSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: capiNamespace},
+		Spec: capiv1beta1.MachineSetSpec{Template: capiv1beta1.MachineTemplateSpec{Spec: capiv1beta1.MachineSpec{
+			InfrastructureRef: corev1.ObjectReference{
+				APIVersion: awscapiv1beta2.GroupVersion.String(), Kind: "AWSMachineTemplate", Name: templateName,
+			},
+		}}},
+	}
+}
+
+func newCleanupAWSMachine(name, templateName string) *awscapiv1beta2.AWSMachine {
+	return &awscapiv1beta2.AWSMachine{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: capiNamespace, Annotations: map[string]string{
+		capiv1beta1.TemplateClonedFromNameAnnotation:      templateName,
+		capiv1beta1.TemplateClonedFromGroupKindAnnotation: "AWSMachineTemplate.infrastructure.cluster.x-k8s.io",
+	}}}
+}
@@ -0,0 +1,255 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machinesync
+
+import (
+	"context"
+	"fmt"
+
+	configv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/utils/ptr"
+	capiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/mapi2capi"
+	"github.com/openshift/cluster-capi-operator/pkg/operatorconfig"
+	"github.com/openshift/cluster-capi-operator/pkg/util"
+)
+
+const (
+	// machineRoleLabel is the label holding the role of a MAPI Machine.
+	machineRoleLabel string = "machine.openshift.io/cluster-api-machine-role"
+
+	// masterMachineRole is the role of control plane Machines.
+	masterMachineRole string = "master"
+
+	// controlPlaneMachineSetKind is the kind of the ControlPlaneMachineSet owning the control plane Machines.
+	controlPlaneMachineSetKind string = "ControlPlaneMachineSet"
+
+	reasonFailedToConvertMAPIMachineToCAPI string = "FailedToConvertMAPIMachineToCAPI"
+	reasonFailedToCreateCAPIMachine        string = "FailedToCreateCAPIMachine"
+	reasonResourceSynchronized             string = "ResourceSynchronized"
+	reasonConversionWarning                string = "ConversionWarning"
+	reasonUnsupportedOnCAPI                string = "UnsupportedOnCAPI"
+)
+
+// isControlPlaneMachine returns whether the MAPI Machine is a control plane Machine, either by its role
+// or because it is owned by the ControlPlaneMachineSet.
+func isControlPlaneMachine(mapiMachine *machinev1beta1.Machine) bool {
+	if mapiMachine.GetLabels()[machineRoleLabel] == masterMachineRole {
+		return true
+	}
+
+	for _, ref := range mapiMachine.GetOwnerReferences() {
+		if ref.Kind == controlPlaneMachineSetKind && ref.APIVersion == machinev1.GroupVersion.String() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withoutControlPlaneMachineSetOwner returns a copy of the MAPI Machine without its ControlPlaneMachineSet
+// owner reference. The CAPI control plane is externally managed, so the ControlPlaneMachineSet has no CAPI counterpart
+// and keeps managing the control plane from the MAPI side.
+func withoutControlPlaneMachineSetOwner(mapiMachine *machinev1beta1.Machine) *machinev1beta1.Machine {
+	machine := mapiMachine.DeepCopy()
+	ownerReferences := []metav1.OwnerReference{}
+
+	for _, ref := range machine.GetOwnerReferences() {
+		if ref.Kind == controlPlaneMachineSetKind && ref.APIVersion == machinev1.GroupVersion.String() {
+			continue
+		}
+
+		ownerReferences = append(ownerReferences, ref)
+	}
+
+	machine.SetOwnerReferences(ownerReferences)
+
+	return machine
+}
+
+// setControlPlaneMachineMetadata marks the converted CAPI Machine and InfraMachine as control plane members of the
+// cluster, and moves them to the CAPI namespace.
+// The failure domain is left as converted: the ControlPlaneMachineSet writes the failure domain of each Machine into
+// its providerSpec, from which the conversion derives the CAPI failure domain.
+func setControlPlaneMachineMetadata(capiMachine *capiv1beta1.Machine, infraMachine client.Object, clusterName, namespace string) {
+	labels := map[string]string{}
+	for key, value := range capiMachine.GetLabels() {
+		labels[key] = value
+	}
+
+	labels[capiv1beta1.MachineControlPlaneLabel] = ""
+	labels[capiv1beta1.ClusterNameLabel] = clusterName
+
+	capiMachine.SetLabels(labels)
+	capiMachine.SetNamespace(namespace)
+	capiMachine.Spec.InfrastructureRef.Namespace = namespace
+
+	// The InfraMachine should always have the same labels as the Machine.
+	infraMachine.SetLabels(labels)
+	infraMachine.SetNamespace(namespace)
+}
+
+// reconcileMAPIControlPlaneMachinetoCAPIMachine mirrors a MAPI authoritative control plane Machine into a CAPI Machine.
+// The mirror follows the externally managed control plane pattern: it carries the control plane label and has no
+// owner, as there is no CAPI control plane provider, and the CAPI Machine adopts the existing instance by provider ID.
+// Once created, the CAPI Machine and InfraMachine are not updated, as control plane changes are rolled out by the
+// ControlPlaneMachineSet replacing Machines.
+// The features of the ClusterCAPIOperatorConfig take precedence over the ones of the reconciler.
+func (r *MachineSyncReconciler) reconcileMAPIControlPlaneMachinetoCAPIMachine(ctx context.Context, mapiMachine *machinev1beta1.Machine, capiMachineNotFound bool,
+	features operatorconfig.Features) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	if !ptr.Deref(features.ControlPlaneMigration, r.ControlPlaneMigration) {
+		logger.V(1).Info("Control plane migration is disabled, skipping control plane machine")
+		return ctrl.Result{}, nil
+	}
+
+	if !capiMachineNotFound {
+		return ctrl.Result{}, r.applySynchronizedConditionWithPatch(ctx, mapiMachine, corev1.ConditionTrue,
+			reasonResourceSynchronized, "Successfully synchronized MAPI control plane Machine to CAPI", &mapiMachine.Generation)
+	}
+
+	capiMachine, infraMachine, warnings, err := r.convertMAPIToCAPIMachine(withoutControlPlaneMachineSetOwner(mapiMachine), features)
+	if err != nil {
+		conversionErr := fmt.Errorf("failed to convert MAPI machine to CAPI machine: %w", err)
+
+		// Fields that have no CAPI equivalent get their own reason, so that users can tell them apart
+		// from conversion failures that may be resolved by a future version of the conversion.
+		reason := reasonFailedToConvertMAPIMachineToCAPI
+		if mapi2capi.IsUnsupportedOnCAPI(err) {
+			reason = reasonUnsupportedOnCAPI
+		}
+
+		r.Recorder.Event(mapiMachine, corev1.EventTypeWarning, reason, conversionErr.Error())
+
+		if condErr := r.applySynchronizedConditionWithPatch(ctx, mapiMachine, corev1.ConditionFalse,
+			reason, conversionErr.Error(), nil); condErr != nil {
+			return ctrl.Result{}, utilerrors.NewAggregate([]error{conversionErr, condErr})
+		}
+
+		return ctrl.Result{}, conversionErr
+	}
+
+	for _, warning := range warnings {
+		logger.Info("Warning during conversion", "warning", warning)
+		r.Recorder.Event(mapiMachine, corev1.EventTypeWarning, reasonConversionWarning, warning)
+	}
+
+	setControlPlaneMachineMetadata(capiMachine, infraMachine, r.Infra.Status.InfrastructureName, r.CAPINamespace)
+
+	if err := r.createIfNotExists(ctx, infraMachine); err != nil {
+		createErr := fmt.Errorf("failed to create CAPI infra machine: %w", err)
+		if condErr := r.applySynchronizedConditionWithPatch(ctx, mapiMachine, corev1.ConditionFalse,
+			reasonFailedToCreateCAPIMachine, createErr.Error(), nil); condErr != nil {
+			return ctrl.Result{}, utilerrors.NewAggregate([]error{createErr, condErr})
+		}
+
+		return ctrl.Result{}, createErr
+	}
+
+	if err := r.createIfNotExists(ctx, capiMachine); err != nil {
+		createErr := fmt.Errorf("failed to create CAPI machine: %w", err)
+		if condErr := r.applySynchronizedConditionWithPatch(ctx, mapiMachine, corev1.ConditionFalse,
+			reasonFailedToCreateCAPIMachine, createErr.Error(), nil); condErr != nil {
+			return ctrl.Result{}, utilerrors.NewAggregate([]error{createErr, condErr})
+		}
+
+		return ctrl.Result{}, createErr
+	}
+
+	logger.Info("Mirrored control plane machine to CAPI", "failureDomain", capiMachine.Spec.FailureDomain)
+
+	return ctrl.Result{}, r.applySynchronizedConditionWithPatch(ctx, mapiMachine, corev1.ConditionTrue,
+		reasonResourceSynchronized, "Successfully synchronized MAPI control plane Machine to CAPI", &mapiMachine.Generation)
+}
+
+// createIfNotExists creates the object, tolerating it having been created since the cache was read.
+func (r *MachineSyncReconciler) createIfNotExists(ctx context.Context, obj client.Object) error {
+	if err := r.Create(ctx, obj); err != nil && !apierrors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create %s: %w", obj.GetName(), err)
+	}
+
+	return nil
+}
+
+// convertMAPIToCAPIMachine converts the MAPI Machine to a CAPI Machine and InfraMachine for the platform.
+// The features of the ClusterCAPIOperatorConfig take precedence over the ones of the reconciler.
+func (r *MachineSyncReconciler) convertMAPIToCAPIMachine(mapiMachine *machinev1beta1.Machine,
+	features operatorconfig.Features) (*capiv1beta1.Machine, client.Object, []string, error) {
+	switch r.Platform {
+	case configv1.AWSPlatformType:
+		return mapi2capi.FromAWSMachineAndInfra(mapiMachine, r.Infra).ToMachineAndInfrastructureMachine() //nolint:wrapcheck
+	case configv1.AzurePlatformType:
+		return mapi2capi.FromAzureMachineAndInfraWithOptions(mapiMachine, r.Infra, mapi2capi.AzureConversionOptions{
+			ManagedBootDiagnostics: ptr.Deref(features.AzureManagedBootDiagnostics, r.AzureManagedBootDiagnostics),
+		}).ToMachineAndInfrastructureMachine() //nolint:wrapcheck
+	case configv1.GCPPlatformType:
+		return mapi2capi.FromGCPMachineAndInfra(mapiMachine, r.Infra).ToMachineAndInfrastructureMachine() //nolint:wrapcheck
+	case configv1.OpenStackPlatformType:
+		return mapi2capi.FromOpenStackMachineAndInfra(mapiMachine, r.Infra).ToMachineAndInfrastructureMachine() //nolint:wrapcheck
+	case configv1.PowerVSPlatformType:
+		return mapi2capi.FromPowerVSMachineAndInfra(mapiMachine, r.Infra).ToMachineAndInfrastructureMachine() //nolint:wrapcheck
+	case configv1.VSpherePlatformType:
+		return mapi2capi.FromVSphereMachineAndInfra(mapiMachine, r.Infra).ToMachineAndInfrastructureMachine() //nolint:wrapcheck
+	// TODO(OCPCLOUD-xxxx): Nutanix Machines are not converted, neither the MAPI NutanixMachineProviderConfig nor the CAPX API is vendored.
+	// TODO(OCPCLOUD-xxxx): BareMetal Machines are not converted, neither the MAPI BareMetalMachineProviderSpec nor the CAPM3 API is vendored.
+	// TODO(OCPCLOUD-xxxx): IBM Cloud VPC Machines are not converted, neither the MAPI IBMCloudMachineProviderSpec nor the CAPIBM VPC API is vendored.
+	default:
+		return nil, nil, nil, fmt.Errorf("%w: %s", errPlatformNotSupported, r.Platform)
+	}
+}
+
+// applySynchronizedConditionWithPatch sets the Synchronized condition on the MAPI Machine and patches its status.
+// When the generation is provided, the synchronized generation is updated to match.
+func (r *MachineSyncReconciler) applySynchronizedConditionWithPatch(ctx context.Context, mapiMachine *machinev1beta1.Machine,
+	status corev1.ConditionStatus, reason, message string, generation *int64) error {
+	patchBase := client.MergeFrom(mapiMachine.DeepCopy())
+
+	severity := machinev1beta1.ConditionSeverityNone
+	if status != corev1.ConditionTrue {
+		severity = machinev1beta1.ConditionSeverityError
+	}
+
+	mapiMachine.Status.Conditions = util.SetMAPICondition(mapiMachine.Status.Conditions, machinev1beta1.Condition{
+		Type:     controllers.SynchronizedCondition,
+		Status:   status,
+		Severity: severity,
+		Reason:   reason,
+		Message:  message,
+	})
+
+	if generation != nil {
+		mapiMachine.Status.SynchronizedGeneration = *generation
+	}
+
+	if err := r.Status().Patch(ctx, mapiMachine, patchBase); err != nil {
+		return fmt.Errorf("failed to patch MAPI Machine status with synchronized condition: %w", err)
+	}
+
+	return nil
+}
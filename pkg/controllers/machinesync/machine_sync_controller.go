@@ -20,16 +20,28 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 
 	"github.com/go-logr/logr"
 	configv1 "github.com/openshift/api/config/v1"
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+	"github.com/openshift/cluster-capi-operator/pkg/metrics"
+	"github.com/openshift/cluster-capi-operator/pkg/operatorconfig"
+	"github.com/openshift/cluster-capi-operator/pkg/operatorstatus"
 	"github.com/openshift/cluster-capi-operator/pkg/util"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
-	awscapiv1beta1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta1"
+	awscapiv1beta2 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	azurecapiv1beta1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	gcpcapiv1beta1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	powervscapiv1beta2 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
+	openstackcapiv1beta1 "sigs.k8s.io/cluster-api-provider-openstack/api/v1beta1"
+	vspherecapiv1beta1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	capiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/annotations"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -43,6 +55,8 @@ const (
 	mapiNamespace  string = "openshift-machine-api"
 	machineSetKind string = "MachineSet"
 	controllerName string = "MachineSyncController"
+
+	reasonInvalidSyncConflictPolicy string = "InvalidSyncConflictPolicy"
 )
 
 var (
@@ -56,9 +70,26 @@ type MachineSyncReconciler struct {
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
 
+	Infra         *configv1.Infrastructure
 	Platform      configv1.PlatformType
 	CAPINamespace string
 	MAPINamespace string
+
+	// ControlPlaneMigration enables mirroring the control plane Machines into CAPI.
+	// When disabled, control plane Machines are not synchronized.
+	// The controlPlaneMigration feature of the ClusterCAPIOperatorConfig takes precedence.
+	ControlPlaneMigration bool
+
+	// AzureManagedBootDiagnostics enables managed boot diagnostics on the AzureMachines
+	// of Machines that do not configure boot diagnostics.
+	// The azureManagedBootDiagnostics feature of the ClusterCAPIOperatorConfig takes precedence.
+	AzureManagedBootDiagnostics bool
+
+	// OperatorStatus reports the machines failing to synchronize on the ClusterOperator, when set.
+	OperatorStatus *operatorstatus.ClusterOperatorStatusClient
+
+	failuresMu sync.Mutex
+	failures   syncFailures
 }
 
 // SetupWithManager sets the CoreClusterReconciler controller up with the given manager.
@@ -91,6 +122,11 @@ func (r *MachineSyncReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			handler.EnqueueRequestsFromMapFunc(util.RewriteNamespace(r.MAPINamespace)),
 			builder.WithPredicates(util.FilterNamespace(r.CAPINamespace)),
 		).
+		Watches(
+			operatorconfig.New(),
+			handler.EnqueueRequestsFromMapFunc(operatorconfig.EnqueueAll(mgr.GetClient(), &machinev1beta1.MachineList{}, r.MAPINamespace)),
+			builder.WithPredicates(operatorconfig.Predicate()),
+		).
 		Complete(r); err != nil {
 		return fmt.Errorf("failed to create controller: %w", err)
 	}
@@ -104,14 +140,38 @@ func (r *MachineSyncReconciler) SetupWithManager(mgr ctrl.Manager) error {
 }
 
 // Reconcile reconciles CAPI and MAPI machines for their respective namespaces.
+func (r *MachineSyncReconciler) Reconcile(ctx context.Context, req reconcile.Request) (ctrl.Result, error) {
+	result, err := r.reconcile(ctx, req)
+	if err != nil {
+		metrics.RecordReconcileError(controllerName, metrics.ErrorReason(err))
+	}
+
+	if statusErr := r.reportSyncResult(ctx, req.Name, err); statusErr != nil {
+		return ctrl.Result{}, errors.Join(err, fmt.Errorf("error syncing ClusterOperatorStatus: %w", statusErr))
+	}
+
+	return result, err
+}
+
+// reconcile synchronizes the CAPI and MAPI machines.
 //
 //nolint:funlen
-func (r *MachineSyncReconciler) Reconcile(ctx context.Context, req reconcile.Request) (ctrl.Result, error) {
+func (r *MachineSyncReconciler) reconcile(ctx context.Context, req reconcile.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx, "namespace", req.Namespace, "name", req.Name)
 
 	logger.V(1).Info("Reconciling machine")
 	defer logger.V(1).Info("Finished reconciling machine")
 
+	config, err := operatorconfig.GetSpec(ctx, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if config.Controllers.MachineSync.Disabled {
+		logger.V(1).Info("Machine sync is disabled by the operator config, skipping synchronization")
+		return ctrl.Result{}, nil
+	}
+
 	var mapiMachineNotFound, capiMachineNotFound bool
 
 	// Get the MAPI Machine.
@@ -151,21 +211,41 @@ func (r *MachineSyncReconciler) Reconcile(ctx context.Context, req reconcile.Req
 		return ctrl.Result{}, nil
 	}
 
+	// Pausing either copy stops the controller from overwriting manual edits, e.g. while debugging a divergence.
+	if annotations.HasPaused(mapiMachine) || annotations.HasPaused(capiMachine) {
+		logger.Info("Machine is paused, skipping synchronization", "annotation", capiv1beta1.PausedAnnotation)
+		return ctrl.Result{}, nil
+	}
+
+	// The policy is read from the MAPI machine, the default applies when it has not been created yet.
+	conflictPolicy, err := controllers.GetSyncConflictPolicy(mapiMachine)
+	if err != nil {
+		// The policy can only be fixed by updating the MAPI machine annotations, which triggers a new reconcile.
+		logger.Error(err, "Invalid sync conflict policy")
+		r.Recorder.Event(mapiMachine, corev1.EventTypeWarning, reasonInvalidSyncConflictPolicy, err.Error())
+
+		return ctrl.Result{}, nil
+	}
+
 	// We mirror if the CAPI machine is owned by a MachineSet which has a MAPI
 	// counterpart. This is because we want to be able to migrate in both directions.
 	if mapiMachineNotFound {
 		if shouldReconcile, err := r.shouldMirrorCAPIMachineToMAPIMachine(ctx, logger, capiMachine); err != nil {
 			return ctrl.Result{}, err
 		} else if shouldReconcile {
-			return r.reconcileCAPIMachinetoMAPIMachine(ctx, capiMachine, mapiMachine)
+			return r.reconcileCAPIMachinetoMAPIMachine(ctx, conflictPolicy, capiMachine, mapiMachine)
 		}
 	}
 
 	switch mapiMachine.Status.AuthoritativeAPI {
 	case machinev1beta1.MachineAuthorityMachineAPI:
-		return r.reconcileMAPIMachinetoCAPIMachine(ctx, mapiMachine, capiMachine)
+		if isControlPlaneMachine(mapiMachine) {
+			return r.reconcileMAPIControlPlaneMachinetoCAPIMachine(ctx, mapiMachine, capiMachineNotFound, config.Features)
+		}
+
+		return r.reconcileMAPIMachinetoCAPIMachine(ctx, conflictPolicy, mapiMachine, capiMachine)
 	case machinev1beta1.MachineAuthorityClusterAPI:
-		return r.reconcileCAPIMachinetoMAPIMachine(ctx, capiMachine, mapiMachine)
+		return r.reconcileCAPIMachinetoMAPIMachine(ctx, conflictPolicy, capiMachine, mapiMachine)
 	case machinev1beta1.MachineAuthorityMigrating:
 		logger.Info("machine currently migrating", "machine", mapiMachine.GetName())
 		return ctrl.Result{}, nil
@@ -176,12 +256,25 @@ func (r *MachineSyncReconciler) Reconcile(ctx context.Context, req reconcile.Req
 }
 
 // reconcileCAPIMachinetoMAPIMachine reconciles a CAPI Machine to a MAPI Machine.
-func (r *MachineSyncReconciler) reconcileCAPIMachinetoMAPIMachine(ctx context.Context, capiMachine *capiv1beta1.Machine, mapiMachine *machinev1beta1.Machine) (ctrl.Result, error) {
+// The conflict policy controls how a MAPI Machine that diverged from the CAPI Machine is reconciled.
+func (r *MachineSyncReconciler) reconcileCAPIMachinetoMAPIMachine(ctx context.Context, conflictPolicy controllers.SyncConflictPolicy, capiMachine *capiv1beta1.Machine, mapiMachine *machinev1beta1.Machine) (ctrl.Result, error) {
+	// TODO(OCPCLOUD-xxxx): create the MAPI mirror of the CAPI Machine and sync the rest of its spec.
+	if capiMachine.GetName() == "" || mapiMachine.GetName() == "" {
+		return ctrl.Result{}, nil
+	}
+
+	if r.Platform == configv1.AWSPlatformType {
+		if err := r.syncAWSMachineTagsToMAPIMachine(ctx, capiMachine, mapiMachine); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	return ctrl.Result{}, nil
 }
 
 // reconcileMAPIMachinetoCAPIMachine a MAPI Machine to a CAPI Machine.
-func (r *MachineSyncReconciler) reconcileMAPIMachinetoCAPIMachine(ctx context.Context, mapiMachine *machinev1beta1.Machine, capiMachine *capiv1beta1.Machine) (ctrl.Result, error) {
+// The conflict policy controls how a CAPI Machine that diverged from the MAPI Machine is reconciled.
+func (r *MachineSyncReconciler) reconcileMAPIMachinetoCAPIMachine(ctx context.Context, conflictPolicy controllers.SyncConflictPolicy, mapiMachine *machinev1beta1.Machine, capiMachine *capiv1beta1.Machine) (ctrl.Result, error) {
 	return ctrl.Result{}, nil
 }
 
@@ -192,7 +285,17 @@ func (r *MachineSyncReconciler) reconcileMAPIMachinetoCAPIMachine(ctx context.Co
 func getInfraMachineFromProvider(platform configv1.PlatformType) (client.Object, error) {
 	switch platform {
 	case configv1.AWSPlatformType:
-		return &awscapiv1beta1.AWSMachine{}, nil
+		return &awscapiv1beta2.AWSMachine{}, nil
+	case configv1.AzurePlatformType:
+		return &azurecapiv1beta1.AzureMachine{}, nil
+	case configv1.GCPPlatformType:
+		return &gcpcapiv1beta1.GCPMachine{}, nil
+	case configv1.OpenStackPlatformType:
+		return &openstackcapiv1beta1.OpenStackMachine{}, nil
+	case configv1.PowerVSPlatformType:
+		return &powervscapiv1beta2.IBMPowerVSMachine{}, nil
+	case configv1.VSpherePlatformType:
+		return &vspherecapiv1beta1.VSphereMachine{}, nil
 	default:
 		return nil, fmt.Errorf("%w: %s", errPlatformNotSupported, platform)
 	}
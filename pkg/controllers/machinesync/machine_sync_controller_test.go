@@ -18,21 +18,29 @@ package machinesync
 
 import (
 	"context"
+	"encoding/json"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	configv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1"
 	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
 	corev1resourcebuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/core/v1"
 	machinev1resourcebuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/machine/v1beta1"
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/utils/ptr"
+	awscapiv1beta2 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	azurecapiv1beta1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	capiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/config"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
+	"github.com/openshift/cluster-capi-operator/pkg/operatorconfig"
 	"github.com/openshift/cluster-capi-operator/pkg/test"
 )
 
@@ -123,3 +131,86 @@ var _ = Describe("MachineSync Reconciler", func() {
 		Expect(err).ToNot(HaveOccurred())
 	})
 })
+
+var _ = Describe("Control plane machines", func() {
+	cpmsOwnerReference := metav1.OwnerReference{
+		APIVersion: machinev1.GroupVersion.String(),
+		Kind:       controlPlaneMachineSetKind,
+		Name:       "cluster",
+	}
+
+	DescribeTable("isControlPlaneMachine",
+		func(labels map[string]string, ownerReferences []metav1.OwnerReference, expected bool) {
+			machine := machinev1resourcebuilder.Machine().WithLabels(labels).Build()
+			machine.SetOwnerReferences(ownerReferences)
+
+			Expect(isControlPlaneMachine(machine)).To(Equal(expected))
+		},
+		Entry("with the master role", map[string]string{machineRoleLabel: masterMachineRole}, nil, true),
+		Entry("with a ControlPlaneMachineSet owner", nil, []metav1.OwnerReference{cpmsOwnerReference}, true),
+		Entry("with the worker role", map[string]string{machineRoleLabel: "worker"}, nil, false),
+		Entry("with a MachineSet owner", nil, []metav1.OwnerReference{{
+			APIVersion: machinev1beta1.GroupVersion.String(),
+			Kind:       machineSetKind,
+			Name:       "worker",
+		}}, false),
+	)
+
+	It("should drop only the ControlPlaneMachineSet owner reference for conversion", func() {
+		otherOwnerReference := metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "other"}
+
+		machine := machinev1resourcebuilder.Machine().Build()
+		machine.SetOwnerReferences([]metav1.OwnerReference{cpmsOwnerReference, otherOwnerReference})
+
+		Expect(withoutControlPlaneMachineSetOwner(machine).GetOwnerReferences()).To(ConsistOf(otherOwnerReference))
+		Expect(machine.GetOwnerReferences()).To(HaveLen(2), "The original machine should not be modified")
+	})
+
+	It("should mark the converted machine as part of an externally managed control plane", func() {
+		capiMachine := &capiv1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: "master-0", Labels: map[string]string{machineRoleLabel: masterMachineRole}},
+			Spec:       capiv1beta1.MachineSpec{FailureDomain: ptr.To("us-east-1a")},
+		}
+		infraMachine := &awscapiv1beta2.AWSMachine{}
+
+		setControlPlaneMachineMetadata(capiMachine, infraMachine, "cluster-abcde", "capi-namespace")
+
+		expectedLabels := map[string]string{
+			machineRoleLabel:                     masterMachineRole,
+			capiv1beta1.MachineControlPlaneLabel: "",
+			capiv1beta1.ClusterNameLabel:         "cluster-abcde",
+		}
+		Expect(capiMachine.GetLabels()).To(Equal(expectedLabels))
+		Expect(infraMachine.GetLabels()).To(Equal(expectedLabels))
+		Expect(capiMachine.GetNamespace()).To(Equal("capi-namespace"))
+		Expect(capiMachine.Spec.InfrastructureRef.Namespace).To(Equal("capi-namespace"))
+		Expect(infraMachine.GetNamespace()).To(Equal("capi-namespace"))
+		Expect(capiMachine.GetOwnerReferences()).To(BeEmpty())
+		Expect(capiMachine.Spec.FailureDomain).To(HaveValue(Equal("us-east-1a")))
+	})
+})
+
+var _ = DescribeTable("convertMAPIToCAPIMachine Azure managed boot diagnostics default",
+	func(reconcilerDefault bool, configFeature *bool, expected azurecapiv1beta1.BootDiagnosticsStorageAccountType) {
+		r := &MachineSyncReconciler{
+			Platform:                    configv1.AzurePlatformType,
+			Infra:                       &configv1.Infrastructure{Status: configv1.InfrastructureStatus{InfrastructureName: "sample-cluster-name"}},
+			AzureManagedBootDiagnostics: reconcilerDefault,
+		}
+
+		rawProviderSpec, err := json.Marshal(machinev1resourcebuilder.AzureProviderSpec().WithInternalLoadBalancer("").Build())
+		Expect(err).ToNot(HaveOccurred())
+
+		mapiMachine := machinev1resourcebuilder.Machine().WithProviderSpec(machinev1beta1.ProviderSpec{
+			Value: &runtime.RawExtension{Raw: rawProviderSpec},
+		}).Build()
+
+		_, infraMachine, _, err := r.convertMAPIToCAPIMachine(mapiMachine, operatorconfig.Features{AzureManagedBootDiagnostics: configFeature})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(infraMachine).To(HaveField("Spec.Diagnostics.Boot.StorageAccountType", Equal(expected)))
+	},
+	Entry("when the default is off", false, nil, azurecapiv1beta1.DisabledDiagnosticsStorage),
+	Entry("when the default is on", true, nil, azurecapiv1beta1.ManagedDiagnosticsStorage),
+	Entry("when the operator config enables the default over the flag", false, ptr.To(true), azurecapiv1beta1.ManagedDiagnosticsStorage),
+	Entry("when the operator config disables the default over the flag", true, ptr.To(false), azurecapiv1beta1.DisabledDiagnosticsStorage),
+)
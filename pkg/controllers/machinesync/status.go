@@ -0,0 +1,132 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machinesync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/cluster-capi-operator/pkg/operatorstatus"
+)
+
+const (
+	// Controller conditions for the Cluster Operator resource.
+	machineSyncControllerAvailableCondition = "MachineSyncControllerAvailable"
+	machineSyncControllerDegradedCondition  = "MachineSyncControllerDegraded"
+
+	// reasonMachinesFailingSync is used on the degraded condition when some machines fail to synchronize.
+	reasonMachinesFailingSync = "MachinesFailingSync"
+
+	// maxReportedFailures limits how many failing machines are listed on the degraded condition.
+	maxReportedFailures = 10
+)
+
+// syncFailures tracks the machines whose last reconcile failed, to report them on the ClusterOperator.
+type syncFailures struct {
+	// failures maps the name of the failing machines to their last error.
+	failures map[string]string
+	// reported is whether the failures were reported on the ClusterOperator.
+	reported bool
+}
+
+// record records the result of the reconcile of the machine, and returns whether the failing machines changed
+// since they were last reported.
+func (f *syncFailures) record(name string, reconcileErr error) bool {
+	if f.failures == nil {
+		f.failures = map[string]string{}
+	}
+
+	previous, failed := f.failures[name]
+
+	switch {
+	case reconcileErr != nil:
+		f.failures[name] = reconcileErr.Error()
+		return !f.reported || !failed || previous != reconcileErr.Error()
+	case failed:
+		delete(f.failures, name)
+		return true
+	default:
+		return !f.reported
+	}
+}
+
+// message formats the failing machines for the cluster operator condition message.
+func (f *syncFailures) message() string {
+	names := make([]string, 0, len(f.failures))
+	for name := range f.failures {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	messages := []string{}
+
+	for _, name := range names {
+		if len(messages) == maxReportedFailures {
+			messages = append(messages, fmt.Sprintf("and %d more", len(names)-maxReportedFailures))
+			break
+		}
+
+		messages = append(messages, fmt.Sprintf("Machine %s: %s", name, f.failures[name]))
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// reportSyncResult records the result of the reconcile of the machine, and reports the failing machines on
+// the ClusterOperator when they changed.
+func (r *MachineSyncReconciler) reportSyncResult(ctx context.Context, name string, reconcileErr error) error {
+	if r.OperatorStatus == nil {
+		return nil
+	}
+
+	r.failuresMu.Lock()
+	defer r.failuresMu.Unlock()
+
+	if !r.failures.record(name, reconcileErr) {
+		return nil
+	}
+
+	co, err := r.OperatorStatus.GetOrCreateClusterOperator(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get cluster operator: %w", err)
+	}
+
+	degradedCondition := operatorstatus.NewClusterOperatorStatusCondition(machineSyncControllerDegradedCondition, configv1.ConditionFalse,
+		operatorstatus.ReasonAsExpected, "Machine Sync Controller works as expected")
+	if len(r.failures.failures) > 0 {
+		degradedCondition = operatorstatus.NewClusterOperatorStatusCondition(machineSyncControllerDegradedCondition, configv1.ConditionTrue,
+			reasonMachinesFailingSync, r.failures.message())
+	}
+
+	conds := []configv1.ClusterOperatorStatusCondition{
+		operatorstatus.NewClusterOperatorStatusCondition(machineSyncControllerAvailableCondition, configv1.ConditionTrue,
+			operatorstatus.ReasonAsExpected, "Machine Sync Controller works as expected"),
+		degradedCondition,
+	}
+
+	if err := r.OperatorStatus.SyncStatus(ctx, co, conds); err != nil {
+		return fmt.Errorf("failed to sync status: %w", err)
+	}
+
+	r.failures.reported = true
+
+	return nil
+}
@@ -0,0 +1,61 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package machinesync
+
+import (
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("syncFailures", func() {
+	var failures syncFailures
+
+	BeforeEach(func() {
+		failures = syncFailures{}
+	})
+
+	It("should report the first result", func() {
+		Expect(failures.record("worker-a", nil)).To(BeTrue())
+		failures.reported = true
+
+		Expect(failures.record("worker-b", nil)).To(BeFalse())
+	})
+
+	It("should report when the failing machines change", func() {
+		failures.reported = true
+
+		Expect(failures.record("worker-a", errors.New("conversion failed"))).To(BeTrue())
+		Expect(failures.record("worker-a", errors.New("conversion failed"))).To(BeFalse())
+		Expect(failures.record("worker-a", errors.New("update failed"))).To(BeTrue())
+		Expect(failures.message()).To(Equal("Machine worker-a: update failed"))
+
+		Expect(failures.record("worker-a", nil)).To(BeTrue())
+		Expect(failures.failures).To(BeEmpty())
+	})
+
+	It("should limit the number of failing machines in the message", func() {
+		for i := range maxReportedFailures + 2 {
+			failures.record(fmt.Sprintf("worker-%02d", i), errors.New("failed"))
+		}
+
+		Expect(failures.message()).To(HavePrefix("Machine worker-00: failed; Machine worker-01: failed"))
+		Expect(failures.message()).To(HaveSuffix("Machine worker-09: failed; and 2 more"))
+	})
+})
@@ -0,0 +1,104 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package machinesync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	awscapiv1beta2 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	capiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/cluster-capi-operator/pkg/util"
+)
+
+// syncAWSMachineTagsToMAPIMachine keeps the tags of the providerSpec of the MAPI Machine in sync with the additionalTags
+// of the AWSMachine of the authoritative CAPI Machine, with util.SyncTags: the AWSMachine wins on conflicting keys,
+// tags removed from it are removed from the MAPI Machine, and tags added to the MAPI Machine directly are kept.
+// Only the tags of the providerSpec are rewritten, so its other fields are left as they are.
+func (r *MachineSyncReconciler) syncAWSMachineTagsToMAPIMachine(ctx context.Context, capiMachine *capiv1beta1.Machine, mapiMachine *machinev1beta1.Machine) error {
+	awsMachine := &awscapiv1beta2.AWSMachine{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: r.CAPINamespace, Name: capiMachine.Spec.InfrastructureRef.Name}, awsMachine); apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get AWSMachine %s: %w", capiMachine.Spec.InfrastructureRef.Name, err)
+	}
+
+	if mapiMachine.Spec.ProviderSpec.Value == nil {
+		return nil
+	}
+
+	providerSpec := map[string]interface{}{}
+	if err := json.Unmarshal(mapiMachine.Spec.ProviderSpec.Value.Raw, &providerSpec); err != nil {
+		return fmt.Errorf("failed to unmarshal the providerSpec of MAPI Machine: %w", err)
+	}
+
+	var currentTags []machinev1beta1.TagSpecification
+	if rawTags, err := json.Marshal(providerSpec["tags"]); err != nil {
+		return fmt.Errorf("failed to marshal the tags of MAPI Machine: %w", err)
+	} else if err := json.Unmarshal(rawTags, &currentTags); err != nil {
+		return fmt.Errorf("failed to unmarshal the tags of MAPI Machine: %w", err)
+	}
+
+	current := map[string]string{}
+	for _, tag := range currentTags {
+		current[tag.Name] = tag.Value
+	}
+
+	synced := util.SyncTags(awsMachine.Spec.AdditionalTags, current, util.GetManagedTagKeys(mapiMachine))
+
+	updatedMAPIMachine := mapiMachine.DeepCopy()
+	util.SetManagedTagKeys(updatedMAPIMachine, awsMachine.Spec.AdditionalTags)
+
+	if maps.Equal(synced, current) && maps.Equal(updatedMAPIMachine.GetAnnotations(), mapiMachine.GetAnnotations()) {
+		return nil
+	}
+
+	// The tags are sorted so that repeated synchronizations of the same tags produce the same providerSpec.
+	keys := make([]string, 0, len(synced))
+	for key := range synced {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	tags := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		tags = append(tags, map[string]interface{}{"name": key, "value": synced[key]})
+	}
+
+	providerSpec["tags"] = tags
+
+	rawProviderSpec, err := json.Marshal(providerSpec)
+	if err != nil {
+		return fmt.Errorf("failed to marshal the providerSpec of MAPI Machine: %w", err)
+	}
+
+	updatedMAPIMachine.Spec.ProviderSpec.Value = &runtime.RawExtension{Raw: rawProviderSpec}
+
+	if err := r.Patch(ctx, updatedMAPIMachine, client.MergeFrom(mapiMachine)); err != nil {
+		return fmt.Errorf("failed to update the tags of MAPI Machine: %w", err)
+	}
+
+	return nil
+}
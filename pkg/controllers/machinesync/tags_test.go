@@ -0,0 +1,125 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package machinesync
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	configv1 "github.com/openshift/api/config/v1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	awscapiv1beta2 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	capiv1beta1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/cluster-capi-operator/pkg/util"
+)
+
+var _ = DescribeTable("syncAWSMachineTagsToMAPIMachine",
+	func(additionalTags map[string]string, mapiTags []machinev1beta1.TagSpecification, managedTags string, expectTags []machinev1beta1.TagSpecification) {
+		scheme := runtime.NewScheme()
+		utilruntime.Must(awscapiv1beta2.AddToScheme(scheme))
+		utilruntime.Must(machinev1beta1.AddToScheme(scheme))
+
+		rawProviderSpec, err := json.Marshal(map[string]interface{}{
+			"instanceType": "m5.large",
+			"tags":         mapiTags,
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		mapiMachine := &machinev1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "openshift-machine-api"},
+			Spec: machinev1beta1.MachineSpec{
+				ProviderSpec: machinev1beta1.ProviderSpec{Value: &runtime.RawExtension{Raw: rawProviderSpec}},
+			},
+		}
+		if managedTags != "" {
+			mapiMachine.SetAnnotations(map[string]string{util.ManagedTagsAnnotation: managedTags})
+		}
+
+		awsMachine := &awscapiv1beta2.AWSMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "openshift-cluster-api"},
+			Spec:       awscapiv1beta2.AWSMachineSpec{AdditionalTags: additionalTags},
+		}
+
+		capiMachine := &capiv1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "openshift-cluster-api"},
+			Spec: capiv1beta1.MachineSpec{
+				InfrastructureRef: corev1.ObjectReference{Kind: "AWSMachine", Name: "worker"},
+			},
+		}
+
+		reconciler := &MachineSyncReconciler{
+			Client:        fake.NewClientBuilder().WithScheme(scheme).WithObjects(mapiMachine, awsMachine).Build(),
+			Scheme:        scheme,
+			Platform:      configv1.AWSPlatformType,
+			CAPINamespace: "openshift-cluster-api",
+			MAPINamespace: "openshift-machine-api",
+		}
+
+		Expect(reconciler.syncAWSMachineTagsToMAPIMachine(ctx, capiMachine, mapiMachine)).To(Succeed())
+
+		updated := &machinev1beta1.Machine{}
+		Expect(reconciler.Get(ctx, client.ObjectKeyFromObject(mapiMachine), updated)).To(Succeed())
+
+		providerSpec := &machinev1beta1.AWSMachineProviderConfig{}
+		Expect(json.Unmarshal(updated.Spec.ProviderSpec.Value.Raw, providerSpec)).To(Succeed())
+
+		Expect(providerSpec.InstanceType).To(Equal("m5.large"))
+		Expect(providerSpec.Tags).To(ConsistOf(expectTags))
+		Expect(util.GetManagedTagKeys(updated)).To(ConsistOf(mapKeys(additionalTags)))
+	},
+	Entry("adds the AWSMachine tags to the MAPI Machine",
+		map[string]string{"team": "a"},
+		nil,
+		"",
+		[]machinev1beta1.TagSpecification{{Name: "team", Value: "a"}},
+	),
+	Entry("overwrites the conflicting tags with the AWSMachine ones",
+		map[string]string{"team": "a"},
+		[]machinev1beta1.TagSpecification{{Name: "team", Value: "b"}},
+		"team",
+		[]machinev1beta1.TagSpecification{{Name: "team", Value: "a"}},
+	),
+	Entry("removes the tags removed from the AWSMachine",
+		map[string]string{"team": "a"},
+		[]machinev1beta1.TagSpecification{{Name: "team", Value: "a"}, {Name: "owner", Value: "b"}},
+		"owner,team",
+		[]machinev1beta1.TagSpecification{{Name: "team", Value: "a"}},
+	),
+	Entry("keeps the tags added to the MAPI Machine directly",
+		map[string]string{"team": "a"},
+		[]machinev1beta1.TagSpecification{{Name: "owner", Value: "b"}},
+		"",
+		[]machinev1beta1.TagSpecification{{Name: "team", Value: "a"}, {Name: "owner", Value: "b"}},
+	),
+)
+
+func mapKeys(m map[string]string) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
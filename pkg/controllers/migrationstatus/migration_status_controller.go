@@ -0,0 +1,265 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package migrationstatus
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+	"github.com/openshift/cluster-capi-operator/pkg/metrics"
+	"github.com/openshift/cluster-capi-operator/pkg/operatorstatus"
+	"github.com/openshift/cluster-capi-operator/pkg/util"
+)
+
+const (
+	controllerName = "MigrationStatusController"
+
+	// Controller conditions for the Cluster Operator resource.
+	migrationStatusControllerAvailableCondition = "MigrationStatusControllerAvailable"
+	migrationStatusControllerDegradedCondition  = "MigrationStatusControllerDegraded"
+	// migrationStatusControllerUpgradeableCondition blocks cluster upgrades while the migration is in flight.
+	migrationStatusControllerUpgradeableCondition = "MigrationStatusControllerUpgradeable"
+
+	// reasonResourcesNotSynchronized is used on the degraded condition when some resources fail to synchronize.
+	reasonResourcesNotSynchronized = "ResourcesNotSynchronized"
+
+	// reasonMigrationInProgress is used on the upgradeable condition when some resources are migrating or fail
+	// to synchronize, so a cluster upgrade does not land in the middle of a half-migrated fleet.
+	reasonMigrationInProgress = "MigrationInProgress"
+
+	// maxReportedFailures limits how many failing resources are listed on the degraded condition.
+	maxReportedFailures = 10
+)
+
+// authorityCounts counts the resources of a kind by authoritative API and synchronization state.
+type authorityCounts struct {
+	MachineAPI   int
+	ClusterAPI   int
+	Migrating    int
+	Synchronized int
+	Failing      int
+}
+
+// String formats the counts for the cluster operator condition message.
+func (c authorityCounts) String() string {
+	return fmt.Sprintf("%d MachineAPI authoritative, %d ClusterAPI authoritative, %d migrating, %d synchronized, %d failing",
+		c.MachineAPI, c.ClusterAPI, c.Migrating, c.Synchronized, c.Failing)
+}
+
+// migrationSummary summarizes the migration progress of the MAPI MachineSets and Machines.
+type migrationSummary struct {
+	MachineSets authorityCounts
+	Machines    authorityCounts
+
+	// Failures lists the resources whose Synchronized condition is False, with their reason and message.
+	Failures []string
+}
+
+// Message formats the counts for the cluster operator condition message.
+func (s migrationSummary) Message() string {
+	return fmt.Sprintf("MachineSets: %s. Machines: %s.", s.MachineSets, s.Machines)
+}
+
+// UpgradeBlocker explains why the cluster should not be upgraded while the migration is in flight,
+// it is empty when no resource is migrating or failing to synchronize.
+func (s migrationSummary) UpgradeBlocker() string {
+	blockers := []string{}
+
+	if s.MachineSets.Migrating+s.Machines.Migrating > 0 {
+		blockers = append(blockers, fmt.Sprintf("%d MachineSets and %d Machines are migrating between Machine API and Cluster API",
+			s.MachineSets.Migrating, s.Machines.Migrating))
+	}
+
+	if len(s.Failures) > 0 {
+		blockers = append(blockers, fmt.Sprintf("%d MachineSets and Machines fail to synchronize", len(s.Failures)))
+	}
+
+	if len(blockers) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%s. Complete or roll back the migration before upgrading the cluster.", strings.Join(blockers, ", "))
+}
+
+// FailuresMessage formats the failing resources for the cluster operator condition message.
+func (s migrationSummary) FailuresMessage() string {
+	if len(s.Failures) <= maxReportedFailures {
+		return strings.Join(s.Failures, "; ")
+	}
+
+	return fmt.Sprintf("%s; and %d more", strings.Join(s.Failures[:maxReportedFailures], "; "), len(s.Failures)-maxReportedFailures)
+}
+
+// MigrationStatusReconciler reports the migration progress of MAPI MachineSets and Machines on the ClusterOperator.
+type MigrationStatusReconciler struct {
+	operatorstatus.ClusterOperatorStatusClient
+
+	MAPINamespace string
+}
+
+// Reconcile summarizes the MAPI MachineSets and Machines and reports the summary on the ClusterOperator.
+func (r *MigrationStatusReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx).WithName(controllerName)
+	log.V(1).Info("reconciling migration status")
+
+	machineSets := &machinev1beta1.MachineSetList{}
+	if err := r.List(ctx, machineSets, client.InNamespace(r.MAPINamespace)); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list MAPI MachineSets: %w", err)
+	}
+
+	machines := &machinev1beta1.MachineList{}
+	if err := r.List(ctx, machines, client.InNamespace(r.MAPINamespace)); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list MAPI Machines: %w", err)
+	}
+
+	summary := summarizeMigrationStatus(machineSets.Items, machines.Items)
+
+	metrics.SetMigrationFailingResources("MachineSet", summary.MachineSets.Failing)
+	metrics.SetMigrationFailingResources("Machine", summary.Machines.Failing)
+
+	if err := r.setConditions(ctx, summary); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to set conditions for migration status controller: %w", err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// setConditions reports the migration summary on the ClusterOperator.
+func (r *MigrationStatusReconciler) setConditions(ctx context.Context, summary migrationSummary) error {
+	co, err := r.GetOrCreateClusterOperator(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get cluster operator: %w", err)
+	}
+
+	degradedCondition := operatorstatus.NewClusterOperatorStatusCondition(migrationStatusControllerDegradedCondition, configv1.ConditionFalse,
+		operatorstatus.ReasonAsExpected, "All MachineSets and Machines are synchronized")
+	if len(summary.Failures) > 0 {
+		degradedCondition = operatorstatus.NewClusterOperatorStatusCondition(migrationStatusControllerDegradedCondition, configv1.ConditionTrue,
+			reasonResourcesNotSynchronized, summary.FailuresMessage())
+	}
+
+	upgradeableCondition := operatorstatus.NewClusterOperatorStatusCondition(migrationStatusControllerUpgradeableCondition, configv1.ConditionTrue,
+		operatorstatus.ReasonAsExpected, "No migration is in flight")
+	if blocker := summary.UpgradeBlocker(); blocker != "" {
+		upgradeableCondition = operatorstatus.NewClusterOperatorStatusCondition(migrationStatusControllerUpgradeableCondition, configv1.ConditionFalse,
+			reasonMigrationInProgress, blocker)
+	}
+
+	conds := []configv1.ClusterOperatorStatusCondition{
+		operatorstatus.NewClusterOperatorStatusCondition(migrationStatusControllerAvailableCondition, configv1.ConditionTrue,
+			operatorstatus.ReasonAsExpected, summary.Message()),
+		degradedCondition,
+		upgradeableCondition,
+	}
+
+	if err := r.SyncStatus(ctx, co, conds); err != nil {
+		return fmt.Errorf("failed to sync status: %w", err)
+	}
+
+	return nil
+}
+
+// summarizeMigrationStatus counts the MAPI MachineSets and Machines by authoritative API and synchronization state.
+func summarizeMigrationStatus(machineSets []machinev1beta1.MachineSet, machines []machinev1beta1.Machine) migrationSummary {
+	summary := migrationSummary{Failures: []string{}}
+
+	for _, machineSet := range machineSets {
+		countResource(&summary.MachineSets, machineSet.Status.AuthoritativeAPI, machineSet.Status.Conditions)
+		summary.Failures = appendFailure(summary.Failures, "MachineSet", machineSet.GetName(), machineSet.Status.Conditions)
+	}
+
+	for _, machine := range machines {
+		countResource(&summary.Machines, machine.Status.AuthoritativeAPI, machine.Status.Conditions)
+		summary.Failures = appendFailure(summary.Failures, "Machine", machine.GetName(), machine.Status.Conditions)
+	}
+
+	sort.Strings(summary.Failures)
+
+	return summary
+}
+
+// countResource adds a resource to the counts based on its authoritative API and Synchronized condition.
+func countResource(counts *authorityCounts, authority machinev1beta1.MachineAuthority, conditions []machinev1beta1.Condition) {
+	switch authority {
+	case machinev1beta1.MachineAuthorityMachineAPI:
+		counts.MachineAPI++
+	case machinev1beta1.MachineAuthorityClusterAPI:
+		counts.ClusterAPI++
+	case machinev1beta1.MachineAuthorityMigrating:
+		counts.Migrating++
+	}
+
+	if condition := util.GetMAPICondition(conditions, controllers.SynchronizedCondition); condition != nil {
+		switch condition.Status {
+		case corev1.ConditionTrue:
+			counts.Synchronized++
+		case corev1.ConditionFalse:
+			counts.Failing++
+		}
+	}
+}
+
+// appendFailure appends the resource to the failures when its Synchronized condition is False.
+func appendFailure(failures []string, kind, name string, conditions []machinev1beta1.Condition) []string {
+	condition := util.GetMAPICondition(conditions, controllers.SynchronizedCondition)
+	if condition == nil || condition.Status != corev1.ConditionFalse {
+		return failures
+	}
+
+	return append(failures, fmt.Sprintf("%s %s: %s: %s", kind, name, condition.Reason, condition.Message))
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MigrationStatusReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.MAPINamespace == "" {
+		r.MAPINamespace = controllers.DefaultMAPIManagedNamespace
+	}
+
+	// Every change results in the same summary being recomputed, so all events map to a single request.
+	toClusterOperator := handler.EnqueueRequestsFromMapFunc(func(context.Context, client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: client.ObjectKey{Name: controllers.ClusterOperatorName}}}
+	})
+
+	if err := ctrl.NewControllerManagedBy(mgr).
+		Named(controllerName).
+		Watches(
+			&machinev1beta1.MachineSet{},
+			toClusterOperator,
+			builder.WithPredicates(util.FilterNamespace(r.MAPINamespace)),
+		).
+		Watches(
+			&machinev1beta1.Machine{},
+			toClusterOperator,
+			builder.WithPredicates(util.FilterNamespace(r.MAPINamespace)),
+		).
+		Complete(r); err != nil {
+		return fmt.Errorf("failed to create controller: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package migrationstatus
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	machinev1resourcebuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+)
+
+// synchronizedCondition returns a Synchronized condition with the given status and reason.
+func synchronizedCondition(status corev1.ConditionStatus, reason, message string) []machinev1beta1.Condition {
+	return []machinev1beta1.Condition{{
+		Type:    controllers.SynchronizedCondition,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}}
+}
+
+var _ = DescribeTable("summarizeMigrationStatus",
+	func(machineSets []machinev1beta1.MachineSet, machines []machinev1beta1.Machine, expectedSummary migrationSummary) {
+		Expect(summarizeMigrationStatus(machineSets, machines)).To(Equal(expectedSummary))
+	},
+	Entry("with no resources", nil, nil, migrationSummary{Failures: []string{}}),
+	Entry("with resources of every authority", []machinev1beta1.MachineSet{
+		*machinev1resourcebuilder.MachineSet().WithName("mapi").
+			WithAuthoritativeAPIStatus(machinev1beta1.MachineAuthorityMachineAPI).
+			WithConditions(synchronizedCondition(corev1.ConditionTrue, "ResourceSynchronized", "")).Build(),
+		*machinev1resourcebuilder.MachineSet().WithName("capi").
+			WithAuthoritativeAPIStatus(machinev1beta1.MachineAuthorityClusterAPI).Build(),
+		*machinev1resourcebuilder.MachineSet().WithName("migrating").
+			WithAuthoritativeAPIStatus(machinev1beta1.MachineAuthorityMigrating).Build(),
+	}, []machinev1beta1.Machine{
+		*machinev1resourcebuilder.Machine().WithName("mapi").
+			WithAuthoritativeAPIStatus(machinev1beta1.MachineAuthorityMachineAPI).Build(),
+	}, migrationSummary{
+		MachineSets: authorityCounts{MachineAPI: 1, ClusterAPI: 1, Migrating: 1, Synchronized: 1},
+		Machines:    authorityCounts{MachineAPI: 1},
+		Failures:    []string{},
+	}),
+	Entry("with resources failing to synchronize", []machinev1beta1.MachineSet{
+		*machinev1resourcebuilder.MachineSet().WithName("unsupported").
+			WithAuthoritativeAPIStatus(machinev1beta1.MachineAuthorityMachineAPI).
+			WithConditions(synchronizedCondition(corev1.ConditionFalse, "UnsupportedOnCAPI", "field is not supported")).Build(),
+		*machinev1resourcebuilder.MachineSet().WithName("blocked").
+			WithAuthoritativeAPIStatus(machinev1beta1.MachineAuthorityClusterAPI).
+			WithConditions(synchronizedCondition(corev1.ConditionFalse, "RollbackBlocked", "machines are provisioning")).Build(),
+	}, nil, migrationSummary{
+		MachineSets: authorityCounts{MachineAPI: 1, ClusterAPI: 1, Failing: 2},
+		Failures: []string{
+			"MachineSet blocked: RollbackBlocked: machines are provisioning",
+			"MachineSet unsupported: UnsupportedOnCAPI: field is not supported",
+		},
+	}),
+)
+
+var _ = Describe("migrationSummary", func() {
+	It("should format the counts", func() {
+		summary := migrationSummary{
+			MachineSets: authorityCounts{MachineAPI: 2, ClusterAPI: 1, Synchronized: 3},
+			Machines:    authorityCounts{Migrating: 1, Failing: 1},
+		}
+
+		Expect(summary.Message()).To(Equal("MachineSets: 2 MachineAPI authoritative, 1 ClusterAPI authoritative, 0 migrating, 3 synchronized, 0 failing. " +
+			"Machines: 0 MachineAPI authoritative, 0 ClusterAPI authoritative, 1 migrating, 0 synchronized, 1 failing."))
+	})
+
+	It("should limit the number of reported failures", func() {
+		summary := migrationSummary{}
+		for i := range maxReportedFailures + 2 {
+			summary.Failures = append(summary.Failures, fmt.Sprintf("Machine %d: Reason: message", i))
+		}
+
+		Expect(summary.FailuresMessage()).To(SatisfyAll(
+			ContainSubstring("Machine 9: Reason: message; and 2 more"),
+			Not(ContainSubstring("Machine 10")),
+		))
+	})
+
+	It("should not block upgrades without a migration in flight", func() {
+		summary := migrationSummary{
+			MachineSets: authorityCounts{MachineAPI: 2, ClusterAPI: 1, Synchronized: 3},
+			Machines:    authorityCounts{MachineAPI: 4, Synchronized: 4},
+		}
+
+		Expect(summary.UpgradeBlocker()).To(BeEmpty())
+	})
+
+	It("should block upgrades while resources are migrating or failing to synchronize", func() {
+		summary := migrationSummary{
+			MachineSets: authorityCounts{Migrating: 1},
+			Machines:    authorityCounts{Migrating: 2, Failing: 1},
+			Failures:    []string{"Machine worker-a: ConversionFailed: unsupported field"},
+		}
+
+		Expect(summary.UpgradeBlocker()).To(Equal("1 MachineSets and 2 Machines are migrating between Machine API and Cluster API, " +
+			"1 MachineSets and Machines fail to synchronize. Complete or roll back the migration before upgrading the cluster."))
+	})
+})
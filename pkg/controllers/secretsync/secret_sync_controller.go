@@ -32,7 +32,8 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 
 	configv1 "github.com/openshift/api/config/v1"
-	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+	"github.com/openshift/cluster-capi-operator/pkg/metrics"
+	"github.com/openshift/cluster-capi-operator/pkg/operatorconfig"
 	"github.com/openshift/cluster-capi-operator/pkg/operatorstatus"
 )
 
@@ -61,68 +62,72 @@ type UserDataSecretController struct {
 	Scheme *runtime.Scheme
 }
 
-// Reconcile reconciles the user data secret.
+// Reconcile reconciles the user data secrets of the worker pool and of the additional MachineConfigPools.
+// The user data secrets of MachineConfigPools which are no longer selected are left in place, as MachineSets may still use them.
 func (r *UserDataSecretController) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := ctrl.LoggerFrom(ctx).WithName(controllerName)
-	log.Info("reconciling worker user data secret")
+	log.Info("reconciling user data secrets")
 
-	defaultSourceSecretObjectKey := client.ObjectKey{
-		Name: managedUserDataSecretName, Namespace: SecretSourceNamespace,
-	}
-	sourceSecret := &corev1.Secret{}
-
-	if err := r.Get(ctx, defaultSourceSecretObjectKey, sourceSecret); err != nil {
-		log.Error(err, "unable to get source secret for sync")
-
-		if err := r.setDegradedCondition(ctx, log); err != nil {
-			return ctrl.Result{}, fmt.Errorf("failed to set conditions for secret sync controller: %w", err)
+	pools, err := r.userDataPools(ctx)
+	if err != nil {
+		if err := r.setDegradedCondition(ctx, log, err); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to set conditions for user data secret controller: %w", err)
 		}
 
-		return ctrl.Result{}, fmt.Errorf("failed to get source secret: %w", err)
+		return ctrl.Result{}, fmt.Errorf("failed to get user data pools: %w", err)
 	}
 
-	targetSecret := &corev1.Secret{}
-	targetSecretKey := client.ObjectKey{
-		Namespace: r.ManagedNamespace,
-		Name:      managedUserDataSecretName,
-	}
+	// A pool failing to sync does not hold the others back.
+	var errs error
 
-	// If the secret does not exist, it will be created later, so we can ignore a Not Found error
-	if err := r.Get(ctx, targetSecretKey, targetSecret); err != nil && !apierrors.IsNotFound(err) {
-		log.Error(err, "unable to get target secret for sync")
+	for _, pool := range pools {
+		if err := r.syncUserDataSecret(ctx, log, pool); err != nil {
+			log.Error(err, "unable to sync user data secret", "pool", pool)
 
-		if err := r.setDegradedCondition(ctx, log); err != nil {
-			return ctrl.Result{}, fmt.Errorf("failed to set conditions for secret controller: %w", err)
+			errs = errors.Join(errs, err)
 		}
-
-		return ctrl.Result{}, fmt.Errorf("failed to get target secret: %w", err)
 	}
 
-	if r.areSecretsEqual(sourceSecret, targetSecret) {
-		log.Info("user data in source and target secrets is the same, no sync needed")
-
-		if err := r.setAvailableCondition(ctx, log); err != nil {
+	if errs != nil {
+		if err := r.setDegradedCondition(ctx, log, errs); err != nil {
 			return ctrl.Result{}, fmt.Errorf("failed to set conditions for user data secret controller: %w", err)
 		}
 
-		return ctrl.Result{}, nil
+		return ctrl.Result{}, errs
 	}
 
-	if err := r.syncSecretData(ctx, sourceSecret, targetSecret); err != nil {
-		log.Error(err, "unable to sync user data secret")
+	if err := r.setAvailableCondition(ctx, log); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to set conditions for user data secret controller: %w", err)
+	}
 
-		if err := r.setDegradedCondition(ctx, log); err != nil {
-			return ctrl.Result{}, fmt.Errorf("failed to set conditions for user data secret controller: %w", err)
-		}
+	return ctrl.Result{}, nil
+}
 
-		return ctrl.Result{}, err
+// syncUserDataSecret syncs the user data secret of the MachineConfigPool from the Machine API to the CAPI namespace.
+func (r *UserDataSecretController) syncUserDataSecret(ctx context.Context, log logr.Logger, pool string) error {
+	sourceSecret, err := r.getSourceSecret(ctx, pool)
+	if err != nil {
+		return err
 	}
 
-	if err := r.setAvailableCondition(ctx, log); err != nil {
-		return ctrl.Result{}, fmt.Errorf("failed to set conditions for user data secret controller: %w", err)
+	targetSecret := &corev1.Secret{}
+	targetSecretKey := client.ObjectKey{
+		Namespace: r.ManagedNamespace,
+		Name:      sourceSecret.Name,
 	}
 
-	return ctrl.Result{}, nil
+	// If the secret does not exist, it will be created later, so we can ignore a Not Found error
+	if err := r.Get(ctx, targetSecretKey, targetSecret); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("unable to get target secret %s: %w", targetSecretKey, err)
+	}
+
+	if r.areSecretsEqual(sourceSecret, targetSecret) {
+		log.V(2).Info("user data in source and target secrets is the same, no sync needed", "pool", pool)
+
+		return nil
+	}
+
+	return r.syncSecretData(ctx, sourceSecret, targetSecret)
 }
 
 func (r *UserDataSecretController) areSecretsEqual(source *corev1.Secret, target *corev1.Secret) bool {
@@ -137,7 +142,7 @@ func (r *UserDataSecretController) syncSecretData(ctx context.Context, source *c
 		return errSourceSecretMissingUserData
 	}
 
-	target.SetName(managedUserDataSecretName)
+	target.SetName(source.Name)
 	target.SetNamespace(r.ManagedNamespace)
 	target.Data = map[string][]byte{
 		"value":  userData,
@@ -179,6 +184,16 @@ func (r *UserDataSecretController) SetupWithManager(mgr ctrl.Manager) error {
 			handler.EnqueueRequestsFromMapFunc(toUserDataSecret),
 			builder.WithPredicates(userDataSecretPredicate(SecretSourceNamespace)),
 		).
+		Watches(
+			operatorconfig.New(),
+			handler.EnqueueRequestsFromMapFunc(toUserDataSecret),
+			builder.WithPredicates(operatorconfig.Predicate()),
+		).
+		Watches(
+			newMachineConfigPool(),
+			handler.EnqueueRequestsFromMapFunc(toUserDataSecret),
+			builder.WithPredicates(userDataPoolPredicate()),
+		).
 		Complete(r); err != nil {
 		return fmt.Errorf("failed to create controller: %w", err)
 	}
@@ -199,7 +214,7 @@ func (r *UserDataSecretController) setAvailableCondition(ctx context.Context, lo
 			"User Data Secret Controller works as expected"),
 	}
 
-	co.Status.Versions = []configv1.OperandVersion{{Name: controllers.OperatorVersionKey, Version: r.ReleaseVersion}}
+	r.SetOperatorVersion(co)
 
 	log.Info("user Data Secret Controller is available")
 
@@ -210,7 +225,9 @@ func (r *UserDataSecretController) setAvailableCondition(ctx context.Context, lo
 	return nil
 }
 
-func (r *UserDataSecretController) setDegradedCondition(ctx context.Context, log logr.Logger) error {
+func (r *UserDataSecretController) setDegradedCondition(ctx context.Context, log logr.Logger, syncErr error) error {
+	metrics.RecordReconcileError(controllerName, metrics.ErrorReason(syncErr))
+
 	co, err := r.GetOrCreateClusterOperator(ctx)
 	if err != nil {
 		return fmt.Errorf("unable to get cluster operator: %w", err)
@@ -220,10 +237,10 @@ func (r *UserDataSecretController) setDegradedCondition(ctx context.Context, log
 		operatorstatus.NewClusterOperatorStatusCondition(secretSyncControllerAvailableCondition, configv1.ConditionFalse, operatorstatus.ReasonSyncFailed,
 			"User Data Secret Controller failed to sync secret"),
 		operatorstatus.NewClusterOperatorStatusCondition(secretSyncControllerDegradedCondition, configv1.ConditionTrue, operatorstatus.ReasonSyncFailed,
-			"User Data Secret Controller failed to sync secret"),
+			fmt.Sprintf("User Data Secret Controller failed to sync secret: %v", syncErr)),
 	}
 
-	co.Status.Versions = []configv1.OperandVersion{{Name: controllers.OperatorVersionKey, Version: r.ReleaseVersion}}
+	r.SetOperatorVersion(co)
 
 	log.Info("user Data Secret Controller is degraded")
 
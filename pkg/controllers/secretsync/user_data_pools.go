@@ -0,0 +1,169 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package secretsync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/openshift/cluster-capi-operator/pkg/operatorconfig"
+)
+
+const (
+	// workerPool is the MachineConfigPool whose user data secret is always synced.
+	workerPool = "worker"
+
+	// userDataSecretSuffix is the suffix of the user data secrets, named after their MachineConfigPool.
+	userDataSecretSuffix = "-user-data"
+
+	// userDataPoolLabel selects the MachineConfigPools whose user data secrets are synced, besides the ones of the operator config.
+	userDataPoolLabel = "cluster-capi-operator.openshift.io/user-data"
+)
+
+var (
+	errSourceUserDataNotRewritable = errors.New("source user data does not merge a Machine Config Server config")
+
+	// machineConfigPoolGroupVersionKind is the GroupVersionKind of the MachineConfigPool, read as unstructured.
+	//
+	//nolint:gochecknoglobals
+	machineConfigPoolGroupVersionKind = schema.GroupVersionKind{Group: "machineconfiguration.openshift.io", Version: "v1", Kind: "MachineConfigPool"}
+)
+
+// userDataSecretName returns the name of the user data secret of the MachineConfigPool.
+func userDataSecretName(pool string) string {
+	return pool + userDataSecretSuffix
+}
+
+// newMachineConfigPool returns an empty MachineConfigPool, e.g. to watch MachineConfigPools.
+func newMachineConfigPool() *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(machineConfigPoolGroupVersionKind)
+
+	return u
+}
+
+// userDataPools returns the sorted MachineConfigPools whose user data secrets are synced:
+// the worker pool, the pools of the operator config and the labelled MachineConfigPools.
+func (r *UserDataSecretController) userDataPools(ctx context.Context) ([]string, error) {
+	spec, err := operatorconfig.GetSpec(ctx, r.Client)
+	if err != nil {
+		return nil, err
+	}
+
+	pools := append([]string{workerPool}, spec.UserDataPools...)
+
+	machineConfigPools := &unstructured.UnstructuredList{}
+	machineConfigPools.SetGroupVersionKind(machineConfigPoolGroupVersionKind.GroupVersion().WithKind(machineConfigPoolGroupVersionKind.Kind + "List"))
+
+	// The MachineConfigPool API is missing when the MachineConfiguration capability is disabled.
+	if err := r.List(ctx, machineConfigPools, client.MatchingLabels{userDataPoolLabel: "true"}); err != nil && !meta.IsNoMatchError(err) {
+		return nil, fmt.Errorf("unable to list MachineConfigPools: %w", err)
+	}
+
+	for _, pool := range machineConfigPools.Items {
+		pools = append(pools, pool.GetName())
+	}
+
+	slices.Sort(pools)
+
+	return slices.Compact(pools), nil
+}
+
+// getSourceSecret returns the user data secret of the MachineConfigPool in the Machine API namespace.
+// When it does not exist, it is generated from the worker user data secret, except for the worker pool.
+func (r *UserDataSecretController) getSourceSecret(ctx context.Context, pool string) (*corev1.Secret, error) {
+	sourceSecret := &corev1.Secret{}
+
+	key := client.ObjectKey{Name: userDataSecretName(pool), Namespace: SecretSourceNamespace}
+	if err := r.Get(ctx, key, sourceSecret); err == nil {
+		return sourceSecret, nil
+	} else if !apierrors.IsNotFound(err) || pool == workerPool {
+		return nil, fmt.Errorf("unable to get source secret %s: %w", key, err)
+	}
+
+	workerSecret, err := r.getSourceSecret(ctx, workerPool)
+	if err != nil {
+		return nil, err
+	}
+
+	return generateUserDataSecret(workerSecret, pool)
+}
+
+// generateUserDataSecret returns the user data secret of the MachineConfigPool, generated from the worker user data secret.
+// The user data of the MachineConfigPools are ignition stubs merging the config the Machine Config Server serves
+// for their pool, so the worker user data is copied with the merged config of the worker pool replaced by the one of the pool.
+func generateUserDataSecret(workerSecret *corev1.Secret, pool string) (*corev1.Secret, error) {
+	userData := workerSecret.Data[mapiUserDataKey]
+	if userData == nil {
+		return nil, errSourceSecretMissingUserData
+	}
+
+	ignition := map[string]interface{}{}
+	if err := json.Unmarshal(userData, &ignition); err != nil {
+		return nil, fmt.Errorf("unable to parse source user data: %w", err)
+	}
+
+	merge, _, err := unstructured.NestedSlice(ignition, "ignition", "config", "merge")
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse source user data: %w", err)
+	}
+
+	rewritten := false
+
+	for _, m := range merge {
+		config, ok := m.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		if source, ok := config["source"].(string); ok && strings.HasSuffix(source, "/config/"+workerPool) {
+			config["source"] = strings.TrimSuffix(source, workerPool) + pool
+			rewritten = true
+		}
+	}
+
+	if !rewritten {
+		return nil, errSourceUserDataNotRewritable
+	}
+
+	if err := unstructured.SetNestedSlice(ignition, merge, "ignition", "config", "merge"); err != nil {
+		return nil, fmt.Errorf("unable to generate user data: %w", err)
+	}
+
+	poolUserData, err := json.Marshal(ignition)
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate user data: %w", err)
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: userDataSecretName(pool), Namespace: SecretSourceNamespace},
+		Immutable:  workerSecret.Immutable,
+		Type:       workerSecret.Type,
+		Data:       map[string][]byte{mapiUserDataKey: poolUserData},
+	}, nil
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package secretsync
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("generateUserDataSecret", func() {
+	newWorkerSecret := func(userData string) *corev1.Secret {
+		return &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: managedUserDataSecretName, Namespace: SecretSourceNamespace, ResourceVersion: "1"},
+			Type:       corev1.SecretTypeOpaque,
+			Data:       map[string][]byte{mapiUserDataKey: []byte(userData), "disableTemplating": []byte("true")},
+		}
+	}
+
+	It("should merge the config of the pool instead of the worker config", func() {
+		secret, err := generateUserDataSecret(newWorkerSecret(
+			`{"ignition":{"config":{"merge":[{"source":"https://api-int.example.com:22623/config/worker"}]},"version":"3.2.0"}}`,
+		), "infra")
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(secret.Name).To(Equal("infra-user-data"))
+		Expect(secret.Namespace).To(Equal(SecretSourceNamespace))
+		Expect(secret.ResourceVersion).To(BeEmpty())
+		Expect(secret.Type).To(Equal(corev1.SecretTypeOpaque))
+		Expect(secret.Data).To(HaveLen(1))
+		Expect(secret.Data[mapiUserDataKey]).To(MatchJSON(
+			`{"ignition":{"config":{"merge":[{"source":"https://api-int.example.com:22623/config/infra"}]},"version":"3.2.0"}}`,
+		))
+	})
+
+	It("should fail when the worker user data does not merge the worker config", func() {
+		_, err := generateUserDataSecret(newWorkerSecret(`{"ignition":{"version":"3.2.0"}}`), "infra")
+		Expect(err).To(MatchError(errSourceUserDataNotRewritable))
+	})
+
+	It("should fail without worker user data", func() {
+		_, err := generateUserDataSecret(&corev1.Secret{}, "infra")
+		Expect(err).To(MatchError(errSourceSecretMissingUserData))
+	})
+})
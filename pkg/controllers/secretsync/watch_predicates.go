@@ -17,6 +17,7 @@ package secretsync
 
 import (
 	"context"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -26,16 +27,18 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
+// toUserDataSecret maps any change to a request for the worker user data secret, as all the user data secrets are synced together.
 func toUserDataSecret(ctx context.Context, obj client.Object) []reconcile.Request {
 	return []reconcile.Request{{
 		NamespacedName: client.ObjectKey{Name: managedUserDataSecretName, Namespace: SecretSourceNamespace},
 	}}
 }
 
+// userDataSecretPredicate defines a predicate function for the user data secrets of the MachineConfigPools in the namespace.
 func userDataSecretPredicate(targetNamespace string) predicate.Funcs {
 	isOwnedUserDataSecret := func(obj runtime.Object) bool {
 		secret, ok := obj.(*corev1.Secret)
-		return ok && secret.GetNamespace() == targetNamespace && secret.GetName() == managedUserDataSecretName
+		return ok && secret.GetNamespace() == targetNamespace && strings.HasSuffix(secret.GetName(), userDataSecretSuffix)
 	}
 
 	return predicate.Funcs{
@@ -45,3 +48,19 @@ func userDataSecretPredicate(targetNamespace string) predicate.Funcs {
 		GenericFunc: func(e event.GenericEvent) bool { return isOwnedUserDataSecret(e.Object) },
 	}
 }
+
+// userDataPoolPredicate defines a predicate function for the MachineConfigPools whose user data secrets are synced,
+// including the ones which were just unlabelled.
+func userDataPoolPredicate() predicate.Funcs {
+	isUserDataPool := func(obj client.Object) bool {
+		_, ok := obj.GetLabels()[userDataPoolLabel]
+		return ok
+	}
+
+	return predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return isUserDataPool(e.Object) },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return isUserDataPool(e.ObjectOld) || isUserDataPool(e.ObjectNew) },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return isUserDataPool(e.Object) },
+		GenericFunc: func(e event.GenericEvent) bool { return isUserDataPool(e.Object) },
+	}
+}
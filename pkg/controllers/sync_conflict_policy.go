@@ -0,0 +1,64 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package controllers
+
+import (
+	"errors"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SyncConflictPolicy controls how the sync controllers reconcile a non-authoritative resource
+// that has diverged from its authoritative counterpart.
+type SyncConflictPolicy string
+
+const (
+	// SyncConflictPolicyAnnotation is set on Machine API resources to choose the SyncConflictPolicy
+	// used when synchronizing them. When the annotation is not set, SyncConflictPolicyAuthoritativeWins is used.
+	SyncConflictPolicyAnnotation = "machine.openshift.io/sync-conflict-policy"
+
+	// SyncConflictPolicyAuthoritativeWins overwrites the non-authoritative resource with the authoritative one.
+	SyncConflictPolicyAuthoritativeWins SyncConflictPolicy = "authoritative-wins"
+
+	// SyncConflictPolicyFailAndReport leaves a non-authoritative resource whose spec was changed since the last
+	// synchronization untouched, and reports the conflict on the Synchronized condition until it is resolved.
+	SyncConflictPolicyFailAndReport SyncConflictPolicy = "fail-and-report"
+
+	// SyncConflictPolicyMergeNonConflicting keeps the labels and annotations that were only added to the
+	// non-authoritative resource. Everything else, including conflicting keys, is taken from the authoritative resource.
+	SyncConflictPolicyMergeNonConflicting SyncConflictPolicy = "merge-nonconflicting"
+)
+
+// errUnknownSyncConflictPolicy is returned when the sync conflict policy annotation has an unknown value.
+var errUnknownSyncConflictPolicy = errors.New("unknown sync conflict policy")
+
+// GetSyncConflictPolicy returns the SyncConflictPolicy set on the given resource,
+// defaulting to SyncConflictPolicyAuthoritativeWins when none is set.
+func GetSyncConflictPolicy(obj metav1.Object) (SyncConflictPolicy, error) {
+	value, ok := obj.GetAnnotations()[SyncConflictPolicyAnnotation]
+	if !ok || value == "" {
+		return SyncConflictPolicyAuthoritativeWins, nil
+	}
+
+	switch policy := SyncConflictPolicy(value); policy {
+	case SyncConflictPolicyAuthoritativeWins, SyncConflictPolicyFailAndReport, SyncConflictPolicyMergeNonConflicting:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("%w %q in annotation %s, must be one of %q, %q or %q", errUnknownSyncConflictPolicy, value, SyncConflictPolicyAnnotation,
+			SyncConflictPolicyAuthoritativeWins, SyncConflictPolicyFailAndReport, SyncConflictPolicyMergeNonConflicting)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	mapiv1 "github.com/openshift/api/machine/v1beta1"
@@ -39,6 +40,9 @@ var (
 )
 
 const (
+	// capacityReservationIDPrefix is the prefix of all EC2 On-Demand Capacity Reservation IDs.
+	capacityReservationIDPrefix = "cr-"
+
 	errUnsupportedCAPATenancy     = "unable to convert tenancy, unknown value"
 	errUnsupportedHTTPTokensState = "unable to convert httpTokens state, unknown value" //nolint:gosec // This is an error message, not a credential
 )
@@ -101,6 +105,11 @@ func (m machineAndAWSMachineAndAWSCluster) toProviderSpec() (*mapiv1.AWSMachineP
 		errors = append(errors, err)
 	}
 
+	mapiPlacementGroupPartition, err := convertAWSPlacementGroupPartitionToMAPI(fldPath.Child("placementGroupPartition"), m.awsMachine.Spec.PlacementGroupName, m.awsMachine.Spec.PlacementGroupPartition)
+	if err != nil {
+		errors = append(errors, err)
+	}
+
 	mapiAWSMetadataOptions, warn, errs := convertAWSMetadataOptionsToMAPI(fldPath.Child("instanceMetadataOptions"), m.awsMachine.Spec.InstanceMetadataOptions)
 	if errs != nil {
 		errors = append(errors, errs...)
@@ -108,6 +117,9 @@ func (m machineAndAWSMachineAndAWSCluster) toProviderSpec() (*mapiv1.AWSMachineP
 
 	warnings = append(warnings, warn...)
 
+	mapiSecurityGroups, errs := convertAWSSecurityGroupstoMAPI(fldPath.Child("additionalSecurityGroups"), m.awsMachine.Spec.AdditionalSecurityGroups)
+	errors = append(errors, errs...)
+
 	mapaProviderConfig := mapiv1.AWSMachineProviderConfig{
 		TypeMeta: metav1.TypeMeta{
 			Kind: "AWSMachineProviderConfig",
@@ -132,8 +144,8 @@ func (m machineAndAWSMachineAndAWSCluster) toProviderSpec() (*mapiv1.AWSMachineP
 		KeyName: m.awsMachine.Spec.SSHKeyName,
 		// DeviceIndex - OCPCLOUD-2707: Value must always be zero. No other values are valid in MAPA even though the value is configurable.
 		PublicIP:             m.awsMachine.Spec.PublicIP,
-		NetworkInterfaceType: mapiv1.AWSENANetworkInterfaceType,                                          // TODO(OCPCLOUD-2708) This is the default value for MAPA, but other values are not configurable in CAPA.
-		SecurityGroups:       convertAWSSecurityGroupstoMAPI(m.awsMachine.Spec.AdditionalSecurityGroups), // OCPCLOUD-2712: We need to ensure that this is the correct way to convert the security groups.
+		NetworkInterfaceType: mapiv1.AWSENANetworkInterfaceType, // TODO(OCPCLOUD-2708) This is the default value for MAPA, but other values are not configurable in CAPA.
+		SecurityGroups:       mapiSecurityGroups,                // OCPCLOUD-2712: We need to ensure that this is the correct way to convert the security groups.
 		Subnet:               convertAWSResourceReferenceToMAPI(ptr.Deref(m.awsMachine.Spec.Subnet, capav1.AWSResourceReference{})),
 		Placement: mapiv1.Placement{
 			AvailabilityZone: ptr.Deref(m.machine.Spec.FailureDomain, ""),
@@ -145,7 +157,7 @@ func (m machineAndAWSMachineAndAWSCluster) toProviderSpec() (*mapiv1.AWSMachineP
 		SpotMarketOptions:       convertAWSSpotMarketOptionsToMAPI(m.awsMachine.Spec.SpotMarketOptions),
 		MetadataServiceOptions:  mapiAWSMetadataOptions,
 		PlacementGroupName:      m.awsMachine.Spec.PlacementGroupName,
-		PlacementGroupPartition: convertAWSPlacementGroupPartition(m.awsMachine.Spec.PlacementGroupPartition),
+		PlacementGroupPartition: mapiPlacementGroupPartition,
 		CapacityReservationID:   ptr.Deref(m.awsMachine.Spec.CapacityReservationID, ""),
 	}
 
@@ -162,6 +174,11 @@ func (m machineAndAWSMachineAndAWSCluster) toProviderSpec() (*mapiv1.AWSMachineP
 	// IntsanceID - Ignore - Is a subset of providerID.
 	// Ignition - Ignore - Only has a version field and we force this to a particular value.
 
+	if capacityReservationID := ptr.Deref(m.awsMachine.Spec.CapacityReservationID, ""); capacityReservationID != "" && !strings.HasPrefix(capacityReservationID, capacityReservationIDPrefix) {
+		// MAPI only accepts EC2 On-Demand Capacity Reservation IDs.
+		errors = append(errors, field.Invalid(fldPath.Child("capacityReservationId"), capacityReservationID, fmt.Sprintf("capacityReservationId must start with %q", capacityReservationIDPrefix)))
+	}
+
 	// There are quite a few unsupported fields, so break them out for now.
 	errors = append(errors, handleUnsupportedAWSMachineFields(fldPath, m.awsMachine.Spec)...)
 
@@ -202,6 +219,8 @@ func (m machineAndAWSMachineAndAWSCluster) ToMachine() (*mapiv1.Machine, []strin
 
 	mapiMachine.Spec.ProviderSpec.Value = awsRawExt
 
+	errors = append(errors, restorePreservedProviderSpecFields(mapiMachine)...)
+
 	if len(errors) > 0 {
 		return nil, warnings, errors.ToAggregate()
 	}
@@ -234,16 +253,16 @@ func (m machineSetAndAWSMachineTemplateAndAWSCluster) ToMachineSet() (*mapiv1.Ma
 		errors = append(errors, err)
 	}
 
+	if len(errors) > 0 {
+		return nil, warnings, utilerrors.NewAggregate(errors)
+	}
+
 	mapiMachineSet.Spec.Template.Spec = mapaMachine.Spec
 
 	// Copy the labels and annotations from the Machine to the template.
 	mapiMachineSet.Spec.Template.ObjectMeta.Annotations = mapaMachine.ObjectMeta.Annotations
 	mapiMachineSet.Spec.Template.ObjectMeta.Labels = mapaMachine.ObjectMeta.Labels
 
-	if len(errors) > 0 {
-		return nil, warnings, utilerrors.NewAggregate(errors)
-	}
-
 	return mapiMachineSet, warnings, nil
 }
 
@@ -340,26 +359,42 @@ func convertAWSFiltersToMAPI(capiFilters []capav1.Filter) []mapiv1.Filter {
 
 func convertAWSTagsToMAPI(capiTags capav1.Tags) []mapiv1.TagSpecification {
 	mapiTags := []mapiv1.TagSpecification{}
-	for key, value := range capiTags {
+
+	keys := make([]string, 0, len(capiTags))
+	for key := range capiTags {
+		keys = append(keys, key)
+	}
+
+	// Sort the keys so that repeated conversions of the same tags produce the same list.
+	sort.Strings(keys)
+
+	for _, key := range keys {
 		mapiTags = append(mapiTags, mapiv1.TagSpecification{
 			Name:  key,
-			Value: value,
+			Value: capiTags[key],
 		})
 	}
 
 	return mapiTags
 }
 
-func convertAWSSecurityGroupstoMAPI(sgs []capav1.AWSResourceReference) []mapiv1.AWSResourceReference {
+func convertAWSSecurityGroupstoMAPI(fldPath *field.Path, sgs []capav1.AWSResourceReference) ([]mapiv1.AWSResourceReference, field.ErrorList) {
 	mapiSGs := []mapiv1.AWSResourceReference{}
+	errs := field.ErrorList{}
+
+	for i, sg := range sgs {
+		if sg.ID == nil && len(sg.Filters) == 0 {
+			// MAPA silently skips references it cannot resolve, so reject them rather than drop a security group.
+			errs = append(errs, field.Required(fldPath.Index(i), "security group reference must specify an id or filters"))
+			continue
+		}
 
-	for _, sg := range sgs {
 		mapiAWSResourceRef := convertAWSResourceReferenceToMAPI(sg)
 
 		mapiSGs = append(mapiSGs, mapiAWSResourceRef)
 	}
 
-	return mapiSGs
+	return mapiSGs, errs
 }
 
 func convertAWSSpotMarketOptionsToMAPI(capiSpotMarketOptions *capav1.SpotMarketOptions) *mapiv1.SpotMarketOptions {
@@ -438,13 +473,23 @@ func convertKMSKeyToMAPI(kmsKey string) mapiv1.AWSResourceReference {
 	}
 }
 
-func convertAWSPlacementGroupPartition(in int64) *int32 {
+func convertAWSPlacementGroupPartitionToMAPI(fldPath *field.Path, placementGroupName string, in int64) (*int32, *field.Error) {
 	if in == 0 {
-		return nil
+		return nil, nil
+	}
+
+	if in < 1 || in > 7 {
+		return nil, field.Invalid(fldPath, in, "placementGroupPartition must be between 1 and 7")
 	}
-	// We know the value is between 0 and 7 based on API validation. Ignore gosec.
+
+	if placementGroupName == "" {
+		// The partition is only meaningful within a partition placement group, MAPA rejects it otherwise.
+		return nil, field.Invalid(fldPath, in, "placementGroupPartition is only valid when placementGroupName is set")
+	}
+
+	// We know the value is between 1 and 7 based on the validation above. Ignore gosec.
 	//nolint:gosec
-	return ptr.To(int32(in))
+	return ptr.To(int32(in)), nil
 }
 
 // handleUnsupportedAWSMachineFields returns an error for every present field in the AWSMachineSpec that
@@ -498,6 +543,17 @@ func handleUnsupportedAWSMachineFields(fldPath *field.Path, spec capav1.AWSMachi
 		errs = append(errs, field.Invalid(fldPath.Child("privateDNSName"), spec.PrivateDNSName, "privateDNSName is not supported"))
 	}
 
+	if spec.RootVolume != nil && spec.RootVolume.Throughput != nil {
+		// MAPI has no throughput field on EBS block devices, so the value would be lost.
+		errs = append(errs, field.Invalid(fldPath.Child("rootVolume", "throughput"), *spec.RootVolume.Throughput, "throughput is not supported"))
+	}
+
+	for i, volume := range spec.NonRootVolumes {
+		if volume.Throughput != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("nonRootVolumes").Index(i).Child("throughput"), *volume.Throughput, "throughput is not supported"))
+		}
+	}
+
 	if spec.Ignition != nil {
 		if spec.Ignition.Proxy != nil {
 			// TODO(OCPCLOUD-2711): Ignition proxy is not configurable in MAPI. Not required for our use case, add VAP to prevent usage.
@@ -16,6 +16,7 @@ limitations under the License.
 package capi2mapi_test
 
 import (
+	"fmt"
 	"strings"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -29,6 +30,7 @@ import (
 	conversiontest "github.com/openshift/cluster-capi-operator/pkg/conversion/test/fuzz"
 
 	runtimeserializer "k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/utils/ptr"
 
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -120,7 +122,7 @@ func awsMachineFuzzerFuncs(codecs runtimeserializer.CodecFactory) []interface{}
 
 			// TODO(OCPCLOUD-2710): Fields not yet supported by MAPI.
 			imdo.HTTPEndpoint = capav1.InstanceMetadataEndpointStateEnabled
-			imdo.HTTPPutResponseHopLimit = 0
+			imdo.HTTPPutResponseHopLimit = 1
 			imdo.InstanceMetadataTags = capav1.InstanceMetadataEndpointStateDisabled
 		},
 		func(tokenState *capav1.HTTPTokensState, c fuzz.Continue) {
@@ -149,11 +151,36 @@ func awsMachineFuzzerFuncs(codecs runtimeserializer.CodecFactory) []interface{}
 				StorageType: capav1.IgnitionStorageTypeOptionUnencryptedUserData,
 			}
 		},
+		func(smo *capav1.SpotMarketOptions, c fuzz.Continue) {
+			// MaxPrice must be a decimal number to be accepted by the conversion back to CAPI.
+			switch c.Intn(3) {
+			case 0:
+				smo.MaxPrice = nil
+			case 1:
+				smo.MaxPrice = ptr.To("")
+			case 2:
+				smo.MaxPrice = ptr.To(fmt.Sprintf("%d.%d", c.Intn(100), c.Intn(10000)))
+			}
+		},
 		func(spec *capav1.AWSMachineSpec, c fuzz.Continue) {
 			c.FuzzNoCustom(spec)
 
 			fuzzAWSMachineSpecTenancy(&spec.Tenancy, c)
 
+			// The capacity reservation ID must be a valid reservation ID when set.
+			if spec.CapacityReservationID != nil {
+				spec.CapacityReservationID = ptr.To("cr-" + c.RandString())
+			}
+
+			// The placement group partition must be in range and paired with a placement group name.
+			if spec.PlacementGroupPartition != 0 {
+				spec.PlacementGroupPartition = c.Int63n(7) + 1
+
+				if spec.PlacementGroupName == "" {
+					spec.PlacementGroupName = "placement-group"
+				}
+			}
+
 			// Fields not required for our use case can be ignored.
 			spec.ImageLookupFormat = ""
 			spec.ImageLookupOrg = ""
@@ -166,6 +193,22 @@ func awsMachineFuzzerFuncs(codecs runtimeserializer.CodecFactory) []interface{}
 			// Fields not yet supported for conversion.
 			// TODO(OCPCLOUD-2712): Security group overrides still need investigation.
 			spec.SecurityGroupOverrides = nil
+
+			// Security group references must identify a security group.
+			for i := range spec.AdditionalSecurityGroups {
+				if spec.AdditionalSecurityGroups[i].ID == nil && len(spec.AdditionalSecurityGroups[i].Filters) == 0 {
+					spec.AdditionalSecurityGroups[i].ID = ptr.To(c.RandString())
+				}
+			}
+
+			// Throughput has no MAPI equivalent.
+			if spec.RootVolume != nil {
+				spec.RootVolume.Throughput = nil
+			}
+
+			for i := range spec.NonRootVolumes {
+				spec.NonRootVolumes[i].Throughput = nil
+			}
 		},
 		func(m *capav1.AWSMachine, c fuzz.Continue) {
 			c.FuzzNoCustom(m)
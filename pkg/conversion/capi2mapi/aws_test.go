@@ -80,6 +80,93 @@ var _ = Describe("capi2mapi AWS conversion", func() {
 			expectedWarnings: []string{},
 		}),
 
+		Entry("With placement group name and partition", awsCAPI2MAPIMachineConversionInput{
+			awsClusterBuilder: awsCAPIAWSClusterBase,
+			awsMachineBuilder: awsCAPIAWSMachineBase.WithPlacementGroupName("placement-group").WithPlacementGroupPartition(3),
+			machineBuilder:    awsCAPIMachineBase,
+			expectedErrors:    []string{},
+			expectedWarnings:  []string{},
+		}),
+
+		Entry("With placement group partition out of range", awsCAPI2MAPIMachineConversionInput{
+			awsClusterBuilder: awsCAPIAWSClusterBase,
+			awsMachineBuilder: awsCAPIAWSMachineBase.WithPlacementGroupName("placement-group").WithPlacementGroupPartition(9),
+			machineBuilder:    awsCAPIMachineBase,
+			expectedErrors:    []string{"spec.placementGroupPartition: Invalid value: 9: placementGroupPartition must be between 1 and 7"},
+			expectedWarnings:  []string{},
+		}),
+
+		Entry("With placement group partition and no placement group name", awsCAPI2MAPIMachineConversionInput{
+			awsClusterBuilder: awsCAPIAWSClusterBase,
+			awsMachineBuilder: awsCAPIAWSMachineBase.WithPlacementGroupPartition(2),
+			machineBuilder:    awsCAPIMachineBase,
+			expectedErrors:    []string{"spec.placementGroupPartition: Invalid value: 2: placementGroupPartition is only valid when placementGroupName is set"},
+			expectedWarnings:  []string{},
+		}),
+
+		Entry("With capacity reservation ID", awsCAPI2MAPIMachineConversionInput{
+			awsClusterBuilder: awsCAPIAWSClusterBase,
+			awsMachineBuilder: awsCAPIAWSMachineBase.WithCapacityReservationID(ptr.To("cr-0123456789abcdef0")),
+			machineBuilder:    awsCAPIMachineBase,
+			expectedErrors:    []string{},
+			expectedWarnings:  []string{},
+		}),
+
+		Entry("With invalid capacity reservation ID", awsCAPI2MAPIMachineConversionInput{
+			awsClusterBuilder: awsCAPIAWSClusterBase,
+			awsMachineBuilder: awsCAPIAWSMachineBase.WithCapacityReservationID(ptr.To("reservation-1234")),
+			machineBuilder:    awsCAPIMachineBase,
+			expectedErrors:    []string{"spec.capacityReservationId: Invalid value: \"reservation-1234\": capacityReservationId must start with \"cr-\""},
+			expectedWarnings:  []string{},
+		}),
+
+		Entry("With root and non-root volumes", awsCAPI2MAPIMachineConversionInput{
+			awsClusterBuilder: awsCAPIAWSClusterBase,
+			awsMachineBuilder: awsCAPIAWSMachineBase.
+				WithRootVolume(&capav1.Volume{Size: 120, Type: capav1.VolumeTypeGP3}).
+				WithNonRootVolumes([]capav1.Volume{
+					{DeviceName: "/dev/sdb", Size: 50, Type: capav1.VolumeTypeIO1, IOPS: 3000},
+					{DeviceName: "/dev/sdc", Size: 100, Encrypted: ptr.To(true), EncryptionKey: "kms-key"},
+				}),
+			machineBuilder:   awsCAPIMachineBase,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With unsupported volume throughput", awsCAPI2MAPIMachineConversionInput{
+			awsClusterBuilder: awsCAPIAWSClusterBase,
+			awsMachineBuilder: awsCAPIAWSMachineBase.
+				WithRootVolume(&capav1.Volume{Size: 120, Type: capav1.VolumeTypeGP3, Throughput: ptr.To(int64(250))}).
+				WithNonRootVolumes([]capav1.Volume{
+					{DeviceName: "/dev/sdb", Size: 50, Type: capav1.VolumeTypeGP3, Throughput: ptr.To(int64(500))},
+				}),
+			machineBuilder: awsCAPIMachineBase,
+			expectedErrors: []string{
+				"spec.rootVolume.throughput: Invalid value: 250: throughput is not supported",
+				"spec.nonRootVolumes[0].throughput: Invalid value: 500: throughput is not supported",
+			},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With security group filters", awsCAPI2MAPIMachineConversionInput{
+			awsClusterBuilder: awsCAPIAWSClusterBase,
+			awsMachineBuilder: awsCAPIAWSMachineBase.WithAdditionalSecurityGroups([]capav1.AWSResourceReference{
+				{ID: ptr.To("sg-1234")},
+				{Filters: []capav1.Filter{{Name: "tag:Name", Values: []string{"cluster-worker-sg"}}}},
+			}),
+			machineBuilder:   awsCAPIMachineBase,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With empty security group reference", awsCAPI2MAPIMachineConversionInput{
+			awsClusterBuilder: awsCAPIAWSClusterBase,
+			awsMachineBuilder: awsCAPIAWSMachineBase.WithAdditionalSecurityGroups([]capav1.AWSResourceReference{{}}),
+			machineBuilder:    awsCAPIMachineBase,
+			expectedErrors:    []string{"spec.additionalSecurityGroups[0]: Required value: security group reference must specify an id or filters"},
+			expectedWarnings:  []string{},
+		}),
+
 		Entry("With unsupported ImageLookupFormat", awsCAPI2MAPIMachineConversionInput{
 			awsClusterBuilder: awsCAPIAWSClusterBase,
 			awsMachineBuilder: awsCAPIAWSMachineBase.WithImageLookupFormat("unsupported"),
@@ -190,6 +277,18 @@ var _ = Describe("capi2mapi AWS conversion", func() {
 			expectedWarnings: []string{},
 		}),
 
+		Entry("With IMDSv2 required and the default httpPutResponseHopLimit", awsCAPI2MAPIMachineConversionInput{
+			awsClusterBuilder: awsCAPIAWSClusterBase,
+			awsMachineBuilder: awsCAPIAWSMachineBase.
+				WithInstanceMetadataOptions(&capav1.InstanceMetadataOptions{
+					HTTPTokens:              capav1.HTTPTokensStateRequired,
+					HTTPPutResponseHopLimit: 1,
+				}),
+			machineBuilder:   awsCAPIMachineBase,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
 		Entry("With unsupported httpTokens", awsCAPI2MAPIMachineConversionInput{
 			awsClusterBuilder: awsCAPIAWSClusterBase,
 			awsMachineBuilder: awsCAPIAWSMachineBase.
@@ -0,0 +1,620 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capi2mapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/ptr"
+	capzv1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	// azureProviderIDPrefix is the prefix CAPZ expects on resource IDs used as provider IDs.
+	azureProviderIDPrefix = "azure://"
+)
+
+var (
+	errCAPIMachineAzureMachineAzureClusterCannotBeNil            = errors.New("provided Machine, AzureMachine and AzureCluster can not be nil")
+	errCAPIMachineSetAzureMachineTemplateAzureClusterCannotBeNil = errors.New("provided MachineSet, AzureMachineTemplate and AzureCluster can not be nil")
+)
+
+// machineAndAzureMachineAndAzureCluster stores the details of a Cluster API Machine and AzureMachine and AzureCluster.
+type machineAndAzureMachineAndAzureCluster struct {
+	machine      *capiv1.Machine
+	azureMachine *capzv1.AzureMachine
+	azureCluster *capzv1.AzureCluster
+}
+
+// machineSetAndAzureMachineTemplateAndAzureCluster stores the details of a Cluster API MachineSet and AzureMachineTemplate and AzureCluster.
+type machineSetAndAzureMachineTemplateAndAzureCluster struct {
+	machineSet   *capiv1.MachineSet
+	template     *capzv1.AzureMachineTemplate
+	azureCluster *capzv1.AzureCluster
+	*machineAndAzureMachineAndAzureCluster
+}
+
+// FromMachineAndAzureMachineAndAzureCluster wraps a CAPI Machine and CAPZ AzureMachine and CAPZ AzureCluster into a capi2mapi MachineAndInfrastructureMachine.
+func FromMachineAndAzureMachineAndAzureCluster(m *capiv1.Machine, am *capzv1.AzureMachine, ac *capzv1.AzureCluster) MachineAndInfrastructureMachine {
+	return &machineAndAzureMachineAndAzureCluster{machine: m, azureMachine: am, azureCluster: ac}
+}
+
+// FromMachineSetAndAzureMachineTemplateAndAzureCluster wraps a CAPI MachineSet and CAPZ AzureMachineTemplate and CAPZ AzureCluster into a capi2mapi MachineSetAndMachineTemplate.
+func FromMachineSetAndAzureMachineTemplateAndAzureCluster(ms *capiv1.MachineSet, mts *capzv1.AzureMachineTemplate, ac *capzv1.AzureCluster) MachineSetAndMachineTemplate {
+	return &machineSetAndAzureMachineTemplateAndAzureCluster{
+		machineSet:   ms,
+		template:     mts,
+		azureCluster: ac,
+		machineAndAzureMachineAndAzureCluster: &machineAndAzureMachineAndAzureCluster{
+			machine: &capiv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      ms.Spec.Template.ObjectMeta.Labels,
+					Annotations: ms.Spec.Template.ObjectMeta.Annotations,
+				},
+				Spec: ms.Spec.Template.Spec,
+			},
+			azureMachine: &capzv1.AzureMachine{
+				Spec: mts.Spec.Template.Spec,
+			},
+			azureCluster: ac,
+		},
+	}
+}
+
+// toProviderSpec converts a capi2mapi MachineAndAzureMachineAndAzureCluster into a MAPI AzureMachineProviderSpec.
+//
+//nolint:funlen
+func (m machineAndAzureMachineAndAzureCluster) toProviderSpec() (*mapiv1.AzureMachineProviderSpec, []string, field.ErrorList) {
+	var (
+		warnings []string
+		errors   field.ErrorList
+	)
+
+	fldPath := field.NewPath("spec")
+
+	mapiImage, err := convertAzureImageToMAPI(fldPath.Child("image"), m.azureMachine.Spec.Image)
+	if err != nil {
+		errors = append(errors, err)
+	}
+
+	mapiOSDisk := convertAzureOSDiskToMAPI(m.azureMachine.Spec.OSDisk)
+
+	mapiDataDisks, errs := convertAzureDataDisksToMAPI(fldPath.Child("dataDisks"), m.azureMachine.Spec.DataDisks)
+	errors = append(errors, errs...)
+
+	mapiSecurityProfile, errs := convertAzureSecurityProfileToMAPI(fldPath.Child("securityProfile"), m.azureMachine.Spec.SecurityProfile)
+	errors = append(errors, errs...)
+
+	mapiDiagnostics, errs := convertAzureDiagnosticsToMAPI(fldPath.Child("diagnostics"), m.azureMachine.Spec.Diagnostics)
+	errors = append(errors, errs...)
+
+	mapiManagedIdentity, errs := convertAzureIdentityToMAPI(fldPath, m.azureMachine.Spec.Identity, m.azureMachine.Spec.UserAssignedIdentities)
+	errors = append(errors, errs...)
+
+	mapiSpotVMOptions, err := convertAzureSpotVMOptionsToMAPI(fldPath.Child("spotVMOptions"), m.azureMachine.Spec.SpotVMOptions)
+	if err != nil {
+		errors = append(errors, err)
+	}
+
+	mapzProviderSpec := mapiv1.AzureMachineProviderSpec{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "AzureMachineProviderSpec",
+			APIVersion: "machine.openshift.io/v1beta1",
+		},
+		// ObjectMeta - Only present because it's needed to form part of the runtime.RawExtension, not actually used by MAPZ.
+		// UserDataSecret - Populated below.
+		// CredentialsSecret - TODO(OCPCLOUD-2713)
+		Location:                   m.azureCluster.Spec.Location,
+		VMSize:                     m.azureMachine.Spec.VMSize,
+		Image:                      mapiImage,
+		OSDisk:                     mapiOSDisk,
+		DataDisks:                  mapiDataDisks,
+		SSHPublicKey:               m.azureMachine.Spec.SSHPublicKey,
+		PublicIP:                   m.azureMachine.Spec.AllocatePublicIP,
+		Tags:                       convertAzureTagsToMAPI(m.azureMachine.Spec.AdditionalTags),
+		SecurityGroup:              getAzureSubnetSecurityGroupName(m.azureCluster, m.azureMachine.Spec.SubnetName),
+		Subnet:                     m.azureMachine.Spec.SubnetName,
+		ManagedIdentity:            mapiManagedIdentity,
+		Vnet:                       m.azureCluster.Spec.NetworkSpec.Vnet.Name,
+		Zone:                       ptr.Deref(m.machine.Spec.FailureDomain, ""),
+		NetworkResourceGroup:       m.azureCluster.Spec.NetworkSpec.Vnet.ResourceGroup,
+		ResourceGroup:              m.azureCluster.Spec.ResourceGroup,
+		SpotVMOptions:              mapiSpotVMOptions,
+		AcceleratedNetworking:      ptr.Deref(m.azureMachine.Spec.AcceleratedNetworking, false),
+		CapacityReservationGroupID: ptr.Deref(m.azureMachine.Spec.CapacityReservationGroupID, ""),
+		UltraSSDCapability:         convertAzureAdditionalCapabilitiesToMAPI(m.azureMachine.Spec.AdditionalCapabilities),
+		SecurityProfile:            mapiSecurityProfile,
+		Diagnostics:                mapiDiagnostics,
+	}
+
+	if m.azureCluster.Spec.NetworkSpec.NodeOutboundLB != nil {
+		mapzProviderSpec.PublicLoadBalancer = m.azureCluster.Spec.NetworkSpec.NodeOutboundLB.Name
+	}
+
+	userDataSecretName := ptr.Deref(m.machine.Spec.Bootstrap.DataSecretName, "")
+	if userDataSecretName != "" {
+		mapzProviderSpec.UserDataSecret = &corev1.SecretReference{
+			Name: userDataSecretName,
+		}
+	}
+
+	// Below this line are fields not used from the CAPI AzureMachine.
+
+	// ProviderID - Populated at a different level.
+
+	// There are quite a few unsupported fields, so break them out for now.
+	errors = append(errors, handleUnsupportedAzureMachineFields(fldPath, m.azureMachine.Spec)...)
+
+	if len(errors) > 0 {
+		return nil, warnings, errors
+	}
+
+	return &mapzProviderSpec, warnings, nil
+}
+
+// ToMachine converts a capi2mapi MachineAndAzureMachineAndAzureCluster into a MAPI Machine.
+func (m machineAndAzureMachineAndAzureCluster) ToMachine() (*mapiv1.Machine, []string, error) {
+	if m.machine == nil || m.azureMachine == nil || m.azureCluster == nil {
+		return nil, nil, errCAPIMachineAzureMachineAzureClusterCannotBeNil
+	}
+
+	var (
+		errors   field.ErrorList
+		warnings []string
+	)
+
+	mapzSpec, warn, err := m.toProviderSpec()
+	if err != nil {
+		errors = append(errors, err...)
+	}
+
+	azureRawExt, errRaw := RawExtensionFromAzureProviderSpec(mapzSpec)
+	if errRaw != nil {
+		return nil, nil, fmt.Errorf("unable to convert Azure providerSpec to raw extension: %w", errRaw)
+	}
+
+	warnings = append(warnings, warn...)
+
+	mapiMachine, err := fromCAPIMachineToMAPIMachine(m.machine)
+	if err != nil {
+		errors = append(errors, err...)
+	}
+
+	mapiMachine.Spec.ProviderSpec.Value = azureRawExt
+
+	errors = append(errors, restorePreservedProviderSpecFields(mapiMachine)...)
+
+	if len(errors) > 0 {
+		return nil, warnings, errors.ToAggregate()
+	}
+
+	return mapiMachine, warnings, nil
+}
+
+// ToMachineSet converts a capi2mapi MachineSetAndAzureMachineTemplateAndAzureCluster into a MAPI MachineSet.
+func (m machineSetAndAzureMachineTemplateAndAzureCluster) ToMachineSet() (*mapiv1.MachineSet, []string, error) {
+	if m.machineSet == nil || m.template == nil || m.azureCluster == nil || m.machineAndAzureMachineAndAzureCluster == nil {
+		return nil, nil, errCAPIMachineSetAzureMachineTemplateAzureClusterCannotBeNil
+	}
+
+	var (
+		errors   []error
+		warnings []string
+	)
+
+	// Run the full ToMachine conversion so that we can check for
+	// any Machine level conversion errors in the spec translation.
+	mapzMachine, warn, err := m.ToMachine()
+	if err != nil {
+		errors = append(errors, err)
+	}
+
+	warnings = append(warnings, warn...)
+
+	mapiMachineSet, err := fromCAPIMachineSetToMAPIMachineSet(m.machineSet)
+	if err != nil {
+		errors = append(errors, err)
+	}
+
+	if len(errors) > 0 {
+		return nil, warnings, utilerrors.NewAggregate(errors)
+	}
+
+	mapiMachineSet.Spec.Template.Spec = mapzMachine.Spec
+
+	// Copy the labels and annotations from the Machine to the template.
+	mapiMachineSet.Spec.Template.ObjectMeta.Annotations = mapzMachine.ObjectMeta.Annotations
+	mapiMachineSet.Spec.Template.ObjectMeta.Labels = mapzMachine.ObjectMeta.Labels
+
+	return mapiMachineSet, warnings, nil
+}
+
+// Conversion helpers.
+
+// RawExtensionFromAzureProviderSpec marshals the Azure machine provider spec.
+func RawExtensionFromAzureProviderSpec(spec *mapiv1.AzureMachineProviderSpec) (*runtime.RawExtension, error) {
+	if spec == nil {
+		return &runtime.RawExtension{}, nil
+	}
+
+	rawBytes, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling providerSpec: %w", err)
+	}
+
+	return &runtime.RawExtension{
+		Raw: rawBytes,
+	}, nil
+}
+
+func convertAzureImageToMAPI(fldPath *field.Path, capzImage *capzv1.Image) (mapiv1.Image, *field.Error) {
+	switch {
+	case capzImage == nil:
+		return mapiv1.Image{}, field.Required(fldPath, "image is required")
+	case capzImage.ID != nil && *capzImage.ID != "":
+		return mapiv1.Image{ResourceID: *capzImage.ID}, nil
+	case capzImage.Marketplace != nil:
+		imageType := mapiv1.AzureImageTypeMarketplaceNoPlan
+		if capzImage.Marketplace.ThirdPartyImage {
+			imageType = mapiv1.AzureImageTypeMarketplaceWithPlan
+		}
+
+		return mapiv1.Image{
+			Publisher: capzImage.Marketplace.Publisher,
+			Offer:     capzImage.Marketplace.Offer,
+			SKU:       capzImage.Marketplace.SKU,
+			Version:   capzImage.Marketplace.Version,
+			Type:      imageType,
+		}, nil
+	case capzImage.ComputeGallery != nil:
+		return convertAzureComputeGalleryImageToMAPI(capzImage.ComputeGallery), nil
+	case capzImage.SharedGallery != nil:
+		// The deprecated shared gallery image is a compute gallery image within the given subscription.
+		sharedGallery := capzImage.SharedGallery
+
+		var plan *capzv1.ImagePlan
+		if sharedGallery.Publisher != nil && sharedGallery.Offer != nil && sharedGallery.SKU != nil {
+			plan = &capzv1.ImagePlan{Publisher: *sharedGallery.Publisher, Offer: *sharedGallery.Offer, SKU: *sharedGallery.SKU}
+		}
+
+		return convertAzureComputeGalleryImageToMAPI(&capzv1.AzureComputeGalleryImage{
+			Gallery:        sharedGallery.Gallery,
+			Name:           sharedGallery.Name,
+			Version:        sharedGallery.Version,
+			SubscriptionID: ptr.To(sharedGallery.SubscriptionID),
+			ResourceGroup:  ptr.To(sharedGallery.ResourceGroup),
+			Plan:           plan,
+		}), nil
+	default:
+		return mapiv1.Image{}, field.Invalid(fldPath, capzImage, "one of id, marketplace, computeGallery or sharedGallery must be set")
+	}
+}
+
+func convertAzureComputeGalleryImageToMAPI(computeGallery *capzv1.AzureComputeGalleryImage) mapiv1.Image {
+	mapiImage := mapiv1.Image{}
+
+	// CAPZ only treats the image as a private gallery image when both the subscription and resource group are set.
+	if computeGallery.SubscriptionID != nil && computeGallery.ResourceGroup != nil {
+		mapiImage.ResourceID = fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Compute/galleries/%s/images/%s/versions/%s",
+			*computeGallery.SubscriptionID, *computeGallery.ResourceGroup, computeGallery.Gallery, computeGallery.Name, computeGallery.Version)
+	} else {
+		mapiImage.ResourceID = fmt.Sprintf("/CommunityGalleries/%s/Images/%s/Versions/%s",
+			computeGallery.Gallery, computeGallery.Name, computeGallery.Version)
+	}
+
+	if computeGallery.Plan != nil {
+		mapiImage.Publisher = computeGallery.Plan.Publisher
+		mapiImage.Offer = computeGallery.Plan.Offer
+		mapiImage.SKU = computeGallery.Plan.SKU
+		mapiImage.Type = mapiv1.AzureImageTypeMarketplaceWithPlan
+	}
+
+	return mapiImage
+}
+
+func convertAzureOSDiskToMAPI(capzOSDisk capzv1.OSDisk) mapiv1.OSDisk {
+	mapiOSDisk := mapiv1.OSDisk{
+		OSType:      capzOSDisk.OSType,
+		DiskSizeGB:  ptr.Deref(capzOSDisk.DiskSizeGB, 0),
+		CachingType: capzOSDisk.CachingType,
+	}
+
+	if capzOSDisk.ManagedDisk != nil {
+		mapiOSDisk.ManagedDisk.StorageAccountType = capzOSDisk.ManagedDisk.StorageAccountType
+
+		if capzOSDisk.ManagedDisk.DiskEncryptionSet != nil {
+			mapiOSDisk.ManagedDisk.DiskEncryptionSet = &mapiv1.DiskEncryptionSetParameters{
+				ID: capzOSDisk.ManagedDisk.DiskEncryptionSet.ID,
+			}
+		}
+
+		if capzOSDisk.ManagedDisk.SecurityProfile != nil {
+			mapiOSDisk.ManagedDisk.SecurityProfile = mapiv1.VMDiskSecurityProfile{
+				SecurityEncryptionType: mapiv1.SecurityEncryptionTypes(capzOSDisk.ManagedDisk.SecurityProfile.SecurityEncryptionType),
+			}
+
+			if capzOSDisk.ManagedDisk.SecurityProfile.DiskEncryptionSet != nil {
+				mapiOSDisk.ManagedDisk.SecurityProfile.DiskEncryptionSet.ID = capzOSDisk.ManagedDisk.SecurityProfile.DiskEncryptionSet.ID
+			}
+		}
+	}
+
+	if capzOSDisk.DiffDiskSettings != nil {
+		mapiOSDisk.DiskSettings.EphemeralStorageLocation = capzOSDisk.DiffDiskSettings.Option
+	}
+
+	return mapiOSDisk
+}
+
+// convertAzureDataDisksToMAPI converts the CAPZ data disks to their MAPI equivalent.
+// CAPZ always deletes data disks along with the machine, which corresponds to the MAPI Delete deletion policy.
+func convertAzureDataDisksToMAPI(fldPath *field.Path, capzDataDisks []capzv1.DataDisk) ([]mapiv1.DataDisk, field.ErrorList) {
+	if len(capzDataDisks) == 0 {
+		return nil, nil
+	}
+
+	errs := field.ErrorList{}
+	mapiDataDisks := make([]mapiv1.DataDisk, 0, len(capzDataDisks))
+
+	for i, capzDataDisk := range capzDataDisks {
+		if capzDataDisk.Lun == nil {
+			// CAPZ defaults the lun of each data disk on admission, MAPZ requires it to be set.
+			errs = append(errs, field.Required(fldPath.Index(i).Child("lun"), "lun is required"))
+		}
+
+		mapiDataDisk := mapiv1.DataDisk{
+			NameSuffix:     capzDataDisk.NameSuffix,
+			DiskSizeGB:     capzDataDisk.DiskSizeGB,
+			Lun:            ptr.Deref(capzDataDisk.Lun, 0),
+			CachingType:    mapiv1.CachingTypeOption(capzDataDisk.CachingType),
+			DeletionPolicy: mapiv1.DiskDeletionPolicyTypeDelete,
+		}
+
+		if capzDataDisk.ManagedDisk != nil {
+			mapiDataDisk.ManagedDisk.StorageAccountType = mapiv1.StorageAccountType(capzDataDisk.ManagedDisk.StorageAccountType)
+
+			if capzDataDisk.ManagedDisk.DiskEncryptionSet != nil {
+				mapiDataDisk.ManagedDisk.DiskEncryptionSet = &mapiv1.DiskEncryptionSetParameters{
+					ID: capzDataDisk.ManagedDisk.DiskEncryptionSet.ID,
+				}
+			}
+
+			if capzDataDisk.ManagedDisk.SecurityProfile != nil {
+				// MAPZ has no security profile for data disks.
+				errs = append(errs, field.Invalid(fldPath.Index(i).Child("managedDisk", "securityProfile"), capzDataDisk.ManagedDisk.SecurityProfile, "securityProfile is not supported on data disks"))
+			}
+		}
+
+		mapiDataDisks = append(mapiDataDisks, mapiDataDisk)
+	}
+
+	return mapiDataDisks, errs
+}
+
+// convertAzureAdditionalCapabilitiesToMAPI converts the CAPZ additional capabilities to the MAPI UltraSSD capability.
+func convertAzureAdditionalCapabilitiesToMAPI(capzAdditionalCapabilities *capzv1.AdditionalCapabilities) mapiv1.AzureUltraSSDCapabilityState {
+	if capzAdditionalCapabilities == nil || capzAdditionalCapabilities.UltraSSDEnabled == nil {
+		return ""
+	}
+
+	if *capzAdditionalCapabilities.UltraSSDEnabled {
+		return mapiv1.AzureUltraSSDCapabilityEnabled
+	}
+
+	return mapiv1.AzureUltraSSDCapabilityDisabled
+}
+
+// convertAzureSecurityProfileToMAPI converts the CAPZ security profile to its MAPI equivalent.
+// MAPI nests the UEFI settings under the settings for the chosen security type, so they cannot be converted without one.
+func convertAzureSecurityProfileToMAPI(fldPath *field.Path, capzSecurityProfile *capzv1.SecurityProfile) (*mapiv1.SecurityProfile, field.ErrorList) {
+	if capzSecurityProfile == nil {
+		return nil, nil
+	}
+
+	mapiSecurityProfile := &mapiv1.SecurityProfile{
+		EncryptionAtHost: capzSecurityProfile.EncryptionAtHost,
+		Settings: mapiv1.SecuritySettings{
+			SecurityType: mapiv1.SecurityTypes(capzSecurityProfile.SecurityType),
+		},
+	}
+
+	uefiSettings := convertAzureUEFISettingsToMAPI(capzSecurityProfile.UefiSettings)
+
+	switch capzSecurityProfile.SecurityType {
+	case capzv1.SecurityTypesConfidentialVM:
+		mapiSecurityProfile.Settings.ConfidentialVM = &mapiv1.ConfidentialVM{UEFISettings: uefiSettings}
+	case capzv1.SecurityTypesTrustedLaunch:
+		mapiSecurityProfile.Settings.TrustedLaunch = &mapiv1.TrustedLaunch{UEFISettings: uefiSettings}
+	default:
+		if uefiSettings != (mapiv1.UEFISettings{}) {
+			return nil, field.ErrorList{field.Invalid(fldPath.Child("uefiSettings"), capzSecurityProfile.UefiSettings, "uefiSettings can only be converted when securityType is set")}
+		}
+	}
+
+	return mapiSecurityProfile, nil
+}
+
+func convertAzureUEFISettingsToMAPI(capzUEFISettings *capzv1.UefiSettings) mapiv1.UEFISettings {
+	if capzUEFISettings == nil {
+		return mapiv1.UEFISettings{}
+	}
+
+	return mapiv1.UEFISettings{
+		SecureBoot:                       mapiv1.SecureBootPolicy(convertAzurePolicyToMAPI(capzUEFISettings.SecureBootEnabled)),
+		VirtualizedTrustedPlatformModule: mapiv1.VirtualizedTrustedPlatformModulePolicy(convertAzurePolicyToMAPI(capzUEFISettings.VTpmEnabled)),
+	}
+}
+
+// convertAzurePolicyToMAPI converts an optional CAPZ boolean to an Enabled/Disabled MAPI policy.
+// The secure boot and vTPM policies share the same values.
+func convertAzurePolicyToMAPI(enabled *bool) string {
+	switch {
+	case enabled == nil:
+		return ""
+	case *enabled:
+		return string(mapiv1.SecureBootPolicyEnabled)
+	default:
+		return string(mapiv1.SecureBootPolicyDisabled)
+	}
+}
+
+// convertAzureDiagnosticsToMAPI converts the CAPZ boot diagnostics to their MAPI equivalent.
+// CAPZ enables managed boot diagnostics when they are not configured, whereas MAPZ only disables them when they are not configured.
+func convertAzureDiagnosticsToMAPI(fldPath *field.Path, capzDiagnostics *capzv1.Diagnostics) (mapiv1.AzureDiagnostics, field.ErrorList) {
+	if capzDiagnostics == nil || capzDiagnostics.Boot == nil {
+		return mapiv1.AzureDiagnostics{
+			Boot: &mapiv1.AzureBootDiagnostics{StorageAccountType: mapiv1.AzureManagedAzureDiagnosticsStorage},
+		}, nil
+	}
+
+	bootPath := fldPath.Child("boot")
+
+	switch capzDiagnostics.Boot.StorageAccountType {
+	case capzv1.DisabledDiagnosticsStorage:
+		return mapiv1.AzureDiagnostics{}, nil
+	case capzv1.ManagedDiagnosticsStorage:
+		return mapiv1.AzureDiagnostics{
+			Boot: &mapiv1.AzureBootDiagnostics{StorageAccountType: mapiv1.AzureManagedAzureDiagnosticsStorage},
+		}, nil
+	case capzv1.UserManagedDiagnosticsStorage:
+		if capzDiagnostics.Boot.UserManaged == nil {
+			return mapiv1.AzureDiagnostics{}, field.ErrorList{field.Required(bootPath.Child("userManaged"), "userManaged is required when storageAccountType is UserManaged")}
+		}
+
+		return mapiv1.AzureDiagnostics{
+			Boot: &mapiv1.AzureBootDiagnostics{
+				StorageAccountType: mapiv1.CustomerManagedAzureDiagnosticsStorage,
+				CustomerManaged: &mapiv1.AzureCustomerManagedBootDiagnostics{
+					StorageAccountURI: capzDiagnostics.Boot.UserManaged.StorageAccountURI,
+				},
+			},
+		}, nil
+	default:
+		return mapiv1.AzureDiagnostics{}, field.ErrorList{field.Invalid(bootPath.Child("storageAccountType"), capzDiagnostics.Boot.StorageAccountType, "storageAccountType must be one of Disabled, Managed or UserManaged")}
+	}
+}
+
+func convertAzureIdentityToMAPI(fldPath *field.Path, identity capzv1.VMIdentity, userAssignedIdentities []capzv1.UserAssignedIdentity) (string, field.ErrorList) {
+	switch identity {
+	case "", capzv1.VMIdentityNone:
+		if len(userAssignedIdentities) > 0 {
+			return "", field.ErrorList{field.Invalid(fldPath.Child("userAssignedIdentities"), userAssignedIdentities, "userAssignedIdentities are only valid with the UserAssigned identity")}
+		}
+
+		return "", nil
+	case capzv1.VMIdentityUserAssigned:
+		// MAPZ only supports a single user assigned identity.
+		if len(userAssignedIdentities) != 1 {
+			return "", field.ErrorList{field.Invalid(fldPath.Child("userAssignedIdentities"), userAssignedIdentities, "exactly one user assigned identity must be specified")}
+		}
+
+		return strings.TrimPrefix(userAssignedIdentities[0].ProviderID, azureProviderIDPrefix), nil
+	default:
+		return "", field.ErrorList{field.Invalid(fldPath.Child("identity"), identity, "only the None and UserAssigned identities are supported")}
+	}
+}
+
+// convertAzureSpotVMOptionsToMAPI converts the CAPZ spot VM options to their MAPI equivalent.
+// MAPZ always creates spot VMs with the Delete eviction policy, so the Deallocate policy cannot be represented.
+func convertAzureSpotVMOptionsToMAPI(fldPath *field.Path, capzSpotVMOptions *capzv1.SpotVMOptions) (*mapiv1.SpotVMOptions, *field.Error) {
+	if capzSpotVMOptions == nil {
+		return nil, nil
+	}
+
+	if evictionPolicy := ptr.Deref(capzSpotVMOptions.EvictionPolicy, capzv1.SpotEvictionPolicyDelete); evictionPolicy != capzv1.SpotEvictionPolicyDelete {
+		return nil, field.Invalid(fldPath.Child("evictionPolicy"), evictionPolicy, fmt.Sprintf("evictionPolicy must be %q or omitted, MAPI always deletes evicted spot VMs", capzv1.SpotEvictionPolicyDelete))
+	}
+
+	return &mapiv1.SpotVMOptions{
+		MaxPrice: capzSpotVMOptions.MaxPrice,
+	}, nil
+}
+
+func convertAzureTagsToMAPI(capzTags capzv1.Tags) map[string]string {
+	if len(capzTags) == 0 {
+		return nil
+	}
+
+	mapiTags := map[string]string{}
+	for key, value := range capzTags {
+		mapiTags[key] = value
+	}
+
+	return mapiTags
+}
+
+// getAzureSubnetSecurityGroupName returns the name of the security group of the named subnet in the AzureCluster.
+func getAzureSubnetSecurityGroupName(azureCluster *capzv1.AzureCluster, subnetName string) string {
+	for _, subnet := range azureCluster.Spec.NetworkSpec.Subnets {
+		if subnet.Name == subnetName {
+			return subnet.SecurityGroup.Name
+		}
+	}
+
+	return ""
+}
+
+func handleUnsupportedAzureMachineFields(fldPath *field.Path, spec capzv1.AzureMachineSpec) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if spec.FailureDomain != nil {
+		// Deprecated in CAPZ in favour of the failure domain on the CAPI Machine, which is used to populate the zone.
+		errs = append(errs, field.Invalid(fldPath.Child("failureDomain"), spec.FailureDomain, "failureDomain is not supported, set the failure domain on the Machine instead"))
+	}
+
+	if spec.SystemAssignedIdentityRole != nil {
+		// Not required for our use case, MAPZ does not support system assigned identities.
+		errs = append(errs, field.Invalid(fldPath.Child("systemAssignedIdentityRole"), spec.SystemAssignedIdentityRole, "systemAssignedIdentityRole is not supported"))
+	}
+
+	if spec.RoleAssignmentName != "" {
+		// Not required for our use case, MAPZ does not support system assigned identities.
+		errs = append(errs, field.Invalid(fldPath.Child("roleAssignmentName"), spec.RoleAssignmentName, "roleAssignmentName is not supported"))
+	}
+
+	if spec.EnableIPForwarding {
+		// Not required for our use case, MAPZ never enables IP forwarding.
+		errs = append(errs, field.Invalid(fldPath.Child("enableIPForwarding"), spec.EnableIPForwarding, "enableIPForwarding is not supported"))
+	}
+
+	if len(spec.DNSServers) > 0 {
+		// Not required for our use case, MAPZ always uses the DNS servers of the virtual network.
+		errs = append(errs, field.Invalid(fldPath.Child("dnsServers"), spec.DNSServers, "dnsServers are not supported"))
+	}
+
+	if len(spec.VMExtensions) > 0 {
+		// Not required for our use case, MAPZ does not install VM extensions.
+		errs = append(errs, field.Invalid(fldPath.Child("vmExtensions"), spec.VMExtensions, "vmExtensions are not supported"))
+	}
+
+	if len(spec.NetworkInterfaces) > 0 {
+		// Not required for our use case, MAPZ configures a single network interface from the subnet.
+		errs = append(errs, field.Invalid(fldPath.Child("networkInterfaces"), spec.NetworkInterfaces, "networkInterfaces are not supported"))
+	}
+
+	return errs
+}
@@ -0,0 +1,308 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capi2mapi_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	fuzz "github.com/google/gofuzz"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/capi2mapi"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/mapi2capi"
+	conversiontest "github.com/openshift/cluster-capi-operator/pkg/conversion/test/fuzz"
+
+	runtimeserializer "k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/utils/ptr"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	capzv1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	azureMachineKind  = "AzureMachine"
+	azureTemplateKind = "AzureMachineTemplate"
+)
+
+var _ = Describe("Azure Fuzz (capi2mapi)", func() {
+	infra := &configv1.Infrastructure{
+		Spec: configv1.InfrastructureSpec{},
+		Status: configv1.InfrastructureStatus{
+			InfrastructureName: "sample-cluster-name",
+		},
+	}
+
+	infraCluster := &capzv1.AzureCluster{
+		Spec: capzv1.AzureClusterSpec{
+			AzureClusterClassSpec: capzv1.AzureClusterClassSpec{
+				Location: "eastus",
+			},
+			ResourceGroup: "sample-cluster-rg",
+		},
+	}
+
+	Context("AzureMachine Conversion", func() {
+		fromMachineAndAzureMachineAndAzureCluster := func(machine *capiv1.Machine, infraMachine client.Object, infraCluster client.Object) capi2mapi.MachineAndInfrastructureMachine {
+			azureMachine, ok := infraMachine.(*capzv1.AzureMachine)
+			Expect(ok).To(BeTrue(), "input infra machine should be of type %T, got %T", &capzv1.AzureMachine{}, infraMachine)
+
+			azureCluster, ok := infraCluster.(*capzv1.AzureCluster)
+			Expect(ok).To(BeTrue(), "input infra cluster should be of type %T, got %T", &capzv1.AzureCluster{}, infraCluster)
+
+			return capi2mapi.FromMachineAndAzureMachineAndAzureCluster(machine, azureMachine, azureCluster)
+		}
+
+		conversiontest.CAPI2MAPIMachineRoundTripFuzzTest(
+			scheme,
+			infra,
+			infraCluster,
+			&capzv1.AzureMachine{},
+			mapi2capi.FromAzureMachineAndInfra,
+			fromMachineAndAzureMachineAndAzureCluster,
+			conversiontest.ObjectMetaFuzzerFuncs(capiNamespace),
+			conversiontest.CAPIMachineFuzzerFuncs(azureProviderIDFuzzer, azureMachineKind, capzv1.GroupVersion.String(), infra.Status.InfrastructureName),
+			azureMachineFuzzerFuncs,
+		)
+	})
+
+	Context("AzureMachineSet Conversion", func() {
+		fromMachineSetAndAzureMachineTemplateAndAzureCluster := func(machineSet *capiv1.MachineSet, infraMachineTemplate client.Object, infraCluster client.Object) capi2mapi.MachineSetAndMachineTemplate {
+			azureMachineTemplate, ok := infraMachineTemplate.(*capzv1.AzureMachineTemplate)
+			Expect(ok).To(BeTrue(), "input infra machine template should be of type %T, got %T", &capzv1.AzureMachineTemplate{}, infraMachineTemplate)
+
+			azureCluster, ok := infraCluster.(*capzv1.AzureCluster)
+			Expect(ok).To(BeTrue(), "input infra cluster should be of type %T, got %T", &capzv1.AzureCluster{}, infraCluster)
+
+			return capi2mapi.FromMachineSetAndAzureMachineTemplateAndAzureCluster(machineSet, azureMachineTemplate, azureCluster)
+		}
+
+		conversiontest.CAPI2MAPIMachineSetRoundTripFuzzTest(
+			scheme,
+			infra,
+			infraCluster,
+			&capzv1.AzureMachineTemplate{},
+			mapi2capi.FromAzureMachineSetAndInfra,
+			fromMachineSetAndAzureMachineTemplateAndAzureCluster,
+			conversiontest.ObjectMetaFuzzerFuncs(capiNamespace),
+			conversiontest.CAPIMachineFuzzerFuncs(azureProviderIDFuzzer, azureTemplateKind, capzv1.GroupVersion.String(), infra.Status.InfrastructureName),
+			conversiontest.CAPIMachineSetFuzzerFuncs(azureTemplateKind, capzv1.GroupVersion.String(), infra.Status.InfrastructureName),
+			azureMachineFuzzerFuncs,
+			azureMachineTemplateFuzzerFuncs,
+		)
+	})
+})
+
+func azureProviderIDFuzzer(c fuzz.Continue) string {
+	return "azure:///subscriptions/sub/resourceGroups/sample-cluster-rg/providers/Microsoft.Compute/virtualMachines/" + strings.ReplaceAll(c.RandString(), "/", "")
+}
+
+//nolint:funlen
+func azureMachineFuzzerFuncs(codecs runtimeserializer.CodecFactory) []interface{} {
+	return []interface{}{
+		func(image *capzv1.Image, c fuzz.Continue) {
+			name := func() string { return "n" + strings.ReplaceAll(c.RandString(), "/", "") }
+			plan := func() *capzv1.ImagePlan { return &capzv1.ImagePlan{Publisher: name(), Offer: name(), SKU: name()} }
+
+			// The deprecated shared gallery images are converted back as compute gallery images,
+			// and compute gallery images in a subscription without a purchase plan are converted back as IDs.
+			switch c.Intn(4) {
+			case 0:
+				*image = capzv1.Image{ID: ptr.To("/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/images/" + name())}
+			case 1:
+				*image = capzv1.Image{Marketplace: &capzv1.AzureMarketplaceImage{
+					ImagePlan:       *plan(),
+					Version:         name(),
+					ThirdPartyImage: c.RandBool(),
+				}}
+			case 2:
+				computeGallery := &capzv1.AzureComputeGalleryImage{Gallery: name(), Name: name(), Version: name()}
+				if c.RandBool() {
+					computeGallery.Plan = plan()
+				}
+
+				*image = capzv1.Image{ComputeGallery: computeGallery}
+			case 3:
+				*image = capzv1.Image{ComputeGallery: &capzv1.AzureComputeGalleryImage{
+					SubscriptionID: ptr.To(name()),
+					ResourceGroup:  ptr.To(name()),
+					Gallery:        name(),
+					Name:           name(),
+					Version:        name(),
+					Plan:           plan(),
+				}}
+			}
+		},
+		func(osDisk *capzv1.OSDisk, c fuzz.Continue) {
+			c.FuzzNoCustom(osDisk)
+
+			// The conversion always sets the managed disk parameters, and only sets the disk size when it is positive.
+			if osDisk.ManagedDisk == nil {
+				osDisk.ManagedDisk = &capzv1.ManagedDiskParameters{}
+			}
+
+			if osDisk.DiskSizeGB != nil && *osDisk.DiskSizeGB <= 0 {
+				osDisk.DiskSizeGB = nil
+			}
+
+			// An empty option is the same as no ephemeral OS disk.
+			if osDisk.DiffDiskSettings != nil && osDisk.DiffDiskSettings.Option == "" {
+				osDisk.DiffDiskSettings = nil
+			}
+
+			// An empty disk security profile is the same as no disk security profile.
+			if securityProfile := osDisk.ManagedDisk.SecurityProfile; securityProfile != nil {
+				if securityProfile.DiskEncryptionSet != nil && securityProfile.DiskEncryptionSet.ID == "" {
+					securityProfile.DiskEncryptionSet = nil
+				}
+
+				if *securityProfile == (capzv1.VMDiskSecurityProfile{}) {
+					osDisk.ManagedDisk.SecurityProfile = nil
+				}
+			}
+		},
+		func(boot *capzv1.BootDiagnostics, c fuzz.Continue) {
+			// The storage account URI is only used, and always required, for user managed storage.
+			switch c.Intn(3) {
+			case 0:
+				*boot = capzv1.BootDiagnostics{StorageAccountType: capzv1.DisabledDiagnosticsStorage}
+			case 1:
+				*boot = capzv1.BootDiagnostics{StorageAccountType: capzv1.ManagedDiagnosticsStorage}
+			case 2:
+				*boot = capzv1.BootDiagnostics{
+					StorageAccountType: capzv1.UserManagedDiagnosticsStorage,
+					UserManaged: &capzv1.UserManagedBootDiagnostics{
+						StorageAccountURI: "https://" + strings.ReplaceAll(c.RandString(), "/", "") + ".blob.core.windows.net/",
+					},
+				}
+			}
+		},
+		func(securityProfile *capzv1.SecurityProfile, c fuzz.Continue) {
+			c.FuzzNoCustom(securityProfile)
+
+			// MAPI can only hold UEFI settings for a known security type.
+			switch c.Intn(3) {
+			case 0:
+				securityProfile.SecurityType = ""
+				securityProfile.UefiSettings = nil
+			case 1:
+				securityProfile.SecurityType = capzv1.SecurityTypesConfidentialVM
+			case 2:
+				securityProfile.SecurityType = capzv1.SecurityTypesTrustedLaunch
+			}
+
+			if securityProfile.UefiSettings != nil && *securityProfile.UefiSettings == (capzv1.UefiSettings{}) {
+				securityProfile.UefiSettings = nil
+			}
+		},
+		func(dataDisk *capzv1.DataDisk, c fuzz.Continue) {
+			c.FuzzNoCustom(dataDisk)
+
+			// MAPZ requires a lun, and the conversion always sets the managed disk parameters.
+			if dataDisk.Lun == nil {
+				dataDisk.Lun = ptr.To(c.Int31())
+			}
+
+			if dataDisk.ManagedDisk == nil {
+				dataDisk.ManagedDisk = &capzv1.ManagedDiskParameters{}
+			}
+
+			// MAPZ has no security profile for data disks.
+			dataDisk.ManagedDisk.SecurityProfile = nil
+		},
+		func(spot *capzv1.SpotVMOptions, c fuzz.Continue) {
+			c.FuzzNoCustom(spot)
+
+			// MAPI always deletes evicted spot VMs.
+			spot.EvictionPolicy = ptr.To(capzv1.SpotEvictionPolicyDelete)
+		},
+		func(spec *capzv1.AzureMachineSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(spec)
+
+			// Only a single, user assigned, identity can be converted.
+			switch c.Intn(2) {
+			case 0:
+				spec.Identity = capzv1.VMIdentityNone
+				spec.UserAssignedIdentities = nil
+			case 1:
+				spec.Identity = capzv1.VMIdentityUserAssigned
+				spec.UserAssignedIdentities = []capzv1.UserAssignedIdentity{{
+					ProviderID: "azure:///subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/" + strings.ReplaceAll(c.RandString(), "/", ""),
+				}}
+			}
+
+			// The conversion always sets accelerated networking, and only sets the capacity reservation group when it is non-empty.
+			if spec.AcceleratedNetworking == nil {
+				spec.AcceleratedNetworking = ptr.To(false)
+			}
+
+			if spec.CapacityReservationGroupID != nil && *spec.CapacityReservationGroupID == "" {
+				spec.CapacityReservationGroupID = nil
+			}
+
+			if len(spec.DataDisks) == 0 {
+				spec.DataDisks = nil
+			}
+
+			if spec.AdditionalCapabilities != nil && spec.AdditionalCapabilities.UltraSSDEnabled == nil {
+				spec.AdditionalCapabilities = nil
+			}
+
+			// CAPZ enables managed boot diagnostics when they are not configured.
+			if spec.Diagnostics == nil || spec.Diagnostics.Boot == nil {
+				spec.Diagnostics = &capzv1.Diagnostics{
+					Boot: &capzv1.BootDiagnostics{StorageAccountType: capzv1.ManagedDiagnosticsStorage},
+				}
+			}
+
+			if len(spec.AdditionalTags) == 0 {
+				spec.AdditionalTags = nil
+			}
+
+			// Fields not required for our use case can be ignored.
+			spec.FailureDomain = nil
+			spec.SystemAssignedIdentityRole = nil
+			spec.RoleAssignmentName = ""
+			spec.EnableIPForwarding = false
+			spec.DNSServers = nil
+			spec.VMExtensions = nil
+			spec.NetworkInterfaces = nil
+		},
+		func(m *capzv1.AzureMachine, c fuzz.Continue) {
+			c.FuzzNoCustom(m)
+
+			// Ensure the type meta is set correctly.
+			m.TypeMeta.APIVersion = capzv1.GroupVersion.String()
+			m.TypeMeta.Kind = azureMachineKind
+		},
+	}
+}
+
+func azureMachineTemplateFuzzerFuncs(codecs runtimeserializer.CodecFactory) []interface{} {
+	return []interface{}{
+		func(m *capzv1.AzureMachineTemplate, c fuzz.Continue) {
+			c.FuzzNoCustom(m)
+
+			// Ensure the type meta is set correctly.
+			m.TypeMeta.APIVersion = capzv1.GroupVersion.String()
+			m.TypeMeta.Kind = azureTemplateKind
+		},
+	}
+}
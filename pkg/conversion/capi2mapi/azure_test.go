@@ -0,0 +1,327 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capi2mapi
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+	capibuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/cluster-api/core/v1beta1"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/test/matchers"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/utils/ptr"
+	capzv1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	"sigs.k8s.io/yaml"
+)
+
+var _ = Describe("capi2mapi Azure conversion", func() {
+	var (
+		azureCAPIMachineBase = capibuilder.Machine()
+
+		azureCluster = &capzv1.AzureCluster{
+			Spec: capzv1.AzureClusterSpec{
+				AzureClusterClassSpec: capzv1.AzureClusterClassSpec{
+					Location: "eastus",
+				},
+				ResourceGroup: "sample-cluster-rg",
+			},
+		}
+
+		newAzureMachine = func(modify func(*capzv1.AzureMachineSpec)) *capzv1.AzureMachine {
+			azureMachine := &capzv1.AzureMachine{
+				Spec: capzv1.AzureMachineSpec{
+					VMSize: "Standard_D4s_v3",
+					Image: &capzv1.Image{
+						ID: ptr.To("/resourceGroups/test-rg/providers/Microsoft.Compute/images/test-image"),
+					},
+					OSDisk: capzv1.OSDisk{
+						OSType:     "Linux",
+						DiskSizeGB: ptr.To[int32](128),
+						ManagedDisk: &capzv1.ManagedDiskParameters{
+							StorageAccountType: "Premium_LRS",
+						},
+					},
+				},
+			}
+
+			if modify != nil {
+				modify(&azureMachine.Spec)
+			}
+
+			return azureMachine
+		}
+	)
+
+	type azureCAPI2MAPIMachineConversionInput struct {
+		machineBuilder   capibuilder.MachineBuilder
+		azureMachine     *capzv1.AzureMachine
+		expectedErrors   []string
+		expectedWarnings []string
+	}
+
+	var _ = DescribeTable("capi2mapi Azure convert CAPI Machine/InfraMachine/InfraCluster to a MAPI Machine",
+		func(in azureCAPI2MAPIMachineConversionInput) {
+			_, warns, err := FromMachineAndAzureMachineAndAzureCluster(
+				in.machineBuilder.Build(),
+				in.azureMachine,
+				azureCluster,
+			).ToMachine()
+			Expect(err).To(matchers.ConsistOfMatchErrorSubstrings(in.expectedErrors),
+				"should match expected errors while converting Azure CAPI resources to MAPI Machine")
+			Expect(warns).To(matchers.ConsistOfSubstrings(in.expectedWarnings),
+				"should match expected warnings while converting Azure CAPI resources to MAPI Machine")
+		},
+
+		// Base Case.
+		Entry("With a Base configuration", azureCAPI2MAPIMachineConversionInput{
+			machineBuilder:   azureCAPIMachineBase,
+			azureMachine:     newAzureMachine(nil),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With spot VM options using the Delete eviction policy", azureCAPI2MAPIMachineConversionInput{
+			machineBuilder: azureCAPIMachineBase,
+			azureMachine: newAzureMachine(func(spec *capzv1.AzureMachineSpec) {
+				spec.SpotVMOptions = &capzv1.SpotVMOptions{
+					MaxPrice:       ptr.To(resource.MustParse("0.1")),
+					EvictionPolicy: ptr.To(capzv1.SpotEvictionPolicyDelete),
+				}
+			}),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With spot VM options using the Deallocate eviction policy", azureCAPI2MAPIMachineConversionInput{
+			machineBuilder: azureCAPIMachineBase,
+			azureMachine: newAzureMachine(func(spec *capzv1.AzureMachineSpec) {
+				spec.SpotVMOptions = &capzv1.SpotVMOptions{
+					EvictionPolicy: ptr.To(capzv1.SpotEvictionPolicyDeallocate),
+				}
+			}),
+			expectedErrors:   []string{"spec.spotVMOptions.evictionPolicy: Invalid value: \"Deallocate\": evictionPolicy must be \"Delete\" or omitted, MAPI always deletes evicted spot VMs"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a marketplace image", azureCAPI2MAPIMachineConversionInput{
+			machineBuilder: azureCAPIMachineBase,
+			azureMachine: newAzureMachine(func(spec *capzv1.AzureMachineSpec) {
+				spec.Image = &capzv1.Image{
+					Marketplace: &capzv1.AzureMarketplaceImage{
+						ImagePlan: capzv1.ImagePlan{Publisher: "redhat", Offer: "rh-ocp-worker", SKU: "rh-ocp-worker"},
+						Version:   "413.92.2023101700",
+					},
+				}
+			}),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a community gallery image", azureCAPI2MAPIMachineConversionInput{
+			machineBuilder: azureCAPIMachineBase,
+			azureMachine: newAzureMachine(func(spec *capzv1.AzureMachineSpec) {
+				spec.Image = &capzv1.Image{
+					ComputeGallery: &capzv1.AzureComputeGalleryImage{Gallery: "community-gallery", Name: "image", Version: "1.0.0"},
+				}
+			}),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("Without an image source", azureCAPI2MAPIMachineConversionInput{
+			machineBuilder: azureCAPIMachineBase,
+			azureMachine: newAzureMachine(func(spec *capzv1.AzureMachineSpec) {
+				spec.Image = &capzv1.Image{}
+			}),
+			expectedErrors:   []string{"one of id, marketplace, computeGallery or sharedGallery must be set"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With multiple user assigned identities", azureCAPI2MAPIMachineConversionInput{
+			machineBuilder: azureCAPIMachineBase,
+			azureMachine: newAzureMachine(func(spec *capzv1.AzureMachineSpec) {
+				spec.Identity = capzv1.VMIdentityUserAssigned
+				spec.UserAssignedIdentities = []capzv1.UserAssignedIdentity{{ProviderID: "azure:///a"}, {ProviderID: "azure:///b"}}
+			}),
+			expectedErrors:   []string{"spec.userAssignedIdentities: Invalid value: []v1beta1.UserAssignedIdentity{v1beta1.UserAssignedIdentity{ProviderID:\"azure:///a\"}, v1beta1.UserAssignedIdentity{ProviderID:\"azure:///b\"}}: exactly one user assigned identity must be specified"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With an ephemeral OS disk", azureCAPI2MAPIMachineConversionInput{
+			machineBuilder: azureCAPIMachineBase,
+			azureMachine: newAzureMachine(func(spec *capzv1.AzureMachineSpec) {
+				spec.OSDisk.CachingType = "ReadOnly"
+				spec.OSDisk.DiffDiskSettings = &capzv1.DiffDiskSettings{Option: "Local"}
+			}),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a trusted launch security profile", azureCAPI2MAPIMachineConversionInput{
+			machineBuilder: azureCAPIMachineBase,
+			azureMachine: newAzureMachine(func(spec *capzv1.AzureMachineSpec) {
+				spec.SecurityProfile = &capzv1.SecurityProfile{
+					EncryptionAtHost: ptr.To(true),
+					SecurityType:     capzv1.SecurityTypesTrustedLaunch,
+					UefiSettings: &capzv1.UefiSettings{
+						SecureBootEnabled: ptr.To(true),
+						VTpmEnabled:       ptr.To(true),
+					},
+				}
+			}),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With UEFI settings without a security type", azureCAPI2MAPIMachineConversionInput{
+			machineBuilder: azureCAPIMachineBase,
+			azureMachine: newAzureMachine(func(spec *capzv1.AzureMachineSpec) {
+				spec.SecurityProfile = &capzv1.SecurityProfile{
+					UefiSettings: &capzv1.UefiSettings{VTpmEnabled: ptr.To(true)},
+				}
+			}),
+			expectedErrors:   []string{"uefiSettings can only be converted when securityType is set"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With UltraSSD data disks", azureCAPI2MAPIMachineConversionInput{
+			machineBuilder: azureCAPIMachineBase,
+			azureMachine: newAzureMachine(func(spec *capzv1.AzureMachineSpec) {
+				spec.AdditionalCapabilities = &capzv1.AdditionalCapabilities{UltraSSDEnabled: ptr.To(true)}
+				spec.DataDisks = []capzv1.DataDisk{{
+					NameSuffix:  "ultrassd",
+					DiskSizeGB:  4,
+					Lun:         ptr.To[int32](0),
+					CachingType: "None",
+					ManagedDisk: &capzv1.ManagedDiskParameters{StorageAccountType: "UltraSSD_LRS"},
+				}}
+			}),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a data disk without a lun", azureCAPI2MAPIMachineConversionInput{
+			machineBuilder: azureCAPIMachineBase,
+			azureMachine: newAzureMachine(func(spec *capzv1.AzureMachineSpec) {
+				spec.DataDisks = []capzv1.DataDisk{{NameSuffix: "data", DiskSizeGB: 128}}
+			}),
+			expectedErrors:   []string{"spec.dataDisks[0].lun: Required value: lun is required"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With user managed boot diagnostics without a storage account", azureCAPI2MAPIMachineConversionInput{
+			machineBuilder: azureCAPIMachineBase,
+			azureMachine: newAzureMachine(func(spec *capzv1.AzureMachineSpec) {
+				spec.Diagnostics = &capzv1.Diagnostics{
+					Boot: &capzv1.BootDiagnostics{StorageAccountType: capzv1.UserManagedDiagnosticsStorage},
+				}
+			}),
+			expectedErrors:   []string{"spec.diagnostics.boot.userManaged: Required value: userManaged is required when storageAccountType is UserManaged"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With unsupported DNS servers", azureCAPI2MAPIMachineConversionInput{
+			machineBuilder: azureCAPIMachineBase,
+			azureMachine: newAzureMachine(func(spec *capzv1.AzureMachineSpec) {
+				spec.DNSServers = []string{"10.0.0.10"}
+			}),
+			expectedErrors:   []string{"spec.dnsServers: Invalid value: []string{\"10.0.0.10\"}: dnsServers are not supported"},
+			expectedWarnings: []string{},
+		}),
+	)
+
+	var _ = DescribeTable("capi2mapi Azure convert CAPZ image",
+		func(in *capzv1.Image, expected mapiv1.Image) {
+			mapiMachine, _, err := FromMachineAndAzureMachineAndAzureCluster(
+				azureCAPIMachineBase.Build(),
+				newAzureMachine(func(spec *capzv1.AzureMachineSpec) { spec.Image = in }),
+				azureCluster,
+			).ToMachine()
+			Expect(err).ToNot(HaveOccurred())
+
+			providerSpec := &mapiv1.AzureMachineProviderSpec{}
+			Expect(yaml.Unmarshal(mapiMachine.Spec.ProviderSpec.Value.Raw, providerSpec)).To(Succeed())
+			Expect(providerSpec.Image).To(Equal(expected))
+		},
+
+		Entry("With a marketplace image with a purchase plan",
+			&capzv1.Image{Marketplace: &capzv1.AzureMarketplaceImage{
+				ImagePlan:       capzv1.ImagePlan{Publisher: "redhat", Offer: "rh-ocp-worker", SKU: "rh-ocp-worker"},
+				Version:         "413.92.2023101700",
+				ThirdPartyImage: true,
+			}},
+			mapiv1.Image{Publisher: "redhat", Offer: "rh-ocp-worker", SKU: "rh-ocp-worker", Version: "413.92.2023101700", Type: mapiv1.AzureImageTypeMarketplaceWithPlan},
+		),
+		Entry("With a compute gallery image",
+			&capzv1.Image{ComputeGallery: &capzv1.AzureComputeGalleryImage{
+				SubscriptionID: ptr.To("sub"),
+				ResourceGroup:  ptr.To("rg"),
+				Gallery:        "gallery",
+				Name:           "image",
+				Version:        "1.0.0",
+			}},
+			mapiv1.Image{ResourceID: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/galleries/gallery/images/image/versions/1.0.0"},
+		),
+		Entry("With a community gallery image with a purchase plan",
+			&capzv1.Image{ComputeGallery: &capzv1.AzureComputeGalleryImage{
+				Gallery: "community-gallery",
+				Name:    "image",
+				Version: "1.0.0",
+				Plan:    &capzv1.ImagePlan{Publisher: "redhat", Offer: "rh-ocp-worker", SKU: "rh-ocp-worker"},
+			}},
+			mapiv1.Image{
+				ResourceID: "/CommunityGalleries/community-gallery/Images/image/Versions/1.0.0",
+				Publisher:  "redhat",
+				Offer:      "rh-ocp-worker",
+				SKU:        "rh-ocp-worker",
+				Type:       mapiv1.AzureImageTypeMarketplaceWithPlan,
+			},
+		),
+		Entry("With a deprecated shared gallery image",
+			&capzv1.Image{SharedGallery: &capzv1.AzureSharedGalleryImage{
+				SubscriptionID: "sub",
+				ResourceGroup:  "rg",
+				Gallery:        "gallery",
+				Name:           "image",
+				Version:        "1.0.0",
+			}},
+			mapiv1.Image{ResourceID: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/galleries/gallery/images/image/versions/1.0.0"},
+		),
+	)
+
+	var _ = DescribeTable("capi2mapi Azure convert CAPZ spotVMOptions",
+		func(in *capzv1.SpotVMOptions, expected *mapiv1.SpotVMOptions) {
+			mapiMachine, _, err := FromMachineAndAzureMachineAndAzureCluster(
+				azureCAPIMachineBase.Build(),
+				newAzureMachine(func(spec *capzv1.AzureMachineSpec) { spec.SpotVMOptions = in }),
+				azureCluster,
+			).ToMachine()
+			Expect(err).ToNot(HaveOccurred())
+
+			providerSpec := &mapiv1.AzureMachineProviderSpec{}
+			Expect(yaml.Unmarshal(mapiMachine.Spec.ProviderSpec.Value.Raw, providerSpec)).To(Succeed())
+			Expect(equality.Semantic.DeepEqual(providerSpec.SpotVMOptions, expected)).To(BeTrue(), "expected spotVMOptions %v, got %v", expected, providerSpec.SpotVMOptions)
+		},
+
+		Entry("Without spot VM options", nil, nil),
+		Entry("Without an eviction policy", &capzv1.SpotVMOptions{}, &mapiv1.SpotVMOptions{}),
+		Entry("With a max price",
+			&capzv1.SpotVMOptions{MaxPrice: ptr.To(resource.MustParse("0.1")), EvictionPolicy: ptr.To(capzv1.SpotEvictionPolicyDelete)},
+			&mapiv1.SpotVMOptions{MaxPrice: ptr.To(resource.MustParse("0.1"))},
+		),
+	)
+})
@@ -0,0 +1,435 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capi2mapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/ptr"
+	capgv1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	// gcpImageFamilySegment is the path segment that identifies a reference to an image family rather than a specific image.
+	gcpImageFamilySegment = "/family/"
+)
+
+var (
+	errCAPIMachineGCPMachineGCPClusterCannotBeNil            = errors.New("provided Machine, GCPMachine and GCPCluster can not be nil")
+	errCAPIMachineSetGCPMachineTemplateGCPClusterCannotBeNil = errors.New("provided MachineSet, GCPMachineTemplate and GCPCluster can not be nil")
+)
+
+// machineAndGCPMachineAndGCPCluster stores the details of a Cluster API Machine and GCPMachine and GCPCluster.
+type machineAndGCPMachineAndGCPCluster struct {
+	machine    *capiv1.Machine
+	gcpMachine *capgv1.GCPMachine
+	gcpCluster *capgv1.GCPCluster
+}
+
+// machineSetAndGCPMachineTemplateAndGCPCluster stores the details of a Cluster API MachineSet and GCPMachineTemplate and GCPCluster.
+type machineSetAndGCPMachineTemplateAndGCPCluster struct {
+	machineSet *capiv1.MachineSet
+	template   *capgv1.GCPMachineTemplate
+	gcpCluster *capgv1.GCPCluster
+	*machineAndGCPMachineAndGCPCluster
+}
+
+// FromMachineAndGCPMachineAndGCPCluster wraps a CAPI Machine and CAPG GCPMachine and CAPG GCPCluster into a capi2mapi MachineAndInfrastructureMachine.
+func FromMachineAndGCPMachineAndGCPCluster(m *capiv1.Machine, gm *capgv1.GCPMachine, gc *capgv1.GCPCluster) MachineAndInfrastructureMachine {
+	return &machineAndGCPMachineAndGCPCluster{machine: m, gcpMachine: gm, gcpCluster: gc}
+}
+
+// FromMachineSetAndGCPMachineTemplateAndGCPCluster wraps a CAPI MachineSet and CAPG GCPMachineTemplate and CAPG GCPCluster into a capi2mapi MachineSetAndMachineTemplate.
+func FromMachineSetAndGCPMachineTemplateAndGCPCluster(ms *capiv1.MachineSet, mts *capgv1.GCPMachineTemplate, gc *capgv1.GCPCluster) MachineSetAndMachineTemplate {
+	return &machineSetAndGCPMachineTemplateAndGCPCluster{
+		machineSet: ms,
+		template:   mts,
+		gcpCluster: gc,
+		machineAndGCPMachineAndGCPCluster: &machineAndGCPMachineAndGCPCluster{
+			machine: &capiv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      ms.Spec.Template.ObjectMeta.Labels,
+					Annotations: ms.Spec.Template.ObjectMeta.Annotations,
+				},
+				Spec: ms.Spec.Template.Spec,
+			},
+			gcpMachine: &capgv1.GCPMachine{
+				Spec: mts.Spec.Template.Spec,
+			},
+			gcpCluster: gc,
+		},
+	}
+}
+
+// toProviderSpec converts a capi2mapi MachineAndGCPMachineAndGCPCluster into a MAPI GCPMachineProviderSpec.
+func (m machineAndGCPMachineAndGCPCluster) toProviderSpec() (*mapiv1.GCPMachineProviderSpec, []string, field.ErrorList) {
+	var (
+		warnings []string
+		errors   field.ErrorList
+	)
+
+	fldPath := field.NewPath("spec")
+
+	mapiBootDisk, errs := convertGCPRootDeviceToMAPI(fldPath, m.gcpMachine.Spec)
+	errors = append(errors, errs...)
+
+	additionalDisks, errs := convertGCPAdditionalDisksToMAPI(fldPath.Child("additionalDisks"), m.gcpMachine.Spec.AdditionalDisks)
+	errors = append(errors, errs...)
+
+	errors = append(errors, validateGCPScheduling(fldPath, m.gcpMachine.Spec)...)
+
+	mapgProviderSpec := mapiv1.GCPMachineProviderSpec{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "GCPMachineProviderSpec",
+			APIVersion: "machine.openshift.io/v1beta1",
+		},
+		// ObjectMeta - Only present because it's needed to form part of the runtime.RawExtension, not actually used by MAPG.
+		// UserDataSecret - Populated below.
+		// CredentialsSecret - TODO(OCPCLOUD-2713)
+		CanIPForward:           convertGCPIPForwardingToMAPI(m.gcpMachine.Spec.IPForwarding),
+		Disks:                  append([]*mapiv1.GCPDisk{mapiBootDisk}, additionalDisks...),
+		Labels:                 convertGCPLabelsToMAPI(m.gcpMachine.Spec.AdditionalLabels),
+		Metadata:               convertGCPMetadataToMAPI(m.gcpMachine.Spec.AdditionalMetadata),
+		NetworkInterfaces:      convertGCPNetworkInterfacesToMAPI(m.gcpCluster, m.gcpMachine.Spec),
+		Tags:                   convertGCPNetworkTagsToMAPI(m.gcpMachine.Spec.AdditionalNetworkTags),
+		ResourceManagerTags:    convertGCPResourceManagerTagsToMAPI(m.gcpMachine.Spec.ResourceManagerTags),
+		MachineType:            m.gcpMachine.Spec.InstanceType,
+		Region:                 m.gcpCluster.Spec.Region,
+		Zone:                   ptr.Deref(m.machine.Spec.FailureDomain, ""),
+		ProjectID:              m.gcpCluster.Spec.Project,
+		Preemptible:            m.gcpMachine.Spec.Preemptible,
+		OnHostMaintenance:      convertGCPOnHostMaintenanceToMAPI(m.gcpMachine.Spec.OnHostMaintenance),
+		ServiceAccounts:        convertGCPServiceAccountToMAPI(m.gcpMachine.Spec.ServiceAccount),
+		ShieldedInstanceConfig: convertGCPShieldedInstanceConfigToMAPI(m.gcpMachine.Spec.ShieldedInstanceConfig),
+		ConfidentialCompute:    mapiv1.ConfidentialComputePolicy(ptr.Deref(m.gcpMachine.Spec.ConfidentialCompute, "")),
+		// RestartPolicy - CAPG leaves automatic restart to the platform default.
+	}
+
+	userDataSecretName := ptr.Deref(m.machine.Spec.Bootstrap.DataSecretName, "")
+	if userDataSecretName != "" {
+		mapgProviderSpec.UserDataSecret = &corev1.LocalObjectReference{
+			Name: userDataSecretName,
+		}
+	}
+
+	// Below this line are fields not used from the CAPI GCPMachine.
+
+	// ProviderID - Populated at a different level.
+
+	// There are quite a few unsupported fields, so break them out for now.
+	errors = append(errors, handleUnsupportedGCPMachineFields(fldPath, m.gcpMachine.Spec)...)
+
+	if len(errors) > 0 {
+		return nil, warnings, errors
+	}
+
+	return &mapgProviderSpec, warnings, nil
+}
+
+// ToMachine converts a capi2mapi MachineAndGCPMachineAndGCPCluster into a MAPI Machine.
+func (m machineAndGCPMachineAndGCPCluster) ToMachine() (*mapiv1.Machine, []string, error) {
+	if m.machine == nil || m.gcpMachine == nil || m.gcpCluster == nil {
+		return nil, nil, errCAPIMachineGCPMachineGCPClusterCannotBeNil
+	}
+
+	var (
+		errors   field.ErrorList
+		warnings []string
+	)
+
+	mapgSpec, warn, err := m.toProviderSpec()
+	if err != nil {
+		errors = append(errors, err...)
+	}
+
+	gcpRawExt, errRaw := RawExtensionFromGCPProviderSpec(mapgSpec)
+	if errRaw != nil {
+		return nil, nil, fmt.Errorf("unable to convert GCP providerSpec to raw extension: %w", errRaw)
+	}
+
+	warnings = append(warnings, warn...)
+
+	mapiMachine, err := fromCAPIMachineToMAPIMachine(m.machine)
+	if err != nil {
+		errors = append(errors, err...)
+	}
+
+	mapiMachine.Spec.ProviderSpec.Value = gcpRawExt
+
+	errors = append(errors, restorePreservedProviderSpecFields(mapiMachine)...)
+
+	if len(errors) > 0 {
+		return nil, warnings, errors.ToAggregate()
+	}
+
+	return mapiMachine, warnings, nil
+}
+
+// ToMachineSet converts a capi2mapi MachineSetAndGCPMachineTemplateAndGCPCluster into a MAPI MachineSet.
+func (m machineSetAndGCPMachineTemplateAndGCPCluster) ToMachineSet() (*mapiv1.MachineSet, []string, error) {
+	if m.machineSet == nil || m.template == nil || m.gcpCluster == nil || m.machineAndGCPMachineAndGCPCluster == nil {
+		return nil, nil, errCAPIMachineSetGCPMachineTemplateGCPClusterCannotBeNil
+	}
+
+	var (
+		errors   []error
+		warnings []string
+	)
+
+	// Run the full ToMachine conversion so that we can check for
+	// any Machine level conversion errors in the spec translation.
+	mapgMachine, warn, err := m.ToMachine()
+	if err != nil {
+		errors = append(errors, err)
+	}
+
+	warnings = append(warnings, warn...)
+
+	mapiMachineSet, err := fromCAPIMachineSetToMAPIMachineSet(m.machineSet)
+	if err != nil {
+		errors = append(errors, err)
+	}
+
+	if len(errors) > 0 {
+		return nil, warnings, utilerrors.NewAggregate(errors)
+	}
+
+	mapiMachineSet.Spec.Template.Spec = mapgMachine.Spec
+
+	// Copy the labels and annotations from the Machine to the template.
+	mapiMachineSet.Spec.Template.ObjectMeta.Annotations = mapgMachine.ObjectMeta.Annotations
+	mapiMachineSet.Spec.Template.ObjectMeta.Labels = mapgMachine.ObjectMeta.Labels
+
+	return mapiMachineSet, warnings, nil
+}
+
+// Conversion helpers.
+
+// RawExtensionFromGCPProviderSpec marshals the GCP machine provider spec.
+func RawExtensionFromGCPProviderSpec(spec *mapiv1.GCPMachineProviderSpec) (*runtime.RawExtension, error) {
+	if spec == nil {
+		return &runtime.RawExtension{}, nil
+	}
+
+	rawBytes, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling providerSpec: %w", err)
+	}
+
+	return &runtime.RawExtension{
+		Raw: rawBytes,
+	}, nil
+}
+
+// convertGCPRootDeviceToMAPI converts the CAPG root device fields to the MAPI boot disk.
+// CAPG always deletes the boot disk with the machine.
+func convertGCPRootDeviceToMAPI(fldPath *field.Path, spec capgv1.GCPMachineSpec) (*mapiv1.GCPDisk, field.ErrorList) {
+	errs := field.ErrorList{}
+
+	bootDisk := &mapiv1.GCPDisk{
+		AutoDelete: true,
+		Boot:       true,
+		SizeGB:     spec.RootDeviceSize,
+	}
+
+	if spec.RootDeviceType != nil {
+		bootDisk.Type = string(*spec.RootDeviceType)
+	}
+
+	switch {
+	case spec.Image != nil && spec.ImageFamily != nil:
+		errs = append(errs, field.Invalid(fldPath.Child("imageFamily"), *spec.ImageFamily, "imageFamily cannot be set alongside image"))
+	case spec.Image != nil:
+		bootDisk.Image = *spec.Image
+	case spec.ImageFamily != nil:
+		// MAPG passes the image straight through to GCP, which accepts image family references in place of an image.
+		if !strings.Contains(*spec.ImageFamily, gcpImageFamilySegment) {
+			errs = append(errs, field.Invalid(fldPath.Child("imageFamily"), *spec.ImageFamily, fmt.Sprintf("imageFamily must be a full reference containing %q", gcpImageFamilySegment)))
+		}
+
+		bootDisk.Image = *spec.ImageFamily
+	default:
+		// CAPG would otherwise fall back to its own published images, which MAPG has no notion of.
+		errs = append(errs, field.Required(fldPath.Child("image"), "image or imageFamily is required"))
+	}
+
+	return bootDisk, errs
+}
+
+// convertGCPAdditionalDisksToMAPI converts the CAPG additional disks to MAPI disks.
+// CAPG always deletes additional disks with the machine.
+func convertGCPAdditionalDisksToMAPI(fldPath *field.Path, capgDisks []capgv1.AttachedDiskSpec) ([]*mapiv1.GCPDisk, field.ErrorList) {
+	var (
+		mapiDisks []*mapiv1.GCPDisk
+		errs      field.ErrorList
+	)
+
+	for i, disk := range capgDisks {
+		if disk.EncryptionKey != nil {
+			// TODO: Disk encryption keys are not yet converted.
+			errs = append(errs, field.Invalid(fldPath.Index(i).Child("encryptionKey"), disk.EncryptionKey, "encryptionKey is not yet supported"))
+		}
+
+		mapiDisks = append(mapiDisks, &mapiv1.GCPDisk{
+			AutoDelete: true,
+			SizeGB:     ptr.Deref(disk.Size, 0),
+			Type:       string(ptr.Deref(disk.DeviceType, "")),
+		})
+	}
+
+	return mapiDisks, errs
+}
+
+// convertGCPNetworkInterfacesToMAPI builds the single MAPI network interface from the CAPG subnet and public IP,
+// and the network and its host project from the GCPCluster.
+func convertGCPNetworkInterfacesToMAPI(gcpCluster *capgv1.GCPCluster, spec capgv1.GCPMachineSpec) []*mapiv1.GCPNetworkInterface {
+	return []*mapiv1.GCPNetworkInterface{{
+		PublicIP:   ptr.Deref(spec.PublicIP, false),
+		Network:    ptr.Deref(gcpCluster.Spec.Network.Name, ""),
+		ProjectID:  ptr.Deref(gcpCluster.Spec.Network.HostProject, ""),
+		Subnetwork: ptr.Deref(spec.Subnet, ""),
+	}}
+}
+
+// validateGCPScheduling checks that the scheduling options are valid for MAPG.
+// GCP always terminates preemptible instances on host maintenance.
+func validateGCPScheduling(fldPath *field.Path, spec capgv1.GCPMachineSpec) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if spec.Preemptible && spec.OnHostMaintenance != nil && *spec.OnHostMaintenance == capgv1.HostMaintenancePolicyMigrate {
+		errs = append(errs, field.Invalid(fldPath.Child("onHostMaintenance"), *spec.OnHostMaintenance, "onHostMaintenance must be Terminate for preemptible instances"))
+	}
+
+	return errs
+}
+
+func convertGCPLabelsToMAPI(capgLabels capgv1.Labels) map[string]string {
+	if len(capgLabels) == 0 {
+		return nil
+	}
+
+	mapiLabels := map[string]string{}
+	for key, value := range capgLabels {
+		mapiLabels[key] = value
+	}
+
+	return mapiLabels
+}
+
+func convertGCPNetworkTagsToMAPI(capgTags []string) []string {
+	if len(capgTags) == 0 {
+		return nil
+	}
+
+	return append([]string{}, capgTags...)
+}
+
+func convertGCPResourceManagerTagsToMAPI(capgTags capgv1.ResourceManagerTags) []mapiv1.ResourceManagerTag {
+	if len(capgTags) == 0 {
+		return nil
+	}
+
+	mapiTags := []mapiv1.ResourceManagerTag{}
+	for _, tag := range capgTags {
+		mapiTags = append(mapiTags, mapiv1.ResourceManagerTag{
+			ParentID: tag.ParentID,
+			Key:      tag.Key,
+			Value:    tag.Value,
+		})
+	}
+
+	return mapiTags
+}
+
+func convertGCPMetadataToMAPI(capgMetadata []capgv1.MetadataItem) []*mapiv1.GCPMetadata {
+	var mapiMetadata []*mapiv1.GCPMetadata
+
+	for _, metadata := range capgMetadata {
+		mapiMetadata = append(mapiMetadata, &mapiv1.GCPMetadata{
+			Key:   metadata.Key,
+			Value: metadata.Value,
+		})
+	}
+
+	return mapiMetadata
+}
+
+// convertGCPIPForwardingToMAPI converts the CAPG IP forwarding setting to the MAPI flag.
+// CAPG enables IP forwarding unless it is explicitly disabled.
+func convertGCPIPForwardingToMAPI(ipForwarding *capgv1.IPForwarding) bool {
+	return ipForwarding == nil || *ipForwarding != capgv1.IPForwardingDisabled
+}
+
+func convertGCPOnHostMaintenanceToMAPI(onHostMaintenance *capgv1.HostMaintenancePolicy) mapiv1.GCPHostMaintenanceType {
+	if onHostMaintenance == nil {
+		return ""
+	}
+
+	switch *onHostMaintenance {
+	case capgv1.HostMaintenancePolicyMigrate:
+		return mapiv1.MigrateHostMaintenanceType
+	case capgv1.HostMaintenancePolicyTerminate:
+		return mapiv1.TerminateHostMaintenanceType
+	default:
+		return ""
+	}
+}
+
+func convertGCPServiceAccountToMAPI(capgServiceAccount *capgv1.ServiceAccount) []mapiv1.GCPServiceAccount {
+	if capgServiceAccount == nil {
+		return nil
+	}
+
+	return []mapiv1.GCPServiceAccount{{
+		Email:  capgServiceAccount.Email,
+		Scopes: capgServiceAccount.Scopes,
+	}}
+}
+
+func convertGCPShieldedInstanceConfigToMAPI(capgConfig *capgv1.GCPShieldedInstanceConfig) mapiv1.GCPShieldedInstanceConfig {
+	if capgConfig == nil {
+		return mapiv1.GCPShieldedInstanceConfig{}
+	}
+
+	return mapiv1.GCPShieldedInstanceConfig{
+		SecureBoot:                       mapiv1.SecureBootPolicy(capgConfig.SecureBoot),
+		VirtualizedTrustedPlatformModule: mapiv1.VirtualizedTrustedPlatformModulePolicy(capgConfig.VirtualizedTrustedPlatformModule),
+		IntegrityMonitoring:              mapiv1.IntegrityMonitoringPolicy(capgConfig.IntegrityMonitoring),
+	}
+}
+
+func handleUnsupportedGCPMachineFields(fldPath *field.Path, spec capgv1.GCPMachineSpec) field.ErrorList {
+	errs := field.ErrorList{}
+
+	// TODO: The fields below are not yet converted.
+
+	if spec.RootDiskEncryptionKey != nil {
+		errs = append(errs, field.Invalid(fldPath.Child("rootDiskEncryptionKey"), spec.RootDiskEncryptionKey, "rootDiskEncryptionKey is not yet supported"))
+	}
+
+	return errs
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capi2mapi_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	fuzz "github.com/google/gofuzz"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/capi2mapi"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/mapi2capi"
+	conversiontest "github.com/openshift/cluster-capi-operator/pkg/conversion/test/fuzz"
+
+	runtimeserializer "k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/utils/ptr"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	capgv1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	gcpMachineKind  = "GCPMachine"
+	gcpTemplateKind = "GCPMachineTemplate"
+)
+
+var _ = Describe("GCP Fuzz (capi2mapi)", func() {
+	infra := &configv1.Infrastructure{
+		Spec: configv1.InfrastructureSpec{},
+		Status: configv1.InfrastructureStatus{
+			InfrastructureName: "sample-cluster-name",
+			PlatformStatus: &configv1.PlatformStatus{
+				Type: configv1.GCPPlatformType,
+				GCP: &configv1.GCPPlatformStatus{
+					ProjectID: "sample-project",
+					Region:    "us-central1",
+				},
+			},
+		},
+	}
+
+	infraCluster := &capgv1.GCPCluster{
+		Spec: capgv1.GCPClusterSpec{
+			Project: "sample-project",
+			Region:  "us-central1",
+			Network: capgv1.NetworkSpec{
+				Name: ptr.To("sample-cluster-network"),
+			},
+		},
+	}
+
+	Context("GCPMachine Conversion", func() {
+		fromMachineAndGCPMachineAndGCPCluster := func(machine *capiv1.Machine, infraMachine client.Object, infraCluster client.Object) capi2mapi.MachineAndInfrastructureMachine {
+			gcpMachine, ok := infraMachine.(*capgv1.GCPMachine)
+			Expect(ok).To(BeTrue(), "input infra machine should be of type %T, got %T", &capgv1.GCPMachine{}, infraMachine)
+
+			gcpCluster, ok := infraCluster.(*capgv1.GCPCluster)
+			Expect(ok).To(BeTrue(), "input infra cluster should be of type %T, got %T", &capgv1.GCPCluster{}, infraCluster)
+
+			return capi2mapi.FromMachineAndGCPMachineAndGCPCluster(machine, gcpMachine, gcpCluster)
+		}
+
+		conversiontest.CAPI2MAPIMachineRoundTripFuzzTest(
+			scheme,
+			infra,
+			infraCluster,
+			&capgv1.GCPMachine{},
+			mapi2capi.FromGCPMachineAndInfra,
+			fromMachineAndGCPMachineAndGCPCluster,
+			conversiontest.ObjectMetaFuzzerFuncs(capiNamespace),
+			conversiontest.CAPIMachineFuzzerFuncs(gcpProviderIDFuzzer, gcpMachineKind, capgv1.GroupVersion.String(), infra.Status.InfrastructureName),
+			gcpMachineFuzzerFuncs,
+		)
+	})
+
+	Context("GCPMachineSet Conversion", func() {
+		fromMachineSetAndGCPMachineTemplateAndGCPCluster := func(machineSet *capiv1.MachineSet, infraMachineTemplate client.Object, infraCluster client.Object) capi2mapi.MachineSetAndMachineTemplate {
+			gcpMachineTemplate, ok := infraMachineTemplate.(*capgv1.GCPMachineTemplate)
+			Expect(ok).To(BeTrue(), "input infra machine template should be of type %T, got %T", &capgv1.GCPMachineTemplate{}, infraMachineTemplate)
+
+			gcpCluster, ok := infraCluster.(*capgv1.GCPCluster)
+			Expect(ok).To(BeTrue(), "input infra cluster should be of type %T, got %T", &capgv1.GCPCluster{}, infraCluster)
+
+			return capi2mapi.FromMachineSetAndGCPMachineTemplateAndGCPCluster(machineSet, gcpMachineTemplate, gcpCluster)
+		}
+
+		conversiontest.CAPI2MAPIMachineSetRoundTripFuzzTest(
+			scheme,
+			infra,
+			infraCluster,
+			&capgv1.GCPMachineTemplate{},
+			mapi2capi.FromGCPMachineSetAndInfra,
+			fromMachineSetAndGCPMachineTemplateAndGCPCluster,
+			conversiontest.ObjectMetaFuzzerFuncs(capiNamespace),
+			conversiontest.CAPIMachineFuzzerFuncs(gcpProviderIDFuzzer, gcpTemplateKind, capgv1.GroupVersion.String(), infra.Status.InfrastructureName),
+			conversiontest.CAPIMachineSetFuzzerFuncs(gcpTemplateKind, capgv1.GroupVersion.String(), infra.Status.InfrastructureName),
+			gcpMachineFuzzerFuncs,
+			gcpMachineTemplateFuzzerFuncs,
+		)
+	})
+})
+
+func gcpProviderIDFuzzer(c fuzz.Continue) string {
+	return "gce://sample-project/us-central1-a/" + strings.ReplaceAll(c.RandString(), "/", "")
+}
+
+func gcpMachineFuzzerFuncs(codecs runtimeserializer.CodecFactory) []interface{} {
+	return []interface{}{
+		func(config *capgv1.GCPShieldedInstanceConfig, c fuzz.Continue) {
+			policies := []string{"", "Enabled", "Disabled"}
+
+			config.SecureBoot = capgv1.SecureBootPolicy(policies[c.Intn(len(policies))])
+			config.VirtualizedTrustedPlatformModule = capgv1.VirtualizedTrustedPlatformModulePolicy(policies[c.Intn(len(policies))])
+			config.IntegrityMonitoring = capgv1.IntegrityMonitoringPolicy(policies[c.Intn(len(policies))])
+		},
+		func(disk *capgv1.AttachedDiskSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(disk)
+
+			// The conversion only sets the device type and size when they are non-zero.
+			if disk.DeviceType != nil && *disk.DeviceType == "" {
+				disk.DeviceType = nil
+			}
+
+			if disk.Size != nil && *disk.Size == 0 {
+				disk.Size = nil
+			}
+
+			// TODO: Disk encryption keys are not yet converted.
+			disk.EncryptionKey = nil
+		},
+		func(spec *capgv1.GCPMachineSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(spec)
+
+			// MAPG requires exactly one image, and only accepts full image family references.
+			name := func() string { return "n" + strings.ReplaceAll(c.RandString(), "/", "") }
+
+			switch c.Intn(2) {
+			case 0:
+				spec.Image = ptr.To("projects/" + name() + "/global/images/" + name())
+				spec.ImageFamily = nil
+			case 1:
+				spec.Image = nil
+				spec.ImageFamily = ptr.To("projects/" + name() + "/global/images/family/" + name())
+			}
+
+			// The conversion always sets IP forwarding and the public IP, and only sets the subnet and root device type when they are non-empty.
+			spec.IPForwarding = ptr.To([]capgv1.IPForwarding{capgv1.IPForwardingEnabled, capgv1.IPForwardingDisabled}[c.Intn(2)])
+
+			if spec.PublicIP == nil {
+				spec.PublicIP = ptr.To(false)
+			}
+
+			if spec.Subnet != nil && *spec.Subnet == "" {
+				spec.Subnet = nil
+			}
+
+			if spec.RootDeviceType != nil && *spec.RootDeviceType == "" {
+				spec.RootDeviceType = nil
+			}
+
+			// GCP always terminates preemptible and confidential instances on host maintenance.
+			switch c.Intn(3) {
+			case 0:
+				spec.OnHostMaintenance = nil
+			case 1:
+				spec.OnHostMaintenance = ptr.To(capgv1.HostMaintenancePolicyTerminate)
+			case 2:
+				spec.OnHostMaintenance = ptr.To(capgv1.HostMaintenancePolicyMigrate)
+			}
+
+			switch c.Intn(3) {
+			case 0:
+				spec.ConfidentialCompute = nil
+			case 1:
+				spec.ConfidentialCompute = ptr.To(capgv1.ConfidentialComputePolicyEnabled)
+			case 2:
+				spec.ConfidentialCompute = ptr.To(capgv1.ConfidentialComputePolicyDisabled)
+			}
+
+			if spec.Preemptible || ptr.Deref(spec.ConfidentialCompute, "") == capgv1.ConfidentialComputePolicyEnabled {
+				spec.OnHostMaintenance = ptr.To(capgv1.HostMaintenancePolicyTerminate)
+			}
+
+			if len(spec.AdditionalMetadata) == 0 {
+				spec.AdditionalMetadata = nil
+			}
+
+			if len(spec.AdditionalLabels) == 0 {
+				spec.AdditionalLabels = nil
+			}
+
+			if len(spec.AdditionalNetworkTags) == 0 {
+				spec.AdditionalNetworkTags = nil
+			}
+
+			if len(spec.ResourceManagerTags) == 0 {
+				spec.ResourceManagerTags = nil
+			}
+
+			if len(spec.AdditionalDisks) == 0 {
+				spec.AdditionalDisks = nil
+			}
+
+			if spec.ServiceAccount != nil && len(spec.ServiceAccount.Scopes) == 0 {
+				spec.ServiceAccount.Scopes = nil
+			}
+
+			// An empty shielded VM configuration is the same as no shielded VM configuration.
+			if spec.ShieldedInstanceConfig != nil && *spec.ShieldedInstanceConfig == (capgv1.GCPShieldedInstanceConfig{}) {
+				spec.ShieldedInstanceConfig = nil
+			}
+
+			// Clear fields that are not yet supported.
+			spec.RootDiskEncryptionKey = nil
+		},
+		func(m *capgv1.GCPMachine, c fuzz.Continue) {
+			c.FuzzNoCustom(m)
+
+			// Ensure the type meta is set correctly.
+			m.TypeMeta.APIVersion = capgv1.GroupVersion.String()
+			m.TypeMeta.Kind = gcpMachineKind
+		},
+	}
+}
+
+func gcpMachineTemplateFuzzerFuncs(codecs runtimeserializer.CodecFactory) []interface{} {
+	return []interface{}{
+		func(m *capgv1.GCPMachineTemplate, c fuzz.Continue) {
+			c.FuzzNoCustom(m)
+
+			// Ensure the type meta is set correctly.
+			m.TypeMeta.APIVersion = capgv1.GroupVersion.String()
+			m.TypeMeta.Kind = gcpTemplateKind
+		},
+	}
+}
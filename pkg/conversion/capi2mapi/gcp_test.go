@@ -0,0 +1,234 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capi2mapi
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+	capibuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/cluster-api/core/v1beta1"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/test/matchers"
+	"k8s.io/utils/ptr"
+	capgv1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	"sigs.k8s.io/yaml"
+)
+
+var _ = Describe("capi2mapi GCP conversion", func() {
+	var (
+		gcpCAPIMachineBase = capibuilder.Machine()
+
+		gcpCluster = &capgv1.GCPCluster{
+			Spec: capgv1.GCPClusterSpec{
+				Project: "openshift-cpms-unit-tests",
+				Region:  "us-central1",
+				Network: capgv1.NetworkSpec{
+					Name: ptr.To("gcp-network-12345678"),
+				},
+			},
+		}
+
+		newGCPMachine = func(modify func(*capgv1.GCPMachineSpec)) *capgv1.GCPMachine {
+			gcpMachine := &capgv1.GCPMachine{
+				Spec: capgv1.GCPMachineSpec{
+					InstanceType:   "n1-standard-4",
+					Subnet:         ptr.To("gcp-subnetwork-12345678"),
+					Image:          ptr.To("projects/rhcos-cloud/global/images/rhcos-411-85-202205101201-0-gcp-x86-64"),
+					RootDeviceSize: 128,
+					RootDeviceType: ptr.To(capgv1.PdSsdDiskType),
+					PublicIP:       ptr.To(false),
+				},
+			}
+
+			if modify != nil {
+				modify(&gcpMachine.Spec)
+			}
+
+			return gcpMachine
+		}
+	)
+
+	type gcpCAPI2MAPIMachineConversionInput struct {
+		machineBuilder   capibuilder.MachineBuilder
+		gcpMachine       *capgv1.GCPMachine
+		expectedErrors   []string
+		expectedWarnings []string
+	}
+
+	var _ = DescribeTable("capi2mapi GCP convert CAPI Machine/InfraMachine/InfraCluster to a MAPI Machine",
+		func(in gcpCAPI2MAPIMachineConversionInput) {
+			_, warns, err := FromMachineAndGCPMachineAndGCPCluster(
+				in.machineBuilder.Build(),
+				in.gcpMachine,
+				gcpCluster,
+			).ToMachine()
+			Expect(err).To(matchers.ConsistOfMatchErrorSubstrings(in.expectedErrors),
+				"should match expected errors while converting GCP CAPI resources to MAPI Machine")
+			Expect(warns).To(matchers.ConsistOfSubstrings(in.expectedWarnings),
+				"should match expected warnings while converting GCP CAPI resources to MAPI Machine")
+		},
+
+		// Base Case.
+		Entry("With a Base configuration", gcpCAPI2MAPIMachineConversionInput{
+			machineBuilder:   gcpCAPIMachineBase,
+			gcpMachine:       newGCPMachine(nil),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a preemptible instance", gcpCAPI2MAPIMachineConversionInput{
+			machineBuilder: gcpCAPIMachineBase,
+			gcpMachine: newGCPMachine(func(spec *capgv1.GCPMachineSpec) {
+				spec.Preemptible = true
+				spec.OnHostMaintenance = ptr.To(capgv1.HostMaintenancePolicyTerminate)
+			}),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a preemptible instance that migrates on host maintenance", gcpCAPI2MAPIMachineConversionInput{
+			machineBuilder: gcpCAPIMachineBase,
+			gcpMachine: newGCPMachine(func(spec *capgv1.GCPMachineSpec) {
+				spec.Preemptible = true
+				spec.OnHostMaintenance = ptr.To(capgv1.HostMaintenancePolicyMigrate)
+			}),
+			expectedErrors:   []string{"spec.onHostMaintenance: Invalid value: \"Migrate\": onHostMaintenance must be Terminate for preemptible instances"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("Without an image", gcpCAPI2MAPIMachineConversionInput{
+			machineBuilder: gcpCAPIMachineBase,
+			gcpMachine: newGCPMachine(func(spec *capgv1.GCPMachineSpec) {
+				spec.Image = nil
+			}),
+			expectedErrors:   []string{"spec.image: Required value: image or imageFamily is required"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With both an image and an image family", gcpCAPI2MAPIMachineConversionInput{
+			machineBuilder: gcpCAPIMachineBase,
+			gcpMachine: newGCPMachine(func(spec *capgv1.GCPMachineSpec) {
+				spec.ImageFamily = ptr.To("projects/rhcos-cloud/global/images/family/rhcos")
+			}),
+			expectedErrors:   []string{"spec.imageFamily: Invalid value: \"projects/rhcos-cloud/global/images/family/rhcos\": imageFamily cannot be set alongside image"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With shielded VM options", gcpCAPI2MAPIMachineConversionInput{
+			machineBuilder: gcpCAPIMachineBase,
+			gcpMachine: newGCPMachine(func(spec *capgv1.GCPMachineSpec) {
+				spec.ShieldedInstanceConfig = &capgv1.GCPShieldedInstanceConfig{
+					SecureBoot:                       capgv1.SecureBootPolicyEnabled,
+					VirtualizedTrustedPlatformModule: capgv1.VirtualizedTrustedPlatformModulePolicyEnabled,
+					IntegrityMonitoring:              capgv1.IntegrityMonitoringPolicyEnabled,
+				}
+			}),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With confidential compute", gcpCAPI2MAPIMachineConversionInput{
+			machineBuilder: gcpCAPIMachineBase,
+			gcpMachine: newGCPMachine(func(spec *capgv1.GCPMachineSpec) {
+				spec.InstanceType = "n2d-standard-4"
+				spec.ConfidentialCompute = ptr.To(capgv1.ConfidentialComputePolicyEnabled)
+				spec.OnHostMaintenance = ptr.To(capgv1.HostMaintenancePolicyTerminate)
+			}),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With additional disks", gcpCAPI2MAPIMachineConversionInput{
+			machineBuilder: gcpCAPIMachineBase,
+			gcpMachine: newGCPMachine(func(spec *capgv1.GCPMachineSpec) {
+				spec.AdditionalDisks = []capgv1.AttachedDiskSpec{
+					{DeviceType: ptr.To(capgv1.PdSsdDiskType), Size: ptr.To[int64](256)},
+					{DeviceType: ptr.To(capgv1.LocalSsdDiskType)},
+				}
+			}),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With additional labels and network tags", gcpCAPI2MAPIMachineConversionInput{
+			machineBuilder: gcpCAPIMachineBase,
+			gcpMachine: newGCPMachine(func(spec *capgv1.GCPMachineSpec) {
+				spec.AdditionalLabels = capgv1.Labels{"team": "openshift"}
+				spec.AdditionalNetworkTags = []string{"gcp-tag-12345678"}
+			}),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With resource manager tags", gcpCAPI2MAPIMachineConversionInput{
+			machineBuilder: gcpCAPIMachineBase,
+			gcpMachine: newGCPMachine(func(spec *capgv1.GCPMachineSpec) {
+				spec.ResourceManagerTags = capgv1.ResourceManagerTags{
+					{ParentID: "openshift-cpms-unit-tests", Key: "environment", Value: "test"},
+				}
+			}),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a service account", gcpCAPI2MAPIMachineConversionInput{
+			machineBuilder: gcpCAPIMachineBase,
+			gcpMachine: newGCPMachine(func(spec *capgv1.GCPMachineSpec) {
+				spec.ServiceAccount = &capgv1.ServiceAccount{
+					Email:  "default",
+					Scopes: []string{"https://www.googleapis.com/auth/cloud-platform"},
+				}
+			}),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With an unsupported root disk encryption key", gcpCAPI2MAPIMachineConversionInput{
+			machineBuilder: gcpCAPIMachineBase,
+			gcpMachine: newGCPMachine(func(spec *capgv1.GCPMachineSpec) {
+				spec.RootDiskEncryptionKey = &capgv1.CustomerEncryptionKey{KeyType: capgv1.CustomerManagedKey}
+			}),
+			expectedErrors:   []string{"spec.rootDiskEncryptionKey: Invalid value: v1beta1.CustomerEncryptionKey{KeyType:\"Managed\", KMSKeyServiceAccount:(*string)(nil), ManagedKey:(*v1beta1.ManagedKey)(nil), SuppliedKey:(*v1beta1.SuppliedKey)(nil)}: rootDiskEncryptionKey is not yet supported"},
+			expectedWarnings: []string{},
+		}),
+	)
+
+	var _ = DescribeTable("capi2mapi GCP convert CAPG scheduling options",
+		func(modify func(*capgv1.GCPMachineSpec), expectedPreemptible bool, expectedOnHostMaintenance mapiv1.GCPHostMaintenanceType) {
+			mapiMachine, _, err := FromMachineAndGCPMachineAndGCPCluster(gcpCAPIMachineBase.Build(), newGCPMachine(modify), gcpCluster).ToMachine()
+			Expect(err).ToNot(HaveOccurred())
+
+			providerSpec := &mapiv1.GCPMachineProviderSpec{}
+			Expect(yaml.Unmarshal(mapiMachine.Spec.ProviderSpec.Value.Raw, providerSpec)).To(Succeed())
+			Expect(providerSpec.Preemptible).To(Equal(expectedPreemptible))
+			Expect(providerSpec.OnHostMaintenance).To(Equal(expectedOnHostMaintenance))
+			Expect(providerSpec.RestartPolicy).To(BeEmpty())
+		},
+
+		Entry("With a standard instance", nil, false, mapiv1.GCPHostMaintenanceType("")),
+		Entry("With a standard instance that migrates on host maintenance",
+			func(spec *capgv1.GCPMachineSpec) {
+				spec.OnHostMaintenance = ptr.To(capgv1.HostMaintenancePolicyMigrate)
+			},
+			false, mapiv1.MigrateHostMaintenanceType,
+		),
+		Entry("With a preemptible instance",
+			func(spec *capgv1.GCPMachineSpec) {
+				spec.Preemptible = true
+			},
+			true, mapiv1.GCPHostMaintenanceType(""),
+		),
+	)
+})
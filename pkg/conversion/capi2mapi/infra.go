@@ -0,0 +1,103 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capi2mapi
+
+import (
+	"errors"
+	"fmt"
+
+	capav1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	capzv1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	capgv1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	capibmv1 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
+	capov1 "sigs.k8s.io/cluster-api-provider-openstack/api/v1beta1"
+	capvv1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ErrUnexpectedInfraObjectType is returned when the infrastructure objects given to a conversion have unexpected types.
+var ErrUnexpectedInfraObjectType = errors.New("unexpected CAPI infrastructure object type")
+
+// FromMachineAndInfraMachineAndInfraCluster returns the conversion of the CAPI Machine for the types of its InfraMachine and InfraCluster,
+// e.g. when they were fetched from their references, without knowing the platform.
+//
+//nolint:cyclop
+func FromMachineAndInfraMachineAndInfraCluster(machine *capiv1.Machine, infraMachine, infraCluster client.Object) (MachineAndInfrastructureMachine, error) {
+	switch infraMachine := infraMachine.(type) {
+	case *capav1.AWSMachine:
+		if cluster, ok := infraCluster.(*capav1.AWSCluster); ok {
+			return FromMachineAndAWSMachineAndAWSCluster(machine, infraMachine, cluster), nil
+		}
+	case *capzv1.AzureMachine:
+		if cluster, ok := infraCluster.(*capzv1.AzureCluster); ok {
+			return FromMachineAndAzureMachineAndAzureCluster(machine, infraMachine, cluster), nil
+		}
+	case *capgv1.GCPMachine:
+		if cluster, ok := infraCluster.(*capgv1.GCPCluster); ok {
+			return FromMachineAndGCPMachineAndGCPCluster(machine, infraMachine, cluster), nil
+		}
+	case *capov1.OpenStackMachine:
+		if cluster, ok := infraCluster.(*capov1.OpenStackCluster); ok {
+			return FromMachineAndOpenStackMachineAndOpenStackCluster(machine, infraMachine, cluster), nil
+		}
+	case *capibmv1.IBMPowerVSMachine:
+		if cluster, ok := infraCluster.(*capibmv1.IBMPowerVSCluster); ok {
+			return FromMachineAndPowerVSMachineAndPowerVSCluster(machine, infraMachine, cluster), nil
+		}
+	case *capvv1.VSphereMachine:
+		if cluster, ok := infraCluster.(*capvv1.VSphereCluster); ok {
+			return FromMachineAndVSphereMachineAndVSphereCluster(machine, infraMachine, cluster), nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %T and %T", ErrUnexpectedInfraObjectType, infraMachine, infraCluster)
+}
+
+// FromMachineSetAndInfraMachineTemplateAndInfraCluster returns the conversion of the CAPI MachineSet for the types of its
+// InfraMachineTemplate and InfraCluster, e.g. when they were fetched from their references, without knowing the platform.
+//
+//nolint:cyclop
+func FromMachineSetAndInfraMachineTemplateAndInfraCluster(machineSet *capiv1.MachineSet, infraMachineTemplate, infraCluster client.Object) (MachineSetAndMachineTemplate, error) {
+	switch template := infraMachineTemplate.(type) {
+	case *capav1.AWSMachineTemplate:
+		if cluster, ok := infraCluster.(*capav1.AWSCluster); ok {
+			return FromMachineSetAndAWSMachineTemplateAndAWSCluster(machineSet, template, cluster), nil
+		}
+	case *capzv1.AzureMachineTemplate:
+		if cluster, ok := infraCluster.(*capzv1.AzureCluster); ok {
+			return FromMachineSetAndAzureMachineTemplateAndAzureCluster(machineSet, template, cluster), nil
+		}
+	case *capgv1.GCPMachineTemplate:
+		if cluster, ok := infraCluster.(*capgv1.GCPCluster); ok {
+			return FromMachineSetAndGCPMachineTemplateAndGCPCluster(machineSet, template, cluster), nil
+		}
+	case *capov1.OpenStackMachineTemplate:
+		if cluster, ok := infraCluster.(*capov1.OpenStackCluster); ok {
+			return FromMachineSetAndOpenStackMachineTemplateAndOpenStackCluster(machineSet, template, cluster), nil
+		}
+	case *capibmv1.IBMPowerVSMachineTemplate:
+		if cluster, ok := infraCluster.(*capibmv1.IBMPowerVSCluster); ok {
+			return FromMachineSetAndPowerVSMachineTemplateAndPowerVSCluster(machineSet, template, cluster), nil
+		}
+	case *capvv1.VSphereMachineTemplate:
+		if cluster, ok := infraCluster.(*capvv1.VSphereCluster); ok {
+			return FromMachineSetAndVSphereMachineTemplateAndVSphereCluster(machineSet, template, cluster), nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %T and %T", ErrUnexpectedInfraObjectType, infraMachineTemplate, infraCluster)
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capi2mapi
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	capibuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/cluster-api/core/v1beta1"
+	capabuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/cluster-api/infrastructure/v1beta2"
+
+	capzv1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+)
+
+var _ = Describe("capi2mapi conversion from the infrastructure objects", func() {
+	It("should return the conversion of a Machine for the types of the infrastructure objects", func() {
+		conversion, err := FromMachineAndInfraMachineAndInfraCluster(capibuilder.Machine().Build(),
+			capabuilder.AWSMachine().Build(), capabuilder.AWSCluster().Build())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conversion).To(BeAssignableToTypeOf(&machineAndAWSMachineAndAWSCluster{}))
+	})
+
+	It("should return the conversion of a MachineSet for the types of the infrastructure objects", func() {
+		conversion, err := FromMachineSetAndInfraMachineTemplateAndInfraCluster(capibuilder.MachineSet().Build(),
+			capabuilder.AWSMachineTemplate().Build(), capabuilder.AWSCluster().Build())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(conversion).To(BeAssignableToTypeOf(&machineSetAndAWSMachineTemplateAndAWSCluster{}))
+	})
+
+	It("should return an error when the infrastructure objects are of different platforms", func() {
+		_, err := FromMachineAndInfraMachineAndInfraCluster(capibuilder.Machine().Build(),
+			capabuilder.AWSMachine().Build(), &capzv1.AzureCluster{})
+		Expect(err).To(MatchError(ErrUnexpectedInfraObjectType))
+
+		_, err = FromMachineSetAndInfraMachineTemplateAndInfraCluster(capibuilder.MachineSet().Build(),
+			&capzv1.AzureMachineTemplate{}, capabuilder.AWSCluster().Build())
+		Expect(err).To(MatchError(ErrUnexpectedInfraObjectType))
+	})
+})
@@ -153,3 +153,25 @@ func getMAPILifecycleHooks(capiMachine *capiv1.Machine) mapiv1.LifecycleHooks {
 
 	return hooks
 }
+
+// restorePreservedProviderSpecFields restores the providerSpec fields that were preserved on the CAPI Machine when it
+// was converted from MAPI, as they have no CAPI equivalent to convert them from.
+// The annotation is removed, as it only has a meaning on the CAPI Machine.
+func restorePreservedProviderSpecFields(mapiMachine *mapiv1.Machine) field.ErrorList {
+	value, ok := mapiMachine.Annotations[conversionutil.PreservedFieldsAnnotation]
+	if !ok {
+		return nil
+	}
+
+	delete(mapiMachine.Annotations, conversionutil.PreservedFieldsAnnotation)
+
+	providerSpec, err := conversionutil.RestoreProviderSpecFields(value, mapiMachine.Spec.ProviderSpec.Value)
+	if err != nil {
+		fldPath := field.NewPath("metadata", "annotations").Key(conversionutil.PreservedFieldsAnnotation)
+		return field.ErrorList{field.Invalid(fldPath, value, err.Error())}
+	}
+
+	mapiMachine.Spec.ProviderSpec.Value = providerSpec
+
+	return nil
+}
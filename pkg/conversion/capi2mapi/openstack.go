@@ -0,0 +1,556 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capi2mapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	mapiv1alpha1 "github.com/openshift/api/machine/v1alpha1"
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/ptr"
+	capov1 "sigs.k8s.io/cluster-api-provider-openstack/api/v1beta1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	// openstackPortProfileCapabilitiesKey and openstackPortProfileTrustedKey are the Neutron binding profile keys CAPO supports.
+	openstackPortProfileCapabilitiesKey = "capabilities"
+	openstackPortProfileTrustedKey      = "trusted"
+
+	// openstackPortProfileSwitchdevCapabilities is the binding profile capabilities value enabling OVS hardware offload.
+	openstackPortProfileSwitchdevCapabilities = `["switchdev"]`
+)
+
+var (
+	errCAPIMachineOpenStackMachineOpenStackClusterCannotBeNil            = errors.New("provided Machine, OpenStackMachine and OpenStackCluster can not be nil")
+	errCAPIMachineSetOpenStackMachineTemplateOpenStackClusterCannotBeNil = errors.New("provided MachineSet, OpenStackMachineTemplate and OpenStackCluster can not be nil")
+)
+
+// machineAndOpenStackMachineAndOpenStackCluster stores the details of a Cluster API Machine and OpenStackMachine and OpenStackCluster.
+type machineAndOpenStackMachineAndOpenStackCluster struct {
+	machine          *capiv1.Machine
+	openstackMachine *capov1.OpenStackMachine
+	openstackCluster *capov1.OpenStackCluster
+}
+
+// machineSetAndOpenStackMachineTemplateAndOpenStackCluster stores the details of a Cluster API MachineSet and OpenStackMachineTemplate and OpenStackCluster.
+type machineSetAndOpenStackMachineTemplateAndOpenStackCluster struct {
+	machineSet       *capiv1.MachineSet
+	template         *capov1.OpenStackMachineTemplate
+	openstackCluster *capov1.OpenStackCluster
+	*machineAndOpenStackMachineAndOpenStackCluster
+}
+
+// FromMachineAndOpenStackMachineAndOpenStackCluster wraps a CAPI Machine and CAPO OpenStackMachine and CAPO OpenStackCluster into a capi2mapi MachineAndInfrastructureMachine.
+func FromMachineAndOpenStackMachineAndOpenStackCluster(m *capiv1.Machine, om *capov1.OpenStackMachine, oc *capov1.OpenStackCluster) MachineAndInfrastructureMachine {
+	return &machineAndOpenStackMachineAndOpenStackCluster{machine: m, openstackMachine: om, openstackCluster: oc}
+}
+
+// FromMachineSetAndOpenStackMachineTemplateAndOpenStackCluster wraps a CAPI MachineSet and CAPO OpenStackMachineTemplate and CAPO OpenStackCluster into a capi2mapi MachineSetAndMachineTemplate.
+func FromMachineSetAndOpenStackMachineTemplateAndOpenStackCluster(ms *capiv1.MachineSet, mts *capov1.OpenStackMachineTemplate, oc *capov1.OpenStackCluster) MachineSetAndMachineTemplate {
+	return &machineSetAndOpenStackMachineTemplateAndOpenStackCluster{
+		machineSet:       ms,
+		template:         mts,
+		openstackCluster: oc,
+		machineAndOpenStackMachineAndOpenStackCluster: &machineAndOpenStackMachineAndOpenStackCluster{
+			machine: &capiv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      ms.Spec.Template.ObjectMeta.Labels,
+					Annotations: ms.Spec.Template.ObjectMeta.Annotations,
+				},
+				Spec: ms.Spec.Template.Spec,
+			},
+			openstackMachine: &capov1.OpenStackMachine{
+				Spec: mts.Spec.Template.Spec,
+			},
+			openstackCluster: oc,
+		},
+	}
+}
+
+// toProviderSpec converts a capi2mapi MachineAndOpenStackMachineAndOpenStackCluster into a MAPI OpenstackProviderSpec.
+func (m machineAndOpenStackMachineAndOpenStackCluster) toProviderSpec() (*mapiv1alpha1.OpenstackProviderSpec, []string, field.ErrorList) {
+	var (
+		warnings []string
+		errors   field.ErrorList
+	)
+
+	fldPath := field.NewPath("spec")
+
+	image, errs := convertOpenStackImageToMAPI(fldPath.Child("image"), m.openstackMachine.Spec.Image)
+	errors = append(errors, errs...)
+
+	ports, errs := convertOpenStackPortsToMAPI(fldPath.Child("ports"), m.openstackMachine.Spec.Ports)
+	errors = append(errors, errs...)
+
+	securityGroups, errs := convertOpenStackSecurityGroupsToMAPI(fldPath.Child("securityGroups"), m.openstackMachine.Spec.SecurityGroups)
+	errors = append(errors, errs...)
+
+	rootVolume, errs := convertOpenStackRootVolumeToMAPI(fldPath.Child("rootVolume"), m.openstackMachine.Spec.RootVolume)
+	errors = append(errors, errs...)
+
+	additionalBlockDevices, errs := convertOpenStackAdditionalBlockDevicesToMAPI(fldPath.Child("additionalBlockDevices"), m.openstackMachine.Spec.AdditionalBlockDevices)
+	errors = append(errors, errs...)
+
+	mapoProviderSpec := mapiv1alpha1.OpenstackProviderSpec{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "OpenstackProviderSpec",
+			APIVersion: "machine.openshift.io/v1alpha1",
+		},
+		// ObjectMeta - Only present because it's needed to form part of the runtime.RawExtension, not actually used by MAPO.
+		// CloudsSecret - TODO(OCPCLOUD-2713)
+		// CloudName - TODO(OCPCLOUD-2713)
+		// UserDataSecret - Populated below.
+		// Image - Populated below.
+		// Networks - CAPO only has ports, these are converted to MAPI ports.
+		// FloatingIP - Deprecated in MAPI, CAPO assigns floating IPs from an IP address pool instead.
+		// SshUserName - Not used by MAPO for any functionality.
+		// PrimarySubnet - Not needed, MAPO also uses the first port as the primary address when it is unset.
+		Flavor:                 m.openstackMachine.Spec.Flavor,
+		KeyName:                m.openstackMachine.Spec.SSHKeyName,
+		Ports:                  ports,
+		AvailabilityZone:       ptr.Deref(m.machine.Spec.FailureDomain, ""),
+		SecurityGroups:         securityGroups,
+		Trunk:                  m.openstackMachine.Spec.Trunk,
+		Tags:                   convertOpenStackTagsToMAPI(m.openstackMachine.Spec.Tags),
+		ServerMetadata:         convertOpenStackServerMetadataToMAPI(m.openstackMachine.Spec.ServerMetadata),
+		ConfigDrive:            m.openstackMachine.Spec.ConfigDrive,
+		RootVolume:             rootVolume,
+		AdditionalBlockDevices: additionalBlockDevices,
+	}
+
+	// MAPO creates the root volume from the root volume source and ignores the image when booting from a volume.
+	if rootVolume != nil {
+		mapoProviderSpec.RootVolume.SourceUUID = image
+	} else {
+		mapoProviderSpec.Image = image
+	}
+
+	if m.openstackMachine.Spec.ServerGroup != nil {
+		mapoProviderSpec.ServerGroupID = ptr.Deref(m.openstackMachine.Spec.ServerGroup.ID, "")
+
+		if m.openstackMachine.Spec.ServerGroup.Filter != nil {
+			mapoProviderSpec.ServerGroupName = ptr.Deref(m.openstackMachine.Spec.ServerGroup.Filter.Name, "")
+		}
+	}
+
+	userDataSecretName := ptr.Deref(m.machine.Spec.Bootstrap.DataSecretName, "")
+	if userDataSecretName != "" {
+		mapoProviderSpec.UserDataSecret = &corev1.SecretReference{
+			Name: userDataSecretName,
+		}
+	}
+
+	// Below this line are fields not used from the CAPI OpenStackMachine.
+
+	// ProviderID - Populated at a different level.
+	// IdentityRef - TODO(OCPCLOUD-2713): Work out what needs to happen regarding credentials secrets.
+
+	errors = append(errors, handleUnsupportedOpenStackMachineFields(fldPath, m.openstackMachine.Spec)...)
+
+	if len(errors) > 0 {
+		return nil, warnings, errors
+	}
+
+	return &mapoProviderSpec, warnings, nil
+}
+
+// ToMachine converts a capi2mapi MachineAndOpenStackMachineAndOpenStackCluster into a MAPI Machine.
+func (m machineAndOpenStackMachineAndOpenStackCluster) ToMachine() (*mapiv1.Machine, []string, error) {
+	if m.machine == nil || m.openstackMachine == nil || m.openstackCluster == nil {
+		return nil, nil, errCAPIMachineOpenStackMachineOpenStackClusterCannotBeNil
+	}
+
+	var (
+		errors   field.ErrorList
+		warnings []string
+	)
+
+	mapoSpec, warn, err := m.toProviderSpec()
+	if err != nil {
+		errors = append(errors, err...)
+	}
+
+	openstackRawExt, errRaw := RawExtensionFromOpenStackProviderSpec(mapoSpec)
+	if errRaw != nil {
+		return nil, nil, fmt.Errorf("unable to convert OpenStack providerSpec to raw extension: %w", errRaw)
+	}
+
+	warnings = append(warnings, warn...)
+
+	mapiMachine, err := fromCAPIMachineToMAPIMachine(m.machine)
+	if err != nil {
+		errors = append(errors, err...)
+	}
+
+	mapiMachine.Spec.ProviderSpec.Value = openstackRawExt
+
+	errors = append(errors, restorePreservedProviderSpecFields(mapiMachine)...)
+
+	if len(errors) > 0 {
+		return nil, warnings, errors.ToAggregate()
+	}
+
+	return mapiMachine, warnings, nil
+}
+
+// ToMachineSet converts a capi2mapi MachineSetAndOpenStackMachineTemplateAndOpenStackCluster into a MAPI MachineSet.
+func (m machineSetAndOpenStackMachineTemplateAndOpenStackCluster) ToMachineSet() (*mapiv1.MachineSet, []string, error) {
+	if m.machineSet == nil || m.template == nil || m.openstackCluster == nil || m.machineAndOpenStackMachineAndOpenStackCluster == nil {
+		return nil, nil, errCAPIMachineSetOpenStackMachineTemplateOpenStackClusterCannotBeNil
+	}
+
+	var (
+		errors   []error
+		warnings []string
+	)
+
+	// Run the full ToMachine conversion so that we can check for
+	// any Machine level conversion errors in the spec translation.
+	mapoMachine, warn, err := m.ToMachine()
+	if err != nil {
+		errors = append(errors, err)
+	}
+
+	warnings = append(warnings, warn...)
+
+	mapiMachineSet, err := fromCAPIMachineSetToMAPIMachineSet(m.machineSet)
+	if err != nil {
+		errors = append(errors, err)
+	}
+
+	if len(errors) > 0 {
+		return nil, warnings, utilerrors.NewAggregate(errors)
+	}
+
+	mapiMachineSet.Spec.Template.Spec = mapoMachine.Spec
+
+	// Copy the labels and annotations from the Machine to the template.
+	mapiMachineSet.Spec.Template.ObjectMeta.Annotations = mapoMachine.ObjectMeta.Annotations
+	mapiMachineSet.Spec.Template.ObjectMeta.Labels = mapoMachine.ObjectMeta.Labels
+
+	return mapiMachineSet, warnings, nil
+}
+
+// Conversion helpers.
+
+// RawExtensionFromOpenStackProviderSpec marshals the OpenStack machine provider spec.
+func RawExtensionFromOpenStackProviderSpec(spec *mapiv1alpha1.OpenstackProviderSpec) (*runtime.RawExtension, error) {
+	if spec == nil {
+		return &runtime.RawExtension{}, nil
+	}
+
+	rawBytes, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling providerSpec: %w", err)
+	}
+
+	return &runtime.RawExtension{
+		Raw: rawBytes,
+	}, nil
+}
+
+// convertOpenStackImageToMAPI converts the CAPO image to a MAPI image name.
+// MAPO only references images by name.
+func convertOpenStackImageToMAPI(fldPath *field.Path, image capov1.ImageParam) (string, field.ErrorList) {
+	var errs field.ErrorList
+
+	if image.ID != nil {
+		errs = append(errs, field.Invalid(fldPath.Child("id"), *image.ID, "id is not supported, MAPI only references images by name"))
+	}
+
+	if image.Filter == nil || ptr.Deref(image.Filter.Name, "") == "" {
+		return "", append(errs, field.Required(fldPath.Child("filter", "name"), "name is required"))
+	}
+
+	if len(image.Filter.Tags) > 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("filter", "tags"), image.Filter.Tags, "tags are not supported"))
+	}
+
+	return *image.Filter.Name, errs
+}
+
+// convertOpenStackPortsToMAPI converts the CAPO ports to MAPI ports.
+// MAPO only references port networks and subnets by ID.
+func convertOpenStackPortsToMAPI(fldPath *field.Path, capoPorts []capov1.PortOpts) ([]mapiv1alpha1.PortOpts, field.ErrorList) {
+	var (
+		mapiPorts []mapiv1alpha1.PortOpts
+		errs      field.ErrorList
+	)
+
+	for i, port := range capoPorts {
+		portPath := fldPath.Index(i)
+
+		mapiPort := mapiv1alpha1.PortOpts{
+			NameSuffix:       ptr.Deref(port.NameSuffix, ""),
+			Description:      ptr.Deref(port.Description, ""),
+			AdminStateUp:     port.AdminStateUp,
+			MACAddress:       ptr.Deref(port.MACAddress, ""),
+			Tags:             convertOpenStackTagsToMAPI(port.Tags),
+			VNICType:         ptr.Deref(port.VNICType, ""),
+			Profile:          convertOpenStackPortProfileToMAPI(port.Profile),
+			Trunk:            port.Trunk,
+			DeprecatedHostID: ptr.Deref(port.HostID, ""),
+		}
+
+		if port.Network != nil {
+			if port.Network.Filter != nil {
+				errs = append(errs, field.Invalid(portPath.Child("network", "filter"), port.Network.Filter, "filter is not supported, MAPI only references port networks by ID"))
+			}
+
+			mapiPort.NetworkID = ptr.Deref(port.Network.ID, "")
+		}
+
+		for j, fixedIP := range port.FixedIPs {
+			mapiFixedIP := mapiv1alpha1.FixedIPs{
+				IPAddress: ptr.Deref(fixedIP.IPAddress, ""),
+			}
+
+			if fixedIP.Subnet != nil {
+				if fixedIP.Subnet.Filter != nil {
+					errs = append(errs, field.Invalid(portPath.Child("fixedIPs").Index(j).Child("subnet", "filter"), fixedIP.Subnet.Filter, "filter is not supported, MAPI only references port subnets by ID"))
+				}
+
+				mapiFixedIP.SubnetID = ptr.Deref(fixedIP.Subnet.ID, "")
+			}
+
+			mapiPort.FixedIPs = append(mapiPort.FixedIPs, mapiFixedIP)
+		}
+
+		if len(port.SecurityGroups) > 0 {
+			securityGroups := []string{}
+
+			for j, securityGroup := range port.SecurityGroups {
+				if securityGroup.Filter != nil {
+					errs = append(errs, field.Invalid(portPath.Child("securityGroups").Index(j).Child("filter"), securityGroup.Filter, "filter is not supported, MAPI only references port security groups by ID"))
+				}
+
+				securityGroups = append(securityGroups, ptr.Deref(securityGroup.ID, ""))
+			}
+
+			mapiPort.SecurityGroups = &securityGroups
+		}
+
+		for _, addressPair := range port.AllowedAddressPairs {
+			mapiPort.AllowedAddressPairs = append(mapiPort.AllowedAddressPairs, mapiv1alpha1.AddressPair{
+				IPAddress:  addressPair.IPAddress,
+				MACAddress: ptr.Deref(addressPair.MACAddress, ""),
+			})
+		}
+
+		if port.DisablePortSecurity != nil {
+			mapiPort.PortSecurity = ptr.To(!*port.DisablePortSecurity)
+		}
+
+		errs = append(errs, handleUnsupportedOpenStackPortFields(portPath, port)...)
+
+		mapiPorts = append(mapiPorts, mapiPort)
+	}
+
+	return mapiPorts, errs
+}
+
+// convertOpenStackPortProfileToMAPI converts the CAPO binding profile to a MAPI port binding profile.
+// MAPO leaves OVS hardware offload disabled unless the switchdev capability is requested, so disabling it sets no key.
+func convertOpenStackPortProfileToMAPI(capoProfile *capov1.BindingProfile) map[string]string {
+	if capoProfile == nil {
+		return nil
+	}
+
+	mapiProfile := map[string]string{}
+
+	if ptr.Deref(capoProfile.OVSHWOffload, false) {
+		mapiProfile[openstackPortProfileCapabilitiesKey] = openstackPortProfileSwitchdevCapabilities
+	}
+
+	if capoProfile.TrustedVF != nil {
+		mapiProfile[openstackPortProfileTrustedKey] = strconv.FormatBool(*capoProfile.TrustedVF)
+	}
+
+	if len(mapiProfile) == 0 {
+		return nil
+	}
+
+	return mapiProfile
+}
+
+// convertOpenStackSecurityGroupsToMAPI converts the CAPO security group params to MAPI security groups.
+func convertOpenStackSecurityGroupsToMAPI(fldPath *field.Path, capoSecurityGroups []capov1.SecurityGroupParam) ([]mapiv1alpha1.SecurityGroupParam, field.ErrorList) {
+	var (
+		mapiSecurityGroups []mapiv1alpha1.SecurityGroupParam
+		errs               field.ErrorList
+	)
+
+	for i, securityGroup := range capoSecurityGroups {
+		if securityGroup.ID != nil && securityGroup.Filter != nil {
+			errs = append(errs, field.Invalid(fldPath.Index(i), securityGroup, "id and filter cannot both be set"))
+		}
+
+		mapiSecurityGroup := mapiv1alpha1.SecurityGroupParam{
+			UUID: ptr.Deref(securityGroup.ID, ""),
+		}
+
+		if securityGroup.Filter != nil {
+			tags, tagsAny, notTags, notTagsAny := convertOpenStackNeutronTagsToMAPI(securityGroup.Filter.FilterByNeutronTags)
+
+			mapiSecurityGroup.Name = securityGroup.Filter.Name
+			mapiSecurityGroup.Filter = mapiv1alpha1.SecurityGroupFilter{
+				Description: securityGroup.Filter.Description,
+				ProjectID:   securityGroup.Filter.ProjectID,
+				Tags:        tags,
+				TagsAny:     tagsAny,
+				NotTags:     notTags,
+				NotTagsAny:  notTagsAny,
+			}
+		}
+
+		mapiSecurityGroups = append(mapiSecurityGroups, mapiSecurityGroup)
+	}
+
+	return mapiSecurityGroups, errs
+}
+
+// convertOpenStackNeutronTagsToMAPI converts the CAPO neutron tag lists to MAPI comma separated tag filters.
+func convertOpenStackNeutronTagsToMAPI(neutronTags capov1.FilterByNeutronTags) (string, string, string, string) {
+	joinTags := func(tags []capov1.NeutronTag) string {
+		var stringTags []string
+		for _, tag := range tags {
+			stringTags = append(stringTags, string(tag))
+		}
+
+		return strings.Join(stringTags, ",")
+	}
+
+	return joinTags(neutronTags.Tags), joinTags(neutronTags.TagsAny), joinTags(neutronTags.NotTags), joinTags(neutronTags.NotTagsAny)
+}
+
+func convertOpenStackTagsToMAPI(capoTags []string) []string {
+	if len(capoTags) == 0 {
+		return nil
+	}
+
+	return append([]string{}, capoTags...)
+}
+
+func convertOpenStackServerMetadataToMAPI(capoServerMetadata []capov1.ServerMetadata) map[string]string {
+	if len(capoServerMetadata) == 0 {
+		return nil
+	}
+
+	mapiServerMetadata := map[string]string{}
+
+	for _, metadata := range capoServerMetadata {
+		mapiServerMetadata[metadata.Key] = metadata.Value
+	}
+
+	return mapiServerMetadata
+}
+
+// convertOpenStackRootVolumeToMAPI converts the CAPO root volume to a MAPI root volume.
+// The source of the root volume is populated from the image by the caller.
+func convertOpenStackRootVolumeToMAPI(fldPath *field.Path, capoRootVolume *capov1.RootVolume) (*mapiv1alpha1.RootVolume, field.ErrorList) {
+	if capoRootVolume == nil {
+		return nil, nil
+	}
+
+	zone, errs := convertOpenStackVolumeAvailabilityZoneToMAPI(fldPath.Child("availabilityZone"), capoRootVolume.AvailabilityZone)
+
+	return &mapiv1alpha1.RootVolume{
+		VolumeType: capoRootVolume.Type,
+		Size:       capoRootVolume.SizeGiB,
+		Zone:       zone,
+	}, errs
+}
+
+func convertOpenStackAdditionalBlockDevicesToMAPI(fldPath *field.Path, capoBlockDevices []capov1.AdditionalBlockDevice) ([]mapiv1alpha1.AdditionalBlockDevice, field.ErrorList) {
+	var (
+		mapiBlockDevices []mapiv1alpha1.AdditionalBlockDevice
+		errs             field.ErrorList
+	)
+
+	for i, blockDevice := range capoBlockDevices {
+		mapiBlockDevice := mapiv1alpha1.AdditionalBlockDevice{
+			Name:    blockDevice.Name,
+			SizeGiB: blockDevice.SizeGiB,
+			Storage: mapiv1alpha1.BlockDeviceStorage{
+				Type: mapiv1alpha1.BlockDeviceType(blockDevice.Storage.Type),
+			},
+		}
+
+		if blockDevice.Storage.Volume != nil {
+			zone, zoneErrs := convertOpenStackVolumeAvailabilityZoneToMAPI(fldPath.Index(i).Child("storage", "volume", "availabilityZone"), blockDevice.Storage.Volume.AvailabilityZone)
+			errs = append(errs, zoneErrs...)
+
+			mapiBlockDevice.Storage.Volume = &mapiv1alpha1.BlockDeviceVolume{
+				Type:             blockDevice.Storage.Volume.Type,
+				AvailabilityZone: zone,
+			}
+		}
+
+		mapiBlockDevices = append(mapiBlockDevices, mapiBlockDevice)
+	}
+
+	return mapiBlockDevices, errs
+}
+
+// convertOpenStackVolumeAvailabilityZoneToMAPI converts the CAPO volume availability zone to a MAPI zone name.
+// MAPO only supports naming the volume availability zone explicitly.
+func convertOpenStackVolumeAvailabilityZoneToMAPI(fldPath *field.Path, availabilityZone *capov1.VolumeAvailabilityZone) (string, field.ErrorList) {
+	if availabilityZone == nil {
+		return "", nil
+	}
+
+	if availabilityZone.From != "" && availabilityZone.From != capov1.VolumeAZFromName {
+		return "", field.ErrorList{field.NotSupported(fldPath.Child("from"), availabilityZone.From, []string{string(capov1.VolumeAZFromName)})}
+	}
+
+	return string(ptr.Deref(availabilityZone.Name, "")), nil
+}
+
+func handleUnsupportedOpenStackPortFields(fldPath *field.Path, port capov1.PortOpts) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if port.PropagateUplinkStatus != nil {
+		errs = append(errs, field.Invalid(fldPath.Child("propagateUplinkStatus"), *port.PropagateUplinkStatus, "propagateUplinkStatus is not supported"))
+	}
+
+	if len(port.ValueSpecs) > 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("valueSpecs"), port.ValueSpecs, "valueSpecs are not supported"))
+	}
+
+	return errs
+}
+
+func handleUnsupportedOpenStackMachineFields(fldPath *field.Path, spec capov1.OpenStackMachineSpec) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if spec.FloatingIPPoolRef != nil {
+		// MAPO has no notion of IP address pools for floating IPs.
+		errs = append(errs, field.Invalid(fldPath.Child("floatingIPPoolRef"), spec.FloatingIPPoolRef, "floatingIPPoolRef is not supported"))
+	}
+
+	return errs
+}
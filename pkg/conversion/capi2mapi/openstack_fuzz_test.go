@@ -0,0 +1,195 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capi2mapi_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	fuzz "github.com/google/gofuzz"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/capi2mapi"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/mapi2capi"
+	conversiontest "github.com/openshift/cluster-capi-operator/pkg/conversion/test/fuzz"
+
+	runtimeserializer "k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/utils/ptr"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	capov1 "sigs.k8s.io/cluster-api-provider-openstack/api/v1beta1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	openstackMachineKind  = "OpenStackMachine"
+	openstackTemplateKind = "OpenStackMachineTemplate"
+)
+
+var _ = Describe("OpenStack Fuzz (capi2mapi)", func() {
+	infra := &configv1.Infrastructure{
+		Spec: configv1.InfrastructureSpec{},
+		Status: configv1.InfrastructureStatus{
+			InfrastructureName: "sample-cluster-name",
+			PlatformStatus: &configv1.PlatformStatus{
+				Type: configv1.OpenStackPlatformType,
+			},
+		},
+	}
+
+	infraCluster := &capov1.OpenStackCluster{}
+
+	Context("OpenStackMachine Conversion", func() {
+		fromMachineAndOpenStackMachineAndOpenStackCluster := func(machine *capiv1.Machine, infraMachine client.Object, infraCluster client.Object) capi2mapi.MachineAndInfrastructureMachine {
+			openstackMachine, ok := infraMachine.(*capov1.OpenStackMachine)
+			Expect(ok).To(BeTrue(), "input infra machine should be of type %T, got %T", &capov1.OpenStackMachine{}, infraMachine)
+
+			openstackCluster, ok := infraCluster.(*capov1.OpenStackCluster)
+			Expect(ok).To(BeTrue(), "input infra cluster should be of type %T, got %T", &capov1.OpenStackCluster{}, infraCluster)
+
+			return capi2mapi.FromMachineAndOpenStackMachineAndOpenStackCluster(machine, openstackMachine, openstackCluster)
+		}
+
+		conversiontest.CAPI2MAPIMachineRoundTripFuzzTest(
+			scheme,
+			infra,
+			infraCluster,
+			&capov1.OpenStackMachine{},
+			mapi2capi.FromOpenStackMachineAndInfra,
+			fromMachineAndOpenStackMachineAndOpenStackCluster,
+			conversiontest.ObjectMetaFuzzerFuncs(capiNamespace),
+			conversiontest.CAPIMachineFuzzerFuncs(openstackProviderIDFuzzer, openstackMachineKind, capov1.GroupVersion.String(), infra.Status.InfrastructureName),
+			openstackMachineFuzzerFuncs,
+		)
+	})
+
+	Context("OpenStackMachineSet Conversion", func() {
+		fromMachineSetAndOpenStackMachineTemplateAndOpenStackCluster := func(machineSet *capiv1.MachineSet, infraMachineTemplate client.Object, infraCluster client.Object) capi2mapi.MachineSetAndMachineTemplate {
+			openstackMachineTemplate, ok := infraMachineTemplate.(*capov1.OpenStackMachineTemplate)
+			Expect(ok).To(BeTrue(), "input infra machine template should be of type %T, got %T", &capov1.OpenStackMachineTemplate{}, infraMachineTemplate)
+
+			openstackCluster, ok := infraCluster.(*capov1.OpenStackCluster)
+			Expect(ok).To(BeTrue(), "input infra cluster should be of type %T, got %T", &capov1.OpenStackCluster{}, infraCluster)
+
+			return capi2mapi.FromMachineSetAndOpenStackMachineTemplateAndOpenStackCluster(machineSet, openstackMachineTemplate, openstackCluster)
+		}
+
+		conversiontest.CAPI2MAPIMachineSetRoundTripFuzzTest(
+			scheme,
+			infra,
+			infraCluster,
+			&capov1.OpenStackMachineTemplate{},
+			mapi2capi.FromOpenStackMachineSetAndInfra,
+			fromMachineSetAndOpenStackMachineTemplateAndOpenStackCluster,
+			conversiontest.ObjectMetaFuzzerFuncs(capiNamespace),
+			conversiontest.CAPIMachineFuzzerFuncs(openstackProviderIDFuzzer, openstackTemplateKind, capov1.GroupVersion.String(), infra.Status.InfrastructureName),
+			conversiontest.CAPIMachineSetFuzzerFuncs(openstackTemplateKind, capov1.GroupVersion.String(), infra.Status.InfrastructureName),
+			openstackMachineFuzzerFuncs,
+			openstackMachineTemplateFuzzerFuncs,
+		)
+	})
+})
+
+func openstackProviderIDFuzzer(c fuzz.Continue) string {
+	return "openstack:///" + strings.ReplaceAll(c.RandString(), "/", "")
+}
+
+func openstackMachineFuzzerFuncs(codecs runtimeserializer.CodecFactory) []interface{} {
+	return []interface{}{
+		func(sg *capov1.SecurityGroupParam, c fuzz.Continue) {
+			c.FuzzNoCustom(sg)
+
+			// CAPO only supports referencing a security group by either ID or filter.
+			if sg.ID != nil {
+				sg.Filter = nil
+			}
+		},
+		func(az *capov1.VolumeAvailabilityZone, c fuzz.Continue) {
+			c.FuzzNoCustom(az)
+
+			// MAPO only supports naming the volume availability zone explicitly.
+			az.From = capov1.VolumeAZFromName
+		},
+		func(port *capov1.PortOpts, c fuzz.Continue) {
+			c.FuzzNoCustom(port)
+
+			// MAPO only references port networks, subnets and security groups by ID.
+			if port.Network != nil {
+				port.Network.Filter = nil
+			}
+
+			for i := range port.FixedIPs {
+				if port.FixedIPs[i].Subnet != nil {
+					port.FixedIPs[i].Subnet.Filter = nil
+				}
+			}
+
+			for i := range port.SecurityGroups {
+				port.SecurityGroups[i].Filter = nil
+			}
+
+			// MAPI only requests OVS hardware offload, disabling it is the same as not setting it.
+			if port.Profile != nil && !ptr.Deref(port.Profile.OVSHWOffload, false) {
+				port.Profile.OVSHWOffload = nil
+			}
+
+			if port.Profile != nil && *port.Profile == (capov1.BindingProfile{}) {
+				port.Profile = nil
+			}
+
+			// Clear fields that are not supported.
+			port.PropagateUplinkStatus = nil
+			port.ValueSpecs = nil
+		},
+		func(spec *capov1.OpenStackMachineSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(spec)
+
+			if spec.Flavor == "" {
+				spec.Flavor = "sample-flavor"
+			}
+
+			// MAPO only references images by name.
+			spec.Image = capov1.ImageParam{
+				Filter: &capov1.ImageFilter{Name: ptr.To("sample-image")},
+			}
+
+			// Clear fields that are not supported.
+			spec.FloatingIPPoolRef = nil
+		},
+		func(m *capov1.OpenStackMachine, c fuzz.Continue) {
+			c.FuzzNoCustom(m)
+
+			// Ensure the type meta is set correctly.
+			m.TypeMeta.APIVersion = capov1.GroupVersion.String()
+			m.TypeMeta.Kind = openstackMachineKind
+		},
+	}
+}
+
+func openstackMachineTemplateFuzzerFuncs(codecs runtimeserializer.CodecFactory) []interface{} {
+	return []interface{}{
+		func(m *capov1.OpenStackMachineTemplate, c fuzz.Continue) {
+			c.FuzzNoCustom(m)
+
+			// Ensure the type meta is set correctly.
+			m.TypeMeta.APIVersion = capov1.GroupVersion.String()
+			m.TypeMeta.Kind = openstackTemplateKind
+		},
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capi2mapi
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	mapiv1alpha1 "github.com/openshift/api/machine/v1alpha1"
+	capibuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/cluster-api/core/v1beta1"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/test/matchers"
+	"k8s.io/utils/ptr"
+	capov1 "sigs.k8s.io/cluster-api-provider-openstack/api/v1beta1"
+	"sigs.k8s.io/yaml"
+)
+
+var _ = Describe("capi2mapi OpenStack conversion", func() {
+	var (
+		openstackCAPIMachineBase = capibuilder.Machine()
+
+		openstackCluster = &capov1.OpenStackCluster{}
+
+		newOpenStackMachine = func(modify func(*capov1.OpenStackMachineSpec)) *capov1.OpenStackMachine {
+			openstackMachine := &capov1.OpenStackMachine{
+				Spec: capov1.OpenStackMachineSpec{
+					Flavor: "m1.large",
+					Image: capov1.ImageParam{
+						Filter: &capov1.ImageFilter{Name: ptr.To("rhcos")},
+					},
+					Ports: []capov1.PortOpts{{
+						Network:  &capov1.NetworkParam{ID: ptr.To("d06af90b-1677-4b35-a7fb-3ae023dc8f62")},
+						FixedIPs: []capov1.FixedIP{{Subnet: &capov1.SubnetParam{ID: ptr.To("810c3d97-98c2-4cf3-b0f6-8977b6e0b4b2")}}},
+					}},
+					SecurityGroups: []capov1.SecurityGroupParam{{
+						Filter: &capov1.SecurityGroupFilter{Name: "test-cluster-worker"},
+					}},
+					ServerGroup: &capov1.ServerGroupParam{
+						Filter: &capov1.ServerGroupFilter{Name: ptr.To("test-cluster-worker")},
+					},
+					Trunk: true,
+					Tags:  []string{"openshiftClusterID=test-cluster"},
+				},
+			}
+
+			if modify != nil {
+				modify(&openstackMachine.Spec)
+			}
+
+			return openstackMachine
+		}
+	)
+
+	type openstackCAPI2MAPIMachineConversionInput struct {
+		machineBuilder   capibuilder.MachineBuilder
+		openstackMachine *capov1.OpenStackMachine
+		expectedErrors   []string
+		expectedWarnings []string
+	}
+
+	var _ = DescribeTable("capi2mapi OpenStack convert CAPI Machine/InfraMachine/InfraCluster to a MAPI Machine",
+		func(in openstackCAPI2MAPIMachineConversionInput) {
+			_, warns, err := FromMachineAndOpenStackMachineAndOpenStackCluster(
+				in.machineBuilder.Build(),
+				in.openstackMachine,
+				openstackCluster,
+			).ToMachine()
+			Expect(err).To(matchers.ConsistOfMatchErrorSubstrings(in.expectedErrors),
+				"should match expected errors while converting OpenStack CAPI resources to MAPI Machine")
+			Expect(warns).To(matchers.ConsistOfSubstrings(in.expectedWarnings),
+				"should match expected warnings while converting OpenStack CAPI resources to MAPI Machine")
+		},
+
+		// Base Case.
+		Entry("With a Base configuration", openstackCAPI2MAPIMachineConversionInput{
+			machineBuilder:   openstackCAPIMachineBase,
+			openstackMachine: newOpenStackMachine(nil),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a failure domain", openstackCAPI2MAPIMachineConversionInput{
+			machineBuilder:   openstackCAPIMachineBase.WithFailureDomain(ptr.To("test-zone")),
+			openstackMachine: newOpenStackMachine(nil),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a root volume", openstackCAPI2MAPIMachineConversionInput{
+			machineBuilder: openstackCAPIMachineBase,
+			openstackMachine: newOpenStackMachine(func(spec *capov1.OpenStackMachineSpec) {
+				spec.RootVolume = &capov1.RootVolume{
+					SizeGiB: 50,
+					BlockDeviceVolume: capov1.BlockDeviceVolume{
+						Type:             "fast",
+						AvailabilityZone: &capov1.VolumeAvailabilityZone{From: capov1.VolumeAZFromName, Name: ptr.To(capov1.VolumeAZName("test-volume-zone"))},
+					},
+				}
+			}),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a root volume in the machine availability zone", openstackCAPI2MAPIMachineConversionInput{
+			machineBuilder: openstackCAPIMachineBase,
+			openstackMachine: newOpenStackMachine(func(spec *capov1.OpenStackMachineSpec) {
+				spec.RootVolume = &capov1.RootVolume{
+					SizeGiB: 50,
+					BlockDeviceVolume: capov1.BlockDeviceVolume{
+						AvailabilityZone: &capov1.VolumeAvailabilityZone{From: capov1.VolumeAZFromMachine},
+					},
+				}
+			}),
+			expectedErrors:   []string{"spec.rootVolume.availabilityZone.from: Unsupported value: \"Machine\": supported values: \"Name\""},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With an image ID", openstackCAPI2MAPIMachineConversionInput{
+			machineBuilder: openstackCAPIMachineBase,
+			openstackMachine: newOpenStackMachine(func(spec *capov1.OpenStackMachineSpec) {
+				spec.Image = capov1.ImageParam{ID: ptr.To("c1a2b3c4-0000-1111-2222-333344445555")}
+			}),
+			expectedErrors: []string{
+				"spec.image.id: Invalid value: \"c1a2b3c4-0000-1111-2222-333344445555\": id is not supported, MAPI only references images by name",
+				"spec.image.filter.name: Required value: name is required",
+			},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a port network filter", openstackCAPI2MAPIMachineConversionInput{
+			machineBuilder: openstackCAPIMachineBase,
+			openstackMachine: newOpenStackMachine(func(spec *capov1.OpenStackMachineSpec) {
+				spec.Ports[0].Network = &capov1.NetworkParam{Filter: &capov1.NetworkFilter{Name: "test-network"}}
+			}),
+			expectedErrors:   []string{"spec.ports[0].network.filter: Invalid value: v1beta1.NetworkFilter{Name:\"test-network\""},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With unsupported fields", openstackCAPI2MAPIMachineConversionInput{
+			machineBuilder: openstackCAPIMachineBase,
+			openstackMachine: newOpenStackMachine(func(spec *capov1.OpenStackMachineSpec) {
+				spec.Image.Filter.Tags = []string{"rhcos"}
+				spec.Ports[0].PropagateUplinkStatus = ptr.To(true)
+			}),
+			expectedErrors: []string{
+				"spec.image.filter.tags: Invalid value: []string{\"rhcos\"}: tags are not supported",
+				"spec.ports[0].propagateUplinkStatus: Invalid value: true: propagateUplinkStatus is not supported",
+			},
+			expectedWarnings: []string{},
+		}),
+	)
+
+	var _ = DescribeTable("capi2mapi OpenStack convert CAPO images",
+		func(rootVolume *capov1.RootVolume, expectedImage string, expectedRootVolume *mapiv1alpha1.RootVolume) {
+			mapiMachine, _, err := FromMachineAndOpenStackMachineAndOpenStackCluster(openstackCAPIMachineBase.Build(), newOpenStackMachine(func(spec *capov1.OpenStackMachineSpec) {
+				spec.RootVolume = rootVolume
+			}), openstackCluster).ToMachine()
+			Expect(err).ToNot(HaveOccurred())
+
+			providerSpec := &mapiv1alpha1.OpenstackProviderSpec{}
+			Expect(yaml.Unmarshal(mapiMachine.Spec.ProviderSpec.Value.Raw, providerSpec)).To(Succeed())
+			Expect(providerSpec.Image).To(Equal(expectedImage))
+			Expect(providerSpec.RootVolume).To(Equal(expectedRootVolume))
+		},
+
+		Entry("Without a root volume", nil, "rhcos", nil),
+		Entry("With a root volume", &capov1.RootVolume{SizeGiB: 50}, "", &mapiv1alpha1.RootVolume{SourceUUID: "rhcos", Size: 50}),
+	)
+
+	var _ = DescribeTable("capi2mapi OpenStack convert CAPO port binding profiles",
+		func(profile *capov1.BindingProfile, expectedProfile map[string]string) {
+			mapiMachine, _, err := FromMachineAndOpenStackMachineAndOpenStackCluster(openstackCAPIMachineBase.Build(), newOpenStackMachine(func(spec *capov1.OpenStackMachineSpec) {
+				spec.Ports[0].VNICType = ptr.To("direct")
+				spec.Ports[0].Profile = profile
+			}), openstackCluster).ToMachine()
+			Expect(err).ToNot(HaveOccurred())
+
+			providerSpec := &mapiv1alpha1.OpenstackProviderSpec{}
+			Expect(yaml.Unmarshal(mapiMachine.Spec.ProviderSpec.Value.Raw, providerSpec)).To(Succeed())
+			Expect(providerSpec.Ports).ToNot(BeEmpty())
+			Expect(providerSpec.Ports[0].VNICType).To(Equal("direct"))
+			Expect(providerSpec.Ports[0].Profile).To(Equal(expectedProfile))
+		},
+
+		Entry("Without a binding profile", nil, nil),
+		Entry("With OVS hardware offload", &capov1.BindingProfile{OVSHWOffload: ptr.To(true)}, map[string]string{"capabilities": `["switchdev"]`}),
+		Entry("With OVS hardware offload disabled", &capov1.BindingProfile{OVSHWOffload: ptr.To(false)}, nil),
+		Entry("With a trusted virtual function", &capov1.BindingProfile{TrustedVF: ptr.To(true)}, map[string]string{"trusted": "true"}),
+		Entry("With both keys", &capov1.BindingProfile{OVSHWOffload: ptr.To(true), TrustedVF: ptr.To(false)}, map[string]string{"capabilities": `["switchdev"]`, "trusted": "false"}),
+	)
+})
@@ -0,0 +1,334 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capi2mapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	machinev1 "github.com/openshift/api/machine/v1"
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/ptr"
+	capibmv1 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	// These are the CAPIBM defaults, MAPP has different defaults so they are always set explicitly.
+	powerVSDefaultSystemType          = "s922"
+	powerVSDefaultMemoryGiB           = 2
+	powerVSDefaultSharedProcessors    = "0.25"
+	powerVSDefaultDedicatedProcessors = 1
+)
+
+var (
+	errCAPIMachinePowerVSMachinePowerVSClusterCannotBeNil            = errors.New("provided Machine, IBMPowerVSMachine and IBMPowerVSCluster can not be nil")
+	errCAPIMachineSetPowerVSMachineTemplatePowerVSClusterCannotBeNil = errors.New("provided MachineSet, IBMPowerVSMachineTemplate and IBMPowerVSCluster can not be nil")
+)
+
+// machineAndPowerVSMachineAndPowerVSCluster stores the details of a Cluster API Machine and IBMPowerVSMachine and IBMPowerVSCluster.
+type machineAndPowerVSMachineAndPowerVSCluster struct {
+	machine        *capiv1.Machine
+	powerVSMachine *capibmv1.IBMPowerVSMachine
+	powerVSCluster *capibmv1.IBMPowerVSCluster
+}
+
+// machineSetAndPowerVSMachineTemplateAndPowerVSCluster stores the details of a Cluster API MachineSet and IBMPowerVSMachineTemplate and IBMPowerVSCluster.
+type machineSetAndPowerVSMachineTemplateAndPowerVSCluster struct {
+	machineSet     *capiv1.MachineSet
+	template       *capibmv1.IBMPowerVSMachineTemplate
+	powerVSCluster *capibmv1.IBMPowerVSCluster
+	*machineAndPowerVSMachineAndPowerVSCluster
+}
+
+// FromMachineAndPowerVSMachineAndPowerVSCluster wraps a CAPI Machine and CAPIBM IBMPowerVSMachine and CAPIBM IBMPowerVSCluster into a capi2mapi MachineAndInfrastructureMachine.
+func FromMachineAndPowerVSMachineAndPowerVSCluster(m *capiv1.Machine, pm *capibmv1.IBMPowerVSMachine, pc *capibmv1.IBMPowerVSCluster) MachineAndInfrastructureMachine {
+	return &machineAndPowerVSMachineAndPowerVSCluster{machine: m, powerVSMachine: pm, powerVSCluster: pc}
+}
+
+// FromMachineSetAndPowerVSMachineTemplateAndPowerVSCluster wraps a CAPI MachineSet and CAPIBM IBMPowerVSMachineTemplate and CAPIBM IBMPowerVSCluster into a capi2mapi MachineSetAndMachineTemplate.
+func FromMachineSetAndPowerVSMachineTemplateAndPowerVSCluster(ms *capiv1.MachineSet, mts *capibmv1.IBMPowerVSMachineTemplate, pc *capibmv1.IBMPowerVSCluster) MachineSetAndMachineTemplate {
+	return &machineSetAndPowerVSMachineTemplateAndPowerVSCluster{
+		machineSet:     ms,
+		template:       mts,
+		powerVSCluster: pc,
+		machineAndPowerVSMachineAndPowerVSCluster: &machineAndPowerVSMachineAndPowerVSCluster{
+			machine: &capiv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      ms.Spec.Template.ObjectMeta.Labels,
+					Annotations: ms.Spec.Template.ObjectMeta.Annotations,
+				},
+				Spec: ms.Spec.Template.Spec,
+			},
+			powerVSMachine: &capibmv1.IBMPowerVSMachine{
+				Spec: mts.Spec.Template.Spec,
+			},
+			powerVSCluster: pc,
+		},
+	}
+}
+
+// toProviderSpec converts a capi2mapi MachineAndPowerVSMachineAndPowerVSCluster into a MAPI PowerVSMachineProviderConfig.
+func (m machineAndPowerVSMachineAndPowerVSCluster) toProviderSpec() (*machinev1.PowerVSMachineProviderConfig, []string, field.ErrorList) {
+	var (
+		warnings []string
+		errors   field.ErrorList
+	)
+
+	fldPath := field.NewPath("spec")
+
+	serviceInstance, errs := convertPowerVSServiceInstanceToMAPI(fldPath, m.powerVSMachine.Spec)
+	errors = append(errors, errs...)
+
+	var image machinev1.PowerVSResource
+
+	if m.powerVSMachine.Spec.Image == nil {
+		errors = append(errors, field.Required(fldPath.Child("image"), "image is required"))
+	} else {
+		image, errs = convertPowerVSResourceToMAPI(fldPath.Child("image"), *m.powerVSMachine.Spec.Image)
+		errors = append(errors, errs...)
+	}
+
+	network, errs := convertPowerVSResourceToMAPI(fldPath.Child("network"), m.powerVSMachine.Spec.Network)
+	errors = append(errors, errs...)
+
+	processorType := convertPowerVSProcessorTypeToMAPI(m.powerVSMachine.Spec.ProcessorType)
+
+	mappProviderConfig := machinev1.PowerVSMachineProviderConfig{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "PowerVSMachineProviderConfig",
+			APIVersion: "machine.openshift.io/v1",
+		},
+		// ObjectMeta - Only present because it's needed to form part of the runtime.RawExtension, not actually used by MAPP.
+		// UserDataSecret - Populated below.
+		// CredentialsSecret - TODO(OCPCLOUD-2713)
+		// LoadBalancers - CAPIBM registers control plane machines with the IBMPowerVSCluster load balancers instead.
+		ServiceInstance: serviceInstance,
+		Image:           image,
+		Network:         network,
+		KeyPairName:     m.powerVSMachine.Spec.SSHKey,
+		SystemType:      m.powerVSMachine.Spec.SystemType,
+		ProcessorType:   processorType,
+		Processors:      convertPowerVSProcessorsToMAPI(processorType, m.powerVSMachine.Spec.Processors),
+		MemoryGiB:       m.powerVSMachine.Spec.MemoryGiB,
+	}
+
+	if mappProviderConfig.SystemType == "" {
+		mappProviderConfig.SystemType = powerVSDefaultSystemType
+	}
+
+	if mappProviderConfig.MemoryGiB == 0 {
+		mappProviderConfig.MemoryGiB = powerVSDefaultMemoryGiB
+	}
+
+	userDataSecretName := ptr.Deref(m.machine.Spec.Bootstrap.DataSecretName, "")
+	if userDataSecretName != "" {
+		mappProviderConfig.UserDataSecret = &machinev1.PowerVSSecretReference{
+			Name: userDataSecretName,
+		}
+	}
+
+	// Below this line are fields not used from the CAPI IBMPowerVSMachine.
+
+	// ProviderID - Populated at a different level.
+
+	if m.machine.Spec.FailureDomain != nil {
+		// MAPP places machines using the service instance, it has no notion of a failure domain on the machine.
+		errors = append(errors, field.Invalid(fldPath.Child("failureDomain"), *m.machine.Spec.FailureDomain, "failureDomain is not supported, MAPI places PowerVS machines using the service instance"))
+	}
+
+	if m.powerVSMachine.Spec.ImageRef != nil {
+		// MAPP does not support importing images through an IBMPowerVSImage.
+		errors = append(errors, field.Invalid(fldPath.Child("imageRef"), m.powerVSMachine.Spec.ImageRef, "imageRef is not supported"))
+	}
+
+	if len(errors) > 0 {
+		return nil, warnings, errors
+	}
+
+	return &mappProviderConfig, warnings, nil
+}
+
+// ToMachine converts a capi2mapi MachineAndPowerVSMachineAndPowerVSCluster into a MAPI Machine.
+func (m machineAndPowerVSMachineAndPowerVSCluster) ToMachine() (*mapiv1.Machine, []string, error) {
+	if m.machine == nil || m.powerVSMachine == nil || m.powerVSCluster == nil {
+		return nil, nil, errCAPIMachinePowerVSMachinePowerVSClusterCannotBeNil
+	}
+
+	var (
+		errors   field.ErrorList
+		warnings []string
+	)
+
+	mappSpec, warn, err := m.toProviderSpec()
+	if err != nil {
+		errors = append(errors, err...)
+	}
+
+	powerVSRawExt, errRaw := RawExtensionFromPowerVSProviderSpec(mappSpec)
+	if errRaw != nil {
+		return nil, nil, fmt.Errorf("unable to convert PowerVS providerSpec to raw extension: %w", errRaw)
+	}
+
+	warnings = append(warnings, warn...)
+
+	mapiMachine, err := fromCAPIMachineToMAPIMachine(m.machine)
+	if err != nil {
+		errors = append(errors, err...)
+	}
+
+	mapiMachine.Spec.ProviderSpec.Value = powerVSRawExt
+
+	errors = append(errors, restorePreservedProviderSpecFields(mapiMachine)...)
+
+	if len(errors) > 0 {
+		return nil, warnings, errors.ToAggregate()
+	}
+
+	return mapiMachine, warnings, nil
+}
+
+// ToMachineSet converts a capi2mapi MachineSetAndPowerVSMachineTemplateAndPowerVSCluster into a MAPI MachineSet.
+func (m machineSetAndPowerVSMachineTemplateAndPowerVSCluster) ToMachineSet() (*mapiv1.MachineSet, []string, error) {
+	if m.machineSet == nil || m.template == nil || m.powerVSCluster == nil || m.machineAndPowerVSMachineAndPowerVSCluster == nil {
+		return nil, nil, errCAPIMachineSetPowerVSMachineTemplatePowerVSClusterCannotBeNil
+	}
+
+	var (
+		errors   []error
+		warnings []string
+	)
+
+	// Run the full ToMachine conversion so that we can check for
+	// any Machine level conversion errors in the spec translation.
+	mappMachine, warn, err := m.ToMachine()
+	if err != nil {
+		errors = append(errors, err)
+	}
+
+	warnings = append(warnings, warn...)
+
+	mapiMachineSet, err := fromCAPIMachineSetToMAPIMachineSet(m.machineSet)
+	if err != nil {
+		errors = append(errors, err)
+	}
+
+	if len(errors) > 0 {
+		return nil, warnings, utilerrors.NewAggregate(errors)
+	}
+
+	mapiMachineSet.Spec.Template.Spec = mappMachine.Spec
+
+	// Copy the labels and annotations from the Machine to the template.
+	mapiMachineSet.Spec.Template.ObjectMeta.Annotations = mappMachine.ObjectMeta.Annotations
+	mapiMachineSet.Spec.Template.ObjectMeta.Labels = mappMachine.ObjectMeta.Labels
+
+	return mapiMachineSet, warnings, nil
+}
+
+// Conversion helpers.
+
+// RawExtensionFromPowerVSProviderSpec marshals the PowerVS machine provider spec.
+func RawExtensionFromPowerVSProviderSpec(spec *machinev1.PowerVSMachineProviderConfig) (*runtime.RawExtension, error) {
+	if spec == nil {
+		return &runtime.RawExtension{}, nil
+	}
+
+	rawBytes, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling providerSpec: %w", err)
+	}
+
+	return &runtime.RawExtension{
+		Raw: rawBytes,
+	}, nil
+}
+
+// convertPowerVSServiceInstanceToMAPI converts the CAPIBM service instance to a MAPI resource reference.
+// The deprecated service instance ID is only used when the service instance is not set.
+func convertPowerVSServiceInstanceToMAPI(fldPath *field.Path, spec capibmv1.IBMPowerVSMachineSpec) (machinev1.PowerVSResource, field.ErrorList) {
+	if spec.ServiceInstance != nil {
+		return convertPowerVSResourceToMAPI(fldPath.Child("serviceInstance"), *spec.ServiceInstance)
+	}
+
+	if spec.ServiceInstanceID != "" {
+		return machinev1.PowerVSResource{Type: machinev1.PowerVSResourceTypeID, ID: ptr.To(spec.ServiceInstanceID)}, nil
+	}
+
+	// CAPIBM would create a service instance on demand, MAPP requires an existing one.
+	return machinev1.PowerVSResource{}, field.ErrorList{field.Required(fldPath.Child("serviceInstance"), "serviceInstance is required")}
+}
+
+// convertPowerVSResourceToMAPI converts a CAPIBM resource reference to a MAPI resource reference.
+// CAPIBM infers the kind of reference from the field that is set, MAPP needs it to be named by the type.
+func convertPowerVSResourceToMAPI(fldPath *field.Path, ref capibmv1.IBMPowerVSResourceReference) (machinev1.PowerVSResource, field.ErrorList) {
+	var resources []machinev1.PowerVSResource
+
+	if ref.ID != nil {
+		resources = append(resources, machinev1.PowerVSResource{Type: machinev1.PowerVSResourceTypeID, ID: ptr.To(*ref.ID)})
+	}
+
+	if ref.Name != nil {
+		resources = append(resources, machinev1.PowerVSResource{Type: machinev1.PowerVSResourceTypeName, Name: ptr.To(*ref.Name)})
+	}
+
+	if ref.RegEx != nil {
+		resources = append(resources, machinev1.PowerVSResource{Type: machinev1.PowerVSResourceTypeRegEx, RegEx: ptr.To(*ref.RegEx)})
+	}
+
+	switch len(resources) {
+	case 0:
+		return machinev1.PowerVSResource{}, field.ErrorList{field.Required(fldPath, "one of id, name or regex is required")}
+	case 1:
+		return resources[0], nil
+	default:
+		return machinev1.PowerVSResource{}, field.ErrorList{field.Invalid(fldPath, ref, "only one of id, name or regex may be set")}
+	}
+}
+
+// convertPowerVSProcessorTypeToMAPI converts the CAPIBM processor type to its MAPI equivalent.
+// Both default to shared processors, but the default is always set explicitly.
+func convertPowerVSProcessorTypeToMAPI(processorType capibmv1.PowerVSProcessorType) machinev1.PowerVSProcessorType {
+	switch processorType {
+	case capibmv1.PowerVSProcessorTypeDedicated:
+		return machinev1.PowerVSProcessorTypeDedicated
+	case capibmv1.PowerVSProcessorTypeCapped:
+		return machinev1.PowerVSProcessorTypeCapped
+	default:
+		return machinev1.PowerVSProcessorTypeShared
+	}
+}
+
+// convertPowerVSProcessorsToMAPI converts the CAPIBM processors to MAPI processors.
+// CAPIBM and MAPP default to a different number of shared processors, so the CAPIBM default is always set explicitly.
+func convertPowerVSProcessorsToMAPI(processorType machinev1.PowerVSProcessorType, processors intstr.IntOrString) intstr.IntOrString {
+	if processors.Type == intstr.Int && processors.IntVal == 0 || processors.Type == intstr.String && processors.StrVal == "" {
+		if processorType == machinev1.PowerVSProcessorTypeDedicated {
+			return intstr.FromInt32(powerVSDefaultDedicatedProcessors)
+		}
+
+		return intstr.FromString(powerVSDefaultSharedProcessors)
+	}
+
+	return processors
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capi2mapi_test
+
+import (
+	"strconv"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	fuzz "github.com/google/gofuzz"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/capi2mapi"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/mapi2capi"
+	conversiontest "github.com/openshift/cluster-capi-operator/pkg/conversion/test/fuzz"
+
+	runtimeserializer "k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	capibmv1 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	powerVSMachineKind  = "IBMPowerVSMachine"
+	powerVSTemplateKind = "IBMPowerVSMachineTemplate"
+)
+
+var _ = Describe("PowerVS Fuzz (capi2mapi)", func() {
+	infra := &configv1.Infrastructure{
+		Spec: configv1.InfrastructureSpec{},
+		Status: configv1.InfrastructureStatus{
+			InfrastructureName: "sample-cluster-name",
+			PlatformStatus: &configv1.PlatformStatus{
+				Type: configv1.PowerVSPlatformType,
+			},
+		},
+	}
+
+	infraCluster := &capibmv1.IBMPowerVSCluster{}
+
+	Context("IBMPowerVSMachine Conversion", func() {
+		fromMachineAndPowerVSMachineAndPowerVSCluster := func(machine *capiv1.Machine, infraMachine client.Object, infraCluster client.Object) capi2mapi.MachineAndInfrastructureMachine {
+			powerVSMachine, ok := infraMachine.(*capibmv1.IBMPowerVSMachine)
+			Expect(ok).To(BeTrue(), "input infra machine should be of type %T, got %T", &capibmv1.IBMPowerVSMachine{}, infraMachine)
+
+			powerVSCluster, ok := infraCluster.(*capibmv1.IBMPowerVSCluster)
+			Expect(ok).To(BeTrue(), "input infra cluster should be of type %T, got %T", &capibmv1.IBMPowerVSCluster{}, infraCluster)
+
+			return capi2mapi.FromMachineAndPowerVSMachineAndPowerVSCluster(machine, powerVSMachine, powerVSCluster)
+		}
+
+		conversiontest.CAPI2MAPIMachineRoundTripFuzzTest(
+			scheme,
+			infra,
+			infraCluster,
+			&capibmv1.IBMPowerVSMachine{},
+			mapi2capi.FromPowerVSMachineAndInfra,
+			fromMachineAndPowerVSMachineAndPowerVSCluster,
+			conversiontest.ObjectMetaFuzzerFuncs(capiNamespace),
+			capiMachineWithoutFailureDomainFuzzerFuncs(conversiontest.CAPIMachineFuzzerFuncs(powerVSProviderIDFuzzer, powerVSMachineKind, capibmv1.GroupVersion.String(), infra.Status.InfrastructureName)),
+			powerVSMachineFuzzerFuncs,
+		)
+	})
+
+	Context("IBMPowerVSMachineSet Conversion", func() {
+		fromMachineSetAndPowerVSMachineTemplateAndPowerVSCluster := func(machineSet *capiv1.MachineSet, infraMachineTemplate client.Object, infraCluster client.Object) capi2mapi.MachineSetAndMachineTemplate {
+			powerVSMachineTemplate, ok := infraMachineTemplate.(*capibmv1.IBMPowerVSMachineTemplate)
+			Expect(ok).To(BeTrue(), "input infra machine template should be of type %T, got %T", &capibmv1.IBMPowerVSMachineTemplate{}, infraMachineTemplate)
+
+			powerVSCluster, ok := infraCluster.(*capibmv1.IBMPowerVSCluster)
+			Expect(ok).To(BeTrue(), "input infra cluster should be of type %T, got %T", &capibmv1.IBMPowerVSCluster{}, infraCluster)
+
+			return capi2mapi.FromMachineSetAndPowerVSMachineTemplateAndPowerVSCluster(machineSet, powerVSMachineTemplate, powerVSCluster)
+		}
+
+		conversiontest.CAPI2MAPIMachineSetRoundTripFuzzTest(
+			scheme,
+			infra,
+			infraCluster,
+			&capibmv1.IBMPowerVSMachineTemplate{},
+			mapi2capi.FromPowerVSMachineSetAndInfra,
+			fromMachineSetAndPowerVSMachineTemplateAndPowerVSCluster,
+			conversiontest.ObjectMetaFuzzerFuncs(capiNamespace),
+			capiMachineWithoutFailureDomainFuzzerFuncs(conversiontest.CAPIMachineFuzzerFuncs(powerVSProviderIDFuzzer, powerVSTemplateKind, capibmv1.GroupVersion.String(), infra.Status.InfrastructureName)),
+			conversiontest.CAPIMachineSetFuzzerFuncs(powerVSTemplateKind, capibmv1.GroupVersion.String(), infra.Status.InfrastructureName),
+			powerVSMachineFuzzerFuncs,
+			powerVSMachineTemplateFuzzerFuncs,
+		)
+	})
+})
+
+func powerVSProviderIDFuzzer(c fuzz.Continue) string {
+	return "ibmpowervs://" + strings.ReplaceAll(c.RandString(), "/", "")
+}
+
+func powerVSMachineFuzzerFuncs(codecs runtimeserializer.CodecFactory) []interface{} {
+	return []interface{}{
+		func(ref *capibmv1.IBMPowerVSResourceReference, c fuzz.Continue) {
+			c.FuzzNoCustom(ref)
+
+			// MAPP references a resource using exactly one of the fields.
+			value := c.RandString()
+			*ref = capibmv1.IBMPowerVSResourceReference{}
+
+			switch c.Intn(3) {
+			case 0:
+				ref.ID = &value
+			case 1:
+				ref.Name = &value
+			case 2:
+				ref.RegEx = &value
+			}
+		},
+		func(spec *capibmv1.IBMPowerVSMachineSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(spec)
+
+			// MAPP requires a service instance and an image.
+			if spec.ServiceInstance == nil {
+				spec.ServiceInstance = &capibmv1.IBMPowerVSResourceReference{}
+				c.Fuzz(spec.ServiceInstance)
+			}
+
+			if spec.Image == nil {
+				spec.Image = &capibmv1.IBMPowerVSResourceReference{}
+				c.Fuzz(spec.Image)
+			}
+
+			spec.ServiceInstanceID = ""
+
+			// Dedicated processors must be whole numbers.
+			spec.ProcessorType = []capibmv1.PowerVSProcessorType{"", capibmv1.PowerVSProcessorTypeDedicated, capibmv1.PowerVSProcessorTypeShared, capibmv1.PowerVSProcessorTypeCapped}[c.Intn(4)]
+			if spec.ProcessorType == capibmv1.PowerVSProcessorTypeDedicated {
+				spec.Processors = intstr.FromInt32(c.Int31n(15) + 1)
+			} else {
+				spec.Processors = intstr.FromString(strconv.FormatFloat(float64(c.Intn(60)+1)/4, 'f', -1, 64))
+			}
+
+			// Clear fields that are not supported.
+			spec.ImageRef = nil
+		},
+		func(m *capibmv1.IBMPowerVSMachine, c fuzz.Continue) {
+			c.FuzzNoCustom(m)
+
+			// Ensure the type meta is set correctly.
+			m.TypeMeta.APIVersion = capibmv1.GroupVersion.String()
+			m.TypeMeta.Kind = powerVSMachineKind
+		},
+	}
+}
+
+func powerVSMachineTemplateFuzzerFuncs(codecs runtimeserializer.CodecFactory) []interface{} {
+	return []interface{}{
+		func(m *capibmv1.IBMPowerVSMachineTemplate, c fuzz.Continue) {
+			c.FuzzNoCustom(m)
+
+			// Ensure the type meta is set correctly.
+			m.TypeMeta.APIVersion = capibmv1.GroupVersion.String()
+			m.TypeMeta.Kind = powerVSTemplateKind
+		},
+	}
+}
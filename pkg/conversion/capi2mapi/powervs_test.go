@@ -0,0 +1,160 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capi2mapi
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	machinev1 "github.com/openshift/api/machine/v1"
+	capibuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/cluster-api/core/v1beta1"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/test/matchers"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	capibmv1 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
+	"sigs.k8s.io/yaml"
+)
+
+var _ = Describe("capi2mapi PowerVS conversion", func() {
+	var (
+		powerVSCAPIMachineBase = capibuilder.Machine()
+
+		powerVSCluster = &capibmv1.IBMPowerVSCluster{}
+
+		newPowerVSMachine = func(modify func(*capibmv1.IBMPowerVSMachineSpec)) *capibmv1.IBMPowerVSMachine {
+			powerVSMachine := &capibmv1.IBMPowerVSMachine{
+				Spec: capibmv1.IBMPowerVSMachineSpec{
+					ServiceInstance: &capibmv1.IBMPowerVSResourceReference{ID: ptr.To("e449d86e-c3a0-4c07-959e-8557fdf55482")},
+					SSHKey:          "test-cluster-key",
+					Image:           &capibmv1.IBMPowerVSResourceReference{Name: ptr.To("rhcos-test-cluster")},
+					SystemType:      "s922",
+					ProcessorType:   capibmv1.PowerVSProcessorTypeShared,
+					Processors:      intstr.FromString("0.5"),
+					MemoryGiB:       32,
+					Network:         capibmv1.IBMPowerVSResourceReference{RegEx: ptr.To("^DHCPSERVER.*test-cluster.*_Private$")},
+				},
+			}
+
+			if modify != nil {
+				modify(&powerVSMachine.Spec)
+			}
+
+			return powerVSMachine
+		}
+	)
+
+	type powerVSCAPI2MAPIMachineConversionInput struct {
+		machineBuilder   capibuilder.MachineBuilder
+		powerVSMachine   *capibmv1.IBMPowerVSMachine
+		expectedErrors   []string
+		expectedWarnings []string
+	}
+
+	var _ = DescribeTable("capi2mapi PowerVS convert CAPI Machine/InfraMachine/InfraCluster to a MAPI Machine",
+		func(in powerVSCAPI2MAPIMachineConversionInput) {
+			_, warns, err := FromMachineAndPowerVSMachineAndPowerVSCluster(
+				in.machineBuilder.Build(),
+				in.powerVSMachine,
+				powerVSCluster,
+			).ToMachine()
+			Expect(err).To(matchers.ConsistOfMatchErrorSubstrings(in.expectedErrors),
+				"should match expected errors while converting PowerVS CAPI resources to MAPI Machine")
+			Expect(warns).To(matchers.ConsistOfSubstrings(in.expectedWarnings),
+				"should match expected warnings while converting PowerVS CAPI resources to MAPI Machine")
+		},
+
+		// Base Case.
+		Entry("With a Base configuration", powerVSCAPI2MAPIMachineConversionInput{
+			machineBuilder:   powerVSCAPIMachineBase,
+			powerVSMachine:   newPowerVSMachine(nil),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a deprecated service instance ID", powerVSCAPI2MAPIMachineConversionInput{
+			machineBuilder: powerVSCAPIMachineBase,
+			powerVSMachine: newPowerVSMachine(func(spec *capibmv1.IBMPowerVSMachineSpec) {
+				spec.ServiceInstance = nil
+				spec.ServiceInstanceID = "e449d86e-c3a0-4c07-959e-8557fdf55482"
+			}),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("Without a service instance", powerVSCAPI2MAPIMachineConversionInput{
+			machineBuilder: powerVSCAPIMachineBase,
+			powerVSMachine: newPowerVSMachine(func(spec *capibmv1.IBMPowerVSMachineSpec) {
+				spec.ServiceInstance = nil
+			}),
+			expectedErrors:   []string{"spec.serviceInstance: Required value: serviceInstance is required"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("Without an image", powerVSCAPI2MAPIMachineConversionInput{
+			machineBuilder: powerVSCAPIMachineBase,
+			powerVSMachine: newPowerVSMachine(func(spec *capibmv1.IBMPowerVSMachineSpec) {
+				spec.Image = nil
+			}),
+			expectedErrors:   []string{"spec.image: Required value: image is required"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a network referenced by multiple fields", powerVSCAPI2MAPIMachineConversionInput{
+			machineBuilder: powerVSCAPIMachineBase,
+			powerVSMachine: newPowerVSMachine(func(spec *capibmv1.IBMPowerVSMachineSpec) {
+				spec.Network.Name = ptr.To("test-network")
+			}),
+			expectedErrors:   []string{"spec.network: Invalid value: v1beta2.IBMPowerVSResourceReference{ID:(*string)(nil)"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a failure domain", powerVSCAPI2MAPIMachineConversionInput{
+			machineBuilder:   powerVSCAPIMachineBase.WithFailureDomain(ptr.To("test-zone")),
+			powerVSMachine:   newPowerVSMachine(nil),
+			expectedErrors:   []string{"spec.failureDomain: Invalid value: \"test-zone\": failureDomain is not supported, MAPI places PowerVS machines using the service instance"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With unsupported fields", powerVSCAPI2MAPIMachineConversionInput{
+			machineBuilder: powerVSCAPIMachineBase,
+			powerVSMachine: newPowerVSMachine(func(spec *capibmv1.IBMPowerVSMachineSpec) {
+				spec.ImageRef = &corev1.LocalObjectReference{Name: "rhcos-image"}
+			}),
+			expectedErrors:   []string{"spec.imageRef: Invalid value: "},
+			expectedWarnings: []string{},
+		}),
+	)
+
+	var _ = DescribeTable("capi2mapi PowerVS convert CAPIBM processors",
+		func(processorType capibmv1.PowerVSProcessorType, processors intstr.IntOrString, expectedProcessorType machinev1.PowerVSProcessorType, expectedProcessors intstr.IntOrString) {
+			mapiMachine, _, err := FromMachineAndPowerVSMachineAndPowerVSCluster(powerVSCAPIMachineBase.Build(), newPowerVSMachine(func(spec *capibmv1.IBMPowerVSMachineSpec) {
+				spec.ProcessorType = processorType
+				spec.Processors = processors
+			}), powerVSCluster).ToMachine()
+			Expect(err).ToNot(HaveOccurred())
+
+			providerSpec := &machinev1.PowerVSMachineProviderConfig{}
+			Expect(yaml.Unmarshal(mapiMachine.Spec.ProviderSpec.Value.Raw, providerSpec)).To(Succeed())
+			Expect(providerSpec.ProcessorType).To(Equal(expectedProcessorType))
+			Expect(providerSpec.Processors).To(Equal(expectedProcessors))
+		},
+
+		Entry("With the defaults", capibmv1.PowerVSProcessorType(""), intstr.IntOrString{}, machinev1.PowerVSProcessorTypeShared, intstr.FromString("0.25")),
+		Entry("With the dedicated default", capibmv1.PowerVSProcessorTypeDedicated, intstr.IntOrString{}, machinev1.PowerVSProcessorTypeDedicated, intstr.FromInt32(1)),
+		Entry("With dedicated processors", capibmv1.PowerVSProcessorTypeDedicated, intstr.FromInt32(4), machinev1.PowerVSProcessorTypeDedicated, intstr.FromInt32(4)),
+		Entry("With capped processors", capibmv1.PowerVSProcessorTypeCapped, intstr.FromString("0.75"), machinev1.PowerVSProcessorTypeCapped, intstr.FromString("0.75")),
+	)
+})
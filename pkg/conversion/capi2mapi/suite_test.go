@@ -26,6 +26,11 @@ import (
 	kubescheme "k8s.io/client-go/kubernetes/scheme"
 
 	capav1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	capzv1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	capgv1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	capibmv1 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
+	capov1 "sigs.k8s.io/cluster-api-provider-openstack/api/v1beta1"
+	capvv1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
 	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
 )
 
@@ -42,6 +47,26 @@ func init() {
 	if err := capav1.AddToScheme(scheme); err != nil {
 		panic(fmt.Sprintf("failed to add aws scheme: %v", err))
 	}
+
+	if err := capzv1.AddToScheme(scheme); err != nil {
+		panic(fmt.Sprintf("failed to add azure scheme: %v", err))
+	}
+
+	if err := capgv1.AddToScheme(scheme); err != nil {
+		panic(fmt.Sprintf("failed to add gcp scheme: %v", err))
+	}
+
+	if err := capov1.AddToScheme(scheme); err != nil {
+		panic(fmt.Sprintf("failed to add openstack scheme: %v", err))
+	}
+
+	if err := capvv1.AddToScheme(scheme); err != nil {
+		panic(fmt.Sprintf("failed to add vsphere scheme: %v", err))
+	}
+
+	if err := capibmv1.AddToScheme(scheme); err != nil {
+		panic(fmt.Sprintf("failed to add powervs scheme: %v", err))
+	}
 }
 
 func TestAPIs(t *testing.T) {
@@ -0,0 +1,478 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capi2mapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/ptr"
+	capvv1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+var (
+	errCAPIMachineVSphereMachineVSphereClusterCannotBeNil            = errors.New("provided Machine, VSphereMachine and VSphereCluster can not be nil")
+	errCAPIMachineSetVSphereMachineTemplateVSphereClusterCannotBeNil = errors.New("provided MachineSet, VSphereMachineTemplate and VSphereCluster can not be nil")
+)
+
+// machineAndVSphereMachineAndVSphereCluster stores the details of a Cluster API Machine and VSphereMachine and VSphereCluster.
+type machineAndVSphereMachineAndVSphereCluster struct {
+	machine        *capiv1.Machine
+	vsphereMachine *capvv1.VSphereMachine
+	vsphereCluster *capvv1.VSphereCluster
+}
+
+// machineSetAndVSphereMachineTemplateAndVSphereCluster stores the details of a Cluster API MachineSet and VSphereMachineTemplate and VSphereCluster.
+type machineSetAndVSphereMachineTemplateAndVSphereCluster struct {
+	machineSet     *capiv1.MachineSet
+	template       *capvv1.VSphereMachineTemplate
+	vsphereCluster *capvv1.VSphereCluster
+	*machineAndVSphereMachineAndVSphereCluster
+}
+
+// FromMachineAndVSphereMachineAndVSphereCluster wraps a CAPI Machine and CAPV VSphereMachine and CAPV VSphereCluster into a capi2mapi MachineAndInfrastructureMachine.
+func FromMachineAndVSphereMachineAndVSphereCluster(m *capiv1.Machine, vm *capvv1.VSphereMachine, vc *capvv1.VSphereCluster) MachineAndInfrastructureMachine {
+	return &machineAndVSphereMachineAndVSphereCluster{machine: m, vsphereMachine: vm, vsphereCluster: vc}
+}
+
+// FromMachineSetAndVSphereMachineTemplateAndVSphereCluster wraps a CAPI MachineSet and CAPV VSphereMachineTemplate and CAPV VSphereCluster into a capi2mapi MachineSetAndMachineTemplate.
+func FromMachineSetAndVSphereMachineTemplateAndVSphereCluster(ms *capiv1.MachineSet, mts *capvv1.VSphereMachineTemplate, vc *capvv1.VSphereCluster) MachineSetAndMachineTemplate {
+	return &machineSetAndVSphereMachineTemplateAndVSphereCluster{
+		machineSet:     ms,
+		template:       mts,
+		vsphereCluster: vc,
+		machineAndVSphereMachineAndVSphereCluster: &machineAndVSphereMachineAndVSphereCluster{
+			machine: &capiv1.Machine{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels:      ms.Spec.Template.ObjectMeta.Labels,
+					Annotations: ms.Spec.Template.ObjectMeta.Annotations,
+				},
+				Spec: ms.Spec.Template.Spec,
+			},
+			vsphereMachine: &capvv1.VSphereMachine{
+				Spec: mts.Spec.Template.Spec,
+			},
+			vsphereCluster: vc,
+		},
+	}
+}
+
+// toProviderSpec converts a capi2mapi MachineAndVSphereMachineAndVSphereCluster into a MAPI VSphereMachineProviderSpec.
+func (m machineAndVSphereMachineAndVSphereCluster) toProviderSpec() (*mapiv1.VSphereMachineProviderSpec, []string, field.ErrorList) {
+	var (
+		warnings []string
+		errors   field.ErrorList
+	)
+
+	fldPath := field.NewPath("spec")
+
+	devices, errs := convertVSphereNetworkDevicesToMAPI(fldPath.Child("network", "devices"), m.vsphereMachine.Spec.Network.Devices)
+	errors = append(errors, errs...)
+
+	mapvProviderSpec := mapiv1.VSphereMachineProviderSpec{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "VSphereMachineProviderSpec",
+			APIVersion: "machine.openshift.io/v1beta1",
+		},
+		// ObjectMeta - Only present because it's needed to form part of the runtime.RawExtension, not actually used by MAPV.
+		// UserDataSecret - Populated below.
+		// CredentialsSecret - TODO(OCPCLOUD-2713)
+		Template:          m.vsphereMachine.Spec.Template,
+		Workspace:         convertVSphereWorkspaceToMAPI(m.vsphereMachine.Spec.VirtualMachineCloneSpec),
+		Network:           mapiv1.NetworkSpec{Devices: devices},
+		NumCPUs:           m.vsphereMachine.Spec.NumCPUs,
+		NumCoresPerSocket: m.vsphereMachine.Spec.NumCoresPerSocket,
+		MemoryMiB:         m.vsphereMachine.Spec.MemoryMiB,
+		DiskGiB:           m.vsphereMachine.Spec.DiskGiB,
+		TagIDs:            convertVSphereTagIDsToMAPI(m.vsphereMachine.Spec.TagIDs),
+		Snapshot:          m.vsphereMachine.Spec.Snapshot,
+		CloneMode:         convertVSphereCloneModeToMAPI(m.vsphereMachine.Spec.CloneMode),
+	}
+
+	userDataSecretName := ptr.Deref(m.machine.Spec.Bootstrap.DataSecretName, "")
+	if userDataSecretName != "" {
+		mapvProviderSpec.UserDataSecret = &corev1.LocalObjectReference{
+			Name: userDataSecretName,
+		}
+	}
+
+	// Below this line are fields not used from the CAPI VSphereMachine.
+
+	// ProviderID - Populated at a different level.
+
+	// FailureDomain - MAPV places machines using the workspace, it has no notion of a failure domain on the machine.
+	// The workspace of a machine in a failure domain already holds the failure domain topology, so it is dropped.
+	errors = append(errors, validateVSphereFailureDomain(fldPath, m.machine.Spec.FailureDomain, m.vsphereMachine.Spec)...)
+
+	// There are quite a few unsupported fields, so break them out for now.
+	errors = append(errors, handleUnsupportedVSphereMachineFields(fldPath, m.vsphereMachine.Spec)...)
+
+	if len(errors) > 0 {
+		return nil, warnings, errors
+	}
+
+	return &mapvProviderSpec, warnings, nil
+}
+
+// ToMachine converts a capi2mapi MachineAndVSphereMachineAndVSphereCluster into a MAPI Machine.
+func (m machineAndVSphereMachineAndVSphereCluster) ToMachine() (*mapiv1.Machine, []string, error) {
+	if m.machine == nil || m.vsphereMachine == nil || m.vsphereCluster == nil {
+		return nil, nil, errCAPIMachineVSphereMachineVSphereClusterCannotBeNil
+	}
+
+	var (
+		errors   field.ErrorList
+		warnings []string
+	)
+
+	mapvSpec, warn, err := m.toProviderSpec()
+	if err != nil {
+		errors = append(errors, err...)
+	}
+
+	vsphereRawExt, errRaw := RawExtensionFromVSphereProviderSpec(mapvSpec)
+	if errRaw != nil {
+		return nil, nil, fmt.Errorf("unable to convert vSphere providerSpec to raw extension: %w", errRaw)
+	}
+
+	warnings = append(warnings, warn...)
+
+	mapiMachine, err := fromCAPIMachineToMAPIMachine(m.machine)
+	if err != nil {
+		errors = append(errors, err...)
+	}
+
+	mapiMachine.Spec.ProviderSpec.Value = vsphereRawExt
+
+	errors = append(errors, restorePreservedProviderSpecFields(mapiMachine)...)
+
+	if len(errors) > 0 {
+		return nil, warnings, errors.ToAggregate()
+	}
+
+	return mapiMachine, warnings, nil
+}
+
+// ToMachineSet converts a capi2mapi MachineSetAndVSphereMachineTemplateAndVSphereCluster into a MAPI MachineSet.
+func (m machineSetAndVSphereMachineTemplateAndVSphereCluster) ToMachineSet() (*mapiv1.MachineSet, []string, error) {
+	if m.machineSet == nil || m.template == nil || m.vsphereCluster == nil || m.machineAndVSphereMachineAndVSphereCluster == nil {
+		return nil, nil, errCAPIMachineSetVSphereMachineTemplateVSphereClusterCannotBeNil
+	}
+
+	var (
+		errors   []error
+		warnings []string
+	)
+
+	// Run the full ToMachine conversion so that we can check for
+	// any Machine level conversion errors in the spec translation.
+	mapvMachine, warn, err := m.ToMachine()
+	if err != nil {
+		errors = append(errors, err)
+	}
+
+	warnings = append(warnings, warn...)
+
+	mapiMachineSet, err := fromCAPIMachineSetToMAPIMachineSet(m.machineSet)
+	if err != nil {
+		errors = append(errors, err)
+	}
+
+	if len(errors) > 0 {
+		return nil, warnings, utilerrors.NewAggregate(errors)
+	}
+
+	mapiMachineSet.Spec.Template.Spec = mapvMachine.Spec
+
+	// Copy the labels and annotations from the Machine to the template.
+	mapiMachineSet.Spec.Template.ObjectMeta.Annotations = mapvMachine.ObjectMeta.Annotations
+	mapiMachineSet.Spec.Template.ObjectMeta.Labels = mapvMachine.ObjectMeta.Labels
+
+	return mapiMachineSet, warnings, nil
+}
+
+// Conversion helpers.
+
+// RawExtensionFromVSphereProviderSpec marshals the vSphere machine provider spec.
+func RawExtensionFromVSphereProviderSpec(spec *mapiv1.VSphereMachineProviderSpec) (*runtime.RawExtension, error) {
+	if spec == nil {
+		return &runtime.RawExtension{}, nil
+	}
+
+	rawBytes, err := json.Marshal(spec)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling providerSpec: %w", err)
+	}
+
+	return &runtime.RawExtension{
+		Raw: rawBytes,
+	}, nil
+}
+
+// convertVSphereWorkspaceToMAPI builds the MAPI workspace from the CAPV placement fields.
+func convertVSphereWorkspaceToMAPI(spec capvv1.VirtualMachineCloneSpec) *mapiv1.Workspace {
+	workspace := mapiv1.Workspace{
+		Server:       spec.Server,
+		Datacenter:   spec.Datacenter,
+		Folder:       spec.Folder,
+		Datastore:    spec.Datastore,
+		ResourcePool: spec.ResourcePool,
+	}
+
+	if workspace == (mapiv1.Workspace{}) {
+		return nil
+	}
+
+	return &workspace
+}
+
+// convertVSphereCloneModeToMAPI converts the CAPV clone mode to its MAPI equivalent.
+// CAPV defaults to a linked clone whereas MAPV defaults to a full clone, so the default is always set explicitly.
+func convertVSphereCloneModeToMAPI(cloneMode capvv1.CloneMode) mapiv1.CloneMode {
+	if cloneMode == capvv1.FullClone {
+		return mapiv1.FullClone
+	}
+
+	return mapiv1.LinkedClone
+}
+
+func convertVSphereTagIDsToMAPI(capvTagIDs []string) []string {
+	if len(capvTagIDs) == 0 {
+		return nil
+	}
+
+	return append([]string{}, capvTagIDs...)
+}
+
+// convertVSphereNetworkDevicesToMAPI converts the CAPV network devices to MAPI network devices.
+// MAPV only uses DHCP when a device has no static or pool addresses, and only has a single gateway per device.
+func convertVSphereNetworkDevicesToMAPI(fldPath *field.Path, capvDevices []capvv1.NetworkDeviceSpec) ([]mapiv1.NetworkDeviceSpec, field.ErrorList) {
+	var (
+		mapiDevices []mapiv1.NetworkDeviceSpec
+		errs        field.ErrorList
+	)
+
+	for i, device := range capvDevices {
+		devicePath := fldPath.Index(i)
+		hasAddresses := len(device.IPAddrs) > 0 || len(device.AddressesFromPools) > 0
+
+		if device.DHCP4 && hasAddresses {
+			errs = append(errs, field.Invalid(devicePath.Child("dhcp4"), device.DHCP4, "dhcp4 cannot be combined with ipAddrs or addressesFromPools, MAPI only uses DHCP when a device has no addresses"))
+		}
+
+		if !device.DHCP4 && !hasAddresses {
+			errs = append(errs, field.Invalid(devicePath.Child("dhcp4"), device.DHCP4, "dhcp4 must be enabled when a device has no ipAddrs or addressesFromPools, MAPI always uses DHCP for such devices"))
+		}
+
+		if device.DHCP6 {
+			errs = append(errs, field.Invalid(devicePath.Child("dhcp6"), device.DHCP6, "dhcp6 is not supported"))
+		}
+
+		gateway := device.Gateway4
+		if device.Gateway6 != "" {
+			if gateway != "" {
+				errs = append(errs, field.Invalid(devicePath.Child("gateway6"), device.Gateway6, "gateway6 cannot be set alongside gateway4, MAPI only supports a single gateway per device"))
+			}
+
+			gateway = device.Gateway6
+		}
+
+		errs = append(errs, handleUnsupportedVSphereNetworkDeviceFields(devicePath, device)...)
+		errs = append(errs, validateVSphereNetworkDeviceAddresses(devicePath, device.IPAddrs, device.Nameservers)...)
+
+		addressesFromPools, poolErrs := convertVSphereAddressesFromPoolsToMAPI(devicePath.Child("addressesFromPools"), device.AddressesFromPools)
+		errs = append(errs, poolErrs...)
+
+		mapiDevices = append(mapiDevices, mapiv1.NetworkDeviceSpec{
+			NetworkName:        device.NetworkName,
+			Gateway:            gateway,
+			IPAddrs:            device.IPAddrs,
+			Nameservers:        device.Nameservers,
+			AddressesFromPools: addressesFromPools,
+		})
+	}
+
+	return mapiDevices, errs
+}
+
+// validateVSphereNetworkDeviceAddresses validates the static addresses and nameservers of a CAPV network device.
+// MAPV writes these as is into the guest network configuration, so they must be usable without further resolution.
+func validateVSphereNetworkDeviceAddresses(fldPath *field.Path, ipAddrs []string, nameservers []string) field.ErrorList {
+	var errs field.ErrorList
+
+	for i, ipAddr := range ipAddrs {
+		if _, _, err := net.ParseCIDR(ipAddr); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("ipAddrs").Index(i), ipAddr, "ipAddrs must be valid IPv4 or IPv6 addresses in CIDR notation"))
+		}
+	}
+
+	for i, nameserver := range nameservers {
+		if net.ParseIP(nameserver) == nil {
+			errs = append(errs, field.Invalid(fldPath.Child("nameservers").Index(i), nameserver, "nameservers must be valid IPv4 or IPv6 addresses"))
+		}
+	}
+
+	return errs
+}
+
+// convertVSphereAddressesFromPoolsToMAPI converts the CAPV typed pool references to MAPI IP address pool references.
+// MAPV uses the pool resource as the kind of the pool reference when it claims addresses.
+// MAPV can only resolve a pool through its group, resource and name, so all of them are required.
+func convertVSphereAddressesFromPoolsToMAPI(fldPath *field.Path, capvPools []corev1.TypedLocalObjectReference) ([]mapiv1.AddressesFromPool, field.ErrorList) {
+	var (
+		mapiPools []mapiv1.AddressesFromPool
+		errs      field.ErrorList
+	)
+
+	for i, pool := range capvPools {
+		if ptr.Deref(pool.APIGroup, "") == "" {
+			errs = append(errs, field.Required(fldPath.Index(i).Child("apiGroup"), "apiGroup is required"))
+		}
+
+		if pool.Kind == "" {
+			errs = append(errs, field.Required(fldPath.Index(i).Child("kind"), "kind is required"))
+		}
+
+		if pool.Name == "" {
+			errs = append(errs, field.Required(fldPath.Index(i).Child("name"), "name is required"))
+		}
+
+		mapiPools = append(mapiPools, mapiv1.AddressesFromPool{
+			Group:    ptr.Deref(pool.APIGroup, ""),
+			Resource: pool.Kind,
+			Name:     pool.Name,
+		})
+	}
+
+	return mapiPools, errs
+}
+
+func handleUnsupportedVSphereNetworkDeviceFields(fldPath *field.Path, device capvv1.NetworkDeviceSpec) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if device.DeviceName != "" {
+		errs = append(errs, field.Invalid(fldPath.Child("deviceName"), device.DeviceName, "deviceName is not supported"))
+	}
+
+	if device.MTU != nil {
+		errs = append(errs, field.Invalid(fldPath.Child("mtu"), *device.MTU, "mtu is not supported"))
+	}
+
+	if device.MACAddr != "" {
+		errs = append(errs, field.Invalid(fldPath.Child("macAddr"), device.MACAddr, "macAddr is not supported"))
+	}
+
+	if len(device.Routes) > 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("routes"), device.Routes, "routes are not supported"))
+	}
+
+	if len(device.SearchDomains) > 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("searchDomains"), device.SearchDomains, "searchDomains are not supported"))
+	}
+
+	if device.DHCP4Overrides != nil {
+		errs = append(errs, field.Invalid(fldPath.Child("dhcp4Overrides"), device.DHCP4Overrides, "dhcp4Overrides are not supported"))
+	}
+
+	if device.DHCP6Overrides != nil {
+		errs = append(errs, field.Invalid(fldPath.Child("dhcp6Overrides"), device.DHCP6Overrides, "dhcp6Overrides are not supported"))
+	}
+
+	if device.SkipIPAllocation {
+		errs = append(errs, field.Invalid(fldPath.Child("skipIPAllocation"), device.SkipIPAllocation, "skipIPAllocation is not supported"))
+	}
+
+	return errs
+}
+
+// validateVSphereFailureDomain validates that a machine placed in a failure domain can be placed by MAPV.
+// CAPV copies the failure domain of the Machine onto the VSphereMachine, any other failure domain cannot be honoured.
+func validateVSphereFailureDomain(fldPath *field.Path, failureDomain *string, spec capvv1.VSphereMachineSpec) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if failureDomain != nil && (spec.Server == "" || spec.Datacenter == "") {
+		errs = append(errs, field.Invalid(fldPath.Child("failureDomain"), *failureDomain, "failureDomain requires the server and datacenter to be set on the VSphereMachine, MAPI places vSphere machines using the workspace"))
+	}
+
+	if spec.FailureDomain != nil && ptr.Deref(failureDomain, "") != *spec.FailureDomain {
+		errs = append(errs, field.Invalid(fldPath.Child("failureDomain"), *spec.FailureDomain, "failureDomain of the VSphereMachine must match the failureDomain of the Machine"))
+	}
+
+	return errs
+}
+
+func handleUnsupportedVSphereMachineFields(fldPath *field.Path, spec capvv1.VSphereMachineSpec) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if spec.Thumbprint != "" {
+		// MAPV takes the vCenter certificate from the cloud provider configuration.
+		errs = append(errs, field.Invalid(fldPath.Child("thumbprint"), spec.Thumbprint, "thumbprint is not supported"))
+	}
+
+	if spec.StoragePolicyName != "" {
+		errs = append(errs, field.Invalid(fldPath.Child("storagePolicyName"), spec.StoragePolicyName, "storagePolicyName is not supported"))
+	}
+
+	if len(spec.AdditionalDisksGiB) > 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("additionalDisksGiB"), spec.AdditionalDisksGiB, "additionalDisksGiB are not supported"))
+	}
+
+	if len(spec.CustomVMXKeys) > 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("customVMXKeys"), spec.CustomVMXKeys, "customVMXKeys are not supported"))
+	}
+
+	if len(spec.PciDevices) > 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("pciDevices"), spec.PciDevices, "pciDevices are not supported"))
+	}
+
+	if spec.OS != "" && spec.OS != capvv1.Linux {
+		// MAPV only ever creates Linux machines.
+		errs = append(errs, field.NotSupported(fldPath.Child("os"), spec.OS, []string{string(capvv1.Linux)}))
+	}
+
+	if spec.HardwareVersion != "" {
+		errs = append(errs, field.Invalid(fldPath.Child("hardwareVersion"), spec.HardwareVersion, "hardwareVersion is not supported"))
+	}
+
+	if len(spec.Network.Routes) > 0 {
+		errs = append(errs, field.Invalid(fldPath.Child("network", "routes"), spec.Network.Routes, "routes are not supported"))
+	}
+
+	if spec.Network.PreferredAPIServerCIDR != "" {
+		errs = append(errs, field.Invalid(fldPath.Child("network", "preferredAPIServerCidr"), spec.Network.PreferredAPIServerCIDR, "preferredAPIServerCidr is not supported"))
+	}
+
+	if spec.PowerOffMode != "" && spec.PowerOffMode != capvv1.VirtualMachinePowerOpModeHard {
+		// MAPV always powers off machines forcibly.
+		errs = append(errs, field.NotSupported(fldPath.Child("powerOffMode"), spec.PowerOffMode, []string{string(capvv1.VirtualMachinePowerOpModeHard)}))
+	}
+
+	if spec.GuestSoftPowerOffTimeout != nil {
+		errs = append(errs, field.Invalid(fldPath.Child("guestSoftPowerOffTimeout"), spec.GuestSoftPowerOffTimeout, "guestSoftPowerOffTimeout is not supported"))
+	}
+
+	return errs
+}
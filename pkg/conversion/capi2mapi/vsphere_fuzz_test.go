@@ -0,0 +1,247 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capi2mapi_test
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	fuzz "github.com/google/gofuzz"
+
+	configv1 "github.com/openshift/api/config/v1"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/capi2mapi"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/mapi2capi"
+	conversiontest "github.com/openshift/cluster-capi-operator/pkg/conversion/test/fuzz"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/apitesting/fuzzer"
+	runtimeserializer "k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/utils/ptr"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	capvv1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	vsphereMachineKind  = "VSphereMachine"
+	vsphereTemplateKind = "VSphereMachineTemplate"
+)
+
+var _ = Describe("vSphere Fuzz (capi2mapi)", func() {
+	infra := &configv1.Infrastructure{
+		Spec: configv1.InfrastructureSpec{},
+		Status: configv1.InfrastructureStatus{
+			InfrastructureName: "sample-cluster-name",
+			PlatformStatus: &configv1.PlatformStatus{
+				Type: configv1.VSpherePlatformType,
+			},
+		},
+	}
+
+	infraCluster := &capvv1.VSphereCluster{
+		Spec: capvv1.VSphereClusterSpec{
+			Server: "sample-vcenter",
+		},
+	}
+
+	Context("VSphereMachine Conversion", func() {
+		fromMachineAndVSphereMachineAndVSphereCluster := func(machine *capiv1.Machine, infraMachine client.Object, infraCluster client.Object) capi2mapi.MachineAndInfrastructureMachine {
+			vsphereMachine, ok := infraMachine.(*capvv1.VSphereMachine)
+			Expect(ok).To(BeTrue(), "input infra machine should be of type %T, got %T", &capvv1.VSphereMachine{}, infraMachine)
+
+			vsphereCluster, ok := infraCluster.(*capvv1.VSphereCluster)
+			Expect(ok).To(BeTrue(), "input infra cluster should be of type %T, got %T", &capvv1.VSphereCluster{}, infraCluster)
+
+			return capi2mapi.FromMachineAndVSphereMachineAndVSphereCluster(machine, vsphereMachine, vsphereCluster)
+		}
+
+		conversiontest.CAPI2MAPIMachineRoundTripFuzzTest(
+			scheme,
+			infra,
+			infraCluster,
+			&capvv1.VSphereMachine{},
+			mapi2capi.FromVSphereMachineAndInfra,
+			fromMachineAndVSphereMachineAndVSphereCluster,
+			conversiontest.ObjectMetaFuzzerFuncs(capiNamespace),
+			capiMachineWithoutFailureDomainFuzzerFuncs(conversiontest.CAPIMachineFuzzerFuncs(vsphereProviderIDFuzzer, vsphereMachineKind, capvv1.GroupVersion.String(), infra.Status.InfrastructureName)),
+			vsphereMachineFuzzerFuncs,
+		)
+	})
+
+	Context("VSphereMachineSet Conversion", func() {
+		fromMachineSetAndVSphereMachineTemplateAndVSphereCluster := func(machineSet *capiv1.MachineSet, infraMachineTemplate client.Object, infraCluster client.Object) capi2mapi.MachineSetAndMachineTemplate {
+			vsphereMachineTemplate, ok := infraMachineTemplate.(*capvv1.VSphereMachineTemplate)
+			Expect(ok).To(BeTrue(), "input infra machine template should be of type %T, got %T", &capvv1.VSphereMachineTemplate{}, infraMachineTemplate)
+
+			vsphereCluster, ok := infraCluster.(*capvv1.VSphereCluster)
+			Expect(ok).To(BeTrue(), "input infra cluster should be of type %T, got %T", &capvv1.VSphereCluster{}, infraCluster)
+
+			return capi2mapi.FromMachineSetAndVSphereMachineTemplateAndVSphereCluster(machineSet, vsphereMachineTemplate, vsphereCluster)
+		}
+
+		conversiontest.CAPI2MAPIMachineSetRoundTripFuzzTest(
+			scheme,
+			infra,
+			infraCluster,
+			&capvv1.VSphereMachineTemplate{},
+			mapi2capi.FromVSphereMachineSetAndInfra,
+			fromMachineSetAndVSphereMachineTemplateAndVSphereCluster,
+			conversiontest.ObjectMetaFuzzerFuncs(capiNamespace),
+			capiMachineWithoutFailureDomainFuzzerFuncs(conversiontest.CAPIMachineFuzzerFuncs(vsphereProviderIDFuzzer, vsphereTemplateKind, capvv1.GroupVersion.String(), infra.Status.InfrastructureName)),
+			conversiontest.CAPIMachineSetFuzzerFuncs(vsphereTemplateKind, capvv1.GroupVersion.String(), infra.Status.InfrastructureName),
+			vsphereMachineFuzzerFuncs,
+			vsphereMachineTemplateFuzzerFuncs,
+		)
+	})
+})
+
+func vsphereProviderIDFuzzer(c fuzz.Continue) string {
+	return "vsphere://" + strings.ReplaceAll(c.RandString(), "/", "")
+}
+
+// capiMachineWithoutFailureDomainFuzzerFuncs clears the failure domain after the generic machine spec fuzzer has run.
+// MAPV and MAPP place machines using the workspace and service instance, so the failure domain cannot be converted.
+func capiMachineWithoutFailureDomainFuzzerFuncs(capiMachineFuzzerFuncs fuzzer.FuzzerFuncs) fuzzer.FuzzerFuncs {
+	return func(codecs runtimeserializer.CodecFactory) []interface{} {
+		funcs := capiMachineFuzzerFuncs(codecs)
+
+		for i, f := range funcs {
+			if machineSpecFuzzer, ok := f.(func(*capiv1.MachineSpec, fuzz.Continue)); ok {
+				funcs[i] = func(m *capiv1.MachineSpec, c fuzz.Continue) {
+					machineSpecFuzzer(m, c)
+
+					m.FailureDomain = nil
+				}
+			}
+		}
+
+		return funcs
+	}
+}
+
+func vsphereMachineFuzzerFuncs(codecs runtimeserializer.CodecFactory) []interface{} {
+	return []interface{}{
+		func(device *capvv1.NetworkDeviceSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(device)
+
+			// MAPI only has a single gateway per device.
+			device.Gateway4 = ""
+			device.Gateway6 = ""
+
+			switch c.Intn(3) {
+			case 1:
+				device.Gateway4 = fmt.Sprintf("%d.%d.%d.%d", c.Intn(256), c.Intn(256), c.Intn(256), c.Intn(256))
+			case 2:
+				device.Gateway6 = fmt.Sprintf("fd00::%x", c.Intn(65536))
+			}
+
+			// Static addresses and nameservers are validated, so they must be valid addresses.
+			device.IPAddrs = nil
+			for range c.Intn(3) {
+				device.IPAddrs = append(device.IPAddrs, fmt.Sprintf("192.168.%d.%d/24", c.Intn(256), c.Intn(256)))
+			}
+
+			device.Nameservers = nil
+			for range c.Intn(3) {
+				device.Nameservers = append(device.Nameservers, fmt.Sprintf("fd00::%x", c.Intn(65536)))
+			}
+
+			// MAPI always references IP address pools by group, resource and name.
+			for i := range device.AddressesFromPools {
+				device.AddressesFromPools[i] = corev1.TypedLocalObjectReference{
+					APIGroup: ptr.To("ipam.cluster.x-k8s.io"),
+					Kind:     "IPPool",
+					Name:     fmt.Sprintf("pool-%d", c.Intn(1000)),
+				}
+			}
+
+			if len(device.AddressesFromPools) == 0 {
+				device.AddressesFromPools = nil
+			}
+
+			// MAPI uses DHCP for IPv4 whenever a device has no addresses.
+			device.DHCP4 = len(device.IPAddrs) == 0 && len(device.AddressesFromPools) == 0
+			device.DHCP6 = false
+
+			// Clear fields that are not supported.
+			device.DeviceName = ""
+			device.MTU = nil
+			device.MACAddr = ""
+			device.Routes = nil
+			device.SearchDomains = nil
+			device.DHCP4Overrides = nil
+			device.DHCP6Overrides = nil
+			device.SkipIPAllocation = false
+		},
+		func(spec *capvv1.VSphereMachineSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(spec)
+
+			// A template is required to clone the machine from.
+			if spec.Template == "" {
+				spec.Template = "sample-template"
+			}
+
+			// The CAPV default of a linked clone is always set explicitly on the way back.
+			spec.CloneMode = []capvv1.CloneMode{capvv1.FullClone, capvv1.LinkedClone}[c.Intn(2)]
+
+			if len(spec.Network.Devices) == 0 {
+				spec.Network.Devices = nil
+			}
+
+			if len(spec.TagIDs) == 0 {
+				spec.TagIDs = nil
+			}
+
+			// Clear fields that are not supported.
+			spec.FailureDomain = nil
+			spec.Thumbprint = ""
+			spec.StoragePolicyName = ""
+			spec.AdditionalDisksGiB = nil
+			spec.CustomVMXKeys = nil
+			spec.PciDevices = nil
+			spec.OS = ""
+			spec.HardwareVersion = ""
+			spec.Network.Routes = nil
+			spec.Network.PreferredAPIServerCIDR = ""
+			spec.PowerOffMode = ""
+			spec.GuestSoftPowerOffTimeout = nil
+		},
+		func(m *capvv1.VSphereMachine, c fuzz.Continue) {
+			c.FuzzNoCustom(m)
+
+			// Ensure the type meta is set correctly.
+			m.TypeMeta.APIVersion = capvv1.GroupVersion.String()
+			m.TypeMeta.Kind = vsphereMachineKind
+		},
+	}
+}
+
+func vsphereMachineTemplateFuzzerFuncs(codecs runtimeserializer.CodecFactory) []interface{} {
+	return []interface{}{
+		func(m *capvv1.VSphereMachineTemplate, c fuzz.Continue) {
+			c.FuzzNoCustom(m)
+
+			// Ensure the type meta is set correctly.
+			m.TypeMeta.APIVersion = capvv1.GroupVersion.String()
+			m.TypeMeta.Kind = vsphereTemplateKind
+		},
+	}
+}
@@ -0,0 +1,266 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package capi2mapi
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+	capibuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/cluster-api/core/v1beta1"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/test/matchers"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+	capvv1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	"sigs.k8s.io/yaml"
+)
+
+var _ = Describe("capi2mapi vSphere conversion", func() {
+	var (
+		vsphereCAPIMachineBase = capibuilder.Machine()
+
+		vsphereCluster = &capvv1.VSphereCluster{
+			Spec: capvv1.VSphereClusterSpec{
+				Server: "test-vcenter",
+			},
+		}
+
+		newVSphereMachine = func(modify func(*capvv1.VSphereMachineSpec)) *capvv1.VSphereMachine {
+			vsphereMachine := &capvv1.VSphereMachine{
+				Spec: capvv1.VSphereMachineSpec{
+					VirtualMachineCloneSpec: capvv1.VirtualMachineCloneSpec{
+						Template:     "/test-datacenter/vm/test-rhcos",
+						CloneMode:    capvv1.FullClone,
+						Server:       "test-vcenter",
+						Datacenter:   "test-datacenter",
+						Datastore:    "test-datastore",
+						ResourcePool: "/test-datacenter/hosts/test-cluster/resources",
+						Network: capvv1.NetworkSpec{
+							Devices: []capvv1.NetworkDeviceSpec{{NetworkName: "test-network", DHCP4: true}},
+						},
+						NumCPUs:   4,
+						MemoryMiB: 16384,
+						DiskGiB:   120,
+					},
+				},
+			}
+
+			if modify != nil {
+				modify(&vsphereMachine.Spec)
+			}
+
+			return vsphereMachine
+		}
+	)
+
+	type vsphereCAPI2MAPIMachineConversionInput struct {
+		machineBuilder   capibuilder.MachineBuilder
+		vsphereMachine   *capvv1.VSphereMachine
+		expectedErrors   []string
+		expectedWarnings []string
+	}
+
+	var _ = DescribeTable("capi2mapi vSphere convert CAPI Machine/InfraMachine/InfraCluster to a MAPI Machine",
+		func(in vsphereCAPI2MAPIMachineConversionInput) {
+			_, warns, err := FromMachineAndVSphereMachineAndVSphereCluster(
+				in.machineBuilder.Build(),
+				in.vsphereMachine,
+				vsphereCluster,
+			).ToMachine()
+			Expect(err).To(matchers.ConsistOfMatchErrorSubstrings(in.expectedErrors),
+				"should match expected errors while converting vSphere CAPI resources to MAPI Machine")
+			Expect(warns).To(matchers.ConsistOfSubstrings(in.expectedWarnings),
+				"should match expected warnings while converting vSphere CAPI resources to MAPI Machine")
+		},
+
+		// Base Case.
+		Entry("With a Base configuration", vsphereCAPI2MAPIMachineConversionInput{
+			machineBuilder:   vsphereCAPIMachineBase,
+			vsphereMachine:   newVSphereMachine(nil),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With static IP addresses", vsphereCAPI2MAPIMachineConversionInput{
+			machineBuilder: vsphereCAPIMachineBase,
+			vsphereMachine: newVSphereMachine(func(spec *capvv1.VSphereMachineSpec) {
+				spec.Network.Devices = []capvv1.NetworkDeviceSpec{{
+					NetworkName: "test-network",
+					IPAddrs:     []string{"192.168.1.100/24"},
+					Gateway4:    "192.168.1.1",
+					Nameservers: []string{"192.168.1.2"},
+				}}
+			}),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With multiple network devices", vsphereCAPI2MAPIMachineConversionInput{
+			machineBuilder: vsphereCAPIMachineBase,
+			vsphereMachine: newVSphereMachine(func(spec *capvv1.VSphereMachineSpec) {
+				spec.Network.Devices = []capvv1.NetworkDeviceSpec{
+					{
+						NetworkName: "test-network",
+						IPAddrs:     []string{"192.168.1.100/24"},
+						Gateway4:    "192.168.1.1",
+						Nameservers: []string{"192.168.1.2"},
+					},
+					{
+						NetworkName: "test-storage-network",
+						AddressesFromPools: []corev1.TypedLocalObjectReference{{
+							APIGroup: ptr.To("ipam.cluster.x-k8s.io"),
+							Kind:     "IPPool",
+							Name:     "test-pool",
+						}},
+						Nameservers: []string{"fd00::2"},
+					},
+					{
+						NetworkName: "test-backup-network",
+						DHCP4:       true,
+					},
+				}
+			}),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With invalid static IP addresses and nameservers", vsphereCAPI2MAPIMachineConversionInput{
+			machineBuilder: vsphereCAPIMachineBase,
+			vsphereMachine: newVSphereMachine(func(spec *capvv1.VSphereMachineSpec) {
+				spec.Network.Devices = []capvv1.NetworkDeviceSpec{{
+					NetworkName: "test-network",
+					IPAddrs:     []string{"192.168.1.100"},
+					Nameservers: []string{"dns.example.com"},
+				}}
+			}),
+			expectedErrors: []string{
+				"spec.network.devices[0].ipAddrs[0]: Invalid value: \"192.168.1.100\": ipAddrs must be valid IPv4 or IPv6 addresses in CIDR notation",
+				"spec.network.devices[0].nameservers[0]: Invalid value: \"dns.example.com\": nameservers must be valid IPv4 or IPv6 addresses",
+			},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With an incomplete IP address pool reference", vsphereCAPI2MAPIMachineConversionInput{
+			machineBuilder: vsphereCAPIMachineBase,
+			vsphereMachine: newVSphereMachine(func(spec *capvv1.VSphereMachineSpec) {
+				spec.Network.Devices = []capvv1.NetworkDeviceSpec{{
+					NetworkName:        "test-network",
+					AddressesFromPools: []corev1.TypedLocalObjectReference{{Name: "test-pool"}},
+				}}
+			}),
+			expectedErrors: []string{
+				"spec.network.devices[0].addressesFromPools[0].apiGroup: Required value: apiGroup is required",
+				"spec.network.devices[0].addressesFromPools[0].kind: Required value: kind is required",
+			},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With both an IPv4 and an IPv6 gateway", vsphereCAPI2MAPIMachineConversionInput{
+			machineBuilder: vsphereCAPIMachineBase,
+			vsphereMachine: newVSphereMachine(func(spec *capvv1.VSphereMachineSpec) {
+				spec.Network.Devices = []capvv1.NetworkDeviceSpec{{
+					NetworkName: "test-network",
+					IPAddrs:     []string{"192.168.1.100/24", "fd00::100/64"},
+					Gateway4:    "192.168.1.1",
+					Gateway6:    "fd00::1",
+				}}
+			}),
+			expectedErrors:   []string{"spec.network.devices[0].gateway6: Invalid value: \"fd00::1\": gateway6 cannot be set alongside gateway4, MAPI only supports a single gateway per device"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With DHCP alongside static IP addresses", vsphereCAPI2MAPIMachineConversionInput{
+			machineBuilder: vsphereCAPIMachineBase,
+			vsphereMachine: newVSphereMachine(func(spec *capvv1.VSphereMachineSpec) {
+				spec.Network.Devices[0].IPAddrs = []string{"192.168.1.100/24"}
+			}),
+			expectedErrors:   []string{"spec.network.devices[0].dhcp4: Invalid value: true: dhcp4 cannot be combined with ipAddrs or addressesFromPools, MAPI only uses DHCP when a device has no addresses"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("Without DHCP or any addresses", vsphereCAPI2MAPIMachineConversionInput{
+			machineBuilder: vsphereCAPIMachineBase,
+			vsphereMachine: newVSphereMachine(func(spec *capvv1.VSphereMachineSpec) {
+				spec.Network.Devices[0].DHCP4 = false
+			}),
+			expectedErrors:   []string{"spec.network.devices[0].dhcp4: Invalid value: false: dhcp4 must be enabled when a device has no ipAddrs or addressesFromPools, MAPI always uses DHCP for such devices"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With unsupported fields", vsphereCAPI2MAPIMachineConversionInput{
+			machineBuilder: vsphereCAPIMachineBase,
+			vsphereMachine: newVSphereMachine(func(spec *capvv1.VSphereMachineSpec) {
+				spec.StoragePolicyName = "test-policy"
+				spec.AdditionalDisksGiB = []int32{50}
+				spec.OS = capvv1.Windows
+				spec.PowerOffMode = capvv1.VirtualMachinePowerOpModeSoft
+				spec.Network.Devices[0].MTU = ptr.To[int64](9000)
+			}),
+			expectedErrors: []string{
+				"spec.storagePolicyName: Invalid value: \"test-policy\": storagePolicyName is not supported",
+				"spec.additionalDisksGiB: Invalid value: []int32{50}: additionalDisksGiB are not supported",
+				"spec.os: Unsupported value: \"Windows\": supported values: \"Linux\"",
+				"spec.powerOffMode: Unsupported value: \"soft\": supported values: \"hard\"",
+				"spec.network.devices[0].mtu: Invalid value: 9000: mtu is not supported",
+			},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a failure domain", vsphereCAPI2MAPIMachineConversionInput{
+			machineBuilder: vsphereCAPIMachineBase.WithFailureDomain(ptr.To("test-zone")),
+			vsphereMachine: newVSphereMachine(func(spec *capvv1.VSphereMachineSpec) {
+				spec.FailureDomain = ptr.To("test-zone")
+			}),
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a failure domain without a workspace", vsphereCAPI2MAPIMachineConversionInput{
+			machineBuilder: vsphereCAPIMachineBase.WithFailureDomain(ptr.To("test-zone")),
+			vsphereMachine: newVSphereMachine(func(spec *capvv1.VSphereMachineSpec) {
+				spec.Server = ""
+				spec.Datacenter = ""
+			}),
+			expectedErrors:   []string{"spec.failureDomain: Invalid value: \"test-zone\": failureDomain requires the server and datacenter to be set on the VSphereMachine, MAPI places vSphere machines using the workspace"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a VSphereMachine failure domain not matching the Machine", vsphereCAPI2MAPIMachineConversionInput{
+			machineBuilder: vsphereCAPIMachineBase.WithFailureDomain(ptr.To("test-zone")),
+			vsphereMachine: newVSphereMachine(func(spec *capvv1.VSphereMachineSpec) {
+				spec.FailureDomain = ptr.To("other-zone")
+			}),
+			expectedErrors:   []string{"spec.failureDomain: Invalid value: \"other-zone\": failureDomain of the VSphereMachine must match the failureDomain of the Machine"},
+			expectedWarnings: []string{},
+		}),
+	)
+
+	var _ = DescribeTable("capi2mapi vSphere convert CAPV clone modes",
+		func(cloneMode capvv1.CloneMode, expectedCloneMode mapiv1.CloneMode) {
+			mapiMachine, _, err := FromMachineAndVSphereMachineAndVSphereCluster(vsphereCAPIMachineBase.Build(), newVSphereMachine(func(spec *capvv1.VSphereMachineSpec) {
+				spec.CloneMode = cloneMode
+			}), vsphereCluster).ToMachine()
+			Expect(err).ToNot(HaveOccurred())
+
+			providerSpec := &mapiv1.VSphereMachineProviderSpec{}
+			Expect(yaml.Unmarshal(mapiMachine.Spec.ProviderSpec.Value.Raw, providerSpec)).To(Succeed())
+			Expect(providerSpec.CloneMode).To(Equal(expectedCloneMode))
+		},
+
+		Entry("With the CAPV default, a linked clone", capvv1.CloneMode(""), mapiv1.LinkedClone),
+		Entry("With a full clone", capvv1.FullClone, mapiv1.FullClone),
+		Entry("With a linked clone", capvv1.LinkedClone, mapiv1.LinkedClone),
+	)
+})
@@ -28,6 +28,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 	"k8s.io/utils/ptr"
 	capav1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
@@ -36,8 +37,16 @@ import (
 	"sigs.k8s.io/yaml"
 )
 
+const (
+	// capacityReservationIDPrefix is the prefix of all EC2 On-Demand Capacity Reservation IDs.
+	capacityReservationIDPrefix = "cr-"
+)
+
 var (
 	errUnexpectedObjectTypeForMachine = errors.New("unexpected type for capaMachineObj")
+
+	// spotMaxPriceRegex matches the validation pattern CAPA applies to spotMarketOptions.maxPrice.
+	spotMaxPriceRegex = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
 )
 
 // awsMachineAndInfra stores the details of a Machine API AWSMachine and Infra.
@@ -135,6 +144,8 @@ func (m *awsMachineAndInfra) toMachineAndInfrastructureMachine() (*capiv1.Machin
 		capiMachine.Spec.ClusterName = m.infrastructure.Status.InfrastructureName
 	}
 
+	errs = append(errs, preserveProviderSpecFields(m.machine, capiMachine, "credentialsSecret")...)
+
 	// The InfraMachine should always have the same labels and annotations as the Machine.
 	// See https://github.com/kubernetes-sigs/cluster-api/blob/f88d7ae5155700c2cc367b31ddcc151c9ad579e4/internal/controllers/machineset/machineset_controller.go#L578-L579
 	capaMachine.SetAnnotations(capiMachine.GetAnnotations())
@@ -223,11 +234,29 @@ func (m *awsMachineAndInfra) toAWSMachine(providerSpec mapiv1.AWSMachineProvider
 		errs = append(errs, err)
 	}
 
+	placementGroupPartition, err := convertAWSPlacementGroupPartitionToCAPI(fldPath.Child("placementGroupPartition"), providerSpec.PlacementGroupName, providerSpec.PlacementGroupPartition)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	spotMarketOptions, err := convertAWSSpotMarketOptionsToCAPI(fldPath.Child("spotMarketOptions"), providerSpec.SpotMarketOptions)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	additionalSecurityGroups, sgErrs := convertAWSSecurityGroupstoCAPI(fldPath.Child("securityGroups"), providerSpec.SecurityGroups)
+	errs = append(errs, sgErrs...)
+
+	iamInstanceProfile, err := convertIAMInstanceProfiletoCAPI(fldPath.Child("iamInstanceProfile"), providerSpec.IAMInstanceProfile)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
 	spec := capav1.AWSMachineSpec{
 		AMI:                      capiAWSAMIReference,
-		AdditionalSecurityGroups: convertAWSSecurityGroupstoCAPI(providerSpec.SecurityGroups),
+		AdditionalSecurityGroups: additionalSecurityGroups,
 		AdditionalTags:           convertAWSTagsToCAPI(providerSpec.Tags),
-		IAMInstanceProfile:       convertIAMInstanceProfiletoCAPI(providerSpec.IAMInstanceProfile),
+		IAMInstanceProfile:       iamInstanceProfile,
 		Ignition: &capav1.Ignition{
 			Version:     "3.4",                                               // TODO(OCPCLOUD-2719): Should this be extracted from the ignition in the user data secret?
 			StorageType: capav1.IgnitionStorageTypeOptionUnencryptedUserData, // Hardcoded for OpenShift.
@@ -243,19 +272,23 @@ func (m *awsMachineAndInfra) toAWSMachine(providerSpec mapiv1.AWSMachineProvider
 		InstanceType:            providerSpec.InstanceType,
 		NonRootVolumes:          nonRootVolumes,
 		PlacementGroupName:      providerSpec.PlacementGroupName,
-		PlacementGroupPartition: int64(ptr.Deref(providerSpec.PlacementGroupPartition, 0)),
+		PlacementGroupPartition: placementGroupPartition,
 		// ProviderID. This is populated when this is called in higher level funcs (ToMachine(), ToMachineSet()).
 		// InstanceID. This is populated when this is called in higher level funcs (ToMachine(), ToMachineSet()).
 		PublicIP:          providerSpec.PublicIP,
 		RootVolume:        rootVolume,
 		SSHKeyName:        providerSpec.KeyName,
-		SpotMarketOptions: convertAWSSpotMarketOptionsToCAPI(providerSpec.SpotMarketOptions),
+		SpotMarketOptions: spotMarketOptions,
 		Subnet:            convertAWSResourceReferenceToCAPI(providerSpec.Subnet),
 		Tenancy:           string(providerSpec.Placement.Tenancy),
 		// UncompressedUserData: Not used in OpenShift.
 	}
 
 	if providerSpec.CapacityReservationID != "" {
+		if !strings.HasPrefix(providerSpec.CapacityReservationID, capacityReservationIDPrefix) {
+			errs = append(errs, field.Invalid(fldPath.Child("capacityReservationId"), providerSpec.CapacityReservationID, fmt.Sprintf("capacityReservationId must start with %q", capacityReservationIDPrefix)))
+		}
+
 		spec.CapacityReservationID = &providerSpec.CapacityReservationID
 	}
 
@@ -285,14 +318,27 @@ func (m *awsMachineAndInfra) toAWSMachine(providerSpec mapiv1.AWSMachineProvider
 		errs = append(errs, field.Invalid(fldPath.Child("deviceIndex"), providerSpec.DeviceIndex, "deviceIndex must be 0 or unset"))
 	}
 
-	if providerSpec.NetworkInterfaceType != "" && providerSpec.NetworkInterfaceType != mapiv1.AWSENANetworkInterfaceType {
-		// TODO(OCPCLOUD-2708): We need to upstream the network interface choice to allow the elastic fabric adapter.
+	switch providerSpec.NetworkInterfaceType {
+	case "", mapiv1.AWSENANetworkInterfaceType:
+		// ENA is the default for both MAPA and CAPA.
+	case mapiv1.AWSEFANetworkInterfaceType:
+		// TODO(OCPCLOUD-2708): Blocked on bumping CAPA past v2.6.1, which has no network interface type on the AWSMachineSpec.
+		// EFA has an upstream CAPA equivalent, so it is reported as Invalid, not as unsupported on CAPI:
+		// once the bumped AWSMachineSpec carries networkInterfaceType, convert EFA to it here.
+		// Until then, EFA machines can only be created on CAPI directly, as the e2e suite does with an unstructured AWSMachineTemplate.
+		errs = append(errs, field.Invalid(fldPath.Child("networkInterfaceType"), providerSpec.NetworkInterfaceType, "networkInterface type EFA cannot be converted until CAPA is bumped past v2.6.1 to a release with networkInterfaceType"))
+	default:
 		errs = append(errs, field.Invalid(fldPath.Child("networkInterfaceType"), providerSpec.NetworkInterfaceType, "networkInterface type must be one of ENA or omitted, unsupported value"))
 	}
 
 	if len(providerSpec.LoadBalancers) > 0 {
-		// TODO(OCPCLOUD-2709): CAPA only applies load balancers to the control plane nodes. We should always reject LBs on non-control plane and work out how to connect the control plane LBs correctly otherwise.
-		errs = append(errs, field.Invalid(fldPath.Child("loadBalancers"), providerSpec.LoadBalancers, "loadBalancers are not supported"))
+		if isControlPlaneMachine(m.machine) {
+			// CAPA registers control plane machines with the control plane load balancer of the AWSCluster.
+			warnings = append(warnings, field.Invalid(fldPath.Child("loadBalancers"), providerSpec.LoadBalancers, "loadBalancers are not converted, control plane machines are registered with the AWSCluster load balancers").Error())
+		} else {
+			// TODO(OCPCLOUD-2709): CAPA only applies load balancers to the control plane nodes.
+			errs = append(errs, field.Invalid(fldPath.Child("loadBalancers"), providerSpec.LoadBalancers, "loadBalancers are not supported"))
+		}
 	}
 
 	return &capav1.AWSMachine{
@@ -383,42 +429,92 @@ func convertMetadataServiceOptionstoCAPI(fldPath *field.Path, metad mapiv1.Metad
 
 	capiMetadataOpts := &capav1.InstanceMetadataOptions{
 		HTTPEndpoint: capav1.InstanceMetadataEndpointStateEnabled, // not present in MAPI, fallback to CAPI default.
-		// HTTPPutResponseHopLimit: not present in MAPI, fallback to CAPI default.
-		InstanceMetadataTags: capav1.InstanceMetadataEndpointStateDisabled, // not present in MAPI, fallback to CAPI default.
-		HTTPTokens:           httpTokens,
+		// Not present in MAPI. MAPA never overrides the EC2 default of 1, so set it explicitly to keep IMDSv2
+		// token responses restricted to the instance itself, regardless of any defaulting on the CAPA side.
+		HTTPPutResponseHopLimit: 1,
+		InstanceMetadataTags:    capav1.InstanceMetadataEndpointStateDisabled, // not present in MAPI, fallback to CAPI default.
+		HTTPTokens:              httpTokens,
 	}
 
 	return capiMetadataOpts, nil
 }
 
-func convertIAMInstanceProfiletoCAPI(mapiIAM *mapiv1.AWSResourceReference) string {
-	if mapiIAM == nil || mapiIAM.ID == nil {
-		return ""
+func convertIAMInstanceProfiletoCAPI(fldPath *field.Path, mapiIAM *mapiv1.AWSResourceReference) (string, *field.Error) {
+	if mapiIAM == nil {
+		return "", nil
+	}
+
+	// MAPA only launches instances with the instance profile named by the ID, any ARN or filters are ignored.
+	// CAPA only accepts a name, so reject the references that cannot be converted rather than dropping them.
+	if mapiIAM.ARN != nil {
+		return "", field.Invalid(fldPath.Child("arn"), mapiIAM.ARN, "unable to convert iamInstanceProfile ARN reference. Not supported in CAPI")
+	}
+
+	if len(mapiIAM.Filters) > 0 {
+		return "", field.Invalid(fldPath.Child("filters"), mapiIAM.Filters, "unable to convert iamInstanceProfile Filters reference. Not supported in CAPI")
+	}
+
+	return ptr.Deref(mapiIAM.ID, ""), nil
+}
+
+func convertAWSPlacementGroupPartitionToCAPI(fldPath *field.Path, placementGroupName string, mapiPartition *int32) (int64, *field.Error) {
+	if mapiPartition == nil {
+		return 0, nil
+	}
+
+	if *mapiPartition < 1 || *mapiPartition > 7 {
+		return 0, field.Invalid(fldPath, *mapiPartition, "placementGroupPartition must be between 1 and 7")
 	}
 
-	return *mapiIAM.ID
+	if placementGroupName == "" {
+		// CAPA only sends the partition number to EC2 alongside a placement group name.
+		return 0, field.Invalid(fldPath, *mapiPartition, "placementGroupPartition is only valid when placementGroupName is set")
+	}
+
+	return int64(*mapiPartition), nil
 }
 
-func convertAWSSpotMarketOptionsToCAPI(mapiSpotMarketOptions *mapiv1.SpotMarketOptions) *capav1.SpotMarketOptions {
+func convertAWSSpotMarketOptionsToCAPI(fldPath *field.Path, mapiSpotMarketOptions *mapiv1.SpotMarketOptions) (*capav1.SpotMarketOptions, *field.Error) {
 	if mapiSpotMarketOptions == nil {
-		return nil
+		return nil, nil
+	}
+
+	// An empty maxPrice means the on-demand price is used as the maximum in both MAPA and CAPA.
+	// Any other value must be a plain decimal number, as CAPA validates it against the same pattern.
+	if maxPrice := ptr.Deref(mapiSpotMarketOptions.MaxPrice, ""); maxPrice != "" && !spotMaxPriceRegex.MatchString(maxPrice) {
+		return nil, field.Invalid(fldPath.Child("maxPrice"), maxPrice, "maxPrice must be a decimal number")
 	}
 
 	return &capav1.SpotMarketOptions{
 		MaxPrice: mapiSpotMarketOptions.MaxPrice,
-	}
+	}, nil
 }
 
-func convertAWSSecurityGroupstoCAPI(sgs []mapiv1.AWSResourceReference) []capav1.AWSResourceReference {
+// convertAWSSecurityGroupstoCAPI converts MAPI security group references to CAPA additional security groups.
+// Filters, including tag-based filters such as "tag:Name", are passed through unchanged so that CAPA
+// performs the same lookup that MAPA would, attaching every security group the filters match.
+func convertAWSSecurityGroupstoCAPI(fldPath *field.Path, sgs []mapiv1.AWSResourceReference) ([]capav1.AWSResourceReference, field.ErrorList) {
 	capiSGs := []capav1.AWSResourceReference{}
+	errs := field.ErrorList{}
+
+	for i, sg := range sgs {
+		if sg.ARN != nil {
+			// MAPA never resolves security groups by ARN, and CAPA has no ARN reference.
+			errs = append(errs, field.Invalid(fldPath.Index(i).Child("arn"), sg.ARN, "unable to convert security group ARN reference. Not supported in CAPI"))
+			continue
+		}
+
+		if sg.ID == nil && len(sg.Filters) == 0 {
+			errs = append(errs, field.Required(fldPath.Index(i), "security group reference must specify an id or filters"))
+			continue
+		}
 
-	for _, sg := range sgs {
 		ref := convertAWSResourceReferenceToCAPI(sg)
 
 		capiSGs = append(capiSGs, *ref)
 	}
 
-	return capiSGs
+	return capiSGs, errs
 }
 
 func convertAWSBlockDeviceMappingSpecToCAPI(fldPath *field.Path, mapiBlockDeviceMapping []mapiv1.BlockDeviceMappingSpec) (*capav1.Volume, []capav1.Volume, []string, field.ErrorList) {
@@ -426,6 +522,8 @@ func convertAWSBlockDeviceMappingSpecToCAPI(fldPath *field.Path, mapiBlockDevice
 	nonRootVolumes := []capav1.Volume{}
 	errs := field.ErrorList{}
 	warnings := []string{}
+	rootVolumeFound := false
+	deviceNames := sets.New[string]()
 
 	for i, mapping := range mapiBlockDeviceMapping {
 		if mapping.NoDevice != nil {
@@ -446,6 +544,14 @@ func convertAWSBlockDeviceMappingSpecToCAPI(fldPath *field.Path, mapiBlockDevice
 		}
 
 		if mapping.DeviceName == nil {
+			if rootVolumeFound {
+				// MAPA maps every block device without a device name onto the AMI root device, so only one may be specified.
+				errs = append(errs, field.Required(fldPath.Index(i).Child("deviceName"), "deviceName is required for non-root volumes, only one root volume may omit it"))
+				continue
+			}
+
+			rootVolumeFound = true
+
 			volume, warn, err := blockDeviceMappingSpecToVolume(fldPath.Index(i), mapping, true)
 			errs = append(errs, err...)
 			warnings = append(warnings, warn...)
@@ -455,6 +561,13 @@ func convertAWSBlockDeviceMappingSpecToCAPI(fldPath *field.Path, mapiBlockDevice
 			continue
 		}
 
+		if deviceNames.Has(*mapping.DeviceName) {
+			errs = append(errs, field.Duplicate(fldPath.Index(i).Child("deviceName"), *mapping.DeviceName))
+			continue
+		}
+
+		deviceNames.Insert(*mapping.DeviceName)
+
 		volume, warn, err := blockDeviceMappingSpecToVolume(fldPath.Index(i), mapping, false)
 		errs = append(errs, err...)
 		warnings = append(warnings, warn...)
@@ -16,6 +16,7 @@ limitations under the License.
 package mapi2capi_test
 
 import (
+	"fmt"
 	"strings"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -182,6 +183,17 @@ func awsProviderSpecFuzzerFuncs(codecs runtimeserializer.CodecFactory) []interfa
 				*msa = mapiv1.MetadataServiceAuthenticationRequired
 			}
 		},
+		func(smo *mapiv1.SpotMarketOptions, c fuzz.Continue) {
+			// MaxPrice must be a decimal number to be accepted by the conversion.
+			switch c.Intn(3) {
+			case 0:
+				smo.MaxPrice = nil
+			case 1:
+				smo.MaxPrice = ptr.To("")
+			case 2:
+				smo.MaxPrice = ptr.To(fmt.Sprintf("%d.%d", c.Intn(100), c.Intn(10000)))
+			}
+		},
 		func(ps *mapiv1.AWSMachineProviderConfig, c fuzz.Continue) {
 			c.FuzzNoCustom(ps)
 
@@ -196,19 +208,30 @@ func awsProviderSpecFuzzerFuncs(codecs runtimeserializer.CodecFactory) []interfa
 			ps.DeviceIndex = 0
 			ps.LoadBalancers = nil
 			ps.ObjectMeta = metav1.ObjectMeta{}
-			ps.CredentialsSecret = nil
 
-			// At lest one device mapping must have no device name.
-			rootFound := false
-			for i := range ps.BlockDevices {
-				if ps.BlockDevices[i].DeviceName == nil {
-					rootFound = true
-					break
+			// The capacity reservation ID must be a valid reservation ID when set.
+			if ps.CapacityReservationID != "" {
+				ps.CapacityReservationID = "cr-" + strings.ReplaceAll(c.RandString(), "/", "")
+			}
+
+			// The placement group partition must be in range and paired with a placement group name.
+			if ps.PlacementGroupPartition != nil {
+				ps.PlacementGroupPartition = ptr.To(c.Int31n(7) + 1)
+
+				if ps.PlacementGroupName == "" {
+					ps.PlacementGroupName = "placement-group"
 				}
 			}
 
-			if !rootFound && len(ps.BlockDevices) > 0 {
-				ps.BlockDevices[0].DeviceName = nil
+			// Exactly one device mapping, the first, must have no device name.
+			// The remaining device names must be set and unique.
+			for i := range ps.BlockDevices {
+				if i == 0 {
+					ps.BlockDevices[i].DeviceName = nil
+					continue
+				}
+
+				ps.BlockDevices[i].DeviceName = ptr.To(fmt.Sprintf("/dev/sd%d", i))
 			}
 
 			// Clear pointers to empty structs.
@@ -24,6 +24,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/utils/ptr"
+	capav1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -78,6 +79,24 @@ var _ = Describe("mapi2capi AWS conversion", func() {
 		}
 	}
 
+	var awsProviderSpecWithPlacementGroupPartition = func(placementGroupName string, partition int32) mapiv1.ProviderSpec {
+		providerSpec := awsBaseProviderSpec.WithPlacementGroupName(placementGroupName).Build()
+		providerSpec.PlacementGroupPartition = ptr.To(partition)
+
+		return mapiv1.ProviderSpec{
+			Value: mustConvertAWSProviderSpecToRawExtension(providerSpec),
+		}
+	}
+
+	var awsProviderSpecWithCapacityReservationID = func(capacityReservationID string) mapiv1.ProviderSpec {
+		providerSpec := awsBaseProviderSpec.Build()
+		providerSpec.CapacityReservationID = capacityReservationID
+
+		return mapiv1.ProviderSpec{
+			Value: mustConvertAWSProviderSpecToRawExtension(providerSpec),
+		}
+	}
+
 	var _ = DescribeTable("mapi2capi AWS convert MAPI Machine",
 		func(in awsMAPI2CAPIConversionInput) {
 			_, _, warns, err := FromAWSMachineAndInfra(in.machineBuilder.Build(), in.infra).ToMachineAndInfrastructureMachine()
@@ -93,6 +112,35 @@ var _ = Describe("mapi2capi AWS conversion", func() {
 			expectedWarnings: []string{},
 		}),
 
+		Entry("With placement group name and partition", awsMAPI2CAPIConversionInput{
+			machineBuilder:   awsMAPIMachineBase.WithProviderSpec(awsProviderSpecWithPlacementGroupPartition("placement-group", 3)),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+		Entry("With capacity reservation ID", awsMAPI2CAPIConversionInput{
+			machineBuilder:   awsMAPIMachineBase.WithProviderSpec(awsProviderSpecWithCapacityReservationID("cr-0123456789abcdef0")),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+		Entry("With spot market options and maxPrice", awsMAPI2CAPIConversionInput{
+			machineBuilder: awsMAPIMachineBase.WithProviderSpecBuilder(
+				awsBaseProviderSpec.WithSpotMarketOptions(&mapiv1.SpotMarketOptions{MaxPrice: ptr.To("0.0965")}),
+			),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+		Entry("With spot market options and no maxPrice", awsMAPI2CAPIConversionInput{
+			machineBuilder: awsMAPIMachineBase.WithProviderSpecBuilder(
+				awsBaseProviderSpec.WithSpotMarketOptions(&mapiv1.SpotMarketOptions{}),
+			),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
 		// Only Error.
 		Entry("With LoadBalancers", awsMAPI2CAPIConversionInput{
 			machineBuilder: awsMAPIMachineBase.WithProviderSpecBuilder(
@@ -106,6 +154,18 @@ var _ = Describe("mapi2capi AWS conversion", func() {
 			},
 			expectedWarnings: []string{},
 		}),
+		Entry("With LoadBalancers on a control plane machine", awsMAPI2CAPIConversionInput{
+			machineBuilder: awsMAPIMachineBase.WithLabels(map[string]string{"machine.openshift.io/cluster-api-machine-role": "master"}).WithProviderSpecBuilder(
+				awsBaseProviderSpec.WithLoadBalancers(
+					[]mapiv1.LoadBalancerReference{{Name: "a", Type: mapiv1.ClassicLoadBalancerType}},
+				),
+			),
+			infra:          infra,
+			expectedErrors: []string{},
+			expectedWarnings: []string{
+				"spec.providerSpec.value.loadBalancers: Invalid value: []v1beta1.LoadBalancerReference{v1beta1.LoadBalancerReference{Name:\"a\", Type:\"classic\"}}: loadBalancers are not converted, control plane machines are registered with the AWSCluster load balancers",
+			},
+		}),
 		Entry("With DeviceIndex non-zero", awsMAPI2CAPIConversionInput{
 			machineBuilder: awsMAPIMachineBase.WithProviderSpecBuilder(
 				awsBaseProviderSpec.WithDeviceIndex(1),
@@ -153,6 +213,16 @@ var _ = Describe("mapi2capi AWS conversion", func() {
 			},
 			expectedWarnings: []string{},
 		}),
+		Entry("With EFA network interface type", awsMAPI2CAPIConversionInput{
+			machineBuilder: awsMAPIMachineBase.WithProviderSpecBuilder(
+				awsBaseProviderSpec.WithNetworkInterfaceType(mapiv1.AWSEFANetworkInterfaceType),
+			),
+			infra: infra,
+			expectedErrors: []string{
+				"spec.providerSpec.value.networkInterfaceType: Invalid value: \"EFA\": networkInterface type EFA cannot be converted until CAPA is bumped past v2.6.1 to a release with networkInterfaceType",
+			},
+			expectedWarnings: []string{},
+		}),
 		Entry("With AMI ARN reference", awsMAPI2CAPIConversionInput{
 			machineBuilder: awsMAPIMachineBase.WithProviderSpecBuilder(
 				awsBaseProviderSpec.WithAMI(mapiv1.AWSResourceReference{
@@ -201,6 +271,132 @@ var _ = Describe("mapi2capi AWS conversion", func() {
 			},
 			expectedWarnings: []string{},
 		}),
+		Entry("With placement group partition out of range", awsMAPI2CAPIConversionInput{
+			machineBuilder: awsMAPIMachineBase.WithProviderSpec(awsProviderSpecWithPlacementGroupPartition("placement-group", 8)),
+			infra:          infra,
+			expectedErrors: []string{
+				"spec.providerSpec.value.placementGroupPartition: Invalid value: 8: placementGroupPartition must be between 1 and 7",
+			},
+			expectedWarnings: []string{},
+		}),
+		Entry("With placement group partition and no placement group name", awsMAPI2CAPIConversionInput{
+			machineBuilder: awsMAPIMachineBase.WithProviderSpec(awsProviderSpecWithPlacementGroupPartition("", 2)),
+			infra:          infra,
+			expectedErrors: []string{
+				"spec.providerSpec.value.placementGroupPartition: Invalid value: 2: placementGroupPartition is only valid when placementGroupName is set",
+			},
+			expectedWarnings: []string{},
+		}),
+		Entry("With invalid capacity reservation ID", awsMAPI2CAPIConversionInput{
+			machineBuilder: awsMAPIMachineBase.WithProviderSpec(awsProviderSpecWithCapacityReservationID("reservation-1234")),
+			infra:          infra,
+			expectedErrors: []string{
+				"spec.providerSpec.value.capacityReservationId: Invalid value: \"reservation-1234\": capacityReservationId must start with \"cr-\"",
+			},
+			expectedWarnings: []string{},
+		}),
+		Entry("With invalid spot maxPrice", awsMAPI2CAPIConversionInput{
+			machineBuilder: awsMAPIMachineBase.WithProviderSpecBuilder(
+				awsBaseProviderSpec.WithSpotMarketOptions(&mapiv1.SpotMarketOptions{MaxPrice: ptr.To("one dollar")}),
+			),
+			infra: infra,
+			expectedErrors: []string{
+				"spec.providerSpec.value.spotMarketOptions.maxPrice: Invalid value: \"one dollar\": maxPrice must be a decimal number",
+			},
+			expectedWarnings: []string{},
+		}),
+		Entry("With root and multiple non-root Volumes", awsMAPI2CAPIConversionInput{
+			machineBuilder: awsMAPIMachineBase.WithProviderSpecBuilder(
+				awsBaseProviderSpec.WithBlockDevices([]mapiv1.BlockDeviceMappingSpec{
+					{
+						EBS: &mapiv1.EBSBlockDeviceSpec{VolumeSize: ptr.To(int64(120)), VolumeType: ptr.To("gp3")},
+					},
+					{
+						DeviceName: ptr.To("/dev/sdb"),
+						EBS:        &mapiv1.EBSBlockDeviceSpec{VolumeSize: ptr.To(int64(50)), VolumeType: ptr.To("io1"), Iops: ptr.To(int64(3000))},
+					},
+					{
+						DeviceName: ptr.To("/dev/sdc"),
+						EBS:        &mapiv1.EBSBlockDeviceSpec{VolumeSize: ptr.To(int64(100)), Encrypted: ptr.To(true), KMSKey: mapiv1.AWSResourceReference{ID: ptr.To("kms-key")}},
+					},
+				}),
+			),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+		Entry("With multiple root Volumes", awsMAPI2CAPIConversionInput{
+			machineBuilder: awsMAPIMachineBase.WithProviderSpecBuilder(
+				awsBaseProviderSpec.WithBlockDevices([]mapiv1.BlockDeviceMappingSpec{
+					{EBS: &mapiv1.EBSBlockDeviceSpec{VolumeSize: ptr.To(int64(120))}},
+					{EBS: &mapiv1.EBSBlockDeviceSpec{VolumeSize: ptr.To(int64(50))}},
+				}),
+			),
+			infra: infra,
+			expectedErrors: []string{
+				"spec.providerSpec.value.blockDevices[1].deviceName: Required value: deviceName is required for non-root volumes, only one root volume may omit it",
+			},
+			expectedWarnings: []string{},
+		}),
+		Entry("With duplicate non-root Volume device names", awsMAPI2CAPIConversionInput{
+			machineBuilder: awsMAPIMachineBase.WithProviderSpecBuilder(
+				awsBaseProviderSpec.WithBlockDevices([]mapiv1.BlockDeviceMappingSpec{
+					{DeviceName: ptr.To("/dev/sdb"), EBS: &mapiv1.EBSBlockDeviceSpec{VolumeSize: ptr.To(int64(50))}},
+					{DeviceName: ptr.To("/dev/sdb"), EBS: &mapiv1.EBSBlockDeviceSpec{VolumeSize: ptr.To(int64(50))}},
+				}),
+			),
+			infra: infra,
+			expectedErrors: []string{
+				"spec.providerSpec.value.blockDevices[1].deviceName: Duplicate value: \"/dev/sdb\"",
+			},
+			expectedWarnings: []string{},
+		}),
+		Entry("With security group ARN reference", awsMAPI2CAPIConversionInput{
+			machineBuilder: awsMAPIMachineBase.WithProviderSpecBuilder(
+				awsBaseProviderSpec.WithSecurityGroups([]mapiv1.AWSResourceReference{{
+					ARN: ptr.To("arn:aws:ec2:us-east-1:123456789012:security-group/sg-1234"),
+				}}),
+			),
+			infra: infra,
+			expectedErrors: []string{
+				"spec.providerSpec.value.securityGroups[0].arn: Invalid value: \"arn:aws:ec2:us-east-1:123456789012:security-group/sg-1234\": unable to convert security group ARN reference. Not supported in CAPI",
+			},
+			expectedWarnings: []string{},
+		}),
+		Entry("With empty security group reference", awsMAPI2CAPIConversionInput{
+			machineBuilder: awsMAPIMachineBase.WithProviderSpecBuilder(
+				awsBaseProviderSpec.WithSecurityGroups([]mapiv1.AWSResourceReference{{}}),
+			),
+			infra: infra,
+			expectedErrors: []string{
+				"spec.providerSpec.value.securityGroups[0]: Required value: security group reference must specify an id or filters",
+			},
+			expectedWarnings: []string{},
+		}),
+		Entry("With IAM instance profile ARN reference", awsMAPI2CAPIConversionInput{
+			machineBuilder: awsMAPIMachineBase.WithProviderSpecBuilder(
+				awsBaseProviderSpec.WithIAMInstanceProfile(&mapiv1.AWSResourceReference{
+					ARN: ptr.To("arn:aws:iam::123456789012:instance-profile/worker-profile"),
+				}),
+			),
+			infra: infra,
+			expectedErrors: []string{
+				"spec.providerSpec.value.iamInstanceProfile.arn: Invalid value: \"arn:aws:iam::123456789012:instance-profile/worker-profile\": unable to convert iamInstanceProfile ARN reference. Not supported in CAPI",
+			},
+			expectedWarnings: []string{},
+		}),
+		Entry("With IAM instance profile Filters reference", awsMAPI2CAPIConversionInput{
+			machineBuilder: awsMAPIMachineBase.WithProviderSpecBuilder(
+				awsBaseProviderSpec.WithIAMInstanceProfile(&mapiv1.AWSResourceReference{
+					Filters: []mapiv1.Filter{{Name: "tag:Name", Values: []string{"worker-profile"}}},
+				}),
+			),
+			infra: infra,
+			expectedErrors: []string{
+				"spec.providerSpec.value.iamInstanceProfile.filters: Invalid value: []v1beta1.Filter{v1beta1.Filter{Name:\"tag:Name\", Values:[]string{\"worker-profile\"}}}: unable to convert iamInstanceProfile Filters reference. Not supported in CAPI",
+			},
+			expectedWarnings: []string{},
+		}),
 		Entry("With missing Volume size for EBS", awsMAPI2CAPIConversionInput{
 			machineBuilder: awsMAPIMachineBase.WithProviderSpecBuilder(
 				awsBaseProviderSpec.WithBlockDevices([]mapiv1.BlockDeviceMappingSpec{{
@@ -322,6 +518,70 @@ var _ = Describe("mapi2capi AWS conversion", func() {
 		}),
 	)
 
+	var _ = DescribeTable("mapi2capi AWS convert MAPI metadataServiceOptions",
+		func(authentication mapiv1.MetadataServiceAuthentication, expected *capav1.InstanceMetadataOptions) {
+			machine := awsMAPIMachineBase.WithProviderSpecBuilder(
+				awsBaseProviderSpec.WithMetadataServiceOptions(mapiv1.MetadataServiceOptions{Authentication: authentication}),
+			).Build()
+
+			_, infraMachine, _, err := FromAWSMachineAndInfra(machine, infra).ToMachineAndInfrastructureMachine()
+			Expect(err).ToNot(HaveOccurred(), "should not fail converting an AWS MAPI Machine to CAPI")
+
+			awsMachine, ok := infraMachine.(*capav1.AWSMachine)
+			Expect(ok).To(BeTrue(), "expected infrastructure machine to be an AWSMachine")
+			Expect(awsMachine.Spec.InstanceMetadataOptions).To(Equal(expected))
+		},
+		Entry("With authentication Required", mapiv1.MetadataServiceAuthentication(mapiv1.MetadataServiceAuthenticationRequired), &capav1.InstanceMetadataOptions{
+			HTTPEndpoint:            capav1.InstanceMetadataEndpointStateEnabled,
+			HTTPPutResponseHopLimit: 1,
+			HTTPTokens:              capav1.HTTPTokensStateRequired,
+			InstanceMetadataTags:    capav1.InstanceMetadataEndpointStateDisabled,
+		}),
+		Entry("With authentication Optional", mapiv1.MetadataServiceAuthentication(mapiv1.MetadataServiceAuthenticationOptional), &capav1.InstanceMetadataOptions{
+			HTTPEndpoint:            capav1.InstanceMetadataEndpointStateEnabled,
+			HTTPPutResponseHopLimit: 1,
+			HTTPTokens:              capav1.HTTPTokensStateOptional,
+			InstanceMetadataTags:    capav1.InstanceMetadataEndpointStateDisabled,
+		}),
+		Entry("With authentication unset", mapiv1.MetadataServiceAuthentication(""), &capav1.InstanceMetadataOptions{
+			HTTPEndpoint:            capav1.InstanceMetadataEndpointStateEnabled,
+			HTTPPutResponseHopLimit: 1,
+			InstanceMetadataTags:    capav1.InstanceMetadataEndpointStateDisabled,
+		}),
+	)
+
+	var _ = DescribeTable("mapi2capi AWS convert MAPI securityGroups",
+		func(securityGroups []mapiv1.AWSResourceReference, expected []capav1.AWSResourceReference) {
+			machine := awsMAPIMachineBase.WithProviderSpecBuilder(
+				awsBaseProviderSpec.WithSecurityGroups(securityGroups),
+			).Build()
+
+			_, infraMachine, _, err := FromAWSMachineAndInfra(machine, infra).ToMachineAndInfrastructureMachine()
+			Expect(err).ToNot(HaveOccurred(), "should not fail converting an AWS MAPI Machine to CAPI")
+
+			awsMachine, ok := infraMachine.(*capav1.AWSMachine)
+			Expect(ok).To(BeTrue(), "expected infrastructure machine to be an AWSMachine")
+			Expect(awsMachine.Spec.AdditionalSecurityGroups).To(Equal(expected))
+		},
+		Entry("With security group IDs", []mapiv1.AWSResourceReference{
+			{ID: ptr.To("sg-1234")},
+		}, []capav1.AWSResourceReference{
+			{ID: ptr.To("sg-1234"), Filters: []capav1.Filter{}},
+		}),
+		Entry("With tag filters", []mapiv1.AWSResourceReference{
+			{Filters: []mapiv1.Filter{{Name: "tag:Name", Values: []string{"cluster-worker-sg", "cluster-node-sg"}}}},
+		}, []capav1.AWSResourceReference{
+			{Filters: []capav1.Filter{{Name: "tag:Name", Values: []string{"cluster-worker-sg", "cluster-node-sg"}}}},
+		}),
+		Entry("With mixed IDs and filters", []mapiv1.AWSResourceReference{
+			{ID: ptr.To("sg-1234")},
+			{Filters: []mapiv1.Filter{{Name: "group-name", Values: []string{"workers"}}, {Name: "vpc-id", Values: []string{"vpc-1234"}}}},
+		}, []capav1.AWSResourceReference{
+			{ID: ptr.To("sg-1234"), Filters: []capav1.Filter{}},
+			{Filters: []capav1.Filter{{Name: "group-name", Values: []string{"workers"}}, {Name: "vpc-id", Values: []string{"vpc-1234"}}}},
+		}),
+	)
+
 	var _ = DescribeTable("mapi2capi AWS convert MAPI MachineSet",
 		func(in awsMAPI2CAPIMachinesetConversionInput) {
 			_, _, warns, err := FromAWSMachineSetAndInfra(in.machineSetBuilder.Build(), in.infra).ToMachineSetAndMachineTemplate()
@@ -0,0 +1,764 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mapi2capi
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/ptr"
+	capzv1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// azureResourceIDPrefix is the prefix of all fully qualified Azure resource IDs.
+	azureResourceIDPrefix = "/subscriptions/"
+
+	// azureProviderIDPrefix is the prefix CAPZ expects on resource IDs used as provider IDs.
+	azureProviderIDPrefix = "azure://"
+
+	// azureCommunityGalleryImagePrefix is the prefix of community gallery image version IDs.
+	azureCommunityGalleryImagePrefix = "/CommunityGalleries/"
+
+	// azureSharedGalleryImagePrefix is the prefix of directly shared gallery image version IDs.
+	azureSharedGalleryImagePrefix = "/SharedGalleries/"
+)
+
+var (
+	errUnexpectedObjectTypeForAzureMachine = errors.New("unexpected type for capzMachineObj")
+)
+
+// azureMachineAndInfra stores the details of a Machine API Azure Machine and Infra.
+type azureMachineAndInfra struct {
+	machine        *mapiv1.Machine
+	infrastructure *configv1.Infrastructure
+
+	options AzureConversionOptions
+}
+
+// AzureConversionOptions are the operator level defaults of the Azure conversion.
+// They only ever fill in settings that the MAPI providerSpec does not configure.
+type AzureConversionOptions struct {
+	// ManagedBootDiagnostics enables managed boot diagnostics on the converted AzureMachines when the providerSpec
+	// does not configure boot diagnostics, instead of disabling them to match MAPZ.
+	ManagedBootDiagnostics bool
+}
+
+// azureMachineSetAndInfra stores the details of a Machine API Azure MachineSet and Infra.
+type azureMachineSetAndInfra struct {
+	machineSet     *mapiv1.MachineSet
+	infrastructure *configv1.Infrastructure
+	*azureMachineAndInfra
+}
+
+// FromAzureMachineAndInfra wraps a Machine API Machine for Azure and the OCP Infrastructure object into a mapi2capi AzureProviderSpec.
+func FromAzureMachineAndInfra(m *mapiv1.Machine, i *configv1.Infrastructure) Machine {
+	return FromAzureMachineAndInfraWithOptions(m, i, AzureConversionOptions{})
+}
+
+// FromAzureMachineAndInfraWithOptions wraps a Machine API Machine for Azure and the OCP Infrastructure object into a mapi2capi AzureProviderSpec,
+// converted with the given options.
+func FromAzureMachineAndInfraWithOptions(m *mapiv1.Machine, i *configv1.Infrastructure, options AzureConversionOptions) Machine {
+	return &azureMachineAndInfra{machine: m, infrastructure: i, options: options}
+}
+
+// FromAzureMachineSetAndInfra wraps a Machine API MachineSet for Azure and the OCP Infrastructure object into a mapi2capi AzureProviderSpec.
+func FromAzureMachineSetAndInfra(m *mapiv1.MachineSet, i *configv1.Infrastructure) MachineSet {
+	return FromAzureMachineSetAndInfraWithOptions(m, i, AzureConversionOptions{})
+}
+
+// FromAzureMachineSetAndInfraWithOptions wraps a Machine API MachineSet for Azure and the OCP Infrastructure object into a mapi2capi AzureProviderSpec,
+// converted with the given options.
+func FromAzureMachineSetAndInfraWithOptions(m *mapiv1.MachineSet, i *configv1.Infrastructure, options AzureConversionOptions) MachineSet {
+	return &azureMachineSetAndInfra{
+		machineSet:     m,
+		infrastructure: i,
+		azureMachineAndInfra: &azureMachineAndInfra{
+			machine: &mapiv1.Machine{
+				Spec: m.Spec.Template.Spec,
+			},
+			infrastructure: i,
+			options:        options,
+		},
+	}
+}
+
+// ToMachineAndInfrastructureMachine is used to generate a CAPI Machine and the corresponding InfrastructureMachine
+// from the stored MAPI Machine and Infrastructure objects.
+func (m *azureMachineAndInfra) ToMachineAndInfrastructureMachine() (*capiv1.Machine, client.Object, []string, error) {
+	capiMachine, capzMachine, warnings, errs := m.toMachineAndInfrastructureMachine()
+
+	if len(errs) > 0 {
+		return nil, nil, warnings, errs.ToAggregate()
+	}
+
+	return capiMachine, capzMachine, warnings, nil
+}
+
+func (m *azureMachineAndInfra) toMachineAndInfrastructureMachine() (*capiv1.Machine, client.Object, []string, field.ErrorList) {
+	var (
+		errs     field.ErrorList
+		warnings []string
+	)
+
+	azureProviderSpec, err := azureProviderSpecFromRawExtension(m.machine.Spec.ProviderSpec.Value)
+	if err != nil {
+		return nil, nil, nil, field.ErrorList{field.Invalid(field.NewPath("spec", "providerSpec", "value"), m.machine.Spec.ProviderSpec.Value, err.Error())}
+	}
+
+	capzMachine, warn, machineErrs := m.toAzureMachine(azureProviderSpec)
+	if machineErrs != nil {
+		errs = append(errs, machineErrs...)
+	}
+
+	warnings = append(warnings, warn...)
+
+	capiMachine, machineErrs := fromMAPIMachineToCAPIMachine(m.machine)
+	if machineErrs != nil {
+		errs = append(errs, machineErrs...)
+	}
+
+	// The core conversion always references an AWSMachine, point it at the AzureMachine instead.
+	capiMachine.Spec.InfrastructureRef.APIVersion = capzv1.GroupVersion.String()
+	capiMachine.Spec.InfrastructureRef.Kind = azureMachineKind
+
+	// CAPZ uses the full provider ID to find the virtual machine, so it is carried over as is.
+	capzMachine.Spec.ProviderID = capiMachine.Spec.ProviderID
+
+	// Plug into Core CAPI Machine fields that come from the MAPI ProviderConfig which belong here instead of the CAPI AzureMachineTemplate.
+	if azureProviderSpec.Zone != "" {
+		capiMachine.Spec.FailureDomain = ptr.To(azureProviderSpec.Zone)
+	}
+
+	if azureProviderSpec.UserDataSecret != nil && azureProviderSpec.UserDataSecret.Name != "" {
+		capiMachine.Spec.Bootstrap = capiv1.Bootstrap{
+			DataSecretName: &azureProviderSpec.UserDataSecret.Name,
+		}
+	}
+
+	// Popluate the CAPI Machine ClusterName from the OCP Infrastructure object.
+	if m.infrastructure == nil || m.infrastructure.Status.InfrastructureName == "" {
+		errs = append(errs, field.Invalid(field.NewPath("infrastructure", "status", "infrastructureName"), m.infrastructure.Status.InfrastructureName, "infrastructure cannot be nil and infrastructure.Status.InfrastructureName cannot be empty"))
+	} else {
+		capiMachine.Spec.ClusterName = m.infrastructure.Status.InfrastructureName
+	}
+
+	errs = append(errs, preserveProviderSpecFields(m.machine, capiMachine, "credentialsSecret")...)
+
+	// The InfraMachine should always have the same labels and annotations as the Machine.
+	// See https://github.com/kubernetes-sigs/cluster-api/blob/f88d7ae5155700c2cc367b31ddcc151c9ad579e4/internal/controllers/machineset/machineset_controller.go#L578-L579
+	capzMachine.SetAnnotations(capiMachine.GetAnnotations())
+	capzMachine.SetLabels(capiMachine.GetLabels())
+
+	return capiMachine, capzMachine, warnings, errs
+}
+
+// ToMachineSetAndMachineTemplate converts a mapi2capi AzureMachineSetAndInfra into a CAPI MachineSet and CAPZ AzureMachineTemplate.
+func (m *azureMachineSetAndInfra) ToMachineSetAndMachineTemplate() (*capiv1.MachineSet, client.Object, []string, error) {
+	var (
+		errs     []error
+		warnings []string
+	)
+
+	capiMachine, capzMachineObj, warn, err := m.toMachineAndInfrastructureMachine()
+	if err != nil {
+		errs = append(errs, err.ToAggregate().Errors()...)
+	}
+
+	warnings = append(warnings, warn...)
+
+	capzMachine, ok := capzMachineObj.(*capzv1.AzureMachine)
+	if !ok {
+		panic(fmt.Errorf("%w: %T", errUnexpectedObjectTypeForAzureMachine, capzMachineObj))
+	}
+
+	capzMachineTemplate := azureMachineToAzureMachineTemplate(capzMachine, m.machineSet.Name, capiNamespace)
+
+	capiMachineSet, machineSetErrs := fromMAPIMachineSetToCAPIMachineSet(m.machineSet)
+	if machineSetErrs != nil {
+		errs = append(errs, machineSetErrs.Errors()...)
+	}
+
+	capiMachineSet.Spec.Template.Spec = capiMachine.Spec
+
+	// We have to merge these two maps so that labels and annotations added to the template objectmeta are persisted
+	// along with the labels and annotations from the machine objectmeta.
+	capiMachineSet.Spec.Template.ObjectMeta.Labels = mergeMaps(capiMachineSet.Spec.Template.ObjectMeta.Labels, capiMachine.Labels)
+	capiMachineSet.Spec.Template.ObjectMeta.Annotations = mergeMaps(capiMachineSet.Spec.Template.ObjectMeta.Annotations, capiMachine.Annotations)
+
+	// Override the reference so that it matches the AzureMachineTemplate.
+	capiMachineSet.Spec.Template.Spec.InfrastructureRef.Kind = azureMachineTemplateKind
+	capiMachineSet.Spec.Template.Spec.InfrastructureRef.Name = capzMachineTemplate.Name
+
+	if m.infrastructure == nil || m.infrastructure.Status.InfrastructureName == "" {
+		errs = append(errs, field.Invalid(field.NewPath("infrastructure", "status", "infrastructureName"), m.infrastructure.Status.InfrastructureName, "infrastructure cannot be nil and infrastructure.Status.InfrastructureName cannot be empty"))
+	} else {
+		capiMachineSet.Spec.Template.Spec.ClusterName = m.infrastructure.Status.InfrastructureName
+		capiMachineSet.Spec.ClusterName = m.infrastructure.Status.InfrastructureName
+	}
+
+	if len(errs) > 0 {
+		return nil, nil, warnings, utilerrors.NewAggregate(errs)
+	}
+
+	return capiMachineSet, capzMachineTemplate, warnings, nil
+}
+
+// toAzureMachine implements the ProviderSpec conversion interface for the Azure provider,
+// it converts AzureMachineProviderSpec to AzureMachine.
+//
+//nolint:funlen
+func (m *azureMachineAndInfra) toAzureMachine(providerSpec mapiv1.AzureMachineProviderSpec) (*capzv1.AzureMachine, []string, field.ErrorList) {
+	fldPath := field.NewPath("spec", "providerSpec", "value")
+
+	var (
+		errs     field.ErrorList
+		warnings []string
+	)
+
+	image, imageErrs := convertAzureImageToCAPI(fldPath.Child("image"), providerSpec.Image)
+	errs = append(errs, imageErrs...)
+
+	osDisk := convertAzureOSDiskToCAPI(providerSpec.OSDisk)
+
+	dataDisks, dataDiskErrs := convertAzureDataDisksToCAPI(fldPath.Child("dataDisks"), providerSpec.DataDisks)
+	errs = append(errs, dataDiskErrs...)
+
+	securityProfile, securityProfileErrs := convertAzureSecurityProfileToCAPI(fldPath.Child("securityProfile"), providerSpec.SecurityProfile)
+	errs = append(errs, securityProfileErrs...)
+
+	identity, userAssignedIdentities, err := convertAzureManagedIdentityToCAPI(fldPath.Child("managedIdentity"), providerSpec.ManagedIdentity)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	spec := capzv1.AzureMachineSpec{
+		// ProviderID. This is populated when this is called in higher level funcs (ToMachine(), ToMachineSet()).
+		VMSize: providerSpec.VMSize,
+		// FailureDomain. This is populated on the CAPI Machine instead, from the MAPI zone.
+		Image:                  image,
+		Identity:               identity,
+		UserAssignedIdentities: userAssignedIdentities,
+		OSDisk:                 osDisk,
+		DataDisks:              dataDisks,
+		SSHPublicKey:           providerSpec.SSHPublicKey,
+		AdditionalTags:         convertAzureTagsToCAPI(providerSpec.Tags),
+		AllocatePublicIP:       providerSpec.PublicIP,
+		AcceleratedNetworking:  ptr.To(providerSpec.AcceleratedNetworking),
+		SpotVMOptions:          convertAzureSpotVMOptionsToCAPI(providerSpec.SpotVMOptions),
+		SubnetName:             providerSpec.Subnet,
+		AdditionalCapabilities: convertAzureUltraSSDCapabilityToCAPI(providerSpec.UltraSSDCapability),
+		SecurityProfile:        securityProfile,
+		Diagnostics:            convertAzureDiagnosticsToCAPI(providerSpec.Diagnostics, m.options.ManagedBootDiagnostics),
+
+		// SystemAssignedIdentityRole. Not used in OpenShift.
+		// RoleAssignmentName. Not used in OpenShift.
+		// EnableIPForwarding. Not used in OpenShift.
+		// DNSServers. Not used in OpenShift.
+		// VMExtensions. Not used in OpenShift.
+		// NetworkInterfaces. Not used in OpenShift, the single interface is configured from the subnet.
+	}
+
+	if providerSpec.CapacityReservationGroupID != "" {
+		spec.CapacityReservationGroupID = ptr.To(providerSpec.CapacityReservationGroupID)
+	}
+
+	// Unused fields - Below this line are fields not used from the MAPI AzureMachineProviderSpec.
+
+	// TypeMeta - Only for the purpose of the raw extension, not used for any functionality.
+	// CredentialsSecret - TODO(OCPCLOUD-2713): Work out what needs to happen regarding credentials secrets.
+	// Location - Set on the AzureCluster, CAPZ always creates machines in the cluster location.
+	// Vnet - Set on the AzureCluster network spec.
+	// SecurityGroup - Set on the AzureCluster subnet spec.
+	// PublicLoadBalancer - Set on the AzureCluster as the node outbound load balancer.
+	// ProximityPlacementGroupID - TODO(OCPCLOUD-xxxx): Neither the openshift/api AzureMachineProviderSpec nor the CAPZ v1.15
+	//   AzureMachineSpec have a proximity placement group field, so a providerSpec setting one is converted without it.
+	//   Once both carry one, convert it here and check that the placement group resource ID is in the cluster location.
+
+	errs = append(errs, m.validateAzureResourceGroups(fldPath, providerSpec)...)
+
+	if !reflect.DeepEqual(providerSpec.ObjectMeta, metav1.ObjectMeta{}) {
+		// We don't support setting the object metadata in the provider spec.
+		// It's only present for the purpose of the raw extension and doesn't have any functionality.
+		errs = append(errs, field.Invalid(fldPath.Child("metadata"), providerSpec.ObjectMeta, "metadata is not supported"))
+	}
+
+	if providerSpec.InternalLoadBalancer != "" {
+		// CAPZ only attaches the control plane machines to the internal load balancer of the AzureCluster.
+		if isControlPlaneMachine(m.machine) {
+			warnings = append(warnings, field.Invalid(fldPath.Child("internalLoadBalancer"), providerSpec.InternalLoadBalancer, "internalLoadBalancer is not converted, control plane machines are attached to the AzureCluster internal load balancer").Error())
+		} else {
+			errs = append(errs, field.Invalid(fldPath.Child("internalLoadBalancer"), providerSpec.InternalLoadBalancer, "internalLoadBalancer is not supported"))
+		}
+	}
+
+	errs = append(errs, handleUnsupportedAzureProviderSpecFields(fldPath, providerSpec)...)
+
+	return &capzv1.AzureMachine{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: capzv1.GroupVersion.String(),
+			Kind:       azureMachineKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.machine.Name,
+			Namespace: capiNamespace,
+		},
+		Spec: spec,
+	}, warnings, errs
+}
+
+// validateAzureResourceGroups checks that the resource groups in the provider spec match those of the cluster.
+// CAPZ takes the resource groups from the AzureCluster, so machines cannot be placed in any other resource group.
+func (m *azureMachineAndInfra) validateAzureResourceGroups(fldPath *field.Path, providerSpec mapiv1.AzureMachineProviderSpec) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if m.infrastructure == nil || m.infrastructure.Status.PlatformStatus == nil || m.infrastructure.Status.PlatformStatus.Azure == nil {
+		return errs
+	}
+
+	azurePlatformStatus := m.infrastructure.Status.PlatformStatus.Azure
+
+	if azurePlatformStatus.ResourceGroupName != "" && providerSpec.ResourceGroup != "" && providerSpec.ResourceGroup != azurePlatformStatus.ResourceGroupName {
+		errs = append(errs, field.Invalid(fldPath.Child("resourceGroup"), providerSpec.ResourceGroup, fmt.Sprintf("resourceGroup should match infrastructure status value %q", azurePlatformStatus.ResourceGroupName)))
+	}
+
+	if azurePlatformStatus.NetworkResourceGroupName != "" && providerSpec.NetworkResourceGroup != "" && providerSpec.NetworkResourceGroup != azurePlatformStatus.NetworkResourceGroupName {
+		errs = append(errs, field.Invalid(fldPath.Child("networkResourceGroup"), providerSpec.NetworkResourceGroup, fmt.Sprintf("networkResourceGroup should match infrastructure status value %q", azurePlatformStatus.NetworkResourceGroupName)))
+	}
+
+	return errs
+}
+
+// azureProviderSpecFromRawExtension unmarshals a raw extension into an AzureMachineProviderSpec type.
+func azureProviderSpecFromRawExtension(rawExtension *runtime.RawExtension) (mapiv1.AzureMachineProviderSpec, error) {
+	if rawExtension == nil {
+		return mapiv1.AzureMachineProviderSpec{}, nil
+	}
+
+	spec := mapiv1.AzureMachineProviderSpec{}
+	if err := yaml.Unmarshal(rawExtension.Raw, &spec); err != nil {
+		return mapiv1.AzureMachineProviderSpec{}, fmt.Errorf("error unmarshalling providerSpec: %w", err)
+	}
+
+	return spec, nil
+}
+
+func azureMachineToAzureMachineTemplate(azureMachine *capzv1.AzureMachine, name string, namespace string) *capzv1.AzureMachineTemplate {
+	return &capzv1.AzureMachineTemplate{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: capzv1.GroupVersion.String(),
+			Kind:       azureMachineTemplateKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: capzv1.AzureMachineTemplateSpec{
+			Template: capzv1.AzureMachineTemplateResource{
+				Spec: azureMachine.Spec,
+			},
+		},
+	}
+}
+
+//////// Conversion helpers
+
+func convertAzureImageToCAPI(fldPath *field.Path, mapiImage mapiv1.Image) (*capzv1.Image, field.ErrorList) {
+	hasMarketplaceFields := mapiImage.Publisher != "" || mapiImage.Offer != "" || mapiImage.SKU != "" || mapiImage.Version != ""
+
+	switch {
+	case mapiImage.ResourceID == "":
+		return convertAzureMarketplaceImageToCAPI(fldPath, mapiImage)
+	case mapiImage.Type == mapiv1.AzureImageTypeMarketplaceWithPlan:
+		// Gallery images built from marketplace images carry the purchase plan of the original image.
+		return convertAzureGalleryImageWithPlanToCAPI(fldPath, mapiImage)
+	case hasMarketplaceFields:
+		return nil, field.ErrorList{field.Invalid(fldPath, mapiImage, "publisher, offer, sku and version can only be set alongside resourceID when type is MarketplaceWithPlan")}
+	case strings.HasPrefix(mapiImage.ResourceID, azureSharedGalleryImagePrefix):
+		return nil, field.ErrorList{field.Forbidden(fldPath.Child("resourceID"), "directly shared gallery images are not supported on Cluster API")}
+	case strings.HasPrefix(mapiImage.ResourceID, azureCommunityGalleryImagePrefix):
+		computeGallery, err := parseAzureGalleryImageID(fldPath.Child("resourceID"), mapiImage.ResourceID)
+		if err != nil {
+			return nil, field.ErrorList{err}
+		}
+
+		return &capzv1.Image{ComputeGallery: computeGallery}, nil
+	default:
+		// Managed images and compute gallery images without a purchase plan are both referenced by ID.
+		return &capzv1.Image{ID: ptr.To(mapiImage.ResourceID)}, nil
+	}
+}
+
+func convertAzureMarketplaceImageToCAPI(fldPath *field.Path, mapiImage mapiv1.Image) (*capzv1.Image, field.ErrorList) {
+	errs := field.ErrorList{}
+
+	if mapiImage.Type == mapiv1.AzureImageTypeID {
+		errs = append(errs, field.Required(fldPath.Child("resourceID"), "resourceID is required when type is ID"))
+	}
+
+	errs = append(errs, requireAzureImagePlanFields(fldPath, mapiImage)...)
+
+	if mapiImage.Version == "" {
+		errs = append(errs, field.Required(fldPath.Child("version"), "version is required for marketplace images"))
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return &capzv1.Image{
+		Marketplace: &capzv1.AzureMarketplaceImage{
+			ImagePlan: capzv1.ImagePlan{
+				Publisher: mapiImage.Publisher,
+				Offer:     mapiImage.Offer,
+				SKU:       mapiImage.SKU,
+			},
+			Version:         mapiImage.Version,
+			ThirdPartyImage: mapiImage.Type == mapiv1.AzureImageTypeMarketplaceWithPlan,
+		},
+	}, nil
+}
+
+func convertAzureGalleryImageWithPlanToCAPI(fldPath *field.Path, mapiImage mapiv1.Image) (*capzv1.Image, field.ErrorList) {
+	errs := requireAzureImagePlanFields(fldPath, mapiImage)
+
+	if mapiImage.Version != "" {
+		errs = append(errs, field.Invalid(fldPath.Child("version"), mapiImage.Version, "version cannot be set alongside resourceID, the image version is part of the resourceID"))
+	}
+
+	computeGallery, err := parseAzureGalleryImageID(fldPath.Child("resourceID"), mapiImage.ResourceID)
+	if err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	computeGallery.Plan = &capzv1.ImagePlan{
+		Publisher: mapiImage.Publisher,
+		Offer:     mapiImage.Offer,
+		SKU:       mapiImage.SKU,
+	}
+
+	return &capzv1.Image{ComputeGallery: computeGallery}, nil
+}
+
+func requireAzureImagePlanFields(fldPath *field.Path, mapiImage mapiv1.Image) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if mapiImage.Publisher == "" {
+		errs = append(errs, field.Required(fldPath.Child("publisher"), "publisher is required for marketplace images"))
+	}
+
+	if mapiImage.Offer == "" {
+		errs = append(errs, field.Required(fldPath.Child("offer"), "offer is required for marketplace images"))
+	}
+
+	if mapiImage.SKU == "" {
+		errs = append(errs, field.Required(fldPath.Child("sku"), "sku is required for marketplace images"))
+	}
+
+	return errs
+}
+
+// parseAzureGalleryImageID parses a compute gallery image version ID, in either the
+// /subscriptions/<sub>/resourceGroups/<rg>/providers/Microsoft.Compute/galleries/<gallery>/images/<name>/versions/<version>
+// or the /CommunityGalleries/<gallery>/Images/<name>/Versions/<version> form.
+func parseAzureGalleryImageID(fldPath *field.Path, resourceID string) (*capzv1.AzureComputeGalleryImage, *field.Error) {
+	segments := strings.Split(strings.TrimPrefix(resourceID, "/"), "/")
+
+	switch {
+	case len(segments) == 6 && strings.EqualFold(segments[0], "CommunityGalleries") &&
+		strings.EqualFold(segments[2], "Images") && strings.EqualFold(segments[4], "Versions"):
+		return &capzv1.AzureComputeGalleryImage{
+			Gallery: segments[1],
+			Name:    segments[3],
+			Version: segments[5],
+		}, nil
+	case len(segments) == 12 && strings.EqualFold(segments[0], "subscriptions") && strings.EqualFold(segments[2], "resourceGroups") &&
+		strings.EqualFold(segments[4], "providers") && strings.EqualFold(segments[5], "Microsoft.Compute") && strings.EqualFold(segments[6], "galleries") &&
+		strings.EqualFold(segments[8], "images") && strings.EqualFold(segments[10], "versions"):
+		return &capzv1.AzureComputeGalleryImage{
+			SubscriptionID: ptr.To(segments[1]),
+			ResourceGroup:  ptr.To(segments[3]),
+			Gallery:        segments[7],
+			Name:           segments[9],
+			Version:        segments[11],
+		}, nil
+	default:
+		return nil, field.Invalid(fldPath, resourceID, "resourceID must be a compute gallery or community gallery image version ID")
+	}
+}
+
+func convertAzureOSDiskToCAPI(mapiOSDisk mapiv1.OSDisk) capzv1.OSDisk {
+	osDisk := capzv1.OSDisk{
+		OSType:      mapiOSDisk.OSType,
+		CachingType: mapiOSDisk.CachingType,
+		ManagedDisk: &capzv1.ManagedDiskParameters{
+			StorageAccountType: mapiOSDisk.ManagedDisk.StorageAccountType,
+		},
+	}
+
+	if mapiOSDisk.DiskSizeGB > 0 {
+		osDisk.DiskSizeGB = ptr.To(mapiOSDisk.DiskSizeGB)
+	}
+
+	if mapiOSDisk.ManagedDisk.DiskEncryptionSet != nil {
+		osDisk.ManagedDisk.DiskEncryptionSet = &capzv1.DiskEncryptionSetParameters{
+			ID: mapiOSDisk.ManagedDisk.DiskEncryptionSet.ID,
+		}
+	}
+
+	if mapiOSDisk.DiskSettings.EphemeralStorageLocation != "" {
+		osDisk.DiffDiskSettings = &capzv1.DiffDiskSettings{
+			Option: mapiOSDisk.DiskSettings.EphemeralStorageLocation,
+		}
+	}
+
+	if !reflect.DeepEqual(mapiOSDisk.ManagedDisk.SecurityProfile, mapiv1.VMDiskSecurityProfile{}) {
+		osDisk.ManagedDisk.SecurityProfile = &capzv1.VMDiskSecurityProfile{
+			SecurityEncryptionType: capzv1.SecurityEncryptionType(mapiOSDisk.ManagedDisk.SecurityProfile.SecurityEncryptionType),
+		}
+
+		if mapiOSDisk.ManagedDisk.SecurityProfile.DiskEncryptionSet.ID != "" {
+			osDisk.ManagedDisk.SecurityProfile.DiskEncryptionSet = &capzv1.DiskEncryptionSetParameters{
+				ID: mapiOSDisk.ManagedDisk.SecurityProfile.DiskEncryptionSet.ID,
+			}
+		}
+	}
+
+	return osDisk
+}
+
+// convertAzureSecurityProfileToCAPI converts the MAPI security profile to its CAPZ equivalent.
+// MAPI nests the UEFI settings under the settings for the chosen security type, whereas CAPZ has a single set of UEFI settings.
+func convertAzureSecurityProfileToCAPI(fldPath *field.Path, mapiSecurityProfile *mapiv1.SecurityProfile) (*capzv1.SecurityProfile, field.ErrorList) {
+	if mapiSecurityProfile == nil {
+		return nil, nil
+	}
+
+	errs := field.ErrorList{}
+	settingsPath := fldPath.Child("settings")
+	settings := mapiSecurityProfile.Settings
+
+	securityProfile := &capzv1.SecurityProfile{
+		EncryptionAtHost: mapiSecurityProfile.EncryptionAtHost,
+		SecurityType:     capzv1.SecurityTypes(settings.SecurityType),
+	}
+
+	if settings.ConfidentialVM != nil && settings.SecurityType != mapiv1.SecurityTypesConfidentialVM {
+		errs = append(errs, field.Invalid(settingsPath.Child("confidentialVM"), settings.ConfidentialVM, fmt.Sprintf("confidentialVM may only be set when securityType is %q", mapiv1.SecurityTypesConfidentialVM)))
+	}
+
+	if settings.TrustedLaunch != nil && settings.SecurityType != mapiv1.SecurityTypesTrustedLaunch {
+		errs = append(errs, field.Invalid(settingsPath.Child("trustedLaunch"), settings.TrustedLaunch, fmt.Sprintf("trustedLaunch may only be set when securityType is %q", mapiv1.SecurityTypesTrustedLaunch)))
+	}
+
+	switch {
+	case settings.SecurityType == mapiv1.SecurityTypesConfidentialVM && settings.ConfidentialVM != nil:
+		securityProfile.UefiSettings = convertAzureUEFISettingsToCAPI(settings.ConfidentialVM.UEFISettings)
+	case settings.SecurityType == mapiv1.SecurityTypesTrustedLaunch && settings.TrustedLaunch != nil:
+		securityProfile.UefiSettings = convertAzureUEFISettingsToCAPI(settings.TrustedLaunch.UEFISettings)
+	}
+
+	return securityProfile, errs
+}
+
+func convertAzureUEFISettingsToCAPI(mapiUEFISettings mapiv1.UEFISettings) *capzv1.UefiSettings {
+	if mapiUEFISettings == (mapiv1.UEFISettings{}) {
+		return nil
+	}
+
+	return &capzv1.UefiSettings{
+		SecureBootEnabled: convertAzurePolicyToCAPI(string(mapiUEFISettings.SecureBoot)),
+		VTpmEnabled:       convertAzurePolicyToCAPI(string(mapiUEFISettings.VirtualizedTrustedPlatformModule)),
+	}
+}
+
+// convertAzurePolicyToCAPI converts an Enabled/Disabled MAPI policy to an optional CAPZ boolean.
+// The secure boot and vTPM policies share the same values.
+func convertAzurePolicyToCAPI(policy string) *bool {
+	switch policy {
+	case string(mapiv1.SecureBootPolicyEnabled):
+		return ptr.To(true)
+	case string(mapiv1.SecureBootPolicyDisabled):
+		return ptr.To(false)
+	default:
+		return nil
+	}
+}
+
+// convertAzureDataDisksToCAPI converts the MAPI data disks to their CAPZ equivalent.
+// CAPZ always deletes data disks along with the machine, so only the Delete deletion policy can be converted.
+func convertAzureDataDisksToCAPI(fldPath *field.Path, mapiDataDisks []mapiv1.DataDisk) ([]capzv1.DataDisk, field.ErrorList) {
+	if len(mapiDataDisks) == 0 {
+		return nil, nil
+	}
+
+	errs := field.ErrorList{}
+	dataDisks := make([]capzv1.DataDisk, 0, len(mapiDataDisks))
+
+	for i, mapiDataDisk := range mapiDataDisks {
+		if mapiDataDisk.DeletionPolicy != "" && mapiDataDisk.DeletionPolicy != mapiv1.DiskDeletionPolicyTypeDelete {
+			errs = append(errs, field.Invalid(fldPath.Index(i).Child("deletionPolicy"), mapiDataDisk.DeletionPolicy,
+				fmt.Sprintf("deletionPolicy must be %q, CAPZ always deletes data disks with the machine", mapiv1.DiskDeletionPolicyTypeDelete)))
+		}
+
+		dataDisk := capzv1.DataDisk{
+			NameSuffix: mapiDataDisk.NameSuffix,
+			DiskSizeGB: mapiDataDisk.DiskSizeGB,
+			ManagedDisk: &capzv1.ManagedDiskParameters{
+				StorageAccountType: string(mapiDataDisk.ManagedDisk.StorageAccountType),
+			},
+			Lun:         ptr.To(mapiDataDisk.Lun),
+			CachingType: string(mapiDataDisk.CachingType),
+		}
+
+		if mapiDataDisk.ManagedDisk.DiskEncryptionSet != nil {
+			dataDisk.ManagedDisk.DiskEncryptionSet = &capzv1.DiskEncryptionSetParameters{
+				ID: mapiDataDisk.ManagedDisk.DiskEncryptionSet.ID,
+			}
+		}
+
+		dataDisks = append(dataDisks, dataDisk)
+	}
+
+	return dataDisks, errs
+}
+
+// convertAzureUltraSSDCapabilityToCAPI converts the MAPI UltraSSD capability to the CAPZ additional capabilities.
+// When the capability is not set, both MAPZ and CAPZ enable it if any data disk uses the UltraSSD_LRS storage account type.
+func convertAzureUltraSSDCapabilityToCAPI(ultraSSDCapability mapiv1.AzureUltraSSDCapabilityState) *capzv1.AdditionalCapabilities {
+	switch ultraSSDCapability {
+	case mapiv1.AzureUltraSSDCapabilityEnabled:
+		return &capzv1.AdditionalCapabilities{UltraSSDEnabled: ptr.To(true)}
+	case mapiv1.AzureUltraSSDCapabilityDisabled:
+		return &capzv1.AdditionalCapabilities{UltraSSDEnabled: ptr.To(false)}
+	default:
+		return nil
+	}
+}
+
+// convertAzureDiagnosticsToCAPI converts the MAPI boot diagnostics to their CAPZ equivalent.
+// MAPZ does not enable boot diagnostics unless they are configured, whereas CAPZ enables managed
+// boot diagnostics by default, so they are disabled explicitly when not configured, unless managed boot diagnostics
+// are requested by default.
+func convertAzureDiagnosticsToCAPI(mapiDiagnostics mapiv1.AzureDiagnostics, managedByDefault bool) *capzv1.Diagnostics {
+	if mapiDiagnostics.Boot == nil {
+		storageAccountType := capzv1.DisabledDiagnosticsStorage
+		if managedByDefault {
+			storageAccountType = capzv1.ManagedDiagnosticsStorage
+		}
+
+		return &capzv1.Diagnostics{
+			Boot: &capzv1.BootDiagnostics{StorageAccountType: storageAccountType},
+		}
+	}
+
+	switch mapiDiagnostics.Boot.StorageAccountType {
+	case mapiv1.CustomerManagedAzureDiagnosticsStorage:
+		bootDiagnostics := &capzv1.BootDiagnostics{StorageAccountType: capzv1.UserManagedDiagnosticsStorage}
+
+		if mapiDiagnostics.Boot.CustomerManaged != nil {
+			bootDiagnostics.UserManaged = &capzv1.UserManagedBootDiagnostics{
+				StorageAccountURI: mapiDiagnostics.Boot.CustomerManaged.StorageAccountURI,
+			}
+		}
+
+		return &capzv1.Diagnostics{Boot: bootDiagnostics}
+	default:
+		return &capzv1.Diagnostics{
+			Boot: &capzv1.BootDiagnostics{StorageAccountType: capzv1.ManagedDiagnosticsStorage},
+		}
+	}
+}
+
+// convertAzureManagedIdentityToCAPI converts the MAPI managed identity to a CAPZ user assigned identity.
+// MAPZ builds the resource ID of an identity given by name from the credentials subscription and the cluster
+// resource group, which is not known at conversion time, so only fully qualified resource IDs can be converted.
+func convertAzureManagedIdentityToCAPI(fldPath *field.Path, managedIdentity string) (capzv1.VMIdentity, []capzv1.UserAssignedIdentity, *field.Error) {
+	if managedIdentity == "" {
+		return capzv1.VMIdentityNone, nil, nil
+	}
+
+	if !strings.HasPrefix(managedIdentity, azureResourceIDPrefix) {
+		return capzv1.VMIdentityNone, nil, field.Invalid(fldPath, managedIdentity, fmt.Sprintf("managedIdentity must be a resource ID starting with %q", azureResourceIDPrefix))
+	}
+
+	return capzv1.VMIdentityUserAssigned, []capzv1.UserAssignedIdentity{{ProviderID: azureProviderIDPrefix + managedIdentity}}, nil
+}
+
+// convertAzureSpotVMOptionsToCAPI converts the MAPI spot VM options to their CAPZ equivalent.
+// MAPZ always creates spot VMs with the Delete eviction policy, whereas CAPZ defaults to Deallocate,
+// so the eviction policy is set explicitly to keep evicted machines from lingering as deallocated VMs.
+func convertAzureSpotVMOptionsToCAPI(mapiSpotVMOptions *mapiv1.SpotVMOptions) *capzv1.SpotVMOptions {
+	if mapiSpotVMOptions == nil {
+		return nil
+	}
+
+	return &capzv1.SpotVMOptions{
+		MaxPrice:       mapiSpotVMOptions.MaxPrice,
+		EvictionPolicy: ptr.To(capzv1.SpotEvictionPolicyDelete),
+	}
+}
+
+func convertAzureTagsToCAPI(mapiTags map[string]string) capzv1.Tags {
+	if len(mapiTags) == 0 {
+		return nil
+	}
+
+	capzTags := capzv1.Tags{}
+	for key, value := range mapiTags {
+		capzTags[key] = value
+	}
+
+	return capzTags
+}
+
+func handleUnsupportedAzureProviderSpecFields(fldPath *field.Path, providerSpec mapiv1.AzureMachineProviderSpec) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if providerSpec.AvailabilitySet != "" {
+		// CAPZ creates its own availability sets for machines without a failure domain, it cannot reference an existing one.
+		// Report this as Forbidden so that the sync controller can surface it as unsupported on CAPI rather than as a conversion failure.
+		errs = append(errs, field.Forbidden(fldPath.Child("availabilitySet"), "availabilitySet is not supported on Cluster API, CAPZ only places machines in availability sets it creates itself"))
+	}
+
+	if len(providerSpec.ApplicationSecurityGroups) > 0 {
+		// CAPZ has no support for application security groups.
+		errs = append(errs, field.Invalid(fldPath.Child("applicationSecurityGroups"), providerSpec.ApplicationSecurityGroups, "applicationSecurityGroups are not supported"))
+	}
+
+	if providerSpec.NatRule != nil {
+		// CAPZ manages the inbound NAT rules itself and they cannot be configured per machine.
+		errs = append(errs, field.Invalid(fldPath.Child("natRule"), *providerSpec.NatRule, "natRule is not supported"))
+	}
+
+	return errs
+}
@@ -0,0 +1,280 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mapi2capi_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	fuzz "github.com/google/gofuzz"
+
+	configv1 "github.com/openshift/api/config/v1"
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/capi2mapi"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/mapi2capi"
+	conversiontest "github.com/openshift/cluster-capi-operator/pkg/conversion/test/fuzz"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeserializer "k8s.io/apimachinery/pkg/runtime/serializer"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	capzv1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	azureLocation             = "eastus"
+	azureResourceGroup        = "sample-cluster-rg"
+	azureNetworkResourceGroup = "sample-cluster-network-rg"
+	azureVnet                 = "sample-cluster-vnet"
+	azureSubnet               = "sample-cluster-worker-subnet"
+	azureSecurityGroup        = "sample-cluster-nsg"
+	azureNodeOutboundLB       = "sample-cluster"
+)
+
+var _ = Describe("Azure Fuzz (mapi2capi)", func() {
+	infra := &configv1.Infrastructure{
+		Spec: configv1.InfrastructureSpec{},
+		Status: configv1.InfrastructureStatus{
+			InfrastructureName: "sample-cluster-name",
+		},
+	}
+
+	infraCluster := &capzv1.AzureCluster{
+		Spec: capzv1.AzureClusterSpec{
+			AzureClusterClassSpec: capzv1.AzureClusterClassSpec{
+				Location: azureLocation,
+			},
+			ResourceGroup: azureResourceGroup,
+			NetworkSpec: capzv1.NetworkSpec{
+				Vnet: capzv1.VnetSpec{
+					Name:          azureVnet,
+					ResourceGroup: azureNetworkResourceGroup,
+				},
+				Subnets: capzv1.Subnets{
+					{
+						SubnetClassSpec: capzv1.SubnetClassSpec{Name: azureSubnet, Role: capzv1.SubnetNode},
+						SecurityGroup:   capzv1.SecurityGroup{Name: azureSecurityGroup},
+					},
+				},
+				NodeOutboundLB: &capzv1.LoadBalancerSpec{Name: azureNodeOutboundLB},
+			},
+		},
+	}
+
+	Context("AzureMachine Conversion", func() {
+		fromMachineAndAzureMachineAndAzureCluster := func(machine *capiv1.Machine, infraMachine client.Object, infraCluster client.Object) capi2mapi.MachineAndInfrastructureMachine {
+			azureMachine, ok := infraMachine.(*capzv1.AzureMachine)
+			Expect(ok).To(BeTrue(), "input infra machine should be of type %T, got %T", &capzv1.AzureMachine{}, infraMachine)
+
+			azureCluster, ok := infraCluster.(*capzv1.AzureCluster)
+			Expect(ok).To(BeTrue(), "input infra cluster should be of type %T, got %T", &capzv1.AzureCluster{}, infraCluster)
+
+			return capi2mapi.FromMachineAndAzureMachineAndAzureCluster(machine, azureMachine, azureCluster)
+		}
+
+		conversiontest.MAPI2CAPIMachineRoundTripFuzzTest(
+			scheme,
+			infra,
+			infraCluster,
+			mapi2capi.FromAzureMachineAndInfra,
+			fromMachineAndAzureMachineAndAzureCluster,
+			conversiontest.ObjectMetaFuzzerFuncs(mapiNamespace),
+			conversiontest.MAPIMachineFuzzerFuncs(&mapiv1.AzureMachineProviderSpec{}, azureProviderIDFuzzer),
+			azureProviderSpecFuzzerFuncs,
+		)
+	})
+
+	Context("AzureMachineSet Conversion", func() {
+		fromMachineSetAndAzureMachineTemplateAndAzureCluster := func(machineSet *capiv1.MachineSet, infraMachineTemplate client.Object, infraCluster client.Object) capi2mapi.MachineSetAndMachineTemplate {
+			azureMachineTemplate, ok := infraMachineTemplate.(*capzv1.AzureMachineTemplate)
+			Expect(ok).To(BeTrue(), "input infra machine template should be of type %T, got %T", &capzv1.AzureMachineTemplate{}, infraMachineTemplate)
+
+			azureCluster, ok := infraCluster.(*capzv1.AzureCluster)
+			Expect(ok).To(BeTrue(), "input infra cluster should be of type %T, got %T", &capzv1.AzureCluster{}, infraCluster)
+
+			return capi2mapi.FromMachineSetAndAzureMachineTemplateAndAzureCluster(machineSet, azureMachineTemplate, azureCluster)
+		}
+
+		conversiontest.MAPI2CAPIMachineSetRoundTripFuzzTest(
+			scheme,
+			infra,
+			infraCluster,
+			mapi2capi.FromAzureMachineSetAndInfra,
+			fromMachineSetAndAzureMachineTemplateAndAzureCluster,
+			conversiontest.ObjectMetaFuzzerFuncs(mapiNamespace),
+			conversiontest.MAPIMachineFuzzerFuncs(&mapiv1.AzureMachineProviderSpec{}, azureProviderIDFuzzer),
+			conversiontest.MAPIMachineSetFuzzerFuncs(),
+			azureProviderSpecFuzzerFuncs,
+		)
+	})
+})
+
+func azureProviderIDFuzzer(c fuzz.Continue) string {
+	return "azure:///subscriptions/sub/resourceGroups/" + azureResourceGroup + "/providers/Microsoft.Compute/virtualMachines/" + strings.ReplaceAll(c.RandString(), "/", "")
+}
+
+//nolint:funlen
+func azureProviderSpecFuzzerFuncs(codecs runtimeserializer.CodecFactory) []interface{} {
+	return []interface{}{
+		func(image *mapiv1.Image, c fuzz.Continue) {
+			name := func() string { return "n" + strings.ReplaceAll(c.RandString(), "/", "") }
+			galleryID := "/subscriptions/" + name() + "/resourceGroups/" + name() + "/providers/Microsoft.Compute/galleries/" + name() + "/images/" + name() + "/versions/" + name()
+			communityGalleryID := "/CommunityGalleries/" + name() + "/Images/" + name() + "/Versions/" + name()
+
+			// Only purchase plan images set the publisher, offer and sku alongside a resourceID,
+			// and the conversion only sets the type where it is needed to tell images apart.
+			switch c.Intn(5) {
+			case 0:
+				*image = mapiv1.Image{ResourceID: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/images/" + name()}
+			case 1:
+				*image = mapiv1.Image{ResourceID: galleryID}
+			case 2:
+				*image = mapiv1.Image{ResourceID: communityGalleryID}
+			case 3:
+				*image = mapiv1.Image{
+					ResourceID: []string{galleryID, communityGalleryID}[c.Intn(2)],
+					Publisher:  name(),
+					Offer:      name(),
+					SKU:        name(),
+					Type:       mapiv1.AzureImageTypeMarketplaceWithPlan,
+				}
+			case 4:
+				*image = mapiv1.Image{
+					Publisher: name(),
+					Offer:     name(),
+					SKU:       name(),
+					Version:   name(),
+					Type:      []mapiv1.AzureImageType{mapiv1.AzureImageTypeMarketplaceNoPlan, mapiv1.AzureImageTypeMarketplaceWithPlan}[c.Intn(2)],
+				}
+			}
+		},
+		func(osDisk *mapiv1.OSDisk, c fuzz.Continue) {
+			c.FuzzNoCustom(osDisk)
+
+			// The disk size is only converted when set.
+			if osDisk.DiskSizeGB < 0 {
+				osDisk.DiskSizeGB = -osDisk.DiskSizeGB
+			}
+
+		},
+		func(uefiSettings *mapiv1.UEFISettings, c fuzz.Continue) {
+			policies := []string{"", string(mapiv1.SecureBootPolicyEnabled), string(mapiv1.SecureBootPolicyDisabled)}
+
+			uefiSettings.SecureBoot = mapiv1.SecureBootPolicy(policies[c.Intn(len(policies))])
+			uefiSettings.VirtualizedTrustedPlatformModule = mapiv1.VirtualizedTrustedPlatformModulePolicy(policies[c.Intn(len(policies))])
+		},
+		func(boot *mapiv1.AzureBootDiagnostics, c fuzz.Continue) {
+			// The storage account URI is only used, and always required, for customer managed storage.
+			switch c.Intn(2) {
+			case 0:
+				*boot = mapiv1.AzureBootDiagnostics{StorageAccountType: mapiv1.AzureManagedAzureDiagnosticsStorage}
+			case 1:
+				*boot = mapiv1.AzureBootDiagnostics{
+					StorageAccountType: mapiv1.CustomerManagedAzureDiagnosticsStorage,
+					CustomerManaged: &mapiv1.AzureCustomerManagedBootDiagnostics{
+						StorageAccountURI: "https://" + strings.ReplaceAll(c.RandString(), "/", "") + ".blob.core.windows.net/",
+					},
+				}
+			}
+		},
+		func(settings *mapiv1.SecuritySettings, c fuzz.Continue) {
+			c.FuzzNoCustom(settings)
+
+			// The UEFI settings are always set for, and only for, the chosen security type.
+			switch c.Intn(3) {
+			case 0:
+				settings.SecurityType = ""
+				settings.ConfidentialVM = nil
+				settings.TrustedLaunch = nil
+			case 1:
+				settings.SecurityType = mapiv1.SecurityTypesConfidentialVM
+				settings.TrustedLaunch = nil
+
+				if settings.ConfidentialVM == nil {
+					settings.ConfidentialVM = &mapiv1.ConfidentialVM{}
+				}
+			case 2:
+				settings.SecurityType = mapiv1.SecurityTypesTrustedLaunch
+				settings.ConfidentialVM = nil
+
+				if settings.TrustedLaunch == nil {
+					settings.TrustedLaunch = &mapiv1.TrustedLaunch{}
+				}
+			}
+		},
+		func(dataDisk *mapiv1.DataDisk, c fuzz.Continue) {
+			c.FuzzNoCustom(dataDisk)
+
+			// CAPZ always deletes data disks with the machine.
+			dataDisk.DeletionPolicy = mapiv1.DiskDeletionPolicyTypeDelete
+		},
+		func(ps *mapiv1.AzureMachineProviderSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(ps)
+
+			// The type meta is always set to these values by the conversion.
+			ps.Kind = "AzureMachineProviderSpec"
+			ps.APIVersion = "machine.openshift.io/v1beta1"
+
+			// These fields are taken from the AzureCluster on the way back, so force them to match it here.
+			ps.Location = azureLocation
+			ps.ResourceGroup = azureResourceGroup
+			ps.NetworkResourceGroup = azureNetworkResourceGroup
+			ps.Vnet = azureVnet
+			ps.Subnet = azureSubnet
+			ps.SecurityGroup = azureSecurityGroup
+			ps.PublicLoadBalancer = azureNodeOutboundLB
+
+			switch c.Intn(3) {
+			case 0:
+				ps.UltraSSDCapability = ""
+			case 1:
+				ps.UltraSSDCapability = mapiv1.AzureUltraSSDCapabilityEnabled
+			case 2:
+				ps.UltraSSDCapability = mapiv1.AzureUltraSSDCapabilityDisabled
+			}
+
+			if len(ps.DataDisks) == 0 {
+				ps.DataDisks = nil
+			}
+
+			// Only fully qualified identities can be converted.
+			if ps.ManagedIdentity != "" {
+				ps.ManagedIdentity = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/" + strings.ReplaceAll(c.RandString(), "/", "")
+			}
+
+			// Clear fields that are not supported in the provider spec.
+			ps.ObjectMeta = metav1.ObjectMeta{}
+			ps.AvailabilitySet = ""
+			ps.ApplicationSecurityGroups = nil
+			ps.InternalLoadBalancer = ""
+			ps.NatRule = nil
+
+			// The user data secret is always in the namespace of the Machine.
+			if ps.UserDataSecret != nil {
+				if ps.UserDataSecret.Name == "" {
+					ps.UserDataSecret = nil
+				} else {
+					ps.UserDataSecret = &corev1.SecretReference{Name: ps.UserDataSecret.Name}
+				}
+			}
+		},
+	}
+}
@@ -0,0 +1,472 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mapi2capi
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	configv1 "github.com/openshift/api/config/v1"
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+	machinebuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/machine/v1beta1"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/test/matchers"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	capzv1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+)
+
+var _ = Describe("mapi2capi Azure conversion", func() {
+	var (
+		azureBaseProviderSpec   = machinebuilder.AzureProviderSpec().WithInternalLoadBalancer("")
+		azureMAPIMachineBase    = machinebuilder.Machine().WithProviderSpecBuilder(azureBaseProviderSpec)
+		azureMAPIMachineSetBase = machinebuilder.MachineSet().WithProviderSpecBuilder(azureBaseProviderSpec)
+
+		infra = &configv1.Infrastructure{
+			Spec:   configv1.InfrastructureSpec{},
+			Status: configv1.InfrastructureStatus{InfrastructureName: "sample-cluster-name"},
+		}
+	)
+
+	type azureMAPI2CAPIConversionInput struct {
+		machineBuilder   machinebuilder.MachineBuilder
+		infra            *configv1.Infrastructure
+		expectedErrors   []string
+		expectedWarnings []string
+	}
+
+	type azureMAPI2CAPIMachinesetConversionInput struct {
+		machineSetBuilder machinebuilder.MachineSetBuilder
+		infra             *configv1.Infrastructure
+		expectedErrors    []string
+		expectedWarnings  []string
+	}
+
+	var azureProviderSpec = func(modify func(*mapiv1.AzureMachineProviderSpec)) mapiv1.ProviderSpec {
+		providerSpec := azureBaseProviderSpec.Build()
+		modify(providerSpec)
+
+		rawBytes, err := json.Marshal(providerSpec)
+		if err != nil {
+			panic(fmt.Sprintf("unable to convert (marshal) test AzureProviderSpec to runtime.RawExtension: %v", err))
+		}
+
+		return mapiv1.ProviderSpec{
+			Value: &runtime.RawExtension{Raw: rawBytes},
+		}
+	}
+
+	var _ = DescribeTable("mapi2capi Azure convert MAPI Machine",
+		func(in azureMAPI2CAPIConversionInput) {
+			_, _, warns, err := FromAzureMachineAndInfra(in.machineBuilder.Build(), in.infra).ToMachineAndInfrastructureMachine()
+			Expect(err).To(matchers.ConsistOfMatchErrorSubstrings(in.expectedErrors), "should match expected errors while converting an Azure MAPI Machine to CAPI")
+			Expect(warns).To(matchers.ConsistOfSubstrings(in.expectedWarnings), "should match expected warnings while converting an Azure MAPI Machine to CAPI")
+		},
+
+		// Base Case.
+		Entry("With a Base configuration", azureMAPI2CAPIConversionInput{
+			machineBuilder:   azureMAPIMachineBase,
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With spot VM options", azureMAPI2CAPIConversionInput{
+			machineBuilder: azureMAPIMachineBase.WithProviderSpec(azureProviderSpec(func(ps *mapiv1.AzureMachineProviderSpec) {
+				ps.SpotVMOptions = &mapiv1.SpotVMOptions{MaxPrice: ptr.To(resource.MustParse("0.1"))}
+			})),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a marketplace image", azureMAPI2CAPIConversionInput{
+			machineBuilder: azureMAPIMachineBase.WithProviderSpec(azureProviderSpec(func(ps *mapiv1.AzureMachineProviderSpec) {
+				ps.Image = mapiv1.Image{Publisher: "redhat", Offer: "rh-ocp-worker", SKU: "rh-ocp-worker", Version: "413.92.2023101700"}
+			})),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a marketplace image without a version", azureMAPI2CAPIConversionInput{
+			machineBuilder: azureMAPIMachineBase.WithProviderSpec(azureProviderSpec(func(ps *mapiv1.AzureMachineProviderSpec) {
+				ps.Image = mapiv1.Image{Publisher: "redhat", Offer: "rh-ocp-worker", SKU: "rh-ocp-worker"}
+			})),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.image.version: Required value: version is required for marketplace images"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a directly shared gallery image", azureMAPI2CAPIConversionInput{
+			machineBuilder: azureMAPIMachineBase.WithProviderSpec(azureProviderSpec(func(ps *mapiv1.AzureMachineProviderSpec) {
+				ps.Image = mapiv1.Image{ResourceID: "/SharedGalleries/sharedgallery/Images/image/Versions/1.0.0"}
+			})),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.image.resourceID: Forbidden: directly shared gallery images are not supported on Cluster API"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a purchase plan on a managed image", azureMAPI2CAPIConversionInput{
+			machineBuilder: azureMAPIMachineBase.WithProviderSpec(azureProviderSpec(func(ps *mapiv1.AzureMachineProviderSpec) {
+				ps.Image = mapiv1.Image{
+					ResourceID: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/images/image",
+					Publisher:  "redhat",
+					Offer:      "rh-ocp-worker",
+					SKU:        "rh-ocp-worker",
+					Type:       mapiv1.AzureImageTypeMarketplaceWithPlan,
+				}
+			})),
+			infra:            infra,
+			expectedErrors:   []string{"resourceID must be a compute gallery or community gallery image version ID"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a managed identity name", azureMAPI2CAPIConversionInput{
+			machineBuilder: azureMAPIMachineBase.WithProviderSpec(azureProviderSpec(func(ps *mapiv1.AzureMachineProviderSpec) {
+				ps.ManagedIdentity = "sample-cluster-identity"
+			})),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.managedIdentity: Invalid value: \"sample-cluster-identity\": managedIdentity must be a resource ID starting with \"/subscriptions/\""},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a managed identity resource ID", azureMAPI2CAPIConversionInput{
+			machineBuilder: azureMAPIMachineBase.WithProviderSpec(azureProviderSpec(func(ps *mapiv1.AzureMachineProviderSpec) {
+				ps.ManagedIdentity = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.ManagedIdentity/userAssignedIdentities/sample-cluster-identity"
+			})),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With an ephemeral OS disk", azureMAPI2CAPIConversionInput{
+			machineBuilder: azureMAPIMachineBase.WithProviderSpec(azureProviderSpec(func(ps *mapiv1.AzureMachineProviderSpec) {
+				ps.OSDisk.CachingType = "ReadOnly"
+				ps.OSDisk.DiskSettings.EphemeralStorageLocation = "Local"
+			})),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a confidential VM security profile", azureMAPI2CAPIConversionInput{
+			machineBuilder: azureMAPIMachineBase.WithProviderSpec(azureProviderSpec(func(ps *mapiv1.AzureMachineProviderSpec) {
+				ps.OSDisk.ManagedDisk.SecurityProfile = mapiv1.VMDiskSecurityProfile{
+					SecurityEncryptionType: mapiv1.SecurityEncryptionTypesDiskWithVMGuestState,
+				}
+				ps.SecurityProfile = &mapiv1.SecurityProfile{
+					Settings: mapiv1.SecuritySettings{
+						SecurityType: mapiv1.SecurityTypesConfidentialVM,
+						ConfidentialVM: &mapiv1.ConfidentialVM{
+							UEFISettings: mapiv1.UEFISettings{
+								SecureBoot:                       mapiv1.SecureBootPolicyEnabled,
+								VirtualizedTrustedPlatformModule: mapiv1.VirtualizedTrustedPlatformModulePolicyEnabled,
+							},
+						},
+					},
+				}
+			})),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With trusted launch settings on a confidential VM", azureMAPI2CAPIConversionInput{
+			machineBuilder: azureMAPIMachineBase.WithProviderSpec(azureProviderSpec(func(ps *mapiv1.AzureMachineProviderSpec) {
+				ps.SecurityProfile = &mapiv1.SecurityProfile{
+					Settings: mapiv1.SecuritySettings{
+						SecurityType:  mapiv1.SecurityTypesConfidentialVM,
+						TrustedLaunch: &mapiv1.TrustedLaunch{},
+					},
+				}
+			})),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.securityProfile.settings.trustedLaunch: Invalid value: v1beta1.TrustedLaunch{UEFISettings:v1beta1.UEFISettings{SecureBoot:\"\", VirtualizedTrustedPlatformModule:\"\"}}: trustedLaunch may only be set when securityType is \"TrustedLaunch\""},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With UltraSSD data disks", azureMAPI2CAPIConversionInput{
+			machineBuilder: azureMAPIMachineBase.WithProviderSpec(azureProviderSpec(func(ps *mapiv1.AzureMachineProviderSpec) {
+				ps.UltraSSDCapability = mapiv1.AzureUltraSSDCapabilityEnabled
+				ps.DataDisks = []mapiv1.DataDisk{{
+					NameSuffix:     "ultrassd",
+					DiskSizeGB:     4,
+					Lun:            0,
+					CachingType:    mapiv1.CachingTypeNone,
+					DeletionPolicy: mapiv1.DiskDeletionPolicyTypeDelete,
+					ManagedDisk:    mapiv1.DataDiskManagedDiskParameters{StorageAccountType: mapiv1.StorageAccountUltraSSDLRS},
+				}}
+			})),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a data disk using the Detach deletion policy", azureMAPI2CAPIConversionInput{
+			machineBuilder: azureMAPIMachineBase.WithProviderSpec(azureProviderSpec(func(ps *mapiv1.AzureMachineProviderSpec) {
+				ps.DataDisks = []mapiv1.DataDisk{{
+					NameSuffix:     "data",
+					DiskSizeGB:     128,
+					DeletionPolicy: mapiv1.DiskDeletionPolicyTypeDetach,
+					ManagedDisk:    mapiv1.DataDiskManagedDiskParameters{StorageAccountType: mapiv1.StorageAccountPremiumLRS},
+				}}
+			})),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.dataDisks[0].deletionPolicy: Invalid value: \"Detach\": deletionPolicy must be \"Delete\", CAPZ always deletes data disks with the machine"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With an internal load balancer", azureMAPI2CAPIConversionInput{
+			machineBuilder: azureMAPIMachineBase.WithProviderSpecBuilder(azureBaseProviderSpec.WithInternalLoadBalancer("internal-lb")),
+			infra:          infra,
+			expectedErrors: []string{"spec.providerSpec.value.internalLoadBalancer: Invalid value: \"internal-lb\": internalLoadBalancer is not supported"},
+		}),
+
+		Entry("With an internal load balancer on a control plane machine", azureMAPI2CAPIConversionInput{
+			machineBuilder: azureMAPIMachineBase.WithLabels(map[string]string{"machine.openshift.io/cluster-api-machine-role": "master"}).
+				WithProviderSpecBuilder(azureBaseProviderSpec.WithInternalLoadBalancer("internal-lb")),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{"spec.providerSpec.value.internalLoadBalancer: Invalid value: \"internal-lb\": internalLoadBalancer is not converted, control plane machines are attached to the AzureCluster internal load balancer"},
+		}),
+
+		Entry("With an availability set", azureMAPI2CAPIConversionInput{
+			machineBuilder: azureMAPIMachineBase.WithProviderSpec(azureProviderSpec(func(ps *mapiv1.AzureMachineProviderSpec) {
+				ps.AvailabilitySet = "sample-cluster-worker-as"
+			})),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.availabilitySet: Forbidden: availabilitySet is not supported on Cluster API, CAPZ only places machines in availability sets it creates itself"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a resource group that does not match the infrastructure", azureMAPI2CAPIConversionInput{
+			machineBuilder: azureMAPIMachineBase,
+			infra: &configv1.Infrastructure{
+				Status: configv1.InfrastructureStatus{
+					InfrastructureName: "sample-cluster-name",
+					PlatformStatus: &configv1.PlatformStatus{
+						Type:  configv1.AzurePlatformType,
+						Azure: &configv1.AzurePlatformStatus{ResourceGroupName: "another-resource-group"},
+					},
+				},
+			},
+			expectedErrors:   []string{"spec.providerSpec.value.resourceGroup: Invalid value: \"resource-group-12345678\": resourceGroup should match infrastructure status value \"another-resource-group\""},
+			expectedWarnings: []string{},
+		}),
+	)
+
+	var _ = DescribeTable("mapi2capi Azure convert MAPI MachineSet",
+		func(in azureMAPI2CAPIMachinesetConversionInput) {
+			_, _, warns, err := FromAzureMachineSetAndInfra(in.machineSetBuilder.Build(), in.infra).ToMachineSetAndMachineTemplate()
+			Expect(err).To(matchers.ConsistOfMatchErrorSubstrings(in.expectedErrors), "should match expected errors while converting an Azure MAPI MachineSet to CAPI")
+			Expect(warns).To(matchers.ConsistOfSubstrings(in.expectedWarnings), "should match expected warnings while converting an Azure MAPI MachineSet to CAPI")
+		},
+
+		Entry("With a Base configuration", azureMAPI2CAPIMachinesetConversionInput{
+			machineSetBuilder: azureMAPIMachineSetBase,
+			infra:             infra,
+			expectedErrors:    []string{},
+			expectedWarnings:  []string{},
+		}),
+	)
+
+	Context("when the MAPI MachineSet references an availability set", func() {
+		It("should report the conversion error as unsupported on CAPI", func() {
+			machineSet := azureMAPIMachineSetBase.WithProviderSpec(azureProviderSpec(func(ps *mapiv1.AzureMachineProviderSpec) {
+				ps.AvailabilitySet = "sample-cluster-worker-as"
+			})).Build()
+
+			_, _, _, err := FromAzureMachineSetAndInfra(machineSet, infra).ToMachineSetAndMachineTemplate()
+			Expect(err).To(HaveOccurred())
+			Expect(IsUnsupportedOnCAPI(fmt.Errorf("failed to convert: %w", err))).To(BeTrue())
+		})
+
+		It("should not report the conversion error as unsupported on CAPI when other fields are invalid", func() {
+			machineSet := azureMAPIMachineSetBase.WithProviderSpec(azureProviderSpec(func(ps *mapiv1.AzureMachineProviderSpec) {
+				ps.AvailabilitySet = "sample-cluster-worker-as"
+				ps.InternalLoadBalancer = "internal-lb"
+			})).Build()
+
+			_, _, _, err := FromAzureMachineSetAndInfra(machineSet, infra).ToMachineSetAndMachineTemplate()
+			Expect(err).To(MatchError(SatisfyAll(ContainSubstring("availabilitySet"), ContainSubstring("internalLoadBalancer"))))
+			Expect(IsUnsupportedOnCAPI(err)).To(BeFalse())
+		})
+
+		It("should not report other conversion errors as unsupported on CAPI", func() {
+			machineSet := azureMAPIMachineSetBase.WithProviderSpecBuilder(azureBaseProviderSpec.WithInternalLoadBalancer("internal-lb")).Build()
+
+			_, _, _, err := FromAzureMachineSetAndInfra(machineSet, infra).ToMachineSetAndMachineTemplate()
+			Expect(err).To(HaveOccurred())
+			Expect(IsUnsupportedOnCAPI(err)).To(BeFalse())
+		})
+	})
+
+	Context("when the MAPI Machine sets a proximity placement group", func() {
+		It("should convert the Machine without the proximity placement group", func() {
+			// The vendored AzureMachineProviderSpec has no proximity placement group field, so set it on the raw providerSpec.
+			providerSpec := map[string]interface{}{}
+			Expect(json.Unmarshal(azureProviderSpec(func(*mapiv1.AzureMachineProviderSpec) {}).Value.Raw, &providerSpec)).To(Succeed())
+			providerSpec["proximityPlacementGroupID"] = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/proximityPlacementGroups/ppg"
+
+			rawProviderSpec, err := json.Marshal(providerSpec)
+			Expect(err).ToNot(HaveOccurred())
+
+			withPPG := azureMAPIMachineBase.WithProviderSpec(mapiv1.ProviderSpec{Value: &runtime.RawExtension{Raw: rawProviderSpec}}).Build()
+			withoutPPG := azureMAPIMachineBase.Build()
+
+			_, capzMachine, warnings, err := FromAzureMachineAndInfra(withPPG, infra).ToMachineAndInfrastructureMachine()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(warnings).To(BeEmpty())
+
+			_, expectedCAPZMachine, _, err := FromAzureMachineAndInfra(withoutPPG, infra).ToMachineAndInfrastructureMachine()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(capzMachine).To(Equal(expectedCAPZMachine))
+		})
+	})
+
+	var _ = DescribeTable("mapi2capi Azure convert MAPI spotVMOptions",
+		func(in *mapiv1.SpotVMOptions, expected *capzv1.SpotVMOptions) {
+			machine := azureMAPIMachineBase.WithProviderSpec(azureProviderSpec(func(ps *mapiv1.AzureMachineProviderSpec) {
+				ps.SpotVMOptions = in
+			})).Build()
+
+			_, infraMachine, _, err := FromAzureMachineAndInfra(machine, infra).ToMachineAndInfrastructureMachine()
+			Expect(err).ToNot(HaveOccurred())
+
+			azureMachine, ok := infraMachine.(*capzv1.AzureMachine)
+			Expect(ok).To(BeTrue())
+			Expect(equality.Semantic.DeepEqual(azureMachine.Spec.SpotVMOptions, expected)).To(BeTrue(), "expected spotVMOptions %v, got %v", expected, azureMachine.Spec.SpotVMOptions)
+		},
+
+		Entry("Without spot VM options", nil, nil),
+		Entry("With an empty max price, capped at the on-demand price",
+			&mapiv1.SpotVMOptions{},
+			&capzv1.SpotVMOptions{EvictionPolicy: ptr.To(capzv1.SpotEvictionPolicyDelete)},
+		),
+		Entry("With a max price",
+			&mapiv1.SpotVMOptions{MaxPrice: ptr.To(resource.MustParse("0.1"))},
+			&capzv1.SpotVMOptions{MaxPrice: ptr.To(resource.MustParse("0.1")), EvictionPolicy: ptr.To(capzv1.SpotEvictionPolicyDelete)},
+		),
+	)
+
+	var _ = DescribeTable("mapi2capi Azure convert MAPI image",
+		func(in mapiv1.Image, expected *capzv1.Image) {
+			machine := azureMAPIMachineBase.WithProviderSpec(azureProviderSpec(func(ps *mapiv1.AzureMachineProviderSpec) {
+				ps.Image = in
+			})).Build()
+
+			_, infraMachine, _, err := FromAzureMachineAndInfra(machine, infra).ToMachineAndInfrastructureMachine()
+			Expect(err).ToNot(HaveOccurred())
+
+			azureMachine, ok := infraMachine.(*capzv1.AzureMachine)
+			Expect(ok).To(BeTrue())
+			Expect(azureMachine.Spec.Image).To(Equal(expected))
+		},
+
+		Entry("With a managed image",
+			mapiv1.Image{ResourceID: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/images/image"},
+			&capzv1.Image{ID: ptr.To("/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/images/image")},
+		),
+		Entry("With a marketplace image without a purchase plan",
+			mapiv1.Image{Publisher: "redhat", Offer: "rh-ocp-worker", SKU: "rh-ocp-worker", Version: "413.92.2023101700", Type: mapiv1.AzureImageTypeMarketplaceNoPlan},
+			&capzv1.Image{Marketplace: &capzv1.AzureMarketplaceImage{
+				ImagePlan: capzv1.ImagePlan{Publisher: "redhat", Offer: "rh-ocp-worker", SKU: "rh-ocp-worker"},
+				Version:   "413.92.2023101700",
+			}},
+		),
+		Entry("With a marketplace image with a purchase plan",
+			mapiv1.Image{Publisher: "redhat", Offer: "rh-ocp-worker", SKU: "rh-ocp-worker", Version: "413.92.2023101700", Type: mapiv1.AzureImageTypeMarketplaceWithPlan},
+			&capzv1.Image{Marketplace: &capzv1.AzureMarketplaceImage{
+				ImagePlan:       capzv1.ImagePlan{Publisher: "redhat", Offer: "rh-ocp-worker", SKU: "rh-ocp-worker"},
+				Version:         "413.92.2023101700",
+				ThirdPartyImage: true,
+			}},
+		),
+		Entry("With a community gallery image",
+			mapiv1.Image{ResourceID: "/CommunityGalleries/community-gallery/Images/image/Versions/1.0.0"},
+			&capzv1.Image{ComputeGallery: &capzv1.AzureComputeGalleryImage{Gallery: "community-gallery", Name: "image", Version: "1.0.0"}},
+		),
+		Entry("With a compute gallery image with a purchase plan",
+			mapiv1.Image{
+				ResourceID: "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/galleries/gallery/images/image/versions/1.0.0",
+				Publisher:  "redhat",
+				Offer:      "rh-ocp-worker",
+				SKU:        "rh-ocp-worker",
+				Type:       mapiv1.AzureImageTypeMarketplaceWithPlan,
+			},
+			&capzv1.Image{ComputeGallery: &capzv1.AzureComputeGalleryImage{
+				SubscriptionID: ptr.To("sub"),
+				ResourceGroup:  ptr.To("rg"),
+				Gallery:        "gallery",
+				Name:           "image",
+				Version:        "1.0.0",
+				Plan:           &capzv1.ImagePlan{Publisher: "redhat", Offer: "rh-ocp-worker", SKU: "rh-ocp-worker"},
+			}},
+		),
+	)
+
+	var _ = DescribeTable("mapi2capi Azure convert MAPI boot diagnostics",
+		func(in *mapiv1.AzureBootDiagnostics, managedByDefault bool, expected *capzv1.BootDiagnostics) {
+			machine := azureMAPIMachineBase.WithProviderSpec(azureProviderSpec(func(ps *mapiv1.AzureMachineProviderSpec) {
+				ps.Diagnostics.Boot = in
+			})).Build()
+
+			_, infraMachine, _, err := FromAzureMachineAndInfraWithOptions(machine, infra, AzureConversionOptions{ManagedBootDiagnostics: managedByDefault}).ToMachineAndInfrastructureMachine()
+			Expect(err).ToNot(HaveOccurred())
+
+			azureMachine, ok := infraMachine.(*capzv1.AzureMachine)
+			Expect(ok).To(BeTrue())
+			Expect(azureMachine.Spec.Diagnostics).To(HaveField("Boot", Equal(expected)))
+		},
+
+		Entry("Without boot diagnostics, disabled as CAPZ would otherwise enable them",
+			nil, false,
+			&capzv1.BootDiagnostics{StorageAccountType: capzv1.DisabledDiagnosticsStorage},
+		),
+		Entry("Without boot diagnostics and managed boot diagnostics by default",
+			nil, true,
+			&capzv1.BootDiagnostics{StorageAccountType: capzv1.ManagedDiagnosticsStorage},
+		),
+		Entry("With Azure managed boot diagnostics",
+			&mapiv1.AzureBootDiagnostics{StorageAccountType: mapiv1.AzureManagedAzureDiagnosticsStorage}, false,
+			&capzv1.BootDiagnostics{StorageAccountType: capzv1.ManagedDiagnosticsStorage},
+		),
+		Entry("With customer managed boot diagnostics",
+			&mapiv1.AzureBootDiagnostics{
+				StorageAccountType: mapiv1.CustomerManagedAzureDiagnosticsStorage,
+				CustomerManaged:    &mapiv1.AzureCustomerManagedBootDiagnostics{StorageAccountURI: "https://sample.blob.core.windows.net/"},
+			}, false,
+			&capzv1.BootDiagnostics{
+				StorageAccountType: capzv1.UserManagedDiagnosticsStorage,
+				UserManaged:        &capzv1.UserManagedBootDiagnostics{StorageAccountURI: "https://sample.blob.core.windows.net/"},
+			},
+		),
+		Entry("With customer managed boot diagnostics and managed boot diagnostics by default",
+			&mapiv1.AzureBootDiagnostics{
+				StorageAccountType: mapiv1.CustomerManagedAzureDiagnosticsStorage,
+				CustomerManaged:    &mapiv1.AzureCustomerManagedBootDiagnostics{StorageAccountURI: "https://sample.blob.core.windows.net/"},
+			}, true,
+			&capzv1.BootDiagnostics{
+				StorageAccountType: capzv1.UserManagedDiagnosticsStorage,
+				UserManaged:        &capzv1.UserManagedBootDiagnostics{StorageAccountURI: "https://sample.blob.core.windows.net/"},
+			},
+		),
+	)
+})
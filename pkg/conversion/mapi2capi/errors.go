@@ -0,0 +1,51 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mapi2capi
+
+import (
+	"errors"
+
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// IsUnsupportedOnCAPI returns true when the conversion error only contains fields that have no Cluster API equivalent.
+// Such fields are reported as Forbidden field errors, as opposed to Invalid field errors
+// which are used for values that cannot be converted yet, or that are not valid.
+// An error aggregating both is not unsupported on CAPI, as the other errors still have to be surfaced and fixed.
+func IsUnsupportedOnCAPI(err error) bool {
+	var agg utilerrors.Aggregate
+	if errors.As(err, &agg) {
+		if len(agg.Errors()) == 0 {
+			return false
+		}
+
+		for _, e := range agg.Errors() {
+			if !IsUnsupportedOnCAPI(e) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	var fieldErr *field.Error
+	if errors.As(err, &fieldErr) {
+		return fieldErr.Type == field.ErrorTypeForbidden
+	}
+
+	return false
+}
@@ -0,0 +1,626 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mapi2capi
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/ptr"
+	capgv1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// gcpImageFamilySegment is the path segment that identifies a reference to an image family rather than a specific image.
+	gcpImageFamilySegment = "/family/"
+)
+
+var (
+	errUnexpectedObjectTypeForGCPMachine = errors.New("unexpected type for capgMachineObj")
+)
+
+// gcpMachineAndInfra stores the details of a Machine API GCP Machine and Infra.
+type gcpMachineAndInfra struct {
+	machine        *mapiv1.Machine
+	infrastructure *configv1.Infrastructure
+}
+
+// gcpMachineSetAndInfra stores the details of a Machine API GCP MachineSet and Infra.
+type gcpMachineSetAndInfra struct {
+	machineSet     *mapiv1.MachineSet
+	infrastructure *configv1.Infrastructure
+	*gcpMachineAndInfra
+}
+
+// FromGCPMachineAndInfra wraps a Machine API Machine for GCP and the OCP Infrastructure object into a mapi2capi GCPProviderSpec.
+func FromGCPMachineAndInfra(m *mapiv1.Machine, i *configv1.Infrastructure) Machine {
+	return &gcpMachineAndInfra{machine: m, infrastructure: i}
+}
+
+// FromGCPMachineSetAndInfra wraps a Machine API MachineSet for GCP and the OCP Infrastructure object into a mapi2capi GCPProviderSpec.
+func FromGCPMachineSetAndInfra(m *mapiv1.MachineSet, i *configv1.Infrastructure) MachineSet {
+	return &gcpMachineSetAndInfra{
+		machineSet:     m,
+		infrastructure: i,
+		gcpMachineAndInfra: &gcpMachineAndInfra{
+			machine: &mapiv1.Machine{
+				Spec: m.Spec.Template.Spec,
+			},
+			infrastructure: i,
+		},
+	}
+}
+
+// ToMachineAndInfrastructureMachine is used to generate a CAPI Machine and the corresponding InfrastructureMachine
+// from the stored MAPI Machine and Infrastructure objects.
+func (m *gcpMachineAndInfra) ToMachineAndInfrastructureMachine() (*capiv1.Machine, client.Object, []string, error) {
+	capiMachine, capgMachine, warnings, errs := m.toMachineAndInfrastructureMachine()
+
+	if len(errs) > 0 {
+		return nil, nil, warnings, errs.ToAggregate()
+	}
+
+	return capiMachine, capgMachine, warnings, nil
+}
+
+func (m *gcpMachineAndInfra) toMachineAndInfrastructureMachine() (*capiv1.Machine, client.Object, []string, field.ErrorList) {
+	var (
+		errs     field.ErrorList
+		warnings []string
+	)
+
+	gcpProviderSpec, err := gcpProviderSpecFromRawExtension(m.machine.Spec.ProviderSpec.Value)
+	if err != nil {
+		return nil, nil, nil, field.ErrorList{field.Invalid(field.NewPath("spec", "providerSpec", "value"), m.machine.Spec.ProviderSpec.Value, err.Error())}
+	}
+
+	capgMachine, warn, machineErrs := m.toGCPMachine(gcpProviderSpec)
+	if machineErrs != nil {
+		errs = append(errs, machineErrs...)
+	}
+
+	warnings = append(warnings, warn...)
+
+	capiMachine, machineErrs := fromMAPIMachineToCAPIMachine(m.machine)
+	if machineErrs != nil {
+		errs = append(errs, machineErrs...)
+	}
+
+	// The core conversion always references an AWSMachine, point it at the GCPMachine instead.
+	capiMachine.Spec.InfrastructureRef.APIVersion = capgv1.GroupVersion.String()
+	capiMachine.Spec.InfrastructureRef.Kind = gcpMachineKind
+
+	// CAPG uses the same gce:// provider ID format as MAPG, so it is carried over as is.
+	capgMachine.Spec.ProviderID = capiMachine.Spec.ProviderID
+
+	// Plug into Core CAPI Machine fields that come from the MAPI ProviderConfig which belong here instead of the CAPI GCPMachineTemplate.
+	if gcpProviderSpec.Zone != "" {
+		capiMachine.Spec.FailureDomain = ptr.To(gcpProviderSpec.Zone)
+	}
+
+	if gcpProviderSpec.UserDataSecret != nil && gcpProviderSpec.UserDataSecret.Name != "" {
+		capiMachine.Spec.Bootstrap = capiv1.Bootstrap{
+			DataSecretName: &gcpProviderSpec.UserDataSecret.Name,
+		}
+	}
+
+	// Popluate the CAPI Machine ClusterName from the OCP Infrastructure object.
+	if m.infrastructure == nil || m.infrastructure.Status.InfrastructureName == "" {
+		errs = append(errs, field.Invalid(field.NewPath("infrastructure", "status", "infrastructureName"), m.infrastructure.Status.InfrastructureName, "infrastructure cannot be nil and infrastructure.Status.InfrastructureName cannot be empty"))
+	} else {
+		capiMachine.Spec.ClusterName = m.infrastructure.Status.InfrastructureName
+	}
+
+	errs = append(errs, preserveProviderSpecFields(m.machine, capiMachine, "credentialsSecret", "restartPolicy")...)
+
+	// The InfraMachine should always have the same labels and annotations as the Machine.
+	// See https://github.com/kubernetes-sigs/cluster-api/blob/f88d7ae5155700c2cc367b31ddcc151c9ad579e4/internal/controllers/machineset/machineset_controller.go#L578-L579
+	capgMachine.SetAnnotations(capiMachine.GetAnnotations())
+	capgMachine.SetLabels(capiMachine.GetLabels())
+
+	return capiMachine, capgMachine, warnings, errs
+}
+
+// ToMachineSetAndMachineTemplate converts a mapi2capi GCPMachineSetAndInfra into a CAPI MachineSet and CAPG GCPMachineTemplate.
+func (m *gcpMachineSetAndInfra) ToMachineSetAndMachineTemplate() (*capiv1.MachineSet, client.Object, []string, error) {
+	var (
+		errs     []error
+		warnings []string
+	)
+
+	capiMachine, capgMachineObj, warn, err := m.toMachineAndInfrastructureMachine()
+	if err != nil {
+		errs = append(errs, err.ToAggregate().Errors()...)
+	}
+
+	warnings = append(warnings, warn...)
+
+	capgMachine, ok := capgMachineObj.(*capgv1.GCPMachine)
+	if !ok {
+		panic(fmt.Errorf("%w: %T", errUnexpectedObjectTypeForGCPMachine, capgMachineObj))
+	}
+
+	capgMachineTemplate := gcpMachineToGCPMachineTemplate(capgMachine, m.machineSet.Name, capiNamespace)
+
+	capiMachineSet, machineSetErrs := fromMAPIMachineSetToCAPIMachineSet(m.machineSet)
+	if machineSetErrs != nil {
+		errs = append(errs, machineSetErrs.Errors()...)
+	}
+
+	capiMachineSet.Spec.Template.Spec = capiMachine.Spec
+
+	// We have to merge these two maps so that labels and annotations added to the template objectmeta are persisted
+	// along with the labels and annotations from the machine objectmeta.
+	capiMachineSet.Spec.Template.ObjectMeta.Labels = mergeMaps(capiMachineSet.Spec.Template.ObjectMeta.Labels, capiMachine.Labels)
+	capiMachineSet.Spec.Template.ObjectMeta.Annotations = mergeMaps(capiMachineSet.Spec.Template.ObjectMeta.Annotations, capiMachine.Annotations)
+
+	// Override the reference so that it matches the GCPMachineTemplate.
+	capiMachineSet.Spec.Template.Spec.InfrastructureRef.Kind = gcpMachineTemplateKind
+	capiMachineSet.Spec.Template.Spec.InfrastructureRef.Name = capgMachineTemplate.Name
+
+	if m.infrastructure == nil || m.infrastructure.Status.InfrastructureName == "" {
+		errs = append(errs, field.Invalid(field.NewPath("infrastructure", "status", "infrastructureName"), m.infrastructure.Status.InfrastructureName, "infrastructure cannot be nil and infrastructure.Status.InfrastructureName cannot be empty"))
+	} else {
+		capiMachineSet.Spec.Template.Spec.ClusterName = m.infrastructure.Status.InfrastructureName
+		capiMachineSet.Spec.ClusterName = m.infrastructure.Status.InfrastructureName
+	}
+
+	if len(errs) > 0 {
+		return nil, nil, warnings, utilerrors.NewAggregate(errs)
+	}
+
+	return capiMachineSet, capgMachineTemplate, warnings, nil
+}
+
+// toGCPMachine implements the ProviderSpec conversion interface for the GCP provider,
+// it converts GCPMachineProviderSpec to GCPMachine.
+func (m *gcpMachineAndInfra) toGCPMachine(providerSpec mapiv1.GCPMachineProviderSpec) (*capgv1.GCPMachine, []string, field.ErrorList) {
+	fldPath := field.NewPath("spec", "providerSpec", "value")
+
+	var (
+		errs     field.ErrorList
+		warnings []string
+	)
+
+	bootDisk, additionalDisks, diskErrs := convertGCPDisksToCAPI(fldPath.Child("disks"), providerSpec.Disks)
+	errs = append(errs, diskErrs...)
+
+	subnet, publicIP, networkInterfaceErrs := convertGCPNetworkInterfacesToCAPI(fldPath.Child("networkInterfaces"), providerSpec.NetworkInterfaces)
+	errs = append(errs, networkInterfaceErrs...)
+
+	errs = append(errs, validateGCPScheduling(fldPath, providerSpec)...)
+
+	shieldedInstanceConfig, shieldedInstanceConfigErrs := convertGCPShieldedInstanceConfigToCAPI(fldPath.Child("shieldedInstanceConfig"), providerSpec.ShieldedInstanceConfig)
+	errs = append(errs, shieldedInstanceConfigErrs...)
+
+	serviceAccount, serviceAccountErrs := convertGCPServiceAccountsToCAPI(fldPath.Child("serviceAccounts"), providerSpec.ServiceAccounts)
+	errs = append(errs, serviceAccountErrs...)
+
+	confidentialCompute, confidentialComputeErrs := convertGCPConfidentialComputeToCAPI(fldPath, providerSpec)
+	errs = append(errs, confidentialComputeErrs...)
+
+	spec := capgv1.GCPMachineSpec{
+		InstanceType: providerSpec.MachineType,
+		Subnet:       subnet,
+		// ProviderID. This is populated when this is called in higher level funcs (ToMachine(), ToMachineSet()).
+		AdditionalLabels:       convertGCPLabelsToCAPI(providerSpec.Labels),
+		AdditionalMetadata:     convertGCPMetadataToCAPI(providerSpec.Metadata),
+		PublicIP:               ptr.To(publicIP),
+		AdditionalNetworkTags:  convertGCPTagsToCAPI(providerSpec.Tags),
+		ResourceManagerTags:    convertGCPResourceManagerTagsToCAPI(providerSpec.ResourceManagerTags),
+		AdditionalDisks:        additionalDisks,
+		ServiceAccount:         serviceAccount,
+		Preemptible:            providerSpec.Preemptible,
+		IPForwarding:           convertGCPCanIPForwardToCAPI(providerSpec.CanIPForward),
+		ShieldedInstanceConfig: shieldedInstanceConfig,
+		OnHostMaintenance:      convertGCPOnHostMaintenanceToCAPI(providerSpec.OnHostMaintenance),
+		ConfidentialCompute:    confidentialCompute,
+	}
+
+	if bootDisk != nil {
+		spec.RootDeviceSize = bootDisk.SizeGB
+
+		if bootDisk.Type != "" {
+			spec.RootDeviceType = ptr.To(capgv1.DiskType(bootDisk.Type))
+		}
+
+		// MAPG passes the image straight through to GCP, which accepts image family references in place of an image.
+		if strings.Contains(bootDisk.Image, gcpImageFamilySegment) {
+			spec.ImageFamily = ptr.To(bootDisk.Image)
+		} else if bootDisk.Image != "" {
+			spec.Image = ptr.To(bootDisk.Image)
+		}
+	}
+
+	// Unused fields - Below this line are fields not used from the MAPI GCPMachineProviderSpec.
+
+	// TypeMeta - Only for the purpose of the raw extension, not used for any functionality.
+	// CredentialsSecret - TODO(OCPCLOUD-2713): Work out what needs to happen regarding credentials secrets.
+	// Zone - Set on the CAPI Machine as the failure domain.
+	// Region - Set on the GCPCluster, CAPG always creates machines in the cluster region.
+	// ProjectID - Set on the GCPCluster, CAPG always creates machines in the cluster project.
+	// RestartPolicy - CAPG leaves automatic restart to the platform default, which matches MAPG unless restarts are disabled.
+	// Spot provisioning model - TODO: Neither the MAPI provider spec nor the vendored CAPG API have a provisioning model field yet,
+	//   only the legacy preemptible flag. Once both do, carry it through alongside preemptible.
+
+	errs = append(errs, m.validateGCPProjectAndRegion(fldPath, providerSpec)...)
+
+	if !reflect.DeepEqual(providerSpec.ObjectMeta, metav1.ObjectMeta{}) {
+		// We don't support setting the object metadata in the provider spec.
+		// It's only present for the purpose of the raw extension and doesn't have any functionality.
+		errs = append(errs, field.Invalid(fldPath.Child("metadata"), providerSpec.ObjectMeta, "metadata is not supported"))
+	}
+
+	errs = append(errs, handleUnsupportedGCPProviderSpecFields(fldPath, providerSpec)...)
+
+	return &capgv1.GCPMachine{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: capgv1.GroupVersion.String(),
+			Kind:       gcpMachineKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.machine.Name,
+			Namespace: capiNamespace,
+		},
+		Spec: spec,
+	}, warnings, errs
+}
+
+// validateGCPProjectAndRegion checks that the project and region in the provider spec match those of the cluster.
+// CAPG takes the project and region from the GCPCluster, so machines cannot be created anywhere else.
+func (m *gcpMachineAndInfra) validateGCPProjectAndRegion(fldPath *field.Path, providerSpec mapiv1.GCPMachineProviderSpec) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if m.infrastructure == nil || m.infrastructure.Status.PlatformStatus == nil || m.infrastructure.Status.PlatformStatus.GCP == nil {
+		return errs
+	}
+
+	gcpPlatformStatus := m.infrastructure.Status.PlatformStatus.GCP
+
+	if gcpPlatformStatus.ProjectID != "" && providerSpec.ProjectID != "" && providerSpec.ProjectID != gcpPlatformStatus.ProjectID {
+		errs = append(errs, field.Invalid(fldPath.Child("projectID"), providerSpec.ProjectID, fmt.Sprintf("projectID should match infrastructure status value %q", gcpPlatformStatus.ProjectID)))
+	}
+
+	if gcpPlatformStatus.Region != "" && providerSpec.Region != "" && providerSpec.Region != gcpPlatformStatus.Region {
+		errs = append(errs, field.Invalid(fldPath.Child("region"), providerSpec.Region, fmt.Sprintf("region should match infrastructure status value %q", gcpPlatformStatus.Region)))
+	}
+
+	return errs
+}
+
+// gcpProviderSpecFromRawExtension unmarshals a raw extension into a GCPMachineProviderSpec type.
+func gcpProviderSpecFromRawExtension(rawExtension *runtime.RawExtension) (mapiv1.GCPMachineProviderSpec, error) {
+	if rawExtension == nil {
+		return mapiv1.GCPMachineProviderSpec{}, nil
+	}
+
+	spec := mapiv1.GCPMachineProviderSpec{}
+	if err := yaml.Unmarshal(rawExtension.Raw, &spec); err != nil {
+		return mapiv1.GCPMachineProviderSpec{}, fmt.Errorf("error unmarshalling providerSpec: %w", err)
+	}
+
+	return spec, nil
+}
+
+func gcpMachineToGCPMachineTemplate(gcpMachine *capgv1.GCPMachine, name string, namespace string) *capgv1.GCPMachineTemplate {
+	return &capgv1.GCPMachineTemplate{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: capgv1.GroupVersion.String(),
+			Kind:       gcpMachineTemplateKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: capgv1.GCPMachineTemplateSpec{
+			Template: capgv1.GCPMachineTemplateResource{
+				Spec: gcpMachine.Spec,
+			},
+		},
+	}
+}
+
+//////// Conversion helpers
+
+// convertGCPDisksToCAPI splits the MAPI disks into the boot disk and the CAPG additional disks.
+// CAPG always creates the boot disk from the root device fields, and always deletes all disks with the machine.
+func convertGCPDisksToCAPI(fldPath *field.Path, mapiDisks []*mapiv1.GCPDisk) (*mapiv1.GCPDisk, []capgv1.AttachedDiskSpec, field.ErrorList) {
+	var (
+		bootDisk        *mapiv1.GCPDisk
+		additionalDisks []capgv1.AttachedDiskSpec
+		errs            field.ErrorList
+	)
+
+	for i, disk := range mapiDisks {
+		if disk == nil {
+			continue
+		}
+
+		if !disk.AutoDelete {
+			errs = append(errs, field.Invalid(fldPath.Index(i).Child("autoDelete"), disk.AutoDelete, "autoDelete must be true, CAPG always deletes disks with the machine"))
+		}
+
+		if len(disk.Labels) > 0 {
+			errs = append(errs, field.Invalid(fldPath.Index(i).Child("labels"), disk.Labels, "labels are not supported on disks"))
+		}
+
+		if disk.EncryptionKey != nil {
+			// TODO: Disk encryption keys are not yet converted.
+			errs = append(errs, field.Invalid(fldPath.Index(i).Child("encryptionKey"), disk.EncryptionKey, "encryptionKey is not yet supported"))
+		}
+
+		switch {
+		case !disk.Boot:
+			if disk.Image != "" {
+				errs = append(errs, field.Invalid(fldPath.Index(i).Child("image"), disk.Image, "image is only supported on the boot disk, CAPG always creates additional disks empty"))
+			}
+
+			additionalDisks = append(additionalDisks, convertGCPAdditionalDiskToCAPI(disk))
+		case bootDisk != nil:
+			errs = append(errs, field.Invalid(fldPath.Index(i).Child("boot"), disk.Boot, "only one boot disk can be specified"))
+		default:
+			bootDisk = disk
+		}
+	}
+
+	if bootDisk == nil {
+		errs = append(errs, field.Required(fldPath, "a boot disk is required"))
+	}
+
+	return bootDisk, additionalDisks, errs
+}
+
+func convertGCPAdditionalDiskToCAPI(mapiDisk *mapiv1.GCPDisk) capgv1.AttachedDiskSpec {
+	additionalDisk := capgv1.AttachedDiskSpec{}
+
+	if mapiDisk.Type != "" {
+		additionalDisk.DeviceType = ptr.To(capgv1.DiskType(mapiDisk.Type))
+	}
+
+	if mapiDisk.SizeGB != 0 {
+		additionalDisk.Size = ptr.To(mapiDisk.SizeGB)
+	}
+
+	return additionalDisk
+}
+
+// convertGCPNetworkInterfacesToCAPI converts the single MAPI network interface to the CAPG subnet and public IP.
+// The network and its host project are set on the GCPCluster.
+func convertGCPNetworkInterfacesToCAPI(fldPath *field.Path, mapiNetworkInterfaces []*mapiv1.GCPNetworkInterface) (*string, bool, field.ErrorList) {
+	if len(mapiNetworkInterfaces) == 0 || mapiNetworkInterfaces[0] == nil {
+		return nil, false, nil
+	}
+
+	if len(mapiNetworkInterfaces) > 1 {
+		return nil, false, field.ErrorList{field.Invalid(fldPath, len(mapiNetworkInterfaces), "only a single network interface is supported")}
+	}
+
+	networkInterface := mapiNetworkInterfaces[0]
+
+	var subnet *string
+	if networkInterface.Subnetwork != "" {
+		subnet = ptr.To(networkInterface.Subnetwork)
+	}
+
+	return subnet, networkInterface.PublicIP, nil
+}
+
+// validateGCPScheduling checks the MAPI scheduling options that CAPG has no field for.
+// GCP never restarts preemptible instances and always terminates them on host maintenance,
+// whereas CAPG leaves automatic restarts enabled for all other instances.
+func validateGCPScheduling(fldPath *field.Path, providerSpec mapiv1.GCPMachineProviderSpec) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if providerSpec.Preemptible {
+		if providerSpec.RestartPolicy == mapiv1.RestartPolicyAlways {
+			errs = append(errs, field.Invalid(fldPath.Child("restartPolicy"), providerSpec.RestartPolicy, "restartPolicy cannot be Always for preemptible instances"))
+		}
+
+		if providerSpec.OnHostMaintenance == mapiv1.MigrateHostMaintenanceType {
+			errs = append(errs, field.Invalid(fldPath.Child("onHostMaintenance"), providerSpec.OnHostMaintenance, "onHostMaintenance must be Terminate for preemptible instances"))
+		}
+	} else if providerSpec.RestartPolicy == mapiv1.RestartPolicyNever {
+		errs = append(errs, field.Invalid(fldPath.Child("restartPolicy"), providerSpec.RestartPolicy, "restartPolicy Never is only supported for preemptible instances, CAPG always restarts other instances"))
+	}
+
+	return errs
+}
+
+func convertGCPLabelsToCAPI(mapiLabels map[string]string) capgv1.Labels {
+	if len(mapiLabels) == 0 {
+		return nil
+	}
+
+	capgLabels := capgv1.Labels{}
+	for key, value := range mapiLabels {
+		capgLabels[key] = value
+	}
+
+	return capgLabels
+}
+
+func convertGCPTagsToCAPI(mapiTags []string) []string {
+	if len(mapiTags) == 0 {
+		return nil
+	}
+
+	return append([]string{}, mapiTags...)
+}
+
+func convertGCPResourceManagerTagsToCAPI(mapiTags []mapiv1.ResourceManagerTag) capgv1.ResourceManagerTags {
+	if len(mapiTags) == 0 {
+		return nil
+	}
+
+	capgTags := capgv1.ResourceManagerTags{}
+	for _, tag := range mapiTags {
+		capgTags = append(capgTags, capgv1.ResourceManagerTag{
+			ParentID: tag.ParentID,
+			Key:      tag.Key,
+			Value:    tag.Value,
+		})
+	}
+
+	return capgTags
+}
+
+func convertGCPMetadataToCAPI(mapiMetadata []*mapiv1.GCPMetadata) []capgv1.MetadataItem {
+	var capgMetadata []capgv1.MetadataItem
+
+	for _, metadata := range mapiMetadata {
+		if metadata == nil {
+			continue
+		}
+
+		capgMetadata = append(capgMetadata, capgv1.MetadataItem{
+			Key:   metadata.Key,
+			Value: metadata.Value,
+		})
+	}
+
+	return capgMetadata
+}
+
+// convertGCPCanIPForwardToCAPI converts the MAPI IP forwarding flag to its CAPG equivalent.
+// CAPG enables IP forwarding by default, so it is always set explicitly.
+func convertGCPCanIPForwardToCAPI(canIPForward bool) *capgv1.IPForwarding {
+	if canIPForward {
+		return ptr.To(capgv1.IPForwardingEnabled)
+	}
+
+	return ptr.To(capgv1.IPForwardingDisabled)
+}
+
+func convertGCPOnHostMaintenanceToCAPI(onHostMaintenance mapiv1.GCPHostMaintenanceType) *capgv1.HostMaintenancePolicy {
+	switch onHostMaintenance {
+	case mapiv1.MigrateHostMaintenanceType:
+		return ptr.To(capgv1.HostMaintenancePolicyMigrate)
+	case mapiv1.TerminateHostMaintenanceType:
+		return ptr.To(capgv1.HostMaintenancePolicyTerminate)
+	default:
+		return nil
+	}
+}
+
+// convertGCPServiceAccountsToCAPI converts the MAPI service accounts to the single CAPG service account.
+// GCP only allows a single service account per instance, but MAPI does not enforce this, so reject anything more.
+func convertGCPServiceAccountsToCAPI(fldPath *field.Path, mapiServiceAccounts []mapiv1.GCPServiceAccount) (*capgv1.ServiceAccount, field.ErrorList) {
+	switch len(mapiServiceAccounts) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &capgv1.ServiceAccount{
+			Email:  mapiServiceAccounts[0].Email,
+			Scopes: mapiServiceAccounts[0].Scopes,
+		}, nil
+	default:
+		return nil, field.ErrorList{field.Invalid(fldPath, len(mapiServiceAccounts), "only a single service account is supported, CAPG attaches exactly one service account to each instance")}
+	}
+}
+
+// convertGCPShieldedInstanceConfigToCAPI converts the MAPI shielded VM options to their CAPG equivalent.
+// Both APIs share the same policy values, but any value CAPG does not know about is rejected rather than dropped.
+func convertGCPShieldedInstanceConfigToCAPI(fldPath *field.Path, mapiConfig mapiv1.GCPShieldedInstanceConfig) (*capgv1.GCPShieldedInstanceConfig, field.ErrorList) {
+	if mapiConfig == (mapiv1.GCPShieldedInstanceConfig{}) {
+		return nil, nil
+	}
+
+	errs := field.ErrorList{}
+
+	switch mapiConfig.SecureBoot {
+	case "", mapiv1.SecureBootPolicyEnabled, mapiv1.SecureBootPolicyDisabled:
+	default:
+		errs = append(errs, field.NotSupported(fldPath.Child("secureBoot"), mapiConfig.SecureBoot, []string{string(capgv1.SecureBootPolicyEnabled), string(capgv1.SecureBootPolicyDisabled)}))
+	}
+
+	switch mapiConfig.VirtualizedTrustedPlatformModule {
+	case "", mapiv1.VirtualizedTrustedPlatformModulePolicyEnabled, mapiv1.VirtualizedTrustedPlatformModulePolicyDisabled:
+	default:
+		errs = append(errs, field.NotSupported(fldPath.Child("virtualizedTrustedPlatformModule"), mapiConfig.VirtualizedTrustedPlatformModule,
+			[]string{string(capgv1.VirtualizedTrustedPlatformModulePolicyEnabled), string(capgv1.VirtualizedTrustedPlatformModulePolicyDisabled)}))
+	}
+
+	switch mapiConfig.IntegrityMonitoring {
+	case "", mapiv1.IntegrityMonitoringPolicyEnabled, mapiv1.IntegrityMonitoringPolicyDisabled:
+	default:
+		errs = append(errs, field.NotSupported(fldPath.Child("integrityMonitoring"), mapiConfig.IntegrityMonitoring,
+			[]string{string(capgv1.IntegrityMonitoringPolicyEnabled), string(capgv1.IntegrityMonitoringPolicyDisabled)}))
+	}
+
+	return &capgv1.GCPShieldedInstanceConfig{
+		SecureBoot:                       capgv1.SecureBootPolicy(mapiConfig.SecureBoot),
+		VirtualizedTrustedPlatformModule: capgv1.VirtualizedTrustedPlatformModulePolicy(mapiConfig.VirtualizedTrustedPlatformModule),
+		IntegrityMonitoring:              capgv1.IntegrityMonitoringPolicy(mapiConfig.IntegrityMonitoring),
+	}, errs
+}
+
+// convertGCPConfidentialComputeToCAPI converts the MAPI confidential compute policy to its CAPG equivalent.
+// Confidential instances cannot be live migrated, so they must terminate on host maintenance.
+func convertGCPConfidentialComputeToCAPI(fldPath *field.Path, providerSpec mapiv1.GCPMachineProviderSpec) (*capgv1.ConfidentialComputePolicy, field.ErrorList) {
+	errs := field.ErrorList{}
+
+	switch providerSpec.ConfidentialCompute {
+	case "":
+		return nil, errs
+	case mapiv1.ConfidentialComputePolicyEnabled:
+		if providerSpec.OnHostMaintenance != mapiv1.TerminateHostMaintenanceType {
+			errs = append(errs, field.Invalid(fldPath.Child("onHostMaintenance"), providerSpec.OnHostMaintenance, "onHostMaintenance must be Terminate when confidentialCompute is Enabled"))
+		}
+
+		return ptr.To(capgv1.ConfidentialComputePolicyEnabled), errs
+	case mapiv1.ConfidentialComputePolicyDisabled:
+		return ptr.To(capgv1.ConfidentialComputePolicyDisabled), errs
+	default:
+		// TODO: The vendored CAPG API only has a boolean style policy, so the technology specific
+		// SEV, SEV-SNP and TDX policies cannot be carried over until it gains a confidential instance type.
+		return nil, append(errs, field.NotSupported(fldPath.Child("confidentialCompute"), providerSpec.ConfidentialCompute,
+			[]string{string(capgv1.ConfidentialComputePolicyEnabled), string(capgv1.ConfidentialComputePolicyDisabled)}))
+	}
+}
+
+func handleUnsupportedGCPProviderSpecFields(fldPath *field.Path, providerSpec mapiv1.GCPMachineProviderSpec) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if providerSpec.DeletionProtection {
+		// CAPG has no support for deletion protection, and would not be able to delete the machine with it enabled.
+		errs = append(errs, field.Invalid(fldPath.Child("deletionProtection"), providerSpec.DeletionProtection, "deletionProtection is not supported"))
+	}
+
+	if len(providerSpec.TargetPools) > 0 {
+		// CAPG only registers control plane machines with its own load balancer.
+		errs = append(errs, field.Invalid(fldPath.Child("targetPools"), providerSpec.TargetPools, "targetPools are not supported"))
+	}
+
+	if len(providerSpec.GPUs) > 0 {
+		// TODO: Convert the GPUs to guest accelerators once CAPG is able to attach them.
+		// Accelerator optimized machine types come with their GPUs attached, and so are converted as any other machine type.
+		errs = append(errs, field.Forbidden(fldPath.Child("gpus"), "gpus are not supported on Cluster API, CAPG cannot attach guest accelerators, use an accelerator optimized machine type instead"))
+	}
+
+	return errs
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mapi2capi_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	fuzz "github.com/google/gofuzz"
+
+	configv1 "github.com/openshift/api/config/v1"
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/capi2mapi"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/mapi2capi"
+	conversiontest "github.com/openshift/cluster-capi-operator/pkg/conversion/test/fuzz"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeserializer "k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/utils/ptr"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	capgv1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+const (
+	gcpProjectID   = "sample-project"
+	gcpRegion      = "us-central1"
+	gcpNetwork     = "sample-cluster-network"
+	gcpHostProject = "sample-host-project"
+)
+
+var _ = Describe("GCP Fuzz (mapi2capi)", func() {
+	infra := &configv1.Infrastructure{
+		Spec: configv1.InfrastructureSpec{},
+		Status: configv1.InfrastructureStatus{
+			InfrastructureName: "sample-cluster-name",
+			PlatformStatus: &configv1.PlatformStatus{
+				Type: configv1.GCPPlatformType,
+				GCP: &configv1.GCPPlatformStatus{
+					ProjectID: gcpProjectID,
+					Region:    gcpRegion,
+				},
+			},
+		},
+	}
+
+	infraCluster := &capgv1.GCPCluster{
+		Spec: capgv1.GCPClusterSpec{
+			Project: gcpProjectID,
+			Region:  gcpRegion,
+			Network: capgv1.NetworkSpec{
+				Name:        ptr.To(gcpNetwork),
+				HostProject: ptr.To(gcpHostProject),
+			},
+		},
+	}
+
+	Context("GCPMachine Conversion", func() {
+		fromMachineAndGCPMachineAndGCPCluster := func(machine *capiv1.Machine, infraMachine client.Object, infraCluster client.Object) capi2mapi.MachineAndInfrastructureMachine {
+			gcpMachine, ok := infraMachine.(*capgv1.GCPMachine)
+			Expect(ok).To(BeTrue(), "input infra machine should be of type %T, got %T", &capgv1.GCPMachine{}, infraMachine)
+
+			gcpCluster, ok := infraCluster.(*capgv1.GCPCluster)
+			Expect(ok).To(BeTrue(), "input infra cluster should be of type %T, got %T", &capgv1.GCPCluster{}, infraCluster)
+
+			return capi2mapi.FromMachineAndGCPMachineAndGCPCluster(machine, gcpMachine, gcpCluster)
+		}
+
+		conversiontest.MAPI2CAPIMachineRoundTripFuzzTest(
+			scheme,
+			infra,
+			infraCluster,
+			mapi2capi.FromGCPMachineAndInfra,
+			fromMachineAndGCPMachineAndGCPCluster,
+			conversiontest.ObjectMetaFuzzerFuncs(mapiNamespace),
+			conversiontest.MAPIMachineFuzzerFuncs(&mapiv1.GCPMachineProviderSpec{}, gcpProviderIDFuzzer),
+			gcpProviderSpecFuzzerFuncs,
+		)
+	})
+
+	Context("GCPMachineSet Conversion", func() {
+		fromMachineSetAndGCPMachineTemplateAndGCPCluster := func(machineSet *capiv1.MachineSet, infraMachineTemplate client.Object, infraCluster client.Object) capi2mapi.MachineSetAndMachineTemplate {
+			gcpMachineTemplate, ok := infraMachineTemplate.(*capgv1.GCPMachineTemplate)
+			Expect(ok).To(BeTrue(), "input infra machine template should be of type %T, got %T", &capgv1.GCPMachineTemplate{}, infraMachineTemplate)
+
+			gcpCluster, ok := infraCluster.(*capgv1.GCPCluster)
+			Expect(ok).To(BeTrue(), "input infra cluster should be of type %T, got %T", &capgv1.GCPCluster{}, infraCluster)
+
+			return capi2mapi.FromMachineSetAndGCPMachineTemplateAndGCPCluster(machineSet, gcpMachineTemplate, gcpCluster)
+		}
+
+		conversiontest.MAPI2CAPIMachineSetRoundTripFuzzTest(
+			scheme,
+			infra,
+			infraCluster,
+			mapi2capi.FromGCPMachineSetAndInfra,
+			fromMachineSetAndGCPMachineTemplateAndGCPCluster,
+			conversiontest.ObjectMetaFuzzerFuncs(mapiNamespace),
+			conversiontest.MAPIMachineFuzzerFuncs(&mapiv1.GCPMachineProviderSpec{}, gcpProviderIDFuzzer),
+			conversiontest.MAPIMachineSetFuzzerFuncs(),
+			gcpProviderSpecFuzzerFuncs,
+		)
+	})
+})
+
+func gcpProviderIDFuzzer(c fuzz.Continue) string {
+	return "gce://" + gcpProjectID + "/" + gcpRegion + "-a/" + strings.ReplaceAll(c.RandString(), "/", "")
+}
+
+func gcpProviderSpecFuzzerFuncs(codecs runtimeserializer.CodecFactory) []interface{} {
+	return []interface{}{
+		func(disk *mapiv1.GCPDisk, c fuzz.Continue) {
+			c.FuzzNoCustom(disk)
+
+			// CAPG always deletes disks with the machine, and has no labels for them.
+			disk.AutoDelete = true
+			disk.Labels = nil
+			disk.EncryptionKey = nil
+
+			// An image is always required for the boot disk, the provider spec fuzzer clears it from the additional disks.
+			disk.Image = "projects/" + strings.ReplaceAll(c.RandString(), "/", "") + []string{"/global/images/", "/global/images/family/"}[c.Intn(2)] + strings.ReplaceAll(c.RandString(), "/", "")
+		},
+		func(networkInterface *mapiv1.GCPNetworkInterface, c fuzz.Continue) {
+			c.FuzzNoCustom(networkInterface)
+
+			// The network and its project are taken from the GCPCluster on the way back, so force them to match it here.
+			networkInterface.Network = gcpNetwork
+			networkInterface.ProjectID = gcpHostProject
+		},
+		func(config *mapiv1.GCPShieldedInstanceConfig, c fuzz.Continue) {
+			policies := []string{"", "Enabled", "Disabled"}
+
+			config.SecureBoot = mapiv1.SecureBootPolicy(policies[c.Intn(len(policies))])
+			config.VirtualizedTrustedPlatformModule = mapiv1.VirtualizedTrustedPlatformModulePolicy(policies[c.Intn(len(policies))])
+			config.IntegrityMonitoring = mapiv1.IntegrityMonitoringPolicy(policies[c.Intn(len(policies))])
+		},
+		func(ps *mapiv1.GCPMachineProviderSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(ps)
+
+			// The type meta is always set to these values by the conversion.
+			ps.Kind = "GCPMachineProviderSpec"
+			ps.APIVersion = "machine.openshift.io/v1beta1"
+
+			// These fields are taken from the GCPCluster on the way back, so force them to match it here.
+			ps.ProjectID = gcpProjectID
+			ps.Region = gcpRegion
+
+			// The boot disk is always converted back first, followed by the additional disks.
+			ps.Disks = []*mapiv1.GCPDisk{{}}
+			c.Fuzz(ps.Disks[0])
+			ps.Disks[0].Boot = true
+
+			for range c.Intn(3) {
+				disk := &mapiv1.GCPDisk{}
+				c.Fuzz(disk)
+				// Additional disks are always created empty.
+				disk.Boot = false
+				disk.Image = ""
+
+				ps.Disks = append(ps.Disks, disk)
+			}
+
+			// Exactly one network interface is converted.
+			ps.NetworkInterfaces = []*mapiv1.GCPNetworkInterface{{}}
+			c.Fuzz(ps.NetworkInterfaces[0])
+
+			metadata := []*mapiv1.GCPMetadata{}
+
+			for _, m := range ps.Metadata {
+				if m != nil {
+					metadata = append(metadata, m)
+				}
+			}
+
+			ps.Metadata = metadata
+			if len(ps.Metadata) == 0 {
+				ps.Metadata = nil
+			}
+
+			if len(ps.Labels) == 0 {
+				ps.Labels = nil
+			}
+
+			if len(ps.Tags) == 0 {
+				ps.Tags = nil
+			}
+
+			if len(ps.ResourceManagerTags) == 0 {
+				ps.ResourceManagerTags = nil
+			}
+
+			// CAPG only supports a single service account.
+			if len(ps.ServiceAccounts) == 0 {
+				ps.ServiceAccounts = nil
+			} else {
+				ps.ServiceAccounts = ps.ServiceAccounts[:1]
+
+				if len(ps.ServiceAccounts[0].Scopes) == 0 {
+					ps.ServiceAccounts[0].Scopes = nil
+				}
+			}
+
+			// CAPG leaves automatic restarts to the platform, and GCP always terminates preemptible and confidential instances on host maintenance.
+			ps.RestartPolicy = ""
+
+			switch c.Intn(3) {
+			case 0:
+				ps.OnHostMaintenance = ""
+			case 1:
+				ps.OnHostMaintenance = mapiv1.TerminateHostMaintenanceType
+			case 2:
+				ps.OnHostMaintenance = mapiv1.MigrateHostMaintenanceType
+			}
+
+			ps.ConfidentialCompute = []mapiv1.ConfidentialComputePolicy{"", mapiv1.ConfidentialComputePolicyEnabled, mapiv1.ConfidentialComputePolicyDisabled}[c.Intn(3)]
+
+			if (ps.Preemptible || ps.ConfidentialCompute == mapiv1.ConfidentialComputePolicyEnabled) && ps.OnHostMaintenance != mapiv1.TerminateHostMaintenanceType {
+				ps.OnHostMaintenance = mapiv1.TerminateHostMaintenanceType
+			}
+
+			// Clear fields that are not supported in the provider spec.
+			ps.ObjectMeta = metav1.ObjectMeta{}
+			ps.DeletionProtection = false
+			ps.TargetPools = nil
+
+			// Clear fields that are not yet supported in the provider spec.
+			ps.GPUs = nil
+
+			if ps.UserDataSecret != nil && ps.UserDataSecret.Name == "" {
+				ps.UserDataSecret = nil
+			} else if ps.UserDataSecret != nil {
+				ps.UserDataSecret = &corev1.LocalObjectReference{Name: ps.UserDataSecret.Name}
+			}
+		},
+	}
+}
@@ -0,0 +1,352 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mapi2capi
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	configv1 "github.com/openshift/api/config/v1"
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+	machinebuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/machine/v1beta1"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/test/matchers"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	capgv1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+)
+
+var _ = Describe("mapi2capi GCP conversion", func() {
+	var (
+		gcpBaseProviderSpec   = machinebuilder.GCPProviderSpec().WithTargetPools(nil)
+		gcpMAPIMachineSetBase = machinebuilder.MachineSet().WithProviderSpecBuilder(gcpBaseProviderSpec)
+
+		infra = &configv1.Infrastructure{
+			Spec: configv1.InfrastructureSpec{},
+			Status: configv1.InfrastructureStatus{
+				InfrastructureName: "sample-cluster-name",
+				PlatformStatus: &configv1.PlatformStatus{
+					Type: configv1.GCPPlatformType,
+					GCP: &configv1.GCPPlatformStatus{
+						ProjectID: "openshift-cpms-unit-tests",
+						Region:    "us-central1",
+					},
+				},
+			},
+		}
+	)
+
+	var gcpProviderSpec = func(modify func(*mapiv1.GCPMachineProviderSpec)) mapiv1.ProviderSpec {
+		providerSpec := gcpBaseProviderSpec.Build()
+
+		if modify != nil {
+			modify(providerSpec)
+		}
+
+		rawBytes, err := json.Marshal(providerSpec)
+		if err != nil {
+			panic(fmt.Sprintf("unable to convert (marshal) test GCPProviderSpec to runtime.RawExtension: %v", err))
+		}
+
+		return mapiv1.ProviderSpec{
+			Value: &runtime.RawExtension{Raw: rawBytes},
+		}
+	}
+
+	var gcpMAPIMachine = func(modify func(*mapiv1.GCPMachineProviderSpec)) *mapiv1.Machine {
+		return machinebuilder.Machine().WithProviderSpec(gcpProviderSpec(modify)).Build()
+	}
+
+	type gcpMAPI2CAPIConversionInput struct {
+		machine          *mapiv1.Machine
+		infra            *configv1.Infrastructure
+		expectedErrors   []string
+		expectedWarnings []string
+	}
+
+	var _ = DescribeTable("mapi2capi GCP convert MAPI Machine",
+		func(in gcpMAPI2CAPIConversionInput) {
+			_, _, warns, err := FromGCPMachineAndInfra(in.machine, in.infra).ToMachineAndInfrastructureMachine()
+			Expect(err).To(matchers.ConsistOfMatchErrorSubstrings(in.expectedErrors), "should match expected errors while converting a GCP MAPI Machine to CAPI")
+			Expect(warns).To(matchers.ConsistOfSubstrings(in.expectedWarnings), "should match expected warnings while converting a GCP MAPI Machine to CAPI")
+		},
+
+		// Base Case.
+		Entry("With a Base configuration", gcpMAPI2CAPIConversionInput{
+			machine:          gcpMAPIMachine(nil),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a preemptible instance", gcpMAPI2CAPIConversionInput{
+			machine: gcpMAPIMachine(func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.Preemptible = true
+				ps.OnHostMaintenance = mapiv1.TerminateHostMaintenanceType
+				ps.RestartPolicy = mapiv1.RestartPolicyNever
+			}),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a preemptible instance that migrates on host maintenance", gcpMAPI2CAPIConversionInput{
+			machine: gcpMAPIMachine(func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.Preemptible = true
+				ps.OnHostMaintenance = mapiv1.MigrateHostMaintenanceType
+				ps.RestartPolicy = mapiv1.RestartPolicyAlways
+			}),
+			infra: infra,
+			expectedErrors: []string{
+				"spec.providerSpec.value.onHostMaintenance: Invalid value: \"Migrate\": onHostMaintenance must be Terminate for preemptible instances",
+				"spec.providerSpec.value.restartPolicy: Invalid value: \"Always\": restartPolicy cannot be Always for preemptible instances",
+			},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With automatic restarts disabled on a standard instance", gcpMAPI2CAPIConversionInput{
+			machine: gcpMAPIMachine(func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.RestartPolicy = mapiv1.RestartPolicyNever
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.restartPolicy: Invalid value: \"Never\": restartPolicy Never is only supported for preemptible instances, CAPG always restarts other instances"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("Without a boot disk", gcpMAPI2CAPIConversionInput{
+			machine: gcpMAPIMachine(func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.Disks = nil
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.disks: Required value: a boot disk is required"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a boot disk that is not deleted with the machine", gcpMAPI2CAPIConversionInput{
+			machine: gcpMAPIMachine(func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.Disks[0].AutoDelete = false
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.disks[0].autoDelete: Invalid value: false: autoDelete must be true, CAPG always deletes disks with the machine"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With additional persistent and local SSD disks", gcpMAPI2CAPIConversionInput{
+			machine: gcpMAPIMachine(func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.Disks = append(ps.Disks,
+					&mapiv1.GCPDisk{AutoDelete: true, SizeGB: 256, Type: "pd-ssd"},
+					&mapiv1.GCPDisk{AutoDelete: true, SizeGB: 375, Type: "local-ssd"},
+				)
+			}),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With an additional disk created from an image", gcpMAPI2CAPIConversionInput{
+			machine: gcpMAPIMachine(func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.Disks = append(ps.Disks, &mapiv1.GCPDisk{AutoDelete: true, SizeGB: 256, Image: "projects/project/global/images/data"})
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.disks[1].image: Invalid value: \"projects/project/global/images/data\": image is only supported on the boot disk, CAPG always creates additional disks empty"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With labels and network tags", gcpMAPI2CAPIConversionInput{
+			machine: gcpMAPIMachine(func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.Labels = map[string]string{"team": "openshift", "environment": "test"}
+				ps.Tags = []string{"gcp-tag-12345678", "gcp-tag-87654321"}
+			}),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With resource manager tags", gcpMAPI2CAPIConversionInput{
+			machine: gcpMAPIMachine(func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.ResourceManagerTags = []mapiv1.ResourceManagerTag{
+					{ParentID: "openshift-cpms-unit-tests", Key: "environment", Value: "test"},
+				}
+			}),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With multiple service accounts", gcpMAPI2CAPIConversionInput{
+			machine: gcpMAPIMachine(func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.ServiceAccounts = append(ps.ServiceAccounts, mapiv1.GCPServiceAccount{
+					Email:  "other@openshift-cpms-unit-tests.iam.gserviceaccount.com",
+					Scopes: []string{"https://www.googleapis.com/auth/cloud-platform"},
+				})
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.serviceAccounts: Invalid value: 2: only a single service account is supported, CAPG attaches exactly one service account to each instance"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With multiple network interfaces", gcpMAPI2CAPIConversionInput{
+			machine: gcpMAPIMachine(func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.NetworkInterfaces = append(ps.NetworkInterfaces, &mapiv1.GCPNetworkInterface{Network: "other-network"})
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.networkInterfaces: Invalid value: 2: only a single network interface is supported"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a project that does not match the infrastructure", gcpMAPI2CAPIConversionInput{
+			machine: gcpMAPIMachine(func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.ProjectID = "other-project"
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.projectID: Invalid value: \"other-project\": projectID should match infrastructure status value \"openshift-cpms-unit-tests\""},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With shielded VM options", gcpMAPI2CAPIConversionInput{
+			machine: gcpMAPIMachine(func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.ShieldedInstanceConfig = mapiv1.GCPShieldedInstanceConfig{
+					SecureBoot:                       mapiv1.SecureBootPolicyEnabled,
+					VirtualizedTrustedPlatformModule: mapiv1.VirtualizedTrustedPlatformModulePolicyEnabled,
+					IntegrityMonitoring:              mapiv1.IntegrityMonitoringPolicyDisabled,
+				}
+			}),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a shielded VM option unknown to CAPG", gcpMAPI2CAPIConversionInput{
+			machine: gcpMAPIMachine(func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.ShieldedInstanceConfig = mapiv1.GCPShieldedInstanceConfig{
+					SecureBoot: mapiv1.SecureBootPolicy("Strict"),
+				}
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.shieldedInstanceConfig.secureBoot: Unsupported value: \"Strict\": supported values: \"Enabled\", \"Disabled\""},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With confidential compute", gcpMAPI2CAPIConversionInput{
+			machine: gcpMAPIMachine(func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.MachineType = "n2d-standard-4"
+				ps.ConfidentialCompute = mapiv1.ConfidentialComputePolicyEnabled
+				ps.OnHostMaintenance = mapiv1.TerminateHostMaintenanceType
+			}),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With confidential compute that migrates on host maintenance", gcpMAPI2CAPIConversionInput{
+			machine: gcpMAPIMachine(func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.MachineType = "n2d-standard-4"
+				ps.ConfidentialCompute = mapiv1.ConfidentialComputePolicyEnabled
+				ps.OnHostMaintenance = mapiv1.MigrateHostMaintenanceType
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.onHostMaintenance: Invalid value: \"Migrate\": onHostMaintenance must be Terminate when confidentialCompute is Enabled"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a confidential compute technology unknown to CAPG", gcpMAPI2CAPIConversionInput{
+			machine: gcpMAPIMachine(func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.ConfidentialCompute = mapiv1.ConfidentialComputePolicy("IntelTrustedDomainExtensions")
+				ps.OnHostMaintenance = mapiv1.TerminateHostMaintenanceType
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.confidentialCompute: Unsupported value: \"IntelTrustedDomainExtensions\": supported values: \"Enabled\", \"Disabled\""},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With GPUs", gcpMAPI2CAPIConversionInput{
+			machine: gcpMAPIMachine(func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.GPUs = []mapiv1.GCPGPUConfig{{Type: "nvidia-tesla-t4", Count: 1}}
+				ps.OnHostMaintenance = mapiv1.TerminateHostMaintenanceType
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.gpus: Forbidden: gpus are not supported on Cluster API, CAPG cannot attach guest accelerators, use an accelerator optimized machine type instead"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With an accelerator optimized machine type", gcpMAPI2CAPIConversionInput{
+			machine: gcpMAPIMachine(func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.MachineType = "g2-standard-4"
+				ps.OnHostMaintenance = mapiv1.TerminateHostMaintenanceType
+			}),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With unsupported target pools and deletion protection", gcpMAPI2CAPIConversionInput{
+			machine: gcpMAPIMachine(func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.TargetPools = []string{"target-pool"}
+				ps.DeletionProtection = true
+			}),
+			infra: infra,
+			expectedErrors: []string{
+				"spec.providerSpec.value.targetPools: Invalid value: []string{\"target-pool\"}: targetPools are not supported",
+				"spec.providerSpec.value.deletionProtection: Invalid value: true: deletionProtection is not supported",
+			},
+			expectedWarnings: []string{},
+		}),
+	)
+
+	var _ = DescribeTable("mapi2capi GCP convert MAPI MachineSet",
+		func(in gcpMAPI2CAPIConversionInput) {
+			machineSet := gcpMAPIMachineSetBase.WithProviderSpec(in.machine.Spec.ProviderSpec).Build()
+
+			_, _, warns, err := FromGCPMachineSetAndInfra(machineSet, in.infra).ToMachineSetAndMachineTemplate()
+			Expect(err).To(matchers.ConsistOfMatchErrorSubstrings(in.expectedErrors), "should match expected errors while converting a GCP MAPI MachineSet to CAPI")
+			Expect(warns).To(matchers.ConsistOfSubstrings(in.expectedWarnings), "should match expected warnings while converting a GCP MAPI MachineSet to CAPI")
+		},
+
+		Entry("With a Base configuration", gcpMAPI2CAPIConversionInput{
+			machine:          gcpMAPIMachine(nil),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+	)
+
+	var _ = DescribeTable("mapi2capi GCP convert MAPI scheduling options",
+		func(modify func(*mapiv1.GCPMachineProviderSpec), expectedPreemptible bool, expectedOnHostMaintenance *capgv1.HostMaintenancePolicy) {
+			_, infraMachine, _, err := FromGCPMachineAndInfra(gcpMAPIMachine(modify), infra).ToMachineAndInfrastructureMachine()
+			Expect(err).ToNot(HaveOccurred())
+
+			gcpMachine, ok := infraMachine.(*capgv1.GCPMachine)
+			Expect(ok).To(BeTrue())
+			Expect(gcpMachine.Spec.Preemptible).To(Equal(expectedPreemptible))
+			Expect(gcpMachine.Spec.OnHostMaintenance).To(Equal(expectedOnHostMaintenance))
+		},
+
+		Entry("With a standard instance", nil, false, nil),
+		Entry("With a standard instance that terminates on host maintenance",
+			func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.OnHostMaintenance = mapiv1.TerminateHostMaintenanceType
+			},
+			false, ptr.To(capgv1.HostMaintenancePolicyTerminate),
+		),
+		Entry("With a preemptible instance, left to the platform to terminate on host maintenance",
+			func(ps *mapiv1.GCPMachineProviderSpec) {
+				ps.Preemptible = true
+			},
+			true, nil,
+		),
+	)
+})
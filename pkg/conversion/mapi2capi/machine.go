@@ -30,10 +30,22 @@ import (
 )
 
 const (
-	capiNamespace            = "openshift-cluster-api"
-	workerUserDataSecretName = "worker-user-data"
-	awsMachineKind           = "AWSMachine"
-	awsMachineTemplateKind   = "AWSMachineTemplate"
+	capiNamespace                = "openshift-cluster-api"
+	machineRoleLabel             = "machine.openshift.io/cluster-api-machine-role"
+	masterMachineRole            = "master"
+	workerUserDataSecretName     = "worker-user-data"
+	awsMachineKind               = "AWSMachine"
+	awsMachineTemplateKind       = "AWSMachineTemplate"
+	azureMachineKind             = "AzureMachine"
+	azureMachineTemplateKind     = "AzureMachineTemplate"
+	gcpMachineKind               = "GCPMachine"
+	gcpMachineTemplateKind       = "GCPMachineTemplate"
+	openstackMachineKind         = "OpenStackMachine"
+	openstackMachineTemplateKind = "OpenStackMachineTemplate"
+	powerVSMachineKind           = "IBMPowerVSMachine"
+	powerVSMachineTemplateKind   = "IBMPowerVSMachineTemplate"
+	vsphereMachineKind           = "VSphereMachine"
+	vsphereMachineTemplateKind   = "VSphereMachineTemplate"
 )
 
 var (
@@ -189,3 +201,30 @@ func handleUnsupportedMAPIObjectMetaFields(fldPath *field.Path, objectMeta mapiv
 
 	return errs
 }
+
+// isControlPlaneMachine returns whether the MAPI Machine has the control plane role.
+// Machines converted from a MachineSet template never do, as the template labels are not carried over.
+func isControlPlaneMachine(mapiMachine *mapiv1.Machine) bool {
+	return mapiMachine.Labels[machineRoleLabel] == masterMachineRole
+}
+
+// preserveProviderSpecFields records the named providerSpec fields, which have no CAPI equivalent, on the CAPI Machine
+// so that converting the CAPI Machine back to MAPI restores them.
+func preserveProviderSpecFields(mapiMachine *mapiv1.Machine, capiMachine *capiv1.Machine, fieldNames ...string) field.ErrorList {
+	value, err := conversionutil.PreserveProviderSpecFields(mapiMachine.Spec.ProviderSpec.Value, fieldNames...)
+	if err != nil {
+		return field.ErrorList{field.Invalid(field.NewPath("spec", "providerSpec", "value"), mapiMachine.Spec.ProviderSpec.Value, err.Error())}
+	}
+
+	if value == "" {
+		return nil
+	}
+
+	if capiMachine.Annotations == nil {
+		capiMachine.Annotations = map[string]string{}
+	}
+
+	capiMachine.Annotations[conversionutil.PreservedFieldsAnnotation] = value
+
+	return nil
+}
@@ -23,6 +23,7 @@ import (
 	configbuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/config/v1"
 	machinebuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/machine/v1beta1"
 	"github.com/openshift/cluster-capi-operator/pkg/conversion/test/matchers"
+	conversionutil "github.com/openshift/cluster-capi-operator/pkg/conversion/util"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
@@ -146,4 +147,17 @@ var _ = Describe("mapi2capi Machine conversion", func() {
 			expectedWarnings: []string{},
 		}),
 	)
+
+	It("should preserve providerSpec fields without a CAPI equivalent in an annotation", func() {
+		machine := mapiMachineBase.WithProviderSpecBuilder(
+			awsBaseProviderSpec.WithCredentialsSecret(&corev1.LocalObjectReference{Name: "custom-credentials"}),
+		).Build()
+
+		capiMachine, infraMachine, _, err := FromAWSMachineAndInfra(machine, infraBase.Build()).ToMachineAndInfrastructureMachine()
+		Expect(err).ToNot(HaveOccurred())
+
+		expected := `{"providerSpec":{"credentialsSecret":{"name":"custom-credentials"}}}`
+		Expect(capiMachine.GetAnnotations()).To(HaveKeyWithValue(conversionutil.PreservedFieldsAnnotation, expected))
+		Expect(infraMachine.GetAnnotations()).To(HaveKeyWithValue(conversionutil.PreservedFieldsAnnotation, expected))
+	})
 })
@@ -0,0 +1,826 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mapi2capi
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	configv1 "github.com/openshift/api/config/v1"
+	mapiv1alpha1 "github.com/openshift/api/machine/v1alpha1"
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/ptr"
+	capov1 "sigs.k8s.io/cluster-api-provider-openstack/api/v1beta1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// openstackPortProfileCapabilitiesKey and openstackPortProfileTrustedKey are the Neutron binding profile keys CAPO supports.
+	openstackPortProfileCapabilitiesKey = "capabilities"
+	openstackPortProfileTrustedKey      = "trusted"
+
+	// openstackPortProfileSwitchdevCapabilities is the binding profile capabilities value enabling OVS hardware offload.
+	openstackPortProfileSwitchdevCapabilities = `["switchdev"]`
+)
+
+var (
+	errUnexpectedObjectTypeForOpenStackMachine = errors.New("unexpected type for capoMachineObj")
+)
+
+// openstackMachineAndInfra stores the details of a Machine API OpenStack Machine and Infra.
+type openstackMachineAndInfra struct {
+	machine        *mapiv1.Machine
+	infrastructure *configv1.Infrastructure
+}
+
+// openstackMachineSetAndInfra stores the details of a Machine API OpenStack MachineSet and Infra.
+type openstackMachineSetAndInfra struct {
+	machineSet     *mapiv1.MachineSet
+	infrastructure *configv1.Infrastructure
+	*openstackMachineAndInfra
+}
+
+// FromOpenStackMachineAndInfra wraps a Machine API Machine for OpenStack and the OCP Infrastructure object into a mapi2capi OpenstackProviderSpec.
+func FromOpenStackMachineAndInfra(m *mapiv1.Machine, i *configv1.Infrastructure) Machine {
+	return &openstackMachineAndInfra{machine: m, infrastructure: i}
+}
+
+// FromOpenStackMachineSetAndInfra wraps a Machine API MachineSet for OpenStack and the OCP Infrastructure object into a mapi2capi OpenstackProviderSpec.
+func FromOpenStackMachineSetAndInfra(m *mapiv1.MachineSet, i *configv1.Infrastructure) MachineSet {
+	return &openstackMachineSetAndInfra{
+		machineSet:     m,
+		infrastructure: i,
+		openstackMachineAndInfra: &openstackMachineAndInfra{
+			machine: &mapiv1.Machine{
+				Spec: m.Spec.Template.Spec,
+			},
+			infrastructure: i,
+		},
+	}
+}
+
+// ToMachineAndInfrastructureMachine is used to generate a CAPI Machine and the corresponding InfrastructureMachine
+// from the stored MAPI Machine and Infrastructure objects.
+func (m *openstackMachineAndInfra) ToMachineAndInfrastructureMachine() (*capiv1.Machine, client.Object, []string, error) {
+	capiMachine, capoMachine, warnings, errs := m.toMachineAndInfrastructureMachine()
+
+	if len(errs) > 0 {
+		return nil, nil, warnings, errs.ToAggregate()
+	}
+
+	return capiMachine, capoMachine, warnings, nil
+}
+
+func (m *openstackMachineAndInfra) toMachineAndInfrastructureMachine() (*capiv1.Machine, client.Object, []string, field.ErrorList) {
+	var (
+		errs     field.ErrorList
+		warnings []string
+	)
+
+	openstackProviderSpec, err := openstackProviderSpecFromRawExtension(m.machine.Spec.ProviderSpec.Value)
+	if err != nil {
+		return nil, nil, nil, field.ErrorList{field.Invalid(field.NewPath("spec", "providerSpec", "value"), m.machine.Spec.ProviderSpec.Value, err.Error())}
+	}
+
+	capoMachine, warn, machineErrs := m.toOpenStackMachine(openstackProviderSpec)
+	if machineErrs != nil {
+		errs = append(errs, machineErrs...)
+	}
+
+	warnings = append(warnings, warn...)
+
+	capiMachine, machineErrs := fromMAPIMachineToCAPIMachine(m.machine)
+	if machineErrs != nil {
+		errs = append(errs, machineErrs...)
+	}
+
+	// The core conversion always references an AWSMachine, point it at the OpenStackMachine instead.
+	capiMachine.Spec.InfrastructureRef.APIVersion = capov1.GroupVersion.String()
+	capiMachine.Spec.InfrastructureRef.Kind = openstackMachineKind
+
+	// CAPO uses the same openstack:/// provider ID format as MAPO, so it is carried over as is.
+	capoMachine.Spec.ProviderID = capiMachine.Spec.ProviderID
+
+	// CAPO schedules the server into the availability zone of the Machine failure domain.
+	if openstackProviderSpec.AvailabilityZone != "" {
+		capiMachine.Spec.FailureDomain = ptr.To(openstackProviderSpec.AvailabilityZone)
+	}
+
+	if openstackProviderSpec.UserDataSecret != nil && openstackProviderSpec.UserDataSecret.Name != "" {
+		capiMachine.Spec.Bootstrap = capiv1.Bootstrap{
+			DataSecretName: &openstackProviderSpec.UserDataSecret.Name,
+		}
+	}
+
+	// Popluate the CAPI Machine ClusterName from the OCP Infrastructure object.
+	if m.infrastructure == nil || m.infrastructure.Status.InfrastructureName == "" {
+		errs = append(errs, field.Invalid(field.NewPath("infrastructure", "status", "infrastructureName"), m.infrastructure.Status.InfrastructureName, "infrastructure cannot be nil and infrastructure.Status.InfrastructureName cannot be empty"))
+	} else {
+		capiMachine.Spec.ClusterName = m.infrastructure.Status.InfrastructureName
+	}
+
+	errs = append(errs, preserveProviderSpecFields(m.machine, capiMachine, "cloudsSecret", "cloudName", "sshUserName")...)
+
+	// The InfraMachine should always have the same labels and annotations as the Machine.
+	// See https://github.com/kubernetes-sigs/cluster-api/blob/f88d7ae5155700c2cc367b31ddcc151c9ad579e4/internal/controllers/machineset/machineset_controller.go#L578-L579
+	capoMachine.SetAnnotations(capiMachine.GetAnnotations())
+	capoMachine.SetLabels(capiMachine.GetLabels())
+
+	return capiMachine, capoMachine, warnings, errs
+}
+
+// ToMachineSetAndMachineTemplate converts a mapi2capi OpenStackMachineSetAndInfra into a CAPI MachineSet and CAPO OpenStackMachineTemplate.
+func (m *openstackMachineSetAndInfra) ToMachineSetAndMachineTemplate() (*capiv1.MachineSet, client.Object, []string, error) {
+	var (
+		errs     []error
+		warnings []string
+	)
+
+	capiMachine, capoMachineObj, warn, err := m.toMachineAndInfrastructureMachine()
+	if err != nil {
+		errs = append(errs, err.ToAggregate().Errors()...)
+	}
+
+	warnings = append(warnings, warn...)
+
+	capoMachine, ok := capoMachineObj.(*capov1.OpenStackMachine)
+	if !ok {
+		panic(fmt.Errorf("%w: %T", errUnexpectedObjectTypeForOpenStackMachine, capoMachineObj))
+	}
+
+	capoMachineTemplate := openstackMachineToOpenStackMachineTemplate(capoMachine, m.machineSet.Name, capiNamespace)
+
+	capiMachineSet, machineSetErrs := fromMAPIMachineSetToCAPIMachineSet(m.machineSet)
+	if machineSetErrs != nil {
+		errs = append(errs, machineSetErrs.Errors()...)
+	}
+
+	capiMachineSet.Spec.Template.Spec = capiMachine.Spec
+
+	// We have to merge these two maps so that labels and annotations added to the template objectmeta are persisted
+	// along with the labels and annotations from the machine objectmeta.
+	capiMachineSet.Spec.Template.ObjectMeta.Labels = mergeMaps(capiMachineSet.Spec.Template.ObjectMeta.Labels, capiMachine.Labels)
+	capiMachineSet.Spec.Template.ObjectMeta.Annotations = mergeMaps(capiMachineSet.Spec.Template.ObjectMeta.Annotations, capiMachine.Annotations)
+
+	// Override the reference so that it matches the OpenStackMachineTemplate.
+	capiMachineSet.Spec.Template.Spec.InfrastructureRef.Kind = openstackMachineTemplateKind
+	capiMachineSet.Spec.Template.Spec.InfrastructureRef.Name = capoMachineTemplate.Name
+
+	if m.infrastructure == nil || m.infrastructure.Status.InfrastructureName == "" {
+		errs = append(errs, field.Invalid(field.NewPath("infrastructure", "status", "infrastructureName"), m.infrastructure.Status.InfrastructureName, "infrastructure cannot be nil and infrastructure.Status.InfrastructureName cannot be empty"))
+	} else {
+		capiMachineSet.Spec.Template.Spec.ClusterName = m.infrastructure.Status.InfrastructureName
+		capiMachineSet.Spec.ClusterName = m.infrastructure.Status.InfrastructureName
+	}
+
+	if len(errs) > 0 {
+		return nil, nil, warnings, utilerrors.NewAggregate(errs)
+	}
+
+	return capiMachineSet, capoMachineTemplate, warnings, nil
+}
+
+// toOpenStackMachine implements the ProviderSpec conversion interface for the OpenStack provider,
+// it converts OpenstackProviderSpec to OpenStackMachine.
+func (m *openstackMachineAndInfra) toOpenStackMachine(providerSpec mapiv1alpha1.OpenstackProviderSpec) (*capov1.OpenStackMachine, []string, field.ErrorList) {
+	fldPath := field.NewPath("spec", "providerSpec", "value")
+
+	var (
+		errs     field.ErrorList
+		warnings []string
+	)
+
+	if providerSpec.Flavor == "" {
+		errs = append(errs, field.Required(fldPath.Child("flavor"), "flavor is required"))
+	}
+
+	image, warn, imageErrs := convertOpenStackImageToCAPI(fldPath, providerSpec)
+	errs = append(errs, imageErrs...)
+	warnings = append(warnings, warn...)
+
+	ports, portErrs := convertOpenStackNetworksToCAPI(fldPath.Child("networks"), providerSpec.Networks)
+	errs = append(errs, portErrs...)
+
+	additionalPorts, portErrs := convertOpenStackPortsToCAPI(fldPath.Child("ports"), providerSpec.Ports)
+	errs = append(errs, portErrs...)
+
+	// MAPO attaches the ports for the networks before any additional ports.
+	ports = append(ports, additionalPorts...)
+
+	errs = append(errs, validateOpenStackPrimarySubnet(fldPath.Child("primarySubnet"), providerSpec.PrimarySubnet, ports)...)
+
+	securityGroups, securityGroupErrs := convertOpenStackSecurityGroupsToCAPI(fldPath.Child("securityGroups"), providerSpec.SecurityGroups)
+	errs = append(errs, securityGroupErrs...)
+
+	spec := capov1.OpenStackMachineSpec{
+		Flavor:                 providerSpec.Flavor,
+		Image:                  image,
+		SSHKeyName:             providerSpec.KeyName,
+		Ports:                  ports,
+		SecurityGroups:         securityGroups,
+		Trunk:                  providerSpec.Trunk,
+		Tags:                   convertOpenStackTagsToCAPI(providerSpec.Tags),
+		ServerMetadata:         convertOpenStackServerMetadataToCAPI(providerSpec.ServerMetadata),
+		ConfigDrive:            providerSpec.ConfigDrive,
+		RootVolume:             convertOpenStackRootVolumeToCAPI(providerSpec.RootVolume),
+		AdditionalBlockDevices: convertOpenStackAdditionalBlockDevicesToCAPI(providerSpec.AdditionalBlockDevices),
+		ServerGroup:            convertOpenStackServerGroupToCAPI(providerSpec.ServerGroupID, providerSpec.ServerGroupName),
+	}
+
+	if providerSpec.FloatingIP != "" {
+		// CAPO only assigns floating IPs from an IP address pool.
+		errs = append(errs, field.Invalid(fldPath.Child("floatingIP"), providerSpec.FloatingIP, "floatingIP is not supported"))
+	}
+
+	// Unused fields - Below this line are fields not used from the MAPI OpenstackProviderSpec.
+
+	// TypeMeta - Only for the purpose of the raw extension, not used for any functionality.
+	// AvailabilityZone - Set on the CAPI Machine as the failure domain.
+	// UserDataSecret - Set on the CAPI Machine as the bootstrap data secret.
+	// SshUserName - Not used by MAPO for any functionality.
+	// CloudsSecret - TODO(OCPCLOUD-2713): Work out what needs to happen regarding credentials secrets.
+	// CloudName - TODO(OCPCLOUD-2713): Work out what needs to happen regarding credentials secrets.
+
+	if !reflect.DeepEqual(providerSpec.ObjectMeta, metav1.ObjectMeta{}) {
+		// We don't support setting the object metadata in the provider spec.
+		// It's only present for the purpose of the raw extension and doesn't have any functionality.
+		errs = append(errs, field.Invalid(fldPath.Child("metadata"), providerSpec.ObjectMeta, "metadata is not supported"))
+	}
+
+	return &capov1.OpenStackMachine{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: capov1.GroupVersion.String(),
+			Kind:       openstackMachineKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.machine.Name,
+			Namespace: capiNamespace,
+		},
+		Spec: spec,
+	}, warnings, errs
+}
+
+// openstackProviderSpecFromRawExtension unmarshals a raw extension into an OpenstackProviderSpec type.
+func openstackProviderSpecFromRawExtension(rawExtension *runtime.RawExtension) (mapiv1alpha1.OpenstackProviderSpec, error) {
+	if rawExtension == nil {
+		return mapiv1alpha1.OpenstackProviderSpec{}, nil
+	}
+
+	spec := mapiv1alpha1.OpenstackProviderSpec{}
+	if err := yaml.Unmarshal(rawExtension.Raw, &spec); err != nil {
+		return mapiv1alpha1.OpenstackProviderSpec{}, fmt.Errorf("error unmarshalling providerSpec: %w", err)
+	}
+
+	return spec, nil
+}
+
+func openstackMachineToOpenStackMachineTemplate(openstackMachine *capov1.OpenStackMachine, name string, namespace string) *capov1.OpenStackMachineTemplate {
+	return &capov1.OpenStackMachineTemplate{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: capov1.GroupVersion.String(),
+			Kind:       openstackMachineTemplateKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: capov1.OpenStackMachineTemplateSpec{
+			Template: capov1.OpenStackMachineTemplateResource{
+				Spec: openstackMachine.Spec,
+			},
+		},
+	}
+}
+
+//////// Conversion helpers
+
+// convertOpenStackImageToCAPI converts the MAPI image to a CAPO image filter.
+// When booting from a root volume, MAPO ignores the image and creates the volume from the root volume source instead.
+func convertOpenStackImageToCAPI(fldPath *field.Path, providerSpec mapiv1alpha1.OpenstackProviderSpec) (capov1.ImageParam, []string, field.ErrorList) {
+	var (
+		errs     field.ErrorList
+		warnings []string
+	)
+
+	image := providerSpec.Image
+
+	if providerSpec.RootVolume != nil && providerSpec.RootVolume.SourceUUID != "" {
+		if image != "" && image != providerSpec.RootVolume.SourceUUID {
+			warnings = append(warnings, field.Invalid(fldPath.Child("image"), image, "image is ignored when rootVolume.sourceUUID is set").Error())
+		}
+
+		image = providerSpec.RootVolume.SourceUUID
+	}
+
+	if image == "" {
+		errs = append(errs, field.Required(fldPath.Child("image"), "image or rootVolume.sourceUUID is required"))
+	}
+
+	return capov1.ImageParam{
+		Filter: &capov1.ImageFilter{
+			Name: ptr.To(image),
+		},
+	}, warnings, errs
+}
+
+// convertOpenStackNetworksToCAPI converts the MAPI networks to CAPO ports.
+// Like MAPO, a port is created for each subnet of a network, or a single port when the network has no subnets.
+func convertOpenStackNetworksToCAPI(fldPath *field.Path, mapiNetworks []mapiv1alpha1.NetworkParam) ([]capov1.PortOpts, field.ErrorList) {
+	var (
+		capoPorts []capov1.PortOpts
+		errs      field.ErrorList
+	)
+
+	for i, network := range mapiNetworks {
+		networkPath := fldPath.Index(i)
+
+		networkParam, networkErrs := convertOpenStackNetworkParamToCAPI(networkPath, network)
+		errs = append(errs, networkErrs...)
+
+		if network.FixedIp != "" {
+			errs = append(errs, field.Invalid(networkPath.Child("fixedIp"), network.FixedIp, "fixedIp is not supported"))
+		}
+
+		if network.NoAllowedAddressPairs {
+			errs = append(errs, field.Invalid(networkPath.Child("noAllowedAddressPairs"), network.NoAllowedAddressPairs, "noAllowedAddressPairs is not supported"))
+		}
+
+		if len(network.Profile) > 0 {
+			errs = append(errs, field.Invalid(networkPath.Child("profile"), network.Profile, "profile is not yet supported"))
+		}
+
+		port := capov1.PortOpts{
+			Network: networkParam,
+			Tags:    convertOpenStackTagsToCAPI(network.PortTags),
+			ResolvedPortSpecFields: capov1.ResolvedPortSpecFields{
+				VNICType:            optionalString(network.VNICType),
+				DisablePortSecurity: convertOpenStackPortSecurityToCAPI(network.PortSecurity),
+			},
+		}
+
+		if len(network.Subnets) == 0 {
+			capoPorts = append(capoPorts, port)
+			continue
+		}
+
+		for j, subnet := range network.Subnets {
+			subnetParam, subnetErrs := convertOpenStackSubnetParamToCAPI(networkPath.Child("subnets").Index(j), subnet)
+			errs = append(errs, subnetErrs...)
+
+			subnetPort := *port.DeepCopy()
+			subnetPort.FixedIPs = []capov1.FixedIP{{Subnet: subnetParam}}
+
+			if len(subnet.PortTags) > 0 {
+				subnetPort.Tags = append(subnetPort.Tags, subnet.PortTags...)
+			}
+
+			if subnet.PortSecurity != nil {
+				subnetPort.DisablePortSecurity = convertOpenStackPortSecurityToCAPI(subnet.PortSecurity)
+			}
+
+			capoPorts = append(capoPorts, subnetPort)
+		}
+	}
+
+	return capoPorts, errs
+}
+
+// convertOpenStackNetworkParamToCAPI converts a MAPI network to a CAPO network param.
+// CAPO accepts either an ID or a filter, but not both.
+func convertOpenStackNetworkParamToCAPI(fldPath *field.Path, network mapiv1alpha1.NetworkParam) (*capov1.NetworkParam, field.ErrorList) {
+	id := network.UUID
+	if id == "" {
+		id = network.Filter.ID
+	}
+
+	filter := &capov1.NetworkFilter{
+		Name:                network.Filter.Name,
+		Description:         network.Filter.Description,
+		ProjectID:           openstackProjectID(network.Filter.ProjectID, network.Filter.TenantID),
+		FilterByNeutronTags: convertOpenStackNeutronTagsToCAPI(network.Filter.Tags, network.Filter.TagsAny, network.Filter.NotTags, network.Filter.NotTagsAny),
+	}
+
+	// The deprecated filter fields are ignored by MAPO.
+
+	switch {
+	case id != "" && !filter.IsZero():
+		return nil, field.ErrorList{field.Invalid(fldPath.Child("filter"), network.Filter, "filter cannot be combined with a network uuid, Cluster API only supports one of them")}
+	case id != "":
+		return &capov1.NetworkParam{ID: ptr.To(id)}, nil
+	case !filter.IsZero():
+		return &capov1.NetworkParam{Filter: filter}, nil
+	default:
+		// CAPO infers the network from the subnets when no network is given.
+		return nil, nil
+	}
+}
+
+// convertOpenStackSubnetParamToCAPI converts a MAPI subnet to a CAPO subnet param.
+// CAPO accepts either an ID or a filter, but not both.
+func convertOpenStackSubnetParamToCAPI(fldPath *field.Path, subnet mapiv1alpha1.SubnetParam) (*capov1.SubnetParam, field.ErrorList) {
+	var errs field.ErrorList
+
+	id := subnet.UUID
+	if id == "" {
+		id = subnet.Filter.ID
+	}
+
+	if subnet.Filter.NetworkID != "" {
+		errs = append(errs, field.Invalid(fldPath.Child("filter", "networkId"), subnet.Filter.NetworkID, "networkId is not supported"))
+	}
+
+	if subnet.Filter.SubnetPoolID != "" {
+		errs = append(errs, field.Invalid(fldPath.Child("filter", "subnetpoolId"), subnet.Filter.SubnetPoolID, "subnetpoolId is not supported"))
+	}
+
+	filter := &capov1.SubnetFilter{
+		Name:                subnet.Filter.Name,
+		Description:         subnet.Filter.Description,
+		ProjectID:           openstackProjectID(subnet.Filter.ProjectID, subnet.Filter.TenantID),
+		IPVersion:           subnet.Filter.IPVersion,
+		GatewayIP:           subnet.Filter.GatewayIP,
+		CIDR:                subnet.Filter.CIDR,
+		IPv6AddressMode:     subnet.Filter.IPv6AddressMode,
+		IPv6RAMode:          subnet.Filter.IPv6RAMode,
+		FilterByNeutronTags: convertOpenStackNeutronTagsToCAPI(subnet.Filter.Tags, subnet.Filter.TagsAny, subnet.Filter.NotTags, subnet.Filter.NotTagsAny),
+	}
+
+	// The deprecated filter fields are ignored by MAPO.
+
+	switch {
+	case id != "" && !filter.IsZero():
+		return nil, append(errs, field.Invalid(fldPath.Child("filter"), subnet.Filter, "filter cannot be combined with a subnet uuid, Cluster API only supports one of them"))
+	case id != "":
+		return &capov1.SubnetParam{ID: ptr.To(id)}, errs
+	case !filter.IsZero():
+		return &capov1.SubnetParam{Filter: filter}, errs
+	default:
+		return nil, errs
+	}
+}
+
+// convertOpenStackPortsToCAPI converts the MAPI additional ports to CAPO ports.
+func convertOpenStackPortsToCAPI(fldPath *field.Path, mapiPorts []mapiv1alpha1.PortOpts) ([]capov1.PortOpts, field.ErrorList) {
+	var (
+		capoPorts []capov1.PortOpts
+		errs      field.ErrorList
+	)
+
+	for i, port := range mapiPorts {
+		portPath := fldPath.Index(i)
+
+		if port.TenantID != "" {
+			errs = append(errs, field.Invalid(portPath.Child("tenantID"), port.TenantID, "tenantID is not supported"))
+		}
+
+		if port.ProjectID != "" {
+			errs = append(errs, field.Invalid(portPath.Child("projectID"), port.ProjectID, "projectID is not supported"))
+		}
+
+		profile, profileErrs := convertOpenStackPortProfileToCAPI(portPath.Child("profile"), port.Profile)
+		errs = append(errs, profileErrs...)
+
+		capoPort := capov1.PortOpts{
+			Description:    optionalString(port.Description),
+			NameSuffix:     optionalString(port.NameSuffix),
+			FixedIPs:       convertOpenStackFixedIPsToCAPI(port.FixedIPs),
+			SecurityGroups: convertOpenStackPortSecurityGroupsToCAPI(port.SecurityGroups),
+			Tags:           convertOpenStackTagsToCAPI(port.Tags),
+			Trunk:          port.Trunk,
+			ResolvedPortSpecFields: capov1.ResolvedPortSpecFields{
+				AdminStateUp:        port.AdminStateUp,
+				MACAddress:          optionalString(port.MACAddress),
+				AllowedAddressPairs: convertOpenStackAddressPairsToCAPI(port.AllowedAddressPairs),
+				HostID:              optionalString(port.DeprecatedHostID),
+				VNICType:            optionalString(port.VNICType),
+				Profile:             profile,
+				DisablePortSecurity: convertOpenStackPortSecurityToCAPI(port.PortSecurity),
+			},
+		}
+
+		if port.NetworkID != "" {
+			capoPort.Network = &capov1.NetworkParam{ID: ptr.To(port.NetworkID)}
+		}
+
+		capoPorts = append(capoPorts, capoPort)
+	}
+
+	return capoPorts, errs
+}
+
+// convertOpenStackPortProfileToCAPI converts the MAPI port binding profile to a CAPO binding profile.
+// CAPO only exposes the binding profile keys known to work with Neutron: the switchdev capability
+// used for OVS hardware offload and the trusted mode of SR-IOV virtual functions.
+func convertOpenStackPortProfileToCAPI(fldPath *field.Path, mapiProfile map[string]string) (*capov1.BindingProfile, field.ErrorList) {
+	if len(mapiProfile) == 0 {
+		return nil, nil
+	}
+
+	var (
+		capoProfile = &capov1.BindingProfile{}
+		errs        field.ErrorList
+	)
+
+	// The keys are sorted so that the errors are stable.
+	keys := make([]string, 0, len(mapiProfile))
+	for key := range mapiProfile {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := mapiProfile[key]
+
+		switch key {
+		case openstackPortProfileCapabilitiesKey:
+			if value != openstackPortProfileSwitchdevCapabilities {
+				errs = append(errs, field.Invalid(fldPath.Key(key), value, fmt.Sprintf("only the %s capabilities are supported", openstackPortProfileSwitchdevCapabilities)))
+				continue
+			}
+
+			capoProfile.OVSHWOffload = ptr.To(true)
+		case openstackPortProfileTrustedKey:
+			trusted, err := strconv.ParseBool(value)
+			if err != nil {
+				errs = append(errs, field.Invalid(fldPath.Key(key), value, "trusted must be either true or false"))
+				continue
+			}
+
+			capoProfile.TrustedVF = ptr.To(trusted)
+		default:
+			errs = append(errs, field.Invalid(fldPath.Key(key), value, fmt.Sprintf("only the %s and %s keys are supported", openstackPortProfileCapabilitiesKey, openstackPortProfileTrustedKey)))
+		}
+	}
+
+	return capoProfile, errs
+}
+
+func convertOpenStackFixedIPsToCAPI(mapiFixedIPs []mapiv1alpha1.FixedIPs) []capov1.FixedIP {
+	var capoFixedIPs []capov1.FixedIP
+
+	for _, fixedIP := range mapiFixedIPs {
+		capoFixedIP := capov1.FixedIP{
+			IPAddress: optionalString(fixedIP.IPAddress),
+		}
+
+		if fixedIP.SubnetID != "" {
+			capoFixedIP.Subnet = &capov1.SubnetParam{ID: ptr.To(fixedIP.SubnetID)}
+		}
+
+		capoFixedIPs = append(capoFixedIPs, capoFixedIP)
+	}
+
+	return capoFixedIPs
+}
+
+// convertOpenStackPortSecurityGroupsToCAPI converts the MAPI port security group IDs to CAPO security group params.
+// An explicitly empty list has no CAPO equivalent, in both cases the port uses the machine security groups.
+func convertOpenStackPortSecurityGroupsToCAPI(mapiSecurityGroups *[]string) []capov1.SecurityGroupParam {
+	if mapiSecurityGroups == nil {
+		return nil
+	}
+
+	var capoSecurityGroups []capov1.SecurityGroupParam
+
+	for _, id := range *mapiSecurityGroups {
+		capoSecurityGroups = append(capoSecurityGroups, capov1.SecurityGroupParam{ID: ptr.To(id)})
+	}
+
+	return capoSecurityGroups
+}
+
+func convertOpenStackAddressPairsToCAPI(mapiAddressPairs []mapiv1alpha1.AddressPair) []capov1.AddressPair {
+	var capoAddressPairs []capov1.AddressPair
+
+	for _, addressPair := range mapiAddressPairs {
+		capoAddressPairs = append(capoAddressPairs, capov1.AddressPair{
+			IPAddress:  addressPair.IPAddress,
+			MACAddress: optionalString(addressPair.MACAddress),
+		})
+	}
+
+	return capoAddressPairs
+}
+
+// convertOpenStackPortSecurityToCAPI inverts the MAPI port security flag, CAPO instead tracks whether it is disabled.
+func convertOpenStackPortSecurityToCAPI(portSecurity *bool) *bool {
+	if portSecurity == nil {
+		return nil
+	}
+
+	return ptr.To(!*portSecurity)
+}
+
+// validateOpenStackPrimarySubnet checks that the primary subnet is the subnet of the first port.
+// CAPO always uses the address of the first port as the primary address of the machine.
+func validateOpenStackPrimarySubnet(fldPath *field.Path, primarySubnet string, ports []capov1.PortOpts) field.ErrorList {
+	if primarySubnet == "" {
+		return nil
+	}
+
+	if len(ports) > 0 && len(ports[0].FixedIPs) > 0 && ports[0].FixedIPs[0].Subnet != nil && ptr.Deref(ports[0].FixedIPs[0].Subnet.ID, "") == primarySubnet {
+		return nil
+	}
+
+	return field.ErrorList{field.Invalid(fldPath, primarySubnet, "primarySubnet must be the subnet of the first port, Cluster API uses the first port as the primary address")}
+}
+
+// convertOpenStackSecurityGroupsToCAPI converts the MAPI security groups to CAPO security group params.
+// CAPO accepts either an ID or a filter, but not both.
+func convertOpenStackSecurityGroupsToCAPI(fldPath *field.Path, mapiSecurityGroups []mapiv1alpha1.SecurityGroupParam) ([]capov1.SecurityGroupParam, field.ErrorList) {
+	var (
+		capoSecurityGroups []capov1.SecurityGroupParam
+		errs               field.ErrorList
+	)
+
+	for i, securityGroup := range mapiSecurityGroups {
+		id := securityGroup.UUID
+		if id == "" {
+			id = securityGroup.Filter.ID
+		}
+
+		name := securityGroup.Name
+		if name == "" {
+			name = securityGroup.Filter.Name
+		}
+
+		filter := &capov1.SecurityGroupFilter{
+			Name:                name,
+			Description:         securityGroup.Filter.Description,
+			ProjectID:           openstackProjectID(securityGroup.Filter.ProjectID, securityGroup.Filter.TenantID),
+			FilterByNeutronTags: convertOpenStackNeutronTagsToCAPI(securityGroup.Filter.Tags, securityGroup.Filter.TagsAny, securityGroup.Filter.NotTags, securityGroup.Filter.NotTagsAny),
+		}
+
+		// The deprecated filter fields are ignored by MAPO.
+
+		capoSecurityGroup := capov1.SecurityGroupParam{}
+
+		switch {
+		case id != "" && !filter.IsZero():
+			errs = append(errs, field.Invalid(fldPath.Index(i), securityGroup, "name and filter cannot be combined with a security group uuid, Cluster API only supports one of them"))
+		case id != "":
+			capoSecurityGroup.ID = ptr.To(id)
+		case !filter.IsZero():
+			capoSecurityGroup.Filter = filter
+		}
+
+		capoSecurityGroups = append(capoSecurityGroups, capoSecurityGroup)
+	}
+
+	return capoSecurityGroups, errs
+}
+
+// convertOpenStackNeutronTagsToCAPI converts the MAPI comma separated tag filters to CAPO neutron tag lists.
+func convertOpenStackNeutronTagsToCAPI(tags, tagsAny, notTags, notTagsAny string) capov1.FilterByNeutronTags {
+	splitTags := func(tags string) []capov1.NeutronTag {
+		if tags == "" {
+			return nil
+		}
+
+		var neutronTags []capov1.NeutronTag
+		for _, tag := range strings.Split(tags, ",") {
+			neutronTags = append(neutronTags, capov1.NeutronTag(tag))
+		}
+
+		return neutronTags
+	}
+
+	return capov1.FilterByNeutronTags{
+		Tags:       splitTags(tags),
+		TagsAny:    splitTags(tagsAny),
+		NotTags:    splitTags(notTags),
+		NotTagsAny: splitTags(notTagsAny),
+	}
+}
+
+// openstackProjectID returns the project ID, falling back to the legacy tenant ID.
+func openstackProjectID(projectID, tenantID string) string {
+	if projectID != "" {
+		return projectID
+	}
+
+	return tenantID
+}
+
+func convertOpenStackTagsToCAPI(mapiTags []string) []string {
+	if len(mapiTags) == 0 {
+		return nil
+	}
+
+	return append([]string{}, mapiTags...)
+}
+
+// convertOpenStackServerMetadataToCAPI converts the MAPI server metadata map to a CAPO server metadata list.
+// The list is sorted by key so that the conversion is stable.
+func convertOpenStackServerMetadataToCAPI(mapiServerMetadata map[string]string) []capov1.ServerMetadata {
+	var capoServerMetadata []capov1.ServerMetadata
+
+	for key, value := range mapiServerMetadata {
+		capoServerMetadata = append(capoServerMetadata, capov1.ServerMetadata{Key: key, Value: value})
+	}
+
+	sort.Slice(capoServerMetadata, func(i, j int) bool {
+		return capoServerMetadata[i].Key < capoServerMetadata[j].Key
+	})
+
+	return capoServerMetadata
+}
+
+// convertOpenStackRootVolumeToCAPI converts the MAPI root volume to a CAPO root volume.
+// The source of the root volume is converted as the image and the deprecated fields are ignored by MAPO.
+func convertOpenStackRootVolumeToCAPI(mapiRootVolume *mapiv1alpha1.RootVolume) *capov1.RootVolume {
+	if mapiRootVolume == nil {
+		return nil
+	}
+
+	return &capov1.RootVolume{
+		SizeGiB: mapiRootVolume.Size,
+		BlockDeviceVolume: capov1.BlockDeviceVolume{
+			Type:             mapiRootVolume.VolumeType,
+			AvailabilityZone: convertOpenStackVolumeAvailabilityZoneToCAPI(mapiRootVolume.Zone),
+		},
+	}
+}
+
+func convertOpenStackAdditionalBlockDevicesToCAPI(mapiBlockDevices []mapiv1alpha1.AdditionalBlockDevice) []capov1.AdditionalBlockDevice {
+	var capoBlockDevices []capov1.AdditionalBlockDevice
+
+	for _, blockDevice := range mapiBlockDevices {
+		capoBlockDevice := capov1.AdditionalBlockDevice{
+			Name:    blockDevice.Name,
+			SizeGiB: blockDevice.SizeGiB,
+			Storage: capov1.BlockDeviceStorage{
+				Type: capov1.BlockDeviceType(blockDevice.Storage.Type),
+			},
+		}
+
+		if blockDevice.Storage.Volume != nil {
+			capoBlockDevice.Storage.Volume = &capov1.BlockDeviceVolume{
+				Type:             blockDevice.Storage.Volume.Type,
+				AvailabilityZone: convertOpenStackVolumeAvailabilityZoneToCAPI(blockDevice.Storage.Volume.AvailabilityZone),
+			}
+		}
+
+		capoBlockDevices = append(capoBlockDevices, capoBlockDevice)
+	}
+
+	return capoBlockDevices
+}
+
+func convertOpenStackVolumeAvailabilityZoneToCAPI(zone string) *capov1.VolumeAvailabilityZone {
+	if zone == "" {
+		return nil
+	}
+
+	return &capov1.VolumeAvailabilityZone{
+		From: capov1.VolumeAZFromName,
+		Name: ptr.To(capov1.VolumeAZName(zone)),
+	}
+}
+
+// convertOpenStackServerGroupToCAPI converts the MAPI server group to a CAPO server group param.
+// MAPO requires the ID and name to refer to the same server group, so the ID is preferred when both are set.
+func convertOpenStackServerGroupToCAPI(id, name string) *capov1.ServerGroupParam {
+	switch {
+	case id != "":
+		return &capov1.ServerGroupParam{ID: ptr.To(id)}
+	case name != "":
+		return &capov1.ServerGroupParam{Filter: &capov1.ServerGroupFilter{Name: ptr.To(name)}}
+	default:
+		return nil
+	}
+}
+
+// optionalString returns nil for an empty string, matching how CAPO represents unset optional strings.
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+
+	return ptr.To(s)
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mapi2capi_test
+
+import (
+	"strconv"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	fuzz "github.com/google/gofuzz"
+
+	configv1 "github.com/openshift/api/config/v1"
+	mapiv1alpha1 "github.com/openshift/api/machine/v1alpha1"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/capi2mapi"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/mapi2capi"
+	conversiontest "github.com/openshift/cluster-capi-operator/pkg/conversion/test/fuzz"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeserializer "k8s.io/apimachinery/pkg/runtime/serializer"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	capov1 "sigs.k8s.io/cluster-api-provider-openstack/api/v1beta1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+var _ = Describe("OpenStack Fuzz (mapi2capi)", func() {
+	infra := &configv1.Infrastructure{
+		Spec: configv1.InfrastructureSpec{},
+		Status: configv1.InfrastructureStatus{
+			InfrastructureName: "sample-cluster-name",
+			PlatformStatus: &configv1.PlatformStatus{
+				Type: configv1.OpenStackPlatformType,
+			},
+		},
+	}
+
+	infraCluster := &capov1.OpenStackCluster{}
+
+	Context("OpenStackMachine Conversion", func() {
+		fromMachineAndOpenStackMachineAndOpenStackCluster := func(machine *capiv1.Machine, infraMachine client.Object, infraCluster client.Object) capi2mapi.MachineAndInfrastructureMachine {
+			openstackMachine, ok := infraMachine.(*capov1.OpenStackMachine)
+			Expect(ok).To(BeTrue(), "input infra machine should be of type %T, got %T", &capov1.OpenStackMachine{}, infraMachine)
+
+			openstackCluster, ok := infraCluster.(*capov1.OpenStackCluster)
+			Expect(ok).To(BeTrue(), "input infra cluster should be of type %T, got %T", &capov1.OpenStackCluster{}, infraCluster)
+
+			return capi2mapi.FromMachineAndOpenStackMachineAndOpenStackCluster(machine, openstackMachine, openstackCluster)
+		}
+
+		conversiontest.MAPI2CAPIMachineRoundTripFuzzTest(
+			scheme,
+			infra,
+			infraCluster,
+			mapi2capi.FromOpenStackMachineAndInfra,
+			fromMachineAndOpenStackMachineAndOpenStackCluster,
+			conversiontest.ObjectMetaFuzzerFuncs(mapiNamespace),
+			conversiontest.MAPIMachineFuzzerFuncs(&mapiv1alpha1.OpenstackProviderSpec{}, openstackProviderIDFuzzer),
+			openstackProviderSpecFuzzerFuncs,
+		)
+	})
+
+	Context("OpenStackMachineSet Conversion", func() {
+		fromMachineSetAndOpenStackMachineTemplateAndOpenStackCluster := func(machineSet *capiv1.MachineSet, infraMachineTemplate client.Object, infraCluster client.Object) capi2mapi.MachineSetAndMachineTemplate {
+			openstackMachineTemplate, ok := infraMachineTemplate.(*capov1.OpenStackMachineTemplate)
+			Expect(ok).To(BeTrue(), "input infra machine template should be of type %T, got %T", &capov1.OpenStackMachineTemplate{}, infraMachineTemplate)
+
+			openstackCluster, ok := infraCluster.(*capov1.OpenStackCluster)
+			Expect(ok).To(BeTrue(), "input infra cluster should be of type %T, got %T", &capov1.OpenStackCluster{}, infraCluster)
+
+			return capi2mapi.FromMachineSetAndOpenStackMachineTemplateAndOpenStackCluster(machineSet, openstackMachineTemplate, openstackCluster)
+		}
+
+		conversiontest.MAPI2CAPIMachineSetRoundTripFuzzTest(
+			scheme,
+			infra,
+			infraCluster,
+			mapi2capi.FromOpenStackMachineSetAndInfra,
+			fromMachineSetAndOpenStackMachineTemplateAndOpenStackCluster,
+			conversiontest.ObjectMetaFuzzerFuncs(mapiNamespace),
+			conversiontest.MAPIMachineFuzzerFuncs(&mapiv1alpha1.OpenstackProviderSpec{}, openstackProviderIDFuzzer),
+			conversiontest.MAPIMachineSetFuzzerFuncs(),
+			openstackProviderSpecFuzzerFuncs,
+		)
+	})
+})
+
+func openstackProviderIDFuzzer(c fuzz.Continue) string {
+	return "openstack:///" + strings.ReplaceAll(c.RandString(), "/", "")
+}
+
+func openstackProviderSpecFuzzerFuncs(codecs runtimeserializer.CodecFactory) []interface{} {
+	return []interface{}{
+		func(sg *mapiv1alpha1.SecurityGroupParam, c fuzz.Continue) {
+			c.FuzzNoCustom(sg)
+
+			// The ID, name and project are each converted from whichever of the two MAPI fields is set.
+			if sg.UUID == "" {
+				sg.UUID = sg.Filter.ID
+			}
+
+			if sg.Name == "" {
+				sg.Name = sg.Filter.Name
+			}
+
+			if sg.Filter.ProjectID == "" {
+				sg.Filter.ProjectID = sg.Filter.TenantID
+			}
+
+			sg.Filter.ID = ""
+			sg.Filter.Name = ""
+			sg.Filter.TenantID = ""
+
+			// CAPO only supports referencing a security group by either ID or filter.
+			if sg.UUID != "" {
+				sg.Name = ""
+				sg.Filter = mapiv1alpha1.SecurityGroupFilter{}
+			}
+
+			// Clear fields that are deprecated and ignored by MAPO.
+			sg.Filter.DeprecatedLimit = 0
+			sg.Filter.DeprecatedMarker = ""
+			sg.Filter.DeprecatedSortKey = ""
+			sg.Filter.DeprecatedSortDir = ""
+		},
+		func(port *mapiv1alpha1.PortOpts, c fuzz.Continue) {
+			c.FuzzNoCustom(port)
+
+			// An empty list of security groups is the same as not setting them in CAPO.
+			if port.SecurityGroups != nil && len(*port.SecurityGroups) == 0 {
+				port.SecurityGroups = nil
+			}
+
+			// CAPO only supports the switchdev capability and the trusted mode in the binding profile.
+			port.Profile = nil
+			if c.RandBool() {
+				port.Profile = map[string]string{"capabilities": `["switchdev"]`}
+			}
+
+			if c.RandBool() {
+				if port.Profile == nil {
+					port.Profile = map[string]string{}
+				}
+
+				port.Profile["trusted"] = strconv.FormatBool(c.RandBool())
+			}
+
+			// Clear fields that are not supported.
+			port.TenantID = ""
+			port.ProjectID = ""
+		},
+		func(rv *mapiv1alpha1.RootVolume, c fuzz.Continue) {
+			c.FuzzNoCustom(rv)
+
+			// Clear fields that are deprecated and ignored by MAPO.
+			rv.DeprecatedSourceType = ""
+			rv.DeprecatedDeviceType = ""
+		},
+		func(ps *mapiv1alpha1.OpenstackProviderSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(ps)
+
+			// The type meta is always set to these values by the conversion.
+			ps.Kind = "OpenstackProviderSpec"
+			ps.APIVersion = "machine.openshift.io/v1alpha1"
+
+			if ps.Flavor == "" {
+				ps.Flavor = "sample-flavor"
+			}
+
+			// When booting from a root volume the image is converted from and back to the root volume source.
+			if ps.RootVolume != nil {
+				if ps.RootVolume.SourceUUID == "" {
+					ps.RootVolume.SourceUUID = ps.Image
+				}
+
+				if ps.RootVolume.SourceUUID == "" {
+					ps.RootVolume.SourceUUID = "sample-image"
+				}
+
+				ps.Image = ""
+			} else if ps.Image == "" {
+				ps.Image = "sample-image"
+			}
+
+			// The server group ID is preferred over the name when both are set.
+			if ps.ServerGroupID != "" {
+				ps.ServerGroupName = ""
+			}
+
+			// Networks are converted to CAPO ports and so come back as MAPI ports.
+			ps.Networks = nil
+			ps.PrimarySubnet = ""
+
+			// Clear fields that are not supported in the provider spec.
+			ps.ObjectMeta = metav1.ObjectMeta{}
+			ps.FloatingIP = ""
+
+			if ps.UserDataSecret != nil && ps.UserDataSecret.Name == "" {
+				ps.UserDataSecret = nil
+			} else if ps.UserDataSecret != nil {
+				ps.UserDataSecret = &corev1.SecretReference{Name: ps.UserDataSecret.Name}
+			}
+		},
+	}
+}
@@ -0,0 +1,351 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mapi2capi
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	configv1 "github.com/openshift/api/config/v1"
+	mapiv1alpha1 "github.com/openshift/api/machine/v1alpha1"
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+	machinebuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/machine/v1beta1"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/test/matchers"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	capov1 "sigs.k8s.io/cluster-api-provider-openstack/api/v1beta1"
+)
+
+var _ = Describe("mapi2capi OpenStack conversion", func() {
+	var (
+		openstackBaseProviderSpec   = machinebuilder.OpenStackProviderSpec()
+		openstackMAPIMachineSetBase = machinebuilder.MachineSet().WithProviderSpecBuilder(openstackBaseProviderSpec)
+
+		infra = &configv1.Infrastructure{
+			Spec: configv1.InfrastructureSpec{},
+			Status: configv1.InfrastructureStatus{
+				InfrastructureName: "sample-cluster-name",
+				PlatformStatus: &configv1.PlatformStatus{
+					Type: configv1.OpenStackPlatformType,
+				},
+			},
+		}
+	)
+
+	var openstackProviderSpec = func(modify func(*mapiv1alpha1.OpenstackProviderSpec)) mapiv1.ProviderSpec {
+		providerSpec := openstackBaseProviderSpec.Build()
+
+		if modify != nil {
+			modify(providerSpec)
+		}
+
+		rawBytes, err := json.Marshal(providerSpec)
+		if err != nil {
+			panic(fmt.Sprintf("unable to convert (marshal) test OpenstackProviderSpec to runtime.RawExtension: %v", err))
+		}
+
+		return mapiv1.ProviderSpec{
+			Value: &runtime.RawExtension{Raw: rawBytes},
+		}
+	}
+
+	var openstackMAPIMachine = func(modify func(*mapiv1alpha1.OpenstackProviderSpec)) *mapiv1.Machine {
+		return machinebuilder.Machine().WithProviderSpec(openstackProviderSpec(modify)).Build()
+	}
+
+	type openstackMAPI2CAPIConversionInput struct {
+		machine          *mapiv1.Machine
+		infra            *configv1.Infrastructure
+		expectedErrors   []string
+		expectedWarnings []string
+	}
+
+	var _ = DescribeTable("mapi2capi OpenStack convert MAPI Machine",
+		func(in openstackMAPI2CAPIConversionInput) {
+			_, _, warns, err := FromOpenStackMachineAndInfra(in.machine, in.infra).ToMachineAndInfrastructureMachine()
+			Expect(err).To(matchers.ConsistOfMatchErrorSubstrings(in.expectedErrors), "should match expected errors while converting an OpenStack MAPI Machine to CAPI")
+			Expect(warns).To(matchers.ConsistOfSubstrings(in.expectedWarnings), "should match expected warnings while converting an OpenStack MAPI Machine to CAPI")
+		},
+
+		// Base Case.
+		Entry("With a Base configuration", openstackMAPI2CAPIConversionInput{
+			machine:          openstackMAPIMachine(nil),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With an availability zone", openstackMAPI2CAPIConversionInput{
+			machine:          machinebuilder.Machine().WithProviderSpecBuilder(openstackBaseProviderSpec.WithZone("test-zone")).Build(),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a root volume", openstackMAPI2CAPIConversionInput{
+			machine: openstackMAPIMachine(func(ps *mapiv1alpha1.OpenstackProviderSpec) {
+				ps.Image = ""
+				ps.RootVolume = &mapiv1alpha1.RootVolume{SourceUUID: "rhcos", VolumeType: "fast", Size: 50, Zone: "test-volume-zone"}
+			}),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a root volume and a different image", openstackMAPI2CAPIConversionInput{
+			machine: openstackMAPIMachine(func(ps *mapiv1alpha1.OpenstackProviderSpec) {
+				ps.RootVolume = &mapiv1alpha1.RootVolume{SourceUUID: "rhcos-volume", Size: 50}
+			}),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{"spec.providerSpec.value.image: Invalid value: \"rhcos\": image is ignored when rootVolume.sourceUUID is set"},
+		}),
+
+		Entry("With additional block devices", openstackMAPI2CAPIConversionInput{
+			machine: machinebuilder.Machine().WithProviderSpecBuilder(openstackBaseProviderSpec.WithAdditionalBlockDevices([]mapiv1alpha1.AdditionalBlockDevice{{
+				Name:    "etcd",
+				SizeGiB: 10,
+				Storage: mapiv1alpha1.BlockDeviceStorage{
+					Type:   mapiv1alpha1.VolumeBlockDevice,
+					Volume: &mapiv1alpha1.BlockDeviceVolume{Type: "fast", AvailabilityZone: "test-volume-zone"},
+				},
+			}})).Build(),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With additional ports", openstackMAPI2CAPIConversionInput{
+			machine: openstackMAPIMachine(func(ps *mapiv1alpha1.OpenstackProviderSpec) {
+				ps.Ports = []mapiv1alpha1.PortOpts{{
+					NetworkID:      "0b6d6f4b-3a9b-4d48-a3a0-0b4ab5e4b0e4",
+					NameSuffix:     "storage",
+					FixedIPs:       []mapiv1alpha1.FixedIPs{{SubnetID: "5e1d1a8c-4d63-47b4-b1ef-2d2d2f4c7c4f"}},
+					SecurityGroups: &[]string{"a3b2c1d0-1111-2222-3333-444455556666"},
+					VNICType:       "direct",
+					PortSecurity:   ptr.To(false),
+				}}
+			}),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("Without a flavor", openstackMAPI2CAPIConversionInput{
+			machine:          machinebuilder.Machine().WithProviderSpecBuilder(openstackBaseProviderSpec.WithFlavor("")).Build(),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.flavor: Required value: flavor is required"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("Without an image", openstackMAPI2CAPIConversionInput{
+			machine: openstackMAPIMachine(func(ps *mapiv1alpha1.OpenstackProviderSpec) {
+				ps.Image = ""
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.image: Required value: image or rootVolume.sourceUUID is required"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a primary subnet that is not the first port subnet", openstackMAPI2CAPIConversionInput{
+			machine: openstackMAPIMachine(func(ps *mapiv1alpha1.OpenstackProviderSpec) {
+				ps.PrimarySubnet = "5e1d1a8c-4d63-47b4-b1ef-2d2d2f4c7c4f"
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.primarySubnet: Invalid value: \"5e1d1a8c-4d63-47b4-b1ef-2d2d2f4c7c4f\": primarySubnet must be the subnet of the first port, Cluster API uses the first port as the primary address"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a network uuid and filter", openstackMAPI2CAPIConversionInput{
+			machine: openstackMAPIMachine(func(ps *mapiv1alpha1.OpenstackProviderSpec) {
+				ps.Networks[0].Filter.Name = "test-network"
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.networks[0].filter: Invalid value: v1alpha1.Filter{ID:\"\", Name:\"test-network\""},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With unsupported fields", openstackMAPI2CAPIConversionInput{
+			machine: openstackMAPIMachine(func(ps *mapiv1alpha1.OpenstackProviderSpec) {
+				ps.FloatingIP = "10.0.0.10"
+				ps.Networks[0].FixedIp = "10.0.0.11"
+				ps.Ports = []mapiv1alpha1.PortOpts{{
+					NetworkID: "0b6d6f4b-3a9b-4d48-a3a0-0b4ab5e4b0e4",
+					ProjectID: "test-project",
+					Profile:   map[string]string{"capabilities": `["switchdev", "direct"]`, "trusted": "yes", "physical_network": "physnet1"},
+				}}
+			}),
+			infra: infra,
+			expectedErrors: []string{
+				"spec.providerSpec.value.floatingIP: Invalid value: \"10.0.0.10\": floatingIP is not supported",
+				"spec.providerSpec.value.networks[0].fixedIp: Invalid value: \"10.0.0.11\": fixedIp is not supported",
+				"spec.providerSpec.value.ports[0].projectID: Invalid value: \"test-project\": projectID is not supported",
+				"spec.providerSpec.value.ports[0].profile[capabilities]: Invalid value: \"[\\\"switchdev\\\", \\\"direct\\\"]\": only the [\"switchdev\"] capabilities are supported",
+				"spec.providerSpec.value.ports[0].profile[physical_network]: Invalid value: \"physnet1\": only the capabilities and trusted keys are supported",
+				"spec.providerSpec.value.ports[0].profile[trusted]: Invalid value: \"yes\": trusted must be either true or false",
+			},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With unsupported metadata", openstackMAPI2CAPIConversionInput{
+			machine: openstackMAPIMachine(func(ps *mapiv1alpha1.OpenstackProviderSpec) {
+				ps.ObjectMeta.Name = "test"
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.metadata: Invalid value: v1.ObjectMeta{Name:\"test\""},
+			expectedWarnings: []string{},
+		}),
+	)
+
+	var _ = DescribeTable("mapi2capi OpenStack convert MAPI MachineSet",
+		func(in openstackMAPI2CAPIConversionInput) {
+			machineSet := openstackMAPIMachineSetBase.WithProviderSpec(in.machine.Spec.ProviderSpec).Build()
+
+			_, _, warns, err := FromOpenStackMachineSetAndInfra(machineSet, in.infra).ToMachineSetAndMachineTemplate()
+			Expect(err).To(matchers.ConsistOfMatchErrorSubstrings(in.expectedErrors), "should match expected errors while converting an OpenStack MAPI MachineSet to CAPI")
+			Expect(warns).To(matchers.ConsistOfSubstrings(in.expectedWarnings), "should match expected warnings while converting an OpenStack MAPI MachineSet to CAPI")
+		},
+
+		Entry("With a Base configuration", openstackMAPI2CAPIConversionInput{
+			machine:          openstackMAPIMachine(nil),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+	)
+
+	var _ = DescribeTable("mapi2capi OpenStack convert MAPI networks",
+		func(networks []mapiv1alpha1.NetworkParam, expectedPorts []capov1.PortOpts) {
+			_, infraMachine, _, err := FromOpenStackMachineAndInfra(openstackMAPIMachine(func(ps *mapiv1alpha1.OpenstackProviderSpec) {
+				ps.Networks = networks
+				ps.PrimarySubnet = ""
+			}), infra).ToMachineAndInfrastructureMachine()
+			Expect(err).ToNot(HaveOccurred())
+
+			openstackMachine, ok := infraMachine.(*capov1.OpenStackMachine)
+			Expect(ok).To(BeTrue())
+			Expect(openstackMachine.Spec.Ports).To(Equal(expectedPorts))
+		},
+
+		Entry("With a network without subnets",
+			[]mapiv1alpha1.NetworkParam{{UUID: "d06af90b-1677-4b35-a7fb-3ae023dc8f62"}},
+			[]capov1.PortOpts{{Network: &capov1.NetworkParam{ID: ptr.To("d06af90b-1677-4b35-a7fb-3ae023dc8f62")}}},
+		),
+		Entry("With a network with a subnet filter",
+			[]mapiv1alpha1.NetworkParam{{
+				Subnets: []mapiv1alpha1.SubnetParam{{Filter: mapiv1alpha1.SubnetFilter{Name: "test-nodes", Tags: "openshiftClusterID=test-cluster"}}},
+			}},
+			[]capov1.PortOpts{{
+				FixedIPs: []capov1.FixedIP{{Subnet: &capov1.SubnetParam{Filter: &capov1.SubnetFilter{
+					Name:                "test-nodes",
+					FilterByNeutronTags: capov1.FilterByNeutronTags{Tags: []capov1.NeutronTag{"openshiftClusterID=test-cluster"}},
+				}}}},
+			}},
+		),
+		Entry("With a network with multiple subnets",
+			[]mapiv1alpha1.NetworkParam{{
+				Filter:   mapiv1alpha1.Filter{Name: "test-network"},
+				PortTags: []string{"network-tag"},
+				Subnets: []mapiv1alpha1.SubnetParam{
+					{UUID: "810c3d97-98c2-4cf3-b0f6-8977b6e0b4b2"},
+					{UUID: "5e1d1a8c-4d63-47b4-b1ef-2d2d2f4c7c4f", PortTags: []string{"subnet-tag"}, PortSecurity: ptr.To(false)},
+				},
+			}},
+			[]capov1.PortOpts{
+				{
+					Network:  &capov1.NetworkParam{Filter: &capov1.NetworkFilter{Name: "test-network"}},
+					FixedIPs: []capov1.FixedIP{{Subnet: &capov1.SubnetParam{ID: ptr.To("810c3d97-98c2-4cf3-b0f6-8977b6e0b4b2")}}},
+					Tags:     []string{"network-tag"},
+				},
+				{
+					Network:  &capov1.NetworkParam{Filter: &capov1.NetworkFilter{Name: "test-network"}},
+					FixedIPs: []capov1.FixedIP{{Subnet: &capov1.SubnetParam{ID: ptr.To("5e1d1a8c-4d63-47b4-b1ef-2d2d2f4c7c4f")}}},
+					Tags:     []string{"network-tag", "subnet-tag"},
+					ResolvedPortSpecFields: capov1.ResolvedPortSpecFields{
+						DisablePortSecurity: ptr.To(true),
+					},
+				},
+			},
+		),
+	)
+
+	var _ = DescribeTable("mapi2capi OpenStack convert MAPI ports",
+		func(port mapiv1alpha1.PortOpts, expectedPort capov1.PortOpts) {
+			_, infraMachine, _, err := FromOpenStackMachineAndInfra(openstackMAPIMachine(func(ps *mapiv1alpha1.OpenstackProviderSpec) {
+				ps.Ports = []mapiv1alpha1.PortOpts{port}
+			}), infra).ToMachineAndInfrastructureMachine()
+			Expect(err).ToNot(HaveOccurred())
+
+			openstackMachine, ok := infraMachine.(*capov1.OpenStackMachine)
+			Expect(ok).To(BeTrue())
+			Expect(openstackMachine.Spec.Ports).ToNot(BeEmpty())
+			Expect(openstackMachine.Spec.Ports[len(openstackMachine.Spec.Ports)-1]).To(Equal(expectedPort))
+		},
+
+		Entry("With a trunk port using per-port security groups",
+			mapiv1alpha1.PortOpts{
+				NetworkID:      "0b6d6f4b-3a9b-4d48-a3a0-0b4ab5e4b0e4",
+				NameSuffix:     "trunk",
+				VNICType:       "normal",
+				SecurityGroups: &[]string{"4a5cd6f1-7b8c-4a3e-9a35-2f4b6d3c1e0f"},
+				Trunk:          ptr.To(true),
+			},
+			capov1.PortOpts{
+				Network:        &capov1.NetworkParam{ID: ptr.To("0b6d6f4b-3a9b-4d48-a3a0-0b4ab5e4b0e4")},
+				NameSuffix:     ptr.To("trunk"),
+				SecurityGroups: []capov1.SecurityGroupParam{{ID: ptr.To("4a5cd6f1-7b8c-4a3e-9a35-2f4b6d3c1e0f")}},
+				Trunk:          ptr.To(true),
+				ResolvedPortSpecFields: capov1.ResolvedPortSpecFields{
+					VNICType: ptr.To("normal"),
+				},
+			},
+		),
+		Entry("With an SR-IOV port in trusted mode",
+			mapiv1alpha1.PortOpts{
+				NetworkID:    "6c3e1a4f-2b5d-4e8a-9c7f-1d2e3f4a5b6c",
+				NameSuffix:   "sriov",
+				VNICType:     "direct",
+				PortSecurity: ptr.To(false),
+				Profile:      map[string]string{"trusted": "true"},
+			},
+			capov1.PortOpts{
+				Network:    &capov1.NetworkParam{ID: ptr.To("6c3e1a4f-2b5d-4e8a-9c7f-1d2e3f4a5b6c")},
+				NameSuffix: ptr.To("sriov"),
+				ResolvedPortSpecFields: capov1.ResolvedPortSpecFields{
+					VNICType:            ptr.To("direct"),
+					Profile:             &capov1.BindingProfile{TrustedVF: ptr.To(true)},
+					DisablePortSecurity: ptr.To(true),
+				},
+			},
+		),
+		Entry("With an OVS hardware offload port",
+			mapiv1alpha1.PortOpts{
+				NetworkID: "6c3e1a4f-2b5d-4e8a-9c7f-1d2e3f4a5b6c",
+				VNICType:  "direct",
+				Profile:   map[string]string{"capabilities": `["switchdev"]`},
+			},
+			capov1.PortOpts{
+				Network: &capov1.NetworkParam{ID: ptr.To("6c3e1a4f-2b5d-4e8a-9c7f-1d2e3f4a5b6c")},
+				ResolvedPortSpecFields: capov1.ResolvedPortSpecFields{
+					VNICType: ptr.To("direct"),
+					Profile:  &capov1.BindingProfile{OVSHWOffload: ptr.To(true)},
+				},
+			},
+		),
+	)
+})
@@ -0,0 +1,410 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mapi2capi
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	configv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1"
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	capibmv1 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// These are the MAPP defaults, CAPIBM has different defaults so they are always set explicitly.
+	powerVSDefaultSystemType          = "s922"
+	powerVSDefaultMemoryGiB           = 32
+	powerVSDefaultSharedProcessors    = "0.5"
+	powerVSDefaultDedicatedProcessors = 1
+)
+
+var (
+	errUnexpectedObjectTypeForPowerVSMachine = errors.New("unexpected type for capibmMachineObj")
+)
+
+// powerVSMachineAndInfra stores the details of a Machine API PowerVS Machine and Infra.
+type powerVSMachineAndInfra struct {
+	machine        *mapiv1.Machine
+	infrastructure *configv1.Infrastructure
+}
+
+// powerVSMachineSetAndInfra stores the details of a Machine API PowerVS MachineSet and Infra.
+type powerVSMachineSetAndInfra struct {
+	machineSet     *mapiv1.MachineSet
+	infrastructure *configv1.Infrastructure
+	*powerVSMachineAndInfra
+}
+
+// FromPowerVSMachineAndInfra wraps a Machine API Machine for PowerVS and the OCP Infrastructure object into a mapi2capi PowerVSMachineProviderConfig.
+func FromPowerVSMachineAndInfra(m *mapiv1.Machine, i *configv1.Infrastructure) Machine {
+	return &powerVSMachineAndInfra{machine: m, infrastructure: i}
+}
+
+// FromPowerVSMachineSetAndInfra wraps a Machine API MachineSet for PowerVS and the OCP Infrastructure object into a mapi2capi PowerVSMachineProviderConfig.
+func FromPowerVSMachineSetAndInfra(m *mapiv1.MachineSet, i *configv1.Infrastructure) MachineSet {
+	return &powerVSMachineSetAndInfra{
+		machineSet:     m,
+		infrastructure: i,
+		powerVSMachineAndInfra: &powerVSMachineAndInfra{
+			machine: &mapiv1.Machine{
+				Spec: m.Spec.Template.Spec,
+			},
+			infrastructure: i,
+		},
+	}
+}
+
+// ToMachineAndInfrastructureMachine is used to generate a CAPI Machine and the corresponding InfrastructureMachine
+// from the stored MAPI Machine and Infrastructure objects.
+func (m *powerVSMachineAndInfra) ToMachineAndInfrastructureMachine() (*capiv1.Machine, client.Object, []string, error) {
+	capiMachine, capibmMachine, warnings, errs := m.toMachineAndInfrastructureMachine()
+
+	if len(errs) > 0 {
+		return nil, nil, warnings, errs.ToAggregate()
+	}
+
+	return capiMachine, capibmMachine, warnings, nil
+}
+
+func (m *powerVSMachineAndInfra) toMachineAndInfrastructureMachine() (*capiv1.Machine, client.Object, []string, field.ErrorList) {
+	var (
+		errs     field.ErrorList
+		warnings []string
+	)
+
+	powerVSProviderConfig, err := powerVSProviderConfigFromRawExtension(m.machine.Spec.ProviderSpec.Value)
+	if err != nil {
+		return nil, nil, nil, field.ErrorList{field.Invalid(field.NewPath("spec", "providerSpec", "value"), m.machine.Spec.ProviderSpec.Value, err.Error())}
+	}
+
+	capibmMachine, warn, machineErrs := m.toPowerVSMachine(powerVSProviderConfig)
+	if machineErrs != nil {
+		errs = append(errs, machineErrs...)
+	}
+
+	warnings = append(warnings, warn...)
+
+	capiMachine, machineErrs := fromMAPIMachineToCAPIMachine(m.machine)
+	if machineErrs != nil {
+		errs = append(errs, machineErrs...)
+	}
+
+	// The core conversion always references an AWSMachine, point it at the IBMPowerVSMachine instead.
+	capiMachine.Spec.InfrastructureRef.APIVersion = capibmv1.GroupVersion.String()
+	capiMachine.Spec.InfrastructureRef.Kind = powerVSMachineKind
+
+	// CAPIBM uses the same ibmpowervs:// provider ID format as MAPP, so it is carried over as is.
+	capibmMachine.Spec.ProviderID = capiMachine.Spec.ProviderID
+
+	if powerVSProviderConfig.UserDataSecret != nil && powerVSProviderConfig.UserDataSecret.Name != "" {
+		capiMachine.Spec.Bootstrap = capiv1.Bootstrap{
+			DataSecretName: &powerVSProviderConfig.UserDataSecret.Name,
+		}
+	}
+
+	// Popluate the CAPI Machine ClusterName from the OCP Infrastructure object.
+	if m.infrastructure == nil || m.infrastructure.Status.InfrastructureName == "" {
+		errs = append(errs, field.Invalid(field.NewPath("infrastructure", "status", "infrastructureName"), m.infrastructure.Status.InfrastructureName, "infrastructure cannot be nil and infrastructure.Status.InfrastructureName cannot be empty"))
+	} else {
+		capiMachine.Spec.ClusterName = m.infrastructure.Status.InfrastructureName
+	}
+
+	errs = append(errs, preserveProviderSpecFields(m.machine, capiMachine, "credentialsSecret")...)
+
+	// The InfraMachine should always have the same labels and annotations as the Machine.
+	// See https://github.com/kubernetes-sigs/cluster-api/blob/f88d7ae5155700c2cc367b31ddcc151c9ad579e4/internal/controllers/machineset/machineset_controller.go#L578-L579
+	capibmMachine.SetAnnotations(capiMachine.GetAnnotations())
+	capibmMachine.SetLabels(capiMachine.GetLabels())
+
+	return capiMachine, capibmMachine, warnings, errs
+}
+
+// ToMachineSetAndMachineTemplate converts a mapi2capi PowerVSMachineSetAndInfra into a CAPI MachineSet and CAPIBM IBMPowerVSMachineTemplate.
+func (m *powerVSMachineSetAndInfra) ToMachineSetAndMachineTemplate() (*capiv1.MachineSet, client.Object, []string, error) {
+	var (
+		errs     []error
+		warnings []string
+	)
+
+	capiMachine, capibmMachineObj, warn, err := m.toMachineAndInfrastructureMachine()
+	if err != nil {
+		errs = append(errs, err.ToAggregate().Errors()...)
+	}
+
+	warnings = append(warnings, warn...)
+
+	capibmMachine, ok := capibmMachineObj.(*capibmv1.IBMPowerVSMachine)
+	if !ok {
+		panic(fmt.Errorf("%w: %T", errUnexpectedObjectTypeForPowerVSMachine, capibmMachineObj))
+	}
+
+	capibmMachineTemplate := powerVSMachineToPowerVSMachineTemplate(capibmMachine, m.machineSet.Name, capiNamespace)
+
+	capiMachineSet, machineSetErrs := fromMAPIMachineSetToCAPIMachineSet(m.machineSet)
+	if machineSetErrs != nil {
+		errs = append(errs, machineSetErrs.Errors()...)
+	}
+
+	capiMachineSet.Spec.Template.Spec = capiMachine.Spec
+
+	// We have to merge these two maps so that labels and annotations added to the template objectmeta are persisted
+	// along with the labels and annotations from the machine objectmeta.
+	capiMachineSet.Spec.Template.ObjectMeta.Labels = mergeMaps(capiMachineSet.Spec.Template.ObjectMeta.Labels, capiMachine.Labels)
+	capiMachineSet.Spec.Template.ObjectMeta.Annotations = mergeMaps(capiMachineSet.Spec.Template.ObjectMeta.Annotations, capiMachine.Annotations)
+
+	// Override the reference so that it matches the IBMPowerVSMachineTemplate.
+	capiMachineSet.Spec.Template.Spec.InfrastructureRef.Kind = powerVSMachineTemplateKind
+	capiMachineSet.Spec.Template.Spec.InfrastructureRef.Name = capibmMachineTemplate.Name
+
+	if m.infrastructure == nil || m.infrastructure.Status.InfrastructureName == "" {
+		errs = append(errs, field.Invalid(field.NewPath("infrastructure", "status", "infrastructureName"), m.infrastructure.Status.InfrastructureName, "infrastructure cannot be nil and infrastructure.Status.InfrastructureName cannot be empty"))
+	} else {
+		capiMachineSet.Spec.Template.Spec.ClusterName = m.infrastructure.Status.InfrastructureName
+		capiMachineSet.Spec.ClusterName = m.infrastructure.Status.InfrastructureName
+	}
+
+	if len(errs) > 0 {
+		return nil, nil, warnings, utilerrors.NewAggregate(errs)
+	}
+
+	return capiMachineSet, capibmMachineTemplate, warnings, nil
+}
+
+// toPowerVSMachine implements the ProviderSpec conversion interface for the PowerVS provider,
+// it converts PowerVSMachineProviderConfig to IBMPowerVSMachine.
+func (m *powerVSMachineAndInfra) toPowerVSMachine(providerConfig machinev1.PowerVSMachineProviderConfig) (*capibmv1.IBMPowerVSMachine, []string, field.ErrorList) {
+	fldPath := field.NewPath("spec", "providerSpec", "value")
+
+	var (
+		errs     field.ErrorList
+		warnings []string
+	)
+
+	serviceInstance, resourceErrs := convertPowerVSResourceToCAPI(fldPath.Child("serviceInstance"), providerConfig.ServiceInstance)
+	errs = append(errs, resourceErrs...)
+
+	image, resourceErrs := convertPowerVSResourceToCAPI(fldPath.Child("image"), providerConfig.Image)
+	errs = append(errs, resourceErrs...)
+
+	network, resourceErrs := convertPowerVSResourceToCAPI(fldPath.Child("network"), providerConfig.Network)
+	errs = append(errs, resourceErrs...)
+
+	processorType, processorTypeErrs := convertPowerVSProcessorTypeToCAPI(fldPath.Child("processorType"), providerConfig.ProcessorType)
+	errs = append(errs, processorTypeErrs...)
+
+	processors, processorsErrs := convertPowerVSProcessorsToCAPI(fldPath.Child("processors"), processorType, providerConfig.Processors)
+	errs = append(errs, processorsErrs...)
+
+	spec := capibmv1.IBMPowerVSMachineSpec{
+		ServiceInstance: &serviceInstance,
+		SSHKey:          providerConfig.KeyPairName,
+		Image:           &image,
+		SystemType:      providerConfig.SystemType,
+		ProcessorType:   processorType,
+		Processors:      processors,
+		MemoryGiB:       providerConfig.MemoryGiB,
+		Network:         network,
+	}
+
+	if spec.SystemType == "" {
+		spec.SystemType = powerVSDefaultSystemType
+	}
+
+	if spec.MemoryGiB == 0 {
+		spec.MemoryGiB = powerVSDefaultMemoryGiB
+	}
+
+	if len(providerConfig.LoadBalancers) > 0 {
+		// CAPIBM registers control plane machines with the load balancers of the IBMPowerVSCluster instead.
+		if isControlPlaneMachine(m.machine) {
+			warnings = append(warnings, field.Invalid(fldPath.Child("loadBalancers"), providerConfig.LoadBalancers, "loadBalancers are not converted, control plane machines are registered with the IBMPowerVSCluster load balancers").Error())
+		} else {
+			errs = append(errs, field.Invalid(fldPath.Child("loadBalancers"), providerConfig.LoadBalancers, "loadBalancers are not supported, Cluster API registers control plane machines with the IBMPowerVSCluster load balancers"))
+		}
+	}
+
+	// Unused fields - Below this line are fields not used from the MAPI PowerVSMachineProviderConfig.
+
+	// TypeMeta - Only for the purpose of the raw extension, not used for any functionality.
+	// CredentialsSecret - TODO(OCPCLOUD-2713): Work out what needs to happen regarding credentials secrets.
+
+	if !reflect.DeepEqual(providerConfig.ObjectMeta, metav1.ObjectMeta{}) {
+		// We don't support setting the object metadata in the provider spec.
+		// It's only present for the purpose of the raw extension and doesn't have any functionality.
+		errs = append(errs, field.Invalid(fldPath.Child("metadata"), providerConfig.ObjectMeta, "metadata is not supported"))
+	}
+
+	return &capibmv1.IBMPowerVSMachine{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: capibmv1.GroupVersion.String(),
+			Kind:       powerVSMachineKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.machine.Name,
+			Namespace: capiNamespace,
+		},
+		Spec: spec,
+	}, warnings, errs
+}
+
+// powerVSProviderConfigFromRawExtension unmarshals a raw extension into a PowerVSMachineProviderConfig type.
+func powerVSProviderConfigFromRawExtension(rawExtension *runtime.RawExtension) (machinev1.PowerVSMachineProviderConfig, error) {
+	if rawExtension == nil {
+		return machinev1.PowerVSMachineProviderConfig{}, nil
+	}
+
+	spec := machinev1.PowerVSMachineProviderConfig{}
+	if err := yaml.Unmarshal(rawExtension.Raw, &spec); err != nil {
+		return machinev1.PowerVSMachineProviderConfig{}, fmt.Errorf("error unmarshalling providerSpec: %w", err)
+	}
+
+	return spec, nil
+}
+
+func powerVSMachineToPowerVSMachineTemplate(powerVSMachine *capibmv1.IBMPowerVSMachine, name string, namespace string) *capibmv1.IBMPowerVSMachineTemplate {
+	return &capibmv1.IBMPowerVSMachineTemplate{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: capibmv1.GroupVersion.String(),
+			Kind:       powerVSMachineTemplateKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: capibmv1.IBMPowerVSMachineTemplateSpec{
+			Template: capibmv1.IBMPowerVSMachineTemplateResource{
+				Spec: powerVSMachine.Spec,
+			},
+		},
+	}
+}
+
+//////// Conversion helpers
+
+// convertPowerVSResourceToCAPI converts a MAPI PowerVS resource reference to a CAPIBM resource reference.
+// MAPP resolves the reference using the field named by the type, CAPIBM infers it from the field that is set.
+func convertPowerVSResourceToCAPI(fldPath *field.Path, resource machinev1.PowerVSResource) (capibmv1.IBMPowerVSResourceReference, field.ErrorList) {
+	switch resource.Type {
+	case machinev1.PowerVSResourceTypeID:
+		if resource.ID == nil {
+			return capibmv1.IBMPowerVSResourceReference{}, field.ErrorList{field.Required(fldPath.Child("id"), "id is required when type is ID")}
+		}
+
+		return capibmv1.IBMPowerVSResourceReference{ID: resource.ID}, nil
+	case machinev1.PowerVSResourceTypeName:
+		if resource.Name == nil {
+			return capibmv1.IBMPowerVSResourceReference{}, field.ErrorList{field.Required(fldPath.Child("name"), "name is required when type is Name")}
+		}
+
+		return capibmv1.IBMPowerVSResourceReference{Name: resource.Name}, nil
+	case machinev1.PowerVSResourceTypeRegEx:
+		if resource.RegEx == nil {
+			return capibmv1.IBMPowerVSResourceReference{}, field.ErrorList{field.Required(fldPath.Child("regex"), "regex is required when type is RegEx")}
+		}
+
+		return capibmv1.IBMPowerVSResourceReference{RegEx: resource.RegEx}, nil
+	case "":
+		// Without a type, CAPIBM requires exactly one of the references to be set.
+		ref := capibmv1.IBMPowerVSResourceReference{ID: resource.ID, Name: resource.Name, RegEx: resource.RegEx}
+
+		switch countSet(ref.ID != nil, ref.Name != nil, ref.RegEx != nil) {
+		case 0:
+			return capibmv1.IBMPowerVSResourceReference{}, field.ErrorList{field.Required(fldPath, "one of id, name or regex is required")}
+		case 1:
+			return ref, nil
+		default:
+			return capibmv1.IBMPowerVSResourceReference{}, field.ErrorList{field.Invalid(fldPath, resource, "only one of id, name or regex may be set when type is not set")}
+		}
+	default:
+		return capibmv1.IBMPowerVSResourceReference{}, field.ErrorList{field.NotSupported(fldPath.Child("type"), resource.Type, []string{
+			string(machinev1.PowerVSResourceTypeID),
+			string(machinev1.PowerVSResourceTypeName),
+			string(machinev1.PowerVSResourceTypeRegEx),
+		})}
+	}
+}
+
+// convertPowerVSProcessorTypeToCAPI converts the MAPI processor type to its CAPIBM equivalent.
+// Both default to shared processors, but the default is always set explicitly.
+func convertPowerVSProcessorTypeToCAPI(fldPath *field.Path, processorType machinev1.PowerVSProcessorType) (capibmv1.PowerVSProcessorType, field.ErrorList) {
+	switch processorType {
+	case "", machinev1.PowerVSProcessorTypeShared:
+		return capibmv1.PowerVSProcessorTypeShared, nil
+	case machinev1.PowerVSProcessorTypeDedicated:
+		return capibmv1.PowerVSProcessorTypeDedicated, nil
+	case machinev1.PowerVSProcessorTypeCapped:
+		return capibmv1.PowerVSProcessorTypeCapped, nil
+	default:
+		return "", field.ErrorList{field.NotSupported(fldPath, processorType, []string{
+			string(machinev1.PowerVSProcessorTypeDedicated),
+			string(machinev1.PowerVSProcessorTypeShared),
+			string(machinev1.PowerVSProcessorTypeCapped),
+		})}
+	}
+}
+
+// convertPowerVSProcessorsToCAPI converts the MAPI processors to CAPIBM processors.
+// MAPP and CAPIBM default to a different number of shared processors, so the MAPP default is always set explicitly.
+// Dedicated processors are bound to physical cores and so cannot be fractional.
+func convertPowerVSProcessorsToCAPI(fldPath *field.Path, processorType capibmv1.PowerVSProcessorType, processors intstr.IntOrString) (intstr.IntOrString, field.ErrorList) {
+	if processors.Type == intstr.Int && processors.IntVal == 0 || processors.Type == intstr.String && processors.StrVal == "" {
+		if processorType == capibmv1.PowerVSProcessorTypeDedicated {
+			return intstr.FromInt32(powerVSDefaultDedicatedProcessors), nil
+		}
+
+		return intstr.FromString(powerVSDefaultSharedProcessors), nil
+	}
+
+	if processors.Type == intstr.String {
+		value, err := strconv.ParseFloat(processors.StrVal, 64)
+		if err != nil {
+			return intstr.IntOrString{}, field.ErrorList{field.Invalid(fldPath, processors.StrVal, "processors must be a number")}
+		}
+
+		if processorType == capibmv1.PowerVSProcessorTypeDedicated && value != math.Trunc(value) {
+			return intstr.IntOrString{}, field.ErrorList{field.Invalid(fldPath, processors.StrVal, "processors must be a whole number when processorType is Dedicated")}
+		}
+	}
+
+	return processors, nil
+}
+
+// countSet returns the number of the given conditions that are true.
+func countSet(conditions ...bool) int {
+	count := 0
+
+	for _, c := range conditions {
+		if c {
+			count++
+		}
+	}
+
+	return count
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mapi2capi_test
+
+import (
+	"strconv"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	fuzz "github.com/google/gofuzz"
+
+	configv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/capi2mapi"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/mapi2capi"
+	conversiontest "github.com/openshift/cluster-capi-operator/pkg/conversion/test/fuzz"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeserializer "k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	capibmv1 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+var _ = Describe("PowerVS Fuzz (mapi2capi)", func() {
+	infra := &configv1.Infrastructure{
+		Spec: configv1.InfrastructureSpec{},
+		Status: configv1.InfrastructureStatus{
+			InfrastructureName: "sample-cluster-name",
+			PlatformStatus: &configv1.PlatformStatus{
+				Type: configv1.PowerVSPlatformType,
+			},
+		},
+	}
+
+	infraCluster := &capibmv1.IBMPowerVSCluster{}
+
+	Context("IBMPowerVSMachine Conversion", func() {
+		fromMachineAndPowerVSMachineAndPowerVSCluster := func(machine *capiv1.Machine, infraMachine client.Object, infraCluster client.Object) capi2mapi.MachineAndInfrastructureMachine {
+			powerVSMachine, ok := infraMachine.(*capibmv1.IBMPowerVSMachine)
+			Expect(ok).To(BeTrue(), "input infra machine should be of type %T, got %T", &capibmv1.IBMPowerVSMachine{}, infraMachine)
+
+			powerVSCluster, ok := infraCluster.(*capibmv1.IBMPowerVSCluster)
+			Expect(ok).To(BeTrue(), "input infra cluster should be of type %T, got %T", &capibmv1.IBMPowerVSCluster{}, infraCluster)
+
+			return capi2mapi.FromMachineAndPowerVSMachineAndPowerVSCluster(machine, powerVSMachine, powerVSCluster)
+		}
+
+		conversiontest.MAPI2CAPIMachineRoundTripFuzzTest(
+			scheme,
+			infra,
+			infraCluster,
+			mapi2capi.FromPowerVSMachineAndInfra,
+			fromMachineAndPowerVSMachineAndPowerVSCluster,
+			conversiontest.ObjectMetaFuzzerFuncs(mapiNamespace),
+			conversiontest.MAPIMachineFuzzerFuncs(&machinev1.PowerVSMachineProviderConfig{}, powerVSProviderIDFuzzer),
+			powerVSProviderSpecFuzzerFuncs,
+		)
+	})
+
+	Context("IBMPowerVSMachineSet Conversion", func() {
+		fromMachineSetAndPowerVSMachineTemplateAndPowerVSCluster := func(machineSet *capiv1.MachineSet, infraMachineTemplate client.Object, infraCluster client.Object) capi2mapi.MachineSetAndMachineTemplate {
+			powerVSMachineTemplate, ok := infraMachineTemplate.(*capibmv1.IBMPowerVSMachineTemplate)
+			Expect(ok).To(BeTrue(), "input infra machine template should be of type %T, got %T", &capibmv1.IBMPowerVSMachineTemplate{}, infraMachineTemplate)
+
+			powerVSCluster, ok := infraCluster.(*capibmv1.IBMPowerVSCluster)
+			Expect(ok).To(BeTrue(), "input infra cluster should be of type %T, got %T", &capibmv1.IBMPowerVSCluster{}, infraCluster)
+
+			return capi2mapi.FromMachineSetAndPowerVSMachineTemplateAndPowerVSCluster(machineSet, powerVSMachineTemplate, powerVSCluster)
+		}
+
+		conversiontest.MAPI2CAPIMachineSetRoundTripFuzzTest(
+			scheme,
+			infra,
+			infraCluster,
+			mapi2capi.FromPowerVSMachineSetAndInfra,
+			fromMachineSetAndPowerVSMachineTemplateAndPowerVSCluster,
+			conversiontest.ObjectMetaFuzzerFuncs(mapiNamespace),
+			conversiontest.MAPIMachineFuzzerFuncs(&machinev1.PowerVSMachineProviderConfig{}, powerVSProviderIDFuzzer),
+			conversiontest.MAPIMachineSetFuzzerFuncs(),
+			powerVSProviderSpecFuzzerFuncs,
+		)
+	})
+})
+
+func powerVSProviderIDFuzzer(c fuzz.Continue) string {
+	return "ibmpowervs://" + strings.ReplaceAll(c.RandString(), "/", "")
+}
+
+func powerVSProviderSpecFuzzerFuncs(codecs runtimeserializer.CodecFactory) []interface{} {
+	return []interface{}{
+		func(resource *machinev1.PowerVSResource, c fuzz.Continue) {
+			c.FuzzNoCustom(resource)
+
+			// CAPIBM references a resource using exactly one of the fields, the type is set on the way back.
+			value := c.RandString()
+			*resource = machinev1.PowerVSResource{}
+
+			switch c.Intn(3) {
+			case 0:
+				resource.Type = machinev1.PowerVSResourceTypeID
+				resource.ID = &value
+			case 1:
+				resource.Type = machinev1.PowerVSResourceTypeName
+				resource.Name = &value
+			case 2:
+				resource.Type = machinev1.PowerVSResourceTypeRegEx
+				resource.RegEx = &value
+			}
+		},
+		func(ps *machinev1.PowerVSMachineProviderConfig, c fuzz.Continue) {
+			c.FuzzNoCustom(ps)
+
+			// The type meta is always set to these values by the conversion.
+			ps.Kind = "PowerVSMachineProviderConfig"
+			ps.APIVersion = "machine.openshift.io/v1"
+
+			// The MAPP defaults are always set explicitly by the conversion.
+			if ps.SystemType == "" {
+				ps.SystemType = "s922"
+			}
+
+			if ps.MemoryGiB == 0 {
+				ps.MemoryGiB = 32
+			}
+
+			// Dedicated processors must be whole numbers.
+			ps.ProcessorType = []machinev1.PowerVSProcessorType{machinev1.PowerVSProcessorTypeDedicated, machinev1.PowerVSProcessorTypeShared, machinev1.PowerVSProcessorTypeCapped}[c.Intn(3)]
+			if ps.ProcessorType == machinev1.PowerVSProcessorTypeDedicated {
+				ps.Processors = intstr.FromInt32(c.Int31n(15) + 1)
+			} else {
+				ps.Processors = intstr.FromString(strconv.FormatFloat(float64(c.Intn(60)+1)/4, 'f', -1, 64))
+			}
+
+			// Clear fields that are not supported in the provider spec.
+			ps.ObjectMeta = metav1.ObjectMeta{}
+			ps.LoadBalancers = nil
+
+			if ps.UserDataSecret != nil && ps.UserDataSecret.Name == "" {
+				ps.UserDataSecret = nil
+			}
+		},
+	}
+}
@@ -0,0 +1,240 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mapi2capi
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	configv1 "github.com/openshift/api/config/v1"
+	machinev1 "github.com/openshift/api/machine/v1"
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+	machinebuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/machine/v1beta1"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/test/matchers"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	capibmv1 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
+)
+
+var _ = Describe("mapi2capi PowerVS conversion", func() {
+	var (
+		infra = &configv1.Infrastructure{
+			Spec: configv1.InfrastructureSpec{},
+			Status: configv1.InfrastructureStatus{
+				InfrastructureName: "sample-cluster-name",
+				PlatformStatus: &configv1.PlatformStatus{
+					Type: configv1.PowerVSPlatformType,
+				},
+			},
+		}
+	)
+
+	var powerVSProviderSpec = func(modify func(*machinev1.PowerVSMachineProviderConfig)) mapiv1.ProviderSpec {
+		providerSpec := &machinev1.PowerVSMachineProviderConfig{
+			UserDataSecret:  &machinev1.PowerVSSecretReference{Name: "worker-user-data"},
+			ServiceInstance: machinev1.PowerVSResource{Type: machinev1.PowerVSResourceTypeID, ID: ptr.To("e449d86e-c3a0-4c07-959e-8557fdf55482")},
+			Image:           machinev1.PowerVSResource{Type: machinev1.PowerVSResourceTypeName, Name: ptr.To("rhcos-test-cluster")},
+			Network:         machinev1.PowerVSResource{Type: machinev1.PowerVSResourceTypeRegEx, RegEx: ptr.To("^DHCPSERVER.*test-cluster.*_Private$")},
+			KeyPairName:     "test-cluster-key",
+			SystemType:      "s922",
+			ProcessorType:   machinev1.PowerVSProcessorTypeShared,
+			Processors:      intstr.FromString("0.5"),
+			MemoryGiB:       32,
+		}
+
+		if modify != nil {
+			modify(providerSpec)
+		}
+
+		rawBytes, err := json.Marshal(providerSpec)
+		if err != nil {
+			panic(fmt.Sprintf("unable to convert (marshal) test PowerVSMachineProviderConfig to runtime.RawExtension: %v", err))
+		}
+
+		return mapiv1.ProviderSpec{
+			Value: &runtime.RawExtension{Raw: rawBytes},
+		}
+	}
+
+	var powerVSMAPIMachine = func(modify func(*machinev1.PowerVSMachineProviderConfig)) *mapiv1.Machine {
+		return machinebuilder.Machine().WithProviderSpec(powerVSProviderSpec(modify)).Build()
+	}
+
+	type powerVSMAPI2CAPIConversionInput struct {
+		machine          *mapiv1.Machine
+		infra            *configv1.Infrastructure
+		expectedErrors   []string
+		expectedWarnings []string
+	}
+
+	var _ = DescribeTable("mapi2capi PowerVS convert MAPI Machine",
+		func(in powerVSMAPI2CAPIConversionInput) {
+			_, _, warns, err := FromPowerVSMachineAndInfra(in.machine, in.infra).ToMachineAndInfrastructureMachine()
+			Expect(err).To(matchers.ConsistOfMatchErrorSubstrings(in.expectedErrors), "should match expected errors while converting a PowerVS MAPI Machine to CAPI")
+			Expect(warns).To(matchers.ConsistOfSubstrings(in.expectedWarnings), "should match expected warnings while converting a PowerVS MAPI Machine to CAPI")
+		},
+
+		// Base Case.
+		Entry("With a Base configuration", powerVSMAPI2CAPIConversionInput{
+			machine:          powerVSMAPIMachine(nil),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With dedicated processors", powerVSMAPI2CAPIConversionInput{
+			machine: powerVSMAPIMachine(func(ps *machinev1.PowerVSMachineProviderConfig) {
+				ps.ProcessorType = machinev1.PowerVSProcessorTypeDedicated
+				ps.Processors = intstr.FromInt32(2)
+			}),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With fractional dedicated processors", powerVSMAPI2CAPIConversionInput{
+			machine: powerVSMAPIMachine(func(ps *machinev1.PowerVSMachineProviderConfig) {
+				ps.ProcessorType = machinev1.PowerVSProcessorTypeDedicated
+				ps.Processors = intstr.FromString("1.5")
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.processors: Invalid value: \"1.5\": processors must be a whole number when processorType is Dedicated"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With capped processors", powerVSMAPI2CAPIConversionInput{
+			machine: powerVSMAPIMachine(func(ps *machinev1.PowerVSMachineProviderConfig) {
+				ps.ProcessorType = machinev1.PowerVSProcessorTypeCapped
+				ps.Processors = intstr.FromString("0.25")
+			}),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With an unknown processor type", powerVSMAPI2CAPIConversionInput{
+			machine: powerVSMAPIMachine(func(ps *machinev1.PowerVSMachineProviderConfig) {
+				ps.ProcessorType = "Burst"
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.processorType: Unsupported value: \"Burst\": supported values: \"Dedicated\", \"Shared\", \"Capped\""},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a non-numeric processors value", powerVSMAPI2CAPIConversionInput{
+			machine: powerVSMAPIMachine(func(ps *machinev1.PowerVSMachineProviderConfig) {
+				ps.Processors = intstr.FromString("half")
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.processors: Invalid value: \"half\": processors must be a number"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With an image type but no image name", powerVSMAPI2CAPIConversionInput{
+			machine: powerVSMAPIMachine(func(ps *machinev1.PowerVSMachineProviderConfig) {
+				ps.Image = machinev1.PowerVSResource{Type: machinev1.PowerVSResourceTypeName, ID: ptr.To("c1a2b3c4-0000-1111-2222-333344445555")}
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.image.name: Required value: name is required when type is Name"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a network without a type", powerVSMAPI2CAPIConversionInput{
+			machine: powerVSMAPIMachine(func(ps *machinev1.PowerVSMachineProviderConfig) {
+				ps.Network = machinev1.PowerVSResource{Name: ptr.To("test-network")}
+			}),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a network without a type and multiple references", powerVSMAPI2CAPIConversionInput{
+			machine: powerVSMAPIMachine(func(ps *machinev1.PowerVSMachineProviderConfig) {
+				ps.Network = machinev1.PowerVSResource{ID: ptr.To("b2c3d4e5-0000-1111-2222-333344445555"), Name: ptr.To("test-network")}
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.network: Invalid value: v1.PowerVSResource{Type:\"\""},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("Without a service instance", powerVSMAPI2CAPIConversionInput{
+			machine: powerVSMAPIMachine(func(ps *machinev1.PowerVSMachineProviderConfig) {
+				ps.ServiceInstance = machinev1.PowerVSResource{}
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.serviceInstance: Required value: one of id, name or regex is required"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With load balancers", powerVSMAPI2CAPIConversionInput{
+			machine: powerVSMAPIMachine(func(ps *machinev1.PowerVSMachineProviderConfig) {
+				ps.LoadBalancers = []machinev1.LoadBalancerReference{{Name: "test-cluster-loadbalancer", Type: machinev1.ApplicationLoadBalancerType}}
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.loadBalancers: Invalid value: []v1.LoadBalancerReference{v1.LoadBalancerReference{Name:\"test-cluster-loadbalancer\", Type:\"Application\"}}: loadBalancers are not supported, Cluster API registers control plane machines with the IBMPowerVSCluster load balancers"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With unsupported metadata", powerVSMAPI2CAPIConversionInput{
+			machine: powerVSMAPIMachine(func(ps *machinev1.PowerVSMachineProviderConfig) {
+				ps.ObjectMeta.Name = "test"
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.metadata: Invalid value: v1.ObjectMeta{Name:\"test\""},
+			expectedWarnings: []string{},
+		}),
+	)
+
+	var _ = DescribeTable("mapi2capi PowerVS convert MAPI MachineSet",
+		func(in powerVSMAPI2CAPIConversionInput) {
+			machineSet := machinebuilder.MachineSet().WithProviderSpec(in.machine.Spec.ProviderSpec).Build()
+
+			_, _, warns, err := FromPowerVSMachineSetAndInfra(machineSet, in.infra).ToMachineSetAndMachineTemplate()
+			Expect(err).To(matchers.ConsistOfMatchErrorSubstrings(in.expectedErrors), "should match expected errors while converting a PowerVS MAPI MachineSet to CAPI")
+			Expect(warns).To(matchers.ConsistOfSubstrings(in.expectedWarnings), "should match expected warnings while converting a PowerVS MAPI MachineSet to CAPI")
+		},
+
+		Entry("With a Base configuration", powerVSMAPI2CAPIConversionInput{
+			machine:          powerVSMAPIMachine(nil),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+	)
+
+	var _ = DescribeTable("mapi2capi PowerVS convert MAPI processors",
+		func(processorType machinev1.PowerVSProcessorType, processors intstr.IntOrString, expectedProcessorType capibmv1.PowerVSProcessorType, expectedProcessors intstr.IntOrString) {
+			_, infraMachine, _, err := FromPowerVSMachineAndInfra(powerVSMAPIMachine(func(ps *machinev1.PowerVSMachineProviderConfig) {
+				ps.ProcessorType = processorType
+				ps.Processors = processors
+			}), infra).ToMachineAndInfrastructureMachine()
+			Expect(err).ToNot(HaveOccurred())
+
+			powerVSMachine, ok := infraMachine.(*capibmv1.IBMPowerVSMachine)
+			Expect(ok).To(BeTrue())
+			Expect(powerVSMachine.Spec.ProcessorType).To(Equal(expectedProcessorType))
+			Expect(powerVSMachine.Spec.Processors).To(Equal(expectedProcessors))
+		},
+
+		Entry("With the defaults", machinev1.PowerVSProcessorType(""), intstr.IntOrString{}, capibmv1.PowerVSProcessorTypeShared, intstr.FromString("0.5")),
+		Entry("With the dedicated default", machinev1.PowerVSProcessorTypeDedicated, intstr.IntOrString{}, capibmv1.PowerVSProcessorTypeDedicated, intstr.FromInt32(1)),
+		Entry("With whole dedicated processors as a string", machinev1.PowerVSProcessorTypeDedicated, intstr.FromString("2"), capibmv1.PowerVSProcessorTypeDedicated, intstr.FromString("2")),
+		Entry("With capped processors", machinev1.PowerVSProcessorTypeCapped, intstr.FromString("0.75"), capibmv1.PowerVSProcessorTypeCapped, intstr.FromString("0.75")),
+	)
+})
@@ -0,0 +1,433 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mapi2capi
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+
+	configv1 "github.com/openshift/api/config/v1"
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/ptr"
+	capvv1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	errUnexpectedObjectTypeForVSphereMachine = errors.New("unexpected type for capvMachineObj")
+)
+
+// vsphereMachineAndInfra stores the details of a Machine API vSphere Machine and Infra.
+type vsphereMachineAndInfra struct {
+	machine        *mapiv1.Machine
+	infrastructure *configv1.Infrastructure
+}
+
+// vsphereMachineSetAndInfra stores the details of a Machine API vSphere MachineSet and Infra.
+type vsphereMachineSetAndInfra struct {
+	machineSet     *mapiv1.MachineSet
+	infrastructure *configv1.Infrastructure
+	*vsphereMachineAndInfra
+}
+
+// FromVSphereMachineAndInfra wraps a Machine API Machine for vSphere and the OCP Infrastructure object into a mapi2capi VSphereProviderSpec.
+func FromVSphereMachineAndInfra(m *mapiv1.Machine, i *configv1.Infrastructure) Machine {
+	return &vsphereMachineAndInfra{machine: m, infrastructure: i}
+}
+
+// FromVSphereMachineSetAndInfra wraps a Machine API MachineSet for vSphere and the OCP Infrastructure object into a mapi2capi VSphereProviderSpec.
+func FromVSphereMachineSetAndInfra(m *mapiv1.MachineSet, i *configv1.Infrastructure) MachineSet {
+	return &vsphereMachineSetAndInfra{
+		machineSet:     m,
+		infrastructure: i,
+		vsphereMachineAndInfra: &vsphereMachineAndInfra{
+			machine: &mapiv1.Machine{
+				Spec: m.Spec.Template.Spec,
+			},
+			infrastructure: i,
+		},
+	}
+}
+
+// ToMachineAndInfrastructureMachine is used to generate a CAPI Machine and the corresponding InfrastructureMachine
+// from the stored MAPI Machine and Infrastructure objects.
+func (m *vsphereMachineAndInfra) ToMachineAndInfrastructureMachine() (*capiv1.Machine, client.Object, []string, error) {
+	capiMachine, capvMachine, warnings, errs := m.toMachineAndInfrastructureMachine()
+
+	if len(errs) > 0 {
+		return nil, nil, warnings, errs.ToAggregate()
+	}
+
+	return capiMachine, capvMachine, warnings, nil
+}
+
+func (m *vsphereMachineAndInfra) toMachineAndInfrastructureMachine() (*capiv1.Machine, client.Object, []string, field.ErrorList) {
+	var (
+		errs     field.ErrorList
+		warnings []string
+	)
+
+	vsphereProviderSpec, err := vsphereProviderSpecFromRawExtension(m.machine.Spec.ProviderSpec.Value)
+	if err != nil {
+		return nil, nil, nil, field.ErrorList{field.Invalid(field.NewPath("spec", "providerSpec", "value"), m.machine.Spec.ProviderSpec.Value, err.Error())}
+	}
+
+	capvMachine, warn, machineErrs := m.toVSphereMachine(vsphereProviderSpec)
+	if machineErrs != nil {
+		errs = append(errs, machineErrs...)
+	}
+
+	warnings = append(warnings, warn...)
+
+	capiMachine, machineErrs := fromMAPIMachineToCAPIMachine(m.machine)
+	if machineErrs != nil {
+		errs = append(errs, machineErrs...)
+	}
+
+	// The core conversion always references an AWSMachine, point it at the VSphereMachine instead.
+	capiMachine.Spec.InfrastructureRef.APIVersion = capvv1.GroupVersion.String()
+	capiMachine.Spec.InfrastructureRef.Kind = vsphereMachineKind
+
+	// CAPV uses the same vsphere:// provider ID format as MAPV, so it is carried over as is.
+	capvMachine.Spec.ProviderID = capiMachine.Spec.ProviderID
+
+	// Plug into Core CAPI Machine fields that come from the MAPI ProviderSpec which belong here instead of the CAPI VSphereMachineTemplate.
+	// CAPV places the machine using the deployment zone of the failure domain and copies it onto the VSphereMachine.
+	if failureDomain := vsphereFailureDomainForWorkspace(m.infrastructure, vsphereProviderSpec.Workspace); failureDomain != "" {
+		capiMachine.Spec.FailureDomain = ptr.To(failureDomain)
+		capvMachine.Spec.FailureDomain = ptr.To(failureDomain)
+	}
+
+	if vsphereProviderSpec.UserDataSecret != nil && vsphereProviderSpec.UserDataSecret.Name != "" {
+		capiMachine.Spec.Bootstrap = capiv1.Bootstrap{
+			DataSecretName: &vsphereProviderSpec.UserDataSecret.Name,
+		}
+	}
+
+	// Popluate the CAPI Machine ClusterName from the OCP Infrastructure object.
+	if m.infrastructure == nil || m.infrastructure.Status.InfrastructureName == "" {
+		errs = append(errs, field.Invalid(field.NewPath("infrastructure", "status", "infrastructureName"), m.infrastructure.Status.InfrastructureName, "infrastructure cannot be nil and infrastructure.Status.InfrastructureName cannot be empty"))
+	} else {
+		capiMachine.Spec.ClusterName = m.infrastructure.Status.InfrastructureName
+	}
+
+	errs = append(errs, preserveProviderSpecFields(m.machine, capiMachine, "credentialsSecret")...)
+
+	// The InfraMachine should always have the same labels and annotations as the Machine.
+	// See https://github.com/kubernetes-sigs/cluster-api/blob/f88d7ae5155700c2cc367b31ddcc151c9ad579e4/internal/controllers/machineset/machineset_controller.go#L578-L579
+	capvMachine.SetAnnotations(capiMachine.GetAnnotations())
+	capvMachine.SetLabels(capiMachine.GetLabels())
+
+	return capiMachine, capvMachine, warnings, errs
+}
+
+// ToMachineSetAndMachineTemplate converts a mapi2capi VSphereMachineSetAndInfra into a CAPI MachineSet and CAPV VSphereMachineTemplate.
+func (m *vsphereMachineSetAndInfra) ToMachineSetAndMachineTemplate() (*capiv1.MachineSet, client.Object, []string, error) {
+	var (
+		errs     []error
+		warnings []string
+	)
+
+	capiMachine, capvMachineObj, warn, err := m.toMachineAndInfrastructureMachine()
+	if err != nil {
+		errs = append(errs, err.ToAggregate().Errors()...)
+	}
+
+	warnings = append(warnings, warn...)
+
+	capvMachine, ok := capvMachineObj.(*capvv1.VSphereMachine)
+	if !ok {
+		panic(fmt.Errorf("%w: %T", errUnexpectedObjectTypeForVSphereMachine, capvMachineObj))
+	}
+
+	capvMachineTemplate := vsphereMachineToVSphereMachineTemplate(capvMachine, m.machineSet.Name, capiNamespace)
+
+	capiMachineSet, machineSetErrs := fromMAPIMachineSetToCAPIMachineSet(m.machineSet)
+	if machineSetErrs != nil {
+		errs = append(errs, machineSetErrs.Errors()...)
+	}
+
+	capiMachineSet.Spec.Template.Spec = capiMachine.Spec
+
+	// We have to merge these two maps so that labels and annotations added to the template objectmeta are persisted
+	// along with the labels and annotations from the machine objectmeta.
+	capiMachineSet.Spec.Template.ObjectMeta.Labels = mergeMaps(capiMachineSet.Spec.Template.ObjectMeta.Labels, capiMachine.Labels)
+	capiMachineSet.Spec.Template.ObjectMeta.Annotations = mergeMaps(capiMachineSet.Spec.Template.ObjectMeta.Annotations, capiMachine.Annotations)
+
+	// Override the reference so that it matches the VSphereMachineTemplate.
+	capiMachineSet.Spec.Template.Spec.InfrastructureRef.Kind = vsphereMachineTemplateKind
+	capiMachineSet.Spec.Template.Spec.InfrastructureRef.Name = capvMachineTemplate.Name
+
+	if m.infrastructure == nil || m.infrastructure.Status.InfrastructureName == "" {
+		errs = append(errs, field.Invalid(field.NewPath("infrastructure", "status", "infrastructureName"), m.infrastructure.Status.InfrastructureName, "infrastructure cannot be nil and infrastructure.Status.InfrastructureName cannot be empty"))
+	} else {
+		capiMachineSet.Spec.Template.Spec.ClusterName = m.infrastructure.Status.InfrastructureName
+		capiMachineSet.Spec.ClusterName = m.infrastructure.Status.InfrastructureName
+	}
+
+	if len(errs) > 0 {
+		return nil, nil, warnings, utilerrors.NewAggregate(errs)
+	}
+
+	return capiMachineSet, capvMachineTemplate, warnings, nil
+}
+
+// toVSphereMachine implements the ProviderSpec conversion interface for the vSphere provider,
+// it converts VSphereMachineProviderSpec to VSphereMachine.
+func (m *vsphereMachineAndInfra) toVSphereMachine(providerSpec mapiv1.VSphereMachineProviderSpec) (*capvv1.VSphereMachine, []string, field.ErrorList) {
+	fldPath := field.NewPath("spec", "providerSpec", "value")
+
+	var (
+		errs     field.ErrorList
+		warnings []string
+	)
+
+	if providerSpec.Template == "" {
+		errs = append(errs, field.Required(fldPath.Child("template"), "template is required"))
+	}
+
+	devices, deviceErrs := convertVSphereNetworkDevicesToCAPI(fldPath.Child("network", "devices"), providerSpec.Network.Devices)
+	errs = append(errs, deviceErrs...)
+
+	spec := capvv1.VSphereMachineSpec{
+		VirtualMachineCloneSpec: capvv1.VirtualMachineCloneSpec{
+			Template:          providerSpec.Template,
+			CloneMode:         convertVSphereCloneModeToCAPI(providerSpec.CloneMode),
+			Snapshot:          providerSpec.Snapshot,
+			Network:           capvv1.NetworkSpec{Devices: devices},
+			NumCPUs:           providerSpec.NumCPUs,
+			NumCoresPerSocket: providerSpec.NumCoresPerSocket,
+			MemoryMiB:         providerSpec.MemoryMiB,
+			DiskGiB:           providerSpec.DiskGiB,
+			TagIDs:            convertVSphereTagIDsToCAPI(providerSpec.TagIDs),
+		},
+	}
+
+	if providerSpec.Workspace != nil {
+		spec.Server = providerSpec.Workspace.Server
+		spec.Datacenter = providerSpec.Workspace.Datacenter
+		spec.Folder = providerSpec.Workspace.Folder
+		spec.Datastore = providerSpec.Workspace.Datastore
+		spec.ResourcePool = providerSpec.Workspace.ResourcePool
+	}
+
+	// Unused fields - Below this line are fields not used from the MAPI VSphereMachineProviderSpec.
+
+	// TypeMeta - Only for the purpose of the raw extension, not used for any functionality.
+	// CredentialsSecret - TODO(OCPCLOUD-2713): Work out what needs to happen regarding credentials secrets.
+
+	if !reflect.DeepEqual(providerSpec.ObjectMeta, metav1.ObjectMeta{}) {
+		// We don't support setting the object metadata in the provider spec.
+		// It's only present for the purpose of the raw extension and doesn't have any functionality.
+		errs = append(errs, field.Invalid(fldPath.Child("metadata"), providerSpec.ObjectMeta, "metadata is not supported"))
+	}
+
+	return &capvv1.VSphereMachine{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: capvv1.GroupVersion.String(),
+			Kind:       vsphereMachineKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      m.machine.Name,
+			Namespace: capiNamespace,
+		},
+		Spec: spec,
+	}, warnings, errs
+}
+
+// vsphereProviderSpecFromRawExtension unmarshals a raw extension into a VSphereMachineProviderSpec type.
+func vsphereProviderSpecFromRawExtension(rawExtension *runtime.RawExtension) (mapiv1.VSphereMachineProviderSpec, error) {
+	if rawExtension == nil {
+		return mapiv1.VSphereMachineProviderSpec{}, nil
+	}
+
+	spec := mapiv1.VSphereMachineProviderSpec{}
+	if err := yaml.Unmarshal(rawExtension.Raw, &spec); err != nil {
+		return mapiv1.VSphereMachineProviderSpec{}, fmt.Errorf("error unmarshalling providerSpec: %w", err)
+	}
+
+	return spec, nil
+}
+
+func vsphereMachineToVSphereMachineTemplate(vsphereMachine *capvv1.VSphereMachine, name string, namespace string) *capvv1.VSphereMachineTemplate {
+	return &capvv1.VSphereMachineTemplate{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: capvv1.GroupVersion.String(),
+			Kind:       vsphereMachineTemplateKind,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: capvv1.VSphereMachineTemplateSpec{
+			Template: capvv1.VSphereMachineTemplateResource{
+				Spec: vsphereMachine.Spec,
+			},
+		},
+	}
+}
+
+//////// Conversion helpers
+
+// convertVSphereCloneModeToCAPI converts the MAPI clone mode to its CAPV equivalent.
+// MAPV defaults to a full clone whereas CAPV defaults to a linked clone, so the default is always set explicitly.
+func convertVSphereCloneModeToCAPI(cloneMode mapiv1.CloneMode) capvv1.CloneMode {
+	if cloneMode == mapiv1.LinkedClone {
+		return capvv1.LinkedClone
+	}
+
+	return capvv1.FullClone
+}
+
+// vsphereFailureDomainForWorkspace returns the name of the infrastructure failure domain the workspace places the machine in.
+// Zonal installs spread MAPI machines by giving each of them the workspace of a failure domain, the machine carries no failure domain otherwise.
+func vsphereFailureDomainForWorkspace(infra *configv1.Infrastructure, workspace *mapiv1.Workspace) string {
+	if infra == nil || infra.Spec.PlatformSpec.VSphere == nil || workspace == nil {
+		return ""
+	}
+
+	for _, fd := range infra.Spec.PlatformSpec.VSphere.FailureDomains {
+		// The installer defaults the resource pool of a failure domain to the root resource pool of its compute cluster.
+		resourcePool := fd.Topology.ResourcePool
+		if resourcePool == "" {
+			resourcePool = fd.Topology.ComputeCluster + "/Resources"
+		}
+
+		if workspace.Server == fd.Server &&
+			workspace.Datacenter == fd.Topology.Datacenter &&
+			workspace.Datastore == fd.Topology.Datastore &&
+			workspace.ResourcePool == resourcePool {
+			return fd.Name
+		}
+	}
+
+	return ""
+}
+
+func convertVSphereTagIDsToCAPI(mapiTagIDs []string) []string {
+	if len(mapiTagIDs) == 0 {
+		return nil
+	}
+
+	return append([]string{}, mapiTagIDs...)
+}
+
+// convertVSphereNetworkDevicesToCAPI converts the MAPI network devices to CAPV network devices.
+// MAPV relies on DHCP whenever a device has no static or pool addresses, which CAPV needs to be told explicitly.
+func convertVSphereNetworkDevicesToCAPI(fldPath *field.Path, mapiDevices []mapiv1.NetworkDeviceSpec) ([]capvv1.NetworkDeviceSpec, field.ErrorList) {
+	var (
+		capvDevices []capvv1.NetworkDeviceSpec
+		errs        field.ErrorList
+	)
+
+	for i, device := range mapiDevices {
+		addressesFromPools, poolErrs := convertVSphereAddressesFromPoolsToCAPI(fldPath.Index(i).Child("addressesFromPools"), device.AddressesFromPools)
+		errs = append(errs, poolErrs...)
+		errs = append(errs, validateVSphereNetworkDeviceAddresses(fldPath.Index(i), device.IPAddrs, device.Nameservers)...)
+
+		capvDevice := capvv1.NetworkDeviceSpec{
+			NetworkName:        device.NetworkName,
+			IPAddrs:            device.IPAddrs,
+			Nameservers:        device.Nameservers,
+			AddressesFromPools: addressesFromPools,
+			DHCP4:              len(device.IPAddrs) == 0 && len(device.AddressesFromPools) == 0,
+		}
+
+		if device.Gateway != "" {
+			gateway := net.ParseIP(device.Gateway)
+
+			switch {
+			case gateway == nil:
+				errs = append(errs, field.Invalid(fldPath.Index(i).Child("gateway"), device.Gateway, "gateway must be a valid IPv4 or IPv6 address"))
+			case gateway.To4() != nil:
+				capvDevice.Gateway4 = device.Gateway
+			default:
+				capvDevice.Gateway6 = device.Gateway
+			}
+		}
+
+		capvDevices = append(capvDevices, capvDevice)
+	}
+
+	return capvDevices, errs
+}
+
+// validateVSphereNetworkDeviceAddresses validates the static addresses and nameservers of a MAPI network device.
+// CAPV writes these as is into the guest network configuration, so they must be usable without further resolution.
+func validateVSphereNetworkDeviceAddresses(fldPath *field.Path, ipAddrs []string, nameservers []string) field.ErrorList {
+	var errs field.ErrorList
+
+	for i, ipAddr := range ipAddrs {
+		if _, _, err := net.ParseCIDR(ipAddr); err != nil {
+			errs = append(errs, field.Invalid(fldPath.Child("ipAddrs").Index(i), ipAddr, "ipAddrs must be valid IPv4 or IPv6 addresses in CIDR notation"))
+		}
+	}
+
+	for i, nameserver := range nameservers {
+		if net.ParseIP(nameserver) == nil {
+			errs = append(errs, field.Invalid(fldPath.Child("nameservers").Index(i), nameserver, "nameservers must be valid IPv4 or IPv6 addresses"))
+		}
+	}
+
+	return errs
+}
+
+// convertVSphereAddressesFromPoolsToCAPI converts the MAPI IP address pool references to CAPV typed references.
+// MAPV already uses the pool resource as the kind of the pool reference when it claims addresses.
+// The IPAM provider can only resolve a pool through its group, kind and name, so all of them are required.
+func convertVSphereAddressesFromPoolsToCAPI(fldPath *field.Path, mapiPools []mapiv1.AddressesFromPool) ([]corev1.TypedLocalObjectReference, field.ErrorList) {
+	var (
+		capvPools []corev1.TypedLocalObjectReference
+		errs      field.ErrorList
+	)
+
+	for i, pool := range mapiPools {
+		if pool.Group == "" {
+			errs = append(errs, field.Required(fldPath.Index(i).Child("group"), "group is required"))
+		}
+
+		if pool.Resource == "" {
+			errs = append(errs, field.Required(fldPath.Index(i).Child("resource"), "resource is required"))
+		}
+
+		if pool.Name == "" {
+			errs = append(errs, field.Required(fldPath.Index(i).Child("name"), "name is required"))
+		}
+
+		capvPool := corev1.TypedLocalObjectReference{
+			Kind: pool.Resource,
+			Name: pool.Name,
+		}
+
+		if pool.Group != "" {
+			capvPool.APIGroup = ptr.To(pool.Group)
+		}
+
+		capvPools = append(capvPools, capvPool)
+	}
+
+	return capvPools, errs
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mapi2capi_test
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	fuzz "github.com/google/gofuzz"
+
+	configv1 "github.com/openshift/api/config/v1"
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/capi2mapi"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/mapi2capi"
+	conversiontest "github.com/openshift/cluster-capi-operator/pkg/conversion/test/fuzz"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	runtimeserializer "k8s.io/apimachinery/pkg/runtime/serializer"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	capvv1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+var _ = Describe("vSphere Fuzz (mapi2capi)", func() {
+	infra := &configv1.Infrastructure{
+		Spec: configv1.InfrastructureSpec{},
+		Status: configv1.InfrastructureStatus{
+			InfrastructureName: "sample-cluster-name",
+			PlatformStatus: &configv1.PlatformStatus{
+				Type: configv1.VSpherePlatformType,
+			},
+		},
+	}
+
+	infraCluster := &capvv1.VSphereCluster{
+		Spec: capvv1.VSphereClusterSpec{
+			Server: "sample-vcenter",
+		},
+	}
+
+	Context("VSphereMachine Conversion", func() {
+		fromMachineAndVSphereMachineAndVSphereCluster := func(machine *capiv1.Machine, infraMachine client.Object, infraCluster client.Object) capi2mapi.MachineAndInfrastructureMachine {
+			vsphereMachine, ok := infraMachine.(*capvv1.VSphereMachine)
+			Expect(ok).To(BeTrue(), "input infra machine should be of type %T, got %T", &capvv1.VSphereMachine{}, infraMachine)
+
+			vsphereCluster, ok := infraCluster.(*capvv1.VSphereCluster)
+			Expect(ok).To(BeTrue(), "input infra cluster should be of type %T, got %T", &capvv1.VSphereCluster{}, infraCluster)
+
+			return capi2mapi.FromMachineAndVSphereMachineAndVSphereCluster(machine, vsphereMachine, vsphereCluster)
+		}
+
+		conversiontest.MAPI2CAPIMachineRoundTripFuzzTest(
+			scheme,
+			infra,
+			infraCluster,
+			mapi2capi.FromVSphereMachineAndInfra,
+			fromMachineAndVSphereMachineAndVSphereCluster,
+			conversiontest.ObjectMetaFuzzerFuncs(mapiNamespace),
+			conversiontest.MAPIMachineFuzzerFuncs(&mapiv1.VSphereMachineProviderSpec{}, vsphereProviderIDFuzzer),
+			vsphereProviderSpecFuzzerFuncs,
+		)
+	})
+
+	Context("VSphereMachineSet Conversion", func() {
+		fromMachineSetAndVSphereMachineTemplateAndVSphereCluster := func(machineSet *capiv1.MachineSet, infraMachineTemplate client.Object, infraCluster client.Object) capi2mapi.MachineSetAndMachineTemplate {
+			vsphereMachineTemplate, ok := infraMachineTemplate.(*capvv1.VSphereMachineTemplate)
+			Expect(ok).To(BeTrue(), "input infra machine template should be of type %T, got %T", &capvv1.VSphereMachineTemplate{}, infraMachineTemplate)
+
+			vsphereCluster, ok := infraCluster.(*capvv1.VSphereCluster)
+			Expect(ok).To(BeTrue(), "input infra cluster should be of type %T, got %T", &capvv1.VSphereCluster{}, infraCluster)
+
+			return capi2mapi.FromMachineSetAndVSphereMachineTemplateAndVSphereCluster(machineSet, vsphereMachineTemplate, vsphereCluster)
+		}
+
+		conversiontest.MAPI2CAPIMachineSetRoundTripFuzzTest(
+			scheme,
+			infra,
+			infraCluster,
+			mapi2capi.FromVSphereMachineSetAndInfra,
+			fromMachineSetAndVSphereMachineTemplateAndVSphereCluster,
+			conversiontest.ObjectMetaFuzzerFuncs(mapiNamespace),
+			conversiontest.MAPIMachineFuzzerFuncs(&mapiv1.VSphereMachineProviderSpec{}, vsphereProviderIDFuzzer),
+			conversiontest.MAPIMachineSetFuzzerFuncs(),
+			vsphereProviderSpecFuzzerFuncs,
+		)
+	})
+})
+
+func vsphereProviderIDFuzzer(c fuzz.Continue) string {
+	return "vsphere://" + strings.ReplaceAll(c.RandString(), "/", "")
+}
+
+func vsphereProviderSpecFuzzerFuncs(codecs runtimeserializer.CodecFactory) []interface{} {
+	return []interface{}{
+		func(device *mapiv1.NetworkDeviceSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(device)
+
+			// The gateway is split by IP family in CAPV, so it must be a valid address.
+			switch c.Intn(3) {
+			case 0:
+				device.Gateway = ""
+			case 1:
+				device.Gateway = fmt.Sprintf("%d.%d.%d.%d", c.Intn(256), c.Intn(256), c.Intn(256), c.Intn(256))
+			case 2:
+				device.Gateway = fmt.Sprintf("fd00::%x", c.Intn(65536))
+			}
+
+			// Static addresses and nameservers are validated, so they must be valid addresses.
+			device.IPAddrs = nil
+			for range c.Intn(3) {
+				device.IPAddrs = append(device.IPAddrs, fmt.Sprintf("192.168.%d.%d/24", c.Intn(256), c.Intn(256)))
+			}
+
+			device.Nameservers = nil
+			for range c.Intn(3) {
+				device.Nameservers = append(device.Nameservers, fmt.Sprintf("fd00::%x", c.Intn(65536)))
+			}
+
+			// IP address pools are always referenced by group, resource and name.
+			for i := range device.AddressesFromPools {
+				device.AddressesFromPools[i] = mapiv1.AddressesFromPool{
+					Group:    "ipam.cluster.x-k8s.io",
+					Resource: "IPPool",
+					Name:     fmt.Sprintf("pool-%d", c.Intn(1000)),
+				}
+			}
+
+			if len(device.AddressesFromPools) == 0 {
+				device.AddressesFromPools = nil
+			}
+		},
+		func(ps *mapiv1.VSphereMachineProviderSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(ps)
+
+			// The type meta is always set to these values by the conversion.
+			ps.Kind = "VSphereMachineProviderSpec"
+			ps.APIVersion = "machine.openshift.io/v1beta1"
+
+			// A template is required to clone the machine from.
+			if ps.Template == "" {
+				ps.Template = "sample-template"
+			}
+
+			// The MAPV default of a full clone is always set explicitly on the way back.
+			ps.CloneMode = []mapiv1.CloneMode{mapiv1.FullClone, mapiv1.LinkedClone}[c.Intn(2)]
+
+			if ps.Workspace != nil && *ps.Workspace == (mapiv1.Workspace{}) {
+				ps.Workspace = nil
+			}
+
+			if len(ps.Network.Devices) == 0 {
+				ps.Network.Devices = nil
+			}
+
+			if len(ps.TagIDs) == 0 {
+				ps.TagIDs = nil
+			}
+
+			// Clear fields that are not supported in the provider spec.
+			ps.ObjectMeta = metav1.ObjectMeta{}
+
+			if ps.UserDataSecret != nil && ps.UserDataSecret.Name == "" {
+				ps.UserDataSecret = nil
+			} else if ps.UserDataSecret != nil {
+				ps.UserDataSecret = &corev1.LocalObjectReference{Name: ps.UserDataSecret.Name}
+			}
+		},
+	}
+}
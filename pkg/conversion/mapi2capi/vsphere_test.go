@@ -0,0 +1,321 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package mapi2capi
+
+import (
+	"encoding/json"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	configv1 "github.com/openshift/api/config/v1"
+	mapiv1 "github.com/openshift/api/machine/v1beta1"
+	machinebuilder "github.com/openshift/cluster-api-actuator-pkg/testutils/resourcebuilder/machine/v1beta1"
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/test/matchers"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	capvv1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+)
+
+var _ = Describe("mapi2capi vSphere conversion", func() {
+	var (
+		vsphereBaseProviderSpec   = machinebuilder.VSphereProviderSpec()
+		vsphereMAPIMachineSetBase = machinebuilder.MachineSet().WithProviderSpecBuilder(vsphereBaseProviderSpec)
+
+		infra = &configv1.Infrastructure{
+			Spec: configv1.InfrastructureSpec{},
+			Status: configv1.InfrastructureStatus{
+				InfrastructureName: "sample-cluster-name",
+				PlatformStatus: &configv1.PlatformStatus{
+					Type: configv1.VSpherePlatformType,
+				},
+			},
+		}
+	)
+
+	var vsphereProviderSpec = func(modify func(*mapiv1.VSphereMachineProviderSpec)) mapiv1.ProviderSpec {
+		providerSpec := vsphereBaseProviderSpec.Build()
+
+		if modify != nil {
+			modify(providerSpec)
+		}
+
+		rawBytes, err := json.Marshal(providerSpec)
+		if err != nil {
+			panic(fmt.Sprintf("unable to convert (marshal) test VSphereProviderSpec to runtime.RawExtension: %v", err))
+		}
+
+		return mapiv1.ProviderSpec{
+			Value: &runtime.RawExtension{Raw: rawBytes},
+		}
+	}
+
+	var vsphereMAPIMachine = func(modify func(*mapiv1.VSphereMachineProviderSpec)) *mapiv1.Machine {
+		return machinebuilder.Machine().WithProviderSpec(vsphereProviderSpec(modify)).Build()
+	}
+
+	type vsphereMAPI2CAPIConversionInput struct {
+		machine          *mapiv1.Machine
+		infra            *configv1.Infrastructure
+		expectedErrors   []string
+		expectedWarnings []string
+	}
+
+	var _ = DescribeTable("mapi2capi vSphere convert MAPI Machine",
+		func(in vsphereMAPI2CAPIConversionInput) {
+			_, _, warns, err := FromVSphereMachineAndInfra(in.machine, in.infra).ToMachineAndInfrastructureMachine()
+			Expect(err).To(matchers.ConsistOfMatchErrorSubstrings(in.expectedErrors), "should match expected errors while converting a vSphere MAPI Machine to CAPI")
+			Expect(warns).To(matchers.ConsistOfSubstrings(in.expectedWarnings), "should match expected warnings while converting a vSphere MAPI Machine to CAPI")
+		},
+
+		// Base Case.
+		Entry("With a Base configuration", vsphereMAPI2CAPIConversionInput{
+			machine:          vsphereMAPIMachine(nil),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With a linked clone", vsphereMAPI2CAPIConversionInput{
+			machine: vsphereMAPIMachine(func(ps *mapiv1.VSphereMachineProviderSpec) {
+				ps.CloneMode = mapiv1.LinkedClone
+				ps.Snapshot = "test-snapshot"
+			}),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With an IP address pool", vsphereMAPI2CAPIConversionInput{
+			machine:          machinebuilder.Machine().WithProviderSpecBuilder(vsphereBaseProviderSpec.WithIPPool()).Build(),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With static IP addresses", vsphereMAPI2CAPIConversionInput{
+			machine: vsphereMAPIMachine(func(ps *mapiv1.VSphereMachineProviderSpec) {
+				ps.Network.Devices[0].IPAddrs = []string{"192.168.1.100/24"}
+				ps.Network.Devices[0].Gateway = "192.168.1.1"
+				ps.Network.Devices[0].Nameservers = []string{"192.168.1.2"}
+			}),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With multiple network devices", vsphereMAPI2CAPIConversionInput{
+			machine: vsphereMAPIMachine(func(ps *mapiv1.VSphereMachineProviderSpec) {
+				ps.Network.Devices = []mapiv1.NetworkDeviceSpec{
+					{
+						NetworkName: "test-network",
+						IPAddrs:     []string{"192.168.1.100/24"},
+						Gateway:     "192.168.1.1",
+						Nameservers: []string{"192.168.1.2"},
+					},
+					{
+						NetworkName:        "test-storage-network",
+						AddressesFromPools: []mapiv1.AddressesFromPool{{Group: "ipam.cluster.x-k8s.io", Resource: "IPPool", Name: "test-pool"}},
+						Nameservers:        []string{"fd00::2"},
+					},
+					{
+						NetworkName: "test-backup-network",
+					},
+				}
+			}),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With invalid static IP addresses and nameservers", vsphereMAPI2CAPIConversionInput{
+			machine: vsphereMAPIMachine(func(ps *mapiv1.VSphereMachineProviderSpec) {
+				ps.Network.Devices[0].IPAddrs = []string{"192.168.1.100"}
+				ps.Network.Devices[0].Nameservers = []string{"dns.example.com"}
+			}),
+			infra: infra,
+			expectedErrors: []string{
+				"spec.providerSpec.value.network.devices[0].ipAddrs[0]: Invalid value: \"192.168.1.100\": ipAddrs must be valid IPv4 or IPv6 addresses in CIDR notation",
+				"spec.providerSpec.value.network.devices[0].nameservers[0]: Invalid value: \"dns.example.com\": nameservers must be valid IPv4 or IPv6 addresses",
+			},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With an incomplete IP address pool reference", vsphereMAPI2CAPIConversionInput{
+			machine: vsphereMAPIMachine(func(ps *mapiv1.VSphereMachineProviderSpec) {
+				ps.Network.Devices[0].AddressesFromPools = []mapiv1.AddressesFromPool{{Name: "test-pool"}}
+			}),
+			infra: infra,
+			expectedErrors: []string{
+				"spec.providerSpec.value.network.devices[0].addressesFromPools[0].group: Required value: group is required",
+				"spec.providerSpec.value.network.devices[0].addressesFromPools[0].resource: Required value: resource is required",
+			},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With an invalid gateway", vsphereMAPI2CAPIConversionInput{
+			machine: vsphereMAPIMachine(func(ps *mapiv1.VSphereMachineProviderSpec) {
+				ps.Network.Devices[0].IPAddrs = []string{"192.168.1.100/24"}
+				ps.Network.Devices[0].Gateway = "192.168.1.1/24"
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.network.devices[0].gateway: Invalid value: \"192.168.1.1/24\": gateway must be a valid IPv4 or IPv6 address"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("Without a template", vsphereMAPI2CAPIConversionInput{
+			machine: vsphereMAPIMachine(func(ps *mapiv1.VSphereMachineProviderSpec) {
+				ps.Template = ""
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.template: Required value: template is required"},
+			expectedWarnings: []string{},
+		}),
+
+		Entry("With unsupported metadata", vsphereMAPI2CAPIConversionInput{
+			machine: vsphereMAPIMachine(func(ps *mapiv1.VSphereMachineProviderSpec) {
+				ps.ObjectMeta.Name = "test"
+			}),
+			infra:            infra,
+			expectedErrors:   []string{"spec.providerSpec.value.metadata: Invalid value: v1.ObjectMeta{Name:\"test\""},
+			expectedWarnings: []string{},
+		}),
+	)
+
+	var _ = DescribeTable("mapi2capi vSphere convert MAPI MachineSet",
+		func(in vsphereMAPI2CAPIConversionInput) {
+			machineSet := vsphereMAPIMachineSetBase.WithProviderSpec(in.machine.Spec.ProviderSpec).Build()
+
+			_, _, warns, err := FromVSphereMachineSetAndInfra(machineSet, in.infra).ToMachineSetAndMachineTemplate()
+			Expect(err).To(matchers.ConsistOfMatchErrorSubstrings(in.expectedErrors), "should match expected errors while converting a vSphere MAPI MachineSet to CAPI")
+			Expect(warns).To(matchers.ConsistOfSubstrings(in.expectedWarnings), "should match expected warnings while converting a vSphere MAPI MachineSet to CAPI")
+		},
+
+		Entry("With a Base configuration", vsphereMAPI2CAPIConversionInput{
+			machine:          vsphereMAPIMachine(nil),
+			infra:            infra,
+			expectedErrors:   []string{},
+			expectedWarnings: []string{},
+		}),
+	)
+
+	var _ = DescribeTable("mapi2capi vSphere convert MAPI clone modes",
+		func(cloneMode mapiv1.CloneMode, expectedCloneMode capvv1.CloneMode) {
+			_, infraMachine, _, err := FromVSphereMachineAndInfra(vsphereMAPIMachine(func(ps *mapiv1.VSphereMachineProviderSpec) {
+				ps.CloneMode = cloneMode
+			}), infra).ToMachineAndInfrastructureMachine()
+			Expect(err).ToNot(HaveOccurred())
+
+			vsphereMachine, ok := infraMachine.(*capvv1.VSphereMachine)
+			Expect(ok).To(BeTrue())
+			Expect(vsphereMachine.Spec.CloneMode).To(Equal(expectedCloneMode))
+		},
+
+		Entry("With the MAPI default, a full clone", mapiv1.CloneMode(""), capvv1.FullClone),
+		Entry("With a full clone", mapiv1.FullClone, capvv1.FullClone),
+		Entry("With a linked clone", mapiv1.LinkedClone, capvv1.LinkedClone),
+	)
+
+	var _ = DescribeTable("mapi2capi vSphere convert MAPI workspaces to failure domains",
+		func(workspace *mapiv1.Workspace, expectedFailureDomain *string) {
+			zonalInfra := infra.DeepCopy()
+			zonalInfra.Spec.PlatformSpec.VSphere = &configv1.VSpherePlatformSpec{
+				FailureDomains: []configv1.VSpherePlatformFailureDomainSpec{
+					{
+						Name:   "us-east-1a",
+						Server: "test-vcenter",
+						Topology: configv1.VSpherePlatformTopology{
+							Datacenter:     "test-datacenter",
+							ComputeCluster: "/test-datacenter/host/test-cluster-a",
+							Datastore:      "/test-datacenter/datastore/test-datastore",
+						},
+					},
+					{
+						Name:   "us-east-1b",
+						Server: "test-vcenter",
+						Topology: configv1.VSpherePlatformTopology{
+							Datacenter:     "test-datacenter",
+							ComputeCluster: "/test-datacenter/host/test-cluster-b",
+							Datastore:      "/test-datacenter/datastore/test-datastore",
+							ResourcePool:   "/test-datacenter/host/test-cluster-b/Resources/test-pool",
+						},
+					},
+				},
+			}
+
+			capiMachine, infraMachine, _, err := FromVSphereMachineAndInfra(vsphereMAPIMachine(func(ps *mapiv1.VSphereMachineProviderSpec) {
+				ps.Workspace = workspace
+			}), zonalInfra).ToMachineAndInfrastructureMachine()
+			Expect(err).ToNot(HaveOccurred())
+
+			vsphereMachine, ok := infraMachine.(*capvv1.VSphereMachine)
+			Expect(ok).To(BeTrue())
+			Expect(capiMachine.Spec.FailureDomain).To(Equal(expectedFailureDomain))
+			Expect(vsphereMachine.Spec.FailureDomain).To(Equal(expectedFailureDomain))
+		},
+
+		Entry("With the workspace of a failure domain using the root resource pool", &mapiv1.Workspace{
+			Server:       "test-vcenter",
+			Datacenter:   "test-datacenter",
+			Datastore:    "/test-datacenter/datastore/test-datastore",
+			ResourcePool: "/test-datacenter/host/test-cluster-a/Resources",
+		}, ptr.To("us-east-1a")),
+		Entry("With the workspace of a failure domain using a resource pool", &mapiv1.Workspace{
+			Server:       "test-vcenter",
+			Datacenter:   "test-datacenter",
+			Datastore:    "/test-datacenter/datastore/test-datastore",
+			ResourcePool: "/test-datacenter/host/test-cluster-b/Resources/test-pool",
+		}, ptr.To("us-east-1b")),
+		Entry("With a workspace outside of the failure domains", &mapiv1.Workspace{
+			Server:       "test-vcenter",
+			Datacenter:   "test-datacenter",
+			Datastore:    "/test-datacenter/datastore/test-datastore",
+			ResourcePool: "/test-datacenter/host/test-cluster-c/Resources",
+		}, nil),
+		Entry("Without a workspace", nil, nil),
+	)
+
+	var _ = DescribeTable("mapi2capi vSphere convert MAPI network devices",
+		func(device mapiv1.NetworkDeviceSpec, expectedDevice capvv1.NetworkDeviceSpec) {
+			_, infraMachine, _, err := FromVSphereMachineAndInfra(vsphereMAPIMachine(func(ps *mapiv1.VSphereMachineProviderSpec) {
+				ps.Network.Devices = []mapiv1.NetworkDeviceSpec{device}
+			}), infra).ToMachineAndInfrastructureMachine()
+			Expect(err).ToNot(HaveOccurred())
+
+			vsphereMachine, ok := infraMachine.(*capvv1.VSphereMachine)
+			Expect(ok).To(BeTrue())
+			Expect(vsphereMachine.Spec.Network.Devices).To(ConsistOf(expectedDevice))
+		},
+
+		Entry("With DHCP",
+			mapiv1.NetworkDeviceSpec{NetworkName: "test-network"},
+			capvv1.NetworkDeviceSpec{NetworkName: "test-network", DHCP4: true},
+		),
+		Entry("With a static IPv4 address",
+			mapiv1.NetworkDeviceSpec{NetworkName: "test-network", IPAddrs: []string{"192.168.1.100/24"}, Gateway: "192.168.1.1"},
+			capvv1.NetworkDeviceSpec{NetworkName: "test-network", IPAddrs: []string{"192.168.1.100/24"}, Gateway4: "192.168.1.1"},
+		),
+		Entry("With a static IPv6 address",
+			mapiv1.NetworkDeviceSpec{NetworkName: "test-network", IPAddrs: []string{"fd00::100/64"}, Gateway: "fd00::1"},
+			capvv1.NetworkDeviceSpec{NetworkName: "test-network", IPAddrs: []string{"fd00::100/64"}, Gateway6: "fd00::1"},
+		),
+		Entry("With an IP address pool",
+			mapiv1.NetworkDeviceSpec{NetworkName: "test-network", AddressesFromPools: []mapiv1.AddressesFromPool{{Group: "ipam.cluster.x-k8s.io", Resource: "IPPool", Name: "test-pool"}}},
+			capvv1.NetworkDeviceSpec{NetworkName: "test-network", AddressesFromPools: []corev1.TypedLocalObjectReference{{APIGroup: ptr.To("ipam.cluster.x-k8s.io"), Kind: "IPPool", Name: "test-pool"}}},
+		),
+	)
+})
@@ -0,0 +1,107 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// PreservedFieldsAnnotation holds the raw values of the fields that have no equivalent in the API of the mirrored
+// object, so that they can be restored when converting back.
+const PreservedFieldsAnnotation = "machine.openshift.io/preserved-fields"
+
+// PreservedFields is the structured content of the preserved fields annotation.
+type PreservedFields struct {
+	// ProviderSpec holds the raw values of MAPI providerSpec fields, keyed by their JSON field name.
+	ProviderSpec map[string]json.RawMessage `json:"providerSpec,omitempty"`
+}
+
+// PreserveProviderSpecFields returns the value of the preserved fields annotation recording the named providerSpec
+// fields that are set. It returns an empty string when none of the fields are set.
+func PreserveProviderSpecFields(providerSpec *runtime.RawExtension, fieldNames ...string) (string, error) {
+	if providerSpec == nil || len(providerSpec.Raw) == 0 {
+		return "", nil
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(providerSpec.Raw, &fields); err != nil {
+		return "", fmt.Errorf("failed to unmarshal providerSpec: %w", err)
+	}
+
+	preserved := PreservedFields{ProviderSpec: map[string]json.RawMessage{}}
+
+	for _, name := range fieldNames {
+		if value, ok := fields[name]; ok && !isUnsetJSONValue(value) {
+			preserved.ProviderSpec[name] = value
+		}
+	}
+
+	if len(preserved.ProviderSpec) == 0 {
+		return "", nil
+	}
+
+	value, err := json.Marshal(preserved)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal preserved fields: %w", err)
+	}
+
+	return string(value), nil
+}
+
+// RestoreProviderSpecFields returns the providerSpec with the fields recorded in the preserved fields annotation value
+// set, unless the converted providerSpec already sets them.
+func RestoreProviderSpecFields(value string, providerSpec *runtime.RawExtension) (*runtime.RawExtension, error) {
+	preserved := PreservedFields{}
+	if err := json.Unmarshal([]byte(value), &preserved); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal preserved fields: %w", err)
+	}
+
+	if len(preserved.ProviderSpec) == 0 || providerSpec == nil || len(providerSpec.Raw) == 0 {
+		return providerSpec, nil
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(providerSpec.Raw, &fields); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal providerSpec: %w", err)
+	}
+
+	for name, value := range preserved.ProviderSpec {
+		if existing, ok := fields[name]; !ok || isUnsetJSONValue(existing) {
+			fields[name] = value
+		}
+	}
+
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal providerSpec: %w", err)
+	}
+
+	return &runtime.RawExtension{Raw: raw}, nil
+}
+
+// isUnsetJSONValue returns whether the raw JSON value is null or an empty string,
+// which is how fields without omitempty serialize when they are not set.
+func isUnsetJSONValue(value json.RawMessage) bool {
+	switch string(value) {
+	case "null", `""`:
+		return true
+	default:
+		return false
+	}
+}
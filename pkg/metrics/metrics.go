@@ -0,0 +1,182 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics provides the metrics of the operator controllers, exposed on the metrics endpoint of the manager.
+// The reconcile duration histograms (controller_runtime_reconcile_time_seconds), the reconcile error counters
+// (controller_runtime_reconcile_errors_total) and the work queue depth gauges (workqueue_depth) are provided by
+// controller-runtime for each named controller, the metrics here complement them.
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// reasonUnknown is the reason of errors which are not API errors.
+const reasonUnknown = "Unknown"
+
+// reconcileErrors counts the reconcile errors of the controllers by reason, so alerts can tell apart e.g.
+// a missing cloud resource from an API server being unavailable.
+var reconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "cluster_capi_operator_reconcile_errors_by_reason_total",
+	Help: "Total number of reconcile errors of the operator controllers, by controller and reason.",
+}, []string{"controller", "reason"})
+
+// infraClusterReady reports whether the InfraCluster of the cluster is ready.
+var infraClusterReady = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "cluster_capi_operator_infracluster_ready",
+	Help: "Whether the InfraCluster is ready (1) or not (0).",
+})
+
+// kubeconfigTokenCreated reports when the token of the kubeconfig used by the CAPI controllers was issued.
+var kubeconfigTokenCreated = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "cluster_capi_operator_kubeconfig_token_created_timestamp_seconds",
+	Help: "Unix creation timestamp of the token of the kubeconfig of the CAPI controllers, by kubeconfig secret.",
+}, []string{"secret"})
+
+// kubeconfigTokenExpiry reports when the token of the kubeconfig used by the CAPI controllers expires.
+// The token is renewed ahead of its expiry, a token close to expiry means the rotation is stuck.
+var kubeconfigTokenExpiry = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "cluster_capi_operator_kubeconfig_token_expiry_timestamp_seconds",
+	Help: "Unix expiry timestamp of the token of the kubeconfig of the CAPI controllers, by kubeconfig secret.",
+}, []string{"secret"})
+
+// kubeconfigTokenRotationFailures counts the failures to rotate the token of a kubeconfig secret,
+// e.g. when the TokenRequest is rejected, which lead to an outage of the CAPI controllers once the token expires.
+var kubeconfigTokenRotationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "cluster_capi_operator_kubeconfig_token_rotation_failures_total",
+	Help: "Total number of failures to rotate the token of the kubeconfig of the CAPI controllers, by kubeconfig secret.",
+}, []string{"secret"})
+
+// kubeconfigTokens reports the age and remaining validity of the kubeconfig tokens, computed at scrape time.
+var kubeconfigTokens = &kubeconfigTokenCollector{
+	tokens: map[string]kubeconfigToken{},
+	age: prometheus.NewDesc(
+		"cluster_capi_operator_kubeconfig_token_age_seconds",
+		"Age of the token of the kubeconfig of the CAPI controllers, by kubeconfig secret.",
+		[]string{"secret"}, nil,
+	),
+	remainingValidity: prometheus.NewDesc(
+		"cluster_capi_operator_kubeconfig_token_remaining_validity_seconds",
+		"Remaining validity of the token of the kubeconfig of the CAPI controllers, by kubeconfig secret. Negative once expired.",
+		[]string{"secret"}, nil,
+	),
+}
+
+// kubeconfigToken is the validity of a kubeconfig token.
+type kubeconfigToken struct {
+	created time.Time
+	expiry  time.Time
+}
+
+// kubeconfigTokenCollector collects the age and remaining validity of the kubeconfig tokens.
+// Unlike gauges set on reconcile, they are current at scrape time, even while the controller is stuck.
+type kubeconfigTokenCollector struct {
+	lock              sync.Mutex
+	tokens            map[string]kubeconfigToken
+	age               *prometheus.Desc
+	remainingValidity *prometheus.Desc
+}
+
+// Describe implements prometheus.Collector.
+func (c *kubeconfigTokenCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.age
+	ch <- c.remainingValidity
+}
+
+// Collect implements prometheus.Collector.
+func (c *kubeconfigTokenCollector) Collect(ch chan<- prometheus.Metric) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+
+	for secret, token := range c.tokens {
+		ch <- prometheus.MustNewConstMetric(c.age, prometheus.GaugeValue, now.Sub(token.created).Seconds(), secret)
+		ch <- prometheus.MustNewConstMetric(c.remainingValidity, prometheus.GaugeValue, token.expiry.Sub(now).Seconds(), secret)
+	}
+}
+
+// migrationFailingResources reports the number of MAPI resources whose synchronization with CAPI fails.
+var migrationFailingResources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "cluster_capi_operator_migration_failing_resources",
+	Help: "Number of MAPI resources whose Synchronized condition is False, by kind.",
+}, []string{"kind"})
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(reconcileErrors, infraClusterReady, kubeconfigTokenCreated, kubeconfigTokenExpiry,
+		kubeconfigTokenRotationFailures, kubeconfigTokens, migrationFailingResources)
+}
+
+// RecordReconcileError counts a reconcile error of the controller with the given reason.
+func RecordReconcileError(controller, reason string) {
+	reconcileErrors.WithLabelValues(controller, reason).Inc()
+}
+
+// ErrorReason returns the reason of the error for RecordReconcileError: the reason of the API error it wraps, if any.
+func ErrorReason(err error) string {
+	if reason := apierrors.ReasonForError(err); reason != "" {
+		return string(reason)
+	}
+
+	return reasonUnknown
+}
+
+// SetInfraClusterReady records whether the InfraCluster is ready.
+func SetInfraClusterReady(ready bool) {
+	value := 0.0
+	if ready {
+		value = 1.0
+	}
+
+	infraClusterReady.Set(value)
+}
+
+// SetKubeconfigToken records the creation and expiry times of the token of the kubeconfig secret.
+func SetKubeconfigToken(secret string, created, expiry time.Time) {
+	kubeconfigTokenCreated.WithLabelValues(secret).Set(float64(created.Unix()))
+	kubeconfigTokenExpiry.WithLabelValues(secret).Set(float64(expiry.Unix()))
+
+	kubeconfigTokens.lock.Lock()
+	defer kubeconfigTokens.lock.Unlock()
+
+	kubeconfigTokens.tokens[secret] = kubeconfigToken{created: created, expiry: expiry}
+}
+
+// DeleteKubeconfigToken removes the token metrics of the kubeconfig secret, when it has no token anymore.
+func DeleteKubeconfigToken(secret string) {
+	kubeconfigTokenCreated.DeleteLabelValues(secret)
+	kubeconfigTokenExpiry.DeleteLabelValues(secret)
+
+	kubeconfigTokens.lock.Lock()
+	defer kubeconfigTokens.lock.Unlock()
+
+	delete(kubeconfigTokens.tokens, secret)
+}
+
+// RecordKubeconfigTokenRotationFailure counts a failure to rotate the token of the kubeconfig secret.
+func RecordKubeconfigTokenRotationFailure(secret string) {
+	kubeconfigTokenRotationFailures.WithLabelValues(secret).Inc()
+}
+
+// SetMigrationFailingResources records the number of MAPI resources of the kind failing to synchronize.
+func SetMigrationFailingResources(kind string, failing int) {
+	migrationFailingResources.WithLabelValues(kind).Set(float64(failing))
+}
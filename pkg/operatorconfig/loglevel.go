@@ -0,0 +1,83 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package operatorconfig
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strconv"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const logLevelControllerName = "LogLevelController"
+
+// LogLevelReconciler applies the log level of the ClusterCAPIOperatorConfig to the logger of the operator.
+// The verbosity set by flag is restored when the log level is unset.
+type LogLevelReconciler struct {
+	client.Client
+
+	// Verbosity is the verbosity threshold of the logger, e.g. the one of the textlogger config.
+	Verbosity flag.Value
+
+	defaultVerbosity string
+}
+
+// SetupWithManager sets the LogLevelReconciler up with the given manager.
+func (r *LogLevelReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	r.defaultVerbosity = r.Verbosity.String()
+
+	if err := ctrl.NewControllerManagedBy(mgr).
+		Named(logLevelControllerName).
+		For(New(), builder.WithPredicates(Predicate())).
+		Complete(r); err != nil {
+		return fmt.Errorf("failed to create controller: %w", err)
+	}
+
+	r.Client = mgr.GetClient()
+
+	return nil
+}
+
+// Reconcile sets the verbosity of the logger to the log level of the ClusterCAPIOperatorConfig.
+func (r *LogLevelReconciler) Reconcile(ctx context.Context, _ ctrl.Request) (ctrl.Result, error) {
+	log := ctrl.LoggerFrom(ctx)
+
+	spec, err := GetSpec(ctx, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	verbosity := r.defaultVerbosity
+	if spec.LogLevel != nil {
+		verbosity = strconv.Itoa(*spec.LogLevel)
+	}
+
+	if r.Verbosity.String() == verbosity {
+		return ctrl.Result{}, nil
+	}
+
+	log.Info("Setting log level", "from", r.Verbosity.String(), "to", verbosity)
+
+	if err := r.Verbosity.Set(verbosity); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to set log level to %s: %w", verbosity, err)
+	}
+
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,210 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package operatorconfig reads the ClusterCAPIOperatorConfig, the singleton resource admins use to tune the operator.
+// The operator watches it and applies changes live, without being redeployed.
+//
+// The resource has no generated Go types, it is read as unstructured and its spec decoded into the settings of the
+// controllers using them: the settings shared by the controllers of both operator binaries are decoded into Spec,
+// the settings of the CAPI providers are decoded by the capiinstaller controllers.
+package operatorconfig
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+const (
+	// Name is the name of the singleton ClusterCAPIOperatorConfig, other ClusterCAPIOperatorConfigs are ignored.
+	Name = "cluster"
+)
+
+// GroupVersionKind is the GroupVersionKind of the ClusterCAPIOperatorConfig.
+var GroupVersionKind = schema.GroupVersionKind{
+	Group:   "operator.cluster-capi.openshift.io",
+	Version: "v1alpha1",
+	Kind:    "ClusterCAPIOperatorConfig",
+}
+
+// Spec is the part of the ClusterCAPIOperatorConfig spec shared by the controllers of both operator binaries.
+type Spec struct {
+	// LogLevel is the verbosity of the operator logs, taking precedence over the -v flag.
+	LogLevel *int `json:"logLevel,omitempty"`
+
+	// Controllers toggles the synchronization controllers of the operator.
+	Controllers Controllers `json:"controllers,omitempty"`
+
+	// Features toggles the optional behaviours of the operator, taking precedence over the matching flags.
+	Features Features `json:"features,omitempty"`
+
+	// UserDataPools lists the MachineConfigPools, besides worker, whose user data secrets are synced to the CAPI namespace.
+	UserDataPools []string `json:"userDataPools,omitempty"`
+	// Kubeconfig configures the kubeconfig secrets generated for the CAPI controllers.
+	Kubeconfig Kubeconfig `json:"kubeconfig,omitempty"`
+	// MachineNamespaces lists the namespaces, besides the CAPI namespace, CAPI Machines, MachineSets and MachineDeployments
+	// can be created in, e.g. a namespace watched by an additional CAPI provider.
+	MachineNamespaces []string `json:"machineNamespaces,omitempty"`
+}
+
+// KubeconfigCredentials is how the generated kubeconfigs authenticate.
+type KubeconfigCredentials string
+
+const (
+	// KubeconfigCredentialsToken embeds a bound service account token in the kubeconfigs, rotated by the operator.
+	// This is the default.
+	KubeconfigCredentialsToken KubeconfigCredentials = "Token"
+	// KubeconfigCredentialsExec configures an exec credential plugin in the kubeconfigs, reading the service account token
+	// projected into the pod of the consumer when called, so the credentials never go stale.
+	KubeconfigCredentialsExec KubeconfigCredentials = "Exec"
+)
+
+// Kubeconfig configures the kubeconfig secrets generated for the CAPI controllers.
+type Kubeconfig struct {
+	// Credentials is how the kubeconfigs authenticate, Token when empty.
+	Credentials KubeconfigCredentials `json:"credentials,omitempty"`
+	// Namespaces lists the namespaces, besides the CAPI namespace, the kubeconfig of the core CAPI controllers is copied to,
+	// e.g. the namespace of an IPAM or addon provider.
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// Controllers toggles the synchronization controllers of the operator, e.g. to stop one while debugging it.
+type Controllers struct {
+	MachineSync    ControllerConfig `json:"machineSync,omitempty"`
+	MachineSetSync ControllerConfig `json:"machineSetSync,omitempty"`
+}
+
+// ControllerConfig toggles a controller of the operator.
+type ControllerConfig struct {
+	// Disabled stops the controller from reconciling, the resources it manages are left as they are.
+	Disabled bool `json:"disabled,omitempty"`
+}
+
+// Features toggles the optional behaviours of the operator.
+// Each field that is set takes precedence over the matching flag of the operator binary.
+type Features struct {
+	// AzureManagedBootDiagnostics matches the --azure-managed-boot-diagnostics flag of the machine-api-migration binary.
+	AzureManagedBootDiagnostics *bool `json:"azureManagedBootDiagnostics,omitempty"`
+
+	// ControlPlaneMigration matches the --control-plane-migration flag of the machine-api-migration binary.
+	ControlPlaneMigration *bool `json:"controlPlaneMigration,omitempty"`
+}
+
+// New returns an empty ClusterCAPIOperatorConfig, e.g. to watch ClusterCAPIOperatorConfigs.
+func New() *unstructured.Unstructured {
+	u := &unstructured.Unstructured{}
+	u.SetGroupVersionKind(GroupVersionKind)
+
+	return u
+}
+
+// Get returns the ClusterCAPIOperatorConfig.
+// Nil is returned when it does not exist, including when its CRD is not installed, e.g. in tests.
+func Get(ctx context.Context, cl client.Reader) (*unstructured.Unstructured, error) {
+	u := New()
+	if err := cl.Get(ctx, client.ObjectKey{Name: Name}, u); kerrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to get %s %s: %w", GroupVersionKind.Kind, Name, err)
+	}
+
+	return u, nil
+}
+
+// DecodeSpec decodes the spec of the ClusterCAPIOperatorConfig into the given settings.
+// Fields of the spec that the settings do not have are ignored, as they are the settings of other controllers.
+func DecodeSpec(u *unstructured.Unstructured, into any) error {
+	spec, _, err := unstructured.NestedMap(u.Object, "spec")
+	if err != nil {
+		return fmt.Errorf("incorrect spec of %s %s: %w", GroupVersionKind.Kind, u.GetName(), err)
+	}
+
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return fmt.Errorf("unable to serialize spec of %s %s: %w", GroupVersionKind.Kind, u.GetName(), err)
+	}
+
+	if err := json.Unmarshal(data, into); err != nil {
+		return fmt.Errorf("unable to parse spec of %s %s: %w", GroupVersionKind.Kind, u.GetName(), err)
+	}
+
+	return nil
+}
+
+// GetSpec returns the settings of the ClusterCAPIOperatorConfig shared by the controllers of the operator.
+// An empty Spec is returned when it does not exist.
+func GetSpec(ctx context.Context, cl client.Reader) (Spec, error) {
+	u, err := Get(ctx, cl)
+	if err != nil || u == nil {
+		return Spec{}, err
+	}
+
+	spec := Spec{}
+	if err := DecodeSpec(u, &spec); err != nil {
+		return Spec{}, err
+	}
+
+	return spec, nil
+}
+
+// Predicate filters the events of the singleton ClusterCAPIOperatorConfig.
+func Predicate() predicate.Funcs {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetName() == Name
+	})
+}
+
+// EnqueueAll maps a change of the ClusterCAPIOperatorConfig to requests for all the objects of the given list kind
+// in the given namespace, e.g. to reconcile all of them when a controller is enabled again.
+func EnqueueAll(cl client.Reader, list client.ObjectList, namespace string) handler.MapFunc {
+	return func(ctx context.Context, _ client.Object) []reconcile.Request {
+		objs, ok := list.DeepCopyObject().(client.ObjectList)
+		if !ok {
+			return nil
+		}
+
+		if err := cl.List(ctx, objs, client.InNamespace(namespace)); err != nil {
+			return nil
+		}
+
+		items, err := meta.ExtractList(objs)
+		if err != nil {
+			return nil
+		}
+
+		requests := make([]reconcile.Request, 0, len(items))
+
+		for _, item := range items {
+			obj, ok := item.(client.Object)
+			if !ok {
+				continue
+			}
+
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+		}
+
+		return requests
+	}
+}
@@ -18,10 +18,9 @@ package operatorstatus
 import (
 	"context"
 	"fmt"
-	"reflect"
+	"sort"
 	"strings"
 
-	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -46,6 +45,10 @@ const (
 
 	// ReasonSyncFailed is the reason for the condition when the operator failed to sync resources.
 	ReasonSyncFailed = "SyncingFailed"
+
+	// controllerConditionInfix precedes the type of the conditions of the individual controllers,
+	// e.g. InfraClusterControllerDegraded, which are aggregated into the conditions of the ClusterOperator.
+	controllerConditionInfix = "Controller"
 )
 
 // ClusterOperatorStatusClient is a client for managing the status of the ClusterOperator object.
@@ -57,7 +60,8 @@ type ClusterOperatorStatusClient struct {
 }
 
 // SetStatusAvailable sets the Available condition to True, with the given reason
-// and message, and sets both the Progressing and Degraded conditions to False.
+// and message.
+// The Degraded, Progressing and Upgradeable conditions are aggregated from the conditions of the controllers, see SyncStatus.
 func (r *ClusterOperatorStatusClient) SetStatusAvailable(ctx context.Context, availableConditionMsg string) error {
 	log := ctrl.LoggerFrom(ctx)
 
@@ -74,9 +78,6 @@ func (r *ClusterOperatorStatusClient) SetStatusAvailable(ctx context.Context, av
 	conds := []configv1.ClusterOperatorStatusCondition{
 		NewClusterOperatorStatusCondition(configv1.OperatorAvailable, configv1.ConditionTrue, ReasonAsExpected,
 			availableConditionMsg),
-		NewClusterOperatorStatusCondition(configv1.OperatorProgressing, configv1.ConditionFalse, ReasonAsExpected, ""),
-		NewClusterOperatorStatusCondition(configv1.OperatorDegraded, configv1.ConditionFalse, ReasonAsExpected, ""),
-		NewClusterOperatorStatusCondition(configv1.OperatorUpgradeable, configv1.ConditionTrue, ReasonAsExpected, ""),
 	}
 
 	// Update cluster conditions only if they have been changed
@@ -84,7 +85,7 @@ func (r *ClusterOperatorStatusClient) SetStatusAvailable(ctx context.Context, av
 		if !v1helpers.IsStatusConditionPresentAndEqual(co.Status.Conditions, cond.Type, cond.Status) {
 			log.V(2).Info("syncing status: available")
 
-			co.Status.Versions = []configv1.OperandVersion{{Name: controllers.OperatorVersionKey, Version: r.ReleaseVersion}}
+			r.SetOperatorVersion(co)
 
 			return r.SyncStatus(ctx, co, conds)
 		}
@@ -93,45 +94,31 @@ func (r *ClusterOperatorStatusClient) SetStatusAvailable(ctx context.Context, av
 	return nil
 }
 
-// SetStatusDegraded sets the Degraded condition to True, with the given reason and
-// message, and sets the upgradeable condition.  It does not modify any existing
-// Available or Progressing conditions.
-func (r *ClusterOperatorStatusClient) SetStatusDegraded(ctx context.Context, reconcileErr error) error {
-	log := ctrl.LoggerFrom(ctx)
-
-	co, err := r.GetOrCreateClusterOperator(ctx)
-	if err != nil {
-		log.Error(err, "unable to set cluster operator status degraded")
-		return err
-	}
-
-	desiredVersions := []configv1.OperandVersion{{Name: controllers.OperatorVersionKey, Version: r.ReleaseVersion}}
-	currentVersions := co.Status.Versions
+// SetOperatorVersion sets the version of the operator in the versions of the ClusterOperator,
+// keeping the versions of its operands.
+func (r *ClusterOperatorStatusClient) SetOperatorVersion(co *configv1.ClusterOperator) {
+	operatorVersion := configv1.OperandVersion{Name: controllers.OperatorVersionKey, Version: r.ReleaseVersion}
 
-	var message string
-	if !reflect.DeepEqual(desiredVersions, currentVersions) {
-		message = fmt.Sprintf("Failed when progressing towards %s because %e", printOperandVersions(desiredVersions), reconcileErr)
-	} else {
-		message = fmt.Sprintf("Failed to resync for %s because %e", printOperandVersions(desiredVersions), reconcileErr)
-	}
-
-	conds := []configv1.ClusterOperatorStatusCondition{
-		NewClusterOperatorStatusCondition(configv1.OperatorDegraded, configv1.ConditionTrue,
-			ReasonSyncFailed, message),
-		NewClusterOperatorStatusCondition(configv1.OperatorUpgradeable, configv1.ConditionFalse, ReasonAsExpected, ""),
+	for i := range co.Status.Versions {
+		if co.Status.Versions[i].Name == controllers.OperatorVersionKey {
+			co.Status.Versions[i] = operatorVersion
+			return
+		}
 	}
 
-	// Update cluster conditions only if they have been changed
-	for _, cond := range conds {
-		if !v1helpers.IsStatusConditionPresentAndEqual(co.Status.Conditions, cond.Type, cond.Status) {
-			r.Recorder.Eventf(co, corev1.EventTypeWarning, "Status degraded", reconcileErr.Error())
-			log.V(2).Info("syncing status: degraded", "message", message)
+	co.Status.Versions = append([]configv1.OperandVersion{operatorVersion}, co.Status.Versions...)
+}
 
-			return r.SyncStatus(ctx, co, conds)
-		}
+// RecordEvent records an Event on the ClusterOperator, as an audit trail of the significant actions of the operator.
+// Failing to record the Event does not fail the action, so the failure is only logged.
+func (r *ClusterOperatorStatusClient) RecordEvent(ctx context.Context, eventType, reason, message string) {
+	co := &configv1.ClusterOperator{}
+	if err := r.Client.Get(ctx, client.ObjectKey{Name: controllers.ClusterOperatorName}, co); err != nil {
+		ctrl.LoggerFrom(ctx).Error(err, "unable to record event on cluster operator", "reason", reason, "message", message)
+		return
 	}
 
-	return nil
+	r.Recorder.Event(co, eventType, reason, message)
 }
 
 // GetOrCreateClusterOperator is responsible for fetching the cluster operator should it exist,
@@ -164,12 +151,17 @@ func (r *ClusterOperatorStatusClient) GetOrCreateClusterOperator(ctx context.Con
 	return co, nil
 }
 
-// SyncStatus applies the new condition to the ClusterOperator object.
+// SyncStatus applies the new condition to the ClusterOperator object, and aggregates
+// the Degraded, Progressing and Upgradeable conditions of the controllers into those of the ClusterOperator.
 func (r *ClusterOperatorStatusClient) SyncStatus(ctx context.Context, co *configv1.ClusterOperator, conds []configv1.ClusterOperatorStatusCondition) error {
 	for _, c := range conds {
 		v1helpers.SetStatusCondition(&co.Status.Conditions, c)
 	}
 
+	v1helpers.SetStatusCondition(&co.Status.Conditions, aggregateCondition(co.Status.Conditions, configv1.OperatorDegraded, configv1.ConditionTrue))
+	v1helpers.SetStatusCondition(&co.Status.Conditions, aggregateCondition(co.Status.Conditions, configv1.OperatorProgressing, configv1.ConditionTrue))
+	v1helpers.SetStatusCondition(&co.Status.Conditions, aggregateCondition(co.Status.Conditions, configv1.OperatorUpgradeable, configv1.ConditionFalse))
+
 	if !equality.Semantic.DeepEqual(co.Status.RelatedObjects, r.relatedObjects()) {
 		co.Status.RelatedObjects = r.relatedObjects()
 	}
@@ -187,7 +179,7 @@ func (r *ClusterOperatorStatusClient) relatedObjects() []configv1.ObjectReferenc
 		{Resource: "namespaces", Name: controllers.DefaultManagedNamespace},
 		{Group: configv1.GroupName, Resource: "clusteroperators", Name: controllers.ClusterOperatorName},
 		{Resource: "namespaces", Name: r.ManagedNamespace},
-		{Group: "", Resource: "serviceaccounts", Name: "cluster-capi-operator"},
+		{Group: "", Resource: "serviceaccounts", Name: controllers.OperatorServiceAccountName},
 		{Group: "", Resource: "configmaps", Name: "cluster-capi-operator-images"},
 		{Group: "apps", Resource: "deployments", Name: "cluster-capi-operator"},
 	}
@@ -206,11 +198,38 @@ func NewClusterOperatorStatusCondition(conditionType configv1.ClusterStatusCondi
 	}
 }
 
-func printOperandVersions(versions []configv1.OperandVersion) string {
-	versionsOutput := []string{}
-	for _, operand := range versions {
-		versionsOutput = append(versionsOutput, fmt.Sprintf("%s: %s", operand.Name, operand.Version))
+// aggregateCondition returns the condition of the given type of the ClusterOperator, which has the abnormal status
+// when the same condition of any of the controllers, e.g. InfraClusterControllerDegraded for Degraded, has it.
+// Its reason and message point at the controllers.
+func aggregateCondition(conditions []configv1.ClusterOperatorStatusCondition, conditionType configv1.ClusterStatusConditionType,
+	abnormalStatus configv1.ConditionStatus) configv1.ClusterOperatorStatusCondition {
+	suffix := controllerConditionInfix + string(conditionType)
+	matching := []configv1.ClusterOperatorStatusCondition{}
+
+	for _, cond := range conditions {
+		if strings.HasSuffix(string(cond.Type), suffix) && cond.Status == abnormalStatus {
+			matching = append(matching, cond)
+		}
+	}
+
+	if len(matching) == 0 {
+		normalStatus := configv1.ConditionFalse
+		if abnormalStatus == configv1.ConditionFalse {
+			normalStatus = configv1.ConditionTrue
+		}
+
+		return NewClusterOperatorStatusCondition(conditionType, normalStatus, ReasonAsExpected, "")
+	}
+
+	sort.Slice(matching, func(i, j int) bool { return matching[i].Type < matching[j].Type })
+
+	reasons := make([]string, 0, len(matching))
+	messages := make([]string, 0, len(matching))
+
+	for _, cond := range matching {
+		reasons = append(reasons, fmt.Sprintf("%s_%s", strings.TrimSuffix(string(cond.Type), string(conditionType)), cond.Reason))
+		messages = append(messages, fmt.Sprintf("%s: %s", cond.Type, cond.Message))
 	}
 
-	return strings.Join(versionsOutput, ", ")
+	return NewClusterOperatorStatusCondition(conditionType, abnormalStatus, strings.Join(reasons, "::"), strings.Join(messages, "\n"))
 }
@@ -23,6 +23,8 @@ import (
 	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/openshift/cluster-capi-operator/pkg/operatorconfig"
 )
 
 var (
@@ -70,6 +72,18 @@ var (
 	// fakeAWSClusterCRD is a fake AWSCluster CRD.
 	fakeAWSClusterCRD = generateCRD(v1beta2InfrastructureGroupVersion.WithKind(fakeAWSClusterKind))
 
+	// fakeAWSMachineKind is the Kind for the AWSMachine.
+	fakeAWSMachineKind = "AWSMachine"
+
+	// fakeAWSMachineCRD is a fake AWSMachine CRD.
+	fakeAWSMachineCRD = generateCRD(v1beta2InfrastructureGroupVersion.WithKind(fakeAWSMachineKind))
+
+	// fakeAWSMachineTemplateKind is the Kind for the AWSMachineTemplate.
+	fakeAWSMachineTemplateKind = "AWSMachineTemplate"
+
+	// fakeAWSMachineTemplateCRD is a fake AWSMachineTemplate CRD.
+	fakeAWSMachineTemplateCRD = generateCRD(v1beta2InfrastructureGroupVersion.WithKind(fakeAWSMachineTemplateKind))
+
 	// fakeAzureClusterKind is the Kind for the AWSCluster.
 	fakeAzureClusterKind = "AzureCluster"
 
@@ -81,8 +95,23 @@ var (
 
 	// fakeGCPClusterCRD is a fake GCPCluster CRD.
 	fakeGCPClusterCRD = generateCRD(v1beta2InfrastructureGroupVersion.WithKind(fakeGCPClusterKind))
+
+	// fakeClusterCAPIOperatorConfigCRD is a fake ClusterCAPIOperatorConfig CRD.
+	fakeClusterCAPIOperatorConfigCRD = withClusterScope(generateCRD(operatorconfig.GroupVersionKind))
+
+	// fakeMachineConfigPoolCRD is a fake MachineConfigPool CRD.
+	fakeMachineConfigPoolCRD = withClusterScope(generateCRD(schema.GroupVersionKind{
+		Group: "machineconfiguration.openshift.io", Version: "v1", Kind: "MachineConfigPool",
+	}))
 )
 
+// withClusterScope makes the CRD cluster scoped, e.g. for singleton configuration resources.
+func withClusterScope(crd *apiextensionsv1.CustomResourceDefinition) *apiextensionsv1.CustomResourceDefinition {
+	crd.Spec.Scope = apiextensionsv1.ClusterScoped
+
+	return crd
+}
+
 func generateCRD(gvk schema.GroupVersionKind) *apiextensionsv1.CustomResourceDefinition {
 	shouldPreserveUnknownFields := true
 
@@ -62,8 +62,12 @@ func StartEnvTest(testEnv *envtest.Environment) (*rest.Config, client.Client, er
 		fakeMachineCRD,
 		fakeMachineSetCRD,
 		fakeAWSClusterCRD,
+		fakeAWSMachineCRD,
+		fakeAWSMachineTemplateCRD,
 		fakeAzureClusterCRD,
 		fakeGCPClusterCRD,
+		fakeClusterCAPIOperatorConfigCRD,
+		fakeMachineConfigPoolCRD,
 	}
 	testEnv.CRDDirectoryPaths = []string{
 		path.Join(root, "vendor", "github.com", "openshift", "api", "config", "v1", "zz_generated.crd-manifests"),
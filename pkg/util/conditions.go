@@ -0,0 +1,59 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SetMAPICondition adds or updates the given condition in a list of Machine API conditions.
+// The LastTransitionTime is only bumped when the status of an existing condition changes.
+func SetMAPICondition(conditions []machinev1beta1.Condition, condition machinev1beta1.Condition) []machinev1beta1.Condition {
+	now := metav1.Now()
+
+	for i := range conditions {
+		if conditions[i].Type != condition.Type {
+			continue
+		}
+
+		if conditions[i].Status == condition.Status && !conditions[i].LastTransitionTime.IsZero() {
+			condition.LastTransitionTime = conditions[i].LastTransitionTime
+		} else {
+			condition.LastTransitionTime = now
+		}
+
+		conditions[i] = condition
+
+		return conditions
+	}
+
+	condition.LastTransitionTime = now
+
+	return append(conditions, condition)
+}
+
+// GetMAPICondition returns the condition with the given type from a list of Machine API conditions,
+// or nil if it is not present.
+func GetMAPICondition(conditions []machinev1beta1.Condition, conditionType machinev1beta1.ConditionType) *machinev1beta1.Condition {
+	for i := range conditions {
+		if conditions[i].Type == conditionType {
+			return &conditions[i]
+		}
+	}
+
+	return nil
+}
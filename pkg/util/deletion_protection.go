@@ -0,0 +1,99 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// DeletionProtectionFinalizer is set on the Cluster and the InfraCluster of the managed namespace,
+// so they are not removed while Machines still reference them, even when the deletion webhooks are bypassed.
+const DeletionProtectionFinalizer = "cluster-capi-operator.openshift.io/deletion-protection"
+
+var errUnableToDeepCopy = errors.New("unable to create a deep copy of the object")
+
+// ClusterNameOf returns the name of the Cluster the object belongs to: the value of its cluster name label,
+// or its own name, as the Cluster and the InfraCluster are both named after the infrastructure name.
+func ClusterNameOf(obj client.Object) string {
+	if clusterName, ok := obj.GetLabels()[clusterv1.ClusterNameLabel]; ok && clusterName != "" {
+		return clusterName
+	}
+
+	return obj.GetName()
+}
+
+// MachinesReferencingCluster returns the sorted names of the Machines of the namespace which belong to the Cluster.
+func MachinesReferencingCluster(ctx context.Context, cl client.Reader, namespace, clusterName string) ([]string, error) {
+	machines := &clusterv1.MachineList{}
+	if err := cl.List(ctx, machines, client.InNamespace(namespace)); err != nil {
+		return nil, fmt.Errorf("unable to list Machines: %w", err)
+	}
+
+	names := []string{}
+
+	for _, machine := range machines.Items {
+		if machine.Spec.ClusterName == clusterName {
+			names = append(names, machine.Name)
+		}
+	}
+
+	slices.Sort(names)
+
+	return names, nil
+}
+
+// ReconcileDeletionProtection adds the DeletionProtectionFinalizer to the object, or removes it once the object
+// is being deleted and no Machine references its Cluster anymore.
+// It returns the Machines still referencing the Cluster of an object being deleted.
+func ReconcileDeletionProtection(ctx context.Context, cl client.Client, obj client.Object) ([]string, error) {
+	patchBase, ok := obj.DeepCopyObject().(client.Object)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", errUnableToDeepCopy, obj)
+	}
+
+	if obj.GetDeletionTimestamp().IsZero() {
+		if !controllerutil.AddFinalizer(obj, DeletionProtectionFinalizer) {
+			return nil, nil
+		}
+	} else {
+		machines, err := MachinesReferencingCluster(ctx, cl, obj.GetNamespace(), ClusterNameOf(obj))
+		if err != nil {
+			return nil, err
+		}
+
+		if len(machines) > 0 {
+			return machines, nil
+		}
+
+		if !controllerutil.RemoveFinalizer(obj, DeletionProtectionFinalizer) {
+			return nil, nil
+		}
+	}
+
+	// The finalizers are replaced as a whole by a merge patch, the optimistic lock keeps the ones added concurrently.
+	if err := cl.Patch(ctx, obj, client.MergeFromWithOptions(patchBase, client.MergeFromWithOptimisticLock{})); err != nil {
+		return nil, fmt.Errorf("unable to patch the finalizers of %s: %w", obj.GetName(), err)
+	}
+
+	return nil, nil
+}
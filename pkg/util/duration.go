@@ -32,3 +32,7 @@ var (
 	// RetryPeriod is the default duration for the leader election retrial.
 	RetryPeriod = metav1.Duration{Duration: 26 * time.Second}
 )
+
+// GracefulShutdownTimeout bounds the time the managers wait for the in-flight reconciles to finish on shutdown,
+// before releasing their leader election lease. It must stay below the termination grace period of the operator pod.
+const GracefulShutdownTimeout = 30 * time.Second
@@ -0,0 +1,76 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	configv1 "github.com/openshift/api/config/v1"
+	configv1client "github.com/openshift/client-go/config/clientset/versioned"
+	configinformers "github.com/openshift/client-go/config/informers/externalversions"
+	featuregates "github.com/openshift/library-go/pkg/operator/configobserver/featuregates"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
+)
+
+// errTimedOutWaitingForFeatureGates is returned when the feature gates are not initialized within the timeout.
+var errTimedOutWaitingForFeatureGates = errors.New("timed out waiting for feature gates to be initialized")
+
+// GetFeatureGates is used to fetch the current feature gates from the cluster.
+// The returned accessor exits the process when the feature gates change, so they are observed again on restart.
+func GetFeatureGates(cfg *rest.Config, component string) (featuregates.FeatureGateAccess, error) {
+	desiredVersion := GetReleaseVersion()
+	missingVersion := "0.0.1-snapshot"
+
+	configClient, err := configv1client.NewForConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create config client: %w", err)
+	}
+
+	configInformers := configinformers.NewSharedInformerFactory(configClient, 10*time.Minute)
+
+	// By default, this will exit(0) if the featuregates change.
+	featureGateAccessor := featuregates.NewFeatureGateAccess(
+		desiredVersion, missingVersion,
+		configInformers.Config().V1().ClusterVersions(),
+		configInformers.Config().V1().FeatureGates(),
+		events.NewLoggingEventRecorder(component),
+	)
+	go featureGateAccessor.Run(context.Background())
+	go configInformers.Start(context.Background().Done())
+
+	select {
+	case <-featureGateAccessor.InitialFeatureGatesObserved():
+		featureGates, _ := featureGateAccessor.CurrentFeatureGates()
+		klog.Infof("FeatureGates initialized: %v", featureGates.KnownFeatures())
+	case <-time.After(1 * time.Minute):
+		return nil, errTimedOutWaitingForFeatureGates
+	}
+
+	return featureGateAccessor, nil
+}
+
+// IsFeatureGateEnabled returns whether the feature gate is enabled.
+// Unlike featuregates.FeatureGate.Enabled, it does not panic on feature gates the cluster does not know yet,
+// which are reported as disabled.
+func IsFeatureGateEnabled(featureGates featuregates.FeatureGate, name configv1.FeatureGateName) bool {
+	return slices.Contains(featureGates.KnownFeatures(), name) && featureGates.Enabled(name)
+}
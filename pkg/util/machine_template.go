@@ -0,0 +1,63 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// MachineTemplateBaseNameAnnotation is set on the rotated InfraMachineTemplates to the name they are rotated from,
+	// so successive rotations replace the hash suffix instead of appending to it.
+	MachineTemplateBaseNameAnnotation = "cluster-capi-operator.openshift.io/machine-template-base-name"
+
+	// machineTemplateHashLength is the length of the spec hash suffixed to the name of the rotated InfraMachineTemplates.
+	machineTemplateHashLength = 8
+)
+
+// MachineTemplateSpec returns the spec of the InfraMachineTemplate, as an unstructured map.
+func MachineTemplateSpec(template client.Object) (map[string]interface{}, error) {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(template)
+	if err != nil {
+		return nil, fmt.Errorf("unable to convert InfraMachineTemplate %s to unstructured: %w", template.GetName(), err)
+	}
+
+	spec, _ := u["spec"].(map[string]interface{})
+
+	return spec, nil
+}
+
+// RotatedMachineTemplateName returns the name of the InfraMachineTemplate with the spec: the base name suffixed with the hash of the spec.
+// The base name is truncated so the name remains a valid DNS subdomain.
+// As InfraMachineTemplates are immutable, changing their spec means creating a template under a new name, and referencing it instead.
+func RotatedMachineTemplateName(baseName string, spec map[string]interface{}) (string, error) {
+	rawSpec, err := json.Marshal(spec)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal the spec of InfraMachineTemplate %s: %w", baseName, err)
+	}
+
+	hash := sha256.Sum256(rawSpec)
+	suffix := "-" + hex.EncodeToString(hash[:])[:machineTemplateHashLength]
+
+	return baseName[:min(len(baseName), validation.DNS1123SubdomainMaxLength-len(suffix))] + suffix, nil
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package util
+
+import (
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ManagedTagsAnnotation lists the keys of the tags last synchronized from the authoritative side,
+// so tags removed there can be told apart from tags added by users day-2, and removed too.
+const ManagedTagsAnnotation = "cluster-capi-operator.openshift.io/managed-tags"
+
+// SyncTags returns the non-authoritative tags updated to match the authoritative ones.
+// Tags only present on the non-authoritative side, such as tags added by users day-2, are kept,
+// unless their key was previously managed, i.e. the tag was removed from the authoritative side.
+// When a key is present on both sides the authoritative value wins.
+func SyncTags(authoritative, nonAuthoritative map[string]string, previouslyManaged []string) map[string]string {
+	synced := make(map[string]string, len(authoritative)+len(nonAuthoritative))
+
+	for key, value := range nonAuthoritative {
+		if !slices.Contains(previouslyManaged, key) {
+			synced[key] = value
+		}
+	}
+
+	for key, value := range authoritative {
+		synced[key] = value
+	}
+
+	return synced
+}
+
+// GetManagedTagKeys returns the keys of the tags last synchronized onto the object, from its managed tags annotation.
+func GetManagedTagKeys(obj client.Object) []string {
+	value := obj.GetAnnotations()[ManagedTagsAnnotation]
+	if value == "" {
+		return nil
+	}
+
+	return strings.Split(value, ",")
+}
+
+// SetManagedTagKeys records the keys of the synchronized tags in the managed tags annotation of the object.
+// AWS tag keys cannot contain commas, so the keys are comma separated.
+func SetManagedTagKeys(obj client.Object, tags map[string]string) {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+
+	slices.Sort(keys)
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	annotations[ManagedTagsAnnotation] = strings.Join(keys, ",")
+	obj.SetAnnotations(annotations)
+}
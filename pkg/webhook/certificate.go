@@ -0,0 +1,75 @@
+// Copyright 2024 Red Hat, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package webhook
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"sync"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+)
+
+const (
+	servingCertName = "tls.crt"
+	servingKeyName  = "tls.key"
+)
+
+// WatchCertificateRenewal watches the serving certificate of the webhook server in the certificate directory,
+// and calls onRenewal once the certificate is renewed, e.g. by the service CA operator.
+// The webhook server reloads the certificate on its own, the watch only reports the renewal.
+func WatchCertificateRenewal(mgr ctrl.Manager, certDir string, onRenewal func(cert *x509.Certificate)) error {
+	certWatcher, err := certwatcher.New(filepath.Join(certDir, servingCertName), filepath.Join(certDir, servingKeyName))
+	if err != nil {
+		return fmt.Errorf("unable to watch webhook serving certificate: %w", err)
+	}
+
+	var (
+		lock   sync.Mutex
+		serial *big.Int
+	)
+
+	// The callback is called with the current certificate when registered, then every time the files are read,
+	// so only a change of serial number is a renewal.
+	certWatcher.RegisterCallback(func(cert tls.Certificate) {
+		if len(cert.Certificate) == 0 {
+			return
+		}
+
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			ctrl.Log.Error(err, "unable to parse webhook serving certificate")
+			return
+		}
+
+		lock.Lock()
+		renewed := serial != nil && serial.Cmp(leaf.SerialNumber) != 0
+		serial = leaf.SerialNumber
+		lock.Unlock()
+
+		if renewed {
+			onRenewal(leaf)
+		}
+	})
+
+	if err := mgr.Add(certWatcher); err != nil {
+		return fmt.Errorf("unable to add webhook serving certificate watcher to manager: %w", err)
+	}
+
+	return nil
+}
@@ -61,11 +61,11 @@ func (r *ClusterWebhook) SetupWebhookWithManager(mgr ctrl.Manager) error {
 var _ webhook.CustomValidator = &ClusterWebhook{}
 
 // fetchInfrastructureObject fetches the Infrastructure object from the cluster.
-func (r *ClusterWebhook) fetchInfrastructureObject(ctx context.Context) (*configv1.Infrastructure, error) {
+func fetchInfrastructureObject(ctx context.Context, cl client.Client) (*configv1.Infrastructure, error) {
 	infrastructureObjectKey := client.ObjectKey{Name: "cluster", Namespace: "default"}
 
 	infrastructureObject := configv1.Infrastructure{}
-	if err := r.client.Get(ctx, infrastructureObjectKey, &infrastructureObject); err != nil {
+	if err := cl.Get(ctx, infrastructureObjectKey, &infrastructureObject); err != nil {
 		return nil, fmt.Errorf("failed to fetch Infrastructure object: %w", err)
 	}
 
@@ -78,7 +78,7 @@ func (r *ClusterWebhook) validateClusterName(ctx context.Context, cluster *v1bet
 		return nil
 	}
 
-	infrastructureObject, err := r.fetchInfrastructureObject(ctx)
+	infrastructureObject, err := fetchInfrastructureObject(ctx, r.client)
 	if err != nil {
 		return fmt.Errorf("cluster in %s namespace must be named <infrastructure_id>. Failed to obtain name from Infrastructure object for validation: %w", openshiftCAPINamespace, err)
 	}
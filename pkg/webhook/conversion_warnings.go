@@ -0,0 +1,181 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/cluster-capi-operator/pkg/conversion/capi2mapi"
+)
+
+var errInfraClusterRefNotSet = errors.New("the Cluster has no infrastructureRef")
+
+// conversionWarnings returns a warning for every field of a spec change to a CAPI Machine or MachineSet mirrored to MAPI
+// that won't be reflected in the MAPI mirror, as reported by the capi2mapi conversion, so the drift is understood up front.
+// The change is not rejected, the synchronization controllers report the conversion failures too.
+// No warnings are returned when the conversion can't be attempted, e.g. when the infrastructure objects don't exist yet.
+// The conversion is given copies of the objects, as it moves some of their labels and annotations.
+func (r *MirrorWebhook) conversionWarnings(ctx context.Context, oldObj, newObj runtime.Object) admission.Warnings {
+	// convert returns the warnings and the error of the conversion, or an error when the conversion can't be attempted.
+	var (
+		name       string
+		mapiMirror client.Object
+		convert    func() (warnings []string, conversionErr error, err error)
+	)
+
+	switch newObj := newObj.(type) {
+	case *v1beta1.Machine:
+		oldMachine, ok := oldObj.(*v1beta1.Machine)
+		if !ok || newObj.Namespace != openshiftCAPINamespace || equality.Semantic.DeepEqual(oldMachine.Spec, newObj.Spec) {
+			return nil
+		}
+
+		name = newObj.Name
+		mapiMirror = &machinev1beta1.Machine{}
+		convert = func() ([]string, error, error) {
+			infraMachine, infraCluster, err := r.conversionInfraObjects(ctx, newObj.Spec.InfrastructureRef, newObj.Spec.ClusterName)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			conversion, err := capi2mapi.FromMachineAndInfraMachineAndInfraCluster(newObj.DeepCopy(), infraMachine, infraCluster)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			_, warnings, conversionErr := conversion.ToMachine()
+
+			return warnings, conversionErr, nil
+		}
+	case *v1beta1.MachineSet:
+		oldMachineSet, ok := oldObj.(*v1beta1.MachineSet)
+		if !ok || newObj.Namespace != openshiftCAPINamespace || equality.Semantic.DeepEqual(oldMachineSet.Spec, newObj.Spec) {
+			return nil
+		}
+
+		name = newObj.Name
+		mapiMirror = &machinev1beta1.MachineSet{}
+		convert = func() ([]string, error, error) {
+			infraMachineTemplate, infraCluster, err := r.conversionInfraObjects(ctx, newObj.Spec.Template.Spec.InfrastructureRef, newObj.Spec.ClusterName)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			conversion, err := capi2mapi.FromMachineSetAndInfraMachineTemplateAndInfraCluster(newObj.DeepCopy(), infraMachineTemplate, infraCluster)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			_, warnings, conversionErr := conversion.ToMachineSet()
+
+			return warnings, conversionErr, nil
+		}
+	default:
+		return nil
+	}
+
+	log := ctrl.LoggerFrom(ctx)
+
+	if err := r.client.Get(ctx, client.ObjectKey{Namespace: openshiftMAPINamespace, Name: name}, mapiMirror); err != nil {
+		if !apierrors.IsNotFound(err) {
+			log.Error(err, "Unable to get the Machine API mirror to check the conversion", "name", name)
+		}
+
+		return nil
+	}
+
+	conversionWarnings, conversionErr, err := convert()
+	if err != nil {
+		log.Error(err, "Unable to convert to Machine API to check the conversion", "name", name)
+		return nil
+	}
+
+	mirror := fmt.Sprintf("the Machine API mirror %s/%s", openshiftMAPINamespace, name)
+
+	warnings := admission.Warnings{}
+
+	for _, warning := range conversionWarnings {
+		warnings = append(warnings, fmt.Sprintf("%s, in %s", warning, mirror))
+	}
+
+	var aggregate utilerrors.Aggregate
+	if errors.As(conversionErr, &aggregate) {
+		for _, err := range utilerrors.Flatten(aggregate).Errors() {
+			warnings = append(warnings, fmt.Sprintf("%v: not reflected in %s, which is not synchronized while it is set", err, mirror))
+		}
+	} else if conversionErr != nil {
+		warnings = append(warnings, fmt.Sprintf("%v: not reflected in %s, which is not synchronized while it is set", conversionErr, mirror))
+	}
+
+	return warnings
+}
+
+// conversionInfraObjects returns the InfraMachine or InfraMachineTemplate of the reference, and the InfraCluster of the Cluster,
+// the capi2mapi conversion of a Machine or MachineSet needs.
+func (r *MirrorWebhook) conversionInfraObjects(ctx context.Context, ref corev1.ObjectReference, clusterName string) (client.Object, client.Object, error) {
+	infraMachine, err := r.getReferencedObject(ctx, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cluster := &v1beta1.Cluster{}
+	if err := r.client.Get(ctx, client.ObjectKey{Namespace: openshiftCAPINamespace, Name: clusterName}, cluster); err != nil {
+		return nil, nil, fmt.Errorf("unable to get Cluster %s: %w", clusterName, err)
+	}
+
+	if cluster.Spec.InfrastructureRef == nil {
+		return nil, nil, fmt.Errorf("%w: %s", errInfraClusterRefNotSet, clusterName)
+	}
+
+	infraCluster, err := r.getReferencedObject(ctx, *cluster.Spec.InfrastructureRef)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return infraMachine, infraCluster, nil
+}
+
+// getReferencedObject returns the typed object of the CAPI namespace the reference points to.
+func (r *MirrorWebhook) getReferencedObject(ctx context.Context, ref corev1.ObjectReference) (client.Object, error) {
+	obj, err := r.client.Scheme().New(ref.GroupVersionKind())
+	if err != nil {
+		return nil, fmt.Errorf("unable to create %s: %w", ref.Kind, err)
+	}
+
+	clientObj, ok := obj.(client.Object)
+	if !ok {
+		panic("expected to get an object implementing client.Object")
+	}
+
+	if err := r.client.Get(ctx, client.ObjectKey{Namespace: openshiftCAPINamespace, Name: ref.Name}, clientObj); err != nil {
+		return nil, fmt.Errorf("unable to get %s %s: %w", ref.Kind, ref.Name, err)
+	}
+
+	return clientObj, nil
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/utils/ptr"
+	awsv1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	"sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("MirrorWebhook conversion warnings", func() {
+	const (
+		name        = "worker"
+		clusterName = "cluster-abc12"
+	)
+
+	newMAPIMachineSet := func() *machinev1beta1.MachineSet {
+		return &machinev1beta1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: openshiftMAPINamespace},
+			Spec:       machinev1beta1.MachineSetSpec{AuthoritativeAPI: machinev1beta1.MachineAuthorityClusterAPI},
+			Status:     machinev1beta1.MachineSetStatus{AuthoritativeAPI: machinev1beta1.MachineAuthorityClusterAPI},
+		}
+	}
+
+	newAWSMachineTemplate := func(spec awsv1.AWSMachineSpec) *awsv1.AWSMachineTemplate {
+		return &awsv1.AWSMachineTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: openshiftCAPINamespace},
+			Spec:       awsv1.AWSMachineTemplateSpec{Template: awsv1.AWSMachineTemplateResource{Spec: spec}},
+		}
+	}
+
+	cluster := &v1beta1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName, Namespace: openshiftCAPINamespace},
+		Spec: v1beta1.ClusterSpec{InfrastructureRef: &corev1.ObjectReference{
+			APIVersion: awsv1.GroupVersion.String(), Kind: "AWSCluster", Name: clusterName,
+		}},
+	}
+
+	awsCluster := &awsv1.AWSCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: clusterName, Namespace: openshiftCAPINamespace},
+		Spec:       awsv1.AWSClusterSpec{Region: "us-east-1"},
+	}
+
+	newCAPIMachineSet := func(replicas int32) *v1beta1.MachineSet {
+		return &v1beta1.MachineSet{
+			TypeMeta:   metav1.TypeMeta{APIVersion: v1beta1.GroupVersion.String(), Kind: "MachineSet"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: openshiftCAPINamespace},
+			Spec: v1beta1.MachineSetSpec{
+				ClusterName: clusterName,
+				Replicas:    ptr.To(replicas),
+				Template: v1beta1.MachineTemplateSpec{Spec: v1beta1.MachineSpec{
+					ClusterName: clusterName,
+					InfrastructureRef: corev1.ObjectReference{
+						APIVersion: awsv1.GroupVersion.String(), Kind: "AWSMachineTemplate", Name: name,
+					},
+				}},
+			},
+		}
+	}
+
+	newRequest := func(oldObj, newObj client.Object) admission.Request {
+		oldRaw, err := json.Marshal(oldObj)
+		Expect(err).ToNot(HaveOccurred())
+
+		newRaw, err := json.Marshal(newObj)
+		Expect(err).ToNot(HaveOccurred())
+
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Name:      newObj.GetName(),
+			Namespace: newObj.GetNamespace(),
+			Operation: admissionv1.Update,
+			UserInfo:  authenticationv1.UserInfo{Username: "kube:admin"},
+			OldObject: runtime.RawExtension{Raw: oldRaw},
+			Object:    runtime.RawExtension{Raw: newRaw},
+		}}
+	}
+
+	DescribeTable("Handle",
+		func(objs []client.Object, req admission.Request, expectWarnings []string) {
+			scheme := runtime.NewScheme()
+			utilruntime.Must(awsv1.AddToScheme(scheme))
+			utilruntime.Must(machinev1beta1.AddToScheme(scheme))
+			utilruntime.Must(v1beta1.AddToScheme(scheme))
+
+			mirrorWebhook := &MirrorWebhook{client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()}
+
+			resp := admission.WithCustomValidator(scheme, &v1beta1.MachineSet{}, mirrorWebhook).Handle(context.Background(), req)
+			Expect(resp.Allowed).To(BeTrue())
+			Expect(resp.Warnings).To(Equal(expectWarnings))
+		},
+		Entry("warns about the fields not reflected in the MAPI mirror",
+			[]client.Object{newMAPIMachineSet(), cluster, awsCluster, newAWSMachineTemplate(awsv1.AWSMachineSpec{
+				InstanceType:      "m6i.xlarge",
+				NetworkInterfaces: []string{"eni-0123456789"},
+			})},
+			newRequest(newCAPIMachineSet(1), newCAPIMachineSet(2)),
+			[]string{`spec.networkInterfaces: Invalid value: []string{"eni-0123456789"}: networkInterfaces are not supported:` +
+				" not reflected in the Machine API mirror openshift-machine-api/worker, which is not synchronized while it is set"}),
+		Entry("does not warn when the spec is converted to the MAPI mirror",
+			[]client.Object{newMAPIMachineSet(), cluster, awsCluster, newAWSMachineTemplate(awsv1.AWSMachineSpec{InstanceType: "m6i.xlarge"})},
+			newRequest(newCAPIMachineSet(1), newCAPIMachineSet(2)),
+			[]string(nil)),
+		Entry("does not warn without MAPI mirror",
+			[]client.Object{cluster, awsCluster, newAWSMachineTemplate(awsv1.AWSMachineSpec{
+				InstanceType:      "m6i.xlarge",
+				NetworkInterfaces: []string{"eni-0123456789"},
+			})},
+			newRequest(newCAPIMachineSet(1), newCAPIMachineSet(2)),
+			[]string(nil)),
+		Entry("does not warn when the infrastructure objects do not exist",
+			[]client.Object{newMAPIMachineSet()},
+			newRequest(newCAPIMachineSet(1), newCAPIMachineSet(2)),
+			[]string(nil)),
+	)
+})
@@ -0,0 +1,114 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	awsv1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	azurev1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	gcpv1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	ibmcloudv1 "sigs.k8s.io/cluster-api-provider-ibmcloud/api/v1beta2"
+	openstackv1 "sigs.k8s.io/cluster-api-provider-openstack/api/v1beta1"
+	vspherev1 "sigs.k8s.io/cluster-api-provider-vsphere/apis/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/cluster-capi-operator/pkg/util"
+)
+
+// maxListedMachines is the number of Machines listed in the error message of a rejected deletion.
+const maxListedMachines = 5
+
+var errInfraClusterInUse = errors.New("deletion of InfraCluster is not allowed while Machines reference its cluster")
+
+// InfraClusterWebhook rejects the deletion of the InfraClusters of the CAPI namespace while Machines still reference their Cluster,
+// as the infrastructure provider would then attempt to deprovision the infrastructure of the running cluster, which it does not own.
+// The InfraClusters are additionally protected by a finalizer of the InfraCluster controller, for the deletions bypassing the webhook.
+type InfraClusterWebhook struct {
+	client client.Client
+}
+
+// SetupWebhookWithManager sets up the webhook for the InfraClusters with the manager.
+func (r *InfraClusterWebhook) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	r.client = mgr.GetClient()
+
+	for _, obj := range []runtime.Object{
+		&awsv1.AWSCluster{},
+		&azurev1.AzureCluster{},
+		&gcpv1.GCPCluster{},
+		&ibmcloudv1.IBMPowerVSCluster{},
+		&ibmcloudv1.IBMVPCCluster{},
+		&openstackv1.OpenStackCluster{},
+		&vspherev1.VSphereCluster{},
+	} {
+		if err := ctrl.NewWebhookManagedBy(mgr).
+			WithValidator(r).
+			For(obj).
+			Complete(); err != nil {
+			return fmt.Errorf("failed to create webhook for %T: %w", obj, err)
+		}
+	}
+
+	return nil
+}
+
+var _ webhook.CustomValidator = &InfraClusterWebhook{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
+func (r *InfraClusterWebhook) ValidateCreate(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+func (r *InfraClusterWebhook) ValidateUpdate(_ context.Context, _, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
+func (r *InfraClusterWebhook) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	infraCluster, ok := obj.(client.Object)
+	if !ok {
+		panic("expected to get an object implementing client.Object")
+	}
+
+	if infraCluster.GetNamespace() != openshiftCAPINamespace {
+		return nil, nil
+	}
+
+	machines, err := util.MachinesReferencingCluster(ctx, r.client, infraCluster.GetNamespace(), util.ClusterNameOf(infraCluster))
+	if err != nil {
+		return nil, fmt.Errorf("unable to check the Machines referencing the cluster of InfraCluster %s: %w", infraCluster.GetName(), err)
+	}
+
+	if len(machines) == 0 {
+		return nil, nil
+	}
+
+	listed := slices.Clone(machines[:min(len(machines), maxListedMachines)])
+	if len(machines) > len(listed) {
+		listed = append(listed, fmt.Sprintf("and %d more", len(machines)-len(listed)))
+	}
+
+	return nil, fmt.Errorf("%w: %s", errInfraClusterInUse, strings.Join(listed, ", "))
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	awsv1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	"sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("InfraClusterWebhook", func() {
+	const clusterName = "cluster-abc12"
+
+	newAWSCluster := func(namespace, region string, labels map[string]string) *awsv1.AWSCluster {
+		return &awsv1.AWSCluster{
+			TypeMeta:   metav1.TypeMeta{APIVersion: awsv1.GroupVersion.String(), Kind: "AWSCluster"},
+			ObjectMeta: metav1.ObjectMeta{Name: clusterName, Namespace: namespace, Labels: labels},
+			Spec:       awsv1.AWSClusterSpec{Region: region},
+		}
+	}
+
+	newMachine := func(name, namespace, cluster string) client.Object {
+		return &v1beta1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       v1beta1.MachineSpec{ClusterName: cluster},
+		}
+	}
+
+	newMachines := func(count int) []client.Object {
+		machines := []client.Object{}
+		for i := range count {
+			machines = append(machines, newMachine(fmt.Sprintf("worker-%d", i), openshiftCAPINamespace, clusterName))
+		}
+
+		return machines
+	}
+
+	rawExtension := func(obj client.Object) runtime.RawExtension {
+		if obj == nil {
+			return runtime.RawExtension{}
+		}
+
+		raw, err := json.Marshal(obj)
+		Expect(err).ToNot(HaveOccurred())
+
+		return runtime.RawExtension{Raw: raw}
+	}
+
+	newRequest := func(operation admissionv1.Operation, oldObj, newObj client.Object) admission.Request {
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Name:      clusterName,
+			Operation: operation,
+			UserInfo:  authenticationv1.UserInfo{Username: "kube:admin"},
+			OldObject: rawExtension(oldObj),
+			Object:    rawExtension(newObj),
+		}}
+	}
+
+	DescribeTable("Handle",
+		func(objs []client.Object, req admission.Request, expectAllowed bool, expectMessage string) {
+			scheme := runtime.NewScheme()
+			utilruntime.Must(awsv1.AddToScheme(scheme))
+			utilruntime.Must(v1beta1.AddToScheme(scheme))
+
+			infraClusterWebhook := &InfraClusterWebhook{client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()}
+
+			resp := admission.WithCustomValidator(scheme, &awsv1.AWSCluster{}, infraClusterWebhook).Handle(context.Background(), req)
+			Expect(resp.Allowed).To(Equal(expectAllowed))
+			Expect(resp.Result.Message).To(Equal(expectMessage))
+		},
+		Entry("allows creating an InfraCluster",
+			nil, newRequest(admissionv1.Create, nil, newAWSCluster(openshiftCAPINamespace, "us-east-1", nil)),
+			true, ""),
+		Entry("allows updating an InfraCluster referenced by Machines",
+			newMachines(1),
+			newRequest(admissionv1.Update, newAWSCluster(openshiftCAPINamespace, "us-east-1", nil), newAWSCluster(openshiftCAPINamespace, "us-east-2", nil)),
+			true, ""),
+		Entry("denies deleting an InfraCluster referenced by Machines",
+			newMachines(2), newRequest(admissionv1.Delete, newAWSCluster(openshiftCAPINamespace, "us-east-1", nil), nil),
+			false, "deletion of InfraCluster is not allowed while Machines reference its cluster: worker-0, worker-1"),
+		Entry("denies deleting an InfraCluster referenced by many Machines, listing the first ones",
+			newMachines(7), newRequest(admissionv1.Delete, newAWSCluster(openshiftCAPINamespace, "us-east-1", nil), nil),
+			false, "deletion of InfraCluster is not allowed while Machines reference its cluster: worker-0, worker-1, worker-2, worker-3, worker-4, and 2 more"),
+		Entry("denies deleting an InfraCluster whose cluster is named by its label",
+			[]client.Object{newMachine("worker-0", openshiftCAPINamespace, "cluster-labelled")},
+			newRequest(admissionv1.Delete, newAWSCluster(openshiftCAPINamespace, "us-east-1", map[string]string{v1beta1.ClusterNameLabel: "cluster-labelled"}), nil),
+			false, "deletion of InfraCluster is not allowed while Machines reference its cluster: worker-0"),
+		Entry("allows deleting an InfraCluster without Machines",
+			nil, newRequest(admissionv1.Delete, newAWSCluster(openshiftCAPINamespace, "us-east-1", nil), nil),
+			true, ""),
+		Entry("allows deleting an InfraCluster only referenced by Machines of other clusters or namespaces",
+			[]client.Object{newMachine("worker-0", openshiftCAPINamespace, "other"), newMachine("worker-1", "default", clusterName)},
+			newRequest(admissionv1.Delete, newAWSCluster(openshiftCAPINamespace, "us-east-1", nil), nil),
+			true, ""),
+		Entry("allows deleting InfraClusters in other namespaces",
+			[]client.Object{newMachine("worker-0", "default", clusterName)},
+			newRequest(admissionv1.Delete, newAWSCluster("default", "us-east-1", nil), nil),
+			true, ""),
+	)
+})
@@ -0,0 +1,212 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/cluster-api/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// awsAvailabilityZoneFilter is the subnet filter of CAPA pinning an AWSMachine to an availability zone.
+const awsAvailabilityZoneFilter = "availability-zone"
+
+// MachineDefaulterWebhook defaults the fields of the CAPI Machines and MachineSets of the CAPI namespace which can be
+// derived from the cluster, so users don't have to get them exactly right: the cluster name, the cluster name labels,
+// and the failure domain when the InfraMachine or InfraMachineTemplate pins one.
+// Only unset fields are defaulted, so the Machines and MachineSets mirrored from MAPI are left as they are.
+type MachineDefaulterWebhook struct {
+	client client.Client
+}
+
+// SetupWebhookWithManager sets up the webhook for CAPI Machines and MachineSets with the manager.
+func (r *MachineDefaulterWebhook) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	r.client = mgr.GetClient()
+
+	for _, obj := range []runtime.Object{
+		&v1beta1.Machine{},
+		&v1beta1.MachineSet{},
+	} {
+		if err := ctrl.NewWebhookManagedBy(mgr).
+			WithDefaulter(r).
+			For(obj).
+			Complete(); err != nil {
+			return fmt.Errorf("failed to create webhook for %T: %w", obj, err)
+		}
+	}
+
+	return nil
+}
+
+var _ webhook.CustomDefaulter = &MachineDefaulterWebhook{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the type.
+func (r *MachineDefaulterWebhook) Default(ctx context.Context, obj runtime.Object) error {
+	switch o := obj.(type) {
+	case *v1beta1.Machine:
+		if o.Namespace != openshiftCAPINamespace {
+			return nil
+		}
+
+		return r.defaultMachine(ctx, o)
+	case *v1beta1.MachineSet:
+		if o.Namespace != openshiftCAPINamespace {
+			return nil
+		}
+
+		return r.defaultMachineSet(ctx, o)
+	default:
+		panic(fmt.Sprintf("unexpected object type %T", obj))
+	}
+}
+
+// defaultMachine defaults the cluster name, its label, and the failure domain of the Machine.
+func (r *MachineDefaulterWebhook) defaultMachine(ctx context.Context, machine *v1beta1.Machine) error {
+	clusterName, err := r.defaultClusterName(ctx, machine.Spec.ClusterName)
+	if err != nil {
+		return err
+	}
+
+	machine.Spec.ClusterName = clusterName
+	machine.Labels = withDefaultLabel(machine.Labels, v1beta1.ClusterNameLabel, clusterName)
+
+	if machine.Spec.FailureDomain == nil {
+		failureDomain, err := r.infraFailureDomain(ctx, machine.Spec.InfrastructureRef, "spec")
+		if err != nil {
+			return err
+		}
+
+		machine.Spec.FailureDomain = failureDomain
+	}
+
+	return nil
+}
+
+// defaultMachineSet defaults the cluster name, the cluster name labels, the selector, and the failure domain of the MachineSet.
+func (r *MachineDefaulterWebhook) defaultMachineSet(ctx context.Context, machineSet *v1beta1.MachineSet) error {
+	clusterName, err := r.defaultClusterName(ctx, machineSet.Spec.ClusterName)
+	if err != nil {
+		return err
+	}
+
+	machineSet.Spec.ClusterName = clusterName
+	if machineSet.Spec.Template.Spec.ClusterName == "" {
+		machineSet.Spec.Template.Spec.ClusterName = clusterName
+	}
+	machineSet.Labels = withDefaultLabel(machineSet.Labels, v1beta1.ClusterNameLabel, clusterName)
+	machineSet.Spec.Template.Labels = withDefaultLabel(machineSet.Spec.Template.Labels, v1beta1.ClusterNameLabel, clusterName)
+
+	// Without selector, the Machines are selected by the name of the MachineSet, when it is a valid label value.
+	// The name is not known yet with a generateName, the selector is then left for the user to set.
+	selector := &machineSet.Spec.Selector
+	if len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0 &&
+		machineSet.Name != "" && len(validation.IsValidLabelValue(machineSet.Name)) == 0 {
+		selector.MatchLabels = map[string]string{
+			v1beta1.ClusterNameLabel:    clusterName,
+			v1beta1.MachineSetNameLabel: machineSet.Name,
+		}
+		machineSet.Spec.Template.Labels = withDefaultLabel(machineSet.Spec.Template.Labels, v1beta1.MachineSetNameLabel, machineSet.Name)
+	}
+
+	if machineSet.Spec.Template.Spec.FailureDomain == nil {
+		failureDomain, err := r.infraFailureDomain(ctx, machineSet.Spec.Template.Spec.InfrastructureRef, "spec", "template", "spec")
+		if err != nil {
+			return err
+		}
+
+		machineSet.Spec.Template.Spec.FailureDomain = failureDomain
+	}
+
+	return nil
+}
+
+// defaultClusterName returns the cluster name, defaulted to the name of the Cluster of the CAPI namespace,
+// which is named after the infrastructure name.
+func (r *MachineDefaulterWebhook) defaultClusterName(ctx context.Context, clusterName string) (string, error) {
+	if clusterName != "" {
+		return clusterName, nil
+	}
+
+	infrastructureObject, err := fetchInfrastructureObject(ctx, r.client)
+	if err != nil {
+		return "", fmt.Errorf("unable to default the cluster name: %w", err)
+	}
+
+	return infrastructureObject.Status.InfrastructureName, nil
+}
+
+// infraFailureDomain returns the failure domain pinned by the InfraMachine or InfraMachineTemplate at the path,
+// or nil when it does not pin one, or does not exist yet.
+// The failure domain of an AzureMachine is its failureDomain, the one of an AWSMachine is the availability zone
+// its subnet is filtered by.
+func (r *MachineDefaulterWebhook) infraFailureDomain(ctx context.Context, ref corev1.ObjectReference, specPath ...string) (*string, error) {
+	if ref.Name == "" {
+		return nil, nil
+	}
+
+	infra := &unstructured.Unstructured{}
+	infra.SetAPIVersion(ref.APIVersion)
+	infra.SetKind(ref.Kind)
+
+	if err := r.client.Get(ctx, client.ObjectKey{Namespace: openshiftCAPINamespace, Name: ref.Name}, infra); apierrors.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to get %s %s to default the failure domain: %w", ref.Kind, ref.Name, err)
+	}
+
+	if failureDomain, ok, _ := unstructured.NestedString(infra.Object, append(specPath, "failureDomain")...); ok && failureDomain != "" {
+		return ptr.To(failureDomain), nil
+	}
+
+	filters, _, _ := unstructured.NestedSlice(infra.Object, append(specPath, "subnet", "filters")...)
+	for _, f := range filters {
+		filter, ok := f.(map[string]interface{})
+		if !ok || filter["name"] != awsAvailabilityZoneFilter {
+			continue
+		}
+
+		if values, _, _ := unstructured.NestedStringSlice(filter, "values"); len(values) == 1 {
+			return ptr.To(values[0]), nil
+		}
+	}
+
+	return nil, nil
+}
+
+// withDefaultLabel returns the labels with the label set to the value, unless it is already set.
+func withDefaultLabel(labels map[string]string, key, value string) map[string]string {
+	if _, ok := labels[key]; ok {
+		return labels
+	}
+
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	labels[key] = value
+
+	return labels
+}
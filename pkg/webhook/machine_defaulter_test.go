@@ -0,0 +1,209 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	configv1 "github.com/openshift/api/config/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/utils/ptr"
+	awsv1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	"sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("MachineDefaulterWebhook", func() {
+	const (
+		infrastructureName = "cluster-abc12"
+		templateName       = "worker-template"
+	)
+
+	infrastructure := &configv1.Infrastructure{
+		ObjectMeta: metav1.ObjectMeta{Name: "cluster", Namespace: "default"},
+		Status:     configv1.InfrastructureStatus{InfrastructureName: infrastructureName},
+	}
+
+	newAWSMachineTemplate := func(filters ...awsv1.Filter) *unstructured.Unstructured {
+		template := &awsv1.AWSMachineTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: templateName, Namespace: openshiftCAPINamespace},
+			Spec: awsv1.AWSMachineTemplateSpec{Template: awsv1.AWSMachineTemplateResource{Spec: awsv1.AWSMachineSpec{
+				Subnet: &awsv1.AWSResourceReference{Filters: filters},
+			}}},
+		}
+
+		obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(template)
+		Expect(err).ToNot(HaveOccurred())
+
+		u := &unstructured.Unstructured{Object: obj}
+		u.SetAPIVersion(awsv1.GroupVersion.String())
+		u.SetKind("AWSMachineTemplate")
+
+		return u
+	}
+
+	templateRef := corev1.ObjectReference{APIVersion: awsv1.GroupVersion.String(), Kind: "AWSMachineTemplate", Name: templateName}
+
+	newWebhook := func(objs ...client.Object) *MachineDefaulterWebhook {
+		scheme := runtime.NewScheme()
+		utilruntime.Must(configv1.AddToScheme(scheme))
+		utilruntime.Must(awsv1.AddToScheme(scheme))
+		utilruntime.Must(v1beta1.AddToScheme(scheme))
+
+		return &MachineDefaulterWebhook{client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()}
+	}
+
+	DescribeTable("Default a MachineSet",
+		func(objs []client.Object, machineSet *v1beta1.MachineSet, expectMachineSet *v1beta1.MachineSet) {
+			Expect(newWebhook(objs...).Default(context.Background(), machineSet)).To(Succeed())
+			Expect(machineSet).To(Equal(expectMachineSet))
+		},
+		Entry("defaults the cluster name, its labels and the selector",
+			[]client.Object{infrastructure},
+			&v1beta1.MachineSet{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: openshiftCAPINamespace}},
+			&v1beta1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: openshiftCAPINamespace,
+					Labels: map[string]string{v1beta1.ClusterNameLabel: infrastructureName}},
+				Spec: v1beta1.MachineSetSpec{
+					ClusterName: infrastructureName,
+					Selector: metav1.LabelSelector{MatchLabels: map[string]string{
+						v1beta1.ClusterNameLabel: infrastructureName, v1beta1.MachineSetNameLabel: "worker"}},
+					Template: v1beta1.MachineTemplateSpec{
+						ObjectMeta: v1beta1.ObjectMeta{Labels: map[string]string{
+							v1beta1.ClusterNameLabel: infrastructureName, v1beta1.MachineSetNameLabel: "worker"}},
+						Spec: v1beta1.MachineSpec{ClusterName: infrastructureName},
+					},
+				},
+			},
+		),
+		Entry("keeps the cluster name of the template when it is set",
+			nil,
+			&v1beta1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: "worker-", Namespace: openshiftCAPINamespace},
+				Spec: v1beta1.MachineSetSpec{
+					ClusterName: infrastructureName,
+					Template:    v1beta1.MachineTemplateSpec{Spec: v1beta1.MachineSpec{ClusterName: "other"}},
+				},
+			},
+			&v1beta1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: "worker-", Namespace: openshiftCAPINamespace,
+					Labels: map[string]string{v1beta1.ClusterNameLabel: infrastructureName}},
+				Spec: v1beta1.MachineSetSpec{
+					ClusterName: infrastructureName,
+					Template: v1beta1.MachineTemplateSpec{
+						ObjectMeta: v1beta1.ObjectMeta{Labels: map[string]string{v1beta1.ClusterNameLabel: infrastructureName}},
+						Spec:       v1beta1.MachineSpec{ClusterName: "other"},
+					},
+				},
+			},
+		),
+		Entry("defaults the failure domain pinned by the subnet of the AWSMachineTemplate",
+			[]client.Object{newAWSMachineTemplate(awsv1.Filter{Name: awsAvailabilityZoneFilter, Values: []string{"us-east-1a"}})},
+			&v1beta1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: "worker-", Namespace: openshiftCAPINamespace},
+				Spec: v1beta1.MachineSetSpec{
+					ClusterName: infrastructureName,
+					Template:    v1beta1.MachineTemplateSpec{Spec: v1beta1.MachineSpec{InfrastructureRef: templateRef}},
+				},
+			},
+			&v1beta1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: "worker-", Namespace: openshiftCAPINamespace,
+					Labels: map[string]string{v1beta1.ClusterNameLabel: infrastructureName}},
+				Spec: v1beta1.MachineSetSpec{
+					ClusterName: infrastructureName,
+					Template: v1beta1.MachineTemplateSpec{
+						ObjectMeta: v1beta1.ObjectMeta{Labels: map[string]string{v1beta1.ClusterNameLabel: infrastructureName}},
+						Spec: v1beta1.MachineSpec{ClusterName: infrastructureName, InfrastructureRef: templateRef,
+							FailureDomain: ptr.To("us-east-1a")},
+					},
+				},
+			},
+		),
+		Entry("does not default the failure domain when the subnet is filtered by several availability zones",
+			[]client.Object{newAWSMachineTemplate(awsv1.Filter{Name: awsAvailabilityZoneFilter, Values: []string{"us-east-1a", "us-east-1b"}})},
+			&v1beta1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: "worker-", Namespace: openshiftCAPINamespace},
+				Spec: v1beta1.MachineSetSpec{
+					ClusterName: infrastructureName,
+					Template:    v1beta1.MachineTemplateSpec{Spec: v1beta1.MachineSpec{InfrastructureRef: templateRef}},
+				},
+			},
+			&v1beta1.MachineSet{
+				ObjectMeta: metav1.ObjectMeta{GenerateName: "worker-", Namespace: openshiftCAPINamespace,
+					Labels: map[string]string{v1beta1.ClusterNameLabel: infrastructureName}},
+				Spec: v1beta1.MachineSetSpec{
+					ClusterName: infrastructureName,
+					Template: v1beta1.MachineTemplateSpec{
+						ObjectMeta: v1beta1.ObjectMeta{Labels: map[string]string{v1beta1.ClusterNameLabel: infrastructureName}},
+						Spec:       v1beta1.MachineSpec{ClusterName: infrastructureName, InfrastructureRef: templateRef},
+					},
+				},
+			},
+		),
+		Entry("leaves MachineSets in other namespaces as they are",
+			nil,
+			&v1beta1.MachineSet{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "default"}},
+			&v1beta1.MachineSet{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "default"}},
+		),
+	)
+
+	DescribeTable("Default a Machine",
+		func(objs []client.Object, machine *v1beta1.Machine, expectMachine *v1beta1.Machine) {
+			Expect(newWebhook(objs...).Default(context.Background(), machine)).To(Succeed())
+			Expect(machine).To(Equal(expectMachine))
+		},
+		Entry("defaults the cluster name and its label",
+			[]client.Object{infrastructure},
+			&v1beta1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: openshiftCAPINamespace}},
+			&v1beta1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: openshiftCAPINamespace,
+					Labels: map[string]string{v1beta1.ClusterNameLabel: infrastructureName}},
+				Spec: v1beta1.MachineSpec{ClusterName: infrastructureName},
+			},
+		),
+		Entry("keeps the cluster name, its label and the failure domain when they are set",
+			nil,
+			&v1beta1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: openshiftCAPINamespace,
+					Labels: map[string]string{v1beta1.ClusterNameLabel: "other"}},
+				Spec: v1beta1.MachineSpec{ClusterName: infrastructureName, FailureDomain: ptr.To("us-east-1b")},
+			},
+			&v1beta1.Machine{
+				ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: openshiftCAPINamespace,
+					Labels: map[string]string{v1beta1.ClusterNameLabel: "other"}},
+				Spec: v1beta1.MachineSpec{ClusterName: infrastructureName, FailureDomain: ptr.To("us-east-1b")},
+			},
+		),
+		Entry("leaves Machines in other namespaces as they are",
+			nil,
+			&v1beta1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "default"}},
+			&v1beta1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "default"}},
+		),
+	)
+
+	It("fails to default the cluster name without Infrastructure object", func() {
+		machine := &v1beta1.Machine{ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: openshiftCAPINamespace}}
+		Expect(newWebhook().Default(context.Background(), machine)).To(MatchError(ContainSubstring("unable to default the cluster name")))
+	})
+})
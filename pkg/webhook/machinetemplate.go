@@ -0,0 +1,186 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	awsv1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	azurev1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	gcpv1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// MachineTemplateWebhook rejects the InfraMachineTemplates of the CAPI namespace using fields the OpenShift integration can't honor,
+// e.g. fields relying on the cluster network being managed by the provider, while the cluster infrastructure is managed externally.
+// Such templates would otherwise only fail when their machines are provisioned.
+// Spec changes to the templates are applied to a rotated copy of the template, see rotateMachineTemplate.
+type MachineTemplateWebhook struct {
+	client client.Client
+}
+
+// SetupWebhookWithManager sets up the webhook for the InfraMachineTemplates with the manager.
+func (r *MachineTemplateWebhook) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	r.client = mgr.GetClient()
+
+	for _, obj := range []runtime.Object{
+		&awsv1.AWSMachineTemplate{},
+		&azurev1.AzureMachineTemplate{},
+		&gcpv1.GCPMachineTemplate{},
+	} {
+		if err := ctrl.NewWebhookManagedBy(mgr).
+			WithValidator(r).
+			For(obj).
+			Complete(); err != nil {
+			return fmt.Errorf("failed to create webhook for %T: %w", obj, err)
+		}
+	}
+
+	return nil
+}
+
+var _ webhook.CustomValidator = &MachineTemplateWebhook{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
+func (r *MachineTemplateWebhook) ValidateCreate(_ context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, validateMachineTemplate(obj)
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+func (r *MachineTemplateWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	if err := validateMachineTemplate(newObj); err != nil {
+		return nil, err
+	}
+
+	return nil, r.rotateMachineTemplate(ctx, oldObj, newObj)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
+func (r *MachineTemplateWebhook) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateMachineTemplate validates the InfraMachineTemplate, if it is in the CAPI namespace.
+func validateMachineTemplate(obj runtime.Object) error {
+	template, ok := obj.(client.Object)
+	if !ok {
+		panic("expected to get an object implementing client.Object")
+	}
+
+	if template.GetNamespace() != openshiftCAPINamespace {
+		return nil
+	}
+
+	specPath := field.NewPath("spec", "template", "spec")
+
+	var (
+		errs      field.ErrorList
+		groupKind schema.GroupKind
+	)
+
+	switch t := obj.(type) {
+	case *awsv1.AWSMachineTemplate:
+		errs = validateAWSMachineSpec(specPath, t.Spec.Template.Spec)
+		groupKind = awsv1.GroupVersion.WithKind("AWSMachineTemplate").GroupKind()
+	case *azurev1.AzureMachineTemplate:
+		errs = validateAzureMachineSpec(specPath, t.Spec.Template.Spec)
+		groupKind = azurev1.GroupVersion.WithKind("AzureMachineTemplate").GroupKind()
+	case *gcpv1.GCPMachineTemplate:
+		errs = validateGCPMachineSpec(specPath, t.Spec.Template.Spec)
+		groupKind = gcpv1.GroupVersion.WithKind("GCPMachineTemplate").GroupKind()
+	default:
+		panic(fmt.Sprintf("unexpected InfraMachineTemplate type %T", obj))
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(groupKind, template.GetName(), errs)
+}
+
+// validateAWSMachineSpec validates the AWSMachine spec of an AWSMachineTemplate of the CAPI namespace.
+func validateAWSMachineSpec(fldPath *field.Path, spec awsv1.AWSMachineSpec) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if spec.AMI.ID == nil || *spec.AMI.ID == "" {
+		errs = append(errs, field.Required(fldPath.Child("ami", "id"),
+			"OpenShift machines boot RHCOS, set the RHCOS AMI, e.g. the one of the worker MachineSets, as the AMI is not looked up"))
+	}
+
+	if spec.AMI.EKSOptimizedLookupType != nil {
+		errs = append(errs, field.Forbidden(fldPath.Child("ami", "eksLookupType"), "EKS optimized AMIs can't run OpenShift machines"))
+	}
+
+	for _, lookup := range []struct{ name, value string }{
+		{"imageLookupFormat", spec.ImageLookupFormat},
+		{"imageLookupOrg", spec.ImageLookupOrg},
+		{"imageLookupBaseOS", spec.ImageLookupBaseOS},
+	} {
+		if lookup.value != "" {
+			errs = append(errs, field.Forbidden(fldPath.Child(lookup.name), "AMIs are not looked up for OpenShift machines, set ami.id instead"))
+		}
+	}
+
+	if spec.Ignition == nil {
+		errs = append(errs, field.Required(fldPath.Child("ignition"),
+			"OpenShift machines are bootstrapped with Ignition, set the Ignition version and the UnencryptedUserData storage type"))
+	}
+
+	if spec.Subnet == nil || (spec.Subnet.ID == nil && len(spec.Subnet.Filters) == 0) {
+		errs = append(errs, field.Required(fldPath.Child("subnet"),
+			"the network of the cluster is not managed by CAPA, so the subnet can't be defaulted, set its ID or filters"))
+	}
+
+	if len(spec.SecurityGroupOverrides) > 0 {
+		errs = append(errs, field.Forbidden(fldPath.Child("securityGroupOverrides"),
+			"the security groups of the cluster are not managed by CAPA, use additionalSecurityGroups instead"))
+	}
+
+	return errs
+}
+
+// validateAzureMachineSpec validates the AzureMachine spec of an AzureMachineTemplate of the CAPI namespace.
+func validateAzureMachineSpec(fldPath *field.Path, spec azurev1.AzureMachineSpec) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if spec.Image == nil {
+		errs = append(errs, field.Required(fldPath.Child("image"),
+			"OpenShift machines boot RHCOS, set the RHCOS image, e.g. the one of the worker MachineSets, as the default image is not RHCOS"))
+	}
+
+	return errs
+}
+
+// validateGCPMachineSpec validates the GCPMachine spec of a GCPMachineTemplate of the CAPI namespace.
+func validateGCPMachineSpec(fldPath *field.Path, spec gcpv1.GCPMachineSpec) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if (spec.Image == nil || *spec.Image == "") && (spec.ImageFamily == nil || *spec.ImageFamily == "") {
+		errs = append(errs, field.Required(fldPath.Child("image"),
+			"OpenShift machines boot RHCOS, set the RHCOS image, e.g. the one of the worker MachineSets, as the default image is not RHCOS"))
+	}
+
+	return errs
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/cluster-capi-operator/pkg/util"
+)
+
+var errMachineTemplateRotated = errors.New("InfraMachineTemplates are immutable")
+
+// rotateMachineTemplate gives the InfraMachineTemplates of the CAPI namespace the ergonomics of editing a MAPI providerSpec:
+// when a user changes the spec of a template, the template is cloned with the new spec under a name suffixed with the hash of the spec,
+// and the MachineSets and MachineDeployments referencing the template are updated to reference the clone.
+// The update of the template itself is always rejected, with an error reporting the rotation, as the template is immutable.
+// Nothing is done for dry-run requests, the updates of system components and the updates not changing the spec.
+func (r *MachineTemplateWebhook) rotateMachineTemplate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	oldTemplate, ok := oldObj.(client.Object)
+	if !ok {
+		panic("expected to get an object implementing client.Object")
+	}
+
+	newTemplate, ok := newObj.(client.Object)
+	if !ok {
+		panic("expected to get an object implementing client.Object")
+	}
+
+	if newTemplate.GetNamespace() != openshiftCAPINamespace {
+		return nil
+	}
+
+	req, err := admission.RequestFromContext(ctx)
+	if err != nil || (req.DryRun != nil && *req.DryRun) || strings.HasPrefix(req.UserInfo.Username, systemUserPrefix) {
+		return nil
+	}
+
+	newSpec, err := util.MachineTemplateSpec(newTemplate)
+	if err != nil {
+		return err
+	}
+
+	oldSpec, err := util.MachineTemplateSpec(oldTemplate)
+	if err != nil {
+		return err
+	}
+
+	if equality.Semantic.DeepEqual(oldSpec, newSpec) {
+		return nil
+	}
+
+	gvk, err := r.client.GroupVersionKindFor(newTemplate)
+	if err != nil {
+		return fmt.Errorf("unable to get the kind of InfraMachineTemplate %s: %w", newTemplate.GetName(), err)
+	}
+
+	rotated, err := r.createRotatedMachineTemplate(ctx, newTemplate, newSpec)
+	if err != nil {
+		return err
+	}
+
+	references, err := r.updateMachineTemplateReferences(ctx, gvk.GroupKind(), oldTemplate.GetName(), rotated)
+	if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("%w: the change was applied to the new InfraMachineTemplate %s instead, now referenced by %s",
+		errMachineTemplateRotated, rotated, strings.Join(references, ", "))
+}
+
+// createRotatedMachineTemplate creates the clone of the InfraMachineTemplate named after the hash of its spec,
+// unless it already exists, and returns its name.
+func (r *MachineTemplateWebhook) createRotatedMachineTemplate(ctx context.Context, template client.Object, spec map[string]interface{}) (string, error) {
+	baseName := template.GetName()
+	if name, ok := template.GetAnnotations()[util.MachineTemplateBaseNameAnnotation]; ok && name != "" {
+		baseName = name
+	}
+
+	name, err := util.RotatedMachineTemplateName(baseName, spec)
+	if err != nil {
+		return "", err //nolint:wrapcheck
+	}
+
+	rotated, ok := template.DeepCopyObject().(client.Object)
+	if !ok {
+		panic("expected to get an object implementing client.Object")
+	}
+
+	rotated.SetName(name)
+	rotated.SetResourceVersion("")
+	rotated.SetUID("")
+	rotated.SetGeneration(0)
+	rotated.SetCreationTimestamp(metav1.Time{})
+	rotated.SetManagedFields(nil)
+	rotated.SetOwnerReferences(nil)
+	rotated.SetFinalizers(nil)
+
+	annotations := rotated.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+
+	annotations[util.MachineTemplateBaseNameAnnotation] = baseName
+	rotated.SetAnnotations(annotations)
+
+	if err := r.client.Create(ctx, rotated); err != nil && !apierrors.IsAlreadyExists(err) {
+		return "", fmt.Errorf("unable to create InfraMachineTemplate %s: %w", name, err)
+	}
+
+	return name, nil
+}
+
+// updateMachineTemplateReferences points the MachineSets and MachineDeployments of the CAPI namespace referencing the InfraMachineTemplate
+// to the rotated one, and returns a description of each of them.
+// Updating a MachineSet only changes the template of its new Machines, while a MachineDeployment rolls out its Machines.
+// The MachineSets whose MAPI mirror is authoritative are skipped, as their template is synchronized from the MAPI MachineSet.
+func (r *MachineTemplateWebhook) updateMachineTemplateReferences(ctx context.Context, groupKind schema.GroupKind, name, rotated string) ([]string, error) {
+	machineSets := &v1beta1.MachineSetList{}
+	if err := r.client.List(ctx, machineSets, client.InNamespace(openshiftCAPINamespace)); err != nil {
+		return nil, fmt.Errorf("unable to list MachineSets: %w", err)
+	}
+
+	machineDeployments := &v1beta1.MachineDeploymentList{}
+	if err := r.client.List(ctx, machineDeployments, client.InNamespace(openshiftCAPINamespace)); err != nil {
+		return nil, fmt.Errorf("unable to list MachineDeployments: %w", err)
+	}
+
+	references := []string{}
+
+	for i := range machineSets.Items {
+		machineSet := &machineSets.Items[i]
+
+		mapiAuthoritative, err := r.isMAPIMachineSetAuthoritative(ctx, machineSet.Name)
+		if err != nil {
+			return nil, err
+		} else if mapiAuthoritative {
+			continue
+		}
+
+		if updated, err := r.updateMachineTemplateReference(ctx, machineSet, &machineSet.Spec.Template.Spec.InfrastructureRef.Name,
+			machineSet.Spec.Template.Spec.InfrastructureRef.GroupVersionKind().GroupKind(), groupKind, name, rotated); err != nil {
+			return nil, err
+		} else if updated {
+			references = append(references, "MachineSet "+machineSet.Name)
+		}
+	}
+
+	for i := range machineDeployments.Items {
+		machineDeployment := &machineDeployments.Items[i]
+		if updated, err := r.updateMachineTemplateReference(ctx, machineDeployment, &machineDeployment.Spec.Template.Spec.InfrastructureRef.Name,
+			machineDeployment.Spec.Template.Spec.InfrastructureRef.GroupVersionKind().GroupKind(), groupKind, name, rotated); err != nil {
+			return nil, err
+		} else if updated {
+			references = append(references, "MachineDeployment "+machineDeployment.Name)
+		}
+	}
+
+	if len(references) == 0 {
+		references = append(references, "no MachineSet nor MachineDeployment")
+	}
+
+	return references, nil
+}
+
+// updateMachineTemplateReference sets the InfraMachineTemplate reference of the object to the rotated template,
+// when it references the template, and returns whether it was updated.
+func (r *MachineTemplateWebhook) updateMachineTemplateReference(ctx context.Context, obj client.Object, refName *string,
+	refGroupKind, groupKind schema.GroupKind, name, rotated string) (bool, error) {
+	if refGroupKind != groupKind || (*refName != name && *refName != rotated) {
+		return false, nil
+	}
+
+	if *refName == rotated {
+		return true, nil
+	}
+
+	patchBase, ok := obj.DeepCopyObject().(client.Object)
+	if !ok {
+		panic("expected to get an object implementing client.Object")
+	}
+
+	*refName = rotated
+
+	if err := r.client.Patch(ctx, obj, client.MergeFrom(patchBase)); err != nil {
+		return false, fmt.Errorf("unable to update the InfraMachineTemplate of %s: %w", obj.GetName(), err)
+	}
+
+	return true, nil
+}
+
+// isMAPIMachineSetAuthoritative returns whether the MAPI mirror of the CAPI MachineSet exists and is authoritative.
+func (r *MachineTemplateWebhook) isMAPIMachineSetAuthoritative(ctx context.Context, name string) (bool, error) {
+	mapiMachineSet := &machinev1beta1.MachineSet{}
+	if err := r.client.Get(ctx, client.ObjectKey{Namespace: openshiftMAPINamespace, Name: name}, mapiMachineSet); apierrors.IsNotFound(err) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("unable to get the Machine API mirror of MachineSet %s: %w", name, err)
+	}
+
+	return mapiMachineSet.Status.AuthoritativeAPI == machinev1beta1.MachineAuthorityMachineAPI, nil
+}
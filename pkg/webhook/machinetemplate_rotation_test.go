@@ -0,0 +1,256 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	awsv1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	"sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/openshift/cluster-capi-operator/pkg/util"
+)
+
+var _ = Describe("MachineTemplateWebhook rotation", func() {
+	var (
+		ctx     context.Context
+		scheme  *runtime.Scheme
+		webhook *MachineTemplateWebhook
+	)
+
+	newTemplate := func(name, instanceType string, annotations map[string]string) *awsv1.AWSMachineTemplate {
+		return &awsv1.AWSMachineTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: openshiftCAPINamespace, Annotations: annotations},
+			Spec: awsv1.AWSMachineTemplateSpec{
+				Template: awsv1.AWSMachineTemplateResource{Spec: awsv1.AWSMachineSpec{InstanceType: instanceType}},
+			},
+		}
+	}
+
+	rotatedName := func(baseName string, template *awsv1.AWSMachineTemplate) string {
+		spec, err := util.MachineTemplateSpec(template)
+		Expect(err).ToNot(HaveOccurred())
+
+		name, err := util.RotatedMachineTemplateName(baseName, spec)
+		Expect(err).ToNot(HaveOccurred())
+
+		return name
+	}
+
+	awsTemplateRef := func(name string) corev1.ObjectReference {
+		return corev1.ObjectReference{APIVersion: awsv1.GroupVersion.String(), Kind: "AWSMachineTemplate", Name: name}
+	}
+
+	newMachineSet := func(name string, ref corev1.ObjectReference) *v1beta1.MachineSet {
+		return &v1beta1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: openshiftCAPINamespace},
+			Spec: v1beta1.MachineSetSpec{
+				Template: v1beta1.MachineTemplateSpec{Spec: v1beta1.MachineSpec{InfrastructureRef: ref}},
+			},
+		}
+	}
+
+	newMachineDeployment := func(name string, ref corev1.ObjectReference) *v1beta1.MachineDeployment {
+		return &v1beta1.MachineDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: openshiftCAPINamespace},
+			Spec: v1beta1.MachineDeploymentSpec{
+				Template: v1beta1.MachineTemplateSpec{Spec: v1beta1.MachineSpec{InfrastructureRef: ref}},
+			},
+		}
+	}
+
+	newMAPIMachineSet := func(name string, authoritativeAPI machinev1beta1.MachineAuthority) *machinev1beta1.MachineSet {
+		return &machinev1beta1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: openshiftMAPINamespace},
+			Status:     machinev1beta1.MachineSetStatus{AuthoritativeAPI: authoritativeAPI},
+		}
+	}
+
+	infraRefName := func(obj client.Object) string {
+		Expect(webhook.client.Get(ctx, client.ObjectKeyFromObject(obj), obj)).To(Succeed())
+
+		switch obj := obj.(type) {
+		case *v1beta1.MachineSet:
+			return obj.Spec.Template.Spec.InfrastructureRef.Name
+		case *v1beta1.MachineDeployment:
+			return obj.Spec.Template.Spec.InfrastructureRef.Name
+		default:
+			Fail("unexpected object type")
+			return ""
+		}
+	}
+
+	BeforeEach(func() {
+		ctx = context.Background()
+
+		scheme = runtime.NewScheme()
+		utilruntime.Must(awsv1.AddToScheme(scheme))
+		utilruntime.Must(v1beta1.AddToScheme(scheme))
+		utilruntime.Must(machinev1beta1.AddToScheme(scheme))
+	})
+
+	Describe("createRotatedMachineTemplate", func() {
+		BeforeEach(func() {
+			webhook = &MachineTemplateWebhook{client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+		})
+
+		It("creates the template named after the hash of its spec", func() {
+			template := newTemplate("worker", "m5.xlarge", nil)
+			spec, err := util.MachineTemplateSpec(template)
+			Expect(err).ToNot(HaveOccurred())
+
+			name, err := webhook.createRotatedMachineTemplate(ctx, template, spec)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(name).To(Equal(rotatedName("worker", template)))
+			Expect(name).To(MatchRegexp(`^worker-[0-9a-f]{8}$`))
+
+			rotated := &awsv1.AWSMachineTemplate{}
+			Expect(webhook.client.Get(ctx, client.ObjectKey{Namespace: openshiftCAPINamespace, Name: name}, rotated)).To(Succeed())
+			Expect(rotated.Spec).To(Equal(template.Spec))
+			Expect(rotated.Annotations).To(HaveKeyWithValue(util.MachineTemplateBaseNameAnnotation, "worker"))
+		})
+
+		It("names a different spec differently", func() {
+			template := newTemplate("worker", "m5.xlarge", nil)
+			otherTemplate := newTemplate("worker", "m5.2xlarge", nil)
+
+			Expect(rotatedName("worker", template)).ToNot(Equal(rotatedName("worker", otherTemplate)))
+		})
+
+		It("truncates the base name so the name remains a valid DNS subdomain", func() {
+			baseName := strings.Repeat("a", validation.DNS1123SubdomainMaxLength)
+			template := newTemplate(baseName, "m5.xlarge", nil)
+			spec, err := util.MachineTemplateSpec(template)
+			Expect(err).ToNot(HaveOccurred())
+
+			name, err := webhook.createRotatedMachineTemplate(ctx, template, spec)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(name).To(HaveLen(validation.DNS1123SubdomainMaxLength))
+			Expect(name).To(MatchRegexp(`^a{244}-[0-9a-f]{8}$`))
+			Expect(validation.IsDNS1123Subdomain(name)).To(BeEmpty())
+
+			rotated := &awsv1.AWSMachineTemplate{}
+			Expect(webhook.client.Get(ctx, client.ObjectKey{Namespace: openshiftCAPINamespace, Name: name}, rotated)).To(Succeed())
+			Expect(rotated.Annotations).To(HaveKeyWithValue(util.MachineTemplateBaseNameAnnotation, baseName))
+		})
+
+		It("reuses the base name of an already rotated template", func() {
+			template := newTemplate("worker-0123abcd", "m5.xlarge", map[string]string{util.MachineTemplateBaseNameAnnotation: "worker"})
+			spec, err := util.MachineTemplateSpec(template)
+			Expect(err).ToNot(HaveOccurred())
+
+			name, err := webhook.createRotatedMachineTemplate(ctx, template, spec)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(name).To(Equal(rotatedName("worker", template)))
+
+			rotated := &awsv1.AWSMachineTemplate{}
+			Expect(webhook.client.Get(ctx, client.ObjectKey{Namespace: openshiftCAPINamespace, Name: name}, rotated)).To(Succeed())
+			Expect(rotated.Annotations).To(HaveKeyWithValue(util.MachineTemplateBaseNameAnnotation, "worker"))
+		})
+
+		It("returns the name of the template when it already exists", func() {
+			template := newTemplate("worker", "m5.xlarge", nil)
+			name := rotatedName("worker", template)
+
+			existing := newTemplate(name, "m5.xlarge", map[string]string{"existing": "true"})
+			webhook.client = fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing).Build()
+
+			spec, err := util.MachineTemplateSpec(template)
+			Expect(err).ToNot(HaveOccurred())
+
+			rotated, err := webhook.createRotatedMachineTemplate(ctx, template, spec)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(rotated).To(Equal(name))
+
+			Expect(webhook.client.Get(ctx, client.ObjectKeyFromObject(existing), existing)).To(Succeed())
+			Expect(existing.Annotations).To(HaveKeyWithValue("existing", "true"))
+		})
+	})
+
+	Describe("updateMachineTemplateReferences", func() {
+		const (
+			templateName = "worker"
+			rotated      = "worker-0123abcd"
+		)
+
+		groupKind := awsv1.GroupVersion.WithKind("AWSMachineTemplate").GroupKind()
+
+		It("updates the MachineSets and MachineDeployments referencing the template", func() {
+			machineSet := newMachineSet("worker-a", awsTemplateRef(templateName))
+			machineDeployment := newMachineDeployment("worker-b", awsTemplateRef(templateName))
+			webhook = &MachineTemplateWebhook{client: fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(machineSet, machineDeployment).Build()}
+
+			references, err := webhook.updateMachineTemplateReferences(ctx, groupKind, templateName, rotated)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(references).To(ConsistOf("MachineSet worker-a", "MachineDeployment worker-b"))
+			Expect(infraRefName(machineSet)).To(Equal(rotated))
+			Expect(infraRefName(machineDeployment)).To(Equal(rotated))
+		})
+
+		It("reports the MachineSets already referencing the rotated template", func() {
+			machineSet := newMachineSet("worker-a", awsTemplateRef(rotated))
+			webhook = &MachineTemplateWebhook{client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(machineSet).Build()}
+
+			references, err := webhook.updateMachineTemplateReferences(ctx, groupKind, templateName, rotated)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(references).To(ConsistOf("MachineSet worker-a"))
+			Expect(infraRefName(machineSet)).To(Equal(rotated))
+		})
+
+		It("does not update the references to other templates", func() {
+			otherName := newMachineSet("worker-a", awsTemplateRef("infra"))
+			otherKind := newMachineSet("worker-b", corev1.ObjectReference{
+				APIVersion: "infrastructure.cluster.x-k8s.io/v1beta1", Kind: "GCPMachineTemplate", Name: templateName,
+			})
+			webhook = &MachineTemplateWebhook{client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(otherName, otherKind).Build()}
+
+			references, err := webhook.updateMachineTemplateReferences(ctx, groupKind, templateName, rotated)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(references).To(ConsistOf("no MachineSet nor MachineDeployment"))
+			Expect(infraRefName(otherName)).To(Equal("infra"))
+			Expect(infraRefName(otherKind)).To(Equal(templateName))
+		})
+
+		It("skips the MachineSets whose MAPI mirror is authoritative", func() {
+			mapiAuthoritative := newMachineSet("worker-a", awsTemplateRef(templateName))
+			capiAuthoritative := newMachineSet("worker-b", awsTemplateRef(templateName))
+			webhook = &MachineTemplateWebhook{client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+				mapiAuthoritative, capiAuthoritative,
+				newMAPIMachineSet("worker-a", machinev1beta1.MachineAuthorityMachineAPI),
+				newMAPIMachineSet("worker-b", machinev1beta1.MachineAuthorityClusterAPI),
+			).Build()}
+
+			references, err := webhook.updateMachineTemplateReferences(ctx, groupKind, templateName, rotated)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(references).To(ConsistOf("MachineSet worker-b"))
+			Expect(infraRefName(mapiAuthoritative)).To(Equal(templateName))
+			Expect(infraRefName(capiAuthoritative)).To(Equal(rotated))
+		})
+	})
+})
@@ -0,0 +1,174 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/utils/ptr"
+	awsv1 "sigs.k8s.io/cluster-api-provider-aws/v2/api/v1beta2"
+	azurev1 "sigs.k8s.io/cluster-api-provider-azure/api/v1beta1"
+	gcpv1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("MachineTemplateWebhook", func() {
+	const templateName = "worker"
+
+	validAWSMachineSpec := func() awsv1.AWSMachineSpec {
+		return awsv1.AWSMachineSpec{
+			AMI:          awsv1.AMIReference{ID: ptr.To("ami-0123456789")},
+			InstanceType: "m6i.xlarge",
+			Ignition:     &awsv1.Ignition{Version: "3.4", StorageType: awsv1.IgnitionStorageTypeOptionUnencryptedUserData},
+			Subnet:       &awsv1.AWSResourceReference{ID: ptr.To("subnet-0123456789")},
+		}
+	}
+
+	newAWSMachineTemplate := func(namespace string, spec awsv1.AWSMachineSpec) *awsv1.AWSMachineTemplate {
+		return &awsv1.AWSMachineTemplate{
+			TypeMeta:   metav1.TypeMeta{APIVersion: awsv1.GroupVersion.String(), Kind: "AWSMachineTemplate"},
+			ObjectMeta: metav1.ObjectMeta{Name: templateName, Namespace: namespace},
+			Spec:       awsv1.AWSMachineTemplateSpec{Template: awsv1.AWSMachineTemplateResource{Spec: spec}},
+		}
+	}
+
+	newAzureMachineTemplate := func(spec azurev1.AzureMachineSpec) *azurev1.AzureMachineTemplate {
+		return &azurev1.AzureMachineTemplate{
+			TypeMeta:   metav1.TypeMeta{APIVersion: azurev1.GroupVersion.String(), Kind: "AzureMachineTemplate"},
+			ObjectMeta: metav1.ObjectMeta{Name: templateName, Namespace: openshiftCAPINamespace},
+			Spec:       azurev1.AzureMachineTemplateSpec{Template: azurev1.AzureMachineTemplateResource{Spec: spec}},
+		}
+	}
+
+	newGCPMachineTemplate := func(spec gcpv1.GCPMachineSpec) *gcpv1.GCPMachineTemplate {
+		return &gcpv1.GCPMachineTemplate{
+			TypeMeta:   metav1.TypeMeta{APIVersion: gcpv1.GroupVersion.String(), Kind: "GCPMachineTemplate"},
+			ObjectMeta: metav1.ObjectMeta{Name: templateName, Namespace: openshiftCAPINamespace},
+			Spec:       gcpv1.GCPMachineTemplateSpec{Template: gcpv1.GCPMachineTemplateResource{Spec: spec}},
+		}
+	}
+
+	newCreateRequest := func(obj client.Object) admission.Request {
+		raw, err := json.Marshal(obj)
+		Expect(err).ToNot(HaveOccurred())
+
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Name:      obj.GetName(),
+			Namespace: obj.GetNamespace(),
+			Operation: admissionv1.Create,
+			UserInfo:  authenticationv1.UserInfo{Username: "kube:admin"},
+			Object:    runtime.RawExtension{Raw: raw},
+		}}
+	}
+
+	newUpdateRequest := func(oldObj, newObj client.Object) admission.Request {
+		req := newCreateRequest(newObj)
+
+		raw, err := json.Marshal(oldObj)
+		Expect(err).ToNot(HaveOccurred())
+
+		req.Operation = admissionv1.Update
+		req.OldObject = runtime.RawExtension{Raw: raw}
+
+		return req
+	}
+
+	DescribeTable("Handle",
+		func(obj runtime.Object, req admission.Request, expectAllowed bool, expectMessage string) {
+			scheme := runtime.NewScheme()
+			utilruntime.Must(awsv1.AddToScheme(scheme))
+			utilruntime.Must(azurev1.AddToScheme(scheme))
+			utilruntime.Must(gcpv1.AddToScheme(scheme))
+
+			templateWebhook := &MachineTemplateWebhook{client: fake.NewClientBuilder().WithScheme(scheme).Build()}
+
+			resp := admission.WithCustomValidator(scheme, obj, templateWebhook).Handle(context.Background(), req)
+			Expect(resp.Allowed).To(Equal(expectAllowed))
+			Expect(resp.Result.Message).To(Equal(expectMessage))
+		},
+		Entry("allows a valid AWSMachineTemplate",
+			&awsv1.AWSMachineTemplate{}, newCreateRequest(newAWSMachineTemplate(openshiftCAPINamespace, validAWSMachineSpec())),
+			true, ""),
+		Entry("denies an AWSMachineTemplate without AMI, Ignition and subnet",
+			&awsv1.AWSMachineTemplate{},
+			newCreateRequest(newAWSMachineTemplate(openshiftCAPINamespace, awsv1.AWSMachineSpec{InstanceType: "m6i.xlarge"})),
+			false, `AWSMachineTemplate.infrastructure.cluster.x-k8s.io "worker" is invalid: [`+
+				`spec.template.spec.ami.id: Required value: OpenShift machines boot RHCOS, set the RHCOS AMI, e.g. the one of the worker MachineSets, as the AMI is not looked up, `+
+				`spec.template.spec.ignition: Required value: OpenShift machines are bootstrapped with Ignition, set the Ignition version and the UnencryptedUserData storage type, `+
+				`spec.template.spec.subnet: Required value: the network of the cluster is not managed by CAPA, so the subnet can't be defaulted, set its ID or filters]`),
+		Entry("denies an AWSMachineTemplate looking up its AMI",
+			&awsv1.AWSMachineTemplate{},
+			newCreateRequest(newAWSMachineTemplate(openshiftCAPINamespace, func() awsv1.AWSMachineSpec {
+				spec := validAWSMachineSpec()
+				spec.ImageLookupOrg = "123456789012"
+				return spec
+			}())),
+			false, `AWSMachineTemplate.infrastructure.cluster.x-k8s.io "worker" is invalid: `+
+				`spec.template.spec.imageLookupOrg: Forbidden: AMIs are not looked up for OpenShift machines, set ami.id instead`),
+		Entry("denies an AWSMachineTemplate overriding the security groups",
+			&awsv1.AWSMachineTemplate{},
+			newCreateRequest(newAWSMachineTemplate(openshiftCAPINamespace, func() awsv1.AWSMachineSpec {
+				spec := validAWSMachineSpec()
+				spec.SecurityGroupOverrides = map[awsv1.SecurityGroupRole]string{awsv1.SecurityGroupNode: "sg-0123456789"}
+				return spec
+			}())),
+			false, `AWSMachineTemplate.infrastructure.cluster.x-k8s.io "worker" is invalid: `+
+				`spec.template.spec.securityGroupOverrides: Forbidden: the security groups of the cluster are not managed by CAPA, use additionalSecurityGroups instead`),
+		Entry("denies updating an AWSMachineTemplate to an invalid spec",
+			&awsv1.AWSMachineTemplate{},
+			newUpdateRequest(newAWSMachineTemplate(openshiftCAPINamespace, validAWSMachineSpec()), newAWSMachineTemplate(openshiftCAPINamespace, func() awsv1.AWSMachineSpec {
+				spec := validAWSMachineSpec()
+				spec.Subnet = nil
+				return spec
+			}())),
+			false, `AWSMachineTemplate.infrastructure.cluster.x-k8s.io "worker" is invalid: `+
+				`spec.template.spec.subnet: Required value: the network of the cluster is not managed by CAPA, so the subnet can't be defaulted, set its ID or filters`),
+		Entry("allows updating an AWSMachineTemplate without spec change",
+			&awsv1.AWSMachineTemplate{},
+			newUpdateRequest(newAWSMachineTemplate(openshiftCAPINamespace, validAWSMachineSpec()), newAWSMachineTemplate(openshiftCAPINamespace, validAWSMachineSpec())),
+			true, ""),
+		Entry("allows invalid AWSMachineTemplates in other namespaces",
+			&awsv1.AWSMachineTemplate{}, newCreateRequest(newAWSMachineTemplate("default", awsv1.AWSMachineSpec{InstanceType: "m6i.xlarge"})),
+			true, ""),
+		Entry("allows an AzureMachineTemplate with an image",
+			&azurev1.AzureMachineTemplate{},
+			newCreateRequest(newAzureMachineTemplate(azurev1.AzureMachineSpec{VMSize: "Standard_D4s_v3", Image: &azurev1.Image{ID: ptr.To("rhcos")}})),
+			true, ""),
+		Entry("denies an AzureMachineTemplate without image",
+			&azurev1.AzureMachineTemplate{}, newCreateRequest(newAzureMachineTemplate(azurev1.AzureMachineSpec{VMSize: "Standard_D4s_v3"})),
+			false, `AzureMachineTemplate.infrastructure.cluster.x-k8s.io "worker" is invalid: `+
+				`spec.template.spec.image: Required value: OpenShift machines boot RHCOS, set the RHCOS image, e.g. the one of the worker MachineSets, as the default image is not RHCOS`),
+		Entry("allows a GCPMachineTemplate with an image family",
+			&gcpv1.GCPMachineTemplate{},
+			newCreateRequest(newGCPMachineTemplate(gcpv1.GCPMachineSpec{InstanceType: "n2-standard-4", ImageFamily: ptr.To("rhcos")})),
+			true, ""),
+		Entry("denies a GCPMachineTemplate without image",
+			&gcpv1.GCPMachineTemplate{}, newCreateRequest(newGCPMachineTemplate(gcpv1.GCPMachineSpec{InstanceType: "n2-standard-4"})),
+			false, `GCPMachineTemplate.infrastructure.cluster.x-k8s.io "worker" is invalid: `+
+				`spec.template.spec.image: Required value: OpenShift machines boot RHCOS, set the RHCOS image, e.g. the one of the worker MachineSets, as the default image is not RHCOS`),
+	)
+})
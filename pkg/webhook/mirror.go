@@ -0,0 +1,199 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	openshiftMAPINamespace = "openshift-machine-api"
+
+	// systemUserPrefix is the prefix of the service accounts and system components, such as the sync controllers,
+	// which are allowed to update the non-authoritative mirror.
+	systemUserPrefix = "system:"
+)
+
+var errNonAuthoritativeMirrorSpecChange = errors.New("spec changes to the non-authoritative mirror are overwritten by synchronization")
+
+// MirrorWebhook rejects spec changes made by users to the non-authoritative copy of a Machine or MachineSet
+// mirrored between MAPI and CAPI, as synchronization would immediately overwrite them.
+// Changes to a paused copy are allowed with a warning, as they are only overwritten once synchronization resumes.
+type MirrorWebhook struct {
+	client client.Client
+}
+
+// SetupWebhookWithManager sets up the webhook for MAPI and CAPI Machines and MachineSets with the manager.
+func (r *MirrorWebhook) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	r.client = mgr.GetClient()
+
+	for _, obj := range []runtime.Object{
+		&machinev1beta1.Machine{},
+		&machinev1beta1.MachineSet{},
+		&v1beta1.Machine{},
+		&v1beta1.MachineSet{},
+	} {
+		if err := ctrl.NewWebhookManagedBy(mgr).
+			WithValidator(r).
+			For(obj).
+			Complete(); err != nil {
+			return fmt.Errorf("failed to create webhook for %T: %w", obj, err)
+		}
+	}
+
+	return nil
+}
+
+var _ webhook.CustomValidator = &MirrorWebhook{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
+func (r *MirrorWebhook) ValidateCreate(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+func (r *MirrorWebhook) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	if req, err := admission.RequestFromContext(ctx); err == nil && strings.HasPrefix(req.UserInfo.Username, systemUserPrefix) {
+		return nil, nil
+	}
+
+	authoritative, err := r.authoritativeResource(ctx, oldObj, newObj)
+	if err != nil {
+		return nil, err
+	}
+
+	if authoritative == "" {
+		return r.conversionWarnings(ctx, oldObj, newObj), nil
+	}
+
+	newMeta, ok := newObj.(metav1.Object)
+	if !ok {
+		panic("expected to get an object with metadata")
+	}
+
+	if annotations.HasPaused(newMeta) {
+		return admission.Warnings{fmt.Sprintf("synchronization is paused, spec changes will be overwritten by %s once the %s annotation is removed",
+			authoritative, v1beta1.PausedAnnotation)}, nil
+	}
+
+	return nil, fmt.Errorf("%w: update %s instead, or pause synchronization with the %s annotation",
+		errNonAuthoritativeMirrorSpecChange, authoritative, v1beta1.PausedAnnotation)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
+func (r *MirrorWebhook) ValidateDelete(_ context.Context, _ runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// authoritativeResource returns a description of the authoritative resource when the update changes the spec of the
+// non-authoritative mirror, or an empty string otherwise.
+func (r *MirrorWebhook) authoritativeResource(ctx context.Context, oldObj, newObj runtime.Object) (string, error) {
+	switch newObj := newObj.(type) {
+	case *machinev1beta1.Machine:
+		oldMachine, ok := oldObj.(*machinev1beta1.Machine)
+		if !ok {
+			panic("expected to get an of object of type v1beta1.Machine")
+		}
+
+		// The authoritative API is how users move the machine between APIs, so it can always be changed.
+		oldSpec, newSpec := oldMachine.Spec.DeepCopy(), newObj.Spec.DeepCopy()
+		oldSpec.AuthoritativeAPI, newSpec.AuthoritativeAPI = "", ""
+
+		if newObj.Namespace != openshiftMAPINamespace || oldMachine.Status.AuthoritativeAPI != machinev1beta1.MachineAuthorityClusterAPI ||
+			equality.Semantic.DeepEqual(oldSpec, newSpec) {
+			return "", nil
+		}
+
+		return fmt.Sprintf("Cluster API Machine %s/%s", openshiftCAPINamespace, newObj.Name), nil
+	case *machinev1beta1.MachineSet:
+		oldMachineSet, ok := oldObj.(*machinev1beta1.MachineSet)
+		if !ok {
+			panic("expected to get an of object of type v1beta1.MachineSet")
+		}
+
+		oldSpec, newSpec := oldMachineSet.Spec.DeepCopy(), newObj.Spec.DeepCopy()
+		oldSpec.AuthoritativeAPI, newSpec.AuthoritativeAPI = "", ""
+
+		if newObj.Namespace != openshiftMAPINamespace || oldMachineSet.Status.AuthoritativeAPI != machinev1beta1.MachineAuthorityClusterAPI ||
+			equality.Semantic.DeepEqual(oldSpec, newSpec) {
+			return "", nil
+		}
+
+		return fmt.Sprintf("Cluster API MachineSet %s/%s", openshiftCAPINamespace, newObj.Name), nil
+	case *v1beta1.Machine:
+		oldMachine, ok := oldObj.(*v1beta1.Machine)
+		if !ok {
+			panic("expected to get an of object of type v1beta1.Machine")
+		}
+
+		if newObj.Namespace != openshiftCAPINamespace || equality.Semantic.DeepEqual(oldMachine.Spec, newObj.Spec) {
+			return "", nil
+		}
+
+		return r.mapiAuthoritativeResource(ctx, &machinev1beta1.Machine{}, newObj.Name)
+	case *v1beta1.MachineSet:
+		oldMachineSet, ok := oldObj.(*v1beta1.MachineSet)
+		if !ok {
+			panic("expected to get an of object of type v1beta1.MachineSet")
+		}
+
+		if newObj.Namespace != openshiftCAPINamespace || equality.Semantic.DeepEqual(oldMachineSet.Spec, newObj.Spec) {
+			return "", nil
+		}
+
+		return r.mapiAuthoritativeResource(ctx, &machinev1beta1.MachineSet{}, newObj.Name)
+	default:
+		return "", nil
+	}
+}
+
+// mapiAuthoritativeResource returns a description of the MAPI mirror of a CAPI resource when the MAPI mirror is
+// authoritative, or an empty string otherwise.
+func (r *MirrorWebhook) mapiAuthoritativeResource(ctx context.Context, mapiObj client.Object, name string) (string, error) {
+	if err := r.client.Get(ctx, client.ObjectKey{Namespace: openshiftMAPINamespace, Name: name}, mapiObj); apierrors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get Machine API mirror: %w", err)
+	}
+
+	switch mapiObj := mapiObj.(type) {
+	case *machinev1beta1.Machine:
+		if mapiObj.Status.AuthoritativeAPI == machinev1beta1.MachineAuthorityMachineAPI {
+			return fmt.Sprintf("Machine API Machine %s/%s", openshiftMAPINamespace, name), nil
+		}
+	case *machinev1beta1.MachineSet:
+		if mapiObj.Status.AuthoritativeAPI == machinev1beta1.MachineAuthorityMachineAPI {
+			return fmt.Sprintf("Machine API MachineSet %s/%s", openshiftMAPINamespace, name), nil
+		}
+	}
+
+	return "", nil
+}
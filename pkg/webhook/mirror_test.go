@@ -0,0 +1,188 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("MirrorWebhook", func() {
+	const (
+		name = "worker"
+
+		capiMachineSetDenial = "spec changes to the non-authoritative mirror are overwritten by synchronization:" +
+			" update Cluster API MachineSet openshift-cluster-api/worker instead, or pause synchronization with the cluster.x-k8s.io/paused annotation"
+		mapiMachineSetDenial = "spec changes to the non-authoritative mirror are overwritten by synchronization:" +
+			" update Machine API MachineSet openshift-machine-api/worker instead, or pause synchronization with the cluster.x-k8s.io/paused annotation"
+		capiMachineDenial = "spec changes to the non-authoritative mirror are overwritten by synchronization:" +
+			" update Cluster API Machine openshift-cluster-api/worker instead, or pause synchronization with the cluster.x-k8s.io/paused annotation"
+		mapiMachineDenial = "spec changes to the non-authoritative mirror are overwritten by synchronization:" +
+			" update Machine API Machine openshift-machine-api/worker instead, or pause synchronization with the cluster.x-k8s.io/paused annotation"
+	)
+
+	newMAPIMachineSet := func(authority machinev1beta1.MachineAuthority, replicas int32) *machinev1beta1.MachineSet {
+		return &machinev1beta1.MachineSet{
+			TypeMeta:   metav1.TypeMeta{APIVersion: machinev1beta1.GroupVersion.String(), Kind: "MachineSet"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: openshiftMAPINamespace},
+			Spec:       machinev1beta1.MachineSetSpec{Replicas: ptr.To(replicas), AuthoritativeAPI: authority},
+			Status:     machinev1beta1.MachineSetStatus{AuthoritativeAPI: authority},
+		}
+	}
+
+	newMAPIMachine := func(authority machinev1beta1.MachineAuthority, providerID string) *machinev1beta1.Machine {
+		return &machinev1beta1.Machine{
+			TypeMeta:   metav1.TypeMeta{APIVersion: machinev1beta1.GroupVersion.String(), Kind: "Machine"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: openshiftMAPINamespace},
+			Spec:       machinev1beta1.MachineSpec{ProviderID: ptr.To(providerID), AuthoritativeAPI: authority},
+			Status:     machinev1beta1.MachineStatus{AuthoritativeAPI: authority},
+		}
+	}
+
+	newCAPIMachineSet := func(namespace string, replicas int32) *v1beta1.MachineSet {
+		return &v1beta1.MachineSet{
+			TypeMeta:   metav1.TypeMeta{APIVersion: v1beta1.GroupVersion.String(), Kind: "MachineSet"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       v1beta1.MachineSetSpec{Replicas: ptr.To(replicas)},
+		}
+	}
+
+	newCAPIMachine := func(namespace, providerID string) *v1beta1.Machine {
+		return &v1beta1.Machine{
+			TypeMeta:   metav1.TypeMeta{APIVersion: v1beta1.GroupVersion.String(), Kind: "Machine"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec:       v1beta1.MachineSpec{ProviderID: ptr.To(providerID)},
+		}
+	}
+
+	paused := func(obj client.Object) client.Object {
+		obj.SetAnnotations(map[string]string{v1beta1.PausedAnnotation: ""})
+		return obj
+	}
+
+	newRequest := func(username string, oldObj, newObj client.Object) admission.Request {
+		oldRaw, err := json.Marshal(oldObj)
+		Expect(err).ToNot(HaveOccurred())
+
+		newRaw, err := json.Marshal(newObj)
+		Expect(err).ToNot(HaveOccurred())
+
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Name:      newObj.GetName(),
+			Namespace: newObj.GetNamespace(),
+			Operation: admissionv1.Update,
+			UserInfo:  authenticationv1.UserInfo{Username: username},
+			OldObject: runtime.RawExtension{Raw: oldRaw},
+			Object:    runtime.RawExtension{Raw: newRaw},
+		}}
+	}
+
+	DescribeTable("Handle",
+		func(obj runtime.Object, objs []client.Object, req admission.Request, expectAllowed bool, expectMessage string, expectWarnings []string) {
+			scheme := runtime.NewScheme()
+			utilruntime.Must(machinev1beta1.AddToScheme(scheme))
+			utilruntime.Must(v1beta1.AddToScheme(scheme))
+
+			mirrorWebhook := &MirrorWebhook{client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()}
+
+			resp := admission.WithCustomValidator(scheme, obj, mirrorWebhook).Handle(context.Background(), req)
+			Expect(resp.Allowed).To(Equal(expectAllowed))
+			Expect(resp.Result.Message).To(Equal(expectMessage))
+			Expect(resp.Warnings).To(Equal(expectWarnings))
+		},
+		Entry("denies spec changes to a MAPI MachineSet mirroring an authoritative CAPI MachineSet",
+			&machinev1beta1.MachineSet{}, nil,
+			newRequest("kube:admin", newMAPIMachineSet(machinev1beta1.MachineAuthorityClusterAPI, 1),
+				newMAPIMachineSet(machinev1beta1.MachineAuthorityClusterAPI, 2)),
+			false, capiMachineSetDenial, nil),
+		Entry("allows spec changes to an authoritative MAPI MachineSet",
+			&machinev1beta1.MachineSet{}, nil,
+			newRequest("kube:admin", newMAPIMachineSet(machinev1beta1.MachineAuthorityMachineAPI, 1),
+				newMAPIMachineSet(machinev1beta1.MachineAuthorityMachineAPI, 2)),
+			true, "", nil),
+		Entry("allows changing the authoritative API of a non-authoritative MAPI MachineSet",
+			&machinev1beta1.MachineSet{}, nil,
+			newRequest("kube:admin", newMAPIMachineSet(machinev1beta1.MachineAuthorityClusterAPI, 1),
+				&machinev1beta1.MachineSet{
+					TypeMeta:   metav1.TypeMeta{APIVersion: machinev1beta1.GroupVersion.String(), Kind: "MachineSet"},
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: openshiftMAPINamespace},
+					Spec:       machinev1beta1.MachineSetSpec{Replicas: ptr.To[int32](1), AuthoritativeAPI: machinev1beta1.MachineAuthorityMachineAPI},
+				}),
+			true, "", nil),
+		Entry("allows spec changes to a paused non-authoritative MAPI MachineSet with a warning",
+			&machinev1beta1.MachineSet{}, nil,
+			newRequest("kube:admin", newMAPIMachineSet(machinev1beta1.MachineAuthorityClusterAPI, 1),
+				paused(newMAPIMachineSet(machinev1beta1.MachineAuthorityClusterAPI, 2))),
+			true, "", []string{"synchronization is paused, spec changes will be overwritten by Cluster API MachineSet openshift-cluster-api/worker" +
+				" once the cluster.x-k8s.io/paused annotation is removed"}),
+		Entry("allows system users to change the spec of a non-authoritative MAPI MachineSet",
+			&machinev1beta1.MachineSet{}, nil,
+			newRequest("system:serviceaccount:openshift-cluster-api:cluster-capi-operator",
+				newMAPIMachineSet(machinev1beta1.MachineAuthorityClusterAPI, 1), newMAPIMachineSet(machinev1beta1.MachineAuthorityClusterAPI, 2)),
+			true, "", nil),
+		Entry("denies spec changes to a MAPI Machine mirroring an authoritative CAPI Machine",
+			&machinev1beta1.Machine{}, nil,
+			newRequest("kube:admin", newMAPIMachine(machinev1beta1.MachineAuthorityClusterAPI, "aws:///us-east-1a/i-1"),
+				newMAPIMachine(machinev1beta1.MachineAuthorityClusterAPI, "aws:///us-east-1a/i-2")),
+			false, capiMachineDenial, nil),
+		Entry("allows spec changes to an authoritative MAPI Machine",
+			&machinev1beta1.Machine{}, nil,
+			newRequest("kube:admin", newMAPIMachine(machinev1beta1.MachineAuthorityMachineAPI, "aws:///us-east-1a/i-1"),
+				newMAPIMachine(machinev1beta1.MachineAuthorityMachineAPI, "aws:///us-east-1a/i-2")),
+			true, "", nil),
+		Entry("denies spec changes to a CAPI MachineSet mirroring an authoritative MAPI MachineSet",
+			&v1beta1.MachineSet{}, []client.Object{newMAPIMachineSet(machinev1beta1.MachineAuthorityMachineAPI, 1)},
+			newRequest("kube:admin", newCAPIMachineSet(openshiftCAPINamespace, 1), newCAPIMachineSet(openshiftCAPINamespace, 2)),
+			false, mapiMachineSetDenial, nil),
+		Entry("allows spec changes to a CAPI MachineSet whose MAPI mirror is not authoritative",
+			&v1beta1.MachineSet{}, []client.Object{newMAPIMachineSet(machinev1beta1.MachineAuthorityClusterAPI, 1)},
+			newRequest("kube:admin", newCAPIMachineSet(openshiftCAPINamespace, 1), newCAPIMachineSet(openshiftCAPINamespace, 2)),
+			true, "", nil),
+		Entry("allows spec changes to a CAPI MachineSet without MAPI mirror",
+			&v1beta1.MachineSet{}, nil,
+			newRequest("kube:admin", newCAPIMachineSet(openshiftCAPINamespace, 1), newCAPIMachineSet(openshiftCAPINamespace, 2)),
+			true, "", nil),
+		Entry("allows spec changes to CAPI MachineSets in other namespaces",
+			&v1beta1.MachineSet{}, []client.Object{newMAPIMachineSet(machinev1beta1.MachineAuthorityMachineAPI, 1)},
+			newRequest("kube:admin", newCAPIMachineSet("default", 1), newCAPIMachineSet("default", 2)),
+			true, "", nil),
+		Entry("denies spec changes to a CAPI Machine mirroring an authoritative MAPI Machine",
+			&v1beta1.Machine{}, []client.Object{newMAPIMachine(machinev1beta1.MachineAuthorityMachineAPI, "aws:///us-east-1a/i-1")},
+			newRequest("kube:admin", newCAPIMachine(openshiftCAPINamespace, "aws:///us-east-1a/i-1"),
+				newCAPIMachine(openshiftCAPINamespace, "aws:///us-east-1a/i-2")),
+			false, mapiMachineDenial, nil),
+		Entry("allows system users to change the spec of a non-authoritative CAPI Machine",
+			&v1beta1.Machine{}, []client.Object{newMAPIMachine(machinev1beta1.MachineAuthorityMachineAPI, "aws:///us-east-1a/i-1")},
+			newRequest("system:serviceaccount:openshift-cluster-api:cluster-capi-operator",
+				newCAPIMachine(openshiftCAPINamespace, "aws:///us-east-1a/i-1"), newCAPIMachine(openshiftCAPINamespace, "aws:///us-east-1a/i-2")),
+			true, "", nil),
+	)
+})
@@ -0,0 +1,76 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/cluster-capi-operator/pkg/operatorconfig"
+)
+
+// machineNamespacePath is the path of the namespace webhook. The CAPI Machines and MachineSets use a custom path,
+// as their generated validation path is already served by the MirrorWebhook.
+const machineNamespacePath = "/validate-cluster-x-k8s-io-v1beta1-machine-namespace"
+
+// MachineNamespaceWebhook rejects the creation of CAPI Machines, MachineSets and MachineDeployments outside of the CAPI namespace
+// and the machine namespaces of the ClusterCAPIOperatorConfig, as no installed provider watches other namespaces
+// and such resources would silently never be reconciled.
+// Only the namespace of the request is checked, so a single handler serves all the kinds.
+// Requests from system users, such as the CAPI MachineSet controller creating Machines, are always allowed:
+// they only create resources for ones that were already admitted, and must not depend on the operator being available.
+type MachineNamespaceWebhook struct {
+	client client.Client
+}
+
+// SetupWebhookWithManager sets up the webhook for CAPI Machines, MachineSets and MachineDeployments with the manager.
+func (r *MachineNamespaceWebhook) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	r.client = mgr.GetClient()
+
+	mgr.GetWebhookServer().Register(machineNamespacePath, &admission.Webhook{Handler: r})
+
+	return nil
+}
+
+var _ admission.Handler = &MachineNamespaceWebhook{}
+
+// Handle implements admission.Handler, allowing the request when its namespace is allowed.
+func (r *MachineNamespaceWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if strings.HasPrefix(req.UserInfo.Username, systemUserPrefix) {
+		return admission.Allowed("")
+	}
+
+	spec, err := operatorconfig.GetSpec(ctx, r.client)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("unable to get the allowed machine namespaces: %w", err))
+	}
+
+	allowed := append([]string{openshiftCAPINamespace}, spec.MachineNamespaces...)
+	if slices.Contains(allowed, req.Namespace) {
+		return admission.Allowed("")
+	}
+
+	return admission.Denied(fmt.Sprintf("%s can only be created in the namespaces %s, no installed provider would reconcile it in %s;"+
+		" additional namespaces can be allowed with the machineNamespaces of the ClusterCAPIOperatorConfig",
+		req.Kind.Kind, strings.Join(allowed, ", "), req.Namespace))
+}
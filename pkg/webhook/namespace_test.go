@@ -0,0 +1,75 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/cluster-capi-operator/pkg/operatorconfig"
+)
+
+var _ = Describe("MachineNamespaceWebhook", func() {
+	newRequest := func(username, namespace string) admission.Request {
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Group: "cluster.x-k8s.io", Version: "v1beta1", Kind: "MachineSet"},
+			Namespace: namespace,
+			Operation: admissionv1.Create,
+			UserInfo:  authenticationv1.UserInfo{Username: username},
+		}}
+	}
+
+	newOperatorConfig := func(machineNamespaces ...interface{}) client.Object {
+		config := operatorconfig.New()
+		config.SetName(operatorconfig.Name)
+		Expect(unstructured.SetNestedSlice(config.Object, machineNamespaces, "spec", "machineNamespaces")).To(Succeed())
+
+		return config
+	}
+
+	DescribeTable("Handle",
+		func(objs []client.Object, req admission.Request, expectAllowed bool, expectMessage string) {
+			webhook := &MachineNamespaceWebhook{client: fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(objs...).Build()}
+
+			resp := webhook.Handle(context.Background(), req)
+			Expect(resp.Allowed).To(Equal(expectAllowed))
+			Expect(resp.Result.Message).To(Equal(expectMessage))
+		},
+		Entry("allows users in the CAPI namespace", nil, newRequest("kube:admin", openshiftCAPINamespace), true, ""),
+		Entry("denies users in other namespaces without ClusterCAPIOperatorConfig", nil, newRequest("kube:admin", "default"), false,
+			"MachineSet can only be created in the namespaces openshift-cluster-api, no installed provider would reconcile it in default;"+
+				" additional namespaces can be allowed with the machineNamespaces of the ClusterCAPIOperatorConfig"),
+		Entry("allows users in the machine namespaces of the ClusterCAPIOperatorConfig",
+			[]client.Object{newOperatorConfig("capi-tenant")}, newRequest("kube:admin", "capi-tenant"), true, ""),
+		Entry("denies users in namespaces not listed in the ClusterCAPIOperatorConfig",
+			[]client.Object{newOperatorConfig("capi-tenant")}, newRequest("kube:admin", "default"), false,
+			"MachineSet can only be created in the namespaces openshift-cluster-api, capi-tenant, no installed provider would reconcile it in default;"+
+				" additional namespaces can be allowed with the machineNamespaces of the ClusterCAPIOperatorConfig"),
+		Entry("allows system users in any namespace", nil,
+			newRequest("system:serviceaccount:openshift-cluster-api:capi-controller-manager", "default"), true, ""),
+	)
+})
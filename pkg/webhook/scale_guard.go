@@ -0,0 +1,130 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apiserver/pkg/authentication/serviceaccount"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/openshift/cluster-capi-operator/pkg/controllers"
+)
+
+const (
+	// machineSetScalePath is the path of the scale guard webhook, serving the MAPI and CAPI MachineSets and their scale subresource.
+	machineSetScalePath = "/validate-machineset-scale"
+)
+
+// operatorServiceAccount is the user of the operator, whose synchronization controllers carry the replicas over
+// between the MachineSet mirrors during the transition.
+var operatorServiceAccount = serviceaccount.MakeUsername(controllers.DefaultManagedNamespace, controllers.OperatorServiceAccountName)
+
+// MachineSetScaleGuardWebhook rejects the scaling of a MAPI or CAPI MachineSet while the authoritative API of the MAPI MachineSet
+// is transitioning, as scaling one of the mirrors during the handoff can duplicate or orphan machines.
+// Both the updates of the scale subresource, e.g. by the autoscaler, and the updates of spec.replicas are guarded,
+// the scaling can be retried once the transition completes.
+type MachineSetScaleGuardWebhook struct {
+	client client.Client
+}
+
+// SetupWebhookWithManager sets up the webhook for MAPI and CAPI MachineSets with the manager.
+// A single handler serves the MachineSets of both APIs and their scale subresource, as only the replicas are compared.
+func (r *MachineSetScaleGuardWebhook) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	r.client = mgr.GetClient()
+
+	mgr.GetWebhookServer().Register(machineSetScalePath, &admission.Webhook{Handler: r})
+
+	return nil
+}
+
+var _ admission.Handler = &MachineSetScaleGuardWebhook{}
+
+// Handle implements admission.Handler, rejecting the requests changing the replicas of a transitioning MachineSet.
+func (r *MachineSetScaleGuardWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Namespace != openshiftMAPINamespace && req.Namespace != openshiftCAPINamespace {
+		return admission.Allowed("")
+	}
+
+	if req.UserInfo.Username == operatorServiceAccount {
+		return admission.Allowed("")
+	}
+
+	oldReplicas, err := replicasOf(req.OldObject.Raw)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	newReplicas, err := replicasOf(req.Object.Raw)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if oldReplicas == newReplicas {
+		return admission.Allowed("")
+	}
+
+	mapiMachineSet := &machinev1beta1.MachineSet{}
+	if err := r.client.Get(ctx, client.ObjectKey{Namespace: openshiftMAPINamespace, Name: req.Name}, mapiMachineSet); apierrors.IsNotFound(err) {
+		return admission.Allowed("")
+	} else if err != nil {
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("unable to get Machine API MachineSet %s: %w", req.Name, err))
+	}
+
+	if !isAuthoritativeAPITransitioning(mapiMachineSet) {
+		return admission.Allowed("")
+	}
+
+	return admission.Denied(fmt.Sprintf("MachineSet %s can't be scaled while its authoritative API transitions from %s to %s,"+
+		" as scaling during the handoff can duplicate or orphan machines; retry once the transition completes",
+		req.Name, mapiMachineSet.Status.AuthoritativeAPI, mapiMachineSet.Spec.AuthoritativeAPI))
+}
+
+// isAuthoritativeAPITransitioning returns whether the authoritative API of the MAPI MachineSet is being handed off,
+// i.e. the synchronization controllers are migrating it, or have yet to start migrating it to the requested API.
+func isAuthoritativeAPITransitioning(machineSet *machinev1beta1.MachineSet) bool {
+	status := machineSet.Status.AuthoritativeAPI
+
+	return status == machinev1beta1.MachineAuthorityMigrating ||
+		(status != "" && machineSet.Spec.AuthoritativeAPI != "" && status != machineSet.Spec.AuthoritativeAPI)
+}
+
+// replicasOf returns the spec.replicas of the raw MachineSet or Scale, which share the field, or -1 when it is not set.
+func replicasOf(raw []byte) (int64, error) {
+	obj := &unstructured.Unstructured{}
+	if err := runtime.DecodeInto(unstructured.UnstructuredJSONScheme, raw, obj); err != nil {
+		return 0, fmt.Errorf("unable to decode the object of the request: %w", err)
+	}
+
+	replicas, found, err := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if err != nil {
+		return 0, fmt.Errorf("unable to get the replicas of the object of the request: %w", err)
+	}
+
+	if !found {
+		return -1, nil
+	}
+
+	return replicas, nil
+}
@@ -0,0 +1,151 @@
+/*
+Copyright 2024 Red Hat, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package webhook
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	machinev1beta1 "github.com/openshift/api/machine/v1beta1"
+	admissionv1 "k8s.io/api/admission/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("MachineSetScaleGuardWebhook", func() {
+	const (
+		machineSetName = "worker"
+
+		machineSetWithReplicas    = `{"apiVersion":"machine.openshift.io/v1beta1","kind":"MachineSet","spec":{"replicas":%d}}`
+		machineSetWithoutReplicas = `{"apiVersion":"machine.openshift.io/v1beta1","kind":"MachineSet","spec":{}}`
+		scaleWithReplicas         = `{"apiVersion":"autoscaling/v1","kind":"Scale","spec":{"replicas":%d}}`
+		transitioningDenial       = "MachineSet worker can't be scaled while its authoritative API transitions from MachineAPI to ClusterAPI," +
+			" as scaling during the handoff can duplicate or orphan machines; retry once the transition completes"
+	)
+
+	newMAPIMachineSet := func(specAuthority, statusAuthority machinev1beta1.MachineAuthority) *machinev1beta1.MachineSet {
+		return &machinev1beta1.MachineSet{
+			ObjectMeta: metav1.ObjectMeta{Name: machineSetName, Namespace: openshiftMAPINamespace},
+			Spec:       machinev1beta1.MachineSetSpec{AuthoritativeAPI: specAuthority},
+			Status:     machinev1beta1.MachineSetStatus{AuthoritativeAPI: statusAuthority},
+		}
+	}
+
+	newRequest := func(namespace, username, oldObject, newObject string) admission.Request {
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Name:      machineSetName,
+			Namespace: namespace,
+			Operation: admissionv1.Update,
+			UserInfo:  authenticationv1.UserInfo{Username: username},
+			OldObject: runtime.RawExtension{Raw: []byte(oldObject)},
+			Object:    runtime.RawExtension{Raw: []byte(newObject)},
+		}}
+	}
+
+	DescribeTable("Handle",
+		func(objs []client.Object, req admission.Request, expectAllowed bool, expectMessage string) {
+			scheme := runtime.NewScheme()
+			utilruntime.Must(machinev1beta1.AddToScheme(scheme))
+
+			webhook := &MachineSetScaleGuardWebhook{client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()}
+
+			resp := webhook.Handle(context.Background(), req)
+			Expect(resp.Allowed).To(Equal(expectAllowed))
+			Expect(resp.Result.Message).To(Equal(expectMessage))
+		},
+		Entry("allows scaling a MachineSet whose authoritative API is settled",
+			[]client.Object{newMAPIMachineSet(machinev1beta1.MachineAuthorityClusterAPI, machinev1beta1.MachineAuthorityClusterAPI)},
+			newRequest(openshiftMAPINamespace, "kube:admin", fmt.Sprintf(machineSetWithReplicas, 1), fmt.Sprintf(machineSetWithReplicas, 2)),
+			true, ""),
+		Entry("denies scaling a transitioning MAPI MachineSet",
+			[]client.Object{newMAPIMachineSet(machinev1beta1.MachineAuthorityClusterAPI, machinev1beta1.MachineAuthorityMachineAPI)},
+			newRequest(openshiftMAPINamespace, "kube:admin", fmt.Sprintf(machineSetWithReplicas, 1), fmt.Sprintf(machineSetWithReplicas, 2)),
+			false, transitioningDenial),
+		Entry("denies scaling the CAPI mirror of a transitioning MAPI MachineSet",
+			[]client.Object{newMAPIMachineSet(machinev1beta1.MachineAuthorityClusterAPI, machinev1beta1.MachineAuthorityMachineAPI)},
+			newRequest(openshiftCAPINamespace, "kube:admin", fmt.Sprintf(machineSetWithReplicas, 1), fmt.Sprintf(machineSetWithReplicas, 2)),
+			false, transitioningDenial),
+		Entry("denies scaling a transitioning MachineSet through the scale subresource",
+			[]client.Object{newMAPIMachineSet(machinev1beta1.MachineAuthorityClusterAPI, machinev1beta1.MachineAuthorityMachineAPI)},
+			newRequest(openshiftMAPINamespace, "system:serviceaccount:openshift-machine-api:cluster-autoscaler",
+				fmt.Sprintf(scaleWithReplicas, 1), fmt.Sprintf(scaleWithReplicas, 2)),
+			false, transitioningDenial),
+		Entry("denies setting the unset replicas of a transitioning MachineSet",
+			[]client.Object{newMAPIMachineSet(machinev1beta1.MachineAuthorityClusterAPI, machinev1beta1.MachineAuthorityMachineAPI)},
+			newRequest(openshiftMAPINamespace, "kube:admin", machineSetWithoutReplicas, fmt.Sprintf(machineSetWithReplicas, 0)),
+			false, transitioningDenial),
+		Entry("allows updates of a transitioning MachineSet not changing the replicas",
+			[]client.Object{newMAPIMachineSet(machinev1beta1.MachineAuthorityClusterAPI, machinev1beta1.MachineAuthorityMachineAPI)},
+			newRequest(openshiftMAPINamespace, "kube:admin", fmt.Sprintf(machineSetWithReplicas, 1), fmt.Sprintf(machineSetWithReplicas, 1)),
+			true, ""),
+		Entry("allows updates of a transitioning MachineSet leaving the replicas unset",
+			[]client.Object{newMAPIMachineSet(machinev1beta1.MachineAuthorityClusterAPI, machinev1beta1.MachineAuthorityMachineAPI)},
+			newRequest(openshiftMAPINamespace, "kube:admin", machineSetWithoutReplicas, machineSetWithoutReplicas),
+			true, ""),
+		Entry("allows the operator to scale a transitioning MachineSet",
+			[]client.Object{newMAPIMachineSet(machinev1beta1.MachineAuthorityClusterAPI, machinev1beta1.MachineAuthorityMigrating)},
+			newRequest(openshiftCAPINamespace, "system:serviceaccount:openshift-cluster-api:cluster-capi-operator",
+				fmt.Sprintf(machineSetWithReplicas, 1), fmt.Sprintf(machineSetWithReplicas, 2)),
+			true, ""),
+		Entry("allows scaling a CAPI MachineSet without MAPI mirror", nil,
+			newRequest(openshiftCAPINamespace, "kube:admin", fmt.Sprintf(machineSetWithReplicas, 1), fmt.Sprintf(machineSetWithReplicas, 2)),
+			true, ""),
+		Entry("allows scaling MachineSets in other namespaces",
+			[]client.Object{newMAPIMachineSet(machinev1beta1.MachineAuthorityClusterAPI, machinev1beta1.MachineAuthorityMachineAPI)},
+			newRequest("default", "kube:admin", fmt.Sprintf(machineSetWithReplicas, 1), fmt.Sprintf(machineSetWithReplicas, 2)),
+			true, ""),
+	)
+
+	DescribeTable("replicasOf",
+		func(raw string, expectReplicas int64) {
+			replicas, err := replicasOf([]byte(raw))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(replicas).To(Equal(expectReplicas))
+		},
+		Entry("returns the replicas of a MachineSet", fmt.Sprintf(machineSetWithReplicas, 3), int64(3)),
+		Entry("returns zero replicas of a MachineSet", fmt.Sprintf(machineSetWithReplicas, 0), int64(0)),
+		Entry("returns -1 for a MachineSet without replicas", machineSetWithoutReplicas, int64(-1)),
+		Entry("returns the replicas of a Scale", fmt.Sprintf(scaleWithReplicas, 3), int64(3)),
+	)
+
+	It("fails to get the replicas of an invalid object", func() {
+		_, err := replicasOf([]byte(`{"spec":`))
+		Expect(err).To(MatchError(ContainSubstring("unable to decode the object of the request")))
+	})
+
+	DescribeTable("isAuthoritativeAPITransitioning",
+		func(specAuthority, statusAuthority machinev1beta1.MachineAuthority, expectTransitioning bool) {
+			Expect(isAuthoritativeAPITransitioning(newMAPIMachineSet(specAuthority, statusAuthority))).To(Equal(expectTransitioning))
+		},
+		Entry("when migrating", machinev1beta1.MachineAuthorityClusterAPI, machinev1beta1.MachineAuthorityMigrating, true),
+		Entry("when the status has yet to follow the spec to ClusterAPI",
+			machinev1beta1.MachineAuthorityClusterAPI, machinev1beta1.MachineAuthorityMachineAPI, true),
+		Entry("when the status has yet to follow the spec to MachineAPI",
+			machinev1beta1.MachineAuthorityMachineAPI, machinev1beta1.MachineAuthorityClusterAPI, true),
+		Entry("not when settled on MachineAPI", machinev1beta1.MachineAuthorityMachineAPI, machinev1beta1.MachineAuthorityMachineAPI, false),
+		Entry("not when settled on ClusterAPI", machinev1beta1.MachineAuthorityClusterAPI, machinev1beta1.MachineAuthorityClusterAPI, false),
+		Entry("not when the status is not set yet", machinev1beta1.MachineAuthorityClusterAPI, machinev1beta1.MachineAuthority(""), false),
+		Entry("not when the spec is not set", machinev1beta1.MachineAuthority(""), machinev1beta1.MachineAuthorityMachineAPI, false),
+	)
+})